@@ -0,0 +1,187 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+// Conditions and condition Reasons for the TinkerbellMachine object.
+
+// ProvisioningReason (Severity=Info) documents a TinkerbellMachine whose Ready condition is false because it is
+// still being provisioned, without any of its conditions reporting a problem.
+const ProvisioningReason = "Provisioning"
+
+const (
+	// WorkflowSucceededCondition reports on the state of the Tinkerbell Workflow provisioning the machine.
+	WorkflowSucceededCondition clusterv1.ConditionType = "WorkflowSucceeded"
+
+	// WorkflowRunningReason (Severity=Info) documents a TinkerbellMachine waiting for its Workflow to complete.
+	WorkflowRunningReason = "WorkflowRunning"
+
+	// WorkflowFailedReason (Severity=Error) documents a TinkerbellMachine whose Workflow failed. The condition
+	// message contains the failing action and the tail of its output.
+	WorkflowFailedReason = "WorkflowFailed"
+
+	// WorkflowTimeoutReason (Severity=Error) documents a TinkerbellMachine whose Workflow timed out.
+	WorkflowTimeoutReason = "WorkflowTimeout"
+)
+
+const (
+	// HardwareAvailableCondition reports whether the Hardware bound to the TinkerbellMachine still exists. It is
+	// only set once the bound Hardware went missing.
+	HardwareAvailableCondition clusterv1.ConditionType = "HardwareAvailable"
+
+	// HardwareMissingReason (Severity=Error) documents a TinkerbellMachine whose bound Hardware was deleted.
+	HardwareMissingReason = "HardwareMissing"
+)
+
+const (
+	// HardwarePausedCondition is set to true while the Hardware bound to the TinkerbellMachine is paused with the
+	// v1alpha1.tinkerbell.org/paused annotation, during which the machine is not reconciled and its Hardware is not
+	// changed. It is removed once the Hardware is unpaused.
+	HardwarePausedCondition clusterv1.ConditionType = "HardwarePaused"
+)
+
+const (
+	// HardwareClaimedCondition reports whether Hardware matching the affinity of the TinkerbellMachine was
+	// claimed for it. It is only set once the TinkerbellMachine waited for Hardware.
+	HardwareClaimedCondition clusterv1.ConditionType = "HardwareClaimed"
+
+	// InsufficientHardwareReason (Severity=Warning) documents a TinkerbellMachine waiting for Hardware matching
+	// its affinity to be added or released. The condition message names the hardware selector, which is also the
+	// selector label of the capt_unsatisfied_hardware_demand metric.
+	InsufficientHardwareReason = "InsufficientHardware"
+
+	// HardwarePoolUnavailableReason (Severity=Error) documents a TinkerbellMachine whose HardwarePool does not
+	// exist or does not allow the namespace of the TinkerbellMachine.
+	HardwarePoolUnavailableReason = "HardwarePoolUnavailable"
+
+	// MultipleHardwareClaimedReason (Severity=Error) documents a TinkerbellMachine owning several Hardware of which
+	// none is its spec.hardwareName, so CAPT cannot tell which one to keep. The owner labels of the Hardware not
+	// provisioned for the machine must be removed.
+	MultipleHardwareClaimedReason = "MultipleHardwareClaimed"
+)
+
+const (
+	// ImageAvailableCondition reports whether the OS image of the TinkerbellMachine could be reached before
+	// provisioning started. It is only set when the image pre-flight check is enabled.
+	ImageAvailableCondition clusterv1.ConditionType = "ImageAvailable"
+
+	// ImageUnavailableReason (Severity=Warning) documents a TinkerbellMachine whose OS image could not be
+	// reached. The machine is not powered on until it can.
+	ImageUnavailableReason = "ImageUnavailable"
+)
+
+const (
+	// BMCReadyCondition reports whether the BMC of the Hardware of the TinkerbellMachine can power it: its rufio
+	// Machine exists, has usable credentials and was contacted by rufio. It is only set on TinkerbellMachines whose
+	// Hardware power is managed through BMC Jobs, before provisioning starts.
+	BMCReadyCondition clusterv1.ConditionType = "BMCReady"
+
+	// BMCNotReadyReason (Severity=Warning) documents a TinkerbellMachine whose Hardware BMC cannot be used. The
+	// condition message contains the underlying problem, e.g. the rufio error. The machine is not powered on until
+	// the BMC is ready.
+	BMCNotReadyReason = "BMCNotReady"
+)
+
+const (
+	// ProvisioningSlotAcquiredCondition reports whether the TinkerbellMachine may start provisioning under the
+	// MaxConcurrentProvisioning limit and outside the MaintenanceWindows of its TinkerbellCluster. It is only set
+	// on TinkerbellMachines of clusters with a limit or which had to wait for a maintenance window.
+	ProvisioningSlotAcquiredCondition clusterv1.ConditionType = "ProvisioningSlotAcquired"
+
+	// WaitingForProvisioningSlotReason (Severity=Info) documents a TinkerbellMachine waiting for other machines
+	// of the cluster to finish provisioning.
+	WaitingForProvisioningSlotReason = "WaitingForProvisioningSlot"
+
+	// ProvisioningDeferredReason (Severity=Info) documents a TinkerbellMachine waiting for a maintenance window of
+	// its cluster to end before starting to provision.
+	ProvisioningDeferredReason = "ProvisioningDeferred"
+)
+
+const (
+	// WorkflowStagesSucceededCondition reports on the workflow stages run before the Workflow installing the OS.
+	// It is only set on TinkerbellMachines with workflow stages.
+	WorkflowStagesSucceededCondition clusterv1.ConditionType = "WorkflowStagesSucceeded"
+
+	// WorkflowStageRunningReason (Severity=Info) documents a TinkerbellMachine waiting for the Workflow of a
+	// stage to complete. The condition message names the stage.
+	WorkflowStageRunningReason = "WorkflowStageRunning"
+
+	// WorkflowStageFailedReason (Severity=Error) documents a TinkerbellMachine whose stage Workflow failed or
+	// timed out. The condition message names the stage and contains the failing action.
+	WorkflowStageFailedReason = "WorkflowStageFailed"
+)
+
+const (
+	// BootstrapSucceededCondition reports whether cloud-init bootstrapped the machine, as reported by the machine
+	// once cloud-init finished. It is only set on TinkerbellMachines whose OS reports its bootstrap result.
+	BootstrapSucceededCondition clusterv1.ConditionType = "BootstrapSucceeded"
+
+	// BootstrapFailedReason (Severity=Error) documents a TinkerbellMachine whose bootstrap data failed to run, e.g.
+	// kubeadm failed to join the node. The condition message contains the exit code of cloud-init and the end of
+	// its output.
+	BootstrapFailedReason = "BootstrapFailed"
+)
+
+const (
+	// BMCJobSucceededCondition reports on the state of the latest BMC Job run against the machine's hardware.
+	BMCJobSucceededCondition clusterv1.ConditionType = "BMCJobSucceeded"
+
+	// BMCJobRunningReason (Severity=Info) documents a TinkerbellMachine waiting for a BMC Job to complete.
+	BMCJobRunningReason = "BMCJobRunning"
+
+	// BMCJobFailedReason (Severity=Error) documents a TinkerbellMachine whose BMC Job failed.
+	BMCJobFailedReason = "BMCJobFailed"
+
+	// BMCJobRetryingReason (Severity=Warning) documents a TinkerbellMachine whose BMC Job failed and is retried
+	// after a back-off.
+	BMCJobRetryingReason = "BMCJobRetrying"
+)
+
+const (
+	// NodeHealthyCondition reports on the Node of the machine in the workload cluster. It is only set when
+	// TinkerbellMachines are configured to wait for their Node before being marked as Ready.
+	NodeHealthyCondition clusterv1.ConditionType = "NodeHealthy"
+
+	// NodeNotFoundReason (Severity=Info) documents a TinkerbellMachine waiting for its Node to join the cluster.
+	NodeNotFoundReason = "NodeNotFound"
+
+	// NodeNotReadyReason (Severity=Info) documents a TinkerbellMachine waiting for its Node to become Ready.
+	NodeNotReadyReason = "NodeNotReady"
+
+	// WorkloadClusterUnreachableReason (Severity=Info) documents a TinkerbellMachine whose Node could not be
+	// checked because the workload cluster API server is not reachable yet.
+	WorkloadClusterUnreachableReason = "WorkloadClusterUnreachable"
+
+	// KubeletVersionMatchesCondition reports whether the kubelet of the machine's Node runs the Kubernetes
+	// version requested by the Machine.
+	KubeletVersionMatchesCondition clusterv1.ConditionType = "KubeletVersionMatches"
+
+	// KubeletVersionMismatchReason (Severity=Warning) documents a Node running a different kubelet version than
+	// requested, usually caused by an OS image built for another Kubernetes version.
+	KubeletVersionMismatchReason = "KubeletVersionMismatch"
+)
+
+const (
+	// ControlPlaneEndpointReachableCondition reports whether the control plane endpoint of the TinkerbellCluster
+	// passed its probe. It is only set on TinkerbellClusters with a ControlPlaneEndpointProbe.
+	ControlPlaneEndpointReachableCondition clusterv1.ConditionType = "ControlPlaneEndpointReachable"
+
+	// ControlPlaneEndpointUnreachableReason (Severity=Warning) documents a TinkerbellCluster whose control plane
+	// endpoint failed its probe. The condition message contains the error of the probe.
+	ControlPlaneEndpointUnreachableReason = "ControlPlaneEndpointUnreachable"
+)
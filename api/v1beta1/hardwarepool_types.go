@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HardwarePoolSource selects Hardware of one namespace for a HardwarePool.
+type HardwarePoolSource struct {
+	// Namespace is the namespace of the Hardware.
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// Selector restricts the Hardware of the namespace to the Hardware matching it. All Hardware of the
+	// namespace is selected when it is not set.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// HardwarePoolSpec defines the desired state of HardwarePool.
+type HardwarePoolSpec struct {
+	// Sources select the Hardware of the pool.
+	// +kubebuilder:validation:MinItems=1
+	Sources []HardwarePoolSource `json:"sources"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=hardwarepools,scope=Cluster,categories=cluster-api,shortName=hwp
+// +kubebuilder:storageversion
+
+// HardwarePool is the Schema for the hardwarepools API. It lets TinkerbellMachines of several namespaces select
+// Hardware managed in other namespaces. TinkerbellMachines of a namespace may only use the pool when the service
+// accounts of the namespace are allowed by RBAC to "use" it.
+type HardwarePool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HardwarePoolSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HardwarePoolList contains a list of HardwarePool.
+type HardwarePoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HardwarePool `json:"items"`
+}
+
+//nolint:gochecknoinits
+func init() {
+	SchemeBuilder.Register(&HardwarePool{}, &HardwarePoolList{})
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
@@ -25,6 +26,18 @@ const (
 	// ClusterFinalizer allows ReconcileTinkerbellCluster to clean up Tinkerbell resources before
 	// removing it from the apiserver.
 	ClusterFinalizer = "tinkerbellcluster.infrastructure.cluster.x-k8s.io"
+
+	// ReleaseHardwareOnDeleteAnnotation can be set to "true" on a TinkerbellCluster to opt into releasing
+	// all Hardware claimed for the cluster when the TinkerbellCluster is deleted. It is equivalent to
+	// setting Spec.ReleaseHardwareOnDelete.
+	ReleaseHardwareOnDeleteAnnotation = "tinkerbellcluster.infrastructure.cluster.x-k8s.io/release-hardware-on-delete"
+
+	// DefaultAPIServerPort is the port of the Kubernetes API server of clusters not setting APIServerPort.
+	DefaultAPIServerPort = 6443
+
+	// DefaultImageLookupFormat is the ImageLookupFormat of clusters not setting one. The .gz suffix of gzip
+	// compressed images is replaced with the suffix of the image format of machines using other formats.
+	DefaultImageLookupFormat = "{{.BaseRegistry}}/{{.OSDistro}}-{{.OSVersion}}:{{.KubernetesVersion}}.gz"
 )
 
 // TinkerbellClusterSpec defines the desired state of TinkerbellCluster.
@@ -37,6 +50,23 @@ type TinkerbellClusterSpec struct {
 	// +optional
 	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint,omitempty"`
 
+	// APIServerPort is the port the Kubernetes API servers of the control plane machines listen on, which must
+	// match the port kube-vip and kubeadm are configured with. It is the port of the control plane endpoint unless
+	// the Cluster or ControlPlaneEndpoint set a different one, e.g. when a load balancer remaps the API port.
+	// Defaults to 6443.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	APIServerPort int32 `json:"apiServerPort,omitempty"`
+
+	// ControlPlaneEndpointProbe, when set, probes the control plane endpoint before the TinkerbellCluster is marked
+	// Ready, reporting the result in the ControlPlaneEndpointReachable condition. Only set it for endpoints served
+	// before the first control plane machine is provisioned, e.g. by an external load balancer: machines are not
+	// provisioned before the TinkerbellCluster is Ready, so an endpoint served by the control plane machines
+	// themselves, e.g. through kube-vip, never becomes reachable.
+	// +optional
+	ControlPlaneEndpointProbe *ControlPlaneEndpointProbe `json:"controlPlaneEndpointProbe,omitempty"`
+
 	// ImageLookupFormat is the URL naming format to use for machine images when
 	// a machine does not specify. When set, this will be used for all cluster machines
 	// unless a machine specifies a different ImageLookupFormat. Supports substitutions
@@ -48,7 +78,11 @@ type TinkerbellClusterSpec struct {
 	// (if known), and the kubernetes version as defined by the packages produced by
 	// kubernetes/release: v1.13.0, v1.12.5-mybuild.1, or v1.17.3. For example, the default
 	// image format of {{.BaseRegistry}}/{{.OSDistro}}-{{.OSVersion}}:{{.KubernetesVersion}}.gz will
-	// attempt to pull the image from that location. See also: https://golang.org/pkg/text/template/
+	// attempt to pull the image from that location. {{.ShortK8sVersion}} is the kubernetes minor version, e.g.
+	// v1.30, and {{.Arch}} the architecture of the Hardware as reported in its DHCP settings, e.g. x86_64. The
+	// {{sha256 "name"}} and {{size "name"}} functions look up the checksum and size of an image in the
+	// ImageCatalogRef of the TinkerbellCluster. Formats using unknown fields or functions are rejected.
+	// See also: https://golang.org/pkg/text/template/
 	// +optional
 	ImageLookupFormat string `json:"imageLookupFormat,omitempty"`
 
@@ -68,6 +102,255 @@ type TinkerbellClusterSpec struct {
 	// images. If not set it will default based on ImageLookupOSDistro.
 	// +optional
 	ImageLookupOSVersion string `json:"imageLookupOSVersion,omitempty"`
+
+	// ImageCatalogRef references a ConfigMap in the namespace of the cluster describing OS images, looked up by the
+	// sha256 and size functions of ImageLookupFormat. Each key is the name of an image and each value a YAML
+	// document with its hex encoded sha256 checksum and its size in bytes.
+	// +optional
+	ImageCatalogRef *corev1.LocalObjectReference `json:"imageCatalogRef,omitempty"`
+
+	// TemplateLibraryRef references a ConfigMap in the namespace of the cluster holding named Tinkerbell templates,
+	// one per key, which machines of the cluster use by setting their TemplateRefName.
+	// +optional
+	TemplateLibraryRef *corev1.LocalObjectReference `json:"templateLibraryRef,omitempty"`
+
+	// ReleaseHardwareOnDelete makes the deletion of the TinkerbellCluster wait until no TinkerbellMachines
+	// of the cluster are left and then release all Hardware still claimed for the cluster, wiping its user data.
+	// This keeps a cluster teardown from stranding claimed Hardware, e.g. when TinkerbellMachines were removed
+	// without their finalizers running.
+	// +optional
+	ReleaseHardwareOnDelete bool `json:"releaseHardwareOnDelete,omitempty"`
+
+	// MaxConcurrentProvisioning limits how many machines of the cluster run provisioning workflows, which
+	// includes powering on the Hardware through its BMC, at the same time. Further machines wait with the
+	// ProvisioningSlotAcquired condition set to false until a workflow finishes. Rolling out many machines at once
+	// can otherwise overload image servers and power circuits. Zero or unset means no limit.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxConcurrentProvisioning int32 `json:"maxConcurrentProvisioning,omitempty"`
+
+	// MetadataURL is the URL of the Tinkerbell metadata service (Hegel) the machines of the cluster fetch their
+	// metadata and user-data from, unless a machine sets its own. Defaults to port 50061 of the TINKERBELL_IP
+	// the controller is configured with.
+	// +optional
+	MetadataURL string `json:"metadataURL,omitempty"`
+
+	// Endpoints are the addresses under which the machines of the cluster reach the Tinkerbell stack and CAPT while
+	// they provision, unless a machine sets its own, e.g. for clusters in a network segment reaching them through
+	// NAT.
+	// +optional
+	Endpoints *ProvisioningEndpoints `json:"endpoints,omitempty"`
+
+	// WorkflowTimeouts overrides the global timeout of the workflows of the machines of the cluster and the
+	// timeouts of their actions, unless a machine sets its own.
+	// +optional
+	WorkflowTimeouts *WorkflowTimeouts `json:"workflowTimeouts,omitempty"`
+
+	// ControlPlaneHardwareReservation is how Hardware labeled v1alpha1.tinkerbell.org/reserved-for=control-plane
+	// is kept for the control plane machines of the cluster, which select it over other matching Hardware. With
+	// Strict, the default, worker machines never select reserved Hardware. With Soft, worker machines select it
+	// only when no other Hardware matches them.
+	// +optional
+	ControlPlaneHardwareReservation HardwareReservationMode `json:"controlPlaneHardwareReservation,omitempty"`
+
+	// Proxy is the HTTP proxy the machines of the cluster use. It is set in the environment of the actions of their
+	// workflows, and the generated template configures containerd and docker of Linux nodes to use it.
+	// +optional
+	Proxy *Proxy `json:"proxy,omitempty"`
+
+	// ActionImages configures where the actions of the workflows of the machines of the cluster get their images
+	// from, e.g. for air-gapped installations.
+	// +optional
+	ActionImages *ActionImages `json:"actionImages,omitempty"`
+
+	// ImageVerification, when set, verifies the OS image of the machines of the cluster in their workflow against
+	// its cosign signed SHA-256 checksum, while it is written to disk. Workflows fail, wiping the disk, on images which do
+	// not match their checksum or whose checksum is not signed, or not signed by the configured key or identity, and
+	// machines whose template has no action streaming an image, or only one streaming images which cannot be verified,
+	// are not provisioned.
+	// +optional
+	ImageVerification *ImageVerification `json:"imageVerification,omitempty"`
+
+	// HardwareFailureCooldown is how long Hardware on which a workflow or BMC Job failed is not selected for the
+	// machines of the cluster, so a machine recreated after a failure does not claim the same broken server right
+	// away. Zero or unset disables the cool-down.
+	// +optional
+	HardwareFailureCooldown *metav1.Duration `json:"hardwareFailureCooldown,omitempty"`
+
+	// MaintenanceWindows are recurring periods, e.g. change freezes, during which no machine of the cluster starts
+	// provisioning: no workflow is created and no Hardware is powered on or netbooted. Machines wait with the
+	// ProvisioningSlotAcquired condition set to false with the ProvisioningDeferred reason until the window ends,
+	// while the status of machines already provisioning keeps being reconciled.
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// HardwareFinalizerPolicy defines whether CAPT adds its finalizer to the Hardware claimed by the machines of the
+	// cluster. Must be one of "Add" or "None". With "None", e.g. for Hardware managed through GitOps, bindings are
+	// only tracked through labels, the deletion of bound Hardware is refused by a validating webhook, and bound
+	// Hardware deleted anyway does not fail ready machines. Defaults to "Add".
+	// +optional
+	HardwareFinalizerPolicy HardwareFinalizerPolicy `json:"hardwareFinalizerPolicy,omitempty"`
+}
+
+// MaintenanceWindow is a recurring period starting on a cron schedule.
+type MaintenanceWindow struct {
+	// Schedule is the cron expression of the starts of the window: minute, hour, day of month, month and day of
+	// week, e.g. "0 22 * * FRI" for Fridays at 22:00.
+	Schedule string `json:"schedule"`
+
+	// Duration is how long the window lasts from each start.
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone is the IANA name of the time zone of the schedule, e.g. Europe/Berlin. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// Proxy is the HTTP proxy configuration of machines.
+type Proxy struct {
+	// HTTPProxy is the URL of the proxy for HTTP requests.
+	// +optional
+	HTTPProxy string `json:"httpProxy,omitempty"`
+
+	// HTTPSProxy is the URL of the proxy for HTTPS requests.
+	// +optional
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+
+	// NoProxy are the hosts, domains, IP addresses and CIDRs which are reached without the proxy, e.g. the Tinkerbell
+	// stack, the control plane endpoint and the pod and service CIDRs of the cluster.
+	// +optional
+	NoProxy []string `json:"noProxy,omitempty"`
+}
+
+// ActionImagePullPolicy is when tink-worker pulls the images of the actions of workflows.
+// +kubebuilder:validation:Enum=Always;IfNotPresent
+type ActionImagePullPolicy string
+
+const (
+	// ActionImagePullAlways pulls the image of each action before running it, the behavior of tink-worker.
+	ActionImagePullAlways ActionImagePullPolicy = "Always"
+
+	// ActionImagePullIfNotPresent pulls the images of all actions missing in Hook once, before the first action.
+	// tink-worker still pulls the image of each action, which only checks the registry for images already
+	// present and falls back to them when the registry cannot be reached.
+	ActionImagePullIfNotPresent ActionImagePullPolicy = "IfNotPresent"
+)
+
+// ActionImages configures the images of the actions of workflows.
+type ActionImages struct {
+	// Registry replaces the registry of the images of the actions of rendered templates, keeping their repository
+	// path, e.g. quay.io/tinkerbell/actions/writefile:latest is pulled as <registry>/tinkerbell/actions/writefile:latest
+	// and images of Docker Hub such as alpine as <registry>/library/alpine. It is a host, with an optional port and
+	// path, e.g. 10.1.1.1:5000/tinkerbell.
+	// +optional
+	Registry string `json:"registry,omitempty"`
+
+	// BundleURL is the http or https URL of an image archive, as written by docker save, holding the action images.
+	// It is loaded into Hook before the first action, so the images need not be pulled from a registry.
+	// +optional
+	BundleURL string `json:"bundleURL,omitempty"`
+
+	// PullPolicy is when the action images are pulled. Defaults to Always.
+	// +optional
+	PullPolicy ActionImagePullPolicy `json:"pullPolicy,omitempty"`
+
+	// LoaderImage is the image of the action loading BundleURL and pulling the missing images, which needs a docker
+	// CLI, sh and wget. It is pulled from Registry too, as it cannot be part of the bundle it loads, so air-gapped
+	// installations mirror it or build it into Hook. Defaults to docker:27-cli.
+	// +optional
+	LoaderImage string `json:"loaderImage,omitempty"`
+
+	// NetbootHandshakeImage is the image of the action making the netboot handshake, which needs curl. It is pulled
+	// from Registry too. Defaults to curlimages/curl:8.11.1.
+	// +optional
+	NetbootHandshakeImage string `json:"netbootHandshakeImage,omitempty"`
+}
+
+// ImageVerification configures the verification of the cosign signatures of OS images, either with a public key or
+// keyless, with the identity of the certificate which signed the image.
+type ImageVerification struct {
+	// PublicKey is the PEM encoded public key the image checksums are signed with. The checksum of an image, as
+	// written by sha256sum, is fetched from its URL with the .sha256 suffix and its signature with the .sha256.sig
+	// suffix, as written by cosign sign-blob --output-signature. Exclusive with CertificateIdentity.
+	// +optional
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// CertificateIdentity is the identity, e.g. an email address or a workflow URL, of the certificate the image
+	// checksums are signed keyless with. The signing bundle of the checksum of an image is fetched from its URL with the
+	// .sha256.bundle suffix, as written by cosign sign-blob --bundle, and verified against the public Sigstore instance,
+	// which Hook must reach.
+	// +optional
+	CertificateIdentity string `json:"certificateIdentity,omitempty"`
+
+	// CertificateOIDCIssuer is the OIDC issuer of the certificate the images are signed keyless with, e.g.
+	// https://token.actions.githubusercontent.com. Required with CertificateIdentity.
+	// +optional
+	CertificateOIDCIssuer string `json:"certificateOIDCIssuer,omitempty"`
+
+	// Image is the image of the action streaming and verifying images, which needs cosign, sh, wget, sha256sum,
+	// dd and the decompressors of compressed images. It is pulled from the registry of ActionImages too. Defaults to
+	// ghcr.io/sigstore/cosign/cosign:v2.4.1-dev.
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// ControlPlaneEndpointProbeType is how the control plane endpoint is probed.
+// +kubebuilder:validation:Enum=TCP;TLS
+type ControlPlaneEndpointProbeType string
+
+const (
+	// ControlPlaneEndpointProbeTCP probes the control plane endpoint by opening a TCP connection to it.
+	ControlPlaneEndpointProbeTCP ControlPlaneEndpointProbeType = "TCP"
+
+	// ControlPlaneEndpointProbeTLS probes the control plane endpoint with a TLS handshake, checking that the
+	// certificate it presents is valid for the expected server name. The certificate chain is not verified.
+	ControlPlaneEndpointProbeTLS ControlPlaneEndpointProbeType = "TLS"
+)
+
+// ControlPlaneEndpointProbe configures the probe of the control plane endpoint of a cluster.
+type ControlPlaneEndpointProbe struct {
+	// Type is how the endpoint is probed.
+	Type ControlPlaneEndpointProbeType `json:"type"`
+
+	// ServerName is the name the certificate presented by the endpoint must be valid for with the TLS probe.
+	// Defaults to the host of the control plane endpoint.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+
+	// Timeout is how long a probe may take. Defaults to 5s.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// HardwareReservationMode is how strictly Hardware reserved for control plane machines is kept from worker machines.
+// +kubebuilder:validation:Enum=Strict;Soft
+type HardwareReservationMode string
+
+const (
+	// HardwareReservationStrict never selects reserved Hardware for worker machines.
+	HardwareReservationStrict HardwareReservationMode = "Strict"
+
+	// HardwareReservationSoft selects reserved Hardware for worker machines when no other Hardware matches them.
+	HardwareReservationSoft HardwareReservationMode = "Soft"
+)
+
+// HardwareFinalizerPolicy defines whether CAPT adds its finalizer to claimed Hardware.
+// +kubebuilder:validation:Enum=Add;None
+type HardwareFinalizerPolicy string
+
+const (
+	// HardwareFinalizerPolicyAdd adds the finalizer of CAPT to claimed Hardware, so it is only deleted once it was
+	// released. It is the default.
+	HardwareFinalizerPolicyAdd HardwareFinalizerPolicy = "Add"
+
+	// HardwareFinalizerPolicyNone never adds a finalizer to Hardware, leaving its lifecycle to its owner.
+	HardwareFinalizerPolicyNone HardwareFinalizerPolicy = "None"
+)
+
+// ReleaseHardwareOnDeleteEnabled returns true when Hardware claimed for the cluster should be released
+// on deletion, either through the spec or the ReleaseHardwareOnDeleteAnnotation.
+func (c *TinkerbellCluster) ReleaseHardwareOnDeleteEnabled() bool {
+	return c.Spec.ReleaseHardwareOnDelete || c.GetAnnotations()[ReleaseHardwareOnDeleteAnnotation] == "true"
 }
 
 // TinkerbellClusterStatus defines the observed state of TinkerbellCluster.
@@ -78,13 +361,24 @@ type TinkerbellClusterStatus struct {
 	// Ready denotes that the cluster (infrastructure) is ready.
 	// +optional
 	Ready bool `json:"ready"`
+
+	// Conditions defines current service state of the TinkerbellCluster.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// ObservedGeneration is the generation of the TinkerbellCluster last reconciled successfully. It is behind
+	// metadata.generation while a change of the spec was not acted upon yet.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 // +kubebuilder:subresource:status
-// +kubebuilder:resource:path=tinkerbellclusters,scope=Namespaced,categories=cluster-api
+// +kubebuilder:resource:path=tinkerbellclusters,scope=Namespaced,categories=cluster-api,shortName=tc
 // +kubebuilder:object:root=true
 // +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this TinkerbellCluster belongs"
+// +kubebuilder:printcolumn:name="Endpoint",type="string",JSONPath=".spec.controlPlaneEndpoint.host",description="Host of the control plane endpoint"
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="TinkerbellCluster ready status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of TinkerbellCluster"
 
 // TinkerbellCluster is the Schema for the tinkerbellclusters API.
 type TinkerbellCluster struct {
@@ -95,6 +389,16 @@ type TinkerbellCluster struct {
 	Status TinkerbellClusterStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the observations of the operational state of the TinkerbellCluster resource.
+func (c *TinkerbellCluster) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the TinkerbellCluster to the predescribed clusterv1.Conditions.
+func (c *TinkerbellCluster) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // TinkerbellClusterList contains a list of TinkerbellCluster.
@@ -17,11 +17,18 @@ limitations under the License.
 package v1beta1
 
 import (
+	"encoding/pem"
+	"net/url"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/cron"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/imageurl"
 )
 
 const (
@@ -41,12 +48,176 @@ func (c *TinkerbellCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
 func (c *TinkerbellCluster) ValidateCreate() (admission.Warnings, error) {
-	return nil, nil
+	return nil, aggregateObjErrors(c.GroupVersionKind().GroupKind(), c.Name, c.validateSpec())
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
 func (c *TinkerbellCluster) ValidateUpdate(_ runtime.Object) (admission.Warnings, error) {
-	return nil, nil
+	return nil, aggregateObjErrors(c.GroupVersionKind().GroupKind(), c.Name, c.validateSpec())
+}
+
+func (c *TinkerbellCluster) validateSpec() field.ErrorList {
+	allErrs := validateHTTPURL(field.NewPath("spec", "metadataURL"), c.Spec.MetadataURL)
+
+	if c.Spec.WorkflowTimeouts != nil {
+		allErrs = append(allErrs, c.Spec.WorkflowTimeouts.validate(field.NewPath("spec", "workflowTimeouts"))...)
+	}
+
+	if c.Spec.Proxy != nil {
+		allErrs = append(allErrs, c.Spec.Proxy.validate(field.NewPath("spec", "proxy"))...)
+	}
+
+	if c.Spec.Endpoints != nil {
+		allErrs = append(allErrs, c.Spec.Endpoints.validate(field.NewPath("spec", "endpoints"))...)
+	}
+
+	if c.Spec.ActionImages != nil {
+		allErrs = append(allErrs, c.Spec.ActionImages.validate(field.NewPath("spec", "actionImages"))...)
+	}
+
+	if c.Spec.ControlPlaneEndpointProbe != nil {
+		allErrs = append(allErrs,
+			c.Spec.ControlPlaneEndpointProbe.validate(field.NewPath("spec", "controlPlaneEndpointProbe"))...)
+	}
+
+	if c.Spec.ImageVerification != nil {
+		allErrs = append(allErrs, c.Spec.ImageVerification.validate(field.NewPath("spec", "imageVerification"))...)
+	}
+
+	if err := imageurl.Validate(c.Spec.ImageLookupFormat); err != nil {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "imageLookupFormat"), c.Spec.ImageLookupFormat,
+			err.Error()))
+	}
+
+	if c.Spec.HardwareFailureCooldown != nil && c.Spec.HardwareFailureCooldown.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "hardwareFailureCooldown"),
+			c.Spec.HardwareFailureCooldown.Duration.String(), "must not be negative"))
+	}
+
+	for i, w := range c.Spec.MaintenanceWindows {
+		allErrs = append(allErrs, w.validate(field.NewPath("spec", "maintenanceWindows").Index(i))...)
+	}
+
+	return allErrs
+}
+
+// validate returns the errors of the schedule, duration and time zone of the maintenance window.
+func (w MaintenanceWindow) validate(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if _, err := cron.Parse(w.Schedule); err != nil {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("schedule"), w.Schedule, err.Error()))
+	}
+
+	if w.Duration.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("duration"), w.Duration.Duration.String(),
+			"must be positive"))
+	}
+
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("timeZone"), w.TimeZone, err.Error()))
+	}
+
+	return allErrs
+}
+
+// validate validates the registry, which must be a host with an optional port and path, and the bundle URL.
+func (a ActionImages) validate(fieldPath *field.Path) field.ErrorList {
+	allErrs := validateHTTPURL(fieldPath.Child("bundleURL"), a.BundleURL)
+
+	if a.Registry != "" {
+		u, err := url.Parse("//" + a.Registry)
+		if err != nil || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" ||
+			strings.HasSuffix(a.Registry, "/") || strings.Contains(a.Registry, "://") {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("registry"), a.Registry,
+				"must be a registry host with an optional port and path, without scheme"))
+		}
+	}
+
+	if strings.ContainsFunc(a.LoaderImage, isSpaceOrControl) {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("loaderImage"), a.LoaderImage,
+			"must not contain whitespace or control characters"))
+	}
+
+	return allErrs
+}
+
+// validate validates that the server name is only set for the TLS probe and the timeout is positive.
+func (p ControlPlaneEndpointProbe) validate(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if p.ServerName != "" && p.Type != ControlPlaneEndpointProbeTLS {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("serverName"), "only allowed with the TLS probe"))
+	}
+
+	if p.Timeout != nil && p.Timeout.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("timeout"), p.Timeout.Duration.String(),
+			"must be positive"))
+	}
+
+	return allErrs
+}
+
+// validate validates that the image verification is either with a public key or keyless, and its public key and
+// image.
+func (v ImageVerification) validate(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch {
+	case v.PublicKey == "" && v.CertificateIdentity == "":
+		allErrs = append(allErrs, field.Required(fieldPath.Child("publicKey"),
+			"either publicKey or certificateIdentity must be set"))
+	case v.PublicKey != "" && v.CertificateIdentity != "":
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("certificateIdentity"),
+			"must not be set with publicKey"))
+	case v.PublicKey != "":
+		if block, _ := pem.Decode([]byte(v.PublicKey)); block == nil || block.Type != "PUBLIC KEY" {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("publicKey"), v.PublicKey,
+				"must be a PEM encoded public key"))
+		}
+	}
+
+	if v.CertificateIdentity != "" && v.CertificateOIDCIssuer == "" {
+		allErrs = append(allErrs, field.Required(fieldPath.Child("certificateOIDCIssuer"),
+			"must be set with certificateIdentity"))
+	}
+
+	if strings.ContainsFunc(v.Image, isSpaceOrControl) {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("image"), v.Image,
+			"must not contain whitespace or control characters"))
+	}
+
+	return allErrs
+}
+
+// validate validates the proxy URLs and the entries of the no proxy list, which must not contain separators.
+func (p Proxy) validate(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for _, proxy := range []struct{ name, url string }{
+		{"httpProxy", p.HTTPProxy},
+		{"httpsProxy", p.HTTPSProxy},
+	} {
+		name, proxyURL := proxy.name, proxy.url
+		if proxyURL == "" {
+			continue
+		}
+
+		u, err := url.Parse(proxyURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child(name), proxyURL,
+				"must be an http or https URL with a host"))
+		}
+	}
+
+	for i, host := range p.NoProxy {
+		if host == "" || strings.ContainsAny(host, ", \t\n\"") {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("noProxy").Index(i), host,
+				"must be a single non-empty host, domain, IP address or CIDR"))
+		}
+	}
+
+	return allErrs
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
@@ -65,10 +236,18 @@ func defaultVersionForOSDistro(distro string) string {
 // Default implements webhookutil.defaulter so a webhook will be registered for the type.
 func (c *TinkerbellCluster) Default() {
 	if c.Spec.ImageLookupFormat == "" {
-		c.Spec.ImageLookupFormat = "{{.BaseRegistry}}/{{.OSDistro}}-{{.OSVersion}}:{{.KubernetesVersion}}.gz"
+		c.Spec.ImageLookupFormat = DefaultImageLookupFormat
 	}
 
 	if c.Spec.ImageLookupOSVersion == "" {
 		c.Spec.ImageLookupOSVersion = defaultVersionForOSDistro(c.Spec.ImageLookupOSDistro)
 	}
+
+	if c.Spec.APIServerPort == 0 {
+		c.Spec.APIServerPort = DefaultAPIServerPort
+	}
+
+	if c.Spec.ControlPlaneEndpoint.Host != "" && c.Spec.ControlPlaneEndpoint.Port == 0 {
+		c.Spec.ControlPlaneEndpoint.Port = c.Spec.APIServerPort
+	}
 }
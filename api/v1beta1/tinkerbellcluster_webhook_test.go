@@ -0,0 +1,292 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_tinkerbell_cluster_defaults_api_server_port(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		spec     v1beta1.TinkerbellClusterSpec
+		port     int32
+		endpoint clusterv1.APIEndpoint
+	}{
+		"unset": {port: 6443},
+		"remapped port": {
+			spec: v1beta1.TinkerbellClusterSpec{APIServerPort: 7443},
+			port: 7443,
+		},
+		"endpoint without port": {
+			spec: v1beta1.TinkerbellClusterSpec{
+				APIServerPort:        7443,
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "10.0.0.10"},
+			},
+			port:     7443,
+			endpoint: clusterv1.APIEndpoint{Host: "10.0.0.10", Port: 7443},
+		},
+		"endpoint with a different port": {
+			spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "10.0.0.10", Port: 443},
+			},
+			port:     6443,
+			endpoint: clusterv1.APIEndpoint{Host: "10.0.0.10", Port: 443},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			c := &v1beta1.TinkerbellCluster{Spec: tc.spec}
+			c.Default()
+
+			g.Expect(c.Spec.APIServerPort).To(Equal(tc.port))
+			g.Expect(c.Spec.ControlPlaneEndpoint).To(Equal(tc.endpoint))
+		})
+	}
+}
+
+func Test_tinkerbell_cluster_validates_metadata_url(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	cluster := &v1beta1.TinkerbellCluster{Spec: v1beta1.TinkerbellClusterSpec{MetadataURL: "http://10.0.0.1:50061"}}
+	_, err := cluster.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for _, metadataURL := range []string{"10.0.0.1:50061", "ftp://10.0.0.1"} {
+		cluster.Spec.MetadataURL = metadataURL
+		_, err = cluster.ValidateCreate()
+		g.Expect(err).To(HaveOccurred(), metadataURL)
+		_, err = cluster.ValidateUpdate(cluster)
+		g.Expect(err).To(HaveOccurred(), metadataURL)
+	}
+}
+
+func Test_tinkerbell_cluster_validates_endpoints(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	cluster := &v1beta1.TinkerbellCluster{Spec: v1beta1.TinkerbellClusterSpec{
+		Endpoints: &v1beta1.ProvisioningEndpoints{
+			HookURL:            "http://203.0.113.10:8080",
+			TinkServerAddress:  "203.0.113.10:42113",
+			SyslogHost:         "203.0.113.10",
+			BootstrapReportURL: "https://203.0.113.10:8082",
+		},
+	}}
+	_, err := cluster.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for name, endpoints := range map[string]v1beta1.ProvisioningEndpoints{
+		"hook URL without scheme":    {HookURL: "203.0.113.10:8080"},
+		"tink server without port":   {TinkServerAddress: "203.0.113.10"},
+		"syslog host with spaces":    {SyslogHost: "203.0.113.10 debug"},
+		"bootstrap report FTP URL":   {BootstrapReportURL: "ftp://203.0.113.10"},
+		"tink server without a host": {TinkServerAddress: ":42113"},
+	} {
+		cluster.Spec.Endpoints = &endpoints
+		_, err = cluster.ValidateCreate()
+		g.Expect(err).To(HaveOccurred(), name)
+	}
+}
+
+func Test_tinkerbell_cluster_validates_proxy(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	valid := v1beta1.Proxy{
+		HTTPProxy:  "http://proxy.example.com:3128",
+		HTTPSProxy: "http://proxy.example.com:3128",
+		NoProxy:    []string{"10.0.0.0/8", ".cluster.local", "localhost"},
+	}
+
+	cluster := &v1beta1.TinkerbellCluster{Spec: v1beta1.TinkerbellClusterSpec{Proxy: &valid}}
+	_, err := cluster.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for name, proxy := range map[string]v1beta1.Proxy{
+		"proxy without scheme":    {HTTPProxy: "proxy.example.com:3128"},
+		"proxy with ftp scheme":   {HTTPSProxy: "ftp://proxy.example.com"},
+		"empty no proxy entry":    {NoProxy: []string{""}},
+		"joined no proxy entries": {NoProxy: []string{"localhost,10.0.0.0/8"}},
+	} {
+		cluster.Spec.Proxy = &proxy
+		_, err = cluster.ValidateCreate()
+		g.Expect(err).To(HaveOccurred(), name)
+	}
+}
+
+func Test_tinkerbell_cluster_validates_action_images(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	valid := v1beta1.ActionImages{
+		Registry:   "10.1.1.1:5000/tinkerbell",
+		BundleURL:  "http://10.1.1.1:8080/actions.tar",
+		PullPolicy: v1beta1.ActionImagePullIfNotPresent,
+	}
+
+	cluster := &v1beta1.TinkerbellCluster{Spec: v1beta1.TinkerbellClusterSpec{ActionImages: &valid}}
+	_, err := cluster.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for name, images := range map[string]v1beta1.ActionImages{
+		"registry with scheme":        {Registry: "https://10.1.1.1:5000"},
+		"registry with trailing path": {Registry: "10.1.1.1:5000/"},
+		"registry with credentials":   {Registry: "admin:secret@10.1.1.1:5000"},
+		"bundle without scheme":       {BundleURL: "10.1.1.1:8080/actions.tar"},
+		"loader image with spaces":    {LoaderImage: "docker:27-cli; reboot"},
+	} {
+		cluster.Spec.ActionImages = &images
+		_, err = cluster.ValidateCreate()
+		g.Expect(err).To(HaveOccurred(), name)
+	}
+}
+
+func Test_tinkerbell_cluster_validates_control_plane_endpoint_probe(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	cluster := &v1beta1.TinkerbellCluster{Spec: v1beta1.TinkerbellClusterSpec{
+		ControlPlaneEndpointProbe: &v1beta1.ControlPlaneEndpointProbe{
+			Type:       v1beta1.ControlPlaneEndpointProbeTLS,
+			ServerName: "kubernetes.default.svc",
+			Timeout:    &metav1.Duration{Duration: time.Second},
+		},
+	}}
+	_, err := cluster.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for name, probe := range map[string]v1beta1.ControlPlaneEndpointProbe{
+		"server name with TCP probe": {Type: v1beta1.ControlPlaneEndpointProbeTCP, ServerName: "kubernetes"},
+		"negative timeout": {
+			Type: v1beta1.ControlPlaneEndpointProbeTCP, Timeout: &metav1.Duration{Duration: -time.Second},
+		},
+	} {
+		cluster.Spec.ControlPlaneEndpointProbe = &probe
+		_, err = cluster.ValidateCreate()
+		g.Expect(err).To(HaveOccurred(), name)
+	}
+}
+
+func Test_tinkerbell_cluster_validates_image_verification(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	const publicKey = "-----BEGIN PUBLIC KEY-----\nMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE\n-----END PUBLIC KEY-----\n"
+
+	for name, verification := range map[string]v1beta1.ImageVerification{
+		"public key": {PublicKey: publicKey},
+		"keyless":    {CertificateIdentity: "images@example.com", CertificateOIDCIssuer: "https://accounts.google.com"},
+	} {
+		cluster := &v1beta1.TinkerbellCluster{Spec: v1beta1.TinkerbellClusterSpec{ImageVerification: &verification}}
+		_, err := cluster.ValidateCreate()
+		g.Expect(err).NotTo(HaveOccurred(), name)
+	}
+
+	for name, verification := range map[string]v1beta1.ImageVerification{
+		"neither key nor identity": {},
+		"key and identity": {
+			PublicKey: publicKey, CertificateIdentity: "images@example.com",
+			CertificateOIDCIssuer: "https://accounts.google.com",
+		},
+		"malformed key":           {PublicKey: "not a key"},
+		"identity without issuer": {CertificateIdentity: "images@example.com"},
+		"image with spaces":       {PublicKey: publicKey, Image: "cosign; reboot"},
+	} {
+		cluster := &v1beta1.TinkerbellCluster{Spec: v1beta1.TinkerbellClusterSpec{ImageVerification: &verification}}
+		_, err := cluster.ValidateCreate()
+		g.Expect(err).To(HaveOccurred(), name)
+	}
+}
+
+func Test_tinkerbell_cluster_validates_hardware_failure_cooldown(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	cluster := &v1beta1.TinkerbellCluster{Spec: v1beta1.TinkerbellClusterSpec{
+		HardwareFailureCooldown: &metav1.Duration{Duration: time.Hour},
+	}}
+	_, err := cluster.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	cluster.Spec.HardwareFailureCooldown.Duration = -time.Hour
+	_, err = cluster.ValidateCreate()
+	g.Expect(err).To(HaveOccurred())
+}
+
+func Test_tinkerbell_cluster_validates_image_lookup_format(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	cluster := &v1beta1.TinkerbellCluster{Spec: v1beta1.TinkerbellClusterSpec{
+		ImageLookupFormat: `{{.BaseRegistry}}/{{.Arch}}/{{.ShortK8sVersion}}/{{sha256 "ubuntu"}}.gz`,
+	}}
+	_, err := cluster.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for name, format := range map[string]string{
+		"unknown field":    "{{.BaseRegistry}}/{{.OSDistribution}}.gz",
+		"unknown function": `{{md5 "ubuntu"}}.gz`,
+		"invalid syntax":   "{{.BaseRegistry",
+	} {
+		cluster.Spec.ImageLookupFormat = format
+		_, err = cluster.ValidateCreate()
+		g.Expect(err).To(HaveOccurred(), name)
+	}
+}
+
+func Test_tinkerbell_cluster_validates_maintenance_windows(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	valid := v1beta1.MaintenanceWindow{
+		Schedule: "0 22 * * FRI",
+		Duration: metav1.Duration{Duration: 4 * time.Hour},
+		TimeZone: "Europe/Berlin",
+	}
+
+	cluster := &v1beta1.TinkerbellCluster{Spec: v1beta1.TinkerbellClusterSpec{
+		MaintenanceWindows: []v1beta1.MaintenanceWindow{valid},
+	}}
+	_, err := cluster.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for name, mutate := range map[string]func(*v1beta1.MaintenanceWindow){
+		"invalid schedule":  func(w *v1beta1.MaintenanceWindow) { w.Schedule = "0 25 * * *" },
+		"zero duration":     func(w *v1beta1.MaintenanceWindow) { w.Duration.Duration = 0 },
+		"unknown time zone": func(w *v1beta1.MaintenanceWindow) { w.TimeZone = "Mars/Olympus" },
+	} {
+		w := valid
+		mutate(&w)
+		cluster.Spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{w}
+		_, err = cluster.ValidateCreate()
+		g.Expect(err).To(HaveOccurred(), name)
+	}
+}
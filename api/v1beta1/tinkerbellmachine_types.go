@@ -18,7 +18,9 @@ package v1beta1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
@@ -31,6 +33,93 @@ const (
 // BootMode defines the type of booting that will be done. i.e. netboot, iso, etc.
 type BootMode string
 
+const (
+	// BootModeNone leaves booting the Hardware into the provisioning environment to the operator.
+	BootModeNone BootMode = "none"
+
+	// BootModeNetboot network boots the Hardware into the provisioning environment via PXE.
+	BootModeNetboot BootMode = "netboot"
+
+	// BootModeISO boots the Hardware into the provisioning environment from an ISO mounted as virtual media
+	// through its BMC, without DHCP or PXE. PXE stays disallowed on the interfaces managed by CAPT and the ISO
+	// is ejected once the machine is provisioned.
+	BootModeISO BootMode = "iso"
+)
+
+// PowerManagement defines who manages the power of the Hardware of a machine.
+type PowerManagement string
+
+const (
+	// PowerManagementProvider powers Hardware with a BMC on and off through Rufio BMC Jobs.
+	PowerManagementProvider PowerManagement = "provider"
+
+	// PowerManagementExternal leaves power to an out-of-band system. No BMC Jobs are created, and events describe
+	// the power actions the system must perform.
+	PowerManagementExternal PowerManagement = "external"
+
+	// PowerManagementNone never changes the power of the Hardware.
+	PowerManagementNone PowerManagement = "none"
+)
+
+// BootstrapDataDriftPolicy defines what happens when the bootstrap data of a provisioned machine changes.
+type BootstrapDataDriftPolicy string
+
+const (
+	// BootstrapDataDriftPolicyUpdate updates the user-data of the Hardware, which only takes effect the next time
+	// the machine is provisioned. It is the default.
+	BootstrapDataDriftPolicyUpdate BootstrapDataDriftPolicy = "Update"
+
+	// BootstrapDataDriftPolicyRemediate updates the user-data of the Hardware and marks the Machine for remediation
+	// by a MachineHealthCheck, so it is replaced with a machine provisioned with the new bootstrap data.
+	BootstrapDataDriftPolicyRemediate BootstrapDataDriftPolicy = "Remediate"
+)
+
+// TinkerbellMachinePhase is the lifecycle phase of a TinkerbellMachine, derived from its conditions and deletion
+// state.
+type TinkerbellMachinePhase string
+
+const (
+	// TinkerbellMachinePhasePending is the phase of a machine for which no Hardware was claimed yet.
+	TinkerbellMachinePhasePending TinkerbellMachinePhase = "Pending"
+
+	// TinkerbellMachinePhaseProvisioning is the phase of a machine whose Hardware is being provisioned.
+	TinkerbellMachinePhaseProvisioning TinkerbellMachinePhase = "Provisioning"
+
+	// TinkerbellMachinePhaseProvisioned is the phase of a machine which is Ready.
+	TinkerbellMachinePhaseProvisioned TinkerbellMachinePhase = "Provisioned"
+
+	// TinkerbellMachinePhaseDeleting is the phase of a machine being deleted.
+	TinkerbellMachinePhaseDeleting TinkerbellMachinePhase = "Deleting"
+
+	// TinkerbellMachinePhaseFailed is the phase of a machine with a terminal error or a condition reporting an
+	// error, e.g. a failed Workflow.
+	TinkerbellMachinePhaseFailed TinkerbellMachinePhase = "Failed"
+)
+
+// HardwareMissingPolicy defines what happens when the Hardware bound to a machine is deleted.
+type HardwareMissingPolicy string
+
+const (
+	// HardwareMissingPolicyFail marks the machine as failed. It is the default.
+	HardwareMissingPolicyFail HardwareMissingPolicy = "Fail"
+
+	// HardwareMissingPolicyReselect releases the missing Hardware from a machine which is not ready yet and selects
+	// new Hardware for it. Machines which are already ready are marked as failed.
+	HardwareMissingPolicyReselect HardwareMissingPolicy = "Reselect"
+)
+
+// PostReleaseHookFailurePolicy defines what happens when the post-release hook of a machine fails.
+type PostReleaseHookFailurePolicy string
+
+const (
+	// PostReleaseHookFailurePolicyFail retries the hook until it succeeds, keeping the TinkerbellMachine until then.
+	// It is the default.
+	PostReleaseHookFailurePolicyFail PostReleaseHookFailurePolicy = "Fail"
+
+	// PostReleaseHookFailurePolicyIgnore records a warning event and deletes the TinkerbellMachine regardless.
+	PostReleaseHookFailurePolicyIgnore PostReleaseHookFailurePolicy = "Ignore"
+)
+
 // TinkerbellMachineSpec defines the desired state of TinkerbellMachine.
 type TinkerbellMachineSpec struct {
 	// ImageLookupFormat is the URL naming format to use for machine images when
@@ -44,7 +133,11 @@ type TinkerbellMachineSpec struct {
 	// (if known), and the kubernetes version as defined by the packages produced by
 	// kubernetes/release: v1.13.0, v1.12.5-mybuild.1, or v1.17.3. For example, the default
 	// image format of {{.BaseRegistry}}/{{.OSDistro}}-{{.OSVersion}}:{{.KubernetesVersion}}.gz will
-	// attempt to pull the image from that location. See also: https://golang.org/pkg/text/template/
+	// attempt to pull the image from that location. {{.ShortK8sVersion}} is the kubernetes minor version, e.g.
+	// v1.30, and {{.Arch}} the architecture of the Hardware as reported in its DHCP settings, e.g. x86_64. The
+	// {{sha256 "name"}} and {{size "name"}} functions look up the checksum and size of an image in the
+	// ImageCatalogRef of the TinkerbellCluster. Formats using unknown fields or functions are rejected.
+	// See also: https://golang.org/pkg/text/template/
 	// +optional
 	ImageLookupFormat string `json:"imageLookupFormat,omitempty"`
 
@@ -63,25 +156,492 @@ type TinkerbellMachineSpec struct {
 	// +optional
 	ImageLookupOSVersion string `json:"imageLookupOSVersion,omitempty"`
 
+	// Image describes the OS image written to the Hardware by the default template.
+	// +optional
+	Image ImageSpec `json:"image,omitempty"`
+
 	// TemplateOverride overrides the default Tinkerbell template used by CAPT.
 	// You can learn more about Tinkerbell templates here: https://tinkerbell.org/docs/concepts/templates/
 	// +optional
 	TemplateOverride string `json:"templateOverride,omitempty"`
 
+	// TemplateRefName names a template of the TemplateLibraryRef of the TinkerbellCluster used instead of the default
+	// template, so templates shared by many machines are maintained in one place. The template is first rendered
+	// with the context of the machine, using [[ and ]] as delimiters so the placeholders Tinkerbell renders are kept:
+	// [[.MachineName]], [[.ClusterName]], [[.HardwareName]], [[.KubernetesVersion]], [[.ImageURL]], [[.DestDisk]],
+	// [[.DestPartition]] and [[.MetadataURL]]. It then applies like TemplateOverride, with which it cannot be combined.
+	// +optional
+	TemplateRefName string `json:"templateRefName,omitempty"`
+
+	// ActionEnvironment sets or overrides environment variables of actions in the Tinkerbell template,
+	// keyed by action name. It applies to the default template, TemplateOverride and TemplateRefName, so a single
+	// environment variable can be tweaked without replacing the whole template.
+	// +optional
+	ActionEnvironment map[string]map[string]string `json:"actionEnvironment,omitempty"`
+
+	// WorkflowTimeouts overrides the global timeout of the workflow and the timeouts of its actions. It applies to
+	// both the default template and TemplateOverride, and takes precedence over the WorkflowTimeouts of the
+	// TinkerbellCluster.
+	// +optional
+	WorkflowTimeouts *WorkflowTimeouts `json:"workflowTimeouts,omitempty"`
+
+	// WorkflowStages are run one after the other as separate Tinkerbell workflows before the workflow installing
+	// the OS, e.g. to update firmware or burn in the Hardware. Each stage must succeed before the next one, and
+	// eventually the OS installation, is started.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	WorkflowStages []WorkflowStage `json:"workflowStages,omitempty"`
+
+	// WorkerDevice configures how the Hardware of the machine is referenced as the worker of its workflows,
+	// so templates referencing the worker by a different key or identifier work without overrides.
+	// +optional
+	WorkerDevice WorkerDevice `json:"workerDevice,omitempty"`
+
 	// HardwareAffinity allows filtering for hardware.
 	// +optional
 	HardwareAffinity *HardwareAffinity `json:"hardwareAffinity,omitempty"`
 
+	// HardwarePool is the name of the HardwarePool to select Hardware from instead of the namespace of the
+	// TinkerbellMachine. The HardwarePool must allow the namespace of the TinkerbellMachine. The Templates,
+	// Workflows and BMC Jobs of the machine are created in the namespace of the selected Hardware.
+	// Immutable once Hardware is selected.
+	// +optional
+	HardwarePool string `json:"hardwarePool,omitempty"`
+
 	// BootOptions are options that control the booting of Hardware.
 	// +optional
 	BootOptions BootOptions `json:"bootOptions,omitempty"`
 
+	// MetadataURL is the URL of the Tinkerbell metadata service (Hegel) the machine fetches its metadata and
+	// user-data from, e.g. for machines in an L2 segment served by its own metadata service. Overrides the
+	// metadataURL of the TinkerbellCluster.
+	// +optional
+	MetadataURL string `json:"metadataURL,omitempty"`
+
+	// Endpoints are the addresses under which the machine reaches the Tinkerbell stack and CAPT while it
+	// provisions, e.g. for machines in a network segment reaching them through NAT. Each field set overrides the
+	// endpoints of the TinkerbellCluster.
+	// +optional
+	Endpoints *ProvisioningEndpoints `json:"endpoints,omitempty"`
+
+	// BootstrapDataKey is the key of the bootstrap data Secret of the Machine holding the bootstrap data, for
+	// bootstrap providers which do not store it under the "value" key defined by the Cluster API contract.
+	// Defaults to "value".
+	// +optional
+	BootstrapDataKey string `json:"bootstrapDataKey,omitempty"`
+
+	// StaticNetwork configures a static address on the provisioned OS instead of DHCP, for sites which only
+	// serve static leases for PXE. The address is reported as the address of the machine instead of the DHCP
+	// address of the first interface of the Hardware. The network configuration is only written by the default
+	// template. It cannot be set in TinkerbellMachineTemplates, as the address is specific to a machine.
+	// +optional
+	StaticNetwork *StaticNetwork `json:"staticNetwork,omitempty"`
+
+	// Bond bonds interfaces of the Hardware on the provisioned OS, for sites which require bonded links. The
+	// static network configuration, if any, is applied to the bond, which uses DHCP otherwise. Only the first
+	// member of the bond is netbooted, so the switch must serve PXE on it before the bond negotiates, e.g. with
+	// LACP fallback. The network configuration is only written by the default template.
+	// +optional
+	Bond *Bond `json:"bond,omitempty"`
+
+	// Files are written to the OS partition of the provisioned OS, e.g. registry certificates, proxy configuration or
+	// udev rules, each by a writefile action run before the machine boots into its OS. The content of a file is read
+	// when the Template of the machine is created, so later changes to its Secret or ConfigMap only apply to machines
+	// provisioned afterwards. Only written by the default template.
+	// +optional
+	// +listType=map
+	// +listMapKey=path
+	Files []File `json:"files,omitempty"`
+
+	// BootstrapDataDriftPolicy defines what happens when the bootstrap data of the machine changes after it was
+	// provisioned, for example when certificates are rotated. Must be one of "Update" or "Remediate". Remediation
+	// requires a MachineHealthCheck selecting the Machine and the ReprovisionOnUserDataChange feature gate.
+	// Defaults to "Update".
+	// +optional
+	// +kubebuilder:validation:Enum=Update;Remediate
+	BootstrapDataDriftPolicy BootstrapDataDriftPolicy `json:"bootstrapDataDriftPolicy,omitempty"`
+
+	// HardwareMissingPolicy defines what happens when the Hardware bound to the machine is deleted.
+	// Must be one of "Fail" or "Reselect". Defaults to "Fail".
+	// +optional
+	// +kubebuilder:validation:Enum=Fail;Reselect
+	HardwareMissingPolicy HardwareMissingPolicy `json:"hardwareMissingPolicy,omitempty"`
+
+	// PostReleaseHook is notified when the machine is deleted, right before its Hardware is released, so external
+	// systems can remove e.g. its DHCP reservations and DNS records before the Hardware is claimed again.
+	// +optional
+	PostReleaseHook *PostReleaseHook `json:"postReleaseHook,omitempty"`
+
+	// Storage configures the disks of the machine.
+	// +optional
+	Storage *Storage `json:"storage,omitempty"`
+
+	// ConsoleCapture, when set, captures the serial console of the Hardware over IPMI serial-over-LAN while the
+	// machine provisions. When provisioning fails, the end of the output is kept in a ConfigMap referenced by
+	// status.consoleLog. Requires the Hardware to have a BMC and CAPT to be started with --console-capture-image.
+	// +optional
+	ConsoleCapture *ConsoleCapture `json:"consoleCapture,omitempty"`
+
 	// Those fields are set programmatically, but they cannot be re-constructed from "state of the world", so
 	// we put them in spec instead of status.
 	HardwareName string `json:"hardwareName,omitempty"`
 	ProviderID   string `json:"providerID,omitempty"`
 }
 
+// PostReleaseHook is an HTTP endpoint notified when the Hardware of a machine is released.
+type PostReleaseHook struct {
+	// URL is sent a POST request with a JSON description of the released Hardware, its interfaces and the machine
+	// it was released from. The hook succeeds when the endpoint responds with a 2xx status code. The request may be
+	// sent more than once, so the endpoint must be idempotent. Redirects are not followed, and loopback,
+	// link-local, multicast and unspecified addresses are refused.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+
+	// FailurePolicy defines what happens when the hook fails. Must be one of "Fail" or "Ignore". Defaults to
+	// "Fail".
+	// +optional
+	// +kubebuilder:validation:Enum=Fail;Ignore
+	FailurePolicy PostReleaseHookFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// ConsoleCapture configures the capture of the serial console of a machine while it provisions.
+type ConsoleCapture struct {
+	// Duration is how long the console is captured after the Workflow of the machine was created. Defaults to 1h.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// Storage configures the disks of a machine.
+type Storage struct {
+	// RootDiskSelector selects the disk the OS is installed to among the disks listed in the
+	// v1alpha1.tinkerbell.org/disks annotation of the Hardware, instead of its first disk, whose device name may
+	// change between boots. The disk is referenced by a stable /dev/disk/by-id path. Hardware without a matching
+	// disk is not selected. Only applies to the default template and to TemplateRefName, as [[.DestDisk]].
+	// +optional
+	RootDiskSelector *RootDiskSelector `json:"rootDiskSelector,omitempty"`
+
+	// DataDisks are disks besides the root disk which cloud-init partitions, formats and mounts on first boot, with
+	// fstab entries, so stateful workloads land on the right disks. Each is selected among the disks listed in the
+	// v1alpha1.tinkerbell.org/disks annotation of the Hardware and referenced by a stable /dev/disk/by-id path.
+	// Hardware without a matching disk for each of them is not selected. Disks holding a partition table are not
+	// repartitioned. Only applies to the default template of images configured with cloud-init.
+	// +optional
+	// +listType=map
+	// +listMapKey=label
+	DataDisks []DataDisk `json:"dataDisks,omitempty"`
+}
+
+// DataDiskFilesystem is the filesystem a data disk is formatted with.
+// +kubebuilder:validation:Enum=ext4;xfs
+type DataDiskFilesystem string
+
+const (
+	// DataDiskFilesystemExt4 formats data disks with ext4.
+	DataDiskFilesystemExt4 DataDiskFilesystem = "ext4"
+
+	// DataDiskFilesystemXFS formats data disks with XFS.
+	DataDiskFilesystemXFS DataDiskFilesystem = "xfs"
+)
+
+// DataDisk is a disk of a machine formatted with a single partition and mounted by cloud-init.
+type DataDisk struct {
+	// Label is the label of the filesystem, which the disk is mounted by.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=12
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9][-_a-zA-Z0-9]*$`
+	Label string `json:"label"`
+
+	// Selector selects the disk. The root disk and the disks of other data disks are never selected.
+	Selector RootDiskSelector `json:"selector"`
+
+	// Filesystem is the filesystem the disk is formatted with. Defaults to ext4.
+	// +optional
+	Filesystem DataDiskFilesystem `json:"filesystem,omitempty"`
+
+	// MountPath is the absolute path the disk is mounted at.
+	// +kubebuilder:validation:MinLength=1
+	MountPath string `json:"mountPath"`
+
+	// MountOptions are the mount options of the fstab entry of the disk. Defaults to defaults,nofail.
+	// +optional
+	MountOptions string `json:"mountOptions,omitempty"`
+}
+
+// RootDiskSelector matches disks of Hardware. A disk matches when it matches every field set, at least one field
+// must be set. When several disks match, the first one listed is used.
+type RootDiskSelector struct {
+	// Serial is the serial number of the disk.
+	// +optional
+	Serial string `json:"serial,omitempty"`
+
+	// WWN is the World Wide Name of the disk, e.g. 0x5000c500a1b2c3d4. The comparison ignores case and the 0x
+	// prefix.
+	// +optional
+	WWN string `json:"wwn,omitempty"`
+
+	// MinSize is the minimum size of the disk.
+	// +optional
+	MinSize *resource.Quantity `json:"minSize,omitempty"`
+
+	// MaxSize is the maximum size of the disk.
+	// +optional
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+
+	// Rotational selects spinning disks when true, solid state disks when false.
+	// +optional
+	Rotational *bool `json:"rotational,omitempty"`
+}
+
+// WorkflowStage is a Tinkerbell workflow run before the OS installation workflow.
+type WorkflowStage struct {
+	// Name identifies the stage. The Template and Workflow of the stage are named after the TinkerbellMachine
+	// suffixed with the stage name.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Template is the Tinkerbell template run by the stage. Its last action should reboot the Hardware so the
+	// next stage can netboot. You can learn more about Tinkerbell templates here:
+	// https://tinkerbell.org/docs/concepts/templates/
+	// +kubebuilder:validation:MinLength=1
+	Template string `json:"template"`
+}
+
+// WorkerDeviceSource is the Hardware field identifying the worker of a workflow.
+type WorkerDeviceSource string
+
+const (
+	// WorkerDeviceSourceInstanceID identifies the worker by the instance ID of the Hardware metadata. It is the
+	// default.
+	WorkerDeviceSourceInstanceID WorkerDeviceSource = "InstanceID"
+
+	// WorkerDeviceSourceMAC identifies the worker by the MAC address of the first interface of the Hardware, or of
+	// the interface pinned with WorkerDevice.MAC.
+	WorkerDeviceSourceMAC WorkerDeviceSource = "MAC"
+
+	// WorkerDeviceSourceHardwareName identifies the worker by the name of the Hardware.
+	WorkerDeviceSourceHardwareName WorkerDeviceSource = "HardwareName"
+)
+
+// WorkerDevice configures the entry of the Hardware in the hardware map of a workflow.
+type WorkerDevice struct {
+	// Key is the key of the Hardware in the hardware map, which templates reference the worker with, e.g.
+	// {{.device_1}}. Defaults to device_1.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	Key string `json:"key,omitempty"`
+
+	// Source is the Hardware field identifying the worker. Must be one of "InstanceID", "MAC" or
+	// "HardwareName". Defaults to "InstanceID".
+	// +optional
+	// +kubebuilder:validation:Enum=InstanceID;MAC;HardwareName
+	Source WorkerDeviceSource `json:"source,omitempty"`
+
+	// MAC pins the interface of the Hardware identifying the worker with source "MAC", instead of the first
+	// interface. The interface is also the only one netbooted. Only Hardware with an interface of this MAC address
+	// is selected. It cannot be combined with bond nor set in templates.
+	// +optional
+	MAC string `json:"mac,omitempty"`
+}
+
+// ImageFormat is the format of the OS image written to the Hardware.
+type ImageFormat string
+
+const (
+	// ImageFormatGzip is a gzip compressed raw disk image. It is the default.
+	ImageFormatGzip ImageFormat = "gzip"
+
+	// ImageFormatRaw is an uncompressed raw disk image.
+	ImageFormatRaw ImageFormat = "raw"
+
+	// ImageFormatQCOW2 is a qcow2 disk image. It is converted to a raw disk while being streamed to the disk.
+	ImageFormatQCOW2 ImageFormat = "qcow2"
+
+	// ImageFormatWIM is a Windows imaging format file. It is applied to the OS partition by the action set in
+	// wimApplyAction. Only supported for windows images.
+	ImageFormatWIM ImageFormat = "wim"
+)
+
+// Suffix returns the file name suffix of images of the format, as used by DefaultImageLookupFormat.
+func (f ImageFormat) Suffix() string {
+	switch f {
+	case ImageFormatRaw:
+		return ".raw"
+	case ImageFormatQCOW2:
+		return ".qcow2"
+	case ImageFormatWIM:
+		return ".wim"
+	default:
+		return ".gz"
+	}
+}
+
+// OSFamily is the family of the OS of an image.
+type OSFamily string
+
+const (
+	// OSFamilyLinux is a Linux image configured with cloud-init.
+	OSFamilyLinux OSFamily = "linux"
+
+	// OSFamilyWindows is a Windows disk image configured with cloudbase-init.
+	OSFamilyWindows OSFamily = "windows"
+)
+
+// ImageSpec describes the OS image written to the Hardware by the default template.
+type ImageSpec struct {
+	// Format is the format of the image, which selects the action streaming it to the disk.
+	// Only applies to the default template, not to TemplateOverride. Defaults to gzip. The default image
+	// lookup format names images after their format, with a .gz, .raw, .qcow2 or .wim suffix.
+	// +optional
+	// +kubebuilder:validation:Enum=gzip;raw;qcow2;wim
+	Format ImageFormat `json:"format,omitempty"`
+
+	// WIMApplyAction is the image of the action applying wim images, required for them as there is no stock
+	// Tinkerbell action doing so. It runs with IMG_URL set to the image URL, DEST_DISK to the disk and
+	// DEST_PARTITION to the OS partition, and must partition the disk with the OS on DEST_PARTITION, apply the
+	// image to it and make the disk bootable, as bcdboot does.
+	// +optional
+	WIMApplyAction string `json:"wimApplyAction,omitempty"`
+
+	// OSFamily is the family of the OS of the image. Windows images are configured to fetch their user-data from
+	// the metadata service with cloudbase-init instead of cloud-init, and are rebooted into instead of kexec'd.
+	// Only applies to the default template, not to TemplateOverride. Defaults to linux.
+	// +optional
+	// +kubebuilder:validation:Enum=linux;windows
+	OSFamily OSFamily `json:"osFamily,omitempty"`
+
+	// OSPartition is the number of the partition of the image holding the OS, to which its configuration is
+	// written. Defaults to 1 for linux images, and to 3 for windows images, the partition following the EFI system
+	// and Microsoft reserved partitions.
+	// Only applies to the default template and to TemplateRefName, as [[.DestPartition]], not to TemplateOverride.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	OSPartition int32 `json:"osPartition,omitempty"`
+}
+
+// ProvisioningEndpoints are the addresses under which machines reach the Tinkerbell stack and CAPT while they
+// provision, when they differ from the addresses the controller is configured with, e.g. because machines reach
+// them through NAT. The metadata service and the OS images are set independently with metadataURL and
+// imageLookupBaseRegistry.
+type ProvisioningEndpoints struct {
+	// HookURL is the base URL the Hook kernel and initramfs are downloaded from by the iPXE script CAPT serves to
+	// machines with kernel arguments. Defaults to --hook-url.
+	// +optional
+	HookURL string `json:"hookURL,omitempty"`
+
+	// TinkServerAddress is the host and port of the Tink server gRPC API tink-worker connects to when booted by the
+	// iPXE script CAPT serves. Defaults to --tink-server-address.
+	// +optional
+	TinkServerAddress string `json:"tinkServerAddress,omitempty"`
+
+	// SyslogHost is the host Hook sends its logs to when booted by the iPXE script CAPT serves. Defaults to
+	// --syslog-host.
+	// +optional
+	SyslogHost string `json:"syslogHost,omitempty"`
+
+	// BootstrapReportURL is the base URL under which machines reach the bootstrap report endpoint of CAPT. Only
+	// used when bootstrap reports are enabled. Defaults to --bootstrap-report-url.
+	// +optional
+	BootstrapReportURL string `json:"bootstrapReportURL,omitempty"`
+}
+
+// WorkflowTimeouts are the timeouts of a Tinkerbell workflow, in seconds.
+type WorkflowTimeouts struct {
+	// Global is the timeout of the whole workflow. The default template uses 6000 seconds.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Global int64 `json:"global,omitempty"`
+
+	// Actions are the timeouts of actions of the template, keyed by action name. Actions the template does not
+	// have are ignored, so the same timeouts can apply to machines with different templates.
+	// +optional
+	Actions map[string]int64 `json:"actions,omitempty"`
+}
+
+// Windows returns true for images of the windows OS family.
+func (s ImageSpec) Windows() bool {
+	return s.OSFamily == OSFamilyWindows
+}
+
+// StaticNetwork is the static network configuration of a Hardware interface.
+type StaticNetwork struct {
+	// Address is the IPv4 or IPv6 address of the interface with its prefix length, e.g. 10.0.0.10/24.
+	// +kubebuilder:validation:MinLength=1
+	Address string `json:"address"`
+
+	// Gateway is the address of the default gateway. It must be part of the network of Address.
+	// +optional
+	Gateway string `json:"gateway,omitempty"`
+
+	// Nameservers are the addresses of the DNS servers.
+	// +optional
+	Nameservers []string `json:"nameservers,omitempty"`
+
+	// MACAddress selects the interface of the Hardware to configure. Defaults to the MAC address of the first
+	// interface of the Hardware.
+	// +optional
+	MACAddress string `json:"macAddress,omitempty"`
+}
+
+// BondMode is the bonding mode of a Bond.
+type BondMode string
+
+const (
+	// BondModeLACP aggregates the members of the bond with IEEE 802.3ad LACP.
+	BondModeLACP BondMode = "802.3ad"
+
+	// BondModeActiveBackup uses a single member of the bond at a time, failing over to the next one.
+	BondModeActiveBackup BondMode = "active-backup"
+)
+
+// Bond is the bonded network configuration of Hardware interfaces.
+type Bond struct {
+	// Mode is the bonding mode. Must be one of "802.3ad" or "active-backup". Defaults to "802.3ad".
+	// +optional
+	// +kubebuilder:validation:Enum="802.3ad";active-backup
+	Mode BondMode `json:"mode,omitempty"`
+
+	// Interfaces are the MAC addresses of the interfaces of the Hardware to bond. The first interface is the
+	// primary member: it is netbooted, and the bond uses its MAC address so DHCP reservations keep matching.
+	// Defaults to all interfaces of the Hardware with a MAC address, in order.
+	// +optional
+	Interfaces []string `json:"interfaces,omitempty"`
+}
+
+// File is a file written to the provisioned OS.
+type File struct {
+	// Path is the absolute path of the file on the OS partition, e.g. /etc/containerd/certs.d/registry/ca.crt.
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path"`
+
+	// Mode is the octal permissions of the file. Defaults to "0644".
+	// +optional
+	// +kubebuilder:validation:Pattern=`^0?[0-7]{3}$`
+	Mode string `json:"mode,omitempty"`
+
+	// Content is the content of the file. Exactly one of Content and ContentFrom must be set.
+	// +optional
+	Content string `json:"content,omitempty"`
+
+	// ContentFrom reads the content of the file from a Secret or ConfigMap in the namespace of the machine.
+	// +optional
+	ContentFrom *FileSource `json:"contentFrom,omitempty"`
+}
+
+// FileSource is the Secret or ConfigMap key holding the content of a file. Exactly one of Secret and ConfigMap
+// must be set.
+type FileSource struct {
+	// Secret is the key of a Secret holding the content.
+	// +optional
+	Secret *corev1.SecretKeySelector `json:"secret,omitempty"`
+
+	// ConfigMap is the key of a ConfigMap holding the content.
+	// +optional
+	ConfigMap *corev1.ConfigMapKeySelector `json:"configMap,omitempty"`
+}
+
 // BootOptions are options that control the booting of Hardware.
 type BootOptions struct {
 	// ISOURL is the URL of the ISO that will be one-time booted.
@@ -105,8 +665,54 @@ type BootOptions struct {
 	// +optional
 	// +kubebuilder:validation:Enum=none;netboot;iso
 	BootMode BootMode `json:"bootMode,omitempty"`
+
+	// PersistentNetboot makes the machine netboot an in-memory OS on every boot instead of installing an OS to
+	// disk, for diskless or ephemeral nodes. No Template or Workflow is created and Hardware without disks can be
+	// selected. Cannot be combined with the iso boot mode, templateOverride or workflowStages.
+	// +optional
+	PersistentNetboot *PersistentNetboot `json:"persistentNetboot,omitempty"`
+
+	// PowerManagement defines who manages the power of the Hardware. Must be one of "provider", "external" or
+	// "none". With "external" or "none" no BMC Jobs are created, neither by CAPT nor by Tinkerbell for the
+	// workflow, and the machine waits for the workflow to progress once the Hardware is booted by other means.
+	// With "external", events on the TinkerbellMachine describe the power actions to perform. Cannot be combined
+	// with the iso boot mode, which needs the BMC to mount the ISO. Defaults to "provider".
+	// +optional
+	// +kubebuilder:validation:Enum=provider;external;none
+	PowerManagement PowerManagement `json:"powerManagement,omitempty"`
+
+	// KernelArgs are appended to the kernel command line of the Hook OS netbooted to run the workflows of the
+	// machine, e.g. console= or iommu= settings or NIC quirks. CAPT then serves the iPXE script booting Hook from the
+	// netbooted interfaces of the Hardware instead of leaving it to Smee. Each entry is a single argument. Cannot be
+	// combined with the iso boot mode or persistentNetboot.
+	// +optional
+	KernelArgs []string `json:"kernelArgs,omitempty"`
+}
+
+// PersistentNetboot configures the in-memory OS netbooted on every boot of a machine.
+type PersistentNetboot struct {
+	// IPXEScriptURL is the URL of the iPXE script booting the in-memory OS. It is set on the netbooted
+	// interfaces of the Hardware, which keep netbooting for as long as the machine exists. The OS is expected to
+	// fetch its user-data from the metadata service on every boot, so it always boots with the current bootstrap
+	// data.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Format=url
+	IPXEScriptURL string `json:"ipxeScriptURL"`
 }
 
+// PreferredScoring is how the weights of the preferred hardware affinity terms matched by a Hardware are combined.
+// +kubebuilder:validation:Enum=LastMatch;Sum
+type PreferredScoring string
+
+const (
+	// PreferredScoringLastMatch scores Hardware with the weight of the last preferred term it matches.
+	PreferredScoringLastMatch PreferredScoring = "LastMatch"
+
+	// PreferredScoringSum scores Hardware with the sum of the weights of all the preferred terms it matches, like
+	// the preferred node affinity of Kubernetes.
+	PreferredScoringSum PreferredScoring = "Sum"
+)
+
 // HardwareAffinity defines the required and preferred hardware affinities.
 type HardwareAffinity struct {
 	// Required are the required hardware affinity terms.  The terms are OR'd together, hardware must match one term to
@@ -117,6 +723,22 @@ type HardwareAffinity struct {
 	// weights provided, but are not required.
 	// +optional
 	Preferred []WeightedHardwareAffinityTerm `json:"preferred,omitempty"`
+	// PreferredScoring is how the weights of the Preferred terms matched by a Hardware are combined: LastMatch, the
+	// default, keeps the weight of the last matching term, Sum adds the weights of all matching terms.
+	// +optional
+	PreferredScoring PreferredScoring `json:"preferredScoring,omitempty"`
+	// RequiredExpression is a CEL expression which hardware must satisfy, in addition to the Required terms, to be
+	// considered. The candidate Hardware is available as the "hardware" variable, e.g.
+	// `hardware.metadata.labels["rack"] != "r12" && size(hardware.spec.disks) > 1`. Hardware the expression fails to be
+	// evaluated against, e.g. looking up a missing key, is skipped. Expressions whose estimated cost exceeds the CEL cost
+	// limit are rejected.
+	// +optional
+	RequiredExpression string `json:"requiredExpression,omitempty"`
+	// ScoreExpression is a CEL expression evaluating to an int which is added to the weight of the matched Preferred
+	// terms when ranking hardware. The candidate Hardware is available as the "hardware" variable, e.g.
+	// `size(hardware.spec.disks) * 10`. Hardware the expression fails to be evaluated against is skipped.
+	// +optional
+	ScoreExpression string `json:"scoreExpression,omitempty"`
 }
 
 // HardwareAffinityTerm is used to select for a particular existing hardware resource.
@@ -125,8 +747,9 @@ type HardwareAffinityTerm struct {
 	LabelSelector metav1.LabelSelector `json:"labelSelector"`
 }
 
-// WeightedHardwareAffinityTerm is a HardwareAffinityTerm with an associated weight.  The weights of all the matched
-// WeightedHardwareAffinityTerm fields are added per-hardware to find the most preferred hardware.
+// WeightedHardwareAffinityTerm is a HardwareAffinityTerm with an associated weight. The weights of the matched
+// WeightedHardwareAffinityTerm fields are combined per-hardware according to the PreferredScoring of the affinity to
+// find the most preferred hardware.
 type WeightedHardwareAffinityTerm struct {
 	// Weight associated with matching the corresponding hardwareAffinityTerm, in the range 1-100.
 	// +kubebuilder:validation:Minimum=1
@@ -145,6 +768,11 @@ type TinkerbellMachineStatus struct {
 	// +optional
 	Ready bool `json:"ready"`
 
+	// Phase is the lifecycle phase of the machine, one of Pending, Provisioning, Provisioned, Deleting or Failed,
+	// for tooling which does not understand provider conditions.
+	// +optional
+	Phase TinkerbellMachinePhase `json:"phase,omitempty"`
+
 	// Addresses contains the Tinkerbell device associated addresses.
 	Addresses []corev1.NodeAddress `json:"addresses,omitempty"`
 
@@ -152,6 +780,16 @@ type TinkerbellMachineStatus struct {
 	// +optional
 	InstanceStatus *TinkerbellResourceStatus `json:"instanceStatus,omitempty"`
 
+	// TemplateName is the name of the Template installing the OS, in the namespace of the Hardware.
+	// +optional
+	TemplateName string `json:"templateName,omitempty"`
+
+	// WorkflowName is the name of the Workflow installing the OS, in the namespace of the Hardware. It is the name
+	// of the TinkerbellMachine unless that is too long or the Hardware is pooled, in which case it is shortened and
+	// suffixed with a hash.
+	// +optional
+	WorkflowName string `json:"workflowName,omitempty"`
+
 	// Any transient errors that occur during the reconciliation of Machines
 	// can be added as events to the Machine object and/or logged in the
 	// controller's output.
@@ -176,17 +814,118 @@ type TinkerbellMachineStatus struct {
 	// controller's output.
 	// +optional
 	ErrorMessage *string `json:"errorMessage,omitempty"`
+
+	// Conditions defines current service state of the TinkerbellMachine.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// Phases records when the TinkerbellMachine went through each provisioning phase.
+	// +optional
+	Phases *ProvisioningPhases `json:"phases,omitempty"`
+
+	// Workflow mirrors the status of the Workflow provisioning the machine. It is updated as soon as the Workflow
+	// changes, independently of the reconciliation of the TinkerbellMachine.
+	// +optional
+	Workflow *WorkflowStatus `json:"workflow,omitempty"`
+
+	// ConsoleLog references the ConfigMap, in the namespace of the machine, holding the end of the serial console
+	// output captured while the machine failed to provision. Only set for machines with consoleCapture.
+	// +optional
+	ConsoleLog *corev1.LocalObjectReference `json:"consoleLog,omitempty"`
+
+	// ObservedGeneration is the generation of the TinkerbellMachine last reconciled successfully. It is behind
+	// metadata.generation while a change of the spec was not acted upon yet.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// BMCJobRetries tracks the failed BMC Jobs of each BMC operation of the machine which is retried, until the
+	// operation completes or its retries are exhausted.
+	// +optional
+	// +listType=map
+	// +listMapKey=operation
+	BMCJobRetries []BMCJobRetry `json:"bmcJobRetries,omitempty"`
+}
+
+// BMCJobRetry tracks the retries of a BMC operation of a TinkerbellMachine whose BMC Jobs failed.
+type BMCJobRetry struct {
+	// Operation is the BMC operation, e.g. poweroff.
+	Operation string `json:"operation"`
+
+	// Failures is how many times the BMC Jobs performing the operation failed through all BMCs of the Hardware.
+	Failures int32 `json:"failures"`
+
+	// LastFailedJob is the name of the BMC Job which failed last.
+	LastFailedJob string `json:"lastFailedJob"`
+
+	// LastFailureTime is when the failure of the last failed BMC Job was observed.
+	LastFailureTime metav1.Time `json:"lastFailureTime"`
+}
+
+// WorkflowStatus is the status of a Workflow of a TinkerbellMachine, as reported by the Workflow.
+type WorkflowStatus struct {
+	// Name is the name of the Workflow, in the namespace of the Hardware. It is the name of a workflow stage
+	// Workflow while the stages run.
+	Name string `json:"name"`
+
+	// State is the state of the Workflow, e.g. STATE_RUNNING.
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// CurrentAction is the action the Workflow runs, or ran last.
+	// +optional
+	CurrentAction string `json:"currentAction,omitempty"`
+
+	// FailedAction is the action which failed or timed out, if any.
+	// +optional
+	FailedAction string `json:"failedAction,omitempty"`
+
+	// Output is the tail of the output the failed action reported.
+	// +optional
+	Output string `json:"output,omitempty"`
+}
+
+// ProvisioningPhases records when a TinkerbellMachine went through each provisioning phase. Each timestamp is
+// set once, the first time the phase is observed.
+type ProvisioningPhases struct {
+	// HardwareSelectedAt is when Hardware was claimed for the machine.
+	// +optional
+	HardwareSelectedAt *metav1.Time `json:"hardwareSelectedAt,omitempty"`
+
+	// WorkflowCreatedAt is when the provisioning Workflow was created.
+	// +optional
+	WorkflowCreatedAt *metav1.Time `json:"workflowCreatedAt,omitempty"`
+
+	// WorkflowStartedAt is when the first action of the provisioning Workflow started, i.e. when the Hardware
+	// booted into the provisioning environment.
+	// +optional
+	WorkflowStartedAt *metav1.Time `json:"workflowStartedAt,omitempty"`
+
+	// WorkflowCompletedAt is when the provisioning Workflow finished, successfully or not.
+	// +optional
+	WorkflowCompletedAt *metav1.Time `json:"workflowCompletedAt,omitempty"`
+
+	// ReadyAt is when the TinkerbellMachine was first marked as Ready.
+	// +optional
+	ReadyAt *metav1.Time `json:"readyAt,omitempty"`
+
+	// ProvisioningDuration is the time from the creation of the TinkerbellMachine to ReadyAt.
+	// +optional
+	ProvisioningDuration *metav1.Duration `json:"provisioningDuration,omitempty"`
 }
 
 // +kubebuilder:subresource:status
 // +kubebuilder:object:root=true
-// +kubebuilder:resource:path=tinkerbellmachines,scope=Namespaced,categories=cluster-api
+// +kubebuilder:resource:path=tinkerbellmachines,scope=Namespaced,categories=cluster-api,shortName=tm
 // +kubebuilder:storageversion
 // +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this TinkerbellMachine belongs"
-// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.instanceState",description="Tinkerbell instance state"
+// +kubebuilder:printcolumn:name="Hardware",type="string",JSONPath=".spec.hardwareName",description="Hardware claimed for this TinkerbellMachine"
+// +kubebuilder:printcolumn:name="ProviderID",type="string",JSONPath=".spec.providerID",description="Provider ID of the machine"
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Machine ready status"
-// +kubebuilder:printcolumn:name="InstanceID",type="string",JSONPath=".spec.providerID",description="Tinkerbell instance ID"
-// +kubebuilder:printcolumn:name="Machine",type="string",JSONPath=".metadata.ownerReferences[?(@.kind==\"Machine\")].name",description="Machine object which owns with this TinkerbellMachine"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Lifecycle phase of the machine"
+// +kubebuilder:printcolumn:name="Workflow",type="string",JSONPath=".status.conditions[?(@.type==\"WorkflowSucceeded\")].reason",description="Provisioning phase of the machine while its Workflow did not succeed",priority=1
+// +kubebuilder:printcolumn:name="Action",type="string",JSONPath=".status.workflow.currentAction",description="Action the Workflow of the machine runs, or ran last",priority=1
+// +kubebuilder:printcolumn:name="Machine",type="string",JSONPath=".metadata.ownerReferences[?(@.kind==\"Machine\")].name",description="Machine object which owns with this TinkerbellMachine",priority=1
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of TinkerbellMachine"
 
 // TinkerbellMachine is the Schema for the tinkerbellmachines API.
 type TinkerbellMachine struct {
@@ -197,6 +936,16 @@ type TinkerbellMachine struct {
 	Status TinkerbellMachineStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the observations of the operational state of the TinkerbellMachine resource.
+func (m *TinkerbellMachine) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the TinkerbellMachine to the predescribed clusterv1.Conditions.
+func (m *TinkerbellMachine) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // TinkerbellMachineList contains a list of TinkerbellMachine.
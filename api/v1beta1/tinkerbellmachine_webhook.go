@@ -17,10 +17,24 @@ limitations under the License.
 package v1beta1
 
 import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/hardwareexpr"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/imageurl"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/tinktemplate"
 )
 
 var _ admission.Validator = &TinkerbellMachine{}
@@ -45,11 +59,17 @@ func (m *TinkerbellMachine) ValidateUpdate(oldRaw runtime.Object) (admission.War
 
 	old, _ := oldRaw.(*TinkerbellMachine)
 
-	if old.Spec.HardwareName != "" && m.Spec.HardwareName != old.Spec.HardwareName {
+	// Both may be cleared to release Hardware which went missing, but not changed to other values.
+	if old.Spec.HardwareName != "" && m.Spec.HardwareName != "" && m.Spec.HardwareName != old.Spec.HardwareName {
 		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "hardwareName"), "is immutable once set"))
 	}
 
-	if old.Spec.ProviderID != "" && m.Spec.ProviderID != old.Spec.ProviderID {
+	if old.Spec.HardwareName != "" && m.Spec.HardwarePool != old.Spec.HardwarePool {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "hardwarePool"),
+			"is immutable once Hardware is selected"))
+	}
+
+	if old.Spec.ProviderID != "" && m.Spec.ProviderID != "" && m.Spec.ProviderID != old.Spec.ProviderID {
 		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "providerID"), "is immutable once set"))
 	}
 
@@ -68,15 +88,577 @@ func (m *TinkerbellMachine) validateSpec() field.ErrorList {
 
 	// TODO: there are probably more fields that have requirements
 
-	if spec := m.Spec; spec.HardwareAffinity != nil {
-		for i, term := range spec.HardwareAffinity.Preferred {
-			if term.Weight < 1 || term.Weight > 100 {
+	if m.Spec.HardwareAffinity != nil {
+		allErrs = append(allErrs, m.Spec.HardwareAffinity.validate(fieldBasePath.Child("hardwareAffinity"))...)
+	}
+
+	for action, env := range m.Spec.ActionEnvironment {
+		if action == "" {
+			allErrs = append(allErrs,
+				field.Invalid(fieldBasePath.Child("actionEnvironment"), action, "action name must not be empty"))
+		}
+
+		for name := range env {
+			if name == "" {
 				allErrs = append(allErrs,
-					field.Invalid(fieldBasePath.Child("HardwareAffinity", "Preferred").Index(i),
-						term.Weight, "must be in the range [1,100]"))
+					field.Invalid(fieldBasePath.Child("actionEnvironment").Key(action), name,
+						"environment variable name must not be empty"))
 			}
 		}
 	}
 
+	if m.Spec.WorkflowTimeouts != nil {
+		allErrs = append(allErrs, m.Spec.WorkflowTimeouts.validate(fieldBasePath.Child("workflowTimeouts"))...)
+	}
+
+	allErrs = append(allErrs, m.Spec.BootOptions.validate(fieldBasePath.Child("bootOptions"))...)
+	allErrs = append(allErrs, validateHTTPURL(fieldBasePath.Child("metadataURL"), m.Spec.MetadataURL)...)
+
+	if m.Spec.Endpoints != nil {
+		allErrs = append(allErrs, m.Spec.Endpoints.validate(fieldBasePath.Child("endpoints"))...)
+	}
+
+	if m.Spec.PostReleaseHook != nil {
+		allErrs = append(allErrs, m.Spec.PostReleaseHook.validate(fieldBasePath.Child("postReleaseHook"))...)
+	}
+
+	if key := m.Spec.BootstrapDataKey; key != "" {
+		for _, msg := range validation.IsConfigMapKey(key) {
+			allErrs = append(allErrs, field.Invalid(fieldBasePath.Child("bootstrapDataKey"), key, msg))
+		}
+	}
+
+	if m.Spec.BootOptions.PersistentNetboot != nil {
+		if custom := m.Spec.customTemplateField(); custom != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldBasePath.Child(custom),
+				"cannot be combined with bootOptions.persistentNetboot, which runs no workflow"))
+		}
+
+		if len(m.Spec.WorkflowStages) > 0 {
+			allErrs = append(allErrs, field.Forbidden(fieldBasePath.Child("workflowStages"),
+				"cannot be combined with bootOptions.persistentNetboot, which runs no workflow"))
+		}
+
+		if m.Spec.Storage != nil && m.Spec.Storage.RootDiskSelector != nil {
+			allErrs = append(allErrs, field.Forbidden(fieldBasePath.Child("storage", "rootDiskSelector"),
+				"cannot be combined with bootOptions.persistentNetboot, which installs no OS"))
+		}
+
+		if m.Spec.Storage != nil && len(m.Spec.Storage.DataDisks) > 0 {
+			allErrs = append(allErrs, field.Forbidden(fieldBasePath.Child("storage", "dataDisks"),
+				"cannot be combined with bootOptions.persistentNetboot, which installs no OS"))
+		}
+
+		if m.Spec.ConsoleCapture != nil {
+			allErrs = append(allErrs, field.Forbidden(fieldBasePath.Child("consoleCapture"),
+				"cannot be combined with bootOptions.persistentNetboot, which runs no workflow"))
+		}
+	}
+
+	if c := m.Spec.ConsoleCapture; c != nil && c.Duration != nil && c.Duration.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fieldBasePath.Child("consoleCapture", "duration"),
+			c.Duration.Duration.String(), "must be positive"))
+	}
+
+	allErrs = append(allErrs, m.Spec.validateImage(fieldBasePath)...)
+
+	if m.Spec.StaticNetwork != nil {
+		allErrs = append(allErrs, m.Spec.StaticNetwork.validate(fieldBasePath.Child("staticNetwork"))...)
+	}
+
+	allErrs = append(allErrs, m.Spec.WorkerDevice.validate(fieldBasePath.Child("workerDevice"))...)
+
+	if m.Spec.Bond != nil {
+		allErrs = append(allErrs, m.Spec.Bond.validate(fieldBasePath.Child("bond"))...)
+
+		if m.Spec.WorkerDevice.MAC != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldBasePath.Child("workerDevice", "mac"),
+				"cannot be combined with bond, the primary member of the bond is netbooted"))
+		}
+
+		if m.Spec.StaticNetwork != nil && m.Spec.StaticNetwork.MACAddress != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldBasePath.Child("staticNetwork", "macAddress"),
+				"cannot be combined with bond, the static network configuration applies to the bond"))
+		}
+	}
+
+	return allErrs
+}
+
+func (s TinkerbellMachineSpec) validateImage(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	custom := s.customTemplateField()
+	customMsg := "only applies to the default template and cannot be combined with " + custom
+
+	if err := imageurl.Validate(s.ImageLookupFormat); err != nil {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("imageLookupFormat"), s.ImageLookupFormat, err.Error()))
+	}
+
+	switch s.Image.Format {
+	case "", ImageFormatGzip, ImageFormatRaw, ImageFormatQCOW2, ImageFormatWIM:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fieldPath.Child("image", "format"), s.Image.Format,
+			[]string{string(ImageFormatGzip), string(ImageFormatRaw), string(ImageFormatQCOW2), string(ImageFormatWIM)}))
+	}
+
+	if s.Image.Format == ImageFormatWIM && !s.Image.Windows() {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("image", "format"), s.Image.Format,
+			"wim images are only supported with osFamily windows"))
+	}
+
+	if s.Image.Format == ImageFormatWIM && s.Image.WIMApplyAction == "" {
+		allErrs = append(allErrs, field.Required(fieldPath.Child("image", "wimApplyAction"),
+			"wim images are applied by this action"))
+	}
+
+	if s.Image.Format != ImageFormatWIM && s.Image.WIMApplyAction != "" {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("image", "wimApplyAction"),
+			"only applies to wim images"))
+	}
+
+	if s.Image.Format != "" && custom != "" {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("image", "format"), customMsg))
+	}
+
+	switch s.Image.OSFamily {
+	case "", OSFamilyLinux, OSFamilyWindows:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fieldPath.Child("image", "osFamily"), s.Image.OSFamily,
+			[]string{string(OSFamilyLinux), string(OSFamilyWindows)}))
+	}
+
+	if s.Image.OSPartition < 0 {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("image", "osPartition"), s.Image.OSPartition,
+			"must be a partition number, starting at 1"))
+	}
+
+	if custom != "" && s.Image.OSFamily != "" {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("image", "osFamily"), customMsg))
+	}
+
+	// The partition and root disk are rendered into library templates, but not into template overrides.
+	if s.TemplateOverride != "" && s.Image.OSPartition != 0 {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("image", "osPartition"), customMsg))
+	}
+
+	if custom != "" && len(s.Files) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("files"), customMsg))
+	}
+
+	for i, f := range s.Files {
+		allErrs = append(allErrs, f.validate(fieldPath.Child("files").Index(i))...)
+	}
+
+	if s.Storage != nil && s.Storage.RootDiskSelector != nil {
+		selectorPath := fieldPath.Child("storage", "rootDiskSelector")
+
+		if s.TemplateOverride != "" {
+			allErrs = append(allErrs, field.Forbidden(selectorPath, customMsg))
+		}
+
+		allErrs = append(allErrs, s.Storage.RootDiskSelector.validate(selectorPath)...)
+	}
+
+	if s.Storage != nil {
+		allErrs = append(allErrs, s.Storage.validateDataDisks(fieldPath.Child("storage", "dataDisks"), custom)...)
+	}
+
+	if s.Image.Windows() {
+		if s.StaticNetwork != nil {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("staticNetwork"),
+				"is not supported for windows images, which get their network configuration through DHCP"))
+		}
+
+		if s.Bond != nil {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("bond"),
+				"is not supported for windows images"))
+		}
+
+		if s.Storage != nil && len(s.Storage.DataDisks) > 0 {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("storage", "dataDisks"),
+				"is not supported for windows images, which are not configured with cloud-init"))
+		}
+	}
+
+	if s.TemplateOverride != "" {
+		if err := tinktemplate.Validate(s.TemplateOverride, nil); err != nil {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("templateOverride"), field.OmitValueType{},
+				err.Error()))
+		}
+	}
+
+	if s.TemplateRefName != "" {
+		refPath := fieldPath.Child("templateRefName")
+
+		if s.TemplateOverride != "" {
+			allErrs = append(allErrs, field.Forbidden(refPath, "cannot be combined with templateOverride"))
+		}
+
+		for _, msg := range validation.IsConfigMapKey(s.TemplateRefName) {
+			allErrs = append(allErrs, field.Invalid(refPath, s.TemplateRefName, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// customTemplateField returns the field replacing the default template of the machine, empty when it uses the
+// default template.
+func (s TinkerbellMachineSpec) customTemplateField() string {
+	switch {
+	case s.TemplateOverride != "":
+		return "templateOverride"
+	case s.TemplateRefName != "":
+		return "templateRefName"
+	default:
+		return ""
+	}
+}
+
+// isSpaceOrControl returns true for whitespace and control characters, which would split or end a kernel argument.
+func isSpaceOrControl(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsControl(r)
+}
+
+func (f File) validate(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if !path.IsAbs(f.Path) || path.Clean(f.Path) != f.Path {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("path"), f.Path, "must be a clean absolute path"))
+	}
+
+	if (f.Content == "") == (f.ContentFrom == nil) {
+		allErrs = append(allErrs, field.Invalid(fieldPath, field.OmitValueType{},
+			"exactly one of content and contentFrom must be set"))
+	}
+
+	if from := f.ContentFrom; from != nil && (from.Secret == nil) == (from.ConfigMap == nil) {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("contentFrom"), field.OmitValueType{},
+			"exactly one of secret and configMap must be set"))
+	}
+
+	return allErrs
+}
+
+func (a HardwareAffinity) validate(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, term := range a.Required {
+		allErrs = append(allErrs,
+			term.validate(fieldPath.Child("required").Index(i).Child("labelSelector"), true)...)
+	}
+
+	for i, term := range a.Preferred {
+		termPath := fieldPath.Child("preferred").Index(i)
+
+		if term.Weight < 1 || term.Weight > 100 {
+			allErrs = append(allErrs,
+				field.Invalid(termPath.Child("weight"), term.Weight, "must be in the range [1,100]"))
+		}
+
+		allErrs = append(allErrs,
+			term.HardwareAffinityTerm.validate(termPath.Child("hardwareAffinityTerm", "labelSelector"), false)...)
+	}
+
+	if expr := a.RequiredExpression; expr != "" {
+		if program, err := hardwareexpr.CompileFilter(expr); err != nil {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("requiredExpression"), expr, err.Error()))
+		} else if err := program.CheckCost(); err != nil {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("requiredExpression"), expr, err.Error()))
+		}
+	}
+
+	if expr := a.ScoreExpression; expr != "" {
+		if program, err := hardwareexpr.CompileScore(expr); err != nil {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("scoreExpression"), expr, err.Error()))
+		} else if err := program.CheckCost(); err != nil {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("scoreExpression"), expr, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// validate checks that the label selector of the term parses and, for required terms, that it can match Hardware:
+// as the required terms are OR'd, a term whose requirements contradict each other never selects any Hardware.
+func (t HardwareAffinityTerm) validate(fieldPath *field.Path, required bool) field.ErrorList {
+	if _, err := metav1.LabelSelectorAsSelector(&t.LabelSelector); err != nil {
+		return field.ErrorList{field.Invalid(fieldPath, metav1.FormatLabelSelector(&t.LabelSelector), err.Error())}
+	}
+
+	if !required {
+		return nil
+	}
+
+	if key, msg := contradictingLabelRequirement(t.LabelSelector); key != "" {
+		return field.ErrorList{field.Invalid(fieldPath, metav1.FormatLabelSelector(&t.LabelSelector),
+			fmt.Sprintf("matches no Hardware, the requirements of label %q contradict each other: %s", key, msg))}
+	}
+
+	return nil
+}
+
+// labelConstraint are the values a label may take to satisfy the requirements of a label selector.
+type labelConstraint struct {
+	// allowed are the values the label must have one of, nil when any value is allowed.
+	allowed  sets.Set[string]
+	excluded sets.Set[string]
+	exists   bool
+	absent   bool
+}
+
+// contradictingLabelRequirement returns the first label, in key order, whose requirements in the parsed label selector
+// no value or absence of the label satisfies, with the reason. It returns an empty key when the selector can match.
+func contradictingLabelRequirement(selector metav1.LabelSelector) (key, msg string) {
+	constraints := map[string]*labelConstraint{}
+
+	constraint := func(key string) *labelConstraint {
+		if constraints[key] == nil {
+			constraints[key] = &labelConstraint{excluded: sets.New[string]()}
+		}
+
+		return constraints[key]
+	}
+
+	allow := func(c *labelConstraint, values ...string) {
+		c.exists = true
+
+		if c.allowed == nil {
+			c.allowed = sets.New(values...)
+		} else {
+			c.allowed = c.allowed.Intersection(sets.New(values...))
+		}
+	}
+
+	for key, value := range selector.MatchLabels {
+		allow(constraint(key), value)
+	}
+
+	for _, expr := range selector.MatchExpressions {
+		c := constraint(expr.Key)
+
+		switch expr.Operator {
+		case metav1.LabelSelectorOpIn:
+			allow(c, expr.Values...)
+		case metav1.LabelSelectorOpNotIn:
+			c.excluded.Insert(expr.Values...)
+		case metav1.LabelSelectorOpExists:
+			c.exists = true
+		case metav1.LabelSelectorOpDoesNotExist:
+			c.absent = true
+		}
+	}
+
+	for _, key := range sets.List(sets.KeySet(constraints)) {
+		c := constraints[key]
+
+		switch {
+		case c.exists && c.absent:
+			return key, "it is required both to exist and not to exist"
+		case c.allowed != nil && c.allowed.Difference(c.excluded).Len() == 0:
+			return key, "no value is both required and not excluded"
+		}
+	}
+
+	return "", ""
+}
+
+func (s Storage) validateDataDisks(fieldPath *field.Path, custom string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(s.DataDisks) > 0 && custom != "" {
+		allErrs = append(allErrs, field.Forbidden(fieldPath,
+			"only applies to the default template and cannot be combined with "+custom))
+	}
+
+	mountPaths := map[string]bool{}
+
+	for i, disk := range s.DataDisks {
+		diskPath := fieldPath.Index(i)
+
+		allErrs = append(allErrs, disk.Selector.validate(diskPath.Child("selector"))...)
+
+		switch {
+		case !path.IsAbs(disk.MountPath) || path.Clean(disk.MountPath) != disk.MountPath:
+			allErrs = append(allErrs, field.Invalid(diskPath.Child("mountPath"), disk.MountPath,
+				"must be a clean absolute path"))
+		case disk.MountPath == "/":
+			allErrs = append(allErrs, field.Invalid(diskPath.Child("mountPath"), disk.MountPath,
+				"must not be the root of the filesystem"))
+		case mountPaths[disk.MountPath]:
+			allErrs = append(allErrs, field.Duplicate(diskPath.Child("mountPath"), disk.MountPath))
+		}
+
+		mountPaths[disk.MountPath] = true
+
+		if strings.ContainsFunc(disk.MountOptions, isSpaceOrControl) {
+			allErrs = append(allErrs, field.Invalid(diskPath.Child("mountOptions"), disk.MountOptions,
+				"must not contain whitespace or control characters"))
+		}
+	}
+
+	return allErrs
+}
+
+func (r RootDiskSelector) validate(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if r.Serial == "" && r.WWN == "" && r.MinSize == nil && r.MaxSize == nil && r.Rotational == nil {
+		allErrs = append(allErrs, field.Required(fieldPath,
+			"at least one of serial, wwn, minSize, maxSize and rotational must be set"))
+	}
+
+	if r.MinSize != nil && r.MinSize.Sign() < 0 {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("minSize"), r.MinSize.String(), "must not be negative"))
+	}
+
+	if r.MinSize != nil && r.MaxSize != nil && r.MaxSize.Cmp(*r.MinSize) < 0 {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("maxSize"), r.MaxSize.String(),
+			"must not be less than minSize"))
+	}
+
+	return allErrs
+}
+
+func (n StaticNetwork) validate(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	ip, network, err := net.ParseCIDR(n.Address)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("address"), n.Address,
+			"must be an IP address with prefix length, e.g. 10.0.0.10/24"))
+	}
+
+	if n.Gateway != "" {
+		gateway := net.ParseIP(n.Gateway)
+
+		switch {
+		case gateway == nil:
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("gateway"), n.Gateway, "must be an IP address"))
+		case network != nil && !network.Contains(gateway):
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("gateway"), n.Gateway,
+				fmt.Sprintf("must be part of the network %s", network)))
+		case gateway.Equal(ip):
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("gateway"), n.Gateway,
+				"must not be the address of the machine"))
+		}
+	}
+
+	for i, nameserver := range n.Nameservers {
+		if net.ParseIP(nameserver) == nil {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("nameservers").Index(i), nameserver,
+				"must be an IP address"))
+		}
+	}
+
+	if n.MACAddress != "" {
+		if _, err := net.ParseMAC(n.MACAddress); err != nil {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("macAddress"), n.MACAddress, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+func (b Bond) validate(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch b.Mode {
+	case "", BondModeLACP, BondModeActiveBackup:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fieldPath.Child("mode"), b.Mode,
+			[]string{string(BondModeLACP), string(BondModeActiveBackup)}))
+	}
+
+	seen := map[string]bool{}
+
+	for i, mac := range b.Interfaces {
+		hw, err := net.ParseMAC(mac)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("interfaces").Index(i), mac, err.Error()))
+
+			continue
+		}
+
+		if seen[hw.String()] {
+			allErrs = append(allErrs, field.Duplicate(fieldPath.Child("interfaces").Index(i), mac))
+		}
+
+		seen[hw.String()] = true
+	}
+
+	return allErrs
+}
+
+func (d WorkerDevice) validate(fieldPath *field.Path) field.ErrorList {
+	if d.MAC == "" {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+
+	if _, err := net.ParseMAC(d.MAC); err != nil {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("mac"), d.MAC, err.Error()))
+	}
+
+	if d.Source != WorkerDeviceSourceMAC {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("mac"),
+			fmt.Sprintf("requires source %q", WorkerDeviceSourceMAC)))
+	}
+
+	return allErrs
+}
+
+func (o BootOptions) validate(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if o.ISOURL != "" {
+		u, err := url.Parse(o.ISOURL)
+
+		switch {
+		case err != nil:
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("isoURL"), o.ISOURL, err.Error()))
+		case u.Scheme != "http" && u.Scheme != "https":
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("isoURL"), o.ISOURL, "must be an http or https URL"))
+		case !strings.HasSuffix(u.Path, ".iso"):
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("isoURL"), o.ISOURL, "must point to a .iso file"))
+		}
+	}
+
+	if o.BootMode == BootModeISO && o.ISOURL == "" {
+		allErrs = append(allErrs, field.Required(fieldPath.Child("isoURL"), "is required when bootMode is iso"))
+	}
+
+	if o.PersistentNetboot != nil {
+		u, err := url.Parse(o.PersistentNetboot.IPXEScriptURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("persistentNetboot", "ipxeScriptURL"),
+				o.PersistentNetboot.IPXEScriptURL, "must be an http or https URL"))
+		}
+
+		if o.BootMode == BootModeISO {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("persistentNetboot"),
+				"cannot be combined with bootMode iso"))
+		}
+	}
+
+	for i, arg := range o.KernelArgs {
+		if arg == "" || strings.ContainsFunc(arg, isSpaceOrControl) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("kernelArgs").Index(i), arg,
+				"must be a single argument without whitespace"))
+		}
+	}
+
+	if len(o.KernelArgs) > 0 && (o.BootMode == BootModeISO || o.PersistentNetboot != nil) {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("kernelArgs"),
+			"cannot be combined with bootMode iso or persistentNetboot"))
+	}
+
+	if o.BootMode == BootModeISO && o.PowerManagement != "" && o.PowerManagement != PowerManagementProvider {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("powerManagement"),
+			"must be provider when bootMode is iso"))
+	}
+
 	return allErrs
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -25,16 +26,27 @@ type TinkerbellMachineTemplateSpec struct {
 	Template TinkerbellMachineTemplateResource `json:"template"`
 }
 
+// TinkerbellMachineTemplateStatus defines the observed state of TinkerbellMachineTemplate.
+type TinkerbellMachineTemplateStatus struct {
+	// Capacity is the resource capacity of the Nodes of machines created from the template, derived from the
+	// resources of the Hardware matching its hardware affinity and the cluster-autoscaler capacity annotations of the
+	// template. The cluster-autoscaler uses it to scale MachineDeployments from zero.
+	// +optional
+	Capacity corev1.ResourceList `json:"capacity,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:path=tinkerbellmachinetemplates,scope=Namespaced,categories=cluster-api
 // +kubebuilder:storageversion
+// +kubebuilder:subresource:status
 
 // TinkerbellMachineTemplate is the Schema for the tinkerbellmachinetemplates API.
 type TinkerbellMachineTemplate struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec TinkerbellMachineTemplateSpec `json:"spec,omitempty"`
+	Spec   TinkerbellMachineTemplateSpec   `json:"spec,omitempty"`
+	Status TinkerbellMachineTemplateStatus `json:"status,omitempty"`
 }
 
 // +kubebuilder:object:root=true
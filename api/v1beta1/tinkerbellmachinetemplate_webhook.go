@@ -50,6 +50,29 @@ func (m *TinkerbellMachineTemplate) ValidateCreate() (admission.Warnings, error)
 		allErrs = append(allErrs, field.Forbidden(fieldBasePath.Child("hardwareName"), "cannot be set in templates"))
 	}
 
+	if spec.StaticNetwork != nil {
+		allErrs = append(allErrs, field.Forbidden(fieldBasePath.Child("staticNetwork"),
+			"cannot be set in templates, all machines created from the template would get the same address"))
+	}
+
+	if spec.WorkerDevice.MAC != "" {
+		allErrs = append(allErrs, field.Forbidden(fieldBasePath.Child("workerDevice", "mac"),
+			"cannot be set in templates, all machines created from the template would pin the same interface"))
+	}
+
+	allErrs = append(allErrs, spec.WorkerDevice.validate(fieldBasePath.Child("workerDevice"))...)
+
+	if spec.HardwareAffinity != nil {
+		allErrs = append(allErrs, spec.HardwareAffinity.validate(fieldBasePath.Child("hardwareAffinity"))...)
+	}
+
+	if spec.PostReleaseHook != nil {
+		allErrs = append(allErrs, spec.PostReleaseHook.validate(fieldBasePath.Child("postReleaseHook"))...)
+	}
+
+	allErrs = append(allErrs, spec.BootOptions.validate(fieldBasePath.Child("bootOptions"))...)
+	allErrs = append(allErrs, spec.validateImage(fieldBasePath)...)
+
 	return nil, aggregateObjErrors(m.GroupVersionKind().GroupKind(), m.Name, allErrs)
 }
 
@@ -17,6 +17,10 @@ limitations under the License.
 package v1beta1
 
 import (
+	"net"
+	"net/url"
+	"strings"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -33,3 +37,82 @@ func aggregateObjErrors(gk schema.GroupKind, name string, allErrs field.ErrorLis
 		allErrs,
 	)
 }
+
+// validateHTTPURL validates a URL machines reach a service under, e.g. the Tinkerbell metadata service.
+func validateHTTPURL(fieldPath *field.Path, rawURL string) field.ErrorList {
+	if rawURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return field.ErrorList{field.Invalid(fieldPath, rawURL, "must be an http or https URL with a host")}
+	}
+
+	return nil
+}
+
+// validate validates the URL of the post-release hook: an http or https URL without credentials, whose host is not
+// a loopback, link-local, multicast or unspecified address. CAPT also refuses to connect to such addresses when the
+// host is a name resolving to one.
+func (h *PostReleaseHook) validate(fieldPath *field.Path) field.ErrorList {
+	if allErrs := validateHTTPURL(fieldPath.Child("url"), h.URL); len(allErrs) > 0 {
+		return allErrs
+	}
+
+	u, _ := url.Parse(h.URL)
+	if u.User != nil {
+		return field.ErrorList{field.Invalid(fieldPath.Child("url"), h.URL, "must not contain credentials")}
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); strings.EqualFold(host, "localhost") || (ip != nil && (ip.IsLoopback() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())) {
+		return field.ErrorList{field.Invalid(fieldPath.Child("url"), h.URL,
+			"must not be a loopback, link-local, multicast or unspecified address")}
+	}
+
+	return nil
+}
+
+// validate validates the provisioning endpoints.
+func (e ProvisioningEndpoints) validate(fieldPath *field.Path) field.ErrorList {
+	allErrs := validateHTTPURL(fieldPath.Child("hookURL"), e.HookURL)
+	allErrs = append(allErrs, validateHTTPURL(fieldPath.Child("bootstrapReportURL"), e.BootstrapReportURL)...)
+
+	if e.TinkServerAddress != "" {
+		if host, port, err := net.SplitHostPort(e.TinkServerAddress); err != nil || host == "" || port == "" {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("tinkServerAddress"), e.TinkServerAddress,
+				"must be a host and port"))
+		}
+	}
+
+	if strings.ContainsFunc(e.SyslogHost, isSpaceOrControl) {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("syslogHost"), e.SyslogHost,
+			"must not contain whitespace"))
+	}
+
+	return allErrs
+}
+
+// validate validates the timeouts of a workflow.
+func (t WorkflowTimeouts) validate(fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if t.Global < 0 {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("global"), t.Global, "must be a positive number of seconds"))
+	}
+
+	for action, timeout := range t.Actions {
+		if action == "" {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("actions"), action, "action name must not be empty"))
+		}
+
+		if timeout <= 0 {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("actions").Key(action), timeout,
+				"must be a positive number of seconds"))
+		}
+	}
+
+	return allErrs
+}
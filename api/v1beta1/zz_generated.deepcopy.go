@@ -21,14 +21,77 @@ limitations under the License.
 package v1beta1
 
 import (
-	"k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	apiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/errors"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ActionImages) DeepCopyInto(out *ActionImages) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActionImages.
+func (in *ActionImages) DeepCopy() *ActionImages {
+	if in == nil {
+		return nil
+	}
+	out := new(ActionImages)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BMCJobRetry) DeepCopyInto(out *BMCJobRetry) {
+	*out = *in
+	in.LastFailureTime.DeepCopyInto(&out.LastFailureTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BMCJobRetry.
+func (in *BMCJobRetry) DeepCopy() *BMCJobRetry {
+	if in == nil {
+		return nil
+	}
+	out := new(BMCJobRetry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Bond) DeepCopyInto(out *Bond) {
+	*out = *in
+	if in.Interfaces != nil {
+		in, out := &in.Interfaces, &out.Interfaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Bond.
+func (in *Bond) DeepCopy() *Bond {
+	if in == nil {
+		return nil
+	}
+	out := new(Bond)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BootOptions) DeepCopyInto(out *BootOptions) {
 	*out = *in
+	if in.PersistentNetboot != nil {
+		in, out := &in.PersistentNetboot, &out.PersistentNetboot
+		*out = new(PersistentNetboot)
+		**out = **in
+	}
+	if in.KernelArgs != nil {
+		in, out := &in.KernelArgs, &out.KernelArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BootOptions.
@@ -41,6 +104,107 @@ func (in *BootOptions) DeepCopy() *BootOptions {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsoleCapture) DeepCopyInto(out *ConsoleCapture) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsoleCapture.
+func (in *ConsoleCapture) DeepCopy() *ConsoleCapture {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsoleCapture)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControlPlaneEndpointProbe) DeepCopyInto(out *ControlPlaneEndpointProbe) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControlPlaneEndpointProbe.
+func (in *ControlPlaneEndpointProbe) DeepCopy() *ControlPlaneEndpointProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(ControlPlaneEndpointProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataDisk) DeepCopyInto(out *DataDisk) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataDisk.
+func (in *DataDisk) DeepCopy() *DataDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(DataDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *File) DeepCopyInto(out *File) {
+	*out = *in
+	if in.ContentFrom != nil {
+		in, out := &in.ContentFrom, &out.ContentFrom
+		*out = new(FileSource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new File.
+func (in *File) DeepCopy() *File {
+	if in == nil {
+		return nil
+	}
+	out := new(File)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileSource) DeepCopyInto(out *FileSource) {
+	*out = *in
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileSource.
+func (in *FileSource) DeepCopy() *FileSource {
+	if in == nil {
+		return nil
+	}
+	out := new(FileSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HardwareAffinity) DeepCopyInto(out *HardwareAffinity) {
 	*out = *in
@@ -86,13 +250,341 @@ func (in *HardwareAffinityTerm) DeepCopy() *HardwareAffinityTerm {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwarePool) DeepCopyInto(out *HardwarePool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwarePool.
+func (in *HardwarePool) DeepCopy() *HardwarePool {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwarePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HardwarePool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwarePoolList) DeepCopyInto(out *HardwarePoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HardwarePool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwarePoolList.
+func (in *HardwarePoolList) DeepCopy() *HardwarePoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwarePoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HardwarePoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwarePoolSource) DeepCopyInto(out *HardwarePoolSource) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwarePoolSource.
+func (in *HardwarePoolSource) DeepCopy() *HardwarePoolSource {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwarePoolSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwarePoolSpec) DeepCopyInto(out *HardwarePoolSpec) {
+	*out = *in
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]HardwarePoolSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwarePoolSpec.
+func (in *HardwarePoolSpec) DeepCopy() *HardwarePoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwarePoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageSpec) DeepCopyInto(out *ImageSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSpec.
+func (in *ImageSpec) DeepCopy() *ImageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageVerification) DeepCopyInto(out *ImageVerification) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageVerification.
+func (in *ImageVerification) DeepCopy() *ImageVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PersistentNetboot) DeepCopyInto(out *PersistentNetboot) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PersistentNetboot.
+func (in *PersistentNetboot) DeepCopy() *PersistentNetboot {
+	if in == nil {
+		return nil
+	}
+	out := new(PersistentNetboot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostReleaseHook) DeepCopyInto(out *PostReleaseHook) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostReleaseHook.
+func (in *PostReleaseHook) DeepCopy() *PostReleaseHook {
+	if in == nil {
+		return nil
+	}
+	out := new(PostReleaseHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisioningEndpoints) DeepCopyInto(out *ProvisioningEndpoints) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisioningEndpoints.
+func (in *ProvisioningEndpoints) DeepCopy() *ProvisioningEndpoints {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisioningEndpoints)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisioningPhases) DeepCopyInto(out *ProvisioningPhases) {
+	*out = *in
+	if in.HardwareSelectedAt != nil {
+		in, out := &in.HardwareSelectedAt, &out.HardwareSelectedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.WorkflowCreatedAt != nil {
+		in, out := &in.WorkflowCreatedAt, &out.WorkflowCreatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.WorkflowStartedAt != nil {
+		in, out := &in.WorkflowStartedAt, &out.WorkflowStartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.WorkflowCompletedAt != nil {
+		in, out := &in.WorkflowCompletedAt, &out.WorkflowCompletedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ReadyAt != nil {
+		in, out := &in.ReadyAt, &out.ReadyAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ProvisioningDuration != nil {
+		in, out := &in.ProvisioningDuration, &out.ProvisioningDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisioningPhases.
+func (in *ProvisioningPhases) DeepCopy() *ProvisioningPhases {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisioningPhases)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Proxy) DeepCopyInto(out *Proxy) {
+	*out = *in
+	if in.NoProxy != nil {
+		in, out := &in.NoProxy, &out.NoProxy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Proxy.
+func (in *Proxy) DeepCopy() *Proxy {
+	if in == nil {
+		return nil
+	}
+	out := new(Proxy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RootDiskSelector) DeepCopyInto(out *RootDiskSelector) {
+	*out = *in
+	if in.MinSize != nil {
+		in, out := &in.MinSize, &out.MinSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MaxSize != nil {
+		in, out := &in.MaxSize, &out.MaxSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Rotational != nil {
+		in, out := &in.Rotational, &out.Rotational
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RootDiskSelector.
+func (in *RootDiskSelector) DeepCopy() *RootDiskSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(RootDiskSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StaticNetwork) DeepCopyInto(out *StaticNetwork) {
+	*out = *in
+	if in.Nameservers != nil {
+		in, out := &in.Nameservers, &out.Nameservers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StaticNetwork.
+func (in *StaticNetwork) DeepCopy() *StaticNetwork {
+	if in == nil {
+		return nil
+	}
+	out := new(StaticNetwork)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Storage) DeepCopyInto(out *Storage) {
+	*out = *in
+	if in.RootDiskSelector != nil {
+		in, out := &in.RootDiskSelector, &out.RootDiskSelector
+		*out = new(RootDiskSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DataDisks != nil {
+		in, out := &in.DataDisks, &out.DataDisks
+		*out = make([]DataDisk, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Storage.
+func (in *Storage) DeepCopy() *Storage {
+	if in == nil {
+		return nil
+	}
+	out := new(Storage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TinkerbellCluster) DeepCopyInto(out *TinkerbellCluster) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellCluster.
@@ -149,6 +641,56 @@ func (in *TinkerbellClusterList) DeepCopyObject() runtime.Object {
 func (in *TinkerbellClusterSpec) DeepCopyInto(out *TinkerbellClusterSpec) {
 	*out = *in
 	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	if in.ControlPlaneEndpointProbe != nil {
+		in, out := &in.ControlPlaneEndpointProbe, &out.ControlPlaneEndpointProbe
+		*out = new(ControlPlaneEndpointProbe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageCatalogRef != nil {
+		in, out := &in.ImageCatalogRef, &out.ImageCatalogRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.TemplateLibraryRef != nil {
+		in, out := &in.TemplateLibraryRef, &out.TemplateLibraryRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = new(ProvisioningEndpoints)
+		**out = **in
+	}
+	if in.WorkflowTimeouts != nil {
+		in, out := &in.WorkflowTimeouts, &out.WorkflowTimeouts
+		*out = new(WorkflowTimeouts)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Proxy != nil {
+		in, out := &in.Proxy, &out.Proxy
+		*out = new(Proxy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ActionImages != nil {
+		in, out := &in.ActionImages, &out.ActionImages
+		*out = new(ActionImages)
+		**out = **in
+	}
+	if in.ImageVerification != nil {
+		in, out := &in.ImageVerification, &out.ImageVerification
+		*out = new(ImageVerification)
+		**out = **in
+	}
+	if in.HardwareFailureCooldown != nil {
+		in, out := &in.HardwareFailureCooldown, &out.HardwareFailureCooldown
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellClusterSpec.
@@ -164,6 +706,13 @@ func (in *TinkerbellClusterSpec) DeepCopy() *TinkerbellClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TinkerbellClusterStatus) DeepCopyInto(out *TinkerbellClusterStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellClusterStatus.
@@ -238,12 +787,79 @@ func (in *TinkerbellMachineList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TinkerbellMachineSpec) DeepCopyInto(out *TinkerbellMachineSpec) {
 	*out = *in
+	out.Image = in.Image
+	if in.ActionEnvironment != nil {
+		in, out := &in.ActionEnvironment, &out.ActionEnvironment
+		*out = make(map[string]map[string]string, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				inVal := (*in)[key]
+				in, out := &inVal, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.WorkflowTimeouts != nil {
+		in, out := &in.WorkflowTimeouts, &out.WorkflowTimeouts
+		*out = new(WorkflowTimeouts)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WorkflowStages != nil {
+		in, out := &in.WorkflowStages, &out.WorkflowStages
+		*out = make([]WorkflowStage, len(*in))
+		copy(*out, *in)
+	}
+	out.WorkerDevice = in.WorkerDevice
 	if in.HardwareAffinity != nil {
 		in, out := &in.HardwareAffinity, &out.HardwareAffinity
 		*out = new(HardwareAffinity)
 		(*in).DeepCopyInto(*out)
 	}
-	out.BootOptions = in.BootOptions
+	in.BootOptions.DeepCopyInto(&out.BootOptions)
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = new(ProvisioningEndpoints)
+		**out = **in
+	}
+	if in.StaticNetwork != nil {
+		in, out := &in.StaticNetwork, &out.StaticNetwork
+		*out = new(StaticNetwork)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Bond != nil {
+		in, out := &in.Bond, &out.Bond
+		*out = new(Bond)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]File, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PostReleaseHook != nil {
+		in, out := &in.PostReleaseHook, &out.PostReleaseHook
+		*out = new(PostReleaseHook)
+		**out = **in
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(Storage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConsoleCapture != nil {
+		in, out := &in.ConsoleCapture, &out.ConsoleCapture
+		*out = new(ConsoleCapture)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellMachineSpec.
@@ -261,7 +877,7 @@ func (in *TinkerbellMachineStatus) DeepCopyInto(out *TinkerbellMachineStatus) {
 	*out = *in
 	if in.Addresses != nil {
 		in, out := &in.Addresses, &out.Addresses
-		*out = make([]v1.NodeAddress, len(*in))
+		*out = make([]corev1.NodeAddress, len(*in))
 		copy(*out, *in)
 	}
 	if in.InstanceStatus != nil {
@@ -279,6 +895,35 @@ func (in *TinkerbellMachineStatus) DeepCopyInto(out *TinkerbellMachineStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Phases != nil {
+		in, out := &in.Phases, &out.Phases
+		*out = new(ProvisioningPhases)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Workflow != nil {
+		in, out := &in.Workflow, &out.Workflow
+		*out = new(WorkflowStatus)
+		**out = **in
+	}
+	if in.ConsoleLog != nil {
+		in, out := &in.ConsoleLog, &out.ConsoleLog
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.BMCJobRetries != nil {
+		in, out := &in.BMCJobRetries, &out.BMCJobRetries
+		*out = make([]BMCJobRetry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellMachineStatus.
@@ -297,6 +942,7 @@ func (in *TinkerbellMachineTemplate) DeepCopyInto(out *TinkerbellMachineTemplate
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellMachineTemplate.
@@ -381,6 +1027,28 @@ func (in *TinkerbellMachineTemplateSpec) DeepCopy() *TinkerbellMachineTemplateSp
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TinkerbellMachineTemplateStatus) DeepCopyInto(out *TinkerbellMachineTemplateStatus) {
+	*out = *in
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellMachineTemplateStatus.
+func (in *TinkerbellMachineTemplateStatus) DeepCopy() *TinkerbellMachineTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TinkerbellMachineTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WeightedHardwareAffinityTerm) DeepCopyInto(out *WeightedHardwareAffinityTerm) {
 	*out = *in
@@ -396,3 +1064,70 @@ func (in *WeightedHardwareAffinityTerm) DeepCopy() *WeightedHardwareAffinityTerm
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkerDevice) DeepCopyInto(out *WorkerDevice) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerDevice.
+func (in *WorkerDevice) DeepCopy() *WorkerDevice {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkerDevice)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkflowStage) DeepCopyInto(out *WorkflowStage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowStage.
+func (in *WorkflowStage) DeepCopy() *WorkflowStage {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkflowStage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkflowStatus) DeepCopyInto(out *WorkflowStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowStatus.
+func (in *WorkflowStatus) DeepCopy() *WorkflowStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkflowStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkflowTimeouts) DeepCopyInto(out *WorkflowTimeouts) {
+	*out = *in
+	if in.Actions != nil {
+		in, out := &in.Actions, &out.Actions
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowTimeouts.
+func (in *WorkflowTimeouts) DeepCopy() *WorkflowTimeouts {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkflowTimeouts)
+	in.DeepCopyInto(out)
+	return out
+}
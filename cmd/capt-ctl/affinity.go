@@ -0,0 +1,150 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/hardwareexpr"
+)
+
+// ErrNoCandidateHardware is returned when no available Hardware satisfies a hardware affinity.
+var ErrNoCandidateHardware = errors.New("no available hardware satisfies the hardware affinity")
+
+func validateAffinityFlags(fs *pflag.FlagSet) func(context.Context, client.Client, io.Writer, []string) error {
+	namespace := fs.StringP("namespace", "n", "default", "Namespace of the TinkerbellMachine or TinkerbellMachineTemplate.")
+	machineName := fs.String("machine", "", "Name of the TinkerbellMachine to validate.")
+	templateName := fs.String("template", "", "Name of the TinkerbellMachineTemplate to validate.")
+
+	return func(ctx context.Context, c client.Client, out io.Writer, _ []string) error {
+		affinity, err := getHardwareAffinity(ctx, c, *namespace, *machineName, *templateName)
+		if err != nil {
+			return err
+		}
+
+		return validateAffinity(ctx, c, out, *namespace, affinity)
+	}
+}
+
+func getHardwareAffinity(ctx context.Context, c client.Client, namespace, machineName, templateName string) (*infrastructurev1.HardwareAffinity, error) { //nolint:lll
+	switch {
+	case (machineName == "") == (templateName == ""):
+		return nil, fmt.Errorf("%w: exactly one of --machine or --template is required", ErrUsage)
+	case machineName != "":
+		tm := &infrastructurev1.TinkerbellMachine{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: machineName}, tm); err != nil {
+			return nil, fmt.Errorf("getting TinkerbellMachine: %w", err)
+		}
+
+		return tm.Spec.HardwareAffinity, nil
+	default:
+		tmt := &infrastructurev1.TinkerbellMachineTemplate{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: templateName}, tmt); err != nil {
+			return nil, fmt.Errorf("getting TinkerbellMachineTemplate: %w", err)
+		}
+
+		return tmt.Spec.Template.Spec.HardwareAffinity, nil
+	}
+}
+
+// validateAffinity reports how much of the Hardware pool each required term of the affinity selects, and how much
+// available Hardware remains after applying the required expression, the way the TinkerbellMachine controller
+// selects Hardware from the namespace of the TinkerbellMachine.
+func validateAffinity(ctx context.Context, c client.Client, out io.Writer, namespace string, affinity *infrastructurev1.HardwareAffinity) error { //nolint:lll,cyclop
+	if affinity == nil {
+		affinity = &infrastructurev1.HardwareAffinity{}
+	}
+
+	required := affinity.Required
+	if len(required) == 0 {
+		required = []infrastructurev1.HardwareAffinityTerm{{}}
+	}
+
+	var filter *hardwareexpr.Program
+
+	if affinity.RequiredExpression != "" {
+		var err error
+
+		if filter, err = hardwareexpr.CompileFilter(affinity.RequiredExpression); err != nil {
+			return fmt.Errorf("invalid requiredExpression: %w", err)
+		}
+	}
+
+	if affinity.ScoreExpression != "" {
+		if _, err := hardwareexpr.CompileScore(affinity.ScoreExpression); err != nil {
+			return fmt.Errorf("invalid scoreExpression: %w", err)
+		}
+	}
+
+	candidates := map[client.ObjectKey]bool{}
+
+	for i := range required {
+		selector, err := metav1.LabelSelectorAsSelector(&required[i].LabelSelector)
+		if err != nil {
+			return fmt.Errorf("invalid label selector of required term %d: %w", i, err)
+		}
+
+		matched := &tinkv1.HardwareList{}
+		if err := c.List(ctx, matched, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return fmt.Errorf("listing Hardware: %w", err)
+		}
+
+		available := 0
+
+		for j := range matched.Items {
+			hw := &matched.Items[j]
+			if hardwareState(hw) != hardwareStateAvailable {
+				continue
+			}
+
+			available++
+
+			if filter != nil {
+				ok, err := filter.Matches(hw)
+				if err != nil {
+					return fmt.Errorf("evaluating requiredExpression on Hardware %s/%s: %w", hw.Namespace, hw.Name, err)
+				}
+
+				if !ok {
+					continue
+				}
+			}
+
+			candidates[client.ObjectKeyFromObject(hw)] = true
+		}
+
+		fmt.Fprintf(out, "Required term %d (%s): %d Hardware matched, %d available\n",
+			i, valueOr(selector.String(), "all Hardware"), len(matched.Items), available)
+	}
+
+	fmt.Fprintf(out, "%d available Hardware satisfy the hardware affinity\n", len(candidates))
+
+	if len(candidates) == 0 {
+		return ErrNoCandidateHardware
+	}
+
+	return nil
+}
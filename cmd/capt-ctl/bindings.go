@@ -0,0 +1,195 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/pflag"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/binding"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func backupBindingsFlags(fs *pflag.FlagSet) func(context.Context, client.Client, io.Writer, []string) error {
+	namespace := fs.StringP("namespace", "n", "default", "Namespace of the Hardware.")
+
+	return func(ctx context.Context, c client.Client, out io.Writer, _ []string) error {
+		return backupBindings(ctx, c, out, *namespace)
+	}
+}
+
+// backupBindings prints the bindings of the Hardware of the namespace in the format restore-bindings reads.
+func backupBindings(ctx context.Context, c client.Client, out io.Writer, namespace string) error {
+	hardware := &tinkv1.HardwareList{}
+	if err := c.List(ctx, hardware, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("listing Hardware: %w", err)
+	}
+
+	data, err := binding.Marshal(binding.ForHardware(hardware.Items))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, data)
+
+	return nil
+}
+
+func restoreBindingsFlags(fs *pflag.FlagSet) func(context.Context, client.Client, io.Writer, []string) error {
+	namespace := fs.StringP("namespace", "n", "default", "Namespace of the Hardware.")
+	file := fs.StringP("file", "f", "", "File written by backup-bindings to restore. Defaults to the backup ConfigMap.")
+	configMap := fs.String("configmap", binding.DefaultConfigMapName, "ConfigMap the controller backed bindings up to.")
+	yes := fs.Bool("yes", false, "Restore the bindings. Without it, only what would be done is printed.")
+
+	return func(ctx context.Context, c client.Client, out io.Writer, _ []string) error {
+		var (
+			data []byte
+			err  error
+		)
+
+		if *file != "" {
+			data, err = os.ReadFile(*file)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", *file, err)
+			}
+		} else {
+			cm := &corev1.ConfigMap{}
+			if err := c.Get(ctx, client.ObjectKey{Namespace: *namespace, Name: *configMap}, cm); err != nil {
+				return fmt.Errorf("getting ConfigMap %s/%s: %w", *namespace, *configMap, err)
+			}
+
+			data = []byte(cm.Data[binding.ConfigMapKey])
+		}
+
+		bindings, err := binding.Unmarshal(data)
+		if err != nil {
+			return err
+		}
+
+		return restoreBindings(ctx, c, out, *namespace, bindings, *yes)
+	}
+}
+
+// restoreBindings re-applies backed up bindings onto the Hardware of the namespace, typically after it was
+// recreated during a disaster recovery of the management cluster, so CAPT finds the Hardware its machines run on
+// instead of provisioning other Hardware. Bindings of TinkerbellMachines which are gone, or now run on other
+// Hardware, are skipped, as is Hardware which does not exist or is bound to another machine.
+func restoreBindings(
+	ctx context.Context,
+	c client.Client,
+	out io.Writer,
+	namespace string,
+	bindings []binding.Binding,
+	confirmed bool,
+) error {
+	for _, b := range bindings {
+		key := client.ObjectKey{Namespace: namespace, Name: b.Hardware}
+
+		skip, err := skipBinding(ctx, c, b)
+		if err != nil {
+			return err
+		}
+
+		if skip != "" {
+			fmt.Fprintf(out, "Skipping Hardware %s: %s\n", key, skip)
+
+			continue
+		}
+
+		hw := &tinkv1.Hardware{}
+
+		err = c.Get(ctx, key, hw)
+
+		switch {
+		case apierrors.IsNotFound(err):
+			fmt.Fprintf(out, "Skipping Hardware %s: not found\n", key)
+
+			continue
+		case err != nil:
+			return fmt.Errorf("getting Hardware %s: %w", key, err)
+		}
+
+		patchHelper, err := patch.NewHelper(hw, c)
+		if err != nil {
+			return fmt.Errorf("initializing patch helper for Hardware %s: %w", key, err)
+		}
+
+		changed, err := binding.Apply(hw, b)
+		if err != nil {
+			fmt.Fprintf(out, "Skipping Hardware %s: %v\n", key, err)
+
+			continue
+		}
+
+		if !changed {
+			fmt.Fprintf(out, "Hardware %s is already bound\n", key)
+
+			continue
+		}
+
+		owner := b.Labels[machine.HardwareOwnerNamespaceLabel] + "/" + b.Labels[machine.HardwareOwnerNameLabel]
+
+		if !confirmed {
+			fmt.Fprintf(out, "Would bind Hardware %s to TinkerbellMachine %s. Re-run with --yes.\n", key, owner)
+
+			continue
+		}
+
+		if err := patchHelper.Patch(ctx, hw); err != nil {
+			return fmt.Errorf("patching Hardware %s: %w", key, err)
+		}
+
+		fmt.Fprintf(out, "Bound Hardware %s to TinkerbellMachine %s\n", key, owner)
+	}
+
+	return nil
+}
+
+// skipBinding returns why the binding must not be restored, or an empty string if it can be.
+func skipBinding(ctx context.Context, c client.Client, b binding.Binding) (string, error) {
+	owner := client.ObjectKey{
+		Namespace: b.Labels[machine.HardwareOwnerNamespaceLabel],
+		Name:      b.Labels[machine.HardwareOwnerNameLabel],
+	}
+
+	tm := &infrastructurev1.TinkerbellMachine{}
+
+	err := c.Get(ctx, owner, tm)
+
+	switch {
+	case apierrors.IsNotFound(err):
+		return fmt.Sprintf("TinkerbellMachine %s not found", owner), nil
+	case err != nil:
+		return "", fmt.Errorf("getting TinkerbellMachine %s: %w", owner, err)
+	}
+
+	if tm.Spec.ProviderID != "" && tm.Spec.ProviderID != b.ProviderID {
+		return fmt.Sprintf("TinkerbellMachine %s runs on %s", owner, tm.Spec.ProviderID), nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,201 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+// ErrHardwareInUse is returned when releasing Hardware whose owning TinkerbellMachine still exists.
+var ErrHardwareInUse = errors.New("hardware is in use")
+
+const (
+	hardwareStateAvailable   = "available"
+	hardwareStateClaimed     = "claimed"
+	hardwareStateProvisioned = "provisioned"
+	hardwareStateQuarantined = "quarantined"
+)
+
+// hardwareState returns whether the Hardware is available, quarantined after repeated provisioning failures,
+// claimed by a TinkerbellMachine or provisioned.
+func hardwareState(hw *tinkv1.Hardware) string {
+	if _, ok := hw.GetLabels()[machine.HardwareOwnerNameLabel]; !ok {
+		if _, quarantined := hw.GetLabels()[machine.HardwareQuarantinedLabel]; quarantined {
+			return hardwareStateQuarantined
+		}
+
+		return hardwareStateAvailable
+	}
+
+	if hw.GetAnnotations()[machine.HardwareProvisionedAnnotation] == "true" {
+		return hardwareStateProvisioned
+	}
+
+	return hardwareStateClaimed
+}
+
+func valueOr(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+
+	return v
+}
+
+func listHardwareFlags(fs *pflag.FlagSet) func(context.Context, client.Client, io.Writer, []string) error {
+	namespace := fs.StringP("namespace", "n", "", "Namespace of the Hardware. Defaults to all namespaces.")
+	available := fs.Bool("available", false, "Only list Hardware which can be claimed by a TinkerbellMachine.")
+
+	return func(ctx context.Context, c client.Client, out io.Writer, _ []string) error {
+		return listHardware(ctx, c, out, *namespace, *available)
+	}
+}
+
+func listHardware(ctx context.Context, c client.Client, out io.Writer, namespace string, availableOnly bool) error {
+	hardware := &tinkv1.HardwareList{}
+	if err := c.List(ctx, hardware, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("listing Hardware: %w", err)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0) //nolint:gomnd
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tSTATE\tOWNER\tCLUSTER")
+
+	for i := range hardware.Items {
+		hw := &hardware.Items[i]
+
+		state := hardwareState(hw)
+		if availableOnly && state != hardwareStateAvailable {
+			continue
+		}
+
+		labels := hw.GetLabels()
+		owner := "-"
+
+		if name, ok := labels[machine.HardwareOwnerNameLabel]; ok {
+			owner = labels[machine.HardwareOwnerNamespaceLabel] + "/" + name
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", hw.Namespace, hw.Name, state, owner,
+			valueOr(labels[machine.HardwareClusterNameLabel], "-"))
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+
+	return nil
+}
+
+func releaseHardwareFlags(fs *pflag.FlagSet) func(context.Context, client.Client, io.Writer, []string) error {
+	namespace := fs.StringP("namespace", "n", "default", "Namespace of the Hardware.")
+	force := fs.Bool("force", false,
+		"Release the Hardware even though its TinkerbellMachine still exists. The machine will claim other Hardware. "+
+			"Power the Hardware off first, it keeps running the node of that machine otherwise.")
+
+	return func(ctx context.Context, c client.Client, out io.Writer, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("%w: expected exactly one Hardware name", ErrUsage)
+		}
+
+		return releaseHardware(ctx, c, out, client.ObjectKey{Namespace: *namespace, Name: args[0]}, *force)
+	}
+}
+
+// releaseHardware clears the ownership CAPT recorded on the Hardware and wipes its user data, so the Hardware can be
+// claimed again. Hardware whose TinkerbellMachine still exists is only released when forced, Hardware a Workflow
+// still runs against never is.
+func releaseHardware(ctx context.Context, c client.Client, out io.Writer, key client.ObjectKey, force bool) error {
+	hw := &tinkv1.Hardware{}
+	if err := c.Get(ctx, key, hw); err != nil {
+		return fmt.Errorf("getting Hardware %s: %w", key, err)
+	}
+
+	labels := hw.GetLabels()
+
+	ownerName, owned := labels[machine.HardwareOwnerNameLabel]
+	if !owned {
+		fmt.Fprintf(out, "Hardware %s is already available\n", key)
+
+		return nil
+	}
+
+	owner := client.ObjectKey{Namespace: labels[machine.HardwareOwnerNamespaceLabel], Name: ownerName}
+
+	err := c.Get(ctx, owner, &infrastructurev1.TinkerbellMachine{})
+
+	switch {
+	case err == nil && !force:
+		return fmt.Errorf("%w: TinkerbellMachine %s still exists, delete it or use --force", ErrHardwareInUse, owner)
+	case err != nil && !apierrors.IsNotFound(err):
+		return fmt.Errorf("getting TinkerbellMachine %s: %w", owner, err)
+	}
+
+	workflow, err := hardwareWorkflow(ctx, c, hw)
+	if err != nil {
+		return err
+	}
+
+	if workflow != "" {
+		return fmt.Errorf("%w: Workflow %s still targets the Hardware, delete it and power the Hardware off first",
+			ErrHardwareInUse, workflow)
+	}
+
+	patchHelper, err := patch.NewHelper(hw, c)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for Hardware %s: %w", key, err)
+	}
+
+	machine.ClearHardwareOwnership(hw)
+	hw.Spec.UserData = nil
+
+	if err := patchHelper.Patch(ctx, hw); err != nil {
+		return fmt.Errorf("patching Hardware %s: %w", key, err)
+	}
+
+	fmt.Fprintf(out, "Released Hardware %s from TinkerbellMachine %s\n", key, owner)
+
+	return nil
+}
+
+// hardwareWorkflow returns the name of a Workflow targeting the Hardware, empty if there is none.
+func hardwareWorkflow(ctx context.Context, c client.Client, hw *tinkv1.Hardware) (string, error) {
+	workflows := &tinkv1.WorkflowList{}
+	if err := c.List(ctx, workflows, client.InNamespace(hw.Namespace)); err != nil {
+		return "", fmt.Errorf("listing Workflows: %w", err)
+	}
+
+	for i := range workflows.Items {
+		if workflows.Items[i].Spec.HardwareRef == hw.Name {
+			return workflows.Items[i].Name, nil
+		}
+	}
+
+	return "", nil
+}
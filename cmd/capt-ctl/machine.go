@@ -0,0 +1,93 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+var (
+	// ErrNoOwnerMachine is returned when the TinkerbellMachine to reprovision has no owner Machine.
+	ErrNoOwnerMachine = errors.New("tinkerbellMachine has no owner Machine")
+
+	// ErrMachineNotReplaceable is returned when the Machine to reprovision is not managed by a controller which
+	// would create a replacement.
+	ErrMachineNotReplaceable = errors.New("machine has no controller to replace it")
+)
+
+func reprovisionMachineFlags(fs *pflag.FlagSet) func(context.Context, client.Client, io.Writer, []string) error {
+	namespace := fs.StringP("namespace", "n", "default", "Namespace of the TinkerbellMachine.")
+	yes := fs.Bool("yes", false, "Delete the Machine. Without it, only what would be done is printed.")
+
+	return func(ctx context.Context, c client.Client, out io.Writer, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("%w: expected exactly one TinkerbellMachine name", ErrUsage)
+		}
+
+		return reprovisionMachine(ctx, c, out, client.ObjectKey{Namespace: *namespace, Name: args[0]}, *yes)
+	}
+}
+
+// reprovisionMachine deletes the Machine owning the TinkerbellMachine. CAPT deprovisions and releases the Hardware,
+// and the MachineSet or control plane owning the Machine creates a replacement which is provisioned from scratch.
+// Machines without such a controller are refused, as they would not come back.
+func reprovisionMachine(ctx context.Context, c client.Client, out io.Writer, key client.ObjectKey, confirmed bool) error {
+	tm := &infrastructurev1.TinkerbellMachine{}
+	if err := c.Get(ctx, key, tm); err != nil {
+		return fmt.Errorf("getting TinkerbellMachine %s: %w", key, err)
+	}
+
+	m, err := util.GetOwnerMachine(ctx, c, tm.ObjectMeta)
+	if err != nil {
+		return fmt.Errorf("getting owner Machine: %w", err)
+	}
+
+	if m == nil {
+		return fmt.Errorf("%w: %s", ErrNoOwnerMachine, key)
+	}
+
+	controllerRef := metav1.GetControllerOf(m)
+	if controllerRef == nil {
+		return fmt.Errorf("%w: Machine %s/%s", ErrMachineNotReplaceable, m.Namespace, m.Name)
+	}
+
+	if !confirmed {
+		fmt.Fprintf(out, "Would delete Machine %s/%s (Hardware %s), %s %s would create a replacement. Re-run with --yes.\n",
+			m.Namespace, m.Name, tm.Spec.HardwareName, controllerRef.Kind, controllerRef.Name)
+
+		return nil
+	}
+
+	if err := c.Delete(ctx, m); err != nil {
+		return fmt.Errorf("deleting Machine %s/%s: %w", m.Namespace, m.Name, err)
+	}
+
+	fmt.Fprintf(out, "Deleted Machine %s/%s, %s %s will create a replacement\n",
+		m.Namespace, m.Name, controllerRef.Kind, controllerRef.Name)
+
+	return nil
+}
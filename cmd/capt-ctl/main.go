@@ -0,0 +1,184 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command capt-ctl performs Hardware pool operations against a management cluster running CAPT, replacing the
+// manual label and annotation edits these operations otherwise require.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/captclient"
+)
+
+// ErrUsage is returned when capt-ctl is invoked with invalid arguments.
+var ErrUsage = errors.New("invalid usage")
+
+// command is a capt-ctl subcommand.
+type command struct {
+	usage string
+	help  string
+	// offline commands do not need a management cluster and are run with a nil client.
+	offline bool
+	// flags registers the flags of the command on the given FlagSet. It returns the function running the command.
+	flags func(fs *pflag.FlagSet) func(ctx context.Context, c client.Client, out io.Writer, args []string) error
+}
+
+//nolint:gochecknoglobals
+var commands = map[string]command{
+	"backup-bindings": {
+		usage: "backup-bindings -n NAMESPACE",
+		help:  "Print which TinkerbellMachine each Hardware is bound to, in the format restore-bindings reads.",
+		flags: backupBindingsFlags,
+	},
+	"list-hardware": {
+		usage: "list-hardware [-n NAMESPACE] [--available]",
+		help:  "List Hardware with its availability, owning TinkerbellMachine and cluster.",
+		flags: listHardwareFlags,
+	},
+	"release-hardware": {
+		usage: "release-hardware -n NAMESPACE NAME [--force]",
+		help:  "Release Hardware whose TinkerbellMachine is gone, making it available again.",
+		flags: releaseHardwareFlags,
+	},
+	"restore-bindings": {
+		usage: "restore-bindings -n NAMESPACE [--file FILE | --configmap NAME] [--yes]",
+		help:  "Re-apply backed up Hardware bindings onto recreated Hardware after a disaster recovery.",
+		flags: restoreBindingsFlags,
+	},
+	"reprovision-machine": {
+		usage: "reprovision-machine -n NAMESPACE NAME [--yes]",
+		help:  "Replace a TinkerbellMachine by deleting its Machine, letting its MachineSet or control plane recreate it.",
+		flags: reprovisionMachineFlags,
+	},
+	"simulate": {
+		usage:   "simulate -f FILE [-f FILE]... [--rounds N]",
+		help:    "Print the Templates, Workflows and BMC Jobs CAPT would create for manifests, without a cluster.",
+		offline: true,
+		flags:   simulateFlags,
+	},
+	"validate-affinity": {
+		usage: "validate-affinity -n NAMESPACE (--machine NAME | --template NAME)",
+		help:  "Check the hardware affinity of a TinkerbellMachine or TinkerbellMachineTemplate against the Hardware pool.",
+		flags: validateAffinityFlags,
+	},
+}
+
+// app runs capt-ctl commands. newClient is only called once the arguments were parsed successfully.
+type app struct {
+	out       io.Writer
+	newClient func() (client.Client, error)
+}
+
+func newScheme() (*runtime.Scheme, error) {
+	return captclient.NewScheme() //nolint:wrapcheck // The error describes building the scheme.
+}
+
+func newClient() (client.Client, error) {
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig: %w", err)
+	}
+
+	return captclient.New(cfg) //nolint:wrapcheck // The error describes creating the client.
+}
+
+func (a *app) usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintln(a.out, "Usage: capt-ctl COMMAND [FLAGS]")
+	fmt.Fprintln(a.out)
+	fmt.Fprintln(a.out, "Commands:")
+
+	for _, name := range names {
+		fmt.Fprintf(a.out, "  %-22s %s\n", name, commands[name].help)
+	}
+}
+
+func (a *app) run(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		a.usage()
+
+		if len(args) == 0 {
+			return ErrUsage
+		}
+
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		a.usage()
+
+		return fmt.Errorf("%w: unknown command %q", ErrUsage, args[0])
+	}
+
+	fs := pflag.NewFlagSet(args[0], pflag.ContinueOnError)
+	fs.SetOutput(a.out)
+	fs.Usage = func() {
+		fmt.Fprintf(a.out, "Usage: capt-ctl %s\n\n%s\n\n", cmd.usage, cmd.help)
+		fs.PrintDefaults()
+	}
+	// The kubeconfig flag is registered by controller-runtime on the standard flag set.
+	fs.AddGoFlagSet(flag.CommandLine)
+
+	runCmd := cmd.flags(fs)
+
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+
+		return fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	if cmd.offline {
+		return runCmd(ctx, nil, a.out, fs.Args())
+	}
+
+	c, err := a.newClient()
+	if err != nil {
+		return err
+	}
+
+	return runCmd(ctx, c, a.out, fs.Args())
+}
+
+func main() {
+	a := &app{out: os.Stdout, newClient: newClient}
+
+	if err := a.run(ctrl.SetupSignalHandler(), os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+
+		os.Exit(1)
+	}
+}
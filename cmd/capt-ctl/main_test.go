@@ -0,0 +1,342 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main //nolint:testpackage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/binding"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func testApp(t *testing.T, objects ...client.Object) (*app, client.Client, *bytes.Buffer) {
+	t.Helper()
+	g := NewWithT(t)
+
+	scheme, err := newScheme()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	out := &bytes.Buffer{}
+
+	return &app{out: out, newClient: func() (client.Client, error) { return c, nil }}, c, out
+}
+
+func testHardware(name, owner string, labels map[string]string) *tinkv1.Hardware {
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{}},
+		Spec:       tinkv1.HardwareSpec{UserData: ptr.To("secret")},
+	}
+
+	for k, v := range labels {
+		hw.Labels[k] = v
+	}
+
+	if owner != "" {
+		hw.Labels[machine.HardwareOwnerNameLabel] = owner
+		hw.Labels[machine.HardwareOwnerNamespaceLabel] = "default"
+		hw.Labels[machine.HardwareClusterNameLabel] = "cluster"
+	}
+
+	return hw
+}
+
+func Test_run_usage(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	a, _, out := testApp(t)
+
+	g.Expect(a.run(context.Background(), nil)).To(MatchError(ErrUsage))
+	g.Expect(out.String()).To(ContainSubstring("release-hardware"))
+	g.Expect(a.run(context.Background(), []string{"unknown"})).To(MatchError(ErrUsage))
+	g.Expect(a.run(context.Background(), []string{"release-hardware"})).To(MatchError(ErrUsage))
+}
+
+func Test_listHardware(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	a, _, out := testApp(t, testHardware("free", "", nil), testHardware("used", "machine", nil))
+
+	g.Expect(a.run(context.Background(), []string{"list-hardware"})).To(Succeed())
+	g.Expect(out.String()).To(MatchRegexp(`free\s+available\s+-\s+-`))
+	g.Expect(out.String()).To(MatchRegexp(`used\s+claimed\s+default/machine\s+cluster`))
+
+	out.Reset()
+	g.Expect(a.run(context.Background(), []string{"list-hardware", "--available"})).To(Succeed())
+	g.Expect(out.String()).NotTo(ContainSubstring("used"))
+}
+
+func Test_releaseHardware(t *testing.T) {
+	t.Parallel()
+
+	t.Run("refuses_hardware_of_existing_machine", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		a, _, _ := testApp(t, testHardware("hw", "machine", nil),
+			&infrastructurev1.TinkerbellMachine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}})
+
+		g.Expect(a.run(context.Background(), []string{"release-hardware", "hw"})).To(MatchError(ErrHardwareInUse))
+	})
+
+	t.Run("refuses_hardware_targeted_by_workflow_even_when_forced", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		a, _, _ := testApp(t, testHardware("hw", "machine", nil),
+			&infrastructurev1.TinkerbellMachine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}},
+			&tinkv1.Workflow{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+				Spec:       tinkv1.WorkflowSpec{HardwareRef: "hw"},
+			})
+
+		g.Expect(a.run(context.Background(), []string{"release-hardware", "hw", "--force"})).
+			To(MatchError(ErrHardwareInUse))
+	})
+
+	t.Run("releases_hardware_of_deleted_machine", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		a, c, _ := testApp(t, testHardware("hw", "machine", nil))
+
+		g.Expect(a.run(context.Background(), []string{"release-hardware", "hw"})).To(Succeed())
+
+		hw := &tinkv1.Hardware{}
+		g.Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "hw"}, hw)).To(Succeed())
+		g.Expect(hardwareState(hw)).To(Equal(hardwareStateAvailable))
+		g.Expect(hw.Labels).NotTo(HaveKey(machine.HardwareClusterNameLabel))
+		g.Expect(hw.Spec.UserData).To(BeNil())
+	})
+}
+
+func Test_reprovisionMachine(t *testing.T) {
+	t.Parallel()
+
+	tinkerbellMachine := func() *infrastructurev1.TinkerbellMachine {
+		return &infrastructurev1.TinkerbellMachine{ObjectMeta: metav1.ObjectMeta{
+			Name:      "tm",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(), Kind: "Machine", Name: "m", UID: "m-uid",
+			}},
+		}}
+	}
+
+	t.Run("refuses_machines_without_controller", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		a, _, _ := testApp(t, tinkerbellMachine(),
+			&clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "m", Namespace: "default", UID: "m-uid"}})
+
+		g.Expect(a.run(context.Background(), []string{"reprovision-machine", "tm", "--yes"})).
+			To(MatchError(ErrMachineNotReplaceable))
+	})
+
+	t.Run("deletes_machine_when_confirmed", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		m := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{
+			Name: "m", Namespace: "default", UID: "m-uid",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(), Kind: "MachineSet", Name: "ms", UID: "ms-uid",
+				Controller: ptr.To(true),
+			}},
+		}}
+		a, c, out := testApp(t, tinkerbellMachine(), m)
+
+		g.Expect(a.run(context.Background(), []string{"reprovision-machine", "tm"})).To(Succeed())
+		g.Expect(out.String()).To(ContainSubstring("Would delete Machine default/m"))
+		g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(m), &clusterv1.Machine{})).To(Succeed())
+
+		g.Expect(a.run(context.Background(), []string{"reprovision-machine", "tm", "--yes"})).To(Succeed())
+
+		err := c.Get(context.Background(), client.ObjectKeyFromObject(m), &clusterv1.Machine{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+}
+
+func Test_restoreBindings(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	bindings := binding.ForHardware([]tinkv1.Hardware{*testHardware("hw", "machine", nil),
+		*testHardware("gone", "deleted-machine", nil)})
+
+	// The Hardware was recreated without its binding.
+	_, c, out := testApp(t, testHardware("hw", "", nil), testHardware("gone", "", nil),
+		&infrastructurev1.TinkerbellMachine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}})
+
+	g.Expect(restoreBindings(context.Background(), c, out, "default", bindings, false)).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring("Would bind Hardware default/hw to TinkerbellMachine default/machine"))
+	g.Expect(out.String()).To(ContainSubstring("Skipping Hardware default/gone"))
+
+	hw := &tinkv1.Hardware{}
+	g.Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "hw"}, hw)).To(Succeed())
+	g.Expect(hardwareState(hw)).To(Equal(hardwareStateAvailable))
+
+	g.Expect(restoreBindings(context.Background(), c, out, "default", bindings, true)).To(Succeed())
+
+	g.Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "hw"}, hw)).To(Succeed())
+	g.Expect(hw.Labels).To(HaveKeyWithValue(machine.HardwareOwnerNameLabel, "machine"))
+
+	g.Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "gone"}, hw)).To(Succeed())
+	g.Expect(hw.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel))
+}
+
+func Test_validateAffinity(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	template := &infrastructurev1.TinkerbellMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "template", Namespace: "default"},
+		Spec: infrastructurev1.TinkerbellMachineTemplateSpec{
+			Template: infrastructurev1.TinkerbellMachineTemplateResource{
+				Spec: infrastructurev1.TinkerbellMachineSpec{
+					HardwareAffinity: &infrastructurev1.HardwareAffinity{
+						Required: []infrastructurev1.HardwareAffinityTerm{{
+							LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"type": "worker"}},
+						}},
+						RequiredExpression: `hardware.metadata.name != "excluded"`,
+					},
+				},
+			},
+		},
+	}
+
+	worker := map[string]string{"type": "worker"}
+	a, _, out := testApp(t, template,
+		testHardware("free", "", worker),
+		testHardware("excluded", "", worker),
+		testHardware("used", "machine", worker),
+		testHardware("other", "", nil),
+	)
+
+	g.Expect(a.run(context.Background(), []string{"validate-affinity", "--template", "template"})).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring("Required term 0 (type=worker): 3 Hardware matched, 2 available"))
+	g.Expect(out.String()).To(ContainSubstring("1 available Hardware satisfy the hardware affinity"))
+
+	g.Expect(a.run(context.Background(), []string{"validate-affinity"})).To(MatchError(ErrUsage))
+}
+
+const simulatedManifests = `apiVersion: cluster.x-k8s.io/v1beta1
+kind: Cluster
+metadata:
+  name: demo
+spec:
+  controlPlaneEndpoint:
+    host: 10.0.0.100
+    port: 6443
+  infrastructureRef:
+    apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+    kind: TinkerbellCluster
+    name: demo
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+kind: TinkerbellCluster
+metadata:
+  name: demo
+spec:
+  imageLookupBaseRegistry: registry.example.com
+---
+apiVersion: cluster.x-k8s.io/v1beta1
+kind: Machine
+metadata:
+  name: demo-cp-0
+spec:
+  clusterName: demo
+  version: v1.30.0
+  bootstrap: {}
+  infrastructureRef:
+    apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+    kind: TinkerbellMachine
+    name: demo-cp-0
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+kind: TinkerbellMachine
+metadata:
+  name: demo-cp-0
+spec: {}
+---
+apiVersion: tinkerbell.org/v1alpha1
+kind: Hardware
+metadata:
+  name: node-1
+spec:
+  disks:
+  - device: /dev/sda
+  interfaces:
+  - dhcp:
+      mac: "00:00:00:00:00:01"
+      ip:
+        address: 10.0.0.10
+    netboot:
+      allowPXE: true
+  metadata:
+    instance:
+      id: "00:00:00:00:00:01"
+`
+
+func Test_simulate(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	file := filepath.Join(t.TempDir(), "manifests.yaml")
+	g.Expect(os.WriteFile(file, []byte(simulatedManifests), 0o600)).To(Succeed())
+
+	a, _, out := testApp(t)
+	a.newClient = func() (client.Client, error) {
+		t.Fatal("simulate must not connect to a cluster")
+
+		return nil, nil
+	}
+
+	g.Expect(a.run(context.Background(), []string{"simulate", "-f", file})).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring("kind: Template"))
+	g.Expect(out.String()).To(ContainSubstring("kind: Workflow"))
+	g.Expect(out.String()).To(ContainSubstring("hardwareRef: node-1"))
+	g.Expect(out.String()).NotTo(ContainSubstring("kind: Hardware\n"))
+
+	// Invalid manifests are rejected like the webhooks would.
+	invalid := strings.Replace(simulatedManifests, "spec: {}", "spec:\n  metadataURL: ftp://example.com", 1)
+	g.Expect(os.WriteFile(file, []byte(invalid), 0o600)).To(Succeed())
+
+	out.Reset()
+	g.Expect(a.run(context.Background(), []string{"simulate", "-f", file})).To(MatchError(ErrSimulationFailed))
+	g.Expect(out.String()).To(ContainSubstring("metadataURL"))
+
+	g.Expect(a.run(context.Background(), []string{"simulate"})).To(MatchError(ErrUsage))
+}
@@ -0,0 +1,389 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+	"github.com/spf13/pflag"
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/cluster"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+const (
+	// defaultSimulationRounds is how often each TinkerbellCluster and TinkerbellMachine is reconciled by default,
+	// enough for a machine to add its finalizer, claim Hardware and create its Template and Workflow.
+	defaultSimulationRounds = 5
+
+	manifestBufferSize = 4096
+)
+
+// ErrSimulationFailed is returned when a reconciliation of the simulation failed in its final round.
+var ErrSimulationFailed = errors.New("simulation failed")
+
+func simulateFlags(fs *pflag.FlagSet) func(context.Context, client.Client, io.Writer, []string) error {
+	files := fs.StringArrayP("filename", "f", nil, "YAML manifests to load. May be repeated.")
+	rounds := fs.Int("rounds", defaultSimulationRounds,
+		"How often each TinkerbellCluster and TinkerbellMachine is reconciled.")
+
+	return func(ctx context.Context, _ client.Client, out io.Writer, _ []string) error {
+		if len(*files) == 0 {
+			return fmt.Errorf("%w: at least one manifest is required", ErrUsage)
+		}
+
+		if *rounds < 1 {
+			return fmt.Errorf("%w: rounds must be at least 1", ErrUsage)
+		}
+
+		scheme, err := newScheme()
+		if err != nil {
+			return err
+		}
+
+		var objects []client.Object
+
+		for _, file := range *files {
+			loaded, err := loadManifests(scheme, file)
+			if err != nil {
+				return err
+			}
+
+			objects = append(objects, loaded...)
+		}
+
+		return simulate(ctx, scheme, out, objects, *rounds)
+	}
+}
+
+// loadManifests decodes the objects of a multi-document YAML file into the typed objects of the scheme.
+func loadManifests(scheme *runtime.Scheme, file string) ([]client.Object, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", file, err)
+	}
+	defer f.Close()
+
+	var objects []client.Object
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, manifestBufferSize)
+
+	for {
+		raw := &unstructured.Unstructured{}
+		if err := decoder.Decode(&raw.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+
+			return nil, fmt.Errorf("decoding %s: %w", file, err)
+		}
+
+		if len(raw.Object) == 0 {
+			continue
+		}
+
+		obj, err := scheme.New(raw.GroupVersionKind())
+		if err != nil {
+			return nil, fmt.Errorf("loading %s %s from %s: %w", raw.GetKind(), raw.GetName(), file, err)
+		}
+
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw.Object, obj); err != nil {
+			return nil, fmt.Errorf("loading %s %s from %s: %w", raw.GetKind(), raw.GetName(), file, err)
+		}
+
+		typed, ok := obj.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("loading %s %s from %s: %w", raw.GetKind(), raw.GetName(), file, ErrUsage)
+		}
+
+		if typed.GetNamespace() == "" {
+			typed.SetNamespace("default")
+		}
+
+		objects = append(objects, typed)
+	}
+}
+
+// simulate admits the objects like the CAPT webhooks would, reconciles their TinkerbellClusters and
+// TinkerbellMachines against an in-memory cluster holding only the objects, and prints the Templates, Workflows
+// and BMC Jobs which were created as YAML. Reconcile errors of the final round are printed as comments and fail
+// the simulation.
+func simulate(
+	ctx context.Context,
+	scheme *runtime.Scheme,
+	out io.Writer,
+	objects []client.Object,
+	rounds int,
+) error {
+	if failures := admitSimulatedObjects(objects); len(failures) > 0 {
+		for _, failure := range failures {
+			fmt.Fprintf(out, "# %s\n", failure)
+		}
+
+		return fmt.Errorf("%w: %d objects rejected", ErrSimulationFailed, len(failures))
+	}
+
+	objects = wireSimulatedObjects(objects)
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&infrastructurev1.TinkerbellMachine{}, &infrastructurev1.TinkerbellCluster{}).
+		Build()
+
+	ctx = ctrl.LoggerInto(ctx, logr.Discard())
+	clusterReconciler := &cluster.TinkerbellClusterReconciler{Client: c}
+	machineReconciler := &machine.TinkerbellMachineReconciler{Client: c}
+
+	var failures []string
+
+	for round := range rounds {
+		failures = nil
+
+		for _, obj := range objects {
+			var (
+				kind      string
+				reconcile func(context.Context, ctrl.Request) (ctrl.Result, error)
+			)
+
+			switch obj.(type) {
+			case *infrastructurev1.TinkerbellCluster:
+				kind, reconcile = "TinkerbellCluster", clusterReconciler.Reconcile
+			case *infrastructurev1.TinkerbellMachine:
+				kind, reconcile = "TinkerbellMachine", machineReconciler.Reconcile
+			default:
+				continue
+			}
+
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)}
+			if _, err := reconcile(ctx, req); err != nil && round == rounds-1 {
+				failures = append(failures, fmt.Sprintf("%s %s: %v", kind, req.NamespacedName, err))
+			}
+		}
+	}
+
+	for _, failure := range failures {
+		fmt.Fprintf(out, "# %s\n", failure)
+	}
+
+	if err := printCreatedObjects(ctx, c, out, objects); err != nil {
+		return err
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%w: %d reconciliations failed", ErrSimulationFailed, len(failures))
+	}
+
+	return nil
+}
+
+// admitSimulatedObjects runs the defaulting and validating webhooks of the objects, returning why objects the API
+// server would reject were rejected.
+func admitSimulatedObjects(objects []client.Object) []string {
+	var failures []string
+
+	for _, obj := range objects {
+		if defaulter, ok := obj.(interface{ Default() }); ok {
+			defaulter.Default()
+		}
+
+		if validator, ok := obj.(admission.Validator); ok {
+			if _, err := validator.ValidateCreate(); err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+	}
+
+	return failures
+}
+
+// wireSimulatedObjects does what the Cluster API controllers would do for the objects before CAPT reconciles
+// them: it sets UIDs, owner references and cluster name labels, and gives Machines without bootstrap data a
+// placeholder bootstrap data Secret. UIDs are derived from the objects, so the output of a simulation is stable.
+func wireSimulatedObjects(objects []client.Object) []client.Object {
+	for _, obj := range objects {
+		if obj.GetUID() == "" {
+			obj.SetUID(types.UID(uuid.NewSHA1(uuid.NameSpaceOID, []byte(objectID(obj))).String()))
+		}
+	}
+
+	var secrets []client.Object
+
+	for _, obj := range objects {
+		switch owner := obj.(type) {
+		case *clusterv1.Cluster:
+			if ref := owner.Spec.InfrastructureRef; ref != nil {
+				if infra := findObject[*infrastructurev1.TinkerbellCluster](objects, owner.Namespace, ref.Name); infra != nil {
+					addSimulatedOwner(infra, owner, clusterv1.GroupVersion.WithKind("Cluster"), owner.Name)
+				}
+			}
+		case *clusterv1.Machine:
+			setSimulatedLabel(owner, clusterv1.ClusterNameLabel, owner.Spec.ClusterName)
+
+			if owner.Spec.Bootstrap.DataSecretName == nil {
+				name := owner.Name + "-bootstrap"
+				owner.Spec.Bootstrap.DataSecretName = &name
+				secrets = append(secrets, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: owner.Namespace},
+					Data:       map[string][]byte{"value": []byte("#cloud-config\n")},
+				})
+			}
+
+			infraName := owner.Spec.InfrastructureRef.Name
+			if infra := findObject[*infrastructurev1.TinkerbellMachine](objects, owner.Namespace, infraName); infra != nil {
+				addSimulatedOwner(infra, owner, clusterv1.GroupVersion.WithKind("Machine"), owner.Spec.ClusterName)
+			}
+		}
+	}
+
+	return append(objects, secrets...)
+}
+
+// findObject returns the object of the given type, namespace and name, or nil.
+func findObject[T client.Object](objects []client.Object, namespace, name string) client.Object {
+	for _, obj := range objects {
+		if _, ok := obj.(T); ok && obj.GetNamespace() == namespace && obj.GetName() == name {
+			return obj
+		}
+	}
+
+	return nil
+}
+
+// addSimulatedOwner adds an owner reference to the owner, and the cluster name label, to the object.
+func addSimulatedOwner(obj, owner client.Object, gvk schema.GroupVersionKind, clusterName string) {
+	setSimulatedLabel(obj, clusterv1.ClusterNameLabel, clusterName)
+
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == gvk.Kind && ref.Name == owner.GetName() {
+			return
+		}
+	}
+
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+	obj.SetOwnerReferences(append(obj.GetOwnerReferences(), metav1.OwnerReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       owner.GetName(),
+		UID:        owner.GetUID(),
+	}))
+}
+
+func setSimulatedLabel(obj client.Object, key, value string) {
+	if value == "" {
+		return
+	}
+
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	if _, ok := labels[key]; !ok {
+		labels[key] = value
+	}
+
+	obj.SetLabels(labels)
+}
+
+// printCreatedObjects prints the Templates, Workflows and BMC Jobs which are not part of the given objects as YAML
+// documents, sorted by kind, namespace and name.
+func printCreatedObjects(ctx context.Context, c client.Client, out io.Writer, given []client.Object) error {
+	existing := map[string]bool{}
+
+	for _, obj := range given {
+		existing[objectID(obj)] = true
+	}
+
+	for _, list := range []client.ObjectList{&tinkv1.TemplateList{}, &tinkv1.WorkflowList{}, &rufiov1.JobList{}} {
+		if err := c.List(ctx, list); err != nil {
+			return fmt.Errorf("listing %T: %w", list, err)
+		}
+
+		var created []client.Object
+
+		if err := meta.EachListItem(list, func(item runtime.Object) error {
+			if obj, ok := item.(client.Object); ok && !existing[objectID(obj)] {
+				created = append(created, obj)
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("reading %T: %w", list, err)
+		}
+
+		sort.Slice(created, func(i, j int) bool {
+			return client.ObjectKeyFromObject(created[i]).String() < client.ObjectKeyFromObject(created[j]).String()
+		})
+
+		for _, obj := range created {
+			if err := printSimulatedObject(c.Scheme(), out, obj); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func printSimulatedObject(scheme *runtime.Scheme, out io.Writer, obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return fmt.Errorf("looking up kind of %T: %w", obj, err)
+	}
+
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetResourceVersion("")
+
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("marshaling %s %s: %w", gvk.Kind, client.ObjectKeyFromObject(obj), err)
+	}
+
+	fmt.Fprintf(out, "---\n%s", data)
+
+	return nil
+}
+
+func objectID(obj client.Object) string {
+	return fmt.Sprintf("%T/%s/%s", obj, obj.GetNamespace(), obj.GetName())
+}
@@ -0,0 +1,188 @@
+// Package binding backs up which TinkerbellMachine each Hardware is bound to and restores these bindings onto
+// recreated Hardware, so a disaster recovery of the management cluster does not make CAPT select other Hardware
+// and re-provision live nodes.
+package binding
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/providerid"
+)
+
+const (
+	// DefaultConfigMapName is the default name of the ConfigMap the bindings of the Hardware of a namespace are
+	// backed up to.
+	DefaultConfigMapName = "capt-hardware-bindings"
+
+	// ConfigMapKey is the key of the ConfigMap data holding the bindings.
+	ConfigMapKey = "bindings.json"
+)
+
+var (
+	// ErrBoundToOtherMachine is returned when restoring a binding onto Hardware bound to another machine.
+	ErrBoundToOtherMachine = errors.New("hardware is bound to another machine")
+
+	// ErrUserDataMismatch is returned when restoring a binding onto Hardware whose user-data differs from the one
+	// it was backed up with.
+	ErrUserDataMismatch = errors.New("hardware user-data differs from the backup")
+)
+
+// boundLabels are the labels recording the binding of Hardware to a TinkerbellMachine and its cluster.
+//
+//nolint:gochecknoglobals
+var boundLabels = []string{
+	machine.HardwareOwnerNameLabel,
+	machine.HardwareOwnerNamespaceLabel,
+	machine.HardwareClusterNameLabel,
+	machine.HardwareClusterNamespaceLabel,
+}
+
+// Binding is the binding of a Hardware to a TinkerbellMachine.
+type Binding struct {
+	// Hardware is the name of the Hardware.
+	Hardware string `json:"hardware"`
+
+	// Labels are the labels recording the owning TinkerbellMachine and cluster.
+	Labels map[string]string `json:"labels"`
+
+	// ProviderID is the provider ID of the machine running on the Hardware.
+	ProviderID string `json:"providerID"`
+
+	// Provisioned is true when the OS of the machine was installed on the Hardware.
+	Provisioned bool `json:"provisioned,omitempty"`
+
+	// UserDataSHA256 is the hex encoded SHA-256 hash of the user-data of the Hardware, if any.
+	UserDataSHA256 string `json:"userDataSHA256,omitempty"`
+
+	// NoFinalizer is true when the Hardware was bound without the finalizer of CAPT, as for clusters leaving the
+	// lifecycle of their Hardware to its owner.
+	NoFinalizer bool `json:"noFinalizer,omitempty"`
+}
+
+// ForHardware returns the bindings of the given Hardware, sorted by Hardware name. Hardware not bound to a
+// TinkerbellMachine is skipped.
+func ForHardware(hardware []tinkv1.Hardware) []Binding {
+	bindings := []Binding{}
+
+	for i := range hardware {
+		hw := &hardware[i]
+
+		if _, ok := hw.GetLabels()[machine.HardwareOwnerNameLabel]; !ok {
+			continue
+		}
+
+		b := Binding{
+			Hardware:    hw.Name,
+			Labels:      map[string]string{},
+			ProviderID:  providerid.New(hw.Namespace, hw.Name),
+			Provisioned: hw.GetAnnotations()[machine.HardwareProvisionedAnnotation] == "true",
+			NoFinalizer: !controllerutil.ContainsFinalizer(hw, infrastructurev1.MachineFinalizer),
+		}
+
+		for _, k := range boundLabels {
+			if v, ok := hw.GetLabels()[k]; ok {
+				b.Labels[k] = v
+			}
+		}
+
+		if hw.Spec.UserData != nil {
+			b.UserDataSHA256 = userDataHash(*hw.Spec.UserData)
+		}
+
+		bindings = append(bindings, b)
+	}
+
+	return sortBindings(bindings)
+}
+
+func sortBindings(bindings []Binding) []Binding {
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].Hardware < bindings[j].Hardware })
+
+	return bindings
+}
+
+// Apply restores the binding onto the Hardware. It refuses to bind Hardware bound to another machine or carrying
+// other user-data than it was backed up with, as it likely is not the Hardware the machine was provisioned on.
+// It returns false when the Hardware already had the binding.
+func Apply(hw *tinkv1.Hardware, b Binding) (bool, error) {
+	labels := hw.GetLabels()
+
+	owner, bound := labels[machine.HardwareOwnerNameLabel]
+	if bound && (owner != b.Labels[machine.HardwareOwnerNameLabel] ||
+		labels[machine.HardwareOwnerNamespaceLabel] != b.Labels[machine.HardwareOwnerNamespaceLabel]) {
+		return false, fmt.Errorf("%w: %s/%s", ErrBoundToOtherMachine, labels[machine.HardwareOwnerNamespaceLabel], owner)
+	}
+
+	if hw.Spec.UserData != nil && b.UserDataSHA256 != "" && userDataHash(*hw.Spec.UserData) != b.UserDataSHA256 {
+		return false, ErrUserDataMismatch
+	}
+
+	changed := false
+
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	for k, v := range b.Labels {
+		if labels[k] != v {
+			labels[k] = v
+			changed = true
+		}
+	}
+
+	hw.SetLabels(labels)
+
+	if b.Provisioned && hw.GetAnnotations()[machine.HardwareProvisionedAnnotation] != "true" {
+		annotations := hw.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+
+		annotations[machine.HardwareProvisionedAnnotation] = "true"
+		hw.SetAnnotations(annotations)
+
+		changed = true
+	}
+
+	if !b.NoFinalizer && controllerutil.AddFinalizer(hw, infrastructurev1.MachineFinalizer) {
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// Marshal serializes the bindings for the ConfigMap.
+func Marshal(bindings []Binding) (string, error) {
+	data, err := json.MarshalIndent(bindings, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("serializing bindings: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// Unmarshal parses bindings serialized with Marshal.
+func Unmarshal(data []byte) ([]Binding, error) {
+	var bindings []Binding
+	if err := json.Unmarshal(data, &bindings); err != nil {
+		return nil, fmt.Errorf("parsing bindings: %w", err)
+	}
+
+	return bindings, nil
+}
+
+func userDataHash(userData string) string {
+	sum := sha256.Sum256([]byte(userData))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,134 @@
+package binding_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/binding"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func boundHardware(name, owner string) *tinkv1.Hardware {
+	return &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels: map[string]string{
+				machine.HardwareOwnerNameLabel:      owner,
+				machine.HardwareOwnerNamespaceLabel: "default",
+				machine.HardwareClusterNameLabel:    "cluster",
+				"rack":                              "r1",
+			},
+			Annotations: map[string]string{machine.HardwareProvisionedAnnotation: "true"},
+		},
+		Spec: tinkv1.HardwareSpec{UserData: ptr.To("#cloud-config")},
+	}
+}
+
+func Test_ForHardware_and_Apply(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	available := &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "available", Namespace: "default"}}
+
+	withFinalizer := boundHardware("hw-a", "machine-a")
+	withFinalizer.Finalizers = []string{infrastructurev1.MachineFinalizer}
+
+	bindings := binding.ForHardware([]tinkv1.Hardware{*boundHardware("hw-b", "machine-b"), *available,
+		*withFinalizer})
+
+	g.Expect(bindings).To(HaveLen(2))
+	g.Expect(bindings[0].Hardware).To(Equal("hw-a"))
+	g.Expect(bindings[0].ProviderID).To(Equal("tinkerbell://default/hw-a"))
+	g.Expect(bindings[0].Provisioned).To(BeTrue())
+	g.Expect(bindings[0].Labels).NotTo(HaveKey("rack"), "Expected only binding labels to be backed up")
+
+	recreated := &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "hw-a", Namespace: "default"}}
+
+	changed, err := binding.Apply(recreated, bindings[0])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeTrue())
+	g.Expect(recreated.Labels).To(HaveKeyWithValue(machine.HardwareOwnerNameLabel, "machine-a"))
+	g.Expect(recreated.Annotations).To(HaveKeyWithValue(machine.HardwareProvisionedAnnotation, "true"))
+	g.Expect(recreated.Finalizers).To(ContainElement(infrastructurev1.MachineFinalizer))
+
+	changed, err = binding.Apply(recreated, bindings[0])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeFalse())
+
+	_, err = binding.Apply(boundHardware("hw-a", "machine-b"), bindings[0])
+	g.Expect(err).To(MatchError(binding.ErrBoundToOtherMachine))
+
+	otherUserData := &tinkv1.Hardware{Spec: tinkv1.HardwareSpec{UserData: ptr.To("other")}}
+	_, err = binding.Apply(otherUserData, bindings[0])
+	g.Expect(err).To(MatchError(binding.ErrUserDataMismatch))
+
+	// Hardware bound without finalizer is restored without finalizer.
+	g.Expect(bindings[1].NoFinalizer).To(BeTrue())
+
+	withoutFinalizer := &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "hw-b", Namespace: "default"}}
+	_, err = binding.Apply(withoutFinalizer, bindings[1])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(withoutFinalizer.Labels).To(HaveKeyWithValue(machine.HardwareOwnerNameLabel, "machine-b"))
+	g.Expect(withoutFinalizer.Finalizers).To(BeEmpty())
+}
+
+func Test_Reconcile_keeps_bindings_of_existing_machines(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrastructurev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		boundHardware("hw-a", "machine-a"),
+		boundHardware("hw-b", "machine-b"),
+		&infrastructurev1.TinkerbellMachine{ObjectMeta: metav1.ObjectMeta{Name: "machine-a", Namespace: "default"}},
+	).Build()
+
+	r := &binding.HardwareBindingReconciler{Client: c, APIReader: c, ConfigMapName: binding.DefaultConfigMapName}
+	req := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: binding.DefaultConfigMapName}}
+	ctx := context.Background()
+
+	backedUp := func() []binding.Binding {
+		cm := &corev1.ConfigMap{}
+		g.Expect(c.Get(ctx, req.NamespacedName, cm)).To(Succeed())
+
+		bindings, err := binding.Unmarshal([]byte(cm.Data[binding.ConfigMapKey]))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		return bindings
+	}
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(backedUp()).To(HaveLen(2))
+
+	// Recreate both Hardware without their bindings, as an inventory would after a disaster recovery.
+	for _, name := range []string{"hw-a", "hw-b"} {
+		g.Expect(c.Delete(ctx, &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})).
+			To(Succeed())
+		g.Expect(c.Create(ctx, &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})).
+			To(Succeed())
+	}
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	bindings := backedUp()
+	g.Expect(bindings).To(HaveLen(1), "Expected only the binding of the existing TinkerbellMachine to be kept")
+	g.Expect(bindings[0].Hardware).To(Equal("hw-a"))
+}
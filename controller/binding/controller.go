@@ -0,0 +1,172 @@
+package binding
+
+import (
+	"context"
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+// HardwareBindingReconciler backs up the bindings of the Hardware of each namespace to a ConfigMap in the same
+// namespace whenever Hardware changes.
+type HardwareBindingReconciler struct {
+	client.Client
+
+	// APIReader reads the ConfigMaps bindings are backed up to, so the controller does not need to cache all
+	// ConfigMaps of the management cluster.
+	APIReader client.Reader
+
+	// ConfigMapName is the name of the ConfigMaps bindings are backed up to. Defaults to DefaultConfigMapName.
+	ConfigMapName string
+}
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create;update
+
+// Reconcile writes the bindings of the Hardware of the namespace of the request to the ConfigMap. Backed up
+// bindings of Hardware which is no longer bound, or no longer exists, are kept as long as their TinkerbellMachine
+// exists, so they survive Hardware being recreated until they are restored. The ConfigMap is only created once
+// Hardware of the namespace is bound.
+func (r *HardwareBindingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	hardware := &tinkv1.HardwareList{}
+	if err := r.Client.List(ctx, hardware, client.InNamespace(req.Namespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("listing Hardware: %w", err)
+	}
+
+	bindings := ForHardware(hardware.Items)
+
+	cm := &corev1.ConfigMap{}
+
+	err := r.APIReader.Get(ctx, req.NamespacedName, cm)
+
+	switch {
+	case apierrors.IsNotFound(err):
+		if len(bindings) == 0 {
+			return ctrl.Result{}, nil
+		}
+
+		cm.Name = req.Name
+		cm.Namespace = req.Namespace
+	case err != nil:
+		return ctrl.Result{}, fmt.Errorf("getting ConfigMap: %w", err)
+	default:
+		retained, err := r.retainedBindings(ctx, cm, bindings)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		bindings = sortBindings(append(bindings, retained...))
+	}
+
+	data, err := Marshal(bindings)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if cm.ResourceVersion != "" && cm.Data[ConfigMapKey] == data {
+		return ctrl.Result{}, nil
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+
+	cm.Data[ConfigMapKey] = data
+
+	if cm.ResourceVersion == "" {
+		err = r.Client.Create(ctx, cm)
+	} else {
+		err = r.Client.Update(ctx, cm)
+	}
+
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("writing ConfigMap: %w", err)
+	}
+
+	ctrl.LoggerFrom(ctx).V(4).Info("Backed up Hardware bindings", "count", len(bindings)) //nolint:gomnd
+
+	return ctrl.Result{}, nil
+}
+
+// retainedBindings returns the bindings backed up in the ConfigMap for Hardware which is not bound anymore, but
+// whose TinkerbellMachine still exists.
+func (r *HardwareBindingReconciler) retainedBindings(
+	ctx context.Context,
+	cm *corev1.ConfigMap,
+	current []Binding,
+) ([]Binding, error) {
+	data, ok := cm.Data[ConfigMapKey]
+	if !ok {
+		return nil, nil
+	}
+
+	previous, err := Unmarshal([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bound := map[string]bool{}
+	for _, b := range current {
+		bound[b.Hardware] = true
+	}
+
+	var retained []Binding
+
+	for _, b := range previous {
+		if bound[b.Hardware] {
+			continue
+		}
+
+		owner := client.ObjectKey{
+			Namespace: b.Labels[machine.HardwareOwnerNamespaceLabel],
+			Name:      b.Labels[machine.HardwareOwnerNameLabel],
+		}
+
+		err := r.Client.Get(ctx, owner, &infrastructurev1.TinkerbellMachine{})
+
+		switch {
+		case apierrors.IsNotFound(err):
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("getting TinkerbellMachine %s: %w", owner, err)
+		}
+
+		retained = append(retained, b)
+	}
+
+	return retained, nil
+}
+
+// SetupWithManager configures reconciler with a given manager.
+func (r *HardwareBindingReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	if r.ConfigMapName == "" {
+		r.ConfigMapName = DefaultConfigMapName
+	}
+
+	if r.APIReader == nil {
+		r.APIReader = mgr.GetAPIReader()
+	}
+
+	// All Hardware of a namespace is backed up together, so requests are keyed by namespace.
+	toNamespace := func(_ context.Context, o client.Object) []ctrl.Request {
+		return []ctrl.Request{{NamespacedName: client.ObjectKey{Namespace: o.GetNamespace(), Name: r.ConfigMapName}}}
+	}
+
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("hardwarebinding").
+		WithOptions(options).
+		Watches(&tinkv1.Hardware{}, handler.EnqueueRequestsFromMapFunc(toNamespace)).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
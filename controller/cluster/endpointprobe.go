@@ -0,0 +1,126 @@
+package cluster
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+const (
+	// defaultEndpointProbeTimeout is how long a probe of the control plane endpoint may take when the cluster does
+	// not set a timeout.
+	defaultEndpointProbeTimeout = 5 * time.Second
+
+	// endpointUnreachableRequeueAfter is how long until the control plane endpoint is probed again after it failed
+	// its probe.
+	endpointUnreachableRequeueAfter = 30 * time.Second
+
+	// endpointReachableRequeueAfter is how long until the control plane endpoint is probed again after it passed
+	// its probe, keeping the ControlPlaneEndpointReachable condition current.
+	endpointReachableRequeueAfter = 5 * time.Minute
+)
+
+// ErrEndpointCertificateMismatch is returned by the TLS probe when the certificate presented by the control plane
+// endpoint is not valid for the expected server name.
+var ErrEndpointCertificateMismatch = errors.New("control plane endpoint certificate does not match server name")
+
+// EndpointProber probes the control plane endpoint of a cluster, returning why it is not reachable.
+type EndpointProber func(ctx context.Context, endpoint clusterv1.APIEndpoint,
+	probe infrastructurev1.ControlPlaneEndpointProbe) error
+
+// ProbeControlPlaneEndpoint probes the endpoint by opening a TCP connection to it, followed by a TLS handshake
+// checking the server name of its certificate with the TLS probe type.
+func ProbeControlPlaneEndpoint(
+	ctx context.Context,
+	endpoint clusterv1.APIEndpoint,
+	probe infrastructurev1.ControlPlaneEndpointProbe,
+) error {
+	timeout := defaultEndpointProbeTimeout
+	if probe.Timeout != nil {
+		timeout = probe.Timeout.Duration
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	address := net.JoinHostPort(endpoint.Host, strconv.Itoa(int(endpoint.Port)))
+
+	if probe.Type != infrastructurev1.ControlPlaneEndpointProbeTLS {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+		if err != nil {
+			return fmt.Errorf("connecting to %s: %w", address, err)
+		}
+
+		return conn.Close()
+	}
+
+	serverName := probe.ServerName
+	if serverName == "" {
+		serverName = endpoint.Host
+	}
+
+	dialer := &tls.Dialer{Config: &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		// The CA of the cluster may not exist yet, only the server name of the certificate is checked.
+		InsecureSkipVerify: true, //nolint:gosec
+	}}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("TLS handshake with %s: %w", address, err)
+	}
+
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates //nolint:forcetypeassert
+	if len(certs) == 0 {
+		return fmt.Errorf("%w: %s presented no certificate", ErrEndpointCertificateMismatch, address)
+	}
+
+	if err := certs[0].VerifyHostname(serverName); err != nil {
+		return fmt.Errorf("%w: %w", ErrEndpointCertificateMismatch, err)
+	}
+
+	return nil
+}
+
+// probeControlPlaneEndpoint probes the control plane endpoint when the cluster configures a probe, reporting the
+// result in the ControlPlaneEndpointReachable condition. It returns whether the endpoint is reachable, always true
+// without probe, and how long until it should be probed again.
+func (crc *clusterReconcileContext) probeControlPlaneEndpoint(endpoint clusterv1.APIEndpoint) (bool, time.Duration) {
+	probe := crc.tinkerbellCluster.Spec.ControlPlaneEndpointProbe
+	if probe == nil {
+		conditions.Delete(crc.tinkerbellCluster, infrastructurev1.ControlPlaneEndpointReachableCondition)
+
+		return true, 0
+	}
+
+	if err := crc.endpointProber(crc.ctx, endpoint, *probe); err != nil {
+		msg := err.Error()
+
+		previous := conditions.Get(crc.tinkerbellCluster, infrastructurev1.ControlPlaneEndpointReachableCondition)
+		if previous == nil || previous.Message != msg {
+			record.Warn(crc.tinkerbellCluster, infrastructurev1.ControlPlaneEndpointUnreachableReason, msg)
+		}
+
+		conditions.MarkFalse(crc.tinkerbellCluster, infrastructurev1.ControlPlaneEndpointReachableCondition,
+			infrastructurev1.ControlPlaneEndpointUnreachableReason, clusterv1.ConditionSeverityWarning, "%s", msg)
+
+		return false, endpointUnreachableRequeueAfter
+	}
+
+	conditions.MarkTrue(crc.tinkerbellCluster, infrastructurev1.ControlPlaneEndpointReachableCondition)
+
+	return true, endpointReachableRequeueAfter
+}
@@ -0,0 +1,128 @@
+package cluster_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/cluster"
+)
+
+func TestProbeControlPlaneEndpoint(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewUnstartedServer(nil)
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // The TCP probe closes connections without handshake.
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint := clusterv1.APIEndpoint{Host: host, Port: int32(portNumber)} //nolint:gosec
+
+	for name, tc := range map[string]struct {
+		probe    infrastructurev1.ControlPlaneEndpointProbe
+		endpoint clusterv1.APIEndpoint
+		expected error
+	}{
+		"tcp": {probe: infrastructurev1.ControlPlaneEndpointProbe{Type: infrastructurev1.ControlPlaneEndpointProbeTCP}},
+		"tls_default_server_name": {
+			probe: infrastructurev1.ControlPlaneEndpointProbe{Type: infrastructurev1.ControlPlaneEndpointProbeTLS},
+		},
+		"tls_server_name": {
+			probe: infrastructurev1.ControlPlaneEndpointProbe{
+				Type: infrastructurev1.ControlPlaneEndpointProbeTLS, ServerName: "example.com",
+			},
+		},
+		"tls_wrong_server_name": {
+			probe: infrastructurev1.ControlPlaneEndpointProbe{
+				Type: infrastructurev1.ControlPlaneEndpointProbeTLS, ServerName: "api.example.org",
+			},
+			expected: cluster.ErrEndpointCertificateMismatch,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			err := cluster.ProbeControlPlaneEndpoint(context.Background(), endpoint, tc.probe)
+			if tc.expected != nil {
+				g.Expect(err).To(MatchError(tc.expected))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
+func Test_Cluster_reconciliation_probes_control_plane_endpoint(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tinkCluster := unreadyTinkerbellCluster(clusterName, clusterNamespace)
+	tinkCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: "192.168.1.10", Port: 6443}
+	tinkCluster.Spec.ControlPlaneEndpointProbe = &infrastructurev1.ControlPlaneEndpointProbe{
+		Type: infrastructurev1.ControlPlaneEndpointProbeTCP,
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validCluster(clusterName, clusterNamespace),
+		tinkCluster,
+	})
+
+	var probeErr error
+
+	reconciler := &cluster.TinkerbellClusterReconciler{
+		Client: client,
+		EndpointProber: func(context.Context, clusterv1.APIEndpoint, infrastructurev1.ControlPlaneEndpointProbe) error {
+			return probeErr
+		},
+	}
+
+	key := types.NamespacedName{Name: clusterName, Namespace: clusterNamespace}
+	updated := &infrastructurev1.TinkerbellCluster{}
+
+	probeErr = errors.New("connection refused")
+
+	result, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).NotTo(BeZero(), "Expected the endpoint to be probed again")
+
+	g.Expect(client.Get(context.Background(), key, updated)).To(Succeed())
+	g.Expect(updated.Status.Ready).To(BeFalse(), "Expected the cluster to wait for the endpoint")
+	g.Expect(conditions.IsFalse(updated, infrastructurev1.ControlPlaneEndpointReachableCondition)).To(BeTrue())
+	g.Expect(conditions.GetMessage(updated, infrastructurev1.ControlPlaneEndpointReachableCondition)).
+		To(Equal("connection refused"))
+
+	probeErr = nil
+
+	_, err = reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(context.Background(), key, updated)).To(Succeed())
+	g.Expect(updated.Status.Ready).To(BeTrue())
+	g.Expect(conditions.IsTrue(updated, infrastructurev1.ControlPlaneEndpointReachableCondition)).To(BeTrue())
+}
@@ -0,0 +1,162 @@
+package cluster
+
+import (
+	"fmt"
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/tracing"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+// machinesDeletionRequeueAfter is how long to wait before checking again whether all TinkerbellMachines of a
+// deleted cluster are gone.
+const machinesDeletionRequeueAfter = 10 * time.Second
+
+// clusterName returns the name of the CAPI Cluster the TinkerbellCluster belongs to.
+func (crc *clusterReconcileContext) clusterName() string {
+	if crc.cluster != nil {
+		return crc.cluster.Name
+	}
+
+	if name, ok := crc.tinkerbellCluster.GetLabels()[clusterv1.ClusterNameLabel]; ok {
+		return name
+	}
+
+	return crc.tinkerbellCluster.Name
+}
+
+// liveMachines returns the number of TinkerbellMachines which still belong to the cluster.
+func (crc *clusterReconcileContext) liveMachines() (int, error) {
+	machines := &infrastructurev1.TinkerbellMachineList{}
+
+	if err := crc.client.List(crc.ctx, machines,
+		client.InNamespace(crc.tinkerbellCluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: crc.clusterName()},
+	); err != nil {
+		return 0, fmt.Errorf("listing TinkerbellMachines: %w", err)
+	}
+
+	return len(machines.Items), nil
+}
+
+// labelClaimedHardware adds the cluster labels to Hardware claimed by the TinkerbellMachines of the cluster before
+// CAPT recorded them, so releaseClusterHardware finds it once the machines are gone.
+func (crc *clusterReconcileContext) labelClaimedHardware() error {
+	machines := &infrastructurev1.TinkerbellMachineList{}
+
+	if err := crc.client.List(crc.ctx, machines,
+		client.InNamespace(crc.tinkerbellCluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: crc.clusterName()},
+	); err != nil {
+		return fmt.Errorf("listing TinkerbellMachines: %w", err)
+	}
+
+	if len(machines.Items) == 0 {
+		return nil
+	}
+
+	owners := make(map[types.NamespacedName]bool, len(machines.Items))
+	for i := range machines.Items {
+		owners[client.ObjectKeyFromObject(&machines.Items[i])] = true
+	}
+
+	claimed, err := labels.NewRequirement(machine.HardwareOwnerNameLabel, selection.Exists, nil)
+	if err != nil {
+		return fmt.Errorf("building owner requirement: %w", err)
+	}
+
+	unlabeled, err := labels.NewRequirement(ClusterNameLabel, selection.DoesNotExist, nil)
+	if err != nil {
+		return fmt.Errorf("building cluster requirement: %w", err)
+	}
+
+	hardware := &tinkv1.HardwareList{}
+
+	if err := crc.client.List(crc.ctx, hardware, client.MatchingLabelsSelector{
+		Selector: labels.NewSelector().Add(*claimed, *unlabeled),
+	}); err != nil {
+		return fmt.Errorf("listing Hardware: %w", err)
+	}
+
+	for i := range hardware.Items {
+		hw := &hardware.Items[i]
+
+		if owner, _ := hardwareutil.Owner(hw); !owners[owner] || hardwareutil.Paused(hw) {
+			continue
+		}
+
+		patchHelper, err := patch.NewHelper(hw, crc.client)
+		if err != nil {
+			return fmt.Errorf("initializing patch helper for Hardware %s/%s: %w", hw.Namespace, hw.Name, err)
+		}
+
+		hw.Labels[ClusterNameLabel] = crc.clusterName()
+		hw.Labels[ClusterNamespaceLabel] = crc.tinkerbellCluster.Namespace
+
+		if err := patchHelper.Patch(crc.ctx, hw); err != nil {
+			return fmt.Errorf("patching Hardware %s/%s: %w", hw.Namespace, hw.Name, err)
+		}
+
+		crc.log.Info("Labeled Hardware claimed before the cluster labels were recorded", "hardware", hw.Name,
+			"hardwareNamespace", hw.Namespace)
+	}
+
+	return nil
+}
+
+// releaseClusterHardware releases all Hardware still claimed for the cluster and wipes its user data, as the
+// bootstrap data of a deleted cluster must neither be served nor reused.
+func (crc *clusterReconcileContext) releaseClusterHardware() (reterr error) {
+	ctx, span := tracing.Start(crc.ctx, "ReleaseClusterHardware")
+	defer func() { tracing.End(span, reterr) }()
+
+	hardware := &tinkv1.HardwareList{}
+
+	// Hardware is listed in all namespaces, as machines selecting from a HardwarePool claim Hardware of other
+	// namespaces.
+	if err := crc.client.List(ctx, hardware,
+		client.MatchingLabels{
+			ClusterNameLabel:      crc.clusterName(),
+			ClusterNamespaceLabel: crc.tinkerbellCluster.Namespace,
+		},
+	); err != nil {
+		return fmt.Errorf("listing Hardware: %w", err)
+	}
+
+	for i := range hardware.Items {
+		hw := &hardware.Items[i]
+
+		// Paused Hardware must not be changed, the cluster is not deleted before it is unpaused and released.
+		if hardwareutil.Paused(hw) {
+			return fmt.Errorf("%w: Hardware %s/%s", machine.ErrHardwarePaused, hw.Namespace, hw.Name)
+		}
+
+		patchHelper, err := patch.NewHelper(hw, crc.client)
+		if err != nil {
+			return fmt.Errorf("initializing patch helper for Hardware %s/%s: %w", hw.Namespace, hw.Name, err)
+		}
+
+		machine.ClearHardwareOwnership(hw)
+		hw.Spec.UserData = nil
+
+		if err := patchHelper.Patch(ctx, hw); err != nil {
+			return fmt.Errorf("patching Hardware %s/%s: %w", hw.Namespace, hw.Name, err)
+		}
+
+		crc.log.Info("Released Hardware of deleted cluster", "hardware", hw.Name, "hardwareNamespace", hw.Namespace)
+		record.Eventf(crc.tinkerbellCluster, "HardwareReleased", "Released Hardware %s/%s", hw.Namespace, hw.Name)
+	}
+
+	return nil
+}
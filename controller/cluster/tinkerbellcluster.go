@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -33,20 +34,23 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 
 	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/tracing"
 )
 
 const (
-	// ClusterNameLabel is used to mark Hardware as assigned controlplane machine.
-	ClusterNameLabel = "v1alpha1.tinkerbell.org/clusterName"
+	// ClusterNameLabel is used to mark Hardware as assigned to a machine of the cluster.
+	ClusterNameLabel = machine.HardwareClusterNameLabel
 
 	// ClusterNamespaceLabel is used to mark in which Namespace hardware is used.
-	ClusterNamespaceLabel = "v1alpha1.tinkerbell.org/clusterNamespace"
+	ClusterNamespaceLabel = machine.HardwareClusterNamespaceLabel
 
-	// KubernetesAPIPort is a port used by Tinkerbell clusters for Kubernetes API.
-	KubernetesAPIPort = 6443
+	// KubernetesAPIPort is a port used by Tinkerbell clusters for Kubernetes API, unless they set APIServerPort.
+	KubernetesAPIPort = infrastructurev1.DefaultAPIServerPort
 )
 
 var (
@@ -65,6 +69,10 @@ var (
 type TinkerbellClusterReconciler struct {
 	client.Client
 	WatchFilterValue string
+
+	// EndpointProber probes the control plane endpoint of clusters with a ControlPlaneEndpointProbe. Defaults to
+	// ProbeControlPlaneEndpoint.
+	EndpointProber EndpointProber
 }
 
 // validate validates if context configuration has all required fields properly populated.
@@ -101,11 +109,16 @@ func (tcr *TinkerbellClusterReconciler) newReconcileContext(ctx context.Context,
 		tinkerbellCluster: &infrastructurev1.TinkerbellCluster{},
 		client:            tcr.Client,
 		namespacedName:    namespacedName,
+		endpointProber:    tcr.EndpointProber,
+	}
+
+	if crc.endpointProber == nil {
+		crc.endpointProber = ProbeControlPlaneEndpoint
 	}
 
 	if err := crc.client.Get(crc.ctx, namespacedName, crc.tinkerbellCluster); err != nil {
 		if apierrors.IsNotFound(err) {
-			crc.log.Info("TinkerbellCluster object not found")
+			crc.log.V(4).Info("TinkerbellCluster object not found") //nolint:gomnd
 
 			return nil, nil
 		}
@@ -128,7 +141,7 @@ func (tcr *TinkerbellClusterReconciler) newReconcileContext(ctx context.Context,
 	}
 
 	if cluster == nil {
-		crc.log.Info("OwnerCluster is not set yet.")
+		crc.log.V(4).Info("OwnerCluster is not set yet.") //nolint:gomnd
 	}
 
 	crc.cluster = cluster
@@ -145,6 +158,7 @@ type clusterReconcileContext struct {
 	log               logr.Logger
 	client            client.Client
 	namespacedName    types.NamespacedName
+	endpointProber    EndpointProber
 }
 
 func (crc *clusterReconcileContext) controlPlaneEndpoint() (clusterv1.APIEndpoint, error) {
@@ -177,6 +191,10 @@ func (crc *clusterReconcileContext) controlPlaneEndpoint() (clusterv1.APIEndpoin
 		return endpoint, ErrControlPlaneEndpointNotSet
 	}
 
+	if endpoint.Port == 0 {
+		endpoint.Port = crc.tinkerbellCluster.Spec.APIServerPort
+	}
+
 	if endpoint.Port == 0 {
 		endpoint.Port = KubernetesAPIPort
 	}
@@ -186,10 +204,10 @@ func (crc *clusterReconcileContext) controlPlaneEndpoint() (clusterv1.APIEndpoin
 
 // Reconcile implements ReconcileContext interface by ensuring that all TinkerbellCluster object
 // fields are properly populated.
-func (crc *clusterReconcileContext) reconcile() error {
+func (crc *clusterReconcileContext) reconcile() (ctrl.Result, error) {
 	controlPlaneEndpoint, err := crc.controlPlaneEndpoint()
 	if err != nil {
-		return err
+		return ctrl.Result{}, err
 	}
 
 	// Ensure that we are setting the ControlPlaneEndpoint on the TinkerbellCluster
@@ -197,27 +215,78 @@ func (crc *clusterReconcileContext) reconcile() error {
 	crc.tinkerbellCluster.Spec.ControlPlaneEndpoint.Host = controlPlaneEndpoint.Host
 	crc.tinkerbellCluster.Spec.ControlPlaneEndpoint.Port = controlPlaneEndpoint.Port
 
-	crc.tinkerbellCluster.Status.Ready = true
+	// Once Ready, the cluster stays Ready when the endpoint fails its probe, which only updates the condition.
+	reachable, requeueAfter := crc.probeControlPlaneEndpoint(controlPlaneEndpoint)
+	if reachable {
+		crc.tinkerbellCluster.Status.Ready = true
+	}
+
+	crc.tinkerbellCluster.Status.ObservedGeneration = crc.tinkerbellCluster.Generation
 
-	crc.log.Info("Setting cluster status to ready")
+	// The finalizer is only needed to release claimed Hardware on deletion.
+	if crc.tinkerbellCluster.ReleaseHardwareOnDeleteEnabled() {
+		controllerutil.AddFinalizer(crc.tinkerbellCluster, infrastructurev1.ClusterFinalizer)
+
+		if err := crc.labelClaimedHardware(); err != nil {
+			return ctrl.Result{}, fmt.Errorf("labeling claimed Hardware: %w", err)
+		}
+	}
+
+	crc.log.V(4).Info("Setting cluster status", "ready", crc.tinkerbellCluster.Status.Ready) //nolint:gomnd
 
 	if err := crc.patchHelper.Patch(crc.ctx, crc.tinkerbellCluster); err != nil {
-		return fmt.Errorf("patching cluster object: %w", err)
+		return ctrl.Result{}, fmt.Errorf("patching cluster object: %w", err)
 	}
 
-	return nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
-func (crc *clusterReconcileContext) reconcileDelete() error {
-	return nil
+// reconcileDelete releases the Hardware claimed for the cluster, when opted into, and removes the finalizer.
+func (crc *clusterReconcileContext) reconcileDelete() (ctrl.Result, error) {
+	if crc.tinkerbellCluster.ReleaseHardwareOnDeleteEnabled() {
+		if err := crc.labelClaimedHardware(); err != nil {
+			return ctrl.Result{}, fmt.Errorf("labeling claimed Hardware: %w", err)
+		}
+
+		machines, err := crc.liveMachines()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if machines > 0 {
+			crc.log.Info("Waiting for TinkerbellMachines to be deleted before releasing Hardware", "machines", machines)
+
+			return ctrl.Result{RequeueAfter: machinesDeletionRequeueAfter}, nil
+		}
+
+		if err := crc.releaseClusterHardware(); err != nil {
+			return ctrl.Result{}, fmt.Errorf("releasing Hardware: %w", err)
+		}
+	}
+
+	controllerutil.RemoveFinalizer(crc.tinkerbellCluster, infrastructurev1.ClusterFinalizer)
+
+	if err := crc.patchHelper.Patch(crc.ctx, crc.tinkerbellCluster); err != nil {
+		return ctrl.Result{}, fmt.Errorf("patching cluster object: %w", err)
+	}
+
+	return ctrl.Result{}, nil
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellclusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware,verbs=get;list;watch;update;patch
 
 // Reconcile ensures state of Tinkerbell clusters.
-func (tcr *TinkerbellClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (tcr *TinkerbellClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	ctx, span := tracing.Start(ctx, "TinkerbellCluster.Reconcile",
+		attribute.String("namespace", req.Namespace),
+		attribute.String("name", req.Name),
+	)
+	defer func() { tracing.End(span, reterr) }()
+
 	crc, err := tcr.newReconcileContext(ctx, req.NamespacedName)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("creating reconciliation context: %w", err)
@@ -229,14 +298,14 @@ func (tcr *TinkerbellClusterReconciler) Reconcile(ctx context.Context, req ctrl.
 
 	if !crc.tinkerbellCluster.ObjectMeta.DeletionTimestamp.IsZero() {
 		if annotations.HasPaused(crc.tinkerbellCluster) {
-			crc.log.Info("TinkerbellCluster is marked as paused. Won't reconcile deletion")
+			crc.log.V(4).Info("TinkerbellCluster is marked as paused. Won't reconcile deletion") //nolint:gomnd
 
 			return ctrl.Result{}, nil
 		}
 
 		crc.log.Info("Removing cluster")
 
-		return ctrl.Result{}, crc.reconcileDelete()
+		return crc.reconcileDelete()
 	}
 
 	if crc.cluster == nil {
@@ -244,12 +313,12 @@ func (tcr *TinkerbellClusterReconciler) Reconcile(ctx context.Context, req ctrl.
 	}
 
 	if annotations.IsPaused(crc.cluster, crc.tinkerbellCluster) {
-		crc.log.Info("TinkerbellCluster is marked as paused. Won't reconcile")
+		crc.log.V(4).Info("TinkerbellCluster is marked as paused. Won't reconcile") //nolint:gomnd
 
 		return ctrl.Result{}, nil
 	}
 
-	return ctrl.Result{}, crc.reconcile()
+	return crc.reconcile()
 }
 
 // SetupWithManager configures reconciler with a given manager.
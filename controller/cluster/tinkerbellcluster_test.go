@@ -23,6 +23,7 @@ import (
 	"github.com/google/uuid"
 	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -36,6 +37,7 @@ import (
 
 	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
 	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/cluster"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
 )
 
 //nolint:unparam
@@ -102,6 +104,29 @@ func Test_Cluster_reconciliation_when_controlplane_endpoint_set_on_cluster(t *te
 	g.Expect(updatedTinkerbellCluster.Status.Ready).To(BeTrue(), "Expected infrastructure to be ready")
 }
 
+func Test_Cluster_reconciliation_uses_api_server_port_when_controlplane_endpoint_has_no_port(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	cluster := validCluster(clusterName, clusterNamespace)
+	cluster.Spec.ControlPlaneEndpoint.Host = "192.168.1.10"
+
+	tinkCluster := unreadyTinkerbellCluster(clusterName, clusterNamespace)
+	tinkCluster.Spec.APIServerPort = 7443
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{cluster, tinkCluster})
+
+	_, err := reconcileClusterWithClient(client, clusterName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedTinkerbellCluster := &infrastructurev1.TinkerbellCluster{}
+	namespacedName := types.NamespacedName{Name: clusterName, Namespace: clusterNamespace}
+
+	g.Expect(client.Get(context.Background(), namespacedName, updatedTinkerbellCluster)).To(Succeed())
+	g.Expect(updatedTinkerbellCluster.Spec.ControlPlaneEndpoint).
+		To(Equal(clusterv1.APIEndpoint{Host: "192.168.1.10", Port: 7443}), "Expected the API server port to be used")
+}
+
 type testOptions struct {
 	// Labels allow providing labels for the machine
 	Labels           map[string]string
@@ -161,6 +186,7 @@ func Test_Cluster_reconciliation_when_controlplane_endpoint_set_on_tinkerbellClu
 	tinkCluster := unreadyTinkerbellCluster(clusterName, clusterNamespace)
 	tinkCluster.Spec.ControlPlaneEndpoint.Host = "192.168.1.10"
 	tinkCluster.Spec.ControlPlaneEndpoint.Port = 443
+	tinkCluster.Generation = 2
 
 	objects := []runtime.Object{
 		validHardware(hardwareName, uuid.New().String(), hardwareIP),
@@ -189,6 +215,8 @@ func Test_Cluster_reconciliation_when_controlplane_endpoint_set_on_tinkerbellClu
 		To(BeEquivalentTo(tinkCluster.Spec.ControlPlaneEndpoint.Port), "Expected controlplane endpoint port to be set")
 
 	g.Expect(updatedTinkerbellCluster.Status.Ready).To(BeTrue(), "Expected infrastructure to be ready")
+	g.Expect(updatedTinkerbellCluster.Status.ObservedGeneration).To(BeEquivalentTo(2),
+		"Expected the generation to be observed")
 }
 
 func Test_Cluster_reconciliation(t *testing.T) {
@@ -389,3 +417,82 @@ func clusterReconciliationIsNotRequeuedWhenClusterIsPaused(t *testing.T) {
 
 	g.Expect(result.IsZero()).To(BeTrue(), "Expected result to not request requeue")
 }
+
+//nolint:funlen
+func Test_Cluster_reconciliation_when_cluster_is_deleted_with_release_hardware_on_delete(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tinkCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkCluster.Spec.ReleaseHardwareOnDelete = true
+
+	hw := validHardware(hardwareName, uuid.New().String(), hardwareIP, testOptions{
+		Labels: map[string]string{
+			machine.HardwareOwnerNameLabel:      "myTinkerbellMachineName",
+			machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+			cluster.ClusterNameLabel:            clusterName,
+			cluster.ClusterNamespaceLabel:       clusterNamespace,
+		},
+	})
+	hw.Finalizers = []string{infrastructurev1.MachineFinalizer}
+	hw.Spec.UserData = ptr.To("bootstrap data")
+
+	// Hardware claimed before the cluster labels were recorded.
+	legacyHardware := validHardware("legacy-hardware", uuid.New().String(), "192.168.1.43", testOptions{
+		Labels: map[string]string{
+			machine.HardwareOwnerNameLabel:      "myTinkerbellMachineName",
+			machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+		},
+	})
+
+	tinkMachine := &infrastructurev1.TinkerbellMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myTinkerbellMachineName",
+			Namespace: clusterNamespace,
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: clusterName},
+		},
+	}
+
+	objects := []runtime.Object{
+		hw,
+		legacyHardware,
+		validCluster(clusterName, clusterNamespace),
+		tinkCluster,
+		tinkMachine,
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+	ctx := context.Background()
+
+	g.Expect(client.Delete(ctx, tinkCluster)).To(Succeed())
+
+	result, err := reconcileClusterWithClient(client, clusterName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected requeue while TinkerbellMachines still exist")
+
+	hardwareNamespacedName := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+	updatedHardware := &tinkv1.Hardware{}
+
+	g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+	g.Expect(updatedHardware.Labels).To(HaveKey(machine.HardwareOwnerNameLabel),
+		"Hardware should not be released while TinkerbellMachines still exist")
+
+	g.Expect(client.Delete(ctx, tinkMachine)).To(Succeed())
+
+	_, err = reconcileClusterWithClient(client, clusterName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+	g.Expect(updatedHardware.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel))
+	g.Expect(updatedHardware.Labels).NotTo(HaveKey(cluster.ClusterNameLabel))
+	g.Expect(updatedHardware.Finalizers).To(BeEmpty())
+	g.Expect(updatedHardware.Spec.UserData).To(BeNil(), "Expected Hardware user data to be wiped")
+
+	legacyNamespacedName := types.NamespacedName{Name: "legacy-hardware", Namespace: clusterNamespace}
+	g.Expect(client.Get(ctx, legacyNamespacedName, updatedHardware)).To(Succeed())
+	g.Expect(updatedHardware.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel),
+		"Expected Hardware claimed before the cluster labels were recorded to be released")
+
+	err = client.Get(ctx, types.NamespacedName{Name: clusterName, Namespace: clusterNamespace}, &infrastructurev1.TinkerbellCluster{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected TinkerbellCluster finalizer to be removed")
+}
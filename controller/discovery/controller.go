@@ -0,0 +1,137 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// InventoryReconciler creates the Hardware listed in the inventory ConfigMap of each namespace, in that namespace.
+// Existing Hardware is never changed, so Hardware can be edited after it was discovered; Hardware deleted while it is
+// still listed is recreated.
+type InventoryReconciler struct {
+	client.Client
+
+	// APIReader reads the inventory ConfigMaps, so the controller only needs to cache the metadata of ConfigMaps.
+	APIReader client.Reader
+
+	// ConfigMapName is the name of the ConfigMaps holding the inventories.
+	ConfigMapName string
+}
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile creates the Hardware of the inventory records which do not exist yet. Records whose MAC address is
+// already used by other Hardware are skipped, as both would be leased the same address.
+func (r *InventoryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.APIReader.Get(ctx, req.NamespacedName, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("getting ConfigMap: %w", err)
+	}
+
+	records, err := ParseCSV(strings.NewReader(cm.Data[InventoryKey]))
+	if err != nil {
+		record.Warnf(cm, "InvalidInventory", "Skipped inventory rows: %v", err)
+	}
+
+	hardware := &tinkv1.HardwareList{}
+	if err := r.Client.List(ctx, hardware, client.InNamespace(req.Namespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("listing Hardware: %w", err)
+	}
+
+	names := map[string]bool{}
+	macs := map[string]string{}
+
+	for _, hw := range hardware.Items {
+		names[hw.Name] = true
+
+		for _, iface := range hw.Spec.Interfaces {
+			if iface.DHCP != nil && iface.DHCP.MAC != "" {
+				macs[strings.ToLower(iface.DHCP.MAC)] = hw.Name
+			}
+		}
+	}
+
+	log := ctrl.LoggerFrom(ctx)
+	created := 0
+
+	for _, rec := range records {
+		if names[rec.Hostname] {
+			continue
+		}
+
+		if other, ok := macs[rec.MAC]; ok {
+			record.Warnf(cm, "DuplicateMACAddress",
+				"Skipped Hardware %s: MAC address %s is used by Hardware %s", rec.Hostname, rec.MAC, other)
+
+			continue
+		}
+
+		if err := r.Client.Create(ctx, rec.Hardware(req.Namespace, req.Name)); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+
+			return ctrl.Result{}, fmt.Errorf("creating Hardware %s: %w", rec.Hostname, err)
+		}
+
+		log.Info("Created Hardware from inventory", "hardware", rec.Hostname, "mac", rec.MAC)
+
+		created++
+	}
+
+	if created > 0 {
+		record.Eventf(cm, "HardwareDiscovered", "Created %d Hardware from the inventory", created)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager configures reconciler with a given manager.
+func (r *InventoryReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	if r.APIReader == nil {
+		r.APIReader = mgr.GetAPIReader()
+	}
+
+	isInventory := predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetName() == r.ConfigMapName
+	})
+
+	// Discovered Hardware is watched so it is recreated when deleted while still listed in the inventory.
+	toInventory := func(_ context.Context, o client.Object) []ctrl.Request {
+		source, ok := o.GetLabels()[DiscoveredFromLabel]
+		if !ok {
+			return nil
+		}
+
+		return []ctrl.Request{{NamespacedName: client.ObjectKey{Namespace: o.GetNamespace(), Name: source}}}
+	}
+
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("hardwareinventory").
+		WithOptions(options).
+		WatchesMetadata(&corev1.ConfigMap{}, &handler.EnqueueRequestForObject{}, builder.WithPredicates(isInventory)).
+		Watches(&tinkv1.Hardware{}, handler.EnqueueRequestsFromMapFunc(toInventory)).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
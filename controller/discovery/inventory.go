@@ -0,0 +1,272 @@
+// Package discovery creates Hardware from an inventory of the servers of a fleet, so Hardware does not have to be
+// written by hand for each server joining it.
+package discovery
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+const (
+	// InventoryKey is the key of the ConfigMap data holding the inventory, in CSV format.
+	InventoryKey = "hardware.csv"
+
+	// DiscoveredFromLabel is set on Hardware created from an inventory to the name of the ConfigMap holding it.
+	DiscoveredFromLabel = "v1alpha1.tinkerbell.org/discovered-from"
+
+	// tinkerbellMachineLabelPrefix is the prefix of the labels CAPT sets on the objects of TinkerbellMachines.
+	tinkerbellMachineLabelPrefix = "tinkerbellmachine.infrastructure.cluster.x-k8s.io/"
+)
+
+// reservedLabels are the labels CAPT sets on Hardware to track who claimed it and where it was discovered, which an
+// inventory must not set: Hardware created with them would look claimed by a machine which never did.
+//
+//nolint:gochecknoglobals
+var reservedLabels = map[string]bool{
+	hardwareutil.OwnerNameLabel:        true,
+	hardwareutil.OwnerNamespaceLabel:   true,
+	hardwareutil.ClusterNameLabel:      true,
+	hardwareutil.ClusterNamespaceLabel: true,
+	DiscoveredFromLabel:                true,
+}
+
+// Inventory columns. Other columns, e.g. BMC credentials, are ignored.
+const (
+	columnHostname    = "hostname"
+	columnMAC         = "mac"
+	columnIPAddress   = "ip_address"
+	columnNetmask     = "netmask"
+	columnGateway     = "gateway"
+	columnNameservers = "nameservers"
+	columnDisk        = "disk"
+	columnLabels      = "labels"
+)
+
+var (
+	// ErrMissingColumn is returned when the header of an inventory lacks a required column.
+	ErrMissingColumn = errors.New("inventory is missing a required column")
+
+	// ErrInvalidRecord is returned for inventory rows which cannot be turned into Hardware.
+	ErrInvalidRecord = errors.New("invalid inventory row")
+)
+
+// Record is a server listed in an inventory.
+type Record struct {
+	// Hostname is the hostname of the server, also used as the name of its Hardware.
+	Hostname string
+
+	// MAC is the MAC address of the interface the server netboots from.
+	MAC string
+
+	// IPAddress, Netmask and Gateway are the address the server is leased on that interface.
+	IPAddress string
+	Netmask   string
+	Gateway   string
+
+	// Nameservers are the DNS servers the server is configured with.
+	Nameservers []string
+
+	// Disk is the device the OS is installed to, e.g. /dev/sda.
+	Disk string
+
+	// Labels are set on the Hardware, e.g. to match the HardwareAffinity of machines.
+	Labels map[string]string
+}
+
+// ParseCSV reads the inventory records from CSV with a header row. The hostname and mac columns are required; multiple
+// nameservers and labels are separated by "|", labels being written as key=value. Rows which are invalid, set labels
+// reserved for CAPT, or duplicate the hostname or MAC address of a previous row, are skipped and reported in the
+// returned error along with the valid records.
+func ParseCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading inventory header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, required := range []string{columnHostname, columnMAC} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, required)
+		}
+	}
+
+	var (
+		records []Record
+		errs    []error
+	)
+
+	seen := map[string]bool{}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return records, errors.Join(append(errs, fmt.Errorf("reading inventory: %w", err))...)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+
+			return ""
+		}
+
+		record, err := newRecord(field)
+		if err == nil && (seen[record.Hostname] || seen[record.MAC]) {
+			err = fmt.Errorf("%w: duplicate hostname or MAC address", ErrInvalidRecord)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+
+			continue
+		}
+
+		seen[record.Hostname] = true
+		seen[record.MAC] = true
+		records = append(records, record)
+	}
+
+	return records, errors.Join(errs...)
+}
+
+// newRecord validates the fields of an inventory row and returns its record.
+func newRecord(field func(string) string) (Record, error) {
+	record := Record{
+		Hostname:    field(columnHostname),
+		IPAddress:   field(columnIPAddress),
+		Netmask:     field(columnNetmask),
+		Gateway:     field(columnGateway),
+		Nameservers: splitList(field(columnNameservers)),
+		Disk:        field(columnDisk),
+		Labels:      map[string]string{},
+	}
+
+	if errs := validation.IsDNS1123Subdomain(record.Hostname); len(errs) > 0 {
+		return Record{}, fmt.Errorf("%w: hostname %q: %s", ErrInvalidRecord, record.Hostname, strings.Join(errs, ", "))
+	}
+
+	mac, err := net.ParseMAC(field(columnMAC))
+	if err != nil {
+		return Record{}, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+
+	record.MAC = mac.String()
+
+	for name, ip := range map[string]string{
+		columnIPAddress: record.IPAddress,
+		columnNetmask:   record.Netmask,
+		columnGateway:   record.Gateway,
+	} {
+		if ip != "" && net.ParseIP(ip) == nil {
+			return Record{}, fmt.Errorf("%w: %s %q is not an IP address", ErrInvalidRecord, name, ip)
+		}
+	}
+
+	for _, label := range splitList(field(columnLabels)) {
+		key, value, _ := strings.Cut(label, "=")
+
+		errs := append(validation.IsQualifiedName(key), validation.IsValidLabelValue(value)...)
+		if len(errs) > 0 {
+			return Record{}, fmt.Errorf("%w: label %q: %s", ErrInvalidRecord, label, strings.Join(errs, ", "))
+		}
+
+		if reservedLabels[key] || strings.HasPrefix(key, tinkerbellMachineLabelPrefix) {
+			return Record{}, fmt.Errorf("%w: label %q is reserved for CAPT", ErrInvalidRecord, key)
+		}
+
+		record.Labels[key] = value
+	}
+
+	return record, nil
+}
+
+// splitList splits a "|" separated list, dropping empty entries.
+func splitList(list string) []string {
+	var entries []string
+
+	for _, entry := range strings.Split(list, "|") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// Hardware returns the Hardware of the record, allowed to netboot and run workflows from the interface of the
+// record. The MAC address doubles as the instance ID, which is the default worker ID of machine workflows.
+func (r Record) Hardware(namespace, source string) *tinkv1.Hardware {
+	labels := map[string]string{DiscoveredFromLabel: source}
+	for k, v := range r.Labels {
+		labels[k] = v
+	}
+
+	dhcp := &tinkv1.DHCP{
+		MAC:         r.MAC,
+		Hostname:    r.Hostname,
+		NameServers: r.Nameservers,
+	}
+
+	if r.IPAddress != "" {
+		dhcp.IP = &tinkv1.IP{Address: r.IPAddress, Netmask: r.Netmask, Gateway: r.Gateway}
+	}
+
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.Hostname,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: tinkv1.HardwareSpec{
+			Interfaces: []tinkv1.Interface{{
+				DHCP: dhcp,
+				Netboot: &tinkv1.Netboot{
+					AllowPXE:      ptr.To(true),
+					AllowWorkflow: ptr.To(true),
+				},
+			}},
+			Metadata: &tinkv1.HardwareMetadata{
+				Instance: &tinkv1.MetadataInstance{
+					ID:       r.MAC,
+					Hostname: r.Hostname,
+				},
+			},
+		},
+	}
+
+	if r.Disk != "" {
+		hw.Spec.Disks = []tinkv1.Disk{{Device: r.Disk}}
+	}
+
+	return hw
+}
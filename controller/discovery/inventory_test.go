@@ -0,0 +1,113 @@
+package discovery_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/discovery"
+)
+
+const inventory = `hostname,bmc_ip,mac,ip_address,netmask,gateway,nameservers,disk,labels
+worker-1,10.0.0.101,00:00:5E:00:53:01,10.0.1.1,255.255.255.0,10.0.1.254,1.1.1.1|8.8.8.8,/dev/sda,type=worker|rack=r1
+worker-2,10.0.0.102,00:00:5e:00:53:02,10.0.1.2,255.255.255.0,10.0.1.254,,/dev/nvme0n1,type=worker
+Invalid_Name,10.0.0.103,00:00:5e:00:53:03,,,,,,
+worker-3,10.0.0.104,not-a-mac,,,,,,
+worker-4,10.0.0.105,00:00:5e:00:53:01,,,,,,
+worker-5,10.0.0.106,00:00:5e:00:53:05,,,,,,v1alpha1.tinkerbell.org/ownerName=machine-1
+`
+
+func Test_ParseCSV(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	records, err := discovery.ParseCSV(strings.NewReader(inventory))
+	g.Expect(err).To(MatchError(discovery.ErrInvalidRecord))
+	g.Expect(err.Error()).To(ContainSubstring("line 4"))
+	g.Expect(err.Error()).To(ContainSubstring("line 5"))
+	g.Expect(err.Error()).To(ContainSubstring("line 6"), "Expected the duplicate MAC address to be reported")
+	g.Expect(err.Error()).To(ContainSubstring("line 7"), "Expected the reserved label to be reported")
+
+	g.Expect(records).To(HaveLen(2))
+	g.Expect(records[0]).To(Equal(discovery.Record{
+		Hostname:    "worker-1",
+		MAC:         "00:00:5e:00:53:01",
+		IPAddress:   "10.0.1.1",
+		Netmask:     "255.255.255.0",
+		Gateway:     "10.0.1.254",
+		Nameservers: []string{"1.1.1.1", "8.8.8.8"},
+		Disk:        "/dev/sda",
+		Labels:      map[string]string{"type": "worker", "rack": "r1"},
+	}))
+
+	_, err = discovery.ParseCSV(strings.NewReader("hostname,ip_address\nworker-1,10.0.1.1\n"))
+	g.Expect(err).To(MatchError(discovery.ErrMissingColumn))
+}
+
+func Test_InventoryReconciler(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	existing := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-2", Namespace: "default", Labels: map[string]string{"type": "edited"}},
+	}
+	conflicting := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+		Spec: tinkv1.HardwareSpec{Interfaces: []tinkv1.Interface{{
+			DHCP: &tinkv1.DHCP{MAC: "00:00:5e:00:53:03"},
+		}}},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: "default"},
+		Data: map[string]string{discovery.InventoryKey: `hostname,mac,ip_address,disk,labels
+worker-1,00:00:5e:00:53:01,10.0.1.1,/dev/sda,type=worker
+worker-2,00:00:5e:00:53:02,10.0.1.2,/dev/sda,type=worker
+worker-3,00:00:5e:00:53:03,10.0.1.3,/dev/sda,type=worker
+`},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing, conflicting, cm).Build()
+	r := &discovery.InventoryReconciler{Client: c, APIReader: c, ConfigMapName: "inventory"}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(cm)}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	hw := &tinkv1.Hardware{}
+	g.Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "worker-1"}, hw)).To(Succeed())
+	g.Expect(hw.Labels).To(HaveKeyWithValue("type", "worker"))
+	g.Expect(hw.Labels).To(HaveKeyWithValue(discovery.DiscoveredFromLabel, "inventory"))
+	g.Expect(hw.Spec.Interfaces[0].DHCP.MAC).To(Equal("00:00:5e:00:53:01"))
+	g.Expect(hw.Spec.Interfaces[0].DHCP.IP.Address).To(Equal("10.0.1.1"))
+	g.Expect(*hw.Spec.Interfaces[0].Netboot.AllowPXE).To(BeTrue())
+	g.Expect(hw.Spec.Metadata.Instance.ID).To(Equal("00:00:5e:00:53:01"))
+	g.Expect(hw.Spec.Disks[0].Device).To(Equal("/dev/sda"))
+
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(existing), hw)).To(Succeed())
+	g.Expect(hw.Labels).To(HaveKeyWithValue("type", "edited"), "Expected existing Hardware to be left alone")
+
+	err = c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "worker-3"}, hw)
+	g.Expect(err).To(HaveOccurred(), "Expected Hardware with a MAC address in use not to be created")
+
+	// Hardware deleted while still listed is recreated.
+	g.Expect(c.Delete(ctx, &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Namespace: "default"}})).
+		To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "worker-1"}, hw)).To(Succeed())
+}
@@ -0,0 +1,84 @@
+package hardware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+// ErrHardwareBound is returned when deleting Hardware still bound to a TinkerbellMachine without finalizer.
+var ErrHardwareBound = errors.New("hardware is bound to TinkerbellMachine")
+
+// DeletionGuard refuses the deletion of Hardware bound to a TinkerbellMachine without the finalizer of CAPT, as
+// claimed by the machines of clusters with the None HardwareFinalizerPolicy, until the machine is deleted. Hardware
+// with the finalizer is already kept until it is released, so its deletion is allowed.
+type DeletionGuard struct {
+	Client client.Client
+}
+
+var _ admission.CustomValidator = &DeletionGuard{}
+
+// +kubebuilder:webhook:verbs=delete,path=/validate-tinkerbell-org-v1alpha1-hardware,mutating=false,failurePolicy=ignore,matchPolicy=Equivalent,groups=tinkerbell.org,resources=hardware,versions=v1alpha1,name=guard.hardware.tinkerbell.org,sideEffects=None,admissionReviewVersions=v1;v1beta1
+
+// SetupWebhookWithManager registers the guard for Hardware with the webhook server of the manager.
+func (g *DeletionGuard) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if err := ctrl.NewWebhookManagedBy(mgr).For(&tinkv1.Hardware{}).WithValidator(g).Complete(); err != nil {
+		return fmt.Errorf("setting up Hardware webhook: %w", err)
+	}
+
+	return nil
+}
+
+// ValidateCreate allows the creation of Hardware.
+func (g *DeletionGuard) ValidateCreate(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateUpdate allows updates of Hardware.
+func (g *DeletionGuard) ValidateUpdate(context.Context, runtime.Object, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateDelete refuses the deletion of Hardware bound without finalizer to a TinkerbellMachine which exists and
+// is not being deleted.
+func (g *DeletionGuard) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	hw, ok := obj.(*tinkv1.Hardware)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a Hardware but got a %T", obj))
+	}
+
+	owner, bound := hw.GetLabels()[machine.HardwareOwnerNameLabel]
+	if !bound || controllerutil.ContainsFinalizer(hw, infrastructurev1.MachineFinalizer) {
+		return nil, nil
+	}
+
+	tm := &infrastructurev1.TinkerbellMachine{}
+
+	err := g.Client.Get(ctx, client.ObjectKey{
+		Namespace: hw.GetLabels()[machine.HardwareOwnerNamespaceLabel],
+		Name:      owner,
+	}, tm)
+
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, apierrors.NewInternalError(fmt.Errorf("getting TinkerbellMachine %s: %w", owner, err))
+	case !tm.DeletionTimestamp.IsZero():
+		return nil, nil
+	}
+
+	return nil, apierrors.NewForbidden(tinkv1.GroupVersion.WithResource("hardware").GroupResource(), hw.Name,
+		fmt.Errorf("%w %s/%s, delete the machine first", ErrHardwareBound, tm.Namespace, tm.Name))
+}
@@ -0,0 +1,96 @@
+package hardware_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/hardware"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_DeletionGuard(t *testing.T) {
+	t.Parallel()
+
+	boundHardware := func(finalizers ...string) *tinkv1.Hardware {
+		return &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{
+			Name:      "hw",
+			Namespace: "default",
+			Labels: map[string]string{
+				machine.HardwareOwnerNameLabel:      "machine",
+				machine.HardwareOwnerNamespaceLabel: "default",
+			},
+			Finalizers: finalizers,
+		}}
+	}
+
+	owner := func(deleting bool) *infrastructurev1.TinkerbellMachine {
+		tm := &infrastructurev1.TinkerbellMachine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}}
+		if deleting {
+			now := metav1.Now()
+			tm.DeletionTimestamp = &now
+			tm.Finalizers = []string{infrastructurev1.MachineFinalizer}
+		}
+
+		return tm
+	}
+
+	tests := map[string]struct {
+		hw         *tinkv1.Hardware
+		owner      *infrastructurev1.TinkerbellMachine
+		wantDenied bool
+	}{
+		"unbound hardware": {
+			hw: &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"}},
+		},
+		"bound hardware without finalizer": {
+			hw:         boundHardware(),
+			owner:      owner(false),
+			wantDenied: true,
+		},
+		"bound hardware with finalizer": {
+			hw:    boundHardware(infrastructurev1.MachineFinalizer),
+			owner: owner(false),
+		},
+		"bound hardware of deleted machine": {
+			hw: boundHardware(),
+		},
+		"bound hardware of machine being deleted": {
+			hw:    boundHardware(),
+			owner: owner(true),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(infrastructurev1.AddToScheme(scheme)).To(Succeed())
+
+			var objects []client.Object
+			if tc.owner != nil {
+				objects = append(objects, tc.owner)
+			}
+
+			guard := &hardware.DeletionGuard{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()}
+
+			_, err := guard.ValidateDelete(context.Background(), tc.hw)
+			if tc.wantDenied {
+				g.Expect(apierrors.IsForbidden(err)).To(BeTrue(), "Expected the deletion to be forbidden, got %v", err)
+				g.Expect(err).To(MatchError(ContainSubstring("default/machine")))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
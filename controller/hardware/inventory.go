@@ -0,0 +1,510 @@
+package hardware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+const (
+	// HardwareInventoryScanAnnotation is set on Hardware to "true" to collect its inventory with a one-off workflow.
+	// Only Hardware which is not claimed by a machine is scanned, and Hardware being scanned is not selected for
+	// machines. The annotation is removed once the scan finished.
+	HardwareInventoryScanAnnotation = hardwareutil.InventoryScanAnnotation
+
+	// HardwareInventoryAnnotation is set on scanned Hardware to the JSON inventory reported by the scan.
+	HardwareInventoryAnnotation = "v1alpha1.tinkerbell.org/inventory"
+
+	// HardwareCPUsAnnotation is set on scanned Hardware to its number of logical CPUs.
+	HardwareCPUsAnnotation = "v1alpha1.tinkerbell.org/cpus"
+
+	// HardwareMemoryAnnotation is set on scanned Hardware to its memory, as a quantity, e.g. 256Gi.
+	HardwareMemoryAnnotation = "v1alpha1.tinkerbell.org/memory"
+
+	// HardwareInventoryScannedAtAnnotation is set on scanned Hardware to the time its inventory was reported, in
+	// RFC 3339 format.
+	HardwareInventoryScannedAtAnnotation = "v1alpha1.tinkerbell.org/inventory-scanned-at"
+
+	// InventoryReportPattern is the pattern of the endpoint scans report the inventory of Hardware to. The report
+	// token of the scan in the URL keeps reports from being recorded for other Hardware.
+	InventoryReportPattern = "POST /inventory/{namespace}/{name}/{token}"
+
+	// inventoryWorkflowSuffix is appended to the name of Hardware to name the Template and Workflow scanning it.
+	inventoryWorkflowSuffix = "-inventory"
+
+	// inventoryTokenSecretSuffix is appended to the name of Hardware to name the Secret holding the token its scan
+	// reports with.
+	inventoryTokenSecretSuffix = "-inventory-token"
+
+	// inventoryReportBytes is the largest inventory a scan may report.
+	inventoryReportBytes = 256 * 1024
+
+	inventoryTemplate = `
+version: "0.1"
+name: {{.Name}}
+global_timeout: 1800
+tasks:
+  - name: "inventory"
+    worker: "{{"{{"}}.device_1{{"}}"}}"
+    volumes:
+      - /dev:/dev
+      - /sys:/sys:ro
+    actions:
+      - name: "collect inventory"
+        image: {{.Image}}
+        timeout: 600
+        pid: host
+        environment:
+          INVENTORY_REPORT_URL: {{.ReportURL}}
+`
+)
+
+// Inventory is the inventory of Hardware, as reported by the action of inventory scans in JSON format.
+type Inventory struct {
+	// CPUs is the number of logical CPUs.
+	CPUs int `json:"cpus,omitempty"`
+
+	// MemoryBytes is the size of the memory in bytes.
+	MemoryBytes int64 `json:"memoryBytes,omitempty"`
+
+	// Disks are the disks of the Hardware.
+	Disks []machine.HardwareDisk `json:"disks,omitempty"`
+
+	// Vendor, Product and Serial describe the system, as reported by its DMI tables.
+	Vendor  string `json:"vendor,omitempty"`
+	Product string `json:"product,omitempty"`
+	Serial  string `json:"serial,omitempty"`
+
+	// BIOSVersion is the version of the firmware of the system.
+	BIOSVersion string `json:"biosVersion,omitempty"`
+}
+
+// annotations returns the annotations recording the inventory on Hardware. The disks are recorded in the
+// HardwareDisksAnnotation, so root disk selectors match them.
+func (inv Inventory) annotations(now time.Time) (map[string]string, error) {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return nil, fmt.Errorf("encoding inventory: %w", err)
+	}
+
+	annotations := map[string]string{
+		HardwareInventoryAnnotation:          string(data),
+		HardwareInventoryScannedAtAnnotation: now.UTC().Format(time.RFC3339),
+	}
+
+	if inv.CPUs > 0 {
+		annotations[HardwareCPUsAnnotation] = strconv.Itoa(inv.CPUs)
+	}
+
+	if inv.MemoryBytes > 0 {
+		annotations[HardwareMemoryAnnotation] = resource.NewQuantity(inv.MemoryBytes, resource.BinarySI).String()
+	}
+
+	if len(inv.Disks) > 0 {
+		disks, err := json.Marshal(inv.Disks)
+		if err != nil {
+			return nil, fmt.Errorf("encoding disks: %w", err)
+		}
+
+		annotations[machine.HardwareDisksAnnotation] = string(disks)
+	}
+
+	return annotations, nil
+}
+
+// scannedDisks returns the HardwareDisksAnnotation the last scan of the Hardware recorded, empty when it was not
+// scanned or no disks were reported.
+func scannedDisks(hw *tinkv1.Hardware) string {
+	inv := Inventory{}
+	if err := json.Unmarshal([]byte(hw.Annotations[HardwareInventoryAnnotation]), &inv); err != nil {
+		return ""
+	}
+
+	annotations, err := inv.annotations(time.Time{})
+	if err != nil {
+		return ""
+	}
+
+	return annotations[machine.HardwareDisksAnnotation]
+}
+
+// InventoryScanReconciler collects the inventory of Hardware annotated with HardwareInventoryScanAnnotation, without
+// provisioning it: a Workflow netboots the Hardware and runs an action reporting its CPUs, memory, disks and system
+// information to InventoryReportHandler, which records them in annotations of the Hardware. Hardware selection can
+// then match them, e.g. with root disk selectors or filter expressions, before any OS is installed.
+type InventoryScanReconciler struct {
+	client.Client
+
+	// Image is the image of the action collecting the inventory. It posts the Inventory as JSON to the URL in its
+	// INVENTORY_REPORT_URL environment variable.
+	Image string
+
+	// ReportURL is the base URL under which Hardware reaches the InventoryReportHandler.
+	ReportURL string
+}
+
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=templates;workflows,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile starts the inventory scan of the Hardware when it is requested, and removes its Template and Workflow
+// once the scan finished.
+func (r *InventoryScanReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	hw := &tinkv1.Hardware{}
+	if err := r.Client.Get(ctx, req.NamespacedName, hw); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("getting Hardware: %w", err)
+	}
+
+	if hardwareutil.Paused(hw) {
+		return ctrl.Result{}, nil
+	}
+
+	if hw.Annotations[HardwareInventoryScanAnnotation] != "true" || !hw.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.removeScan(ctx, hw)
+	}
+
+	if owner := hw.Labels[machine.HardwareOwnerNameLabel]; owner != "" {
+		record.Warnf(hw, "InventoryScanIgnored", "Not scanning Hardware claimed by %s", owner)
+
+		return ctrl.Result{}, r.finishScan(ctx, hw)
+	}
+
+	wf := &tinkv1.Workflow{}
+
+	key := types.NamespacedName{Namespace: hw.Namespace, Name: hw.Name + inventoryWorkflowSuffix}
+	if err := r.Client.Get(ctx, key, wf); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("getting inventory Workflow: %w", err)
+		}
+
+		return ctrl.Result{}, r.startScan(ctx, hw)
+	}
+
+	switch wf.Status.State { //nolint:exhaustive
+	case tinkv1.WorkflowStateSuccess:
+		// The action reports the inventory before it succeeds, which removes the scan annotation.
+		record.Warnf(hw, "InventoryScanFailed", "Inventory scan finished without reporting an inventory")
+	case tinkv1.WorkflowStateFailed, tinkv1.WorkflowStateTimeout:
+		record.Warnf(hw, "InventoryScanFailed", "Inventory scan Workflow %s: %s", wf.Status.State, wf.Status.CurrentAction)
+	default:
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, r.finishScan(ctx, hw)
+}
+
+// workerDevice returns the identifier of the Hardware as the worker of the inventory Workflow: its instance ID, as
+// for machines, or the MAC address of its first interface when it has none.
+func workerDevice(hw *tinkv1.Hardware) (string, error) {
+	if hw.Spec.Metadata != nil && hw.Spec.Metadata.Instance != nil && hw.Spec.Metadata.Instance.ID != "" {
+		return hw.Spec.Metadata.Instance.ID, nil
+	}
+
+	if len(hw.Spec.Interfaces) > 0 && hw.Spec.Interfaces[0].DHCP != nil && hw.Spec.Interfaces[0].DHCP.MAC != "" {
+		return hw.Spec.Interfaces[0].DHCP.MAC, nil
+	}
+
+	return "", fmt.Errorf("%w: neither instance ID nor MAC address of Hardware %s is set",
+		machine.ErrWorkerDeviceUnavailable, hw.Name)
+}
+
+// startScan creates the Template and Workflow scanning the Hardware, owned by the Hardware.
+func (r *InventoryScanReconciler) startScan(ctx context.Context, hw *tinkv1.Hardware) error {
+	device, err := workerDevice(hw)
+	if err != nil {
+		record.Warnf(hw, "InventoryScanFailed", "Not scanning Hardware: %v", err)
+
+		return r.finishScan(ctx, hw)
+	}
+
+	token, err := machine.EnsureReportToken(ctx, r.Client, hw, hw.Name+inventoryTokenSecretSuffix)
+	if err != nil {
+		return err
+	}
+
+	name := hw.Name + inventoryWorkflowSuffix
+	reportURL := fmt.Sprintf("%s/inventory/%s/%s/%s", strings.TrimSuffix(r.ReportURL, "/"),
+		hw.Namespace, hw.Name, token)
+
+	var data bytes.Buffer
+	if err := template.Must(template.New("inventory").Parse(inventoryTemplate)).Execute(&data, map[string]string{
+		"Name":      name,
+		"Image":     r.Image,
+		"ReportURL": reportURL,
+	}); err != nil {
+		return fmt.Errorf("rendering inventory Template: %w", err)
+	}
+
+	if err := createHardwareWorkflow(ctx, r.Client, hw, name, device, data.String(), nil); err != nil {
+		return err
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Started inventory scan", "workflow", name)
+	record.Eventf(hw, "InventoryScanStarted", "Collecting inventory with Workflow %s", name)
+
+	return nil
+}
+
+// createHardwareWorkflow creates a Template with the given data and a Workflow running it on the Hardware, both
+// named name and owned by the Hardware. Tinkerbell netboots the Hardware to run the Workflow, powering it on through
+// its BMC when it has one.
+func createHardwareWorkflow(
+	ctx context.Context,
+	c client.Client,
+	hw *tinkv1.Hardware,
+	name, device, data string,
+	annotations map[string]string,
+) error {
+	tmpl := &tinkv1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: hw.Namespace},
+		Spec:       tinkv1.TemplateSpec{Data: ptr.To(data)},
+	}
+
+	wf := &tinkv1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: hw.Namespace, Annotations: annotations},
+		Spec: tinkv1.WorkflowSpec{
+			TemplateRef: name,
+			HardwareRef: hw.Name,
+			HardwareMap: map[string]string{"device_1": device},
+			BootOptions: tinkv1.BootOptions{ToggleAllowNetboot: true},
+		},
+	}
+
+	if hw.Spec.BMCRef != nil {
+		wf.Spec.BootOptions.BootMode = tinkv1.BootModeNetboot
+	}
+
+	for _, obj := range []client.Object{tmpl, wf} {
+		if err := controllerutil.SetControllerReference(hw, obj, c.Scheme()); err != nil {
+			return fmt.Errorf("setting owner of %s: %w", name, err)
+		}
+
+		if err := c.Create(ctx, obj); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("creating %T %s: %w", obj, name, err)
+		}
+	}
+
+	return nil
+}
+
+// finishScan removes the scan annotation of the Hardware along with the Template and Workflow scanning it.
+func (r *InventoryScanReconciler) finishScan(ctx context.Context, hw *tinkv1.Hardware) error {
+	patchHelper, err := patch.NewHelper(hw, r.Client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for Hardware: %w", err)
+	}
+
+	delete(hw.Annotations, HardwareInventoryScanAnnotation)
+
+	if err := patchHelper.Patch(ctx, hw); err != nil {
+		return fmt.Errorf("patching Hardware: %w", err)
+	}
+
+	return r.removeScan(ctx, hw)
+}
+
+// removeScan removes the Template and Workflow scanning the Hardware, if any, and the Secret holding the token of
+// its report.
+func (r *InventoryScanReconciler) removeScan(ctx context.Context, hw *tinkv1.Hardware) error {
+	if err := removeHardwareWorkflow(ctx, r.Client, hw, hw.Name+inventoryWorkflowSuffix); err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: hw.Namespace, Name: hw.Name + inventoryTokenSecretSuffix}
+
+	if err := r.Client.Get(ctx, key, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("getting inventory token Secret: %w", err)
+	}
+
+	if !metav1.IsControlledBy(secret, hw) {
+		return nil
+	}
+
+	if err := r.Client.Delete(ctx, secret); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("deleting inventory token Secret: %w", err)
+	}
+
+	return nil
+}
+
+// removeHardwareWorkflow removes the Template and Workflow of the given name created for the Hardware, if any.
+func removeHardwareWorkflow(ctx context.Context, c client.Client, hw *tinkv1.Hardware, name string) error {
+	key := types.NamespacedName{Namespace: hw.Namespace, Name: name}
+
+	for _, obj := range []client.Object{&tinkv1.Workflow{}, &tinkv1.Template{}} {
+		if err := c.Get(ctx, key, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return fmt.Errorf("getting %T %s: %w", obj, name, err)
+		}
+
+		// Objects of the same name which were not created for the Hardware are left alone.
+		if !metav1.IsControlledBy(obj, hw) {
+			continue
+		}
+
+		if err := c.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting %T %s: %w", obj, name, err)
+		}
+	}
+
+	return nil
+}
+
+// SetupWithManager configures reconciler with a given manager.
+func (r *InventoryScanReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("hardwareinventoryscan").
+		WithOptions(options).
+		For(&tinkv1.Hardware{}).
+		Owns(&tinkv1.Workflow{}).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
+
+// InventoryReportHandler records the inventories reported by inventory scans in annotations of their Hardware,
+// and ends the scan by removing the HardwareInventoryScanAnnotation. It serves InventoryReportPattern.
+type InventoryReportHandler struct {
+	Client client.Client
+}
+
+// ServeHTTP records the inventory reported for Hardware.
+func (h *InventoryReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := types.NamespacedName{Namespace: r.PathValue("namespace"), Name: r.PathValue("name")}
+	log := ctrl.LoggerFrom(r.Context()).WithValues("hardware", key)
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, inventoryReportBytes))
+	if err != nil {
+		http.Error(w, "reading report", http.StatusBadRequest)
+
+		return
+	}
+
+	inv := Inventory{}
+	if err := json.Unmarshal(body, &inv); err != nil {
+		http.Error(w, fmt.Sprintf("decoding inventory: %v", err), http.StatusBadRequest)
+
+		return
+	}
+
+	hw := &tinkv1.Hardware{}
+	if err := h.Client.Get(r.Context(), key, hw); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		log.Error(err, "Getting Hardware of inventory report")
+		http.Error(w, "getting hardware", http.StatusInternalServerError)
+
+		return
+	}
+
+	// Only Hardware being scanned accepts reports, so the inventory of claimed Hardware is never overwritten.
+	if hw.Annotations[HardwareInventoryScanAnnotation] != "true" {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	ok, err := machine.VerifyReportToken(r.Context(), h.Client, hw, hw.Name+inventoryTokenSecretSuffix,
+		r.PathValue("token"))
+	if err != nil {
+		log.Error(err, "Verifying report token")
+		http.Error(w, "verifying token", http.StatusInternalServerError)
+
+		return
+	}
+
+	if !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if hardwareutil.Paused(hw) {
+		http.Error(w, "hardware is paused", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	annotations, err := inv.annotations(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	// Disks set by users, e.g. describing disks the scan cannot tell apart, are kept. Only disks recorded by an
+	// earlier scan are replaced.
+	if disks, ok := hw.Annotations[machine.HardwareDisksAnnotation]; ok && disks != scannedDisks(hw) {
+		delete(annotations, machine.HardwareDisksAnnotation)
+		log.Info("Keeping disks set on Hardware", "annotation", machine.HardwareDisksAnnotation)
+	}
+
+	patchHelper, err := patch.NewHelper(hw, h.Client)
+	if err != nil {
+		log.Error(err, "Initializing patch helper")
+		http.Error(w, "patching hardware", http.StatusInternalServerError)
+
+		return
+	}
+
+	for k, v := range annotations {
+		hw.Annotations[k] = v
+	}
+
+	delete(hw.Annotations, HardwareInventoryScanAnnotation)
+
+	if err := patchHelper.Patch(r.Context(), hw); err != nil {
+		log.Error(err, "Patching Hardware with inventory")
+		http.Error(w, "patching hardware", http.StatusInternalServerError)
+
+		return
+	}
+
+	record.Eventf(hw, "InventoryScanned", "Recorded inventory: %d CPUs, %s memory, %d disks",
+		inv.CPUs, hw.Annotations[HardwareMemoryAnnotation], len(inv.Disks))
+	log.Info("Recorded inventory report")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,213 @@
+package hardware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/hardware"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+//nolint:funlen
+func Test_InventoryScan(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "hw",
+			Namespace:   "default",
+			UID:         "hw-uid",
+			Annotations: map[string]string{hardware.HardwareInventoryScanAnnotation: "true"},
+		},
+		Spec: tinkv1.HardwareSpec{
+			Interfaces: []tinkv1.Interface{{DHCP: &tinkv1.DHCP{MAC: "aa:bb:cc:dd:ee:ff"}}},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hw).Build()
+	r := &hardware.InventoryScanReconciler{Client: c, Image: "inventory:latest", ReportURL: "http://10.1.1.1:8082/"}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(hw)}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	key := types.NamespacedName{Namespace: "default", Name: "hw-inventory"}
+
+	wf := &tinkv1.Workflow{}
+	g.Expect(c.Get(ctx, key, wf)).To(Succeed())
+	g.Expect(wf.Spec.HardwareMap).To(HaveKeyWithValue("device_1", "aa:bb:cc:dd:ee:ff"))
+	g.Expect(wf.Spec.BootOptions.ToggleAllowNetboot).To(BeTrue())
+	g.Expect(metav1.IsControlledBy(wf, hw)).To(BeTrue())
+
+	tokenKey := types.NamespacedName{Namespace: "default", Name: "hw-inventory-token"}
+
+	secret := &corev1.Secret{}
+	g.Expect(c.Get(ctx, tokenKey, secret)).To(Succeed())
+	g.Expect(metav1.IsControlledBy(secret, hw)).To(BeTrue())
+
+	token := string(secret.Data[machine.ReportTokenKey])
+	g.Expect(token).To(HaveLen(64))
+
+	tmpl := &tinkv1.Template{}
+	g.Expect(c.Get(ctx, key, tmpl)).To(Succeed())
+	g.Expect(*tmpl.Spec.Data).To(ContainSubstring("image: inventory:latest"))
+	g.Expect(*tmpl.Spec.Data).To(ContainSubstring("http://10.1.1.1:8082/inventory/default/hw/" + token))
+	g.Expect(*tmpl.Spec.Data).NotTo(ContainSubstring("hw-uid"))
+	g.Expect(*tmpl.Spec.Data).To(ContainSubstring(`worker: "{{.device_1}}"`))
+
+	handler := &hardware.InventoryReportHandler{Client: c}
+	mux := http.NewServeMux()
+	mux.Handle(hardware.InventoryReportPattern, handler)
+
+	report := func(path, body string) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+
+		return w.Code
+	}
+
+	inventory := `{"cpus": 64, "memoryBytes": 274877906944, "disks": [{"device": "/dev/nvme0n1", "sizeBytes": 1000}]}`
+	g.Expect(report("/inventory/default/hw/hw-uid", inventory)).To(Equal(http.StatusNotFound),
+		"Expected reports authenticated with the UID of the Hardware to be rejected")
+	g.Expect(report("/inventory/default/hw/"+token, "not json")).To(Equal(http.StatusBadRequest))
+	g.Expect(report("/inventory/default/hw/"+token, inventory)).To(Equal(http.StatusNoContent))
+
+	g.Expect(c.Get(ctx, req.NamespacedName, hw)).To(Succeed())
+	g.Expect(hw.Annotations).NotTo(HaveKey(hardware.HardwareInventoryScanAnnotation))
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(hardware.HardwareCPUsAnnotation, "64"))
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(hardware.HardwareMemoryAnnotation, "256Gi"))
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(machine.HardwareDisksAnnotation,
+		`[{"device":"/dev/nvme0n1","sizeBytes":1000}]`))
+	g.Expect(hw.Annotations).To(HaveKey(hardware.HardwareInventoryAnnotation))
+	g.Expect(hw.Annotations).To(HaveKey(hardware.HardwareInventoryScannedAtAnnotation))
+
+	g.Expect(report("/inventory/default/hw/"+token, inventory)).To(Equal(http.StatusNotFound),
+		"Expected reports to be rejected once the scan finished")
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(ctx, key, &tinkv1.Workflow{})).NotTo(Succeed(), "Expected the Workflow to be removed")
+	g.Expect(c.Get(ctx, key, &tinkv1.Template{})).NotTo(Succeed(), "Expected the Template to be removed")
+	g.Expect(c.Get(ctx, tokenKey, &corev1.Secret{})).NotTo(Succeed(), "Expected the token Secret to be removed")
+}
+
+func Test_InventoryScan_ignores_claimed_Hardware(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "hw",
+			Namespace:   "default",
+			Labels:      map[string]string{machine.HardwareOwnerNameLabel: "machine"},
+			Annotations: map[string]string{hardware.HardwareInventoryScanAnnotation: "true"},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hw).Build()
+	r := &hardware.InventoryScanReconciler{Client: c, Image: "inventory:latest", ReportURL: "http://10.1.1.1:8082"}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(hw)}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(c.Get(ctx, req.NamespacedName, hw)).To(Succeed())
+	g.Expect(hw.Annotations).NotTo(HaveKey(hardware.HardwareInventoryScanAnnotation))
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "hw-inventory"}, &tinkv1.Workflow{})).
+		NotTo(Succeed(), "Expected no scan of claimed Hardware")
+}
+
+func Test_InventoryReport_keeps_disks_set_by_users(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	const userDisks = `[{"device":"/dev/sda","serial":"S1","sizeBytes":1000}]`
+
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hw",
+			Namespace: "default",
+			Annotations: map[string]string{
+				hardware.HardwareInventoryScanAnnotation: "true",
+				machine.HardwareDisksAnnotation:          userDisks,
+			},
+		},
+		Spec: tinkv1.HardwareSpec{
+			Interfaces: []tinkv1.Interface{{DHCP: &tinkv1.DHCP{MAC: "aa:bb:cc:dd:ee:ff"}}},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hw).Build()
+	r := &hardware.InventoryScanReconciler{Client: c, Image: "inventory:latest", ReportURL: "http://10.1.1.1:8082"}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(hw)}
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	mux.Handle(hardware.InventoryReportPattern, &hardware.InventoryReportHandler{Client: c})
+
+	scan := func(inventory string) {
+		_, err := r.Reconcile(ctx, req)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		secret := &corev1.Secret{}
+		g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "hw-inventory-token"}, secret)).
+			To(Succeed())
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost,
+			"/inventory/default/hw/"+string(secret.Data[machine.ReportTokenKey]), strings.NewReader(inventory)))
+		g.Expect(w.Code).To(Equal(http.StatusNoContent))
+
+		_, err = r.Reconcile(ctx, req)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(c.Get(ctx, req.NamespacedName, hw)).To(Succeed())
+	}
+
+	scan(`{"cpus": 8, "disks": [{"device": "/dev/sda", "sizeBytes": 1000}]}`)
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(hardware.HardwareCPUsAnnotation, "8"))
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(machine.HardwareDisksAnnotation, userDisks),
+		"Expected the disks set by users to be kept")
+
+	delete(hw.Annotations, machine.HardwareDisksAnnotation)
+	hw.Annotations[hardware.HardwareInventoryScanAnnotation] = "true"
+	g.Expect(c.Update(ctx, hw)).To(Succeed())
+
+	scan(`{"disks": [{"device": "/dev/sda", "sizeBytes": 1000}]}`)
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(machine.HardwareDisksAnnotation,
+		`[{"device":"/dev/sda","sizeBytes":1000}]`))
+
+	hw.Annotations[hardware.HardwareInventoryScanAnnotation] = "true"
+	g.Expect(c.Update(ctx, hw)).To(Succeed())
+
+	scan(`{"disks": [{"device": "/dev/nvme0n1", "sizeBytes": 2000}]}`)
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(machine.HardwareDisksAnnotation,
+		`[{"device":"/dev/nvme0n1","sizeBytes":2000}]`), "Expected disks recorded by a scan to be replaced")
+}
@@ -0,0 +1,126 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hardware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+// LeaseReconciler releases claimed Hardware whose provisioning lease expired after the TinkerbellMachine it was
+// claimed for is gone, e.g. when the TinkerbellMachine was removed without its finalizer running, so abandoned
+// Hardware returns to the pool. Hardware of existing TinkerbellMachines is never released, as their provider ID
+// can not change; an event reports their expired lease instead.
+type LeaseReconciler struct {
+	client.Client
+
+	// Duration is how long claimed Hardware may go without provisioning progress before its lease expires.
+	Duration time.Duration
+}
+
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachines,verbs=get;list;watch
+
+// Reconcile releases the Hardware once its lease expired and the TinkerbellMachine it was claimed for is gone.
+func (r *LeaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	hw := &tinkv1.Hardware{}
+	if err := r.Client.Get(ctx, req.NamespacedName, hw); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("getting Hardware: %w", err)
+	}
+
+	if !hw.DeletionTimestamp.IsZero() || hardwareutil.Paused(hw) {
+		return ctrl.Result{}, nil
+	}
+
+	expiresAt, leased, err := machine.HardwareLeaseExpiry(hw, r.Duration)
+	if err != nil || !leased {
+		return ctrl.Result{}, err
+	}
+
+	if remaining := time.Until(expiresAt); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	log := ctrl.LoggerFrom(ctx)
+
+	owner := types.NamespacedName{
+		Name:      hw.Labels[machine.HardwareOwnerNameLabel],
+		Namespace: hw.Labels[machine.HardwareOwnerNamespaceLabel],
+	}
+
+	err = r.Client.Get(ctx, owner, &infrastructurev1.TinkerbellMachine{})
+
+	switch {
+	case err == nil:
+		log.Info("Provisioning lease of Hardware expired, keeping it for its TinkerbellMachine", "owner", owner)
+		record.Warnf(hw, "ProvisioningLeaseExpired",
+			"Provisioning of TinkerbellMachine %s made no progress since %s", owner, expiresAt.Add(-r.Duration))
+
+		return ctrl.Result{RequeueAfter: r.Duration}, nil
+	case !apierrors.IsNotFound(err):
+		return ctrl.Result{}, fmt.Errorf("getting TinkerbellMachine %s: %w", owner, err)
+	}
+
+	patchHelper, err := patch.NewHelper(hw, r.Client)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("initializing patch helper for Hardware: %w", err)
+	}
+
+	machine.ClearHardwareOwnership(hw)
+	hw.Spec.UserData = nil
+
+	if err := patchHelper.Patch(ctx, hw); err != nil {
+		return ctrl.Result{}, fmt.Errorf("patching Hardware: %w", err)
+	}
+
+	log.Info("Released Hardware with expired provisioning lease of missing TinkerbellMachine", "owner", owner)
+	record.Eventf(hw, "ProvisioningLeaseExpired",
+		"Released Hardware claimed for missing TinkerbellMachine %s after its provisioning lease expired", owner)
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager configures reconciler with a given manager.
+func (r *LeaseReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("hardwarelease").
+		WithOptions(options).
+		For(&tinkv1.Hardware{}).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package hardware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/hardware"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_LeaseReconciler(t *testing.T) {
+	t.Parallel()
+
+	const duration = time.Hour
+
+	leasedHardware := func(renewedAgo time.Duration) *tinkv1.Hardware {
+		return &tinkv1.Hardware{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hw",
+				Namespace: "default",
+				Labels: map[string]string{
+					machine.HardwareOwnerNameLabel:      "machine",
+					machine.HardwareOwnerNamespaceLabel: "default",
+				},
+				Annotations: map[string]string{
+					machine.HardwareLeaseAnnotation: time.Now().Add(-renewedAgo).UTC().Format(time.RFC3339),
+				},
+			},
+			Spec: tinkv1.HardwareSpec{UserData: ptr.To("#cloud-config")},
+		}
+	}
+
+	tests := map[string]struct {
+		hw           *tinkv1.Hardware
+		ownerExists  bool
+		wantReleased bool
+		wantRequeue  bool
+	}{
+		"lease not expired": {
+			hw:          leasedHardware(time.Minute),
+			wantRequeue: true,
+		},
+		"lease expired with existing owner": {
+			hw:          leasedHardware(2 * duration),
+			ownerExists: true,
+			wantRequeue: true,
+		},
+		"lease expired with missing owner": {
+			hw:           leasedHardware(2 * duration),
+			wantReleased: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(infrastructurev1.AddToScheme(scheme)).To(Succeed())
+
+			objects := []client.Object{tc.hw}
+			if tc.ownerExists {
+				objects = append(objects, &infrastructurev1.TinkerbellMachine{
+					ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+				})
+			}
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+			r := &hardware.LeaseReconciler{Client: c, Duration: duration}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tc.hw)}
+			ctx := context.Background()
+
+			result, err := r.Reconcile(ctx, req)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.RequeueAfter > 0).To(Equal(tc.wantRequeue))
+
+			hw := &tinkv1.Hardware{}
+			g.Expect(c.Get(ctx, req.NamespacedName, hw)).To(Succeed())
+
+			if tc.wantReleased {
+				g.Expect(hw.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel))
+				g.Expect(hw.Annotations).NotTo(HaveKey(machine.HardwareLeaseAnnotation))
+				g.Expect(hw.Spec.UserData).To(BeNil())
+			} else {
+				g.Expect(hw.Labels).To(HaveKey(machine.HardwareOwnerNameLabel))
+				g.Expect(hw.Spec.UserData).NotTo(BeNil())
+			}
+		})
+	}
+}
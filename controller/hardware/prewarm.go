@@ -0,0 +1,203 @@
+package hardware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+const (
+	// prewarmWorkflowSuffix is appended to the name of Hardware to name the Template and Workflow pre-warming it.
+	prewarmWorkflowSuffix = machine.HardwarePrewarmWorkflowSuffix
+
+	prewarmTemplate = `
+version: "0.1"
+name: {{.Name}}
+global_timeout: 3600
+tasks:
+  - name: "prewarm"
+    worker: "{{"{{"}}.device_1{{"}}"}}"
+    volumes:
+      - /dev:/dev
+    actions:
+      - name: "prewarm image"
+        image: {{.Image}}
+        timeout: 3000
+        environment:
+          IMAGE_URL: {{printf "%q" .ImageURL}}
+          DEST_DISK: {{printf "%q" .DestDisk}}
+`
+)
+
+// ImagePrewarmReconciler pre-warms Hardware annotated with machine.HardwarePrewarmImageAnnotation with the image
+// it names, without provisioning it: a Workflow netboots the Hardware and runs an action fetching the image. The
+// Hardware is annotated with machine.HardwarePrewarmedImageAnnotation once the action succeeded, so machines
+// provisioned with the image prefer it, e.g. during rolling updates.
+type ImagePrewarmReconciler struct {
+	client.Client
+
+	// Image is the image of the action pre-warming the Hardware. It fetches the image at the URL in its IMAGE_URL
+	// environment variable, optionally staging it on the disk in its DEST_DISK environment variable, and fails when
+	// the image cannot be fetched.
+	Image string
+}
+
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=templates;workflows,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile starts pre-warming the Hardware when it is requested, records the outcome of the pre-warm Workflow and
+// removes its Template and Workflow once finished.
+func (r *ImagePrewarmReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	hw := &tinkv1.Hardware{}
+	if err := r.Client.Get(ctx, req.NamespacedName, hw); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("getting Hardware: %w", err)
+	}
+
+	if hardwareutil.Paused(hw) {
+		return ctrl.Result{}, nil
+	}
+
+	imageURL := hw.Annotations[machine.HardwarePrewarmImageAnnotation]
+	if imageURL == "" || !hw.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.removePrewarm(ctx, hw)
+	}
+
+	if owner := hw.Labels[machine.HardwareOwnerNameLabel]; owner != "" {
+		record.Warnf(hw, "ImagePrewarmIgnored", "Not pre-warming Hardware claimed by %s", owner)
+
+		return ctrl.Result{}, r.finishPrewarm(ctx, hw, nil)
+	}
+
+	wf := &tinkv1.Workflow{}
+
+	key := types.NamespacedName{Namespace: hw.Namespace, Name: hw.Name + prewarmWorkflowSuffix}
+	if err := r.Client.Get(ctx, key, wf); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("getting pre-warm Workflow: %w", err)
+		}
+
+		return ctrl.Result{}, r.startPrewarm(ctx, hw, imageURL)
+	}
+
+	// Another image was requested since the Workflow was started, it is started again with the new one.
+	if wf.Annotations[machine.HardwarePrewarmImageAnnotation] != imageURL {
+		return ctrl.Result{Requeue: true}, r.removePrewarm(ctx, hw)
+	}
+
+	switch wf.Status.State { //nolint:exhaustive
+	case tinkv1.WorkflowStateSuccess:
+		record.Eventf(hw, "ImagePrewarmed", "Pre-warmed image %s", imageURL)
+
+		return ctrl.Result{}, r.finishPrewarm(ctx, hw, map[string]string{
+			machine.HardwarePrewarmedImageAnnotation: imageURL,
+			machine.HardwarePrewarmedAtAnnotation:    time.Now().UTC().Format(time.RFC3339),
+		})
+	case tinkv1.WorkflowStateFailed, tinkv1.WorkflowStateTimeout:
+		record.Warnf(hw, "ImagePrewarmFailed", "Pre-warm Workflow %s: %s", wf.Status.State, wf.Status.CurrentAction)
+
+		return ctrl.Result{}, r.finishPrewarm(ctx, hw, nil)
+	default:
+		return ctrl.Result{}, nil
+	}
+}
+
+// startPrewarm creates the Template and Workflow pre-warming the Hardware with the image, owned by the Hardware.
+func (r *ImagePrewarmReconciler) startPrewarm(ctx context.Context, hw *tinkv1.Hardware, imageURL string) error {
+	device, err := workerDevice(hw)
+	if err != nil {
+		record.Warnf(hw, "ImagePrewarmFailed", "Not pre-warming Hardware: %v", err)
+
+		return r.finishPrewarm(ctx, hw, nil)
+	}
+
+	destDisk := ""
+	if len(hw.Spec.Disks) > 0 {
+		destDisk = hw.Spec.Disks[0].Device
+	}
+
+	name := hw.Name + prewarmWorkflowSuffix
+
+	var data bytes.Buffer
+	if err := template.Must(template.New("prewarm").Parse(prewarmTemplate)).Execute(&data, map[string]string{
+		"Name":     name,
+		"Image":    r.Image,
+		"ImageURL": imageURL,
+		"DestDisk": destDisk,
+	}); err != nil {
+		return fmt.Errorf("rendering pre-warm Template: %w", err)
+	}
+
+	if err := createHardwareWorkflow(ctx, r.Client, hw, name, device, data.String(), map[string]string{
+		machine.HardwarePrewarmImageAnnotation: imageURL,
+	}); err != nil {
+		return err
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Started image pre-warm", "workflow", name, "image", imageURL)
+	record.Eventf(hw, "ImagePrewarmStarted", "Pre-warming image %s with Workflow %s", imageURL, name)
+
+	return nil
+}
+
+// finishPrewarm removes the pre-warm annotation of the Hardware, sets the given annotations on it, and removes the
+// Template and Workflow pre-warming it.
+func (r *ImagePrewarmReconciler) finishPrewarm(
+	ctx context.Context,
+	hw *tinkv1.Hardware,
+	annotations map[string]string,
+) error {
+	patchHelper, err := patch.NewHelper(hw, r.Client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for Hardware: %w", err)
+	}
+
+	delete(hw.Annotations, machine.HardwarePrewarmImageAnnotation)
+
+	for k, v := range annotations {
+		hw.Annotations[k] = v
+	}
+
+	if err := patchHelper.Patch(ctx, hw); err != nil {
+		return fmt.Errorf("patching Hardware: %w", err)
+	}
+
+	return r.removePrewarm(ctx, hw)
+}
+
+// removePrewarm removes the Template and Workflow pre-warming the Hardware, if any.
+func (r *ImagePrewarmReconciler) removePrewarm(ctx context.Context, hw *tinkv1.Hardware) error {
+	return removeHardwareWorkflow(ctx, r.Client, hw, hw.Name+prewarmWorkflowSuffix)
+}
+
+// SetupWithManager configures reconciler with a given manager.
+func (r *ImagePrewarmReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("hardwareimageprewarm").
+		WithOptions(options).
+		For(&tinkv1.Hardware{}).
+		Owns(&tinkv1.Workflow{}).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
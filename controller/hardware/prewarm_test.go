@@ -0,0 +1,113 @@
+package hardware_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/hardware"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_ImagePrewarm(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	const imageURL = "http://10.1.1.1:8080/ubuntu-2204-kube-v1.30.0.gz"
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "hw",
+			Namespace:   "default",
+			Annotations: map[string]string{machine.HardwarePrewarmImageAnnotation: imageURL},
+		},
+		Spec: tinkv1.HardwareSpec{
+			Disks:      []tinkv1.Disk{{Device: "/dev/nvme0n1"}},
+			Interfaces: []tinkv1.Interface{{DHCP: &tinkv1.DHCP{MAC: "aa:bb:cc:dd:ee:ff"}}},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hw).Build()
+	r := &hardware.ImagePrewarmReconciler{Client: c, Image: "prewarm:latest"}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(hw)}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	key := types.NamespacedName{Namespace: "default", Name: "hw-prewarm"}
+
+	wf := &tinkv1.Workflow{}
+	g.Expect(c.Get(ctx, key, wf)).To(Succeed())
+	g.Expect(wf.Spec.HardwareMap).To(HaveKeyWithValue("device_1", "aa:bb:cc:dd:ee:ff"))
+	g.Expect(metav1.IsControlledBy(wf, hw)).To(BeTrue())
+
+	tmpl := &tinkv1.Template{}
+	g.Expect(c.Get(ctx, key, tmpl)).To(Succeed())
+	g.Expect(*tmpl.Spec.Data).To(ContainSubstring("image: prewarm:latest"))
+	g.Expect(*tmpl.Spec.Data).To(ContainSubstring(`IMAGE_URL: "` + imageURL + `"`))
+	g.Expect(*tmpl.Spec.Data).To(ContainSubstring(`DEST_DISK: "/dev/nvme0n1"`))
+
+	wf.Status.State = tinkv1.WorkflowStateSuccess
+	g.Expect(c.Update(ctx, wf)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(c.Get(ctx, req.NamespacedName, hw)).To(Succeed())
+	g.Expect(hw.Annotations).NotTo(HaveKey(machine.HardwarePrewarmImageAnnotation))
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(machine.HardwarePrewarmedImageAnnotation, imageURL))
+	g.Expect(hw.Annotations).To(HaveKey(machine.HardwarePrewarmedAtAnnotation))
+	g.Expect(c.Get(ctx, key, &tinkv1.Workflow{})).NotTo(Succeed(), "Expected the Workflow to be removed")
+	g.Expect(c.Get(ctx, key, &tinkv1.Template{})).NotTo(Succeed(), "Expected the Template to be removed")
+}
+
+func Test_ImagePrewarm_failure_is_not_recorded(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "hw",
+			Namespace:   "default",
+			Annotations: map[string]string{machine.HardwarePrewarmImageAnnotation: "http://10.1.1.1:8080/missing.gz"},
+		},
+		Spec: tinkv1.HardwareSpec{
+			Interfaces: []tinkv1.Interface{{DHCP: &tinkv1.DHCP{MAC: "aa:bb:cc:dd:ee:ff"}}},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hw).Build()
+	r := &hardware.ImagePrewarmReconciler{Client: c, Image: "prewarm:latest"}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(hw)}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	wf := &tinkv1.Workflow{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "hw-prewarm"}, wf)).To(Succeed())
+	wf.Status.State = tinkv1.WorkflowStateFailed
+	g.Expect(c.Update(ctx, wf)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(c.Get(ctx, req.NamespacedName, hw)).To(Succeed())
+	g.Expect(hw.Annotations).NotTo(HaveKey(machine.HardwarePrewarmImageAnnotation))
+	g.Expect(hw.Annotations).NotTo(HaveKey(machine.HardwarePrewarmedImageAnnotation))
+}
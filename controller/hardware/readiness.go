@@ -0,0 +1,100 @@
+// Package hardware contains controllers maintaining Hardware independently of the TinkerbellMachines claiming it.
+package hardware
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+// ReadinessReconciler annotates Hardware with the result of the checks CAPT runs before claiming it for a
+// machine, so Hardware which cannot be provisioned is visible, with the reasons, before any machine selects it.
+type ReadinessReconciler struct {
+	client.Client
+}
+
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware,verbs=get;list;watch;patch
+
+// Reconcile sets the readiness annotations of the Hardware.
+func (r *ReadinessReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	hw := &tinkv1.Hardware{}
+	if err := r.Client.Get(ctx, req.NamespacedName, hw); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("getting Hardware: %w", err)
+	}
+
+	if !hw.DeletionTimestamp.IsZero() || hardwareutil.Paused(hw) {
+		return ctrl.Result{}, nil
+	}
+
+	if err := machine.ResolveStatusInterfaces(ctx, r.Client, hw); err != nil {
+		return ctrl.Result{}, fmt.Errorf("resolving Hardware interfaces: %w", err)
+	}
+
+	problems := machine.HardwareReadiness(hw)
+	ready := strconv.FormatBool(problems == nil)
+
+	reason := ""
+	if problems != nil {
+		reason = problems.Error()
+	}
+
+	annotations := hw.GetAnnotations()
+	if annotations[machine.HardwareProvisioningReadyAnnotation] == ready &&
+		annotations[machine.HardwareNotReadyReasonAnnotation] == reason {
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(hw, r.Client)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("initializing patch helper for Hardware: %w", err)
+	}
+
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[machine.HardwareProvisioningReadyAnnotation] = ready
+
+	if reason == "" {
+		delete(annotations, machine.HardwareNotReadyReasonAnnotation)
+	} else {
+		annotations[machine.HardwareNotReadyReasonAnnotation] = reason
+	}
+
+	hw.SetAnnotations(annotations)
+
+	if err := patchHelper.Patch(ctx, hw); err != nil {
+		return ctrl.Result{}, fmt.Errorf("patching Hardware: %w", err)
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Updated Hardware provisioning readiness", "ready", ready, "reason", reason)
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager configures reconciler with a given manager.
+func (r *ReadinessReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("hardwarereadiness").
+		WithOptions(options).
+		For(&tinkv1.Hardware{}).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
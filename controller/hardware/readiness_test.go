@@ -0,0 +1,55 @@
+package hardware_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/hardware"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_ReadinessReconciler(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"},
+		Spec: tinkv1.HardwareSpec{
+			Interfaces: []tinkv1.Interface{{DHCP: &tinkv1.DHCP{IP: &tinkv1.IP{Address: "10.0.0.2"}}}},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hw).Build()
+	r := &hardware.ReadinessReconciler{Client: c}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(hw)}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(c.Get(ctx, req.NamespacedName, hw)).To(Succeed())
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(machine.HardwareProvisioningReadyAnnotation, "false"))
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(machine.HardwareNotReadyReasonAnnotation,
+		machine.ErrHardwareMissingDiskConfiguration.Error()))
+
+	hw.Spec.Disks = []tinkv1.Disk{{Device: "/dev/sda"}}
+	g.Expect(c.Update(ctx, hw)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(c.Get(ctx, req.NamespacedName, hw)).To(Succeed())
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(machine.HardwareProvisioningReadyAnnotation, "true"))
+	g.Expect(hw.Annotations).NotTo(HaveKey(machine.HardwareNotReadyReasonAnnotation))
+}
@@ -0,0 +1,184 @@
+package machine
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+const (
+	// actionImagesLoaderName is the name of the action loading the action bundle and pulling missing action images.
+	actionImagesLoaderName = "load action images"
+
+	// defaultActionImagesLoader is the image of the loader action of clusters which do not set one.
+	defaultActionImagesLoader = "docker:27-cli"
+
+	// waitdaemonRepository is the repository of the action running the image in its IMAGE environment variable
+	// after the workflow finished, e.g. to kexec into the OS.
+	waitdaemonRepository = "waitdaemon"
+)
+
+// actionImagesLoader is the action loading the action bundle and pulling missing action images, in the order its
+// fields are rendered.
+type actionImagesLoader struct {
+	Name    string   `yaml:"name"`
+	Image   string   `yaml:"image"`
+	Timeout int      `yaml:"timeout"`
+	Command []string `yaml:"command"`
+	Volumes []string `yaml:"volumes"`
+}
+
+// mirrorImage returns the given image pulled from the registry instead of its own, keeping its repository path.
+// Images of Docker Hub without a namespace are in its library namespace. Images with template actions, rendered by
+// Tinkerbell, are returned as they are.
+func mirrorImage(image, registry string) string {
+	if registry == "" || image == "" || strings.Contains(image, "{{") {
+		return image
+	}
+
+	repository := image
+
+	host, rest, ok := strings.Cut(image, "/")
+	if ok && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		repository = rest
+
+		if host != "docker.io" && host != "index.docker.io" {
+			return registry + "/" + repository
+		}
+	}
+
+	if !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+
+	return registry + "/" + repository
+}
+
+// imageRepositoryName returns the last element of the repository path of the image, without tag or digest.
+func imageRepositoryName(image string) string {
+	name, _, _ := strings.Cut(path.Base(image), "@")
+	name, _, _ = strings.Cut(name, ":")
+
+	return name
+}
+
+// shellQuote returns s quoted for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// applyActionImages pulls the images of every action of the Tinkerbell template data from the registry of the given
+// configuration, including the image run by waitdaemon actions, and adds the action loading the action bundle and
+// pulling the images missing in Hook as first action of the first task when the configuration asks for it.
+func applyActionImages(data string, images *infrastructurev1.ActionImages) (string, error) {
+	if images == nil {
+		return data, nil
+	}
+
+	loads := images.BundleURL != "" || images.PullPolicy == infrastructurev1.ActionImagePullIfNotPresent
+	if images.Registry == "" && !loads {
+		return data, nil
+	}
+
+	doc, tasks, err := parseTemplate(data)
+	if err != nil {
+		return "", err
+	}
+
+	pulled := []string{}
+	seen := map[string]bool{}
+
+	pull := func(image string) {
+		if image != "" && !seen[image] && !strings.Contains(image, "{{") {
+			seen[image] = true
+			pulled = append(pulled, image)
+		}
+	}
+
+	forEachAction(tasks, func(_ string, action *yaml.Node) {
+		image := mappingValue(action, "image")
+		if image == nil || image.Kind != yaml.ScalarNode {
+			return
+		}
+
+		image.Value = mirrorImage(image.Value, images.Registry)
+		pull(image.Value)
+
+		if imageRepositoryName(image.Value) != waitdaemonRepository {
+			return
+		}
+
+		if run := mappingValue(mappingValue(action, "environment"), "IMAGE"); run != nil && run.Kind == yaml.ScalarNode {
+			run.Value = mirrorImage(run.Value, images.Registry)
+			pull(run.Value)
+		}
+	})
+
+	if loads {
+		if err := addActionImagesLoader(tasks, images, pulled); err != nil {
+			return "", err
+		}
+	}
+
+	return encodeTemplate(doc)
+}
+
+// addActionImagesLoader adds the action loading the action bundle and, with the IfNotPresent pull policy, pulling
+// the given images missing in Hook, as first action of the first task.
+func addActionImagesLoader(tasks *yaml.Node, images *infrastructurev1.ActionImages, pulled []string) error {
+	if len(tasks.Content) == 0 {
+		return fmt.Errorf("%w: template has no tasks", ErrMalformedTemplate)
+	}
+
+	actions := mappingValue(tasks.Content[0], "actions")
+	if actions == nil || actions.Kind != yaml.SequenceNode {
+		return fmt.Errorf("%w: actions must be a list", ErrMalformedTemplate)
+	}
+
+	script := &strings.Builder{}
+	script.WriteString("set -e\n")
+
+	if images.BundleURL != "" {
+		fmt.Fprintf(script, "wget -qO- %s | docker load\n", shellQuote(escapeTemplateActions(images.BundleURL)))
+	}
+
+	if images.PullPolicy == infrastructurev1.ActionImagePullIfNotPresent {
+		for _, image := range pulled {
+			fmt.Fprintf(script, "docker image inspect %[1]s >/dev/null 2>&1 || docker pull %[1]s\n",
+				shellQuote(image))
+		}
+	}
+
+	loader := images.LoaderImage
+	if loader == "" {
+		loader = defaultActionImagesLoader
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(actionImagesLoader{
+		Name:    actionImagesLoaderName,
+		Image:   mirrorImage(loader, images.Registry),
+		Timeout: 1800, //nolint:gomnd
+		Command: []string{"sh", "-c", script.String()},
+		Volumes: []string{"/var/run/docker.sock:/var/run/docker.sock"},
+	}); err != nil {
+		return fmt.Errorf("encoding action images loader action: %w", err)
+	}
+
+	actions.Content = append([]*yaml.Node{node}, actions.Content...)
+
+	return nil
+}
+
+// actionImages returns the action images configuration of the cluster of the machine, if any.
+func (scope *machineReconcileScope) actionImages() *infrastructurev1.ActionImages {
+	if scope.tinkerbellCluster == nil {
+		return nil
+	}
+
+	return scope.tinkerbellCluster.Spec.ActionImages
+}
@@ -0,0 +1,46 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+)
+
+func Test_mirrorImage(t *testing.T) {
+	t.Parallel()
+
+	const registry = "10.1.1.1:5000"
+
+	tests := map[string]struct {
+		image    string
+		registry string
+		want     string
+	}{
+		"no registry": {image: "quay.io/tinkerbell/actions/writefile", want: "quay.io/tinkerbell/actions/writefile"},
+		"registry host": {
+			image: "quay.io/tinkerbell/actions/writefile", registry: registry,
+			want: "10.1.1.1:5000/tinkerbell/actions/writefile",
+		},
+		"registry host and port": {
+			image: "localhost:5000/actions/kexec:v1", registry: registry, want: "10.1.1.1:5000/actions/kexec:v1",
+		},
+		"registry path": {
+			image: "ghcr.io/a/b@sha256:abc", registry: registry + "/mirror", want: "10.1.1.1:5000/mirror/a/b@sha256:abc",
+		},
+		"docker hub namespace": {
+			image: "curlimages/curl:8.11.1", registry: registry, want: "10.1.1.1:5000/curlimages/curl:8.11.1",
+		},
+		"docker hub library":     {image: "alpine", registry: registry, want: "10.1.1.1:5000/library/alpine"},
+		"docker hub host":        {image: "docker.io/alpine:3", registry: registry, want: "10.1.1.1:5000/library/alpine:3"},
+		"rendered by Tinkerbell": {image: "{{.image}}", registry: registry, want: "{{.image}}"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			g.Expect(mirrorImage(tc.image, tc.registry)).To(Equal(tc.want))
+		})
+	}
+}
@@ -2,91 +2,424 @@ package machine
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"time"
 
 	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
 	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"go.opentelemetry.io/otel/attribute"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+const (
+	// BMCJobOwnerLabel is set on BMC Jobs created by CAPT to the name of the owning TinkerbellMachine, shortened and
+	// suffixed with a hash of it when longer than 63 characters.
+	BMCJobOwnerLabel = "tinkerbellmachine.infrastructure.cluster.x-k8s.io/owner-name"
+
+	// BMCJobOperationLabel is set on BMC Jobs created by CAPT to the operation performed by the Job.
+	BMCJobOperationLabel = "tinkerbellmachine.infrastructure.cluster.x-k8s.io/bmc-job-operation"
+
+	// BMCJobAttemptAnnotation is set on BMC Jobs created by CAPT to the number of Jobs created for the same
+	// operation of the TinkerbellMachine before it, through other BMCs or by retries.
+	BMCJobAttemptAnnotation = "tinkerbellmachine.infrastructure.cluster.x-k8s.io/bmc-job-attempt"
+
+	// bmcJobOperationPowerOff is the operation of BMC Jobs powering off the hardware on machine deletion.
+	bmcJobOperationPowerOff = "poweroff"
+
+	// bmcJobOperationEjectMedia is the operation of BMC Jobs ejecting the provisioning ISO once the hardware
+	// booting from virtual media is provisioned.
+	bmcJobOperationEjectMedia = "eject-media"
 )
 
-// createPowerOffJob creates a BMCJob object with the required tasks for hardware power off.
-func (scope *machineReconcileScope) createPowerOffJob(hw *tinkv1.Hardware) error {
-	controller := true
+// legacyBMCJobOperations are the operations of the BMC Jobs created before they were labeled, which were named
+// after the TinkerbellMachine suffixed with the operation.
+//
+//nolint:gochecknoglobals
+var legacyBMCJobOperations = []string{bmcJobOperationPowerOff, "provision"}
+
+// bmcJobFinished returns true when the BMC Job either completed or failed.
+func bmcJobFinished(job *rufiov1.Job) bool {
+	return job.HasCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue) ||
+		job.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue)
+}
+
+// listBMCJobs returns the BMC Jobs performing the given operation for the TinkerbellMachine, newest first. An empty
+// operation returns the Jobs of all operations.
+//
+// Jobs are matched on the owner UID, so Jobs left behind by a previous TinkerbellMachine with the same name, or by a
+// TinkerbellMachine of the same name in another namespace sharing a HardwarePool, are never mistaken for the Jobs of
+// the current one.
+func (scope *machineReconcileScope) listBMCJobs(operation string) ([]rufiov1.Job, error) {
+	owned, _, err := scope.listBMCJobsByOwner(operation)
+
+	return owned, err
+}
+
+// listBMCJobsByOwner returns the BMC Jobs performing the given operation for the TinkerbellMachine, newest first,
+// and the stale Jobs left behind by a previous TinkerbellMachine of the same name and namespace.
+func (scope *machineReconcileScope) listBMCJobsByOwner(operation string) (owned, stale []rufiov1.Job, err error) {
+	selector := client.MatchingLabels{BMCJobOwnerLabel: scope.bmcJobOwnerLabelValue()}
+	if operation != "" {
+		selector[BMCJobOperationLabel] = operation
+	}
+
+	jobs := &rufiov1.JobList{}
+	if err := scope.client.List(scope.ctx, jobs, client.InNamespace(scope.hardwareNamespace()), selector); err != nil {
+		return nil, nil, fmt.Errorf("listing BMCJobs: %w", err)
+	}
+
+	legacy, err := scope.legacyBMCJobs(operation)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jobs.Items = append(jobs.Items, legacy...)
+
+	for i := range jobs.Items {
+		switch job := &jobs.Items[i]; {
+		case scope.ownedBy(job):
+			owned = append(owned, *job)
+		case scope.previouslyOwnedBy(job):
+			stale = append(stale, *job)
+		}
+	}
+
+	sort.SliceStable(owned, func(i, j int) bool {
+		return owned[j].CreationTimestamp.Before(&owned[i].CreationTimestamp)
+	})
+
+	return owned, stale, nil
+}
+
+// legacyBMCJobs returns the BMC Jobs performing the given operation, or all operations when empty, created for a
+// TinkerbellMachine of the same name before BMC Jobs were labeled. Their operation label is set from their name, so
+// they are handled like the Jobs created since.
+func (scope *machineReconcileScope) legacyBMCJobs(operation string) ([]rufiov1.Job, error) {
+	var jobs []rufiov1.Job
+
+	for _, op := range legacyBMCJobOperations {
+		if operation != "" && operation != op {
+			continue
+		}
+
+		job := rufiov1.Job{}
+		key := client.ObjectKey{Namespace: scope.hardwareNamespace(), Name: scope.tinkerbellMachine.Name + "-" + op}
+
+		if err := scope.client.Get(scope.ctx, key, &job); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("getting BMCJob %s: %w", key, err)
+		}
+
+		if _, ok := job.Labels[BMCJobOperationLabel]; ok {
+			continue
+		}
+
+		if job.Labels == nil {
+			job.Labels = map[string]string{}
+		}
+
+		job.Labels[BMCJobOperationLabel] = op
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// bmcJobOwnerLabelValue returns the value of the BMCJobOwnerLabel of the BMC Jobs of the TinkerbellMachine.
+func (scope *machineReconcileScope) bmcJobOwnerLabelValue() string {
+	return boundedLabelValue(scope.tinkerbellMachine.Name)
+}
+
+// bmcJobAttempt returns the attempt recorded in the BMCJobAttemptAnnotation of the given BMC Job, zero when it has
+// none.
+func bmcJobAttempt(job *rufiov1.Job) int {
+	attempt, err := strconv.Atoi(job.Annotations[BMCJobAttemptAnnotation])
+	if err != nil {
+		return 0
+	}
+
+	return attempt
+}
+
+// previouslyOwnedBy returns true when the given BMC Job, not owned by the TinkerbellMachine, was created for a
+// previous TinkerbellMachine of the same name and namespace. Jobs of pooled Hardware may belong to machines of the
+// same name in other namespaces, which are told apart by their owner namespace label.
+func (scope *machineReconcileScope) previouslyOwnedBy(job *rufiov1.Job) bool {
+	if _, ok := job.Labels[OwnerUIDLabel]; ok {
+		return job.Labels[HardwareOwnerNamespaceLabel] == scope.tinkerbellMachine.Namespace
+	}
+
+	for _, ref := range job.OwnerReferences {
+		if ref.Kind == "TinkerbellMachine" && ref.Name == scope.tinkerbellMachine.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ensureBMCJob returns the BMC Job performing the given operation for the TinkerbellMachine, creating it with the
+// given tasks when it does not exist yet. Duplicate Jobs for the same operation are removed, keeping the newest.
+// A failed Job is retried through the next rufio Machine of Hardware with several BMC paths, and once it failed
+// through all of them, with a back-off until the retry budget is exhausted.
+func (scope *machineReconcileScope) ensureBMCJob(operation string, hw *tinkv1.Hardware, tasks []rufiov1.Action) (_ *rufiov1.Job, reterr error) { //nolint:lll
+	end := scope.trace("EnsureBMCJob", attribute.String("bmc_job.operation", operation))
+	defer func() { end(reterr) }()
+
+	jobs, stale, err := scope.listBMCJobsByOwner(operation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Jobs of a deleted TinkerbellMachine are garbage collected eventually, but a machine recreated with the same
+	// name shortly after must neither wait for them nor act on their outcome.
+	for i := range stale {
+		scope.log.Info("Removing BMCJob of previous machine", "Name", stale[i].Name, "operation", operation)
+
+		if err := scope.client.Delete(scope.ctx, &stale[i]); err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("deleting BMCJob of previous machine: %w", err)
+		}
+	}
+
+	if len(jobs) > 0 {
+		for i := range jobs[1:] {
+			duplicate := &jobs[i+1]
+
+			scope.log.Info("Removing duplicate BMCJob", "Name", duplicate.Name, "operation", operation)
+
+			if err := scope.client.Delete(scope.ctx, duplicate); err != nil && !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("deleting duplicate BMCJob: %w", err)
+			}
+		}
+
+		if err := scope.recordSuccessfulBMC(hw, &jobs[0]); err != nil {
+			return nil, err
+		}
+
+		job, err := scope.fallBackBMCJob(operation, hw, &jobs[0], tasks)
+		if err != nil || job != &jobs[0] {
+			return job, err
+		}
+
+		return scope.retryBMCJob(operation, hw, job, tasks)
+	}
+
+	return scope.createBMCJob(operation, hardwareutil.BMCRefs(hw)[0], tasks, nil)
+}
+
+// createBMCJob creates a BMC Job performing the given tasks against the BMC reached through the given rufio Machine,
+// as the attempt following the given previous Job of the operation, or the first attempt without one.
+//
+// The Job is named after the machine, the operation and the attempt, with a hash of them and the UID of the machine,
+// so reconciliations racing on a stale cache create the same Job instead of duplicates, and never one of a previous
+// machine of the same name. The existing Job is returned when it was created already.
+func (scope *machineReconcileScope) createBMCJob(
+	operation, bmc string,
+	tasks []rufiov1.Action,
+	previous *rufiov1.Job,
+) (*rufiov1.Job, error) {
+	attempt := 0
+	if previous != nil {
+		attempt = bmcJobAttempt(previous) + 1
+	}
+
+	name := fmt.Sprintf("%s-%s-%d", scope.tinkerbellMachine.Name, operation, attempt)
+
 	bmcJob := &rufiov1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-poweroff", scope.tinkerbellMachine.Name),
-			Namespace: scope.tinkerbellMachine.Namespace,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
-					Kind:       "TinkerbellMachine",
-					Name:       scope.tinkerbellMachine.Name,
-					UID:        scope.tinkerbellMachine.ObjectMeta.UID,
-					Controller: &controller,
-				},
+			Name:      hashSuffixedName(name, string(scope.tinkerbellMachine.UID)+"/"+name),
+			Namespace: scope.hardwareNamespace(),
+			Labels: map[string]string{
+				BMCJobOwnerLabel:     scope.bmcJobOwnerLabelValue(),
+				BMCJobOperationLabel: operation,
+			},
+			Annotations: map[string]string{
+				BMCJobAttemptAnnotation: strconv.Itoa(attempt),
 			},
 		},
 		Spec: rufiov1.JobSpec{
 			MachineRef: rufiov1.MachineRef{
-				Name:      hw.Spec.BMCRef.Name,
-				Namespace: scope.tinkerbellMachine.Namespace,
-			},
-			Tasks: []rufiov1.Action{
-				{
-					PowerAction: rufiov1.PowerHardOff.Ptr(),
-				},
+				Name:      bmc,
+				Namespace: scope.hardwareNamespace(),
 			},
+			Tasks: tasks,
 		},
 	}
 
+	scope.setOwner(bmcJob, true)
+	scope.propagateMetadata(bmcJob)
+
 	if err := scope.client.Create(scope.ctx, bmcJob); err != nil {
-		return fmt.Errorf("creating BMCJob: %w", err)
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("creating BMCJob: %w", err)
+		}
+
+		if err := scope.client.Get(scope.ctx, client.ObjectKeyFromObject(bmcJob), bmcJob); err != nil {
+			return nil, fmt.Errorf("getting existing BMCJob: %w", err)
+		}
+
+		return bmcJob, nil
 	}
 
-	scope.log.Info("Created BMCJob to power off hardware",
+	scope.log.Info("Created BMCJob",
 		"Name", bmcJob.Name,
-		"Namespace", bmcJob.Namespace)
+		"Namespace", bmcJob.Namespace,
+		"operation", operation,
+		"bmc", bmc)
+
+	return bmcJob, nil
+}
+
+// cleanupFinishedBMCJobs removes BMC Jobs of the TinkerbellMachine which finished longer than ttl ago. The newest
+// Job of each operation is kept, as it reflects the current state of the operation. The machine is requeued for when
+// the next finished Job expires, as the Jobs themselves do not trigger a reconciliation once they finished.
+func (scope *machineReconcileScope) cleanupFinishedBMCJobs(ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	jobs, err := scope.listBMCJobs("")
+	if err != nil {
+		return err
+	}
+
+	newest := map[string]bool{}
+
+	for i := range jobs {
+		job := &jobs[i]
+		operation := job.Labels[BMCJobOperationLabel]
+
+		if !newest[operation] {
+			newest[operation] = true
+
+			continue
+		}
+
+		if !bmcJobFinished(job) {
+			continue
+		}
+
+		finishedAt := job.CreationTimestamp.Time
+		if job.Status.CompletionTime != nil {
+			finishedAt = job.Status.CompletionTime.Time
+		}
+
+		if remaining := ttl - time.Since(finishedAt); remaining > 0 {
+			scope.requeue(remaining)
+
+			continue
+		}
+
+		scope.log.Info("Removing finished BMCJob", "Name", job.Name, "operation", operation)
+
+		if err := scope.client.Delete(scope.ctx, job); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting finished BMCJob: %w", err)
+		}
+	}
 
 	return nil
 }
 
-// getJob fetches the Job by name.
-func (scope *machineReconcileScope) getJob(name string, job *rufiov1.Job) error {
-	namespacedName := types.NamespacedName{
-		Name:      name,
-		Namespace: scope.tinkerbellMachine.Namespace,
+// setBMCJobCondition reflects the state of the given BMC Job in the BMCJobSucceeded condition.
+func (scope *machineReconcileScope) setBMCJobCondition(operation string, job *rufiov1.Job) {
+	switch {
+	case job.HasCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue):
+		conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition)
+	case scope.bmcJobRetryPending(job):
+		retry := scope.bmcJobRetry(operation)
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition,
+			infrastructurev1.BMCJobRetryingReason, clusterv1.ConditionSeverityWarning,
+			"%s BMCJob %s failed, retrying (attempt %d of %d)", operation, job.Name, retry.Failures+1,
+			scope.bmcJobRetries+1)
+	case job.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue):
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition,
+			infrastructurev1.BMCJobFailedReason, clusterv1.ConditionSeverityError,
+			"%s BMCJob %s failed", operation, job.Name)
+	default:
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition,
+			infrastructurev1.BMCJobRunningReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for %s BMCJob %s to complete", operation, job.Name)
+	}
+}
+
+// recordBMCJobFailure counts the given BMC Job as a provisioning failure of the hardware if it failed and is not
+// retried.
+func (scope *machineReconcileScope) recordBMCJobFailure(hw *tinkv1.Hardware, job *rufiov1.Job) error {
+	if !job.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue) || scope.bmcJobRetryPending(job) {
+		return nil
 	}
 
-	if err := scope.client.Get(scope.ctx, namespacedName, job); err != nil {
-		return fmt.Errorf("GET BMCJob: %w", err)
+	msg := fmt.Sprintf("%s BMCJob %s failed", job.Labels[BMCJobOperationLabel], job.Name)
+
+	if err := scope.recordHardwareFailure(hw, failureKey("Job", job.Name, job.UID), msg); err != nil {
+		return fmt.Errorf("recording hardware failure: %w", err)
 	}
 
 	return nil
 }
 
-// ensureBMCJobCompletionForDelete ensures the machine power off BMCJob is completed.
-// Removes the machint finalizer to let machine delete.
-func (scope *machineReconcileScope) ensureBMCJobCompletionForDelete(hardware *tinkv1.Hardware) error {
-	// Fetch a poweroff BMCJob for the machine.
-	// If Job not found, we remove dependencies and create job.
-	bmcJob := &rufiov1.Job{}
-	jobName := fmt.Sprintf("%s-poweroff", scope.tinkerbellMachine.Name)
+// ejectVirtualMedia ensures the provisioning ISO is ejected from the virtual media of hardware booting in iso mode,
+// so it is not booted into again. The outcome is reported in the BMCJobSucceeded condition.
+func (scope *machineReconcileScope) ejectVirtualMedia(hw *tinkv1.Hardware) error {
+	if !scope.isoBoot() || !scope.managesPower(hw) {
+		return nil
+	}
 
-	if err := scope.getJob(jobName, bmcJob); err != nil {
-		if apierrors.IsNotFound(err) {
-			return scope.createPowerOffJob(hardware)
-		}
+	bmcJob, err := scope.ensureBMCJob(bmcJobOperationEjectMedia, hw, []rufiov1.Action{
+		{
+			VirtualMediaAction: &rufiov1.VirtualMediaAction{
+				Kind: rufiov1.VirtualMediaCD,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("ensuring eject media BMCJob: %w", err)
+	}
 
-		return fmt.Errorf("get bmc job for machine: %w", err)
+	scope.setBMCJobCondition(bmcJobOperationEjectMedia, bmcJob)
+
+	return scope.recordBMCJobFailure(hw, bmcJob)
+}
+
+// ensureBMCJobCompletionForDelete ensures the machine power off BMCJob is completed.
+// Removes the machine finalizer to let machine delete.
+func (scope *machineReconcileScope) ensureBMCJobCompletionForDelete(hardware *tinkv1.Hardware) error {
+	bmcJob, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hardware, []rufiov1.Action{
+		{
+			PowerAction: rufiov1.PowerHardOff.Ptr(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("ensuring power off BMCJob: %w", err)
 	}
 
+	scope.setBMCJobCondition(bmcJobOperationPowerOff, bmcJob)
+
 	// Check the Job conditions to ensure the power off job is complete.
 	if bmcJob.HasCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue) {
 		return scope.removeFinalizer()
 	}
 
-	if bmcJob.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue) {
+	if err := scope.patch(); err != nil {
+		return err
+	}
+
+	if bmcJob.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue) && !scope.bmcJobRetryPending(bmcJob) {
+		if err := scope.recordBMCJobFailure(hardware, bmcJob); err != nil {
+			return err
+		}
+
 		return fmt.Errorf("bmc job %s/%s failed", bmcJob.Namespace, bmcJob.Name) //nolint:goerr113
 	}
 
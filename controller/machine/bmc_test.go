@@ -0,0 +1,384 @@
+package machine //nolint:testpackage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func bmcJobTestScope(t *testing.T, objects ...runtime.Object) *machineReconcileScope {
+	t.Helper()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(rufiov1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrastructurev1.AddToScheme(scheme)).To(Succeed())
+
+	return &machineReconcileScope{
+		log: logr.Discard(),
+		ctx: context.Background(),
+		tinkerbellMachine: &infrastructurev1.TinkerbellMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default", UID: "uid-1"},
+		},
+		client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build(),
+	}
+}
+
+func bmcJob(name, ownerUID, operation string, created time.Time, finished bool) *rufiov1.Job {
+	job := &rufiov1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels: map[string]string{
+				BMCJobOwnerLabel:     "machine",
+				BMCJobOperationLabel: operation,
+			},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "TinkerbellMachine", Name: "machine", UID: types.UID(ownerUID)}},
+		},
+	}
+
+	if finished {
+		job.SetCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue)
+		job.Status.CompletionTime = &metav1.Time{Time: created}
+	}
+
+	return job
+}
+
+func Test_ensureBMCJob(t *testing.T) {
+	t.Parallel()
+
+	hw := &tinkv1.Hardware{Spec: tinkv1.HardwareSpec{BMCRef: &corev1.TypedLocalObjectReference{Name: "bmc"}}}
+
+	t.Run("creates_job_named_after_operation_and_attempt", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		scope := bmcJobTestScope(t)
+
+		job, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(job.Name).To(HavePrefix("machine-poweroff-0-"))
+		g.Expect(job.Labels).To(HaveKeyWithValue(BMCJobOperationLabel, bmcJobOperationPowerOff))
+
+		again, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(again.Name).To(Equal(job.Name), "Expected the existing job to be reused")
+	})
+
+	t.Run("ignores_jobs_of_previous_machine_with_same_name", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		scope := bmcJobTestScope(t, bmcJob("stale", "uid-0", bmcJobOperationPowerOff, time.Now(), true))
+
+		job, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(job.Name).NotTo(Equal("stale"))
+
+		err = scope.client.Get(scope.ctx, client.ObjectKey{Name: "stale", Namespace: "default"}, &rufiov1.Job{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the Job of the previous machine to be removed")
+	})
+
+	t.Run("keeps_pooled_jobs_of_machines_in_other_namespaces", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		other := bmcJob("other-namespace", "", bmcJobOperationPowerOff, time.Now(), true)
+		other.OwnerReferences = nil
+		other.Labels[OwnerUIDLabel] = "uid-2"
+		other.Labels[HardwareOwnerNamespaceLabel] = "other"
+
+		scope := bmcJobTestScope(t, other)
+
+		_, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(scope.client.Get(scope.ctx, client.ObjectKeyFromObject(other), &rufiov1.Job{})).To(Succeed())
+	})
+
+	t.Run("reuses_job_created_by_racing_reconciliation", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		scope := bmcJobTestScope(t)
+
+		first, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		// A stale cache does not list the Job created by the previous reconciliation yet.
+		scope.client = interceptor.NewClient(scope.client.(client.WithWatch), interceptor.Funcs{ //nolint:forcetypeassert
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				return nil
+			},
+		})
+
+		job, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(job.Name).To(Equal(first.Name), "Expected the existing job to be returned instead of a duplicate")
+	})
+
+	t.Run("bounds_owner_label_of_long_machine_names", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		scope := bmcJobTestScope(t)
+		scope.tinkerbellMachine.Name = strings.Repeat("m", 80)
+
+		job, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(len(job.Name)).To(BeNumerically("<=", 63))
+		g.Expect(len(job.Labels[BMCJobOwnerLabel])).To(BeNumerically("<=", 63))
+
+		again, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(again.Name).To(Equal(job.Name), "Expected the existing job to be reused")
+	})
+
+	t.Run("recognizes_jobs_created_before_they_were_labeled", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		legacy := bmcJob("machine-poweroff", "uid-1", bmcJobOperationPowerOff, time.Now(), true)
+		legacy.Labels = nil
+
+		scope := bmcJobTestScope(t, legacy)
+
+		job, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(job.Name).To(Equal("machine-poweroff"))
+	})
+
+	t.Run("removes_duplicate_jobs", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		now := time.Now()
+		scope := bmcJobTestScope(t,
+			bmcJob("older", "uid-1", bmcJobOperationPowerOff, now.Add(-time.Minute), false),
+			bmcJob("newer", "uid-1", bmcJobOperationPowerOff, now, false),
+		)
+
+		job, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(job.Name).To(Equal("newer"))
+
+		jobs, err := scope.listBMCJobs(bmcJobOperationPowerOff)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(jobs).To(HaveLen(1))
+	})
+}
+
+//nolint:funlen
+func Test_ensureBMCJob_falls_back_to_next_BMC(t *testing.T) {
+	t.Parallel()
+
+	hardware := func() *tinkv1.Hardware {
+		return &tinkv1.Hardware{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "hw",
+				Namespace:   "default",
+				Annotations: map[string]string{HardwareBMCRefsAnnotation: "redfish, ipmi"},
+			},
+			Spec: tinkv1.HardwareSpec{BMCRef: &corev1.TypedLocalObjectReference{Name: "redfish"}},
+		}
+	}
+
+	jobThrough := func(name, bmc string, created time.Time, condition rufiov1.JobConditionType) *rufiov1.Job {
+		job := bmcJob(name, "uid-1", bmcJobOperationPowerOff, created, false)
+		job.Spec.MachineRef.Name = bmc
+		job.SetCondition(condition, rufiov1.ConditionTrue)
+
+		return job
+	}
+
+	now := time.Now()
+
+	t.Run("retries_failed_job_through_next_bmc", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		hw := hardware()
+		scope := bmcJobTestScope(t, hw, jobThrough("redfish", "redfish", now, rufiov1.JobFailed))
+
+		job, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(job.Name).NotTo(Equal("redfish"))
+		g.Expect(job.Spec.MachineRef.Name).To(Equal("ipmi"))
+	})
+
+	t.Run("records_bmc_of_completed_job", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		hw := hardware()
+		scope := bmcJobTestScope(t, hw,
+			jobThrough("redfish", "redfish", now.Add(-time.Minute), rufiov1.JobFailed),
+			jobThrough("ipmi", "ipmi", now, rufiov1.JobCompleted),
+		)
+
+		job, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(job.Name).To(Equal("ipmi"))
+
+		updated := &tinkv1.Hardware{}
+		g.Expect(scope.client.Get(scope.ctx, client.ObjectKeyFromObject(hw), updated)).To(Succeed())
+		g.Expect(updated.Annotations).To(HaveKeyWithValue(HardwareLastSuccessfulBMCAnnotation, "ipmi"))
+	})
+
+	t.Run("reports_failure_once_all_bmcs_failed", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		hw := hardware()
+		scope := bmcJobTestScope(t, hw,
+			jobThrough("redfish", "redfish", now.Add(-time.Minute), rufiov1.JobFailed),
+			jobThrough("ipmi", "ipmi", now, rufiov1.JobFailed),
+		)
+
+		job, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(job.Name).To(Equal("ipmi"))
+		g.Expect(job.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue)).To(BeTrue())
+	})
+}
+
+func Test_ensureBMCJob_retries_failed_operation(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"},
+		Spec:       tinkv1.HardwareSpec{BMCRef: &corev1.TypedLocalObjectReference{Name: "bmc"}},
+	}
+
+	failed := bmcJob("first", "uid-1", bmcJobOperationPowerOff, time.Now().Add(-time.Hour), false)
+	failed.Spec.MachineRef.Name = "bmc"
+	failed.SetCondition(rufiov1.JobFailed, rufiov1.ConditionTrue)
+
+	scope := bmcJobTestScope(t, hw, failed)
+	scope.bmcJobRetries = 2
+	scope.bmcJobRetryBackoff = time.Minute
+
+	// The fake client does not set the creation timestamp the Jobs are ordered by.
+	created := time.Now()
+	failJob := func(job *rufiov1.Job) {
+		created = created.Add(time.Minute)
+		job.CreationTimestamp = metav1.NewTime(created)
+		job.SetCondition(rufiov1.JobFailed, rufiov1.ConditionTrue)
+		g.Expect(scope.client.Update(scope.ctx, job)).To(Succeed())
+	}
+
+	elapseBackoff := func() {
+		retry := scope.bmcJobRetry(bmcJobOperationPowerOff)
+		retry.LastFailureTime = metav1.NewTime(retry.LastFailureTime.Add(-maxBMCJobRetryBackoff))
+	}
+
+	// The failed Job is kept during the back-off, without failing the operation.
+	job, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(job.Name).To(Equal("first"))
+	g.Expect(scope.bmcJobRetryPending(job)).To(BeTrue())
+	g.Expect(scope.requeueAfter).To(BeNumerically("~", time.Minute, time.Second))
+	g.Expect(scope.tinkerbellMachine.Status.BMCJobRetries).To(ConsistOf(HaveField("Failures", int32(1))))
+
+	scope.setBMCJobCondition(bmcJobOperationPowerOff, job)
+	g.Expect(conditions.GetReason(scope.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition)).
+		To(Equal(infrastructurev1.BMCJobRetryingReason))
+
+	// A new Job is created once the back-off elapsed.
+	elapseBackoff()
+
+	job, err = scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(job.Name).NotTo(Equal("first"))
+	g.Expect(job.Spec.MachineRef.Name).To(Equal("bmc"))
+
+	failJob(job)
+
+	job, err = scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(scope.bmcJobRetryPending(job)).To(BeTrue())
+	g.Expect(scope.bmcJobRetry(bmcJobOperationPowerOff).Failures).To(Equal(int32(2)))
+
+	elapseBackoff()
+
+	job, err = scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The operation fails once the retry budget is exhausted.
+	failJob(job)
+
+	last, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(last.Name).To(Equal(job.Name))
+	g.Expect(scope.bmcJobRetryPending(last)).To(BeFalse())
+	g.Expect(scope.bmcJobRetry(bmcJobOperationPowerOff).Failures).To(Equal(int32(3)))
+
+	scope.setBMCJobCondition(bmcJobOperationPowerOff, last)
+	g.Expect(conditions.GetReason(scope.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition)).
+		To(Equal(infrastructurev1.BMCJobFailedReason))
+
+	// A completed Job forgets the failures.
+	last.Status.Conditions = nil
+	last.SetCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue)
+	g.Expect(scope.client.Update(scope.ctx, last)).To(Succeed())
+
+	_, err = scope.ensureBMCJob(bmcJobOperationPowerOff, hw, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(scope.tinkerbellMachine.Status.BMCJobRetries).To(BeEmpty())
+}
+
+func Test_bmcJobRetryBackoff(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	g.Expect(bmcJobRetryBackoff(30*time.Second, 1)).To(Equal(30 * time.Second))
+	g.Expect(bmcJobRetryBackoff(30*time.Second, 3)).To(Equal(2 * time.Minute))
+	g.Expect(bmcJobRetryBackoff(30*time.Second, 20)).To(Equal(maxBMCJobRetryBackoff))
+}
+
+func Test_cleanupFinishedBMCJobs(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	now := time.Now()
+	scope := bmcJobTestScope(t,
+		bmcJob("latest", "uid-1", bmcJobOperationPowerOff, now.Add(-48*time.Hour), true),
+		bmcJob("expired", "uid-1", bmcJobOperationPowerOff, now.Add(-72*time.Hour), true),
+		bmcJob("recent", "uid-1", "provision", now.Add(-time.Minute), true),
+		bmcJob("recent-older", "uid-1", "provision", now.Add(-2*time.Minute), true),
+	)
+
+	g.Expect(scope.cleanupFinishedBMCJobs(24 * time.Hour)).To(Succeed())
+
+	jobs, err := scope.listBMCJobs("")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var names []string
+	for _, job := range jobs {
+		names = append(names, job.Name)
+	}
+
+	g.Expect(names).To(ConsistOf("latest", "recent", "recent-older"))
+	g.Expect(scope.requeueAfter).To(BeNumerically("~", 24*time.Hour-2*time.Minute, time.Minute),
+		"Expected a requeue for when the next finished BMCJob expires")
+}
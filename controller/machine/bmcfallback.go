@@ -0,0 +1,88 @@
+package machine
+
+import (
+	"fmt"
+	"slices"
+
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+const (
+	// HardwareBMCRefsAnnotation is hardwareutil.BMCRefsAnnotation.
+	HardwareBMCRefsAnnotation = hardwareutil.BMCRefsAnnotation
+
+	// HardwareLastSuccessfulBMCAnnotation is hardwareutil.LastSuccessfulBMCAnnotation.
+	HardwareLastSuccessfulBMCAnnotation = hardwareutil.LastSuccessfulBMCAnnotation
+)
+
+// nextBMCRef returns the rufio Machine to retry the given failed BMC Job through, the one following the rufio
+// Machine of the Job in the order of hardwareutil.BMCRefs. It returns an empty string when the Job did not fail or
+// all rufio Machines were tried.
+func nextBMCRef(hw *tinkv1.Hardware, job *rufiov1.Job) string {
+	if !job.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue) {
+		return ""
+	}
+
+	refs := hardwareutil.BMCRefs(hw)
+
+	i := slices.Index(refs, job.Spec.MachineRef.Name)
+	if i < 0 || i == len(refs)-1 {
+		return ""
+	}
+
+	return refs[i+1]
+}
+
+// fallBackBMCJob retries the given failed BMC Job through the next rufio Machine of the hardware, returning the new
+// Job. It returns the given Job when there is none left to try.
+func (scope *machineReconcileScope) fallBackBMCJob(
+	operation string,
+	hw *tinkv1.Hardware,
+	job *rufiov1.Job,
+	tasks []rufiov1.Action,
+) (*rufiov1.Job, error) {
+	next := nextBMCRef(hw, job)
+	if next == "" {
+		return job, nil
+	}
+
+	record.Warnf(scope.tinkerbellMachine, "BMCFallback", "%s BMCJob %s failed through BMC %s, retrying through BMC %s",
+		operation, job.Name, job.Spec.MachineRef.Name, next)
+
+	return scope.createBMCJob(operation, next, tasks, job)
+}
+
+// recordSuccessfulBMC records the rufio Machine through which the given BMC Job completed in the
+// HardwareLastSuccessfulBMCAnnotation of the hardware, so later BMC Jobs try it first.
+func (scope *machineReconcileScope) recordSuccessfulBMC(hw *tinkv1.Hardware, job *rufiov1.Job) error {
+	name := job.Spec.MachineRef.Name
+
+	if !job.HasCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue) ||
+		hw.Annotations[HardwareLastSuccessfulBMCAnnotation] == name ||
+		!slices.Contains(hardwareutil.BMCRefs(hw), name) {
+		return nil
+	}
+
+	// With a single BMC, there is nothing to choose from.
+	if _, ok := hw.Annotations[HardwareBMCRefsAnnotation]; !ok {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(hw, scope.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for Hardware: %w", err)
+	}
+
+	hw.Annotations[HardwareLastSuccessfulBMCAnnotation] = name
+
+	if err := patchHelper.Patch(scope.ctx, hw); err != nil {
+		return fmt.Errorf("recording successful BMC on Hardware: %w", err)
+	}
+
+	return nil
+}
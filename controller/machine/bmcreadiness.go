@@ -0,0 +1,159 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+// bmcNotReadyRequeueAfter is how long a machine whose Hardware BMC is not ready waits before checking it again.
+const bmcNotReadyRequeueAfter = 30 * time.Second
+
+var (
+	// ErrBMCMachineNotFound is the reason of a BMC which is not ready because its rufio Machine does not exist.
+	ErrBMCMachineNotFound = errors.New("rufio Machine referenced by the Hardware bmcRef not found")
+
+	// ErrBMCCredentialsInvalid is the reason of a BMC which is not ready because the Secret of its rufio Machine
+	// is missing or lacks a username or password.
+	ErrBMCCredentialsInvalid = errors.New("BMC credentials are not usable")
+
+	// ErrBMCNotContactable is the reason of a BMC which is not ready because rufio could not contact it, or has not
+	// tried yet.
+	ErrBMCNotContactable = errors.New("BMC is not contactable")
+)
+
+// bmcCredentialKeys are the keys of the Secret of a rufio Machine holding the BMC credentials.
+//
+//nolint:gochecknoglobals
+var bmcCredentialKeys = []string{"username", "password"}
+
+// bmcReadiness returns why the BMC of the hardware cannot be used to power it, nil when it can. The BMC of Hardware
+// with several BMC paths can be used when any of its rufio Machines can. The returned error is only set when the BMC
+// could not be checked.
+func (scope *machineReconcileScope) bmcReadiness(hw *tinkv1.Hardware) (notReady, err error) {
+	reasons := []error{}
+
+	for _, name := range hardwareutil.BMCRefs(hw) {
+		notReady, err := scope.bmcMachineReadiness(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if notReady == nil {
+			return nil, nil
+		}
+
+		reasons = append(reasons, notReady)
+	}
+
+	return errors.Join(reasons...), nil
+}
+
+// bmcMachineReadiness returns why the rufio Machine of the given name cannot be used to power the hardware, nil when
+// it can.
+func (scope *machineReconcileScope) bmcMachineReadiness(name string) (notReady, err error) {
+	bmc := &rufiov1.Machine{}
+	key := client.ObjectKey{Namespace: scope.hardwareNamespace(), Name: name}
+
+	if err := scope.client.Get(scope.ctx, key, bmc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrBMCMachineNotFound, key), nil
+		}
+
+		return nil, fmt.Errorf("getting rufio Machine %s: %w", key, err)
+	}
+
+	// Machines using the RPC provider do not need credentials.
+	if options := bmc.Spec.Connection.ProviderOptions; options == nil || options.RPC == nil {
+		notReady, err := scope.bmcCredentialsReadiness(bmc)
+		if notReady != nil || err != nil {
+			return notReady, err
+		}
+	}
+
+	for _, c := range bmc.Status.Conditions {
+		if c.Type != rufiov1.Contactable {
+			continue
+		}
+
+		if c.Status != rufiov1.ConditionTrue {
+			return fmt.Errorf("%w: rufio Machine %s: %s", ErrBMCNotContactable, key, c.Message), nil
+		}
+
+		return nil, nil
+	}
+
+	return fmt.Errorf("%w: rufio Machine %s was not contacted yet", ErrBMCNotContactable, key), nil
+}
+
+// bmcCredentialsReadiness returns why the credentials of the rufio Machine are not usable, nil when they are.
+func (scope *machineReconcileScope) bmcCredentialsReadiness(bmc *rufiov1.Machine) (notReady, err error) {
+	ref := bmc.Spec.Connection.AuthSecretRef
+	if ref.Name == "" {
+		return fmt.Errorf("%w: rufio Machine %s has no authSecretRef", ErrBMCCredentialsInvalid, bmc.Name), nil
+	}
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}
+
+	if err := scope.client.Get(scope.ctx, key, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%w: Secret %s not found", ErrBMCCredentialsInvalid, key), nil
+		}
+
+		return nil, fmt.Errorf("getting BMC Secret %s: %w", key, err)
+	}
+
+	for _, k := range bmcCredentialKeys {
+		if len(secret.Data[k]) == 0 {
+			return fmt.Errorf("%w: Secret %s has no %s", ErrBMCCredentialsInvalid, key, k), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ensureBMCReady checks that the BMC of the hardware can power it before provisioning starts, so a broken BMC is
+// reported on the machine instead of failing the BMC Jobs later. It returns false, setting the BMCReady condition
+// to false and requeueing, when it cannot. Hardware whose power is not managed through BMC Jobs is not checked.
+func (scope *machineReconcileScope) ensureBMCReady(hw *tinkv1.Hardware) (bool, error) {
+	if !scope.managesPower(hw) {
+		return true, nil
+	}
+
+	notReady, err := scope.bmcReadiness(hw)
+	if err != nil {
+		return false, err
+	}
+
+	if notReady != nil {
+		msg := notReady.Error()
+
+		previous := conditions.Get(scope.tinkerbellMachine, infrastructurev1.BMCReadyCondition)
+		if previous == nil || previous.Message != msg {
+			record.Warn(scope.tinkerbellMachine, infrastructurev1.BMCNotReadyReason, msg)
+		}
+
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.BMCReadyCondition,
+			infrastructurev1.BMCNotReadyReason, clusterv1.ConditionSeverityWarning, "%s", msg)
+		scope.requeue(bmcNotReadyRequeueAfter)
+
+		return false, nil
+	}
+
+	conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.BMCReadyCondition)
+
+	return true, nil
+}
@@ -0,0 +1,109 @@
+package machine
+
+import (
+	"slices"
+	"time"
+
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+// maxBMCJobRetryBackoff caps the back-off between the retries of a failed BMC operation.
+const maxBMCJobRetryBackoff = 10 * time.Minute
+
+// bmcJobRetryBackoff returns how long a BMC operation which failed the given number of times waits before it is
+// retried: the base back-off, doubled for every further failure, capped at maxBMCJobRetryBackoff.
+func bmcJobRetryBackoff(base time.Duration, failures int32) time.Duration {
+	backoff := base
+
+	for i := int32(1); i < failures && backoff < maxBMCJobRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxBMCJobRetryBackoff)
+}
+
+// bmcJobRetry returns the retries of the given BMC operation of the machine, nil when none of its Jobs failed.
+func (scope *machineReconcileScope) bmcJobRetry(operation string) *infrastructurev1.BMCJobRetry {
+	retries := scope.tinkerbellMachine.Status.BMCJobRetries
+
+	for i := range retries {
+		if retries[i].Operation == operation {
+			return &retries[i]
+		}
+	}
+
+	return nil
+}
+
+// bmcJobRetryPending returns true when the given failed BMC Job is retried once the back-off of its operation
+// elapsed, so the failure is not terminal yet.
+func (scope *machineReconcileScope) bmcJobRetryPending(job *rufiov1.Job) bool {
+	retry := scope.bmcJobRetry(job.Labels[BMCJobOperationLabel])
+
+	return retry != nil && retry.LastFailedJob == job.Name && int(retry.Failures) <= scope.bmcJobRetries
+}
+
+// retryBMCJob retries the given BMC Job, which failed through all BMCs of the Hardware, with a new Job once the
+// back-off of its operation elapsed, as long as the operation did not fail more often than the retry budget
+// allows. Every failure is recorded in the status of the machine, with an event. The given Job is returned while
+// waiting for the back-off or once the budget is exhausted, and the failures are forgotten once it completed.
+func (scope *machineReconcileScope) retryBMCJob(
+	operation string,
+	hw *tinkv1.Hardware,
+	job *rufiov1.Job,
+	tasks []rufiov1.Action,
+) (*rufiov1.Job, error) {
+	if job.HasCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue) {
+		scope.tinkerbellMachine.Status.BMCJobRetries = slices.DeleteFunc(scope.tinkerbellMachine.Status.BMCJobRetries,
+			func(r infrastructurev1.BMCJobRetry) bool { return r.Operation == operation })
+
+		return job, nil
+	}
+
+	if !job.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue) || scope.bmcJobRetries <= 0 {
+		return job, nil
+	}
+
+	retry := scope.bmcJobRetry(operation)
+	if retry == nil {
+		scope.tinkerbellMachine.Status.BMCJobRetries = append(scope.tinkerbellMachine.Status.BMCJobRetries,
+			infrastructurev1.BMCJobRetry{Operation: operation})
+		retry = scope.bmcJobRetry(operation)
+	}
+
+	if retry.LastFailedJob != job.Name {
+		retry.Failures++
+		retry.LastFailedJob = job.Name
+		retry.LastFailureTime = metav1.Now()
+
+		if int(retry.Failures) > scope.bmcJobRetries {
+			record.Warnf(scope.tinkerbellMachine, "BMCJobRetriesExhausted",
+				"%s BMCJob %s failed, giving up after %d attempts", operation, job.Name, retry.Failures)
+		} else {
+			record.Warnf(scope.tinkerbellMachine, "BMCJobRetry",
+				"%s BMCJob %s failed, retrying in %s (attempt %d of %d)", operation, job.Name,
+				bmcJobRetryBackoff(scope.bmcJobRetryBackoff, retry.Failures), retry.Failures+1, scope.bmcJobRetries+1)
+		}
+	}
+
+	if int(retry.Failures) > scope.bmcJobRetries {
+		return job, nil
+	}
+
+	retryAt := retry.LastFailureTime.Add(bmcJobRetryBackoff(scope.bmcJobRetryBackoff, retry.Failures))
+	if wait := time.Until(retryAt); wait > 0 {
+		scope.requeue(wait)
+
+		return job, nil
+	}
+
+	scope.log.Info("Retrying failed BMCJob", "Name", job.Name, "operation", operation, "failures", retry.Failures)
+
+	return scope.createBMCJob(operation, hardwareutil.BMCRefs(hw)[0], tasks, job)
+}
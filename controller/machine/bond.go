@@ -0,0 +1,97 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// ErrBondInterfaceNotFound is the error returned when the Hardware has no interface with the MAC address of a
+// member of the bond.
+var ErrBondInterfaceNotFound = fmt.Errorf("hardware has no interface for the bond")
+
+// bondForHardware returns the bond configuration of the machine with the MAC addresses of its members as set on
+// the given hardware, defaulting to all of its interfaces with a MAC address, and with its mode defaulted. It
+// returns nil when the machine has no bond configuration.
+func bondForHardware(bond *infrastructurev1.Bond, hw *tinkv1.Hardware) (*infrastructurev1.Bond, error) {
+	if bond == nil {
+		return nil, nil
+	}
+
+	resolved := bond.DeepCopy()
+	if resolved.Mode == "" {
+		resolved.Mode = infrastructurev1.BondModeLACP
+	}
+
+	macs := map[string]string{}
+	ordered := []string{}
+
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP == nil || iface.DHCP.MAC == "" {
+			continue
+		}
+
+		macs[strings.ToLower(iface.DHCP.MAC)] = iface.DHCP.MAC
+		ordered = append(ordered, iface.DHCP.MAC)
+	}
+
+	if len(resolved.Interfaces) == 0 {
+		if len(ordered) == 0 {
+			return nil, fmt.Errorf("%w: no interface has a MAC address", ErrBondInterfaceNotFound)
+		}
+
+		resolved.Interfaces = ordered
+
+		return resolved, nil
+	}
+
+	for i, member := range resolved.Interfaces {
+		mac, found := macs[strings.ToLower(member)]
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrBondInterfaceNotFound, member)
+		}
+
+		resolved.Interfaces[i] = mac
+	}
+
+	return resolved, nil
+}
+
+// bondPrimaryInterface returns the interface of the hardware which is the primary member of the bond of the
+// machine, or nil when the machine has no bond configuration.
+func (scope *machineReconcileScope) bondPrimaryInterface(hw *tinkv1.Hardware) (*tinkv1.Interface, error) {
+	bond, err := bondForHardware(scope.tinkerbellMachine.Spec.Bond, hw)
+	if err != nil || bond == nil {
+		return nil, err
+	}
+
+	for i, iface := range hw.Spec.Interfaces {
+		if iface.DHCP != nil && iface.DHCP.MAC == bond.Interfaces[0] {
+			return &hw.Spec.Interfaces[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrBondInterfaceNotFound, bond.Interfaces[0])
+}
+
+// bondIP returns the DHCP address of the primary member of the bond of the machine, which the bond keeps as it
+// uses its MAC address, or the DHCP address of the first interface of the hardware without bond configuration.
+func (scope *machineReconcileScope) bondIP(hw *tinkv1.Hardware) (string, error) {
+	primary, err := scope.bondPrimaryInterface(hw)
+	if err != nil {
+		return "", err
+	}
+
+	if primary == nil {
+		return hardwareIP(hw)
+	}
+
+	if primary.DHCP.IP == nil || primary.DHCP.IP.Address == "" {
+		return "", fmt.Errorf("%w: primary bond member %s", ErrHardwareFirstInterfaceDHCPMissingIP, primary.DHCP.MAC)
+	}
+
+	return primary.DHCP.IP.Address, nil
+}
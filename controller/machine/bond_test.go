@@ -0,0 +1,89 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_bondForHardware(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hw := &tinkv1.Hardware{
+		Spec: tinkv1.HardwareSpec{
+			Interfaces: []tinkv1.Interface{
+				{DHCP: &tinkv1.DHCP{MAC: "00:00:5e:00:53:01"}},
+				{DHCP: &tinkv1.DHCP{}},
+				{DHCP: &tinkv1.DHCP{MAC: "00:00:5e:00:53:02"}},
+			},
+		},
+	}
+
+	resolved, err := bondForHardware(nil, hw)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolved).To(BeNil())
+
+	resolved, err = bondForHardware(&infrastructurev1.Bond{}, hw)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolved.Mode).To(Equal(infrastructurev1.BondModeLACP))
+	g.Expect(resolved.Interfaces).To(Equal([]string{"00:00:5e:00:53:01", "00:00:5e:00:53:02"}),
+		"Expected all interfaces with a MAC address by default")
+
+	resolved, err = bondForHardware(&infrastructurev1.Bond{
+		Mode:       infrastructurev1.BondModeActiveBackup,
+		Interfaces: []string{"00:00:5E:00:53:02", "00:00:5e:00:53:01"},
+	}, hw)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolved.Mode).To(Equal(infrastructurev1.BondModeActiveBackup))
+	g.Expect(resolved.Interfaces).To(Equal([]string{"00:00:5e:00:53:02", "00:00:5e:00:53:01"}))
+
+	_, err = bondForHardware(&infrastructurev1.Bond{Interfaces: []string{"00:00:5e:00:53:03"}}, hw)
+	g.Expect(err).To(MatchError(ErrBondInterfaceNotFound))
+
+	_, err = bondForHardware(&infrastructurev1.Bond{}, &tinkv1.Hardware{})
+	g.Expect(err).To(MatchError(ErrBondInterfaceNotFound))
+}
+
+func Test_bond_selects_primary_member_for_netboot(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hw := &tinkv1.Hardware{
+		Spec: tinkv1.HardwareSpec{
+			Interfaces: []tinkv1.Interface{
+				{DHCP: &tinkv1.DHCP{MAC: "00:00:5e:00:53:01"}, Netboot: &tinkv1.Netboot{}},
+				{
+					DHCP:    &tinkv1.DHCP{MAC: "00:00:5e:00:53:02", IP: &tinkv1.IP{Address: "10.0.0.12"}},
+					Netboot: &tinkv1.Netboot{},
+				},
+			},
+		},
+	}
+
+	scope := &machineReconcileScope{
+		tinkerbellMachine: &infrastructurev1.TinkerbellMachine{
+			Spec: infrastructurev1.TinkerbellMachineSpec{
+				Bond: &infrastructurev1.Bond{Interfaces: []string{"00:00:5e:00:53:02", "00:00:5e:00:53:01"}},
+				WorkerDevice: infrastructurev1.WorkerDevice{
+					Source: infrastructurev1.WorkerDeviceSourceMAC,
+				},
+			},
+		},
+	}
+
+	primary, err := scope.bondPrimaryInterface(hw)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(primary.DHCP.MAC).To(Equal("00:00:5e:00:53:02"))
+
+	device, err := scope.workerDevice(hw)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(device).To(Equal("00:00:5e:00:53:02"))
+
+	ip, err := scope.machineIP(hw)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ip).To(Equal("10.0.0.12"))
+}
@@ -0,0 +1,128 @@
+package machine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/record"
+)
+
+const (
+	// BootstrapDataExpiresAtAnnotation can be set on a bootstrap data Secret by bootstrap providers or operators
+	// to signal when the credentials embedded in the bootstrap data (e.g. kubeadm bootstrap tokens) expire.
+	// The value must be an RFC3339 timestamp.
+	BootstrapDataExpiresAtAnnotation = "tinkerbellmachine.infrastructure.cluster.x-k8s.io/bootstrap-data-expires-at"
+
+	// DefaultBootstrapDataKey is the key of the bootstrap data Secret holding the bootstrap data, as defined by
+	// the Cluster API bootstrap provider contract.
+	DefaultBootstrapDataKey = "value"
+
+	// bootstrapDataRefreshRequeueAfter is how long to wait before checking again whether expired bootstrap
+	// data has been refreshed by the bootstrap provider.
+	bootstrapDataRefreshRequeueAfter = 30 * time.Second
+)
+
+// getBootstrapDataSecret returns the bootstrap data Secret referenced by the given machine.
+func (scope *machineReconcileScope) getBootstrapDataSecret(machine *clusterv1.Machine) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: machine.Namespace, Name: *machine.Spec.Bootstrap.DataSecretName}
+
+	if err := scope.client.Get(scope.ctx, key, secret); err != nil {
+		return nil, fmt.Errorf("retrieving bootstrap data secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+// getReadyBootstrapCloudConfig returns the bootstrap data stored under the given key of the bootstrap data Secret,
+// DefaultBootstrapDataKey when empty. The format declared in the Secret, if any, must be one CAPT supports.
+func getReadyBootstrapCloudConfig(secret *corev1.Secret, key string) (string, error) {
+	if key == "" {
+		key = DefaultBootstrapDataKey
+	}
+
+	switch format := BootstrapFormat(secret.Data[bootstrapDataFormatKey]); format {
+	case "", BootstrapFormatCloudConfig, BootstrapFormatIgnition, BootstrapFormatTalos:
+	default:
+		return "", fmt.Errorf("%w: key %q of secret %s/%s is %q", ErrUnsupportedBootstrapFormat,
+			bootstrapDataFormatKey, secret.Namespace, secret.Name, format)
+	}
+
+	bootstrapUserData, ok := secret.Data[key]
+	if !ok {
+		keys := make([]string, 0, len(secret.Data))
+		for k := range secret.Data {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		return "", fmt.Errorf("%w: secret %s/%s has no key %q, found keys [%s]", ErrMissingBootstrapDataSecretValueKey,
+			secret.Namespace, secret.Name, key, strings.Join(keys, ", "))
+	}
+
+	if len(bootstrapUserData) == 0 {
+		return "", fmt.Errorf("%w: key %q of secret %s/%s", ErrBootstrapUserDataEmpty, key, secret.Namespace,
+			secret.Name)
+	}
+
+	return string(bootstrapUserData), nil
+}
+
+// bootstrapDataExpiry returns the time at which the bootstrap data stored in the given Secret expires.
+//
+// An explicit BootstrapDataExpiresAtAnnotation always takes precedence, so bootstrap providers refreshing the data
+// in place must update it. Otherwise, when ttl is positive, the expiry is computed from the creation of the Secret.
+// The managed fields are not used, as server-side apply rewrites their timestamps without the data changing. A zero
+// time is returned when the expiry is unknown.
+func bootstrapDataExpiry(secret *corev1.Secret, ttl time.Duration) (time.Time, error) {
+	if v, ok := secret.GetAnnotations()[BootstrapDataExpiresAtAnnotation]; ok {
+		expiresAt, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parsing %s annotation: %w", BootstrapDataExpiresAtAnnotation, err)
+		}
+
+		return expiresAt, nil
+	}
+
+	if ttl <= 0 || secret.CreationTimestamp.IsZero() {
+		return time.Time{}, nil
+	}
+
+	return secret.CreationTimestamp.Add(ttl), nil
+}
+
+// bootstrapDataExpired returns true when the bootstrap data of the machine is known to be expired.
+func (scope *machineReconcileScope) bootstrapDataExpired() bool {
+	return !scope.bootstrapDataExpiresAt.IsZero() && !time.Now().Before(scope.bootstrapDataExpiresAt)
+}
+
+// requeueAtBootstrapDataExpiry requeues the TinkerbellMachine when its bootstrap data expires, so a machine
+// waiting to be provisioned stops before creating its workflow with expired data even when nothing else changes.
+func (scope *machineReconcileScope) requeueAtBootstrapDataExpiry() {
+	if scope.bootstrapDataExpiresAt.IsZero() {
+		return
+	}
+
+	if until := time.Until(scope.bootstrapDataExpiresAt); until > 0 {
+		scope.requeue(until)
+	}
+}
+
+// waitForBootstrapDataRefresh holds off provisioning until the bootstrap provider refreshes expired bootstrap
+// data, so the machine does not netboot with credentials that can no longer be used to join the cluster.
+func (scope *machineReconcileScope) waitForBootstrapDataRefresh() {
+	scope.log.Info("Bootstrap data expired, waiting for it to be refreshed before provisioning",
+		"expiredAt", scope.bootstrapDataExpiresAt)
+
+	record.Warnf(scope.tinkerbellMachine, "BootstrapDataExpired",
+		"Bootstrap data expired at %s, waiting for it to be refreshed before provisioning",
+		scope.bootstrapDataExpiresAt.Format(time.RFC3339))
+
+	scope.requeue(bootstrapDataRefreshRequeueAfter)
+}
@@ -0,0 +1,51 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_bootstrapDataExpiry(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	applied := metav1.NewTime(created.Add(time.Hour))
+
+	tests := map[string]struct {
+		annotations map[string]string
+		ttl         time.Duration
+		want        time.Time
+	}{
+		"unknown without ttl": {},
+		"ttl from the creation of the secret, not its managed fields": {
+			ttl:  2 * time.Hour,
+			want: created.Add(2 * time.Hour),
+		},
+		"annotation takes precedence": {
+			annotations: map[string]string{BootstrapDataExpiresAtAnnotation: "2024-02-01T00:00:00Z"},
+			ttl:         2 * time.Hour,
+			want:        time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: metav1.NewTime(created),
+				Annotations:       tc.annotations,
+				ManagedFields:     []metav1.ManagedFieldsEntry{{Manager: "apply", Time: &applied}},
+			}}
+
+			got, err := bootstrapDataExpiry(secret, tc.ttl)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(got.Equal(tc.want)).To(BeTrue(), "got %s, want %s", got, tc.want)
+		})
+	}
+}
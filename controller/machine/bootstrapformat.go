@@ -0,0 +1,172 @@
+package machine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// BootstrapFormat is the format of the bootstrap data of a machine.
+type BootstrapFormat string
+
+const (
+	// BootstrapFormatCloudConfig is cloud-init user-data, e.g. from the kubeadm bootstrap provider.
+	BootstrapFormatCloudConfig BootstrapFormat = "cloud-config"
+
+	// BootstrapFormatIgnition is an Ignition config, e.g. from the kubeadm bootstrap provider for Flatcar.
+	BootstrapFormatIgnition BootstrapFormat = "ignition"
+
+	// BootstrapFormatTalos is a Talos machine configuration, e.g. from the Talos bootstrap provider.
+	BootstrapFormatTalos BootstrapFormat = "talos"
+
+	// bootstrapDataFormatKey is the key of the bootstrap data Secret holding the format of the bootstrap data,
+	// as defined by the Cluster API bootstrap provider contract.
+	bootstrapDataFormatKey = "format"
+)
+
+// detectBootstrapFormat returns the format of the bootstrap data stored in the given Secret. The format declared
+// by the bootstrap provider in the Secret takes precedence, otherwise it is sniffed from the data. Data in no
+// recognized format is assumed to be cloud-init user-data, like shell scripts and MIME multipart archives.
+func detectBootstrapFormat(secret *corev1.Secret, data string) BootstrapFormat {
+	switch BootstrapFormat(secret.Data[bootstrapDataFormatKey]) {
+	case BootstrapFormatIgnition:
+		return BootstrapFormatIgnition
+	case BootstrapFormatTalos:
+		return BootstrapFormatTalos
+	}
+
+	trimmed := strings.TrimSpace(data)
+
+	if strings.HasPrefix(trimmed, "#cloud-config") || strings.HasPrefix(trimmed, "#!") {
+		return BootstrapFormatCloudConfig
+	}
+
+	if strings.HasPrefix(trimmed, "{") {
+		var config struct {
+			Ignition *json.RawMessage `json:"ignition"`
+		}
+
+		if json.Unmarshal([]byte(trimmed), &config) == nil && config.Ignition != nil {
+			return BootstrapFormatIgnition
+		}
+	}
+
+	var talos struct {
+		Version string           `json:"version"`
+		Machine *json.RawMessage `json:"machine"`
+	}
+
+	// Talos configurations may be multi-document, the machine configuration is the first document.
+	first, _, _ := strings.Cut(trimmed, "\n---")
+	if yaml.Unmarshal([]byte(first), &talos) == nil && talos.Version != "" && talos.Machine != nil {
+		return BootstrapFormatTalos
+	}
+
+	return BootstrapFormatCloudConfig
+}
+
+// injectProviderID replaces the provider ID placeholder of the bootstrap data with the provider ID of the machine.
+// The Ignition configs embed file contents as data URLs, which may be base64 or percent-encoded, so placeholders
+// in them are replaced within the decoded contents.
+func injectProviderID(format BootstrapFormat, data, providerID string) (string, error) {
+	if format != BootstrapFormatIgnition {
+		return strings.ReplaceAll(data, providerIDPlaceholder, providerID), nil
+	}
+
+	var config any
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		return "", fmt.Errorf("parsing Ignition config: %w", err)
+	}
+
+	config, err := replaceInIgnition(config, providerID)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(config); err != nil {
+		return "", fmt.Errorf("serializing Ignition config: %w", err)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// replaceInIgnition replaces the provider ID placeholder in all strings of the Ignition config, decoding data
+// URLs.
+func replaceInIgnition(v any, providerID string) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			replaced, err := replaceInIgnition(e, providerID)
+			if err != nil {
+				return nil, err
+			}
+
+			v[k] = replaced
+		}
+
+		return v, nil
+	case []any:
+		for i, e := range v {
+			replaced, err := replaceInIgnition(e, providerID)
+			if err != nil {
+				return nil, err
+			}
+
+			v[i] = replaced
+		}
+
+		return v, nil
+	case string:
+		if strings.HasPrefix(v, "data:") {
+			return replaceInDataURL(v, providerID)
+		}
+
+		return strings.ReplaceAll(v, providerIDPlaceholder, providerID), nil
+	default:
+		return v, nil
+	}
+}
+
+// replaceInDataURL replaces the provider ID placeholder in the contents of a data URL, keeping its encoding.
+func replaceInDataURL(dataURL, providerID string) (string, error) {
+	header, contents, ok := strings.Cut(dataURL, ",")
+	if !ok {
+		return dataURL, nil
+	}
+
+	if strings.HasSuffix(header, ";base64") {
+		decoded, err := base64.StdEncoding.DecodeString(contents)
+		if err != nil {
+			return "", fmt.Errorf("decoding data URL: %w", err)
+		}
+
+		if !bytes.Contains(decoded, []byte(providerIDPlaceholder)) {
+			return dataURL, nil
+		}
+
+		replaced := bytes.ReplaceAll(decoded, []byte(providerIDPlaceholder), []byte(providerID))
+
+		return header + "," + base64.StdEncoding.EncodeToString(replaced), nil
+	}
+
+	decoded, err := url.PathUnescape(contents)
+	if err != nil {
+		return "", fmt.Errorf("decoding data URL: %w", err)
+	}
+
+	if !strings.Contains(decoded, providerIDPlaceholder) {
+		return dataURL, nil
+	}
+
+	return header + "," + url.PathEscape(strings.ReplaceAll(decoded, providerIDPlaceholder, providerID)), nil
+}
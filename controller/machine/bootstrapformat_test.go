@@ -0,0 +1,138 @@
+package machine //nolint:testpackage
+
+import (
+	"encoding/base64"
+	"net/url"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_detectBootstrapFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		format string
+		data   string
+		want   BootstrapFormat
+	}{
+		"kubeadm cloud-config": {
+			data: "## template: jinja\n#cloud-config\nruncmd:\n  - kubeadm join\n",
+			want: BootstrapFormatCloudConfig,
+		},
+		"shell script": {
+			data: "#!/bin/sh\necho hello\n",
+			want: BootstrapFormatCloudConfig,
+		},
+		"declared ignition": {
+			format: "ignition",
+			data:   "not sniffed",
+			want:   BootstrapFormatIgnition,
+		},
+		"sniffed ignition": {
+			data: `{"ignition":{"version":"3.3.0"},"storage":{}}`,
+			want: BootstrapFormatIgnition,
+		},
+		"talos machine config": {
+			data: "version: v1alpha1\nmachine:\n  type: controlplane\ncluster:\n  clusterName: test\n---\nkind: Extra\n",
+			want: BootstrapFormatTalos,
+		},
+		"other JSON": {
+			data: `{"hello":"world"}`,
+			want: BootstrapFormatCloudConfig,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			secret := &corev1.Secret{Data: map[string][]byte{"value": []byte(tc.data)}}
+			if tc.format != "" {
+				secret.Data[bootstrapDataFormatKey] = []byte(tc.format)
+			}
+
+			g.Expect(detectBootstrapFormat(secret, tc.data)).To(Equal(tc.want))
+		})
+	}
+}
+
+func Test_getReadyBootstrapCloudConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data    map[string][]byte
+		key     string
+		want    string
+		wantErr error
+	}{
+		"default key": {
+			data: map[string][]byte{"value": []byte("#cloud-config"), "format": []byte("cloud-config")},
+			want: "#cloud-config",
+		},
+		"configured key": {
+			data: map[string][]byte{"userdata": []byte("#cloud-config")},
+			key:  "userdata",
+			want: "#cloud-config",
+		},
+		"missing key": {
+			data:    map[string][]byte{"userdata": []byte("#cloud-config")},
+			wantErr: ErrMissingBootstrapDataSecretValueKey,
+		},
+		"empty key": {
+			data:    map[string][]byte{"value": nil},
+			wantErr: ErrBootstrapUserDataEmpty,
+		},
+		"unsupported format": {
+			data:    map[string][]byte{"value": []byte("data"), "format": []byte("unknown")},
+			wantErr: ErrUnsupportedBootstrapFormat,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			data, err := getReadyBootstrapCloudConfig(&corev1.Secret{Data: tc.data}, tc.key)
+			if tc.wantErr != nil {
+				g.Expect(err).To(MatchError(tc.wantErr))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(data).To(Equal(tc.want))
+		})
+	}
+}
+
+func Test_injectProviderID(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	const providerID = "tinkerbell://default/hw-1"
+
+	cloudConfig, err := injectProviderID(BootstrapFormatCloudConfig, "provider-id: PROVIDER_ID", providerID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cloudConfig).To(Equal("provider-id: " + providerID))
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("providerID: PROVIDER_ID"))
+	ignition := `{"ignition":{"version":"3.3.0"},"storage":{"files":[` +
+		`{"path":"/a","contents":{"source":"data:;base64,` + encoded + `"}},` +
+		`{"path":"/b","contents":{"source":"data:,providerID%3A%20PROVIDER_ID"}}]},` +
+		`"systemd":{"units":[{"name":"kubelet.service","contents":"--provider-id=PROVIDER_ID"}]}}`
+
+	injected, err := injectProviderID(BootstrapFormatIgnition, ignition, providerID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(injected).NotTo(ContainSubstring(providerIDPlaceholder))
+	g.Expect(injected).To(ContainSubstring("--provider-id=" + providerID))
+	g.Expect(injected).To(ContainSubstring(
+		"data:;base64," + base64.StdEncoding.EncodeToString([]byte("providerID: "+providerID))))
+	g.Expect(injected).To(ContainSubstring("data:," + url.PathEscape("providerID: "+providerID)))
+
+	_, err = injectProviderID(BootstrapFormatIgnition, "not json", providerID)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,285 @@
+package machine
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+const (
+	// bootstrapReportScript is the path of the script reporting the bootstrap result in the OS of the machine.
+	bootstrapReportScript = "/usr/local/sbin/capt-bootstrap-report"
+
+	// bootstrapReportService is the systemd service running bootstrapReportScript after cloud-init finished.
+	bootstrapReportService = "capt-bootstrap-report.service"
+
+	// bootstrapSuccessSentinel is written by Cluster API bootstrap providers once the bootstrap data ran
+	// successfully.
+	bootstrapSuccessSentinel = "/run/cluster-api/bootstrap-success.complete"
+
+	// bootstrapReportLogBytes is how much of the end of the cloud-init output a report may contain.
+	bootstrapReportLogBytes = 64 * 1024
+
+	// bootstrapReportLogLines is how many lines of the end of the cloud-init output are reported in the
+	// BootstrapSucceeded condition.
+	bootstrapReportLogLines = 20
+
+	// bootstrapReportedStatusSucceeded is the status reported by machines whose bootstrap data ran successfully.
+	bootstrapReportedStatusSucceeded = "succeeded"
+)
+
+// bootstrapReportFiles returns the files configuring the OS of a machine to report its bootstrap result to the
+// given URL once cloud-init finished: whether the bootstrap success sentinel exists, the exit code of cloud-init and
+// the end of its output. The report is sent once per installation. Nil is returned when no URL is set.
+func bootstrapReportFiles(url string) []WorkflowFile {
+	if url == "" {
+		return nil
+	}
+
+	script := fmt.Sprintf(`#!/bin/sh
+# Reports the bootstrap result of the machine to Cluster API Provider Tinkerbell.
+cloud-init status --wait >/dev/null 2>&1
+exit_code=$?
+
+status=failed
+if [ -f %[1]s ]; then
+	status=%[2]s
+fi
+
+tail -n 50 /var/log/cloud-init-output.log 2>/dev/null |
+	curl -fsS --retry 30 --retry-delay 10 --retry-connrefused -X POST -H "Content-Type: text/plain" \
+		--data-binary @- "%[3]s?status=${status}&exitCode=${exit_code}" &&
+	touch /var/lib/capt-bootstrap-reported
+`, bootstrapSuccessSentinel, bootstrapReportedStatusSucceeded, url)
+
+	service := fmt.Sprintf(`[Unit]
+Description=Report the bootstrap result to Cluster API Provider Tinkerbell
+After=cloud-final.service network-online.target
+ConditionPathExists=!/var/lib/capt-bootstrap-reported
+
+[Service]
+Type=oneshot
+ExecStart=%s
+`, bootstrapReportScript)
+
+	// cloud-final.service pulls in the service rather than a target, as it is ordered after multi-user.target.
+	dropIn := fmt.Sprintf("[Unit]\nWants=%s\n", bootstrapReportService)
+
+	return []WorkflowFile{
+		{Path: bootstrapReportScript, Mode: "0700", Content: script},
+		{Path: "/etc/systemd/system/" + bootstrapReportService, Content: service},
+		{Path: "/etc/systemd/system/cloud-final.service.d/capt-bootstrap-report.conf", Content: dropIn},
+	}
+}
+
+// reportBaseURL returns the base URL under which the machine reaches the BootstrapReportServer: the bootstrap
+// report URL of its endpoints or the one of the controller.
+func (scope *machineReconcileScope) reportBaseURL() string {
+	if u := scope.endpoints().BootstrapReportURL; u != "" {
+		return strings.TrimSuffix(u, "/")
+	}
+
+	return strings.TrimSuffix(scope.bootstrapReportURL, "/")
+}
+
+// bootstrapReportEndpoint returns the URL the machine reports its bootstrap result to, under the report base URL,
+// empty when bootstrap results are not reported. The report token of the machine in the URL keeps reports from
+// being sent for another machine.
+func (scope *machineReconcileScope) bootstrapReportEndpoint() (string, error) {
+	if scope.bootstrapReportURL == "" {
+		return "", nil
+	}
+
+	token, err := scope.reportToken()
+	if err != nil {
+		return "", err
+	}
+
+	tm := scope.tinkerbellMachine
+
+	return fmt.Sprintf("%s/bootstrap/%s/%s/%s", scope.reportBaseURL(), tm.Namespace, tm.Name, token), nil
+}
+
+// BootstrapReportServer receives the bootstrap results reported by machines and records them in the
+// BootstrapSucceeded condition of their TinkerbellMachine, so a machine whose bootstrap failed is told apart from
+// one whose node is slow to join.
+type BootstrapReportServer struct {
+	Client client.Client
+
+	// Address is the address the server listens on.
+	Address string
+
+	// Handlers are served alongside the bootstrap report endpoint, by pattern, e.g. for other reports of machines.
+	Handlers map[string]http.Handler
+
+	// TLSConfig, when set, serves the endpoints with TLS, so the report tokens in their URLs cannot be read off the
+	// provisioning network. Its GetCertificate or Certificates must provide the serving certificate.
+	TLSConfig *tls.Config
+}
+
+// NeedLeaderElection returns false, as every replica of the controller can record reports.
+func (s *BootstrapReportServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Handler returns the handler of the bootstrap report endpoint.
+func (s *BootstrapReportServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /bootstrap/{namespace}/{name}/{token}", s)
+
+	for pattern, handler := range s.Handlers {
+		mux.Handle(pattern, handler)
+	}
+
+	return mux
+}
+
+// Start serves bootstrap reports until the context is done.
+func (s *BootstrapReportServer) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.Address,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second, //nolint:gomnd
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+
+	if s.TLSConfig != nil {
+		server.TLSConfig = s.TLSConfig
+
+		go func() { errs <- server.ListenAndServeTLS("", "") }()
+	} else {
+		ctrl.LoggerFrom(ctx).Info("Serving bootstrap reports without TLS, report tokens are sent in plain text",
+			"address", s.Address)
+
+		go func() { errs <- server.ListenAndServe() }()
+	}
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("serving bootstrap reports: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second) //nolint:gomnd
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("shutting down bootstrap report server: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// ServeHTTP records the bootstrap result reported for a TinkerbellMachine.
+func (s *BootstrapReportServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := types.NamespacedName{Namespace: r.PathValue("namespace"), Name: r.PathValue("name")}
+	log := ctrl.LoggerFrom(r.Context()).WithValues("tinkerbellMachine", key)
+
+	output, err := io.ReadAll(io.LimitReader(r.Body, bootstrapReportLogBytes))
+	if err != nil {
+		http.Error(w, "reading report", http.StatusBadRequest)
+
+		return
+	}
+
+	tm := &infrastructurev1.TinkerbellMachine{}
+	if err := s.Client.Get(r.Context(), key, tm); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		log.Error(err, "Getting TinkerbellMachine of bootstrap report")
+		http.Error(w, "getting machine", http.StatusInternalServerError)
+
+		return
+	}
+
+	if !verifyMachineReportToken(w, r, s.Client, tm) {
+		return
+	}
+
+	patchHelper, err := patch.NewHelper(tm, s.Client)
+	if err != nil {
+		log.Error(err, "Initializing patch helper")
+		http.Error(w, "patching machine", http.StatusInternalServerError)
+
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == bootstrapReportedStatusSucceeded {
+		conditions.MarkTrue(tm, infrastructurev1.BootstrapSucceededCondition)
+	} else {
+		msg := fmt.Sprintf("cloud-init exited with code %s:\n%s", r.URL.Query().Get("exitCode"),
+			lastLines(string(output), bootstrapReportLogLines))
+
+		conditions.MarkFalse(tm, infrastructurev1.BootstrapSucceededCondition, infrastructurev1.BootstrapFailedReason,
+			clusterv1.ConditionSeverityError, "%s", msg)
+		record.Warnf(tm, infrastructurev1.BootstrapFailedReason, "Bootstrap failed, %s", msg)
+	}
+
+	if err := patchHelper.Patch(r.Context(), tm, patch.WithOwnedConditions{
+		Conditions: []clusterv1.ConditionType{infrastructurev1.BootstrapSucceededCondition},
+	}); err != nil {
+		log.Error(err, "Patching TinkerbellMachine with bootstrap report")
+		http.Error(w, "patching machine", http.StatusInternalServerError)
+
+		return
+	}
+
+	log.Info("Recorded bootstrap report", "status", status)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyMachineReportToken verifies the report token in the path of the request is the one of the TinkerbellMachine,
+// and responds with 404 Not Found otherwise, so reports for other machines cannot be sent by guessing their names.
+func verifyMachineReportToken(
+	w http.ResponseWriter,
+	r *http.Request,
+	c client.Client,
+	tm *infrastructurev1.TinkerbellMachine,
+) bool {
+	ok, err := VerifyReportToken(r.Context(), c, tm, ReportTokenSecretName(tm.Name), r.PathValue("token"))
+	if err != nil {
+		ctrl.LoggerFrom(r.Context()).Error(err, "Verifying report token", "tinkerbellMachine", tm.Name)
+		http.Error(w, "verifying token", http.StatusInternalServerError)
+
+		return false
+	}
+
+	if !ok {
+		http.NotFound(w, r)
+	}
+
+	return ok
+}
+
+// lastLines returns the last n lines of s, without trailing new lines.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,114 @@
+package machine_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_BootstrapReportServer(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, uuid.New().String())
+	tm.UID = types.UID(uuid.New().String())
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{tm})
+	token := reportToken(t, client, tm)
+
+	handler := (&machine.BootstrapReportServer{Client: client}).Handler()
+
+	report := func(token, query, output string) int {
+		req := httptest.NewRequest(http.MethodPost, "/bootstrap/"+clusterNamespace+"/"+tinkerbellMachineName+"/"+
+			token+"?"+query, strings.NewReader(output))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	condition := func() *clusterv1.Condition {
+		tm := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(context.Background(),
+			types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, tm)).To(Succeed())
+
+		return conditions.Get(tm, infrastructurev1.BootstrapSucceededCondition)
+	}
+
+	g.Expect(report(string(tm.UID), "status=failed", "")).To(Equal(http.StatusNotFound),
+		"Expected reports authenticated with the UID of the machine to be rejected")
+	g.Expect(report(strings.Repeat("0", len(token)), "status=failed", "")).To(Equal(http.StatusNotFound),
+		"Expected reports with another token to be rejected")
+	g.Expect(condition()).To(BeNil())
+
+	g.Expect(report(token, "status=failed&exitCode=1", "[init] Using Kubernetes version\nerror execution phase\n")).
+		To(Equal(http.StatusNoContent))
+	g.Expect(condition().Status).To(BeEquivalentTo("False"))
+	g.Expect(condition().Reason).To(Equal(infrastructurev1.BootstrapFailedReason))
+	g.Expect(condition().Message).To(Equal("cloud-init exited with code 1:\n[init] Using Kubernetes version\n" +
+		"error execution phase"))
+
+	g.Expect(report(token, "status=succeeded&exitCode=0", "")).To(Equal(http.StatusNoContent))
+	g.Expect(condition().Status).To(BeEquivalentTo("True"))
+}
+
+func Test_WorkflowTemplate_reports_bootstrap_result(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	const reportURL = "http://10.1.1.1:8082/bootstrap/default/machine/token"
+
+	wt := &machine.WorkflowTemplate{
+		Name:               "machine",
+		ImageURL:           "http://10.1.1.1:8080/ubuntu.gz",
+		DestPartition:      "/dev/sda1",
+		BootstrapReportURL: reportURL,
+	}
+
+	data, err := wt.Render()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(data).To(ContainSubstring("add file /usr/local/sbin/capt-bootstrap-report"))
+	g.Expect(data).To(ContainSubstring("add file /etc/systemd/system/cloud-final.service.d/capt-bootstrap-report.conf"))
+	g.Expect(data).To(ContainSubstring(reportURL + "?status="))
+
+	wt.OSFamily = infrastructurev1.OSFamilyWindows
+
+	data, err = wt.Render()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(data).NotTo(ContainSubstring("capt-bootstrap-report"), "Expected no report from cloudbase-init")
+}
+
+func Test_ReportTokenSecretName_is_bounded(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	name := strings.Repeat("a", 253)
+	other := name[:252] + "b"
+
+	g.Expect(machine.ReportTokenSecretName(name)).To(HaveLen(63))
+	g.Expect(machine.ReportTokenSecretName(name)).NotTo(Equal(machine.ReportTokenSecretName(other)))
+	g.Expect(machine.ReportTokenSecretName("machine")).To(HavePrefix("machine-report-token-"))
+}
+
+// reportToken returns the token reports for the TinkerbellMachine are authenticated with, creating it.
+func reportToken(t *testing.T, c crclient.Client, tm *infrastructurev1.TinkerbellMachine) string {
+	t.Helper()
+
+	token, err := machine.EnsureReportToken(context.Background(), c, tm, machine.ReportTokenSecretName(tm.Name))
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+
+	return token
+}
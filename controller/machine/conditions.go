@@ -0,0 +1,83 @@
+package machine
+
+import (
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// provisioningConditions are the conditions summarized in the Ready condition of a TinkerbellMachine until it is
+// Ready, in the order they are reached while provisioning.
+var provisioningConditions = []clusterv1.ConditionType{
+	infrastructurev1.HardwareClaimedCondition,
+	infrastructurev1.HardwareAvailableCondition,
+	infrastructurev1.ImageAvailableCondition,
+	infrastructurev1.BMCReadyCondition,
+	infrastructurev1.ProvisioningSlotAcquiredCondition,
+	infrastructurev1.WorkflowStagesSucceededCondition,
+	infrastructurev1.BMCJobSucceededCondition,
+	infrastructurev1.WorkflowSucceededCondition,
+	infrastructurev1.BootstrapSucceededCondition,
+	infrastructurev1.NodeHealthyCondition,
+}
+
+// readyConditions are the conditions summarized in the Ready condition of a TinkerbellMachine once it is Ready.
+// BMC Jobs run after provisioning, e.g. ejecting virtual media, do not affect the readiness of the machine.
+var readyConditions = []clusterv1.ConditionType{
+	infrastructurev1.HardwareAvailableCondition,
+	infrastructurev1.WorkflowSucceededCondition,
+	infrastructurev1.BootstrapSucceededCondition,
+	infrastructurev1.NodeHealthyCondition,
+}
+
+// ownedConditions are the conditions the TinkerbellMachine controller owns, so patching them wins over changes made
+// to them since the TinkerbellMachine was read.
+var ownedConditions = append([]clusterv1.ConditionType{clusterv1.ReadyCondition}, provisioningConditions...)
+
+// setReadyCondition sets the Ready condition of the TinkerbellMachine, which Cluster API mirrors as the
+// InfrastructureReady condition of the Machine. It summarizes the conditions of the machine, reporting the most
+// severe problem first. A machine which is not Ready yet, without any condition reporting a problem, is reported as
+// provisioning.
+func (scope *machineReconcileScope) setReadyCondition() {
+	tm := scope.tinkerbellMachine
+
+	if scope.MachineScheduledForDeletion() {
+		conditions.MarkFalse(tm, clusterv1.ReadyCondition, clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
+
+		return
+	}
+
+	if !tm.Status.Ready {
+		summarized := hasAnyCondition(tm, provisioningConditions)
+		if summarized {
+			conditions.SetSummary(tm, conditions.WithConditions(provisioningConditions...))
+		}
+
+		if !summarized || !conditions.IsFalse(tm, clusterv1.ReadyCondition) {
+			conditions.MarkFalse(tm, clusterv1.ReadyCondition, infrastructurev1.ProvisioningReason,
+				clusterv1.ConditionSeverityInfo, "Waiting for the machine to be provisioned")
+		}
+
+		return
+	}
+
+	if !hasAnyCondition(tm, readyConditions) {
+		conditions.MarkTrue(tm, clusterv1.ReadyCondition)
+
+		return
+	}
+
+	conditions.SetSummary(tm, conditions.WithConditions(readyConditions...))
+}
+
+// hasAnyCondition returns true when any of the given conditions is set on the TinkerbellMachine.
+func hasAnyCondition(tm *infrastructurev1.TinkerbellMachine, types []clusterv1.ConditionType) bool {
+	for _, t := range types {
+		if conditions.Has(tm, t) {
+			return true
+		}
+	}
+
+	return false
+}
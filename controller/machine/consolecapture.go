@@ -0,0 +1,309 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// consoleCaptureOperation is the BMCJobOperationLabel of the Jobs capturing the serial console of machines.
+	consoleCaptureOperation = "console-capture"
+
+	// defaultConsoleCaptureDuration is how long the console of a machine is captured when its consoleCapture sets
+	// no duration.
+	defaultConsoleCaptureDuration = time.Hour
+
+	// consoleLogBytes is how much of the end of the captured console output is kept.
+	consoleLogBytes = 512 * 1024
+
+	// ConsoleLogKey is the key of the ConfigMap referenced by the consoleLog status of a TinkerbellMachine holding
+	// the captured console output.
+	ConsoleLogKey = "console.log"
+
+	// consoleCaptureEndRequeueAfter is how long to wait before checking again whether a Job capturing a console
+	// ended once its capture duration passed.
+	consoleCaptureEndRequeueAfter = 10 * time.Second
+
+	// defaultIPMIPort is the port of BMCs whose rufio Machine sets none.
+	defaultIPMIPort = 623
+
+	// consoleCaptureScript deactivates any serial-over-LAN session left open on the BMC before attaching to the
+	// console, as BMCs allow a single session.
+	consoleCaptureScript = `ipmitool -I lanplus -H "$BMC_HOST" -p "$BMC_PORT" -U "$BMC_USERNAME" -E sol deactivate || true
+exec ipmitool -I lanplus -H "$BMC_HOST" -p "$BMC_PORT" -U "$BMC_USERNAME" -E sol activate`
+)
+
+// ConsoleLogReader returns the end of the output of the Job capturing the console of a machine, at most limitBytes.
+type ConsoleLogReader func(ctx context.Context, job *batchv1.Job, limitBytes int64) (string, error)
+
+// NewConsoleLogReader returns a ConsoleLogReader reading the logs of the Pods of the Job with the given clientset.
+func NewConsoleLogReader(clientset kubernetes.Interface) ConsoleLogReader {
+	return func(ctx context.Context, job *batchv1.Job, limitBytes int64) (string, error) {
+		pods, err := clientset.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: batchv1.JobNameLabel + "=" + job.Name,
+		})
+		if err != nil {
+			return "", fmt.Errorf("listing Pods of Job %s: %w", job.Name, err)
+		}
+
+		if len(pods.Items) == 0 {
+			return "", nil
+		}
+
+		stream, err := clientset.CoreV1().Pods(job.Namespace).GetLogs(pods.Items[0].Name, &corev1.PodLogOptions{
+			LimitBytes: &limitBytes,
+		}).Stream(ctx)
+		if err != nil {
+			return "", fmt.Errorf("streaming logs of Pod %s: %w", pods.Items[0].Name, err)
+		}
+		defer stream.Close()
+
+		output, err := io.ReadAll(stream)
+		if err != nil {
+			return "", fmt.Errorf("reading logs of Pod %s: %w", pods.Items[0].Name, err)
+		}
+
+		return string(output), nil
+	}
+}
+
+// capturesConsole returns true when the console of the Hardware is captured while the machine provisions.
+func (scope *machineReconcileScope) capturesConsole(hw *tinkv1.Hardware) bool {
+	return scope.tinkerbellMachine.Spec.ConsoleCapture != nil && scope.consoleCaptureImage != "" &&
+		hw.Spec.BMCRef != nil
+}
+
+// listConsoleCaptureJobs returns the Jobs capturing the console of the machine.
+func (scope *machineReconcileScope) listConsoleCaptureJobs() ([]batchv1.Job, error) {
+	reader := scope.apiReader
+	if reader == nil {
+		reader = scope.client
+	}
+
+	// The Jobs are read uncached, so the controller does not watch all Jobs of the cluster.
+	jobs := &batchv1.JobList{}
+	if err := reader.List(scope.ctx, jobs, client.InNamespace(scope.hardwareNamespace()), client.MatchingLabels{
+		BMCJobOwnerLabel:     scope.bmcJobOwnerLabelValue(),
+		BMCJobOperationLabel: consoleCaptureOperation,
+	}); err != nil {
+		return nil, fmt.Errorf("listing console capture Jobs: %w", err)
+	}
+
+	owned := make([]batchv1.Job, 0, len(jobs.Items))
+
+	for i := range jobs.Items {
+		if scope.ownedBy(&jobs.Items[i]) {
+			owned = append(owned, jobs.Items[i])
+		}
+	}
+
+	return owned, nil
+}
+
+// ensureConsoleCapture starts capturing the console of the Hardware over serial-over-LAN with a Job running
+// ipmitool, unless it is captured already. The Job authenticates with the credentials of the rufio Machine of the
+// Hardware and stops after the capture duration.
+func (scope *machineReconcileScope) ensureConsoleCapture(hw *tinkv1.Hardware) error {
+	if !scope.capturesConsole(hw) || scope.tinkerbellMachine.Status.ConsoleLog != nil {
+		return nil
+	}
+
+	jobs, err := scope.listConsoleCaptureJobs()
+	if err != nil || len(jobs) > 0 {
+		return err
+	}
+
+	bmc := &rufiov1.Machine{}
+
+	key := client.ObjectKey{Namespace: scope.hardwareNamespace(), Name: hw.Spec.BMCRef.Name}
+	if err := scope.client.Get(scope.ctx, key, bmc); err != nil {
+		return fmt.Errorf("getting rufio Machine %s: %w", key, err)
+	}
+
+	secret := bmc.Spec.Connection.AuthSecretRef
+	if secret.Namespace != "" && secret.Namespace != key.Namespace {
+		record.Warnf(scope.tinkerbellMachine, "ConsoleCaptureUnavailable",
+			"Credentials of rufio Machine %s are in namespace %s, not capturing the console", key, secret.Namespace)
+
+		return nil
+	}
+
+	port := bmc.Spec.Connection.Port
+	if port == 0 {
+		port = defaultIPMIPort
+	}
+
+	duration := defaultConsoleCaptureDuration
+	if d := scope.tinkerbellMachine.Spec.ConsoleCapture.Duration; d != nil {
+		duration = d.Duration
+	}
+
+	credential := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+			Key:                  key,
+		}}
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-%s-", scope.tinkerbellMachine.Name, consoleCaptureOperation),
+			Namespace:    key.Namespace,
+			Labels: map[string]string{
+				BMCJobOwnerLabel:     scope.bmcJobOwnerLabelValue(),
+				BMCJobOperationLabel: consoleCaptureOperation,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			ActiveDeadlineSeconds: ptr.To(int64(duration.Seconds())),
+			BackoffLimit:          ptr.To(int32(0)),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:    "console",
+						Image:   scope.consoleCaptureImage,
+						Command: []string{"/bin/sh", "-c", consoleCaptureScript},
+						Env: []corev1.EnvVar{
+							{Name: "BMC_HOST", Value: bmc.Spec.Connection.Host},
+							{Name: "BMC_PORT", Value: fmt.Sprint(port)},
+							{Name: "BMC_USERNAME", ValueFrom: credential("username")},
+							{Name: "IPMI_PASSWORD", ValueFrom: credential("password")},
+						},
+						// ipmitool needs a terminal to attach to the console.
+						Stdin: true,
+						TTY:   true,
+					}},
+				},
+			},
+		},
+	}
+
+	scope.setOwner(job, true)
+	scope.propagateMetadata(job)
+
+	if err := scope.client.Create(scope.ctx, job); err != nil {
+		return fmt.Errorf("creating console capture Job: %w", err)
+	}
+
+	scope.log.Info("Capturing console of Hardware", "hardware", hw.Name, "job", job.Name, "duration", duration)
+
+	return nil
+}
+
+// saveConsoleLog keeps the end of the console output captured while the machine failed to provision, or until its
+// capture ended, in a ConfigMap in the namespace of the machine, referenced by its consoleLog status, and stops the
+// capture.
+func (scope *machineReconcileScope) saveConsoleLog() error {
+	if scope.tinkerbellMachine.Spec.ConsoleCapture == nil || scope.tinkerbellMachine.Status.ConsoleLog != nil ||
+		scope.consoleLogReader == nil {
+		return nil
+	}
+
+	jobs, err := scope.listConsoleCaptureJobs()
+	if err != nil || len(jobs) == 0 {
+		return err
+	}
+
+	output, err := scope.consoleLogReader(scope.ctx, &jobs[0], consoleLogBytes)
+	if err != nil {
+		return fmt.Errorf("reading console output: %w", err)
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      scope.tinkerbellMachine.Name + "-console-log",
+		Namespace: scope.tinkerbellMachine.Namespace,
+	}}
+
+	if _, err := controllerutil.CreateOrPatch(scope.ctx, scope.client, cm, func() error {
+		cm.Data = map[string]string{ConsoleLogKey: output}
+
+		return controllerutil.SetOwnerReference(scope.tinkerbellMachine, cm, scope.client.Scheme())
+	}); err != nil {
+		return fmt.Errorf("saving console output: %w", err)
+	}
+
+	scope.tinkerbellMachine.Status.ConsoleLog = &corev1.LocalObjectReference{Name: cm.Name}
+	record.Eventf(scope.tinkerbellMachine, "ConsoleLogSaved", "Saved console output to ConfigMap %s", cm.Name)
+
+	return scope.removeConsoleCapture()
+}
+
+// saveEndedConsoleCapture saves the console output once the Job capturing it ended, e.g. because its capture duration
+// passed, while the machine has not provisioned yet, so the output of machines stuck before their workflow fails is
+// kept. Until then the machine is reconciled again when the capture duration passes.
+func (scope *machineReconcileScope) saveEndedConsoleCapture() error {
+	if scope.tinkerbellMachine.Spec.ConsoleCapture == nil || scope.tinkerbellMachine.Status.ConsoleLog != nil ||
+		scope.consoleLogReader == nil {
+		return nil
+	}
+
+	jobs, err := scope.listConsoleCaptureJobs()
+	if err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+
+		if jobEnded(job) {
+			return scope.saveConsoleLog()
+		}
+
+		if job.Status.StartTime == nil || job.Spec.ActiveDeadlineSeconds == nil {
+			continue
+		}
+
+		deadline := job.Status.StartTime.Add(time.Duration(*job.Spec.ActiveDeadlineSeconds) * time.Second)
+		scope.requeue(max(time.Until(deadline), consoleCaptureEndRequeueAfter))
+	}
+
+	return nil
+}
+
+// jobEnded returns true when the Job completed or failed, including when its active deadline passed.
+func jobEnded(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) &&
+			condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeConsoleCapture stops capturing the console of the machine.
+func (scope *machineReconcileScope) removeConsoleCapture() error {
+	if scope.tinkerbellMachine.Spec.ConsoleCapture == nil {
+		return nil
+	}
+
+	jobs, err := scope.listConsoleCaptureJobs()
+	if err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		err := scope.client.Delete(scope.ctx, &jobs[i], client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting console capture Job: %w", err)
+		}
+	}
+
+	return nil
+}
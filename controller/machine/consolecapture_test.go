@@ -0,0 +1,184 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+//nolint:funlen
+func Test_Machine_reconciliation_with_console_capture(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.ConsoleCapture = &infrastructurev1.ConsoleCapture{Duration: &metav1.Duration{Duration: 30 * time.Minute}}
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.BMCRef = &corev1.TypedLocalObjectReference{Name: "bmc"}
+
+	client := kubernetesClientWithObjects(t, append([]runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}, validBMC("bmc", clusterNamespace)...))
+	ctx := context.Background()
+
+	r := &machine.TinkerbellMachineReconciler{
+		Client:              client,
+		ConsoleCaptureImage: "ipmitool:latest",
+		ConsoleLogReader: func(_ context.Context, _ *batchv1.Job, limitBytes int64) (string, error) {
+			g.Expect(limitBytes).To(BeNumerically(">", 0))
+
+			return "Kernel panic - not syncing: VFS: Unable to mount root fs", nil
+		},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}}
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	wf := &tinkv1.Workflow{}
+	g.Expect(client.Get(ctx, req.NamespacedName, wf)).To(Succeed())
+	wf.Status.State = tinkv1.WorkflowStateRunning
+	g.Expect(client.Update(ctx, wf)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	jobs := &batchv1.JobList{}
+	g.Expect(client.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(1), "Expected a Job capturing the console")
+
+	job := jobs.Items[0]
+	g.Expect(job.Labels).To(HaveKeyWithValue(machine.BMCJobOperationLabel, "console-capture"))
+	g.Expect(*job.Spec.ActiveDeadlineSeconds).To(BeEquivalentTo(30 * 60))
+	g.Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("ipmitool:latest"))
+	g.Expect(job.Spec.Template.Spec.Containers[0].Env).
+		To(ContainElement(corev1.EnvVar{Name: "BMC_HOST", Value: "10.0.0.1"}))
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(1), "Expected the console to be captured by a single Job")
+
+	g.Expect(client.Get(ctx, req.NamespacedName, wf)).To(Succeed())
+	wf.Status.State = tinkv1.WorkflowStateFailed
+	g.Expect(client.Update(ctx, wf)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).To(HaveOccurred(), "Expected failed workflow to be reported")
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, req.NamespacedName, updatedMachine)).To(Succeed())
+	g.Expect(updatedMachine.Status.ConsoleLog).NotTo(BeNil(), "Expected the console log to be referenced")
+
+	cm := &corev1.ConfigMap{}
+	cmKey := types.NamespacedName{Name: updatedMachine.Status.ConsoleLog.Name, Namespace: clusterNamespace}
+	g.Expect(client.Get(ctx, cmKey, cm)).To(Succeed())
+	g.Expect(cm.Data).To(HaveKeyWithValue(machine.ConsoleLogKey, ContainSubstring("Kernel panic")))
+
+	g.Expect(client.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(BeEmpty(), "Expected the capture to stop once the console log was saved")
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_saves_console_log_once_capture_ends(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.ConsoleCapture = &infrastructurev1.ConsoleCapture{Duration: &metav1.Duration{Duration: 30 * time.Minute}}
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.BMCRef = &corev1.TypedLocalObjectReference{Name: "bmc"}
+
+	client := kubernetesClientWithObjects(t, append([]runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}, validBMC("bmc", clusterNamespace)...))
+	ctx := context.Background()
+
+	r := &machine.TinkerbellMachineReconciler{
+		Client:              client,
+		APIReader:           client,
+		ConsoleCaptureImage: "ipmitool:latest",
+		ConsoleLogReader: func(context.Context, *batchv1.Job, int64) (string, error) {
+			return "Booting from Hard Disk...\nNo bootable device", nil
+		},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}}
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	wf := &tinkv1.Workflow{}
+	g.Expect(client.Get(ctx, req.NamespacedName, wf)).To(Succeed())
+	wf.Status.State = tinkv1.WorkflowStateRunning
+	g.Expect(client.Update(ctx, wf)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	jobs := &batchv1.JobList{}
+	g.Expect(client.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(1), "Expected a Job capturing the console")
+
+	job := &jobs.Items[0]
+	job.Status.StartTime = &metav1.Time{Time: time.Now()}
+	g.Expect(client.Status().Update(ctx, job)).To(Succeed())
+
+	result, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically("~", 30*time.Minute, time.Minute),
+		"Expected the machine to be reconciled again when the capture duration passes")
+
+	job.Status.Conditions = []batchv1.JobCondition{{
+		Type:   batchv1.JobFailed,
+		Status: corev1.ConditionTrue,
+		Reason: batchv1.JobReasonDeadlineExceeded,
+	}}
+	g.Expect(client.Status().Update(ctx, job)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred(), "Expected the machine to keep provisioning")
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, req.NamespacedName, updatedMachine)).To(Succeed())
+	g.Expect(updatedMachine.Status.ConsoleLog).NotTo(BeNil(), "Expected the console log to be saved once captured")
+
+	cm := &corev1.ConfigMap{}
+	cmKey := types.NamespacedName{Name: updatedMachine.Status.ConsoleLog.Name, Namespace: clusterNamespace}
+	g.Expect(client.Get(ctx, cmKey, cm)).To(Succeed())
+	g.Expect(cm.Data).To(HaveKeyWithValue(machine.ConsoleLogKey, ContainSubstring("No bootable device")))
+
+	g.Expect(client.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(BeEmpty(), "Expected the ended capture to be removed")
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(BeEmpty(), "Expected the console not to be captured again")
+}
@@ -0,0 +1,63 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+)
+
+// ErrHardwareCoolingDown is the error returned when Hardware is not selected because it failed provisioning within
+// the hardware failure cool-down of the cluster.
+var ErrHardwareCoolingDown = fmt.Errorf("provisioning failed recently")
+
+// hardwareFailureCooldown returns the hardware failure cool-down of the cluster of the machine, zero when disabled.
+func (scope *machineReconcileScope) hardwareFailureCooldown() time.Duration {
+	if scope.tinkerbellCluster == nil || scope.tinkerbellCluster.Spec.HardwareFailureCooldown == nil {
+		return 0
+	}
+
+	return scope.tinkerbellCluster.Spec.HardwareFailureCooldown.Duration
+}
+
+// lastHardwareFailure returns when the last provisioning failure of the Hardware was recorded, the zero time when
+// none was or the annotation cannot be parsed.
+func lastHardwareFailure(hw *tinkv1.Hardware) time.Time {
+	// A malformed time is treated as no previous failure.
+	failedAt, _ := time.Parse(time.RFC3339, hw.GetAnnotations()[HardwareLastFailureTimeAnnotation])
+
+	return failedAt
+}
+
+// cooledDownHardware returns the given Hardware which did not fail provisioning within the hardware failure
+// cool-down of the cluster, all of it when the cluster has none. When none remains, the returned error lists when
+// each Hardware can be selected again.
+func (scope *machineReconcileScope) cooledDownHardware(hardware []tinkv1.Hardware) ([]tinkv1.Hardware, error) {
+	cooldown := scope.hardwareFailureCooldown()
+	if cooldown <= 0 {
+		return hardware, nil
+	}
+
+	now := time.Now()
+	available := make([]tinkv1.Hardware, 0, len(hardware))
+	coolingDown := []error{}
+
+	for i := range hardware {
+		failedAt := lastHardwareFailure(&hardware[i])
+		if until := failedAt.Add(cooldown); !failedAt.IsZero() && until.After(now) {
+			coolingDown = append(coolingDown, fmt.Errorf("Hardware %s: %w, selectable again at %s",
+				hardware[i].Name, ErrHardwareCoolingDown, until.UTC().Format(time.RFC3339)))
+
+			continue
+		}
+
+		available = append(available, hardware[i])
+	}
+
+	if len(available) == 0 && len(coolingDown) > 0 {
+		return nil, errors.Join(coolingDown...)
+	}
+
+	return available, nil
+}
@@ -0,0 +1,80 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_cooledDownHardware(t *testing.T) {
+	t.Parallel()
+
+	failedAgo := func(name string, ago time.Duration) tinkv1.Hardware {
+		return tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				HardwareLastFailureTimeAnnotation: time.Now().Add(-ago).UTC().Format(time.RFC3339),
+			},
+		}}
+	}
+
+	tests := map[string]struct {
+		cooldown *metav1.Duration
+		hardware []tinkv1.Hardware
+		want     []string
+		wantErr  error
+	}{
+		"all hardware without cool-down": {
+			hardware: []tinkv1.Hardware{failedAgo("recent", time.Minute)},
+			want:     []string{"recent"},
+		},
+		"recently failed hardware is excluded": {
+			cooldown: &metav1.Duration{Duration: time.Hour},
+			hardware: []tinkv1.Hardware{
+				failedAgo("recent", time.Minute),
+				failedAgo("old", 2*time.Hour),
+				{ObjectMeta: metav1.ObjectMeta{Name: "never"}},
+			},
+			want: []string{"old", "never"},
+		},
+		"error when all hardware is cooling down": {
+			cooldown: &metav1.Duration{Duration: time.Hour},
+			hardware: []tinkv1.Hardware{failedAgo("recent", time.Minute)},
+			wantErr:  ErrHardwareCoolingDown,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			scope := &machineReconcileScope{
+				tinkerbellCluster: &infrastructurev1.TinkerbellCluster{
+					Spec: infrastructurev1.TinkerbellClusterSpec{HardwareFailureCooldown: test.cooldown},
+				},
+			}
+
+			got, err := scope.cooledDownHardware(test.hardware)
+			if test.wantErr != nil {
+				g.Expect(err).To(MatchError(test.wantErr))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			names := []string{}
+			for _, hw := range got {
+				names = append(names, hw.Name)
+			}
+
+			g.Expect(names).To(Equal(test.want))
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+const (
+	// dataDisksConfigPath is the cloud-init configuration partitioning, formatting and mounting the data disks.
+	dataDisksConfigPath = "/etc/cloud/cloud.cfg.d/20_capt_data_disks.cfg"
+
+	// defaultDataDiskMountOptions are the mount options of data disks which do not set any. nofail keeps a failed
+	// disk from blocking the boot of the machine.
+	defaultDataDiskMountOptions = "defaults,nofail"
+)
+
+var (
+	// ErrNoMatchingDataDisk is the error returned when no disk of Hardware besides its root disk and the disks of the
+	// other data disks matches the selector of a data disk.
+	ErrNoMatchingDataDisk = fmt.Errorf("no disk matches the data disk selector")
+
+	// ErrDataDisksUnsupportedBootstrapFormat is the error returned when data disks are requested for a machine whose
+	// image is not configured with cloud-init, which prepares them.
+	ErrDataDisksUnsupportedBootstrapFormat = fmt.Errorf("data disks require cloud-config bootstrap data")
+)
+
+// WorkflowDataDisk is a data disk of the Hardware of a machine, prepared by cloud-init on first boot.
+type WorkflowDataDisk struct {
+	// Device is the stable /dev/disk/by-id path of the disk.
+	Device       string
+	Label        string
+	Filesystem   infrastructurev1.DataDiskFilesystem
+	MountPath    string
+	MountOptions string
+}
+
+// dataDisksConfig is the cloud-init configuration of data disks, see
+// https://cloudinit.readthedocs.io/en/latest/reference/modules.html#disk-setup and
+// https://cloudinit.readthedocs.io/en/latest/reference/modules.html#mounts.
+type dataDisksConfig struct {
+	DiskSetup map[string]dataDiskSetup `yaml:"disk_setup"`
+	FSSetup   []dataDiskFSSetup        `yaml:"fs_setup"`
+	Mounts    [][]string               `yaml:"mounts"`
+}
+
+type dataDiskSetup struct {
+	TableType string `yaml:"table_type"`
+	Layout    bool   `yaml:"layout"`
+	Overwrite bool   `yaml:"overwrite"`
+}
+
+type dataDiskFSSetup struct {
+	Label      string `yaml:"label"`
+	Filesystem string `yaml:"filesystem"`
+	Device     string `yaml:"device"`
+	Partition  string `yaml:"partition"`
+	Overwrite  bool   `yaml:"overwrite"`
+}
+
+// dataDisksForHardware returns the data disks of the machine on the Hardware, each on the first disk listed in the
+// HardwareDisksAnnotation matching its selector which has a stable path, is not the given root disk and is not used
+// by a previous data disk.
+func dataDisksForHardware(
+	hw *tinkv1.Hardware, rootDisk string, dataDisks []infrastructurev1.DataDisk,
+) ([]WorkflowDataDisk, error) {
+	if len(dataDisks) == 0 {
+		return nil, nil
+	}
+
+	disks, err := hardwareDisks(hw)
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{rootDisk: true}
+	resolved := make([]WorkflowDataDisk, 0, len(dataDisks))
+
+	for i := range dataDisks {
+		dataDisk := &dataDisks[i]
+		device := ""
+
+		for _, disk := range disks {
+			path := disk.stablePath()
+			if path == "" || used[path] || used[disk.Device] || !disk.matches(&dataDisk.Selector) {
+				continue
+			}
+
+			device = path
+			used[path] = true
+			used[disk.Device] = true
+
+			break
+		}
+
+		if device == "" {
+			return nil, fmt.Errorf("%w: data disk %s", ErrNoMatchingDataDisk, dataDisk.Label)
+		}
+
+		filesystem := dataDisk.Filesystem
+		if filesystem == "" {
+			filesystem = infrastructurev1.DataDiskFilesystemExt4
+		}
+
+		mountOptions := dataDisk.MountOptions
+		if mountOptions == "" {
+			mountOptions = defaultDataDiskMountOptions
+		}
+
+		resolved = append(resolved, WorkflowDataDisk{
+			Device:       device,
+			Label:        dataDisk.Label,
+			Filesystem:   filesystem,
+			MountPath:    dataDisk.MountPath,
+			MountOptions: mountOptions,
+		})
+	}
+
+	return resolved, nil
+}
+
+// dataDiskFiles returns the cloud-init configuration partitioning the data disks with a single partition, formatting
+// it and mounting it by label with an fstab entry. Disks holding a partition table and partitions holding a
+// filesystem are left as they are, so the data of a disk survives reinstalling the OS. Nil is returned without data
+// disks.
+func dataDiskFiles(disks []WorkflowDataDisk) ([]WorkflowFile, error) {
+	if len(disks) == 0 {
+		return nil, nil
+	}
+
+	config := dataDisksConfig{DiskSetup: map[string]dataDiskSetup{}}
+
+	for _, disk := range disks {
+		config.DiskSetup[disk.Device] = dataDiskSetup{TableType: "gpt", Layout: true}
+		config.FSSetup = append(config.FSSetup, dataDiskFSSetup{
+			Label:      disk.Label,
+			Filesystem: string(disk.Filesystem),
+			Device:     disk.Device,
+			Partition:  "auto",
+		})
+		config.Mounts = append(config.Mounts, []string{
+			"LABEL=" + disk.Label, disk.MountPath, string(disk.Filesystem), disk.MountOptions, "0", "2",
+		})
+	}
+
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("encoding data disks configuration: %w", err)
+	}
+
+	return []WorkflowFile{{Path: dataDisksConfigPath, Content: string(content)}}, nil
+}
+
+// dataDisks returns the data disks of the machine, if any.
+func (scope *machineReconcileScope) dataDisks() []infrastructurev1.DataDisk {
+	if scope.tinkerbellMachine.Spec.Storage == nil {
+		return nil
+	}
+
+	return scope.tinkerbellMachine.Spec.Storage.DataDisks
+}
+
+// dataDiskHardware returns the given Hardware with a disk matching each data disk of the machine besides its root
+// disk, all of it when the machine has none. When none matches, the returned error lists why.
+func (scope *machineReconcileScope) dataDiskHardware(hardware []tinkv1.Hardware) ([]tinkv1.Hardware, error) {
+	dataDisks := scope.dataDisks()
+	if len(dataDisks) == 0 {
+		return hardware, nil
+	}
+
+	matching := make([]tinkv1.Hardware, 0, len(hardware))
+	notMatching := []error{}
+
+	for i := range hardware {
+		hw := &hardware[i]
+
+		if _, err := scope.hardwareDataDisks(hw); err != nil {
+			notMatching = append(notMatching, fmt.Errorf("Hardware %s: %w", hw.Name, err))
+
+			continue
+		}
+
+		matching = append(matching, *hw)
+	}
+
+	if len(matching) == 0 && len(notMatching) > 0 {
+		return nil, errors.Join(notMatching...)
+	}
+
+	return matching, nil
+}
+
+// hardwareDataDisks returns the data disks of the machine on the given Hardware.
+func (scope *machineReconcileScope) hardwareDataDisks(hw *tinkv1.Hardware) ([]WorkflowDataDisk, error) {
+	dataDisks := scope.dataDisks()
+	if len(dataDisks) == 0 {
+		return nil, nil
+	}
+
+	if len(hw.Spec.Disks) < 1 {
+		return nil, ErrHardwareMissingDiskConfiguration
+	}
+
+	rootDisk, err := scope.targetDisk(hw)
+	if err != nil {
+		return nil, err
+	}
+
+	return dataDisksForHardware(hw, rootDisk, dataDisks)
+}
@@ -0,0 +1,118 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_Machine_reconciliation_prepares_data_disks(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+	tm.Spec.Storage = &infrastructurev1.Storage{DataDisks: []infrastructurev1.DataDisk{{
+		Label:     "etcd",
+		Selector:  infrastructurev1.RootDiskSelector{Rotational: ptr.To(false)},
+		MountPath: "/var/lib/etcd",
+	}, {
+		Label:        "data",
+		Selector:     infrastructurev1.RootDiskSelector{MinSize: ptr.To(resource.MustParse("1Ti"))},
+		Filesystem:   infrastructurev1.DataDiskFilesystemXFS,
+		MountPath:    "/var/lib/data",
+		MountOptions: "noatime,nofail",
+	}}}
+
+	// The solid state disk of the Hardware is its root disk, so no disk is left for etcd.
+	small := validHardware("small", uuid.New().String(), "10.10.0.11")
+	small.Annotations = map[string]string{
+		machine.HardwareDisksAnnotation: `[
+			{"device": "/dev/sda", "serial": "S4EVNX0N", "wwn": "0x5002538E4098A1B1", "rotational": false},
+			{"device": "/dev/sdb", "wwn": "0x5000C500A1B2C3D4", "sizeBytes": 4000787030016, "rotational": true}
+		]`,
+	}
+
+	hw := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+	hw.Annotations = map[string]string{
+		machine.HardwareDisksAnnotation: `[
+			{"device": "/dev/sda", "wwn": "0x5002538E4098A1B2", "rotational": false},
+			{"device": "/dev/sdb", "wwn": "0x5000C500A1B2C3D5", "sizeBytes": 4000787030016, "rotational": true},
+			{"device": "/dev/nvme0n1", "byID": "/dev/disk/by-id/nvme-S5GXNX0R", "rotational": false}
+		]`,
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		small,
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	g.Expect(client.Get(ctx, key, tm)).To(Succeed())
+	g.Expect(tm.Spec.HardwareName).To(Equal(hardwareName), "Expected the Hardware with a disk for each data disk")
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(ctx, key, template)).To(Succeed())
+
+	data := *template.Spec.Data
+	g.Expect(data).To(ContainSubstring("DEST_PATH: /etc/cloud/cloud.cfg.d/20_capt_data_disks.cfg"))
+	g.Expect(data).To(ContainSubstring("/dev/disk/by-id/nvme-S5GXNX0R:"))
+	g.Expect(data).To(ContainSubstring("/dev/disk/by-id/wwn-0x5000c500a1b2c3d5:"))
+	g.Expect(data).NotTo(ContainSubstring("/dev/disk/by-id/wwn-0x5002538e4098a1b2"),
+		"Expected the root disk not to be used as a data disk")
+	g.Expect(data).To(ContainSubstring("- - LABEL=etcd\n"))
+	g.Expect(data).To(ContainSubstring("- ext4\n"))
+	g.Expect(data).To(ContainSubstring("- defaults,nofail\n"))
+	g.Expect(data).To(ContainSubstring("- xfs\n"))
+	g.Expect(data).To(ContainSubstring("- noatime,nofail\n"))
+}
+
+func Test_Machine_reconciliation_without_hardware_matching_data_disks(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+	tm.Spec.Storage = &infrastructurev1.Storage{DataDisks: []infrastructurev1.DataDisk{{
+		Label:     "data",
+		Selector:  infrastructurev1.RootDiskSelector{Serial: "S4EVNX0N"},
+		MountPath: "/var/lib/data",
+	}}}
+
+	// The only disk matching is the root disk of the Hardware.
+	hw := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+	hw.Annotations = map[string]string{
+		machine.HardwareDisksAnnotation: `[{"device": "/dev/sda", "serial": "S4EVNX0N", "wwn": "0x5002538E4098A1B2"}]`,
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
+	g.Expect(err).To(MatchError(machine.ErrNoMatchingDataDisk))
+}
@@ -0,0 +1,119 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+const (
+	// HardwareDecommissionLabel is hardwareutil.DecommissionLabel. The Machine of the TinkerbellMachine provisioned
+	// on such Hardware is annotated with clusterv1.DeleteMachineAnnotation, so MachineSets and control planes delete
+	// it first when scaling down.
+	HardwareDecommissionLabel = hardwareutil.DecommissionLabel
+
+	// decommissionDeleteMachineValue is the value of the clusterv1.DeleteMachineAnnotation set by CAPT, telling it
+	// apart from the annotation set by users, which CAPT never removes.
+	decommissionDeleteMachineValue = "hardware-decommission"
+)
+
+// reconcileDeletePriority annotates the Machine with clusterv1.DeleteMachineAnnotation while the Hardware it is
+// provisioned on is labeled with HardwareDecommissionLabel, and removes the annotation CAPT set once the label is
+// removed.
+func (scope *machineReconcileScope) reconcileDeletePriority(hw *tinkv1.Hardware) error {
+	if scope.machine == nil {
+		return nil
+	}
+
+	_, decommission := hw.Labels[HardwareDecommissionLabel]
+	value, annotated := scope.machine.Annotations[clusterv1.DeleteMachineAnnotation]
+
+	raise := decommission && !annotated
+	lower := !decommission && annotated && value == decommissionDeleteMachineValue
+
+	if !raise && !lower {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(scope.machine, scope.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for Machine: %w", err)
+	}
+
+	if raise {
+		if scope.machine.Annotations == nil {
+			scope.machine.Annotations = map[string]string{}
+		}
+
+		scope.machine.Annotations[clusterv1.DeleteMachineAnnotation] = decommissionDeleteMachineValue
+	} else {
+		delete(scope.machine.Annotations, clusterv1.DeleteMachineAnnotation)
+	}
+
+	if err := patchHelper.Patch(scope.ctx, scope.machine); err != nil {
+		return fmt.Errorf("patching Machine: %w", err)
+	}
+
+	if raise {
+		record.Eventf(scope.tinkerbellMachine, "DeletePriorityRaised",
+			"Hardware %s is flagged for decommission, Machine %s is deleted first on scale down", hw.Name,
+			scope.machine.Name)
+	} else {
+		record.Eventf(scope.tinkerbellMachine, "DeletePriorityCleared",
+			"Hardware %s is no longer flagged for decommission", hw.Name)
+	}
+
+	return nil
+}
+
+// HardwareToOwnerTinkerbellMachine is a handler.MapFunc enqueueing the TinkerbellMachine which claimed the given
+// Hardware, so flagging Hardware for decommission is reflected on its Machine without waiting for a resync.
+func (r *TinkerbellMachineReconciler) HardwareToOwnerTinkerbellMachine(ctx context.Context) handler.MapFunc {
+	log := ctrl.LoggerFrom(ctx)
+
+	return func(_ context.Context, o client.Object) []ctrl.Request {
+		hw, ok := o.(*tinkv1.Hardware)
+		if !ok {
+			log.Error(
+				fmt.Errorf("expected a Hardware but got a %T", o), //nolint:goerr113
+				"failed to get owner TinkerbellMachine for Hardware",
+			)
+
+			return nil
+		}
+
+		owner, owned := hardwareutil.Owner(hw)
+		if !owned {
+			return nil
+		}
+
+		return []ctrl.Request{{NamespacedName: owner}}
+	}
+}
+
+// hardwareDecommissionChanged is a predicate passing updates of Hardware adding or removing the
+// HardwareDecommissionLabel.
+func hardwareDecommissionChanged() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			_, before := e.ObjectOld.GetLabels()[HardwareDecommissionLabel]
+			_, after := e.ObjectNew.GetLabels()[HardwareDecommissionLabel]
+
+			return before != after
+		},
+	}
+}
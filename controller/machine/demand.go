@@ -0,0 +1,226 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// anyHardwareSelector is the selector of TinkerbellMachines without hardware affinity.
+const anyHardwareSelector = "*"
+
+//nolint:gochecknoglobals
+var unsatisfiedDemand = newHardwareDemand(unsatisfiedHardwareDemand)
+
+type demandKey struct {
+	namespace string
+	selector  string
+}
+
+// hardwareDemand tracks the TinkerbellMachines waiting for Hardware, so capacity automation can add Hardware
+// matching the selectors with unsatisfied demand.
+type hardwareDemand struct {
+	mu       sync.Mutex
+	gauge    *prometheus.GaugeVec
+	machines map[types.NamespacedName]demandKey
+	counts   map[demandKey]int
+}
+
+func newHardwareDemand(gauge *prometheus.GaugeVec) *hardwareDemand {
+	return &hardwareDemand{
+		gauge:    gauge,
+		machines: map[types.NamespacedName]demandKey{},
+		counts:   map[demandKey]int{},
+	}
+}
+
+// wait records the machine as waiting for Hardware matching the selector.
+func (d *hardwareDemand) wait(machine types.NamespacedName, selector string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := demandKey{namespace: machine.Namespace, selector: selector}
+
+	previous, found := d.machines[machine]
+	if found && previous == key {
+		return
+	}
+
+	if found {
+		d.add(previous, -1)
+	}
+
+	d.machines[machine] = key
+	d.add(key, 1)
+}
+
+// satisfied records the machine as no longer waiting for Hardware.
+func (d *hardwareDemand) satisfied(machine types.NamespacedName) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key, found := d.machines[machine]
+	if !found {
+		return
+	}
+
+	delete(d.machines, machine)
+	d.add(key, -1)
+}
+
+// add adds delta to the number of machines waiting for the key, removing the series once there are none.
+func (d *hardwareDemand) add(key demandKey, delta int) {
+	d.counts[key] += delta
+
+	if d.counts[key] <= 0 {
+		delete(d.counts, key)
+		d.gauge.DeleteLabelValues(key.namespace, key.selector)
+
+		return
+	}
+
+	d.gauge.WithLabelValues(key.namespace, key.selector).Set(float64(d.counts[key]))
+}
+
+// hardwareSelector returns the canonical form of the hardware affinity, ORing the label selectors of the
+// required terms and ANDing the result with the required expression.
+func hardwareSelector(affinity *infrastructurev1.HardwareAffinity) string {
+	if affinity == nil {
+		return anyHardwareSelector
+	}
+
+	terms := make([]string, 0, len(affinity.Required))
+
+	for i := range affinity.Required {
+		term := metav1.FormatLabelSelector(&affinity.Required[i].LabelSelector)
+		if term == "<none>" {
+			term = anyHardwareSelector
+		}
+
+		terms = append(terms, term)
+	}
+
+	selector := strings.Join(terms, " || ")
+
+	if expr := strings.TrimSpace(affinity.RequiredExpression); expr != "" {
+		if selector == "" {
+			return expr
+		}
+
+		return fmt.Sprintf("(%s) && (%s)", selector, expr)
+	}
+
+	if selector == "" {
+		return anyHardwareSelector
+	}
+
+	return selector
+}
+
+// updateHardwareDemand records whether the machine waits for Hardware after selecting Hardware returned err.
+// Machines waiting for Hardware are reported by the HardwareClaimed condition and the unsatisfied demand metric.
+func (scope *machineReconcileScope) updateHardwareDemand(err error) {
+	key := types.NamespacedName{Namespace: scope.tinkerbellMachine.Namespace, Name: scope.tinkerbellMachine.Name}
+
+	if err == nil {
+		unsatisfiedDemand.satisfied(key)
+
+		if conditions.Has(scope.tinkerbellMachine, infrastructurev1.HardwareClaimedCondition) {
+			conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.HardwareClaimedCondition)
+		}
+
+		return
+	}
+
+	if errors.Is(err, ErrHardwarePoolUnavailable) {
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.HardwareClaimedCondition,
+			infrastructurev1.HardwarePoolUnavailableReason, clusterv1.ConditionSeverityError, "%s", err.Error())
+
+		return
+	}
+
+	if errors.Is(err, ErrMultipleHardwareClaimed) {
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.HardwareClaimedCondition,
+			infrastructurev1.MultipleHardwareClaimedReason, clusterv1.ConditionSeverityError, "%s", err.Error())
+
+		return
+	}
+
+	if !errors.Is(err, ErrNoHardwareAvailable) {
+		return
+	}
+
+	selector := hardwareSelector(scope.tinkerbellMachine.Spec.HardwareAffinity)
+	unsatisfiedDemand.wait(key, selector)
+
+	msg := fmt.Sprintf("Waiting for Hardware matching %s", selector)
+
+	previous := conditions.Get(scope.tinkerbellMachine, infrastructurev1.HardwareClaimedCondition)
+	if previous == nil || previous.Message != msg {
+		record.Warn(scope.tinkerbellMachine, infrastructurev1.InsufficientHardwareReason, msg)
+	}
+
+	conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.HardwareClaimedCondition,
+		infrastructurev1.InsufficientHardwareReason, clusterv1.ConditionSeverityWarning, "%s", msg)
+}
+
+// HardwareToWaitingTinkerbellMachines is a handler.MapFunc enqueuing the TinkerbellMachines waiting for Hardware
+// when unclaimed Hardware in their namespace is added or changed, e.g. by automation enrolling new servers, so
+// they claim it without waiting for their backoff to expire.
+func (r *TinkerbellMachineReconciler) HardwareToWaitingTinkerbellMachines(ctx context.Context) handler.MapFunc {
+	log := ctrl.LoggerFrom(ctx)
+
+	return func(ctx context.Context, o client.Object) []ctrl.Request {
+		hw, ok := o.(*tinkv1.Hardware)
+		if !ok {
+			log.Error(
+				fmt.Errorf("expected a Hardware but got a %T", o), //nolint:goerr113
+				"failed to get TinkerbellMachines for Hardware",
+			)
+
+			return nil
+		}
+
+		if _, owned := hw.Labels[HardwareOwnerNameLabel]; owned {
+			return nil
+		}
+
+		machines := &infrastructurev1.TinkerbellMachineList{}
+		if err := r.Client.List(ctx, machines, client.InNamespace(hw.Namespace)); err != nil {
+			log.Error(err, "failed to list TinkerbellMachines waiting for Hardware")
+
+			return nil
+		}
+
+		var requests []ctrl.Request
+
+		for i := range machines.Items {
+			m := &machines.Items[i]
+
+			if conditions.GetReason(m, infrastructurev1.HardwareClaimedCondition) != infrastructurev1.InsufficientHardwareReason {
+				continue
+			}
+
+			requests = append(requests, ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: m.Namespace, Name: m.Name},
+			})
+		}
+
+		return requests
+	}
+}
@@ -0,0 +1,81 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_hardwareDemand(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "demand"}, []string{"namespace", "selector"})
+	demand := newHardwareDemand(gauge)
+
+	first := types.NamespacedName{Namespace: "ns", Name: "first"}
+	second := types.NamespacedName{Namespace: "ns", Name: "second"}
+
+	demand.wait(first, "rack=r1")
+	demand.wait(first, "rack=r1")
+	demand.wait(second, "rack=r1")
+	g.Expect(testutil.ToFloat64(gauge.WithLabelValues("ns", "rack=r1"))).To(Equal(2.0))
+
+	demand.wait(second, "rack=r2")
+	g.Expect(testutil.ToFloat64(gauge.WithLabelValues("ns", "rack=r1"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(gauge.WithLabelValues("ns", "rack=r2"))).To(Equal(1.0))
+
+	demand.satisfied(first)
+	demand.satisfied(first)
+	demand.satisfied(second)
+	g.Expect(testutil.CollectAndCount(gauge)).To(BeZero(), "Expected series without demand to be removed")
+}
+
+func Test_hardwareSelector(t *testing.T) {
+	t.Parallel()
+
+	term := func(labels map[string]string) infrastructurev1.HardwareAffinityTerm {
+		return infrastructurev1.HardwareAffinityTerm{LabelSelector: metav1.LabelSelector{MatchLabels: labels}}
+	}
+
+	tests := map[string]struct {
+		affinity *infrastructurev1.HardwareAffinity
+		want     string
+	}{
+		"no affinity":    {want: "*"},
+		"empty affinity": {affinity: &infrastructurev1.HardwareAffinity{}, want: "*"},
+		"required terms": {
+			affinity: &infrastructurev1.HardwareAffinity{Required: []infrastructurev1.HardwareAffinityTerm{
+				term(map[string]string{"rack": "r1", "type": "worker"}),
+				term(map[string]string{"rack": "r2"}),
+			}},
+			want: "rack=r1,type=worker || rack=r2",
+		},
+		"required expression": {
+			affinity: &infrastructurev1.HardwareAffinity{RequiredExpression: "size(hardware.spec.disks) > 1"},
+			want:     "size(hardware.spec.disks) > 1",
+		},
+		"required terms and expression": {
+			affinity: &infrastructurev1.HardwareAffinity{
+				Required:           []infrastructurev1.HardwareAffinityTerm{term(map[string]string{"rack": "r1"})},
+				RequiredExpression: "size(hardware.spec.disks) > 1",
+			},
+			want: "(rack=r1) && (size(hardware.spec.disks) > 1)",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			g.Expect(hardwareSelector(tc.affinity)).To(Equal(tc.want))
+		})
+	}
+}
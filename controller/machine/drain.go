@@ -0,0 +1,124 @@
+package machine
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// drainRequeueAfter is how long to wait before checking the Pods evicted from a draining Node again.
+	drainRequeueAfter = 15 * time.Second
+
+	// nodeNameField is the field Pods are listed by to find the ones of a Node.
+	nodeNameField = "spec.nodeName"
+)
+
+// drainNode cordons the Node of the machine in the workload cluster and evicts its Pods, except mirror Pods and the
+// Pods of DaemonSets, before the Hardware is power cycled. Evictions respect PodDisruptionBudgets. Once no Pod is
+// left to evict, the Node is deleted and true returned, as well as when the machine has no Node or no workload
+// cluster client is configured.
+func (scope *machineReconcileScope) drainNode() (bool, error) {
+	if scope.workloadClusterClient == nil || scope.machine == nil || scope.tinkerbellMachine.Spec.ProviderID == "" {
+		return true, nil
+	}
+
+	cluster := client.ObjectKey{Namespace: scope.machine.Namespace, Name: scope.machine.Spec.ClusterName}
+
+	workloadClient, err := scope.workloadClusterClient(scope.ctx, cluster)
+	if err != nil {
+		return false, fmt.Errorf("getting workload cluster client: %w", err)
+	}
+
+	node, err := findNode(scope.ctx, workloadClient, scope.tinkerbellMachine.Spec.ProviderID)
+	if err != nil {
+		return false, err
+	}
+
+	// Machines whose Node never joined have nothing to drain.
+	if node == nil {
+		return true, nil
+	}
+
+	if !node.Spec.Unschedulable {
+		before := node.DeepCopy()
+		node.Spec.Unschedulable = true
+
+		if err := workloadClient.Patch(scope.ctx, node, client.MergeFrom(before)); err != nil {
+			return false, fmt.Errorf("cordoning Node %s: %w", node.Name, err)
+		}
+
+		record.Eventf(scope.tinkerbellMachine, "NodeCordoned", "Cordoned Node %s", node.Name)
+	}
+
+	pods := &corev1.PodList{}
+	if err := workloadClient.List(scope.ctx, pods, client.MatchingFields{nodeNameField: node.Name}); err != nil {
+		return false, fmt.Errorf("listing Pods of Node %s: %w", node.Name, err)
+	}
+
+	remaining := 0
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !drainable(pod) {
+			continue
+		}
+
+		remaining++
+
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}}
+		if err := workloadClient.SubResource("eviction").Create(scope.ctx, pod, eviction); err != nil {
+			// Evictions blocked by a PodDisruptionBudget are answered with TooManyRequests and retried.
+			if apierrors.IsTooManyRequests(err) || apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return false, fmt.Errorf("evicting Pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+	}
+
+	if remaining > 0 {
+		scope.log.Info("Waiting for the Pods of the Node to be evicted", "node", node.Name, "pods", remaining)
+
+		return false, nil
+	}
+
+	// The Node is removed, so the reinstalled kubelet registers it again instead of joining a cordoned Node.
+	if err := workloadClient.Delete(scope.ctx, node); err != nil && !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("deleting Node %s: %w", node.Name, err)
+	}
+
+	record.Eventf(scope.tinkerbellMachine, "NodeDrained", "Drained and removed Node %s", node.Name)
+
+	return true, nil
+}
+
+// drainable returns whether the Pod is evicted when its Node is drained: mirror Pods cannot be evicted, the Pods of
+// DaemonSets would be recreated on the Node, and Pods which terminated hold no workload anymore.
+func drainable(pod *corev1.Pod) bool {
+	if _, mirror := pod.Annotations[corev1.MirrorPodAnnotationKey]; mirror {
+		return false
+	}
+
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller && ref.Kind == "DaemonSet" {
+			return false
+		}
+	}
+
+	return true
+}
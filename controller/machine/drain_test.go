@@ -0,0 +1,93 @@
+package machine //nolint:testpackage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_drainNode(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	pod := func(name string, mutate func(*corev1.Pod)) *corev1.Pod {
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: "node"},
+		}
+		mutate(p)
+
+		return p
+	}
+
+	node := testNode(corev1.ConditionTrue, "v1.30.1")
+	workload := fake.NewClientBuilder().
+		WithObjects(
+			node,
+			pod("app", func(*corev1.Pod) {}),
+			pod("daemon", func(p *corev1.Pod) {
+				p.OwnerReferences = []metav1.OwnerReference{{
+					APIVersion: "apps/v1", Kind: "DaemonSet", Name: "daemon", UID: "daemon", Controller: ptr.To(true),
+				}}
+			}),
+			pod("mirror", func(p *corev1.Pod) {
+				p.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: "mirror"}
+			}),
+			pod("other-node", func(p *corev1.Pod) { p.Spec.NodeName = "other" }),
+		).
+		WithIndex(&corev1.Pod{}, nodeNameField, func(o client.Object) []string {
+			return []string{o.(*corev1.Pod).Spec.NodeName} //nolint:forcetypeassert
+		}).
+		Build()
+
+	scope := &machineReconcileScope{
+		log: logr.Discard(),
+		ctx: context.Background(),
+		tinkerbellMachine: &infrastructurev1.TinkerbellMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+			Spec:       infrastructurev1.TinkerbellMachineSpec{ProviderID: nodeTestProviderID},
+		},
+		machine: &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+			Spec:       clusterv1.MachineSpec{ClusterName: "cluster"},
+		},
+		workloadClusterClient: func(context.Context, client.ObjectKey) (client.Client, error) {
+			return workload, nil
+		},
+	}
+
+	drained, err := scope.drainNode()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(drained).To(BeFalse(), "Expected to wait for the evicted Pod")
+
+	g.Expect(workload.Get(scope.ctx, client.ObjectKeyFromObject(node), node)).To(Succeed())
+	g.Expect(node.Spec.Unschedulable).To(BeTrue(), "Expected the Node to be cordoned")
+
+	pods := &corev1.PodList{}
+	g.Expect(workload.List(scope.ctx, pods)).To(Succeed())
+
+	names := []string{}
+	for _, p := range pods.Items {
+		names = append(names, p.Name)
+	}
+
+	g.Expect(names).To(ConsistOf("daemon", "mirror", "other-node"))
+
+	drained, err = scope.drainNode()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(drained).To(BeTrue())
+
+	err = workload.Get(scope.ctx, client.ObjectKeyFromObject(node), node)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the drained Node to be deleted")
+}
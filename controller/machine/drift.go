@@ -0,0 +1,118 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/feature"
+)
+
+// handleBootstrapDataDrift is called once the user-data of provisioned Hardware was updated to changed bootstrap
+// data, or rendered from a recreated bootstrap data Secret. The running node still uses the old bootstrap data, so
+// the drift is recorded as an event with the given cause and, depending on the BootstrapDataDriftPolicy, the Machine
+// is marked for remediation by a MachineHealthCheck, which has Cluster API drain the Node before the Machine is
+// deleted. Control plane machines are never marked.
+func (scope *machineReconcileScope) handleBootstrapDataDrift(cause string) error {
+	record.Eventf(scope.tinkerbellMachine, "BootstrapDataDrifted", "%s, updated the Hardware user-data", cause)
+
+	if scope.tinkerbellMachine.Spec.BootstrapDataDriftPolicy != infrastructurev1.BootstrapDataDriftPolicyRemediate ||
+		scope.machine == nil {
+		return nil
+	}
+
+	if !feature.Enabled(scope.featureGates, feature.ReprovisionOnUserDataChange) {
+		scope.log.Info("Not requesting remediation of drifted machine, feature gate is disabled",
+			"featureGate", feature.ReprovisionOnUserDataChange)
+
+		return nil
+	}
+
+	// Remediating several control plane machines at once could lose the etcd quorum, so they are rolled out through
+	// their control plane provider instead.
+	if scope.isControlPlane() {
+		record.Warnf(scope.tinkerbellMachine, "RemediationRefused",
+			"Not requesting remediation of control plane Machine %s, roll out the control plane to apply the changed "+
+				"bootstrap data", scope.machine.Name)
+
+		return nil
+	}
+
+	if _, ok := scope.machine.Annotations[clusterv1.RemediateMachineAnnotation]; ok {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(scope.machine, scope.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for Machine: %w", err)
+	}
+
+	if scope.machine.Annotations == nil {
+		scope.machine.Annotations = map[string]string{}
+	}
+
+	scope.machine.Annotations[clusterv1.RemediateMachineAnnotation] = ""
+
+	if err := patchHelper.Patch(scope.ctx, scope.machine); err != nil {
+		return fmt.Errorf("patching Machine: %w", err)
+	}
+
+	record.Eventf(scope.tinkerbellMachine, "RemediationRequested",
+		"Requested remediation of Machine %s to apply the changed bootstrap data", scope.machine.Name)
+
+	return nil
+}
+
+// BootstrapSecretToTinkerbellMachines is a handler.MapFunc enqueueing the TinkerbellMachines whose Machines use the
+// given Secret as bootstrap data, so changed bootstrap data is applied to the Hardware without waiting for a resync.
+func (r *TinkerbellMachineReconciler) BootstrapSecretToTinkerbellMachines(ctx context.Context) handler.MapFunc {
+	log := ctrl.LoggerFrom(ctx)
+
+	return func(ctx context.Context, o client.Object) []ctrl.Request {
+		secret, ok := o.(*corev1.Secret)
+		if !ok {
+			return nil
+		}
+
+		clusterName, ok := secret.Labels[clusterv1.ClusterNameLabel]
+		if !ok {
+			return nil
+		}
+
+		machines := &clusterv1.MachineList{}
+		if err := r.Client.List(ctx, machines,
+			client.InNamespace(secret.Namespace),
+			client.MatchingLabels{clusterv1.ClusterNameLabel: clusterName},
+		); err != nil {
+			log.Error(err, "failed to list Machines for bootstrap Secret", "Secret", secret.Name)
+
+			return nil
+		}
+
+		var result []ctrl.Request
+
+		for _, m := range machines.Items {
+			if m.Spec.Bootstrap.DataSecretName == nil || *m.Spec.Bootstrap.DataSecretName != secret.Name {
+				continue
+			}
+
+			if m.Spec.InfrastructureRef.Kind != "TinkerbellMachine" || m.Spec.InfrastructureRef.Name == "" {
+				continue
+			}
+
+			result = append(result, ctrl.Request{
+				NamespacedName: client.ObjectKey{Namespace: m.Namespace, Name: m.Spec.InfrastructureRef.Name},
+			})
+		}
+
+		return result
+	}
+}
@@ -0,0 +1,59 @@
+package machine
+
+import (
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// endpoints returns the provisioning endpoints of the machine: each field set on the machine, otherwise on its
+// cluster. Fields set on neither are empty, leaving the controller configuration to apply.
+func (scope *machineReconcileScope) endpoints() infrastructurev1.ProvisioningEndpoints {
+	endpoints := infrastructurev1.ProvisioningEndpoints{}
+
+	if scope.tinkerbellCluster != nil && scope.tinkerbellCluster.Spec.Endpoints != nil {
+		endpoints = *scope.tinkerbellCluster.Spec.Endpoints
+	}
+
+	overrides := scope.tinkerbellMachine.Spec.Endpoints
+	if overrides == nil {
+		return endpoints
+	}
+
+	if overrides.HookURL != "" {
+		endpoints.HookURL = overrides.HookURL
+	}
+
+	if overrides.TinkServerAddress != "" {
+		endpoints.TinkServerAddress = overrides.TinkServerAddress
+	}
+
+	if overrides.SyslogHost != "" {
+		endpoints.SyslogHost = overrides.SyslogHost
+	}
+
+	if overrides.BootstrapReportURL != "" {
+		endpoints.BootstrapReportURL = overrides.BootstrapReportURL
+	}
+
+	return endpoints
+}
+
+// hookBootOptions returns the options of the iPXE script booting Hook for the machine: the endpoints of the
+// machine or its cluster, otherwise the options the controller is configured with, defaulted to TINKERBELL_IP.
+func (scope *machineReconcileScope) hookBootOptions() HookBootOptions {
+	options := scope.hookBoot
+	endpoints := scope.endpoints()
+
+	if endpoints.HookURL != "" {
+		options.URL = endpoints.HookURL
+	}
+
+	if endpoints.TinkServerAddress != "" {
+		options.TinkServerAddress = endpoints.TinkServerAddress
+	}
+
+	if endpoints.SyslogHost != "" {
+		options.SyslogHost = endpoints.SyslogHost
+	}
+
+	return options.withDefaults(tinkerbellIP())
+}
@@ -0,0 +1,180 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+const (
+	// writeFileAction is the action writing files to the OS partition.
+	writeFileAction = "quay.io/tinkerbell/actions/writefile"
+
+	// defaultFileMode is the mode of files which do not set one.
+	defaultFileMode = "0644"
+)
+
+// ErrFileContentNotFound is the error returned when the Secret or ConfigMap key holding the content of a file does
+// not exist.
+var ErrFileContentNotFound = fmt.Errorf("file content not found")
+
+// WorkflowFile is a file written to the OS partition by the default template.
+type WorkflowFile struct {
+	Path    string
+	Mode    string
+	Content string
+}
+
+// writeFile is the writefile action of a WorkflowFile, in the order its fields are rendered.
+type writeFile struct {
+	Name        string               `yaml:"name"`
+	Image       string               `yaml:"image"`
+	Timeout     int                  `yaml:"timeout"`
+	Environment writeFileEnvironment `yaml:"environment"`
+}
+
+type writeFileEnvironment struct {
+	DestDisk string `yaml:"DEST_DISK"`
+	FSType   string `yaml:"FS_TYPE"`
+	DestPath string `yaml:"DEST_PATH"`
+	UID      int    `yaml:"UID"`
+	GID      int    `yaml:"GID"`
+	Mode     string `yaml:"MODE"`
+	DirMode  string `yaml:"DIRMODE"`
+	Contents string `yaml:"CONTENTS"`
+}
+
+// fileActionName returns the name of the action writing the file at the given path.
+func fileActionName(path string) string {
+	return "add file " + path
+}
+
+// escapeTemplateActions escapes the action delimiters of s, as Tinkerbell renders templates with text/template
+// before running them.
+func escapeTemplateActions(s string) string {
+	return strings.ReplaceAll(s, "{{", `{{"{{"}}`)
+}
+
+// applyFiles adds a writefile action for each file to the Tinkerbell template data, before the last action of the
+// first task, which boots into the OS.
+func applyFiles(data string, files []WorkflowFile, destPartition, fsType string) (string, error) {
+	if len(files) == 0 {
+		return data, nil
+	}
+
+	doc, tasks, err := parseTemplate(data)
+	if err != nil {
+		return "", err
+	}
+
+	if len(tasks.Content) == 0 {
+		return "", fmt.Errorf("%w: template has no tasks", ErrMalformedTemplate)
+	}
+
+	actions := mappingValue(tasks.Content[0], "actions")
+	if actions == nil || actions.Kind != yaml.SequenceNode || len(actions.Content) == 0 {
+		return "", fmt.Errorf("%w: actions must be a list", ErrMalformedTemplate)
+	}
+
+	nodes := make([]*yaml.Node, 0, len(files))
+
+	for _, f := range files {
+		mode := f.Mode
+		if mode == "" {
+			mode = defaultFileMode
+		}
+
+		if !strings.HasPrefix(mode, "0") {
+			mode = "0" + mode
+		}
+
+		node := &yaml.Node{}
+		if err := node.Encode(writeFile{
+			Name:    fileActionName(f.Path),
+			Image:   writeFileAction,
+			Timeout: 90, //nolint:gomnd
+			Environment: writeFileEnvironment{
+				DestDisk: destPartition,
+				FSType:   fsType,
+				DestPath: escapeTemplateActions(f.Path),
+				Mode:     mode,
+				DirMode:  "0755",
+				Contents: escapeTemplateActions(f.Content),
+			},
+		}); err != nil {
+			return "", fmt.Errorf("encoding action of file %s: %w", f.Path, err)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	last := len(actions.Content) - 1
+	actions.Content = append(actions.Content[:last], append(nodes, actions.Content[last])...)
+
+	return encodeTemplate(doc)
+}
+
+// workflowFiles returns the files of the machine with their content, read from their Secret or ConfigMap if needed.
+func (scope *machineReconcileScope) workflowFiles() ([]WorkflowFile, error) {
+	files := make([]WorkflowFile, 0, len(scope.tinkerbellMachine.Spec.Files))
+
+	for _, f := range scope.tinkerbellMachine.Spec.Files {
+		content := f.Content
+
+		if from := f.ContentFrom; from != nil {
+			var err error
+
+			content, err = scope.fileContent(from.Secret, from.ConfigMap)
+			if err != nil {
+				return nil, fmt.Errorf("reading content of file %s: %w", f.Path, err)
+			}
+		}
+
+		files = append(files, WorkflowFile{Path: f.Path, Mode: f.Mode, Content: content})
+	}
+
+	return files, nil
+}
+
+// fileContent returns the value of the given Secret or ConfigMap key in the namespace of the machine.
+func (scope *machineReconcileScope) fileContent(
+	secretKey *corev1.SecretKeySelector, configMapKey *corev1.ConfigMapKeySelector,
+) (string, error) {
+	namespace := scope.tinkerbellMachine.Namespace
+
+	if secretKey != nil {
+		secret := &corev1.Secret{}
+
+		key := types.NamespacedName{Name: secretKey.Name, Namespace: namespace}
+		if err := scope.client.Get(scope.ctx, key, secret); err != nil {
+			return "", fmt.Errorf("getting Secret %s: %w", secretKey.Name, err)
+		}
+
+		value, ok := secret.Data[secretKey.Key]
+		if !ok {
+			return "", fmt.Errorf("%w: Secret %s has no key %q", ErrFileContentNotFound, secretKey.Name, secretKey.Key)
+		}
+
+		return string(value), nil
+	}
+
+	cm := &corev1.ConfigMap{}
+
+	key := types.NamespacedName{Name: configMapKey.Name, Namespace: namespace}
+	if err := scope.client.Get(scope.ctx, key, cm); err != nil {
+		return "", fmt.Errorf("getting ConfigMap %s: %w", configMapKey.Name, err)
+	}
+
+	if value, ok := cm.Data[configMapKey.Key]; ok {
+		return value, nil
+	}
+
+	if value, ok := cm.BinaryData[configMapKey.Key]; ok {
+		return string(value), nil
+	}
+
+	return "", fmt.Errorf("%w: ConfigMap %s has no key %q", ErrFileContentNotFound, configMapKey.Name, configMapKey.Key)
+}
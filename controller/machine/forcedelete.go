@@ -0,0 +1,75 @@
+package machine
+
+import (
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+// ForceDeleteAnnotation is set on a TinkerbellMachine to delete it without waiting for its workflow to finish nor
+// powering off its Hardware through the BMC, e.g. when the BMC is unreachable or was decommissioned and the machine
+// is stuck in provisioning. Its value is ignored.
+const ForceDeleteAnnotation = "tinkerbellmachine.infrastructure.cluster.x-k8s.io/force-delete"
+
+// forceDelete returns whether the TinkerbellMachine is annotated with ForceDeleteAnnotation.
+func (scope *machineReconcileScope) forceDelete() bool {
+	_, ok := scope.tinkerbellMachine.Annotations[ForceDeleteAnnotation]
+
+	return ok
+}
+
+// forceDeleteMachine removes the Template and Workflow of the machine, releases its Hardware and removes the
+// finalizer, skipping the BMC Jobs powering the Hardware off. The Hardware may still run its workflow or OS, so it
+// is quarantined before being released, which is reported in warning events on both the machine and the Hardware.
+func (scope *machineReconcileScope) forceDeleteMachine(hw *tinkv1.Hardware) error {
+	scope.log.Info("Force deleting machine, skipping hardware power off", "Hardware", hw.Name)
+
+	if err := scope.quarantineForceDeletedHardware(hw); err != nil {
+		return err
+	}
+
+	msg := "Force deleted machine %s: Hardware %s was quarantined and released without being powered off and may " +
+		"still be running"
+	record.Warnf(scope.tinkerbellMachine, "ForceDeleted", msg, scope.tinkerbellMachine.Name, hw.Name)
+	record.Warnf(hw, "ForceDeleted", msg, scope.tinkerbellMachine.Name, hw.Name)
+
+	if err := scope.removeDependencies(hw); err != nil {
+		return err
+	}
+
+	return scope.removeFinalizer()
+}
+
+// quarantineForceDeletedHardware labels the Hardware of the machine with HardwareQuarantinedLabel, so it is not
+// selected for another machine while it may still be running until an operator removes the label. The Hardware may
+// already have been released waiting for its power off; Hardware claimed by another machine is left alone.
+func (scope *machineReconcileScope) quarantineForceDeletedHardware(hw *tinkv1.Hardware) error {
+	if _, claimed := hardwareutil.Owner(hw); claimed && !scope.ownsHardware(hw) {
+		return nil
+	}
+
+	if hw.GetLabels()[HardwareQuarantinedLabel] != "" {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(hw, scope.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	if hw.Labels == nil {
+		hw.Labels = map[string]string{}
+	}
+
+	hw.Labels[HardwareQuarantinedLabel] = "true"
+
+	if err := patchHelper.Patch(scope.ctx, hw); err != nil {
+		return fmt.Errorf("patching Hardware object: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_Machine_reconciliation_force_deletes_machine_with_unreachable_bmc(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+
+	// The rufio Machine of the BMC was decommissioned along with the BMC.
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.BMCRef = &corev1.TypedLocalObjectReference{Name: "bmc"}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	machineKey := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+	hardwareKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tm := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, machineKey, tm)).To(Succeed())
+	g.Expect(client.Delete(ctx, tm)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client.Get(ctx, machineKey, tm)).To(Succeed(), "Expected the machine to wait for the power off")
+
+	tm.Annotations = map[string]string{machine.ForceDeleteAnnotation: ""}
+	g.Expect(client.Update(ctx, tm)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = client.Get(ctx, machineKey, tm)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the machine to be removed without powering off")
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, hardwareKey, updatedHardware)).To(Succeed())
+	g.Expect(updatedHardware.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel))
+	g.Expect(updatedHardware.Labels).To(HaveKeyWithValue(machine.HardwareQuarantinedLabel, "true"),
+		"Expected the Hardware which may still be running to be quarantined")
+}
@@ -3,46 +3,53 @@ package machine
 import (
 	"fmt"
 	"sort"
-	"strings"
+	"time"
 
 	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/hardwareexpr"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/providerid"
 )
 
+// The labels and annotations of claimed Hardware are defined in the hardwareutil package for integrations.
 const (
-	// HardwareOwnerNameLabel is a label set by either CAPT controllers or Tinkerbell controller to indicate
-	// that given hardware takes part of at least one workflow.
-	HardwareOwnerNameLabel = "v1alpha1.tinkerbell.org/ownerName"
+	// HardwareOwnerNameLabel is hardwareutil.OwnerNameLabel.
+	HardwareOwnerNameLabel = hardwareutil.OwnerNameLabel
 
-	// HardwareOwnerNamespaceLabel is a label set by either CAPT controllers or Tinkerbell controller to indicate
-	// that given hardware takes part of at least one workflow.
-	HardwareOwnerNamespaceLabel = "v1alpha1.tinkerbell.org/ownerNamespace"
+	// HardwareOwnerNamespaceLabel is hardwareutil.OwnerNamespaceLabel.
+	HardwareOwnerNamespaceLabel = hardwareutil.OwnerNamespaceLabel
 
-	// HardwareProvisionedAnnotation signifies that the Hardware with this annotation has be provisioned by CAPT.
-	HardwareProvisionedAnnotation = "v1alpha1.tinkerbell.org/provisioned"
+	// HardwareClusterNameLabel is hardwareutil.ClusterNameLabel.
+	HardwareClusterNameLabel = hardwareutil.ClusterNameLabel
+
+	// HardwareClusterNamespaceLabel is hardwareutil.ClusterNamespaceLabel.
+	HardwareClusterNamespaceLabel = hardwareutil.ClusterNamespaceLabel
+
+	// HardwareProvisionedAnnotation is hardwareutil.ProvisionedAnnotation.
+	HardwareProvisionedAnnotation = hardwareutil.ProvisionedAnnotation
 )
 
 var (
 	// ErrNoHardwareAvailable is the error returned when there is no hardware available for provisioning.
 	ErrNoHardwareAvailable = fmt.Errorf("no hardware available")
-	// ErrHardwareIsNil is the error returned when the given hardware resource is nil.
-	ErrHardwareIsNil = fmt.Errorf("given Hardware object is nil")
-	// ErrHardwareMissingInterfaces is the error returned when the referenced hardware does not have any
-	// network interfaces defined.
-	ErrHardwareMissingInterfaces = fmt.Errorf("hardware has no interfaces defined")
-	// ErrHardwareFirstInterfaceNotDHCP is the error returned when the referenced hardware does not have it's
-	// first network interface configured for DHCP.
-	ErrHardwareFirstInterfaceNotDHCP = fmt.Errorf("hardware's first interface has no DHCP address defined")
-	// ErrHardwareFirstInterfaceDHCPMissingIP is the error returned when the referenced hardware does not have a
-	// DHCP IP address assigned for it's first interface.
-	ErrHardwareFirstInterfaceDHCPMissingIP = fmt.Errorf("hardware's first interface has no DHCP IP address defined")
+	// ErrHardwareIsNil is hardwareutil.ErrHardwareIsNil.
+	ErrHardwareIsNil = hardwareutil.ErrHardwareIsNil
+	// ErrHardwareMissingInterfaces is hardwareutil.ErrMissingInterfaces.
+	ErrHardwareMissingInterfaces = hardwareutil.ErrMissingInterfaces
+	// ErrHardwareFirstInterfaceNotDHCP is hardwareutil.ErrFirstInterfaceNotDHCP.
+	ErrHardwareFirstInterfaceNotDHCP = hardwareutil.ErrFirstInterfaceNotDHCP
+	// ErrHardwareFirstInterfaceDHCPMissingIP is hardwareutil.ErrFirstInterfaceDHCPMissingIP.
+	ErrHardwareFirstInterfaceDHCPMissingIP = hardwareutil.ErrFirstInterfaceDHCPMissingIP
 	// ErrHardwareMissingDiskConfiguration is returned when the referenced hardware is missing
 	// disk configuration.
 	ErrHardwareMissingDiskConfiguration = fmt.Errorf("disk configuration is required")
@@ -50,27 +57,7 @@ var (
 
 // hardwareIP returns the IP address of the first network interface of the given hardware.
 func hardwareIP(hardware *tinkv1.Hardware) (string, error) {
-	if hardware == nil {
-		return "", ErrHardwareIsNil
-	}
-
-	if len(hardware.Spec.Interfaces) == 0 {
-		return "", ErrHardwareMissingInterfaces
-	}
-
-	if hardware.Spec.Interfaces[0].DHCP == nil {
-		return "", ErrHardwareFirstInterfaceNotDHCP
-	}
-
-	if hardware.Spec.Interfaces[0].DHCP.IP == nil {
-		return "", ErrHardwareFirstInterfaceDHCPMissingIP
-	}
-
-	if hardware.Spec.Interfaces[0].DHCP.IP.Address == "" {
-		return "", ErrHardwareFirstInterfaceDHCPMissingIP
-	}
-
-	return hardware.Spec.Interfaces[0].DHCP.IP.Address, nil
+	return hardwareutil.IP(hardware) //nolint:wrapcheck // The errors are aliased by this package.
 }
 
 // patchHardwareStates patches a hardware's metadata and instance states.
@@ -103,8 +90,20 @@ func (scope *machineReconcileScope) takeHardwareOwnership(hw *tinkv1.Hardware) e
 	hw.ObjectMeta.Labels[HardwareOwnerNameLabel] = scope.tinkerbellMachine.Name
 	hw.ObjectMeta.Labels[HardwareOwnerNamespaceLabel] = scope.tinkerbellMachine.Namespace
 
-	// Add finalizer to hardware as well to make sure we release it before Machine object is removed.
-	controllerutil.AddFinalizer(hw, infrastructurev1.MachineFinalizer)
+	if scope.machine != nil && scope.machine.Spec.ClusterName != "" {
+		hw.ObjectMeta.Labels[HardwareClusterNameLabel] = scope.machine.Spec.ClusterName
+		hw.ObjectMeta.Labels[HardwareClusterNamespaceLabel] = scope.tinkerbellMachine.Namespace
+	}
+
+	// Add finalizer to hardware as well to make sure we release it before Machine object is removed, unless the
+	// lifecycle of the Hardware is left to its owner.
+	if scope.hardwareFinalizerPolicy() == infrastructurev1.HardwareFinalizerPolicyNone {
+		controllerutil.RemoveFinalizer(hw, infrastructurev1.MachineFinalizer)
+	} else {
+		controllerutil.AddFinalizer(hw, infrastructurev1.MachineFinalizer)
+	}
+
+	scope.takeHardwareLease(hw)
 
 	if err := scope.client.Update(scope.ctx, hw); err != nil {
 		return fmt.Errorf("updating Hardware object: %w", err)
@@ -114,39 +113,124 @@ func (scope *machineReconcileScope) takeHardwareOwnership(hw *tinkv1.Hardware) e
 }
 
 func (scope *machineReconcileScope) ensureHardwareUserData(hw *tinkv1.Hardware, providerID string) error {
-	userData := strings.ReplaceAll(scope.bootstrapCloudConfig, providerIDPlaceholder, providerID)
+	recreated := scope.bootstrapSecretRecreated(hw)
 
-	if hw.Spec.UserData == nil || *hw.Spec.UserData != userData {
-		patchHelper, err := patch.NewHelper(hw, scope.client)
-		if err != nil {
-			return fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	if !recreated && scope.userDataUpToDate(hw, providerID) {
+		if _, ok := hw.GetAnnotations()[HardwareBootstrapSecretUIDAnnotation]; !ok && scope.bootstrapSecretUID != "" {
+			// Hardware claimed before the UID of the bootstrap data Secret was recorded.
+			return scope.patchHardwareAnnotations(hw, map[string]string{
+				HardwareBootstrapSecretUIDAnnotation: string(scope.bootstrapSecretUID),
+			})
 		}
 
-		hw.Spec.UserData = &userData
-		if err := patchHelper.Patch(scope.ctx, hw); err != nil {
-			return fmt.Errorf("patching Hardware object: %w", err)
-		}
+		return nil
+	}
+
+	if recreated {
+		scope.log.Info("Bootstrap data Secret was recreated, refreshing Hardware user-data",
+			"previousUID", hw.GetAnnotations()[HardwareBootstrapSecretUIDAnnotation], "uid", scope.bootstrapSecretUID)
+		record.Eventf(scope.tinkerbellMachine, "BootstrapSecretRecreated",
+			"Bootstrap data Secret was recreated with UID %s, refreshing the user-data of Hardware %s",
+			scope.bootstrapSecretUID, hw.Name)
+	}
+
+	userData, err := injectProviderID(scope.bootstrapFormat, scope.bootstrapCloudConfig, providerID)
+	if err != nil {
+		return fmt.Errorf("injecting provider ID into bootstrap data: %w", err)
+	}
+
+	hash := userDataHash(scope.bootstrapFormat, scope.bootstrapCloudConfig, providerID, userData)
+
+	provisioned := hw.Spec.UserData != nil && hw.ObjectMeta.GetAnnotations()[HardwareProvisionedAnnotation] == "true"
+
+	if hw.Spec.UserData != nil && *hw.Spec.UserData == userData {
+		// Hardware claimed before the hash was recorded, or whose bootstrap data Secret was recreated unchanged, e.g.
+		// by clusterctl move or a restore from a backup. The node booted with the same data, so only the new UID is
+		// recorded.
+		return scope.patchHardwareAnnotations(hw, scope.userDataAnnotations(hash))
+	}
+
+	patchHelper, err := patch.NewHelper(hw, scope.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	if hw.ObjectMeta.Annotations == nil {
+		hw.ObjectMeta.Annotations = map[string]string{}
+	}
+
+	hw.Spec.UserData = &userData
+	for k, v := range scope.userDataAnnotations(hash) {
+		hw.ObjectMeta.Annotations[k] = v
+	}
+
+	if err := patchHelper.Patch(scope.ctx, hw); err != nil {
+		return fmt.Errorf("patching Hardware object: %w", err)
+	}
+
+	// User-data of Hardware which was already provisioned by this machine no longer matches what the node booted with.
+	if provisioned {
+		return scope.handleBootstrapDataDrift("Bootstrap data changed after the machine was provisioned")
 	}
 
 	return nil
 }
 
 func (scope *machineReconcileScope) ensureHardware() (*tinkv1.Hardware, error) {
+	missing, err := scope.boundHardwareMissing()
+	if err != nil {
+		return nil, fmt.Errorf("checking bound Hardware: %w", err)
+	}
+
+	if missing {
+		if err := scope.handleMissingHardware(); err != nil {
+			return nil, fmt.Errorf("handling missing Hardware: %w", err)
+		}
+
+		// Releasing the missing Hardware updates the TinkerbellMachine, which triggers the selection of new one.
+		return nil, &errRequeueRequested{}
+	}
+
+	end := scope.trace("SelectHardware")
 	hw, err := scope.hardwareForMachine()
+	end(err)
+	scope.updateHardwareDemand(err)
+
 	if err != nil {
 		return nil, fmt.Errorf("getting hardware: %w", err)
 	}
 
+	if scope.tinkerbellMachine.Spec.HardwareName == "" {
+		available, err := scope.ensureImageAvailable(hw)
+		if err != nil {
+			return nil, err
+		}
+
+		if !available {
+			return nil, &errRequeueRequested{}
+		}
+	}
+
 	if err := scope.takeHardwareOwnership(hw); err != nil {
 		return nil, fmt.Errorf("taking Hardware ownership: %w", err)
 	}
 
-	if scope.tinkerbellMachine.Spec.HardwareName == "" {
+	if conditions.Has(scope.tinkerbellMachine, infrastructurev1.HardwareAvailableCondition) {
+		conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.HardwareAvailableCondition)
+	}
+
+	newlyClaimed := scope.tinkerbellMachine.Spec.HardwareName == ""
+	if newlyClaimed {
 		scope.log.Info("Selected Hardware for machine", "Hardware name", hw.Name)
+		recordPhase(&scope.phases().HardwareSelectedAt, time.Now())
 	}
 
 	scope.tinkerbellMachine.Spec.HardwareName = hw.Name
-	scope.tinkerbellMachine.Spec.ProviderID = fmt.Sprintf("tinkerbell://%s/%s", hw.Namespace, hw.Name)
+	scope.tinkerbellMachine.Spec.ProviderID = providerid.New(hw.Namespace, hw.Name)
+
+	if err := scope.recordWorkflowNames(newlyClaimed); err != nil {
+		return nil, err
+	}
 
 	if err := scope.ensureHardwareUserData(hw, scope.tinkerbellMachine.Spec.ProviderID); err != nil {
 		return nil, fmt.Errorf("ensuring Hardware user data: %w", err)
@@ -163,6 +247,12 @@ func (scope *machineReconcileScope) hardwareForMachine() (*tinkv1.Hardware, erro
 		return hardware, nil
 	}
 
+	if hardware, err := scope.readoptedHardware(); err != nil {
+		return nil, err
+	} else if hardware != nil {
+		return hardware, nil
+	}
+
 	// then fallback to searching for new hardware
 	hardwareSelector := scope.tinkerbellMachine.Spec.HardwareAffinity.DeepCopy()
 	if hardwareSelector == nil {
@@ -173,42 +263,131 @@ func (scope *machineReconcileScope) hardwareForMachine() (*tinkv1.Hardware, erro
 		hardwareSelector.Required = append(hardwareSelector.Required, infrastructurev1.HardwareAffinityTerm{})
 	}
 
+	sources, err := scope.hardwareSources()
+	if err != nil {
+		return nil, err
+	}
+
 	var matchingHardware []tinkv1.Hardware
 
+	requiredTerms := map[client.ObjectKey][]int{}
+
 	// OR all of the required terms by selecting each individually, we could end up with duplicates in matchingHardware
 	// but it doesn't matter
 	for i := range hardwareSelector.Required {
-		var matched tinkv1.HardwareList
-
-		// add a selector for unselected hardware
+		// add a selector for unselected hardware which is neither quarantined nor flagged for decommission
 		hardwareSelector.Required[i].LabelSelector.MatchExpressions = append(
 			hardwareSelector.Required[i].LabelSelector.MatchExpressions,
 			metav1.LabelSelectorRequirement{
 				Key:      HardwareOwnerNameLabel,
 				Operator: metav1.LabelSelectorOpDoesNotExist,
+			},
+			metav1.LabelSelectorRequirement{
+				Key:      HardwareQuarantinedLabel,
+				Operator: metav1.LabelSelectorOpDoesNotExist,
+			},
+			metav1.LabelSelectorRequirement{
+				Key:      HardwareDecommissionLabel,
+				Operator: metav1.LabelSelectorOpDoesNotExist,
 			})
 
-		selector, err := metav1.LabelSelectorAsSelector(&hardwareSelector.Required[i].LabelSelector)
+		termSelector, err := metav1.LabelSelectorAsSelector(&hardwareSelector.Required[i].LabelSelector)
 		if err != nil {
 			return nil, fmt.Errorf("converting label selector: %w", err)
 		}
 
-		if err := scope.client.List(scope.ctx, &matched, &client.ListOptions{LabelSelector: selector}); err != nil {
-			return nil, fmt.Errorf("listing hardware without owner: %w", err)
+		for _, source := range sources {
+			var matched tinkv1.HardwareList
+
+			selector, err := sourceSelector(termSelector, source)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := scope.client.List(scope.ctx, &matched, &client.ListOptions{
+				LabelSelector: selector,
+				Namespace:     source.Namespace,
+			}); err != nil {
+				return nil, fmt.Errorf("listing hardware without owner: %w", err)
+			}
+
+			matchingHardware = append(matchingHardware, matched.Items...)
+
+			for j := range matched.Items {
+				key := client.ObjectKeyFromObject(&matched.Items[j])
+				requiredTerms[key] = append(requiredTerms[key], i)
+			}
+		}
+	}
+
+	for i := range matchingHardware {
+		if err := ResolveStatusInterfaces(scope.ctx, scope.client, &matchingHardware[i]); err != nil {
+			return nil, err
 		}
+	}
+
+	matchingHardware, evalErrs, err := filterHardwareByExpression(matchingHardware, hardwareSelector.RequiredExpression)
+	if err != nil {
+		return nil, fmt.Errorf("filtering hardware by required expression: %w", err)
+	}
+
+	scope.reportExpressionErrors("requiredExpression", evalErrs)
+
+	matchingHardware, err = unpausedHardware(matchingHardware)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoHardwareAvailable, err)
+	}
+
+	matchingHardware, err = scope.idleHardware(matchingHardware)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoHardwareAvailable, err)
+	}
+
+	matchingHardware, err = scope.pinnedInterfaceHardware(matchingHardware)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoHardwareAvailable, err)
+	}
+
+	matchingHardware, err = readyHardware(matchingHardware, scope.persistentNetboot())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoHardwareAvailable, err)
+	}
+
+	matchingHardware, err = scope.rootDiskHardware(matchingHardware)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoHardwareAvailable, err)
+	}
+
+	matchingHardware, err = scope.dataDiskHardware(matchingHardware)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoHardwareAvailable, err)
+	}
 
-		matchingHardware = append(matchingHardware, matched.Items...)
+	matchingHardware, err = scope.cooledDownHardware(matchingHardware)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoHardwareAvailable, err)
 	}
 
+	matchingHardware = scope.roleHardware(matchingHardware)
+	matchingHardware = scope.reservedHardware(matchingHardware)
+
 	// finally sort by our preferred affinity terms
-	cmp, err := byHardwareAffinity(matchingHardware, hardwareSelector.Preferred)
+	scores, evalErrs, err := scoreHardware(matchingHardware, hardwareSelector.Preferred,
+		hardwareSelector.PreferredScoring, hardwareSelector.ScoreExpression)
 	if err != nil {
 		return nil, fmt.Errorf("sorting hardware by preference: %w", err)
 	}
 
-	sort.Slice(matchingHardware, cmp)
+	scope.reportExpressionErrors("scoreExpression", evalErrs)
+	matchingHardware = scoredHardware(matchingHardware, scores)
+
+	scope.scorePrewarmedHardware(matchingHardware, scores)
+
+	sort.Slice(matchingHardware, byHardwareAffinity(matchingHardware, scores))
 
 	if len(matchingHardware) > 0 {
+		scope.recordSelection(matchingHardware, requiredTerms, scores)
+
 		return &matchingHardware[0], nil
 	}
 	// nothing was found
@@ -216,58 +395,246 @@ func (scope *machineReconcileScope) hardwareForMachine() (*tinkv1.Hardware, erro
 }
 
 // assignedHardware returns hardware that is already assigned. In the event of no hardware being assigned, it returns
-// nil, nil.
+// nil, nil. Hardware of machines selecting from a HardwarePool is looked up in all namespaces, so it is found even
+// when the pool changed or was removed.
 func (scope *machineReconcileScope) assignedHardware() (*tinkv1.Hardware, error) {
+	opts := []client.ListOption{
+		client.MatchingLabels{
+			HardwareOwnerNameLabel:      scope.tinkerbellMachine.Name,
+			HardwareOwnerNamespaceLabel: scope.tinkerbellMachine.Namespace,
+		},
+	}
+
+	if scope.tinkerbellMachine.Spec.HardwarePool == "" {
+		opts = append(opts, client.InNamespace(scope.tinkerbellMachine.Namespace))
+	}
+
 	var selectedHardware tinkv1.HardwareList
-	if err := scope.client.List(scope.ctx, &selectedHardware, client.MatchingLabels{
-		HardwareOwnerNameLabel:      scope.tinkerbellMachine.Name,
-		HardwareOwnerNamespaceLabel: scope.tinkerbellMachine.Namespace,
-	}); err != nil {
+	if err := scope.client.List(scope.ctx, &selectedHardware, opts...); err != nil {
 		return nil, fmt.Errorf("listing hardware with owner: %w", err)
 	}
 
+	if len(selectedHardware.Items) > 1 {
+		hw, err := scope.resolveMultipleClaims(selectedHardware.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		return hw, ResolveStatusInterfaces(scope.ctx, scope.client, hw)
+	}
+
 	if len(selectedHardware.Items) > 0 {
-		return &selectedHardware.Items[0], nil
+		hw := &selectedHardware.Items[0]
+
+		return hw, ResolveStatusInterfaces(scope.ctx, scope.client, hw)
 	}
 
 	return nil, nil
 }
 
-//nolint:lll
-func byHardwareAffinity(hardware []tinkv1.Hardware, preferred []infrastructurev1.WeightedHardwareAffinityTerm) (func(i int, j int) bool, error) {
-	scores := map[client.ObjectKey]int32{}
+// hardwareMatchingAffinity returns the given Hardware matching the required terms and the required expression of
+// the hardware affinity, regardless of whether it is claimed.
+func hardwareMatchingAffinity(
+	hardware []tinkv1.Hardware,
+	affinity *infrastructurev1.HardwareAffinity,
+) ([]tinkv1.Hardware, error) {
+	if affinity == nil {
+		return hardware, nil
+	}
+
+	selectors := make([]labels.Selector, 0, len(affinity.Required))
+
+	for i := range affinity.Required {
+		selector, err := metav1.LabelSelectorAsSelector(&affinity.Required[i].LabelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("converting label selector: %w", err)
+		}
+
+		selectors = append(selectors, selector)
+	}
+
+	var matched []tinkv1.Hardware
+
+	for i := range hardware {
+		if len(selectors) == 0 {
+			matched = append(matched, hardware[i])
+
+			continue
+		}
+
+		for _, selector := range selectors {
+			if selector.Matches(labels.Set(hardware[i].Labels)) {
+				matched = append(matched, hardware[i])
+
+				break
+			}
+		}
+	}
+
+	matched, _, err := filterHardwareByExpression(matched, affinity.RequiredExpression)
+
+	return matched, err
+}
+
+// filterHardwareByExpression returns the hardware matching the given CEL expression. All hardware is returned when
+// the expression is empty. Hardware the expression fails to be evaluated against is skipped, with the failures
+// returned as evalErrs, so one unusual Hardware does not prevent the selection of the others.
+func filterHardwareByExpression(
+	hardware []tinkv1.Hardware,
+	expr string,
+) (_ []tinkv1.Hardware, evalErrs []error, _ error) {
+	if expr == "" {
+		return hardware, nil, nil
+	}
+
+	program, err := hardwareexpr.CompileFilter(expr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("compiling required expression: %w", err)
+	}
+
+	var matched []tinkv1.Hardware
+
+	for i := range hardware {
+		ok, err := program.Matches(&hardware[i])
+		if err != nil {
+			evalErrs = append(evalErrs, fmt.Errorf("hardware %s/%s: %w", hardware[i].Namespace, hardware[i].Name, err))
+
+			continue
+		}
+
+		if ok {
+			matched = append(matched, hardware[i])
+		}
+	}
+
+	return matched, evalErrs, nil
+}
+
+// scoreHardware scores the hardware by the weights of the preferred terms it matches, plus the score computed by
+// the score expression, if any. When the hardware matches several preferred terms, the weights are summed for the
+// Sum scoring, otherwise the weight of the last one is kept. Hardware the score expression fails to be evaluated
+// against has no score, with the failures returned as evalErrs.
+//
+//nolint:cyclop
+func scoreHardware(
+	hardware []tinkv1.Hardware,
+	preferred []infrastructurev1.WeightedHardwareAffinityTerm,
+	scoring infrastructurev1.PreferredScoring,
+	scoreExpression string,
+) (_ map[client.ObjectKey]*hardwareScore, evalErrs []error, _ error) {
+	scores := map[client.ObjectKey]*hardwareScore{}
+
+	for i := range hardware {
+		scores[client.ObjectKeyFromObject(&hardware[i])] = &hardwareScore{}
+	}
+
 	// compute scores for each item based on the preferred term weights
-	for _, term := range preferred {
+	for t, term := range preferred {
 		selector, err := metav1.LabelSelectorAsSelector(&term.HardwareAffinityTerm.LabelSelector)
 		if err != nil {
-			return nil, fmt.Errorf("constructing label selector: %w", err)
+			return nil, nil, fmt.Errorf("constructing label selector: %w", err)
 		}
 
 		for i := range hardware {
 			hw := &hardware[i]
 			if selector.Matches(labels.Set(hw.Labels)) {
-				scores[client.ObjectKeyFromObject(hw)] = term.Weight
+				score := scores[client.ObjectKeyFromObject(hw)]
+
+				if scoring == infrastructurev1.PreferredScoringSum {
+					score.Weight += int64(term.Weight)
+				} else {
+					score.Weight = int64(term.Weight)
+				}
+
+				score.PreferredTerms = append(score.PreferredTerms, t)
 			}
 		}
 	}
 
+	// add the score computed by the score expression, if any
+	if scoreExpression != "" {
+		program, err := hardwareexpr.CompileScore(scoreExpression)
+		if err != nil {
+			return nil, nil, fmt.Errorf("compiling score expression: %w", err)
+		}
+
+		for i := range hardware {
+			hw := &hardware[i]
+
+			score, err := program.Score(hw)
+			if err != nil {
+				evalErrs = append(evalErrs, fmt.Errorf("hardware %s/%s: %w", hw.Namespace, hw.Name, err))
+				delete(scores, client.ObjectKeyFromObject(hw))
+
+				continue
+			}
+
+			scores[client.ObjectKeyFromObject(hw)].ExpressionScore = score
+		}
+	}
+
+	return scores, evalErrs, nil
+}
+
+// scoredHardware returns the hardware which has a score.
+func scoredHardware(hardware []tinkv1.Hardware, scores map[client.ObjectKey]*hardwareScore) []tinkv1.Hardware {
+	scored := hardware[:0]
+
+	for i := range hardware {
+		if _, ok := scores[client.ObjectKeyFromObject(&hardware[i])]; ok {
+			scored = append(scored, hardware[i])
+		}
+	}
+
+	return scored
+}
+
+// reportExpressionErrors logs and records an event for the Hardware skipped because the given expression of the
+// hardware affinity failed to be evaluated against it.
+func (scope *machineReconcileScope) reportExpressionErrors(expression string, evalErrs []error) {
+	if len(evalErrs) == 0 {
+		return
+	}
+
+	for _, err := range evalErrs {
+		scope.log.Info("Skipping Hardware the hardware affinity expression failed to be evaluated against",
+			"expression", expression, "error", err.Error())
+	}
+
+	record.Warnf(scope.tinkerbellMachine, "HardwareExpressionFailed",
+		"Skipped %d Hardware the %s failed to be evaluated against, e.g. %s", len(evalErrs), expression, evalErrs[0])
+}
+
+func byHardwareAffinity(hardware []tinkv1.Hardware, scores map[client.ObjectKey]*hardwareScore) func(i int, j int) bool {
 	return func(i, j int) bool {
-		lhsScore := scores[client.ObjectKeyFromObject(&hardware[i])]
-		rhsScore := scores[client.ObjectKeyFromObject(&hardware[j])]
+		lhs := scores[client.ObjectKeyFromObject(&hardware[i])]
+		rhs := scores[client.ObjectKeyFromObject(&hardware[j])]
 		// sort by score in descending order
-		if lhsScore > rhsScore {
+		if lhs.total() > rhs.total() {
 			return true
-		} else if lhsScore < rhsScore {
+		} else if lhs.total() < rhs.total() {
 			return false
 		}
 
+		// prefer hardware pre-warmed with the image of the machine
+		if lhs.Prewarmed != rhs.Prewarmed {
+			return lhs.Prewarmed
+		}
+
 		// just give a consistent ordering so we predictably pick one if scores are equal
 		if hardware[i].Namespace != hardware[j].Namespace {
 			return hardware[i].Namespace < hardware[j].Namespace
 		}
 
 		return hardware[i].Name < hardware[j].Name
-	}, nil
+	}
+}
+
+// ownsHardware returns whether the Hardware is claimed by the machine.
+func (scope *machineReconcileScope) ownsHardware(hw *tinkv1.Hardware) bool {
+	owner, owned := hardwareutil.Owner(hw)
+
+	return owned && owner == client.ObjectKeyFromObject(scope.tinkerbellMachine)
 }
 
 func (scope *machineReconcileScope) releaseHardware(hw *tinkv1.Hardware) error {
@@ -276,11 +643,9 @@ func (scope *machineReconcileScope) releaseHardware(hw *tinkv1.Hardware) error {
 		return fmt.Errorf("initializing patch helper for selected hardware: %w", err)
 	}
 
-	delete(hw.ObjectMeta.Labels, HardwareOwnerNameLabel)
-	delete(hw.ObjectMeta.Labels, HardwareOwnerNamespaceLabel)
-	delete(hw.ObjectMeta.Annotations, HardwareProvisionedAnnotation)
-
-	controllerutil.RemoveFinalizer(hw, infrastructurev1.MachineFinalizer)
+	ClearHardwareOwnership(hw)
+	scope.clearPersistentNetbootScript(hw)
+	clearHookScripts(hw)
 
 	if err := patchHelper.Patch(scope.ctx, hw); err != nil {
 		return fmt.Errorf("patching Hardware object: %w", err)
@@ -289,15 +654,32 @@ func (scope *machineReconcileScope) releaseHardware(hw *tinkv1.Hardware) error {
 	return nil
 }
 
+// ClearHardwareOwnership removes the labels, annotations and finalizer CAPT sets on claimed Hardware, so it
+// becomes available for other machines. The change is only made to the given object and must be persisted
+// by the caller.
+func ClearHardwareOwnership(hw *tinkv1.Hardware) {
+	delete(hw.ObjectMeta.Labels, HardwareOwnerNameLabel)
+	delete(hw.ObjectMeta.Labels, HardwareOwnerNamespaceLabel)
+	delete(hw.ObjectMeta.Labels, HardwareClusterNameLabel)
+	delete(hw.ObjectMeta.Labels, HardwareClusterNamespaceLabel)
+	delete(hw.ObjectMeta.Annotations, HardwareProvisionedAnnotation)
+	delete(hw.ObjectMeta.Annotations, HardwareUserDataHashAnnotation)
+	delete(hw.ObjectMeta.Annotations, HardwareBootstrapSecretUIDAnnotation)
+	delete(hw.ObjectMeta.Annotations, HardwareNetbootHandshakeAnnotation)
+	clearHardwareLease(hw)
+
+	controllerutil.RemoveFinalizer(hw, infrastructurev1.MachineFinalizer)
+}
+
 func (scope *machineReconcileScope) getHardwareForMachine(hardware *tinkv1.Hardware) error {
 	namespacedName := types.NamespacedName{
 		Name:      scope.tinkerbellMachine.Spec.HardwareName,
-		Namespace: scope.tinkerbellMachine.Namespace,
+		Namespace: scope.hardwareNamespace(),
 	}
 
 	if err := scope.client.Get(scope.ctx, namespacedName, hardware); err != nil {
 		return fmt.Errorf("getting hardware: %w", err)
 	}
 
-	return nil
+	return ResolveStatusInterfaces(scope.ctx, scope.client, hardware)
 }
@@ -0,0 +1,107 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResolveStatusInterfaces fills in the interface and disk data of Hardware created by older Tinkerbell versions,
+// which recorded it in the status of the Hardware instead of its spec. The current Hardware type has no such
+// status fields, so they are read from the raw object through c, which is only done for Hardware missing some of
+// that data. The manager caches unstructured objects, so selecting among many such Hardware does not query the API
+// server for each of them. The spec takes precedence: only interfaces, MAC and IP addresses, netboot settings and
+// disks missing from it are taken from the status. Only the given object is changed; the resolved interfaces are
+// written to the Hardware along with the netboot settings CAPT patches, the resolved disks never are.
+func ResolveStatusInterfaces(ctx context.Context, c client.Reader, hw *tinkv1.Hardware) error {
+	if !missingInterfaceData(hw) {
+		return nil
+	}
+
+	raw := &unstructured.Unstructured{}
+	raw.SetGroupVersionKind(tinkv1.GroupVersion.WithKind("Hardware"))
+
+	if err := c.Get(ctx, client.ObjectKeyFromObject(hw), raw); err != nil {
+		return fmt.Errorf("getting Hardware %s/%s: %w", hw.Namespace, hw.Name, err)
+	}
+
+	status := struct {
+		Interfaces []tinkv1.Interface `json:"interfaces,omitempty"`
+		Disks      []tinkv1.Disk      `json:"disks,omitempty"`
+	}{}
+
+	if content, found := raw.Object["status"].(map[string]any); found {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, &status); err != nil {
+			return fmt.Errorf("reading status of Hardware %s/%s: %w", hw.Namespace, hw.Name, err)
+		}
+	}
+
+	hw.Spec.Interfaces = mergeInterfaces(hw.Spec.Interfaces, status.Interfaces)
+
+	if len(hw.Spec.Disks) == 0 {
+		hw.Spec.Disks = status.Disks
+	}
+
+	return nil
+}
+
+// missingInterfaceData returns true when the Hardware has no interface or disk, or an interface without a MAC or
+// IP address, which older Tinkerbell versions may have recorded in the status of the Hardware instead.
+func missingInterfaceData(hw *tinkv1.Hardware) bool {
+	if len(hw.Spec.Interfaces) == 0 || len(hw.Spec.Disks) == 0 {
+		return true
+	}
+
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP == nil || iface.DHCP.MAC == "" || iface.DHCP.IP == nil || iface.DHCP.IP.Address == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mergeInterfaces fills in the interfaces of the spec with the data missing from them in the status interfaces at
+// the same index, and appends the status interfaces the spec has no counterpart for.
+func mergeInterfaces(spec, status []tinkv1.Interface) []tinkv1.Interface {
+	merged := make([]tinkv1.Interface, 0, max(len(spec), len(status)))
+
+	for i := range max(len(spec), len(status)) {
+		if i >= len(spec) {
+			merged = append(merged, status[i])
+
+			continue
+		}
+
+		iface := *spec[i].DeepCopy()
+
+		if i < len(status) {
+			fallback := status[i]
+
+			if iface.Netboot == nil {
+				iface.Netboot = fallback.Netboot
+			}
+
+			switch {
+			case iface.DHCP == nil:
+				iface.DHCP = fallback.DHCP
+			case fallback.DHCP != nil:
+				if iface.DHCP.MAC == "" {
+					iface.DHCP.MAC = fallback.DHCP.MAC
+				}
+
+				if iface.DHCP.IP == nil || iface.DHCP.IP.Address == "" {
+					iface.DHCP.IP = fallback.DHCP.IP
+				}
+			}
+		}
+
+		merged = append(merged, iface)
+	}
+
+	return merged
+}
@@ -0,0 +1,129 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+// legacyHardwareClient returns a client serving the given status for Hardware read as unstructured objects, the
+// way older Tinkerbell versions stored it.
+func legacyHardwareClient(t *testing.T, status map[string]any, objects ...client.Object) client.Client {
+	t.Helper()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	return interceptor.NewClient(c, interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object,
+			opts ...client.GetOption,
+		) error {
+			if err := c.Get(ctx, key, obj, opts...); err != nil {
+				return err
+			}
+
+			if raw, ok := obj.(*unstructured.Unstructured); ok {
+				raw.Object["status"] = runtime.DeepCopyJSON(status)
+			}
+
+			return nil
+		},
+	})
+}
+
+func Test_ResolveStatusInterfaces(t *testing.T) {
+	t.Parallel()
+
+	status := map[string]any{
+		"interfaces": []any{
+			map[string]any{
+				"dhcp": map[string]any{
+					"mac":      "00:00:00:00:00:01",
+					"hostname": "legacy",
+					"ip":       map[string]any{"address": "10.0.0.1"},
+				},
+				"netboot": map[string]any{"allowPXE": true, "allowWorkflow": true},
+			},
+			map[string]any{
+				"dhcp": map[string]any{"mac": "00:00:00:00:00:02"},
+			},
+		},
+		"disks": []any{map[string]any{"device": "/dev/sda"}},
+	}
+
+	t.Run("spec_takes_precedence_and_status_fills_in_missing_data", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		hw := &tinkv1.Hardware{
+			ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"},
+			Spec: tinkv1.HardwareSpec{
+				Interfaces: []tinkv1.Interface{{DHCP: &tinkv1.DHCP{Hostname: "spec"}}},
+			},
+		}
+
+		c := legacyHardwareClient(t, status, hw.DeepCopy())
+		g.Expect(machine.ResolveStatusInterfaces(context.Background(), c, hw)).To(Succeed())
+
+		g.Expect(hw.Spec.Interfaces).To(HaveLen(2))
+		g.Expect(hw.Spec.Interfaces[0].DHCP.Hostname).To(Equal("spec"))
+		g.Expect(hw.Spec.Interfaces[0].DHCP.MAC).To(Equal("00:00:00:00:00:01"))
+		g.Expect(hw.Spec.Interfaces[0].DHCP.IP.Address).To(Equal("10.0.0.1"))
+		g.Expect(*hw.Spec.Interfaces[0].Netboot.AllowPXE).To(BeTrue())
+		g.Expect(hw.Spec.Interfaces[1].DHCP.MAC).To(Equal("00:00:00:00:00:02"))
+		g.Expect(hw.Spec.Disks).To(Equal([]tinkv1.Disk{{Device: "/dev/sda"}}))
+	})
+
+	t.Run("complete_spec_is_left_alone", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		hw := &tinkv1.Hardware{
+			ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"},
+			Spec: tinkv1.HardwareSpec{
+				Interfaces: []tinkv1.Interface{{
+					DHCP: &tinkv1.DHCP{MAC: "00:00:00:00:00:0a", IP: &tinkv1.IP{Address: "10.0.0.10"}},
+				}},
+				Disks: []tinkv1.Disk{{Device: "/dev/nvme0n1"}},
+			},
+		}
+		expected := hw.Spec.DeepCopy()
+
+		c := legacyHardwareClient(t, status, hw.DeepCopy())
+		g.Expect(machine.ResolveStatusInterfaces(context.Background(), c, hw)).To(Succeed())
+
+		g.Expect(hw.Spec).To(Equal(*expected))
+	})
+
+	t.Run("hardware_without_status_interfaces_is_unchanged", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		hw := &tinkv1.Hardware{
+			ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"},
+			Spec: tinkv1.HardwareSpec{
+				Interfaces: []tinkv1.Interface{{DHCP: &tinkv1.DHCP{MAC: "00:00:00:00:00:0a"}}},
+			},
+		}
+
+		c := legacyHardwareClient(t, map[string]any{"state": ""}, hw.DeepCopy())
+		g.Expect(machine.ResolveStatusInterfaces(context.Background(), c, hw)).To(Succeed())
+
+		g.Expect(hw.Spec.Interfaces).To(HaveLen(1))
+		g.Expect(hw.Spec.Interfaces[0].DHCP.IP).To(BeNil())
+		g.Expect(hw.Spec.Disks).To(BeEmpty())
+	})
+}
@@ -0,0 +1,62 @@
+package machine
+
+import (
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// missingHardwareRequeueAfter is how long until a ready machine whose Hardware is missing, and which is kept rather
+// than failed, checks again whether its Hardware was re-added.
+const missingHardwareRequeueAfter = time.Minute
+
+// hardwareFinalizerPolicy returns whether CAPT adds its finalizer to the Hardware claimed by the machine.
+func (scope *machineReconcileScope) hardwareFinalizerPolicy() infrastructurev1.HardwareFinalizerPolicy {
+	if scope.tinkerbellCluster == nil || scope.tinkerbellCluster.Spec.HardwareFinalizerPolicy == "" {
+		return infrastructurev1.HardwareFinalizerPolicyAdd
+	}
+
+	return scope.tinkerbellCluster.Spec.HardwareFinalizerPolicy
+}
+
+// readoptedHardware returns the Hardware the machine is bound to when it lost the labels recording the binding,
+// because it was deleted and re-added, e.g. pruned and applied again by GitOps. Hardware is only re-adopted when
+// the cluster adds no finalizer to it, and Hardware bound to another machine is never re-adopted. Re-adopted
+// Hardware of a ready machine is marked as provisioned again, so the running node is not provisioned again.
+func (scope *machineReconcileScope) readoptedHardware() (*tinkv1.Hardware, error) {
+	if scope.hardwareFinalizerPolicy() != infrastructurev1.HardwareFinalizerPolicyNone ||
+		scope.tinkerbellMachine.Spec.HardwareName == "" {
+		return nil, nil
+	}
+
+	hw := &tinkv1.Hardware{}
+	if err := scope.getHardwareForMachine(hw); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if _, bound := hw.GetLabels()[HardwareOwnerNameLabel]; bound {
+		return nil, nil
+	}
+
+	if scope.tinkerbellMachine.Status.Ready {
+		if hw.Annotations == nil {
+			hw.Annotations = map[string]string{}
+		}
+
+		hw.Annotations[HardwareProvisionedAnnotation] = "true"
+	}
+
+	scope.log.Info("Re-adopting Hardware which lost its binding to the machine", "Hardware name", hw.Name)
+	record.Eventf(scope.tinkerbellMachine, "HardwareReadopted",
+		"Re-adopted Hardware %s, which was re-added without its binding to the machine", hw.Name)
+
+	return hw, nil
+}
@@ -0,0 +1,87 @@
+package machine
+
+import (
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// boundHardwareMissing returns true when the TinkerbellMachine is bound to Hardware which no longer exists, for
+// example because it was deleted after its finalizer was removed by hand.
+func (scope *machineReconcileScope) boundHardwareMissing() (bool, error) {
+	if scope.tinkerbellMachine.Spec.HardwareName == "" {
+		return false, nil
+	}
+
+	err := scope.getHardwareForMachine(&tinkv1.Hardware{})
+
+	switch {
+	case apierrors.IsNotFound(err):
+		return true, nil
+	case err != nil:
+		return false, err
+	default:
+		return false, nil
+	}
+}
+
+// handleMissingHardware reports the missing Hardware through the HardwareAvailable condition and a warning event,
+// then either releases it so new Hardware is selected or marks the machine as failed, depending on the
+// HardwareMissingPolicy of the TinkerbellMachine. Ready machines of clusters adding no finalizer to Hardware wait
+// for it to be re-added instead.
+func (scope *machineReconcileScope) handleMissingHardware() error {
+	name := scope.tinkerbellMachine.Spec.HardwareName
+	msg := fmt.Sprintf("bound Hardware %s no longer exists", name)
+
+	previous := conditions.Get(scope.tinkerbellMachine, infrastructurev1.HardwareAvailableCondition)
+	if previous == nil || previous.Reason != infrastructurev1.HardwareMissingReason || previous.Message != msg {
+		record.Warn(scope.tinkerbellMachine, infrastructurev1.HardwareMissingReason, msg)
+	}
+
+	conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.HardwareAvailableCondition,
+		infrastructurev1.HardwareMissingReason, clusterv1.ConditionSeverityError, "%s", msg)
+
+	// Without finalizer, Hardware managed through GitOps may be pruned and applied again, while the node keeps running.
+	if scope.tinkerbellMachine.Status.Ready &&
+		scope.hardwareFinalizerPolicy() == infrastructurev1.HardwareFinalizerPolicyNone {
+		scope.log.Info("Bound Hardware is missing, waiting for it to be re-added", "Hardware name", name)
+		scope.requeue(missingHardwareRequeueAfter)
+
+		return scope.patch()
+	}
+
+	if scope.tinkerbellMachine.Spec.HardwareMissingPolicy != infrastructurev1.HardwareMissingPolicyReselect ||
+		scope.tinkerbellMachine.Status.Ready {
+		scope.log.Info("Bound Hardware is missing, marking machine as failed", "Hardware name", name)
+
+		scope.tinkerbellMachine.Status.ErrorReason = ptr.To(capierrors.UpdateMachineError)
+		scope.tinkerbellMachine.Status.ErrorMessage = ptr.To(msg)
+
+		return scope.patch()
+	}
+
+	scope.log.Info("Bound Hardware is missing, selecting new Hardware", "Hardware name", name)
+
+	// The Template and Workflow reference the missing Hardware, so they are created again for the new one.
+	if err := scope.removeTemplate(); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("removing Template: %w", err)
+	}
+
+	if err := scope.removeWorkflow(); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("removing Workflow: %w", err)
+	}
+
+	scope.tinkerbellMachine.Spec.HardwareName = ""
+	scope.tinkerbellMachine.Spec.ProviderID = ""
+	scope.tinkerbellMachine.Status.Addresses = nil
+
+	return scope.patch()
+}
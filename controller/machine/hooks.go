@@ -0,0 +1,69 @@
+package machine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/record"
+)
+
+// pendingPreTerminateHooks returns the pre-terminate deletion hooks which still block the teardown of the
+// machine's hardware.
+//
+// Hooks are read from both the owning CAPI Machine and the TinkerbellMachine itself, so external systems
+// (e.g. a CMDB, DHCP reservations or switch port automation) can keep the hardware bound and powered on
+// until they acknowledge the deletion by removing their annotation. See
+// https://cluster-api.sigs.k8s.io/tasks/experimental-features/runtime-sdk/implement-lifecycle-hooks
+// for the annotation format.
+func (scope *machineReconcileScope) pendingPreTerminateHooks() ([]string, error) {
+	annotations := []map[string]string{scope.tinkerbellMachine.GetAnnotations()}
+
+	machine, err := util.GetOwnerMachine(scope.ctx, scope.client, scope.tinkerbellMachine.ObjectMeta)
+
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return nil, fmt.Errorf("getting Machine object: %w", err)
+	case machine != nil:
+		annotations = append(annotations, machine.GetAnnotations())
+	}
+
+	var hooks []string
+
+	for _, a := range annotations {
+		for k := range a {
+			if strings.HasPrefix(k, clusterv1.PreTerminateDeleteHookAnnotationPrefix) {
+				hooks = append(hooks, k)
+			}
+		}
+	}
+
+	sort.Strings(hooks)
+
+	return hooks, nil
+}
+
+// waitForPreTerminateHooks reports whether the hardware teardown must wait for pre-terminate hooks. The
+// TinkerbellMachine is reconciled again once the hook annotations are removed, through the Machine watch
+// or an update of the TinkerbellMachine itself.
+func (scope *machineReconcileScope) waitForPreTerminateHooks() (bool, error) {
+	hooks, err := scope.pendingPreTerminateHooks()
+	if err != nil {
+		return false, err
+	}
+
+	if len(hooks) == 0 {
+		return false, nil
+	}
+
+	scope.log.Info("Waiting for pre-terminate hooks before releasing and powering off hardware", "hooks", hooks)
+
+	record.Eventf(scope.tinkerbellMachine, "WaitingForPreTerminateHooks",
+		"Waiting for pre-terminate hooks before releasing and powering off hardware: %s", strings.Join(hooks, ", "))
+
+	return true, nil
+}
@@ -0,0 +1,203 @@
+package machine
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+const (
+	// imageCheckTimeout is how long the pre-flight check of an image waits for the server to respond. The check
+	// runs while reconciling a machine, so it is kept short.
+	imageCheckTimeout = 5 * time.Second
+
+	// imageCheckCacheTTL is how long the result of the pre-flight check of an image is reused for all machines
+	// provisioning it.
+	imageCheckCacheTTL = 30 * time.Second
+
+	// imageUnavailableRequeueAfter is how long to wait before checking an unavailable image again.
+	imageUnavailableRequeueAfter = time.Minute
+)
+
+var (
+	// ErrImageUnavailable is the error returned when the OS image of a machine can not be reached.
+	ErrImageUnavailable = fmt.Errorf("image is unavailable")
+
+	// ErrNoCertificatesInCABundle is the error returned when the CA bundle of the image check has no certificates.
+	ErrNoCertificatesInCABundle = fmt.Errorf("no certificates found in CA bundle")
+)
+
+// ImageChecker returns an error when the OS image at the given URL can not be downloaded.
+type ImageChecker func(ctx context.Context, imageURL string) error
+
+// NewHTTPImageChecker returns an ImageChecker sending a HEAD request for HTTP(S) images with the given client.
+// Servers not supporting HEAD requests are sent a GET request whose body is not read. Images with other URL
+// schemes are assumed to be available. Results are cached for imageCheckCacheTTL.
+func NewHTTPImageChecker(client *http.Client) ImageChecker {
+	return cachedImageChecker(func(ctx context.Context, imageURL string) error {
+		u, err := url.Parse(imageURL)
+		if err != nil {
+			return fmt.Errorf("%w: parsing URL: %w", ErrImageUnavailable, err)
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, imageCheckTimeout)
+		defer cancel()
+
+		status, err := requestImage(ctx, client, http.MethodHead, imageURL)
+		if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+			status, err = requestImage(ctx, client, http.MethodGet, imageURL)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrImageUnavailable, err)
+		}
+
+		if status < 200 || status > 299 {
+			return fmt.Errorf("%w: %s returned %d %s", ErrImageUnavailable, imageURL, status, http.StatusText(status))
+		}
+
+		return nil
+	})
+}
+
+// imageCheckResult is a cached result of an ImageChecker.
+type imageCheckResult struct {
+	err       error
+	checkedAt time.Time
+}
+
+// cachedImageChecker returns an ImageChecker reusing the results of check for imageCheckCacheTTL, so machines
+// provisioning the same image do not all request it.
+func cachedImageChecker(check ImageChecker) ImageChecker {
+	var (
+		mu      sync.Mutex
+		results = map[string]imageCheckResult{}
+	)
+
+	return func(ctx context.Context, imageURL string) error {
+		mu.Lock()
+		result, ok := results[imageURL]
+		mu.Unlock()
+
+		if ok && time.Since(result.checkedAt) < imageCheckCacheTTL {
+			return result.err
+		}
+
+		err := check(ctx, imageURL)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		for cachedURL, cached := range results {
+			if time.Since(cached.checkedAt) >= imageCheckCacheTTL {
+				delete(results, cachedURL)
+			}
+		}
+
+		results[imageURL] = imageCheckResult{err: err, checkedAt: time.Now()}
+
+		return err
+	}
+}
+
+func requestImage(ctx context.Context, client *http.Client, method, imageURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, imageURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("requesting %s: %w", imageURL, err)
+	}
+
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// ImageCheckHTTPClient returns the HTTP client for NewHTTPImageChecker, trusting the certificates of the PEM
+// encoded CA bundle at caBundlePath in addition to the system ones, or skipping certificate verification when
+// insecure is set.
+func ImageCheckHTTPClient(caBundlePath string, insecure bool) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure, //nolint:gosec // Explicitly requested by the user.
+	}
+
+	if caBundlePath != "" {
+		pem, err := os.ReadFile(caBundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w %s", ErrNoCertificatesInCABundle, caBundlePath)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// ensureImageAvailable returns true when the OS image of the machine can be downloaded, or when the check is
+// disabled. It is checked before the selected Hardware is claimed. Otherwise the machine is requeued and the
+// ImageAvailable condition reports why, so no Hardware is claimed and powered on to netboot into a workflow failing
+// to download its image. Machines with a template override or a
+// library template are not checked, as the template may not use the image.
+func (scope *machineReconcileScope) ensureImageAvailable(hw *tinkv1.Hardware) (bool, error) {
+	if scope.imageChecker == nil || scope.tinkerbellMachine.Spec.TemplateOverride != "" ||
+		scope.tinkerbellMachine.Spec.TemplateRefName != "" {
+		return true, nil
+	}
+
+	imageURL, err := scope.imageURL(hw)
+	if err != nil {
+		return false, fmt.Errorf("failed to generate imageURL: %w", err)
+	}
+
+	if err := scope.imageChecker(scope.ctx, imageURL); err != nil {
+		msg := err.Error()
+
+		previous := conditions.Get(scope.tinkerbellMachine, infrastructurev1.ImageAvailableCondition)
+		if previous == nil || previous.Message != msg {
+			record.Warn(scope.tinkerbellMachine, infrastructurev1.ImageUnavailableReason, msg)
+		}
+
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.ImageAvailableCondition,
+			infrastructurev1.ImageUnavailableReason, clusterv1.ConditionSeverityWarning, "%s", msg)
+		scope.requeue(imageUnavailableRequeueAfter)
+
+		return false, nil
+	}
+
+	conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.ImageAvailableCondition)
+
+	return true, nil
+}
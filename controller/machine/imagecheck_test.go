@@ -0,0 +1,130 @@
+package machine_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_NewHTTPImageChecker(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/ubuntu.gz":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/no-head.gz" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/no-head.gz":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tests := map[string]struct {
+		url       string
+		available bool
+	}{
+		"existing image":                      {url: server.URL + "/ubuntu.gz", available: true},
+		"missing image":                       {url: server.URL + "/missing.gz"},
+		"server not supporting HEAD requests": {url: server.URL + "/no-head.gz", available: true},
+		"unreachable server":                  {url: "http://127.0.0.1:1/ubuntu.gz"},
+		"non HTTP image":                      {url: "oci://registry/ubuntu:22.04", available: true},
+	}
+
+	check := machine.NewHTTPImageChecker(server.Client())
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			err := check(context.Background(), tc.url)
+			if tc.available {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(machine.ErrImageUnavailable))
+			}
+		})
+	}
+}
+
+func Test_NewHTTPImageChecker_caches_results(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	check := machine.NewHTTPImageChecker(server.Client())
+
+	g.Expect(check(context.Background(), server.URL+"/ubuntu.gz")).To(MatchError(machine.ErrImageUnavailable))
+	g.Expect(check(context.Background(), server.URL+"/ubuntu.gz")).To(MatchError(machine.ErrImageUnavailable))
+	g.Expect(requests.Load()).To(BeEquivalentTo(1), "Expected the result of the first check to be reused")
+}
+
+func Test_Machine_reconciliation_waits_for_available_image(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	var checkErr error
+
+	r := &machine.TinkerbellMachineReconciler{
+		Client:       client,
+		ImageChecker: func(context.Context, string) error { return checkErr },
+	}
+
+	checkErr = machine.ErrImageUnavailable
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected the machine to be requeued")
+	g.Expect(apierrors.IsNotFound(client.Get(ctx, key, &tinkv1.Workflow{}))).To(BeTrue(),
+		"Expected no workflow to be created while the image is unavailable")
+
+	updated := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, key, updated)).To(Succeed())
+	g.Expect(conditions.GetReason(updated, infrastructurev1.ImageAvailableCondition)).
+		To(Equal(infrastructurev1.ImageUnavailableReason))
+	g.Expect(updated.Spec.HardwareName).To(BeEmpty(), "Expected no Hardware to be claimed while the image is unavailable")
+
+	checkErr = nil
+
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client.Get(ctx, key, &tinkv1.Workflow{})).To(Succeed(),
+		"Expected the workflow to be created once the image is available")
+}
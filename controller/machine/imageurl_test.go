@@ -0,0 +1,56 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_imageURL(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		format       infrastructurev1.ImageFormat
+		lookupFormat string
+		want         string
+	}{
+		"default format": {want: "registry/ubuntu-2204:v1.30.1.gz"},
+		"raw images":     {format: infrastructurev1.ImageFormatRaw, want: "registry/ubuntu-2204:v1.30.1.raw"},
+		"qcow2 images":   {format: infrastructurev1.ImageFormatQCOW2, want: "registry/ubuntu-2204:v1.30.1.qcow2"},
+		"custom lookup formats are kept": {
+			format:       infrastructurev1.ImageFormatRaw,
+			lookupFormat: "{{.BaseRegistry}}/{{.OSDistro}}.gz",
+			want:         "registry/ubuntu.gz",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			scope := &machineReconcileScope{
+				tinkerbellMachine: &infrastructurev1.TinkerbellMachine{Spec: infrastructurev1.TinkerbellMachineSpec{
+					ImageLookupFormat: tc.lookupFormat,
+					Image:             infrastructurev1.ImageSpec{Format: tc.format},
+				}},
+				tinkerbellCluster: &infrastructurev1.TinkerbellCluster{Spec: infrastructurev1.TinkerbellClusterSpec{
+					ImageLookupFormat:       infrastructurev1.DefaultImageLookupFormat,
+					ImageLookupBaseRegistry: "registry",
+					ImageLookupOSDistro:     "ubuntu",
+					ImageLookupOSVersion:    "22.04",
+				}},
+				machine: &clusterv1.Machine{Spec: clusterv1.MachineSpec{Version: ptr.To("v1.30.1")}},
+			}
+
+			got, err := scope.imageURL(&tinkv1.Hardware{})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(got).To(Equal(tc.want))
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+const (
+	// defaultImageVerificationImage is the image of the action verifying image signatures of clusters which do not
+	// set one. The dev variants of the cosign images ship a shell, wget and the busybox tools.
+	defaultImageVerificationImage = "ghcr.io/sigstore/cosign/cosign:v2.4.1-dev"
+
+	// imageURLEnvironment is the environment variable of the actions streaming an image to disk holding its URL.
+	imageURLEnvironment = "IMG_URL"
+
+	// destDiskEnvironment is the environment variable of the actions streaming an image to disk holding the disk.
+	destDiskEnvironment = "DEST_DISK"
+
+	// compressedEnvironment is the environment variable of the actions streaming an image to disk set to whether
+	// the image is compressed, in which case its compression is found from the suffix of its URL.
+	compressedEnvironment = "COMPRESSED"
+)
+
+// ErrImageVerificationUnsupported is returned when image verification is configured for a machine whose template
+// has no action streaming an image, or an action streaming an image which cannot be verified, so the image it
+// installs cannot be verified.
+var ErrImageVerificationUnsupported = fmt.Errorf("image verification requires actions streaming an image with %s, "+
+	"%s and %s", imageURLEnvironment, destDiskEnvironment, compressedEnvironment)
+
+// imageVerificationAction is the action streaming and verifying an image, in the order its fields are rendered.
+type imageVerificationAction struct {
+	Name        string            `yaml:"name"`
+	Image       string            `yaml:"image"`
+	Timeout     int               `yaml:"timeout"`
+	Command     []string          `yaml:"command"`
+	Environment map[string]string `yaml:"environment"`
+	Volumes     []string          `yaml:"volumes,omitempty"`
+}
+
+// imageVerificationScript returns the script streaming the image at the URL in the IMG_URL environment variable to
+// the disk in DEST_DISK, decompressing it when COMPRESSED is true, and verifying the bytes it streamed against the
+// SHA-256 checksum at the image URL with the .sha256 suffix. The signature of the checksum is verified with cosign
+// first, with the public key or the certificate identity in the environment. The disk is wiped when the streamed
+// image does not match the checksum, so a workflow failing verification leaves no bootable image behind.
+func imageVerificationScript(verification *infrastructurev1.ImageVerification) string {
+	script := &strings.Builder{}
+	script.WriteString("set -eu -o pipefail\n")
+	script.WriteString("wget -qO /tmp/image.sha256 \"$IMG_URL.sha256\"\n")
+
+	if verification.PublicKey != "" {
+		script.WriteString("wget -qO /tmp/image.sha256.sig \"$IMG_URL.sha256.sig\"\n")
+		script.WriteString("cosign verify-blob --key env://COSIGN_PUBLIC_KEY --signature /tmp/image.sha256.sig " +
+			"/tmp/image.sha256\n")
+	} else {
+		script.WriteString("wget -qO /tmp/image.sha256.bundle \"$IMG_URL.sha256.bundle\"\n")
+		script.WriteString("cosign verify-blob --bundle /tmp/image.sha256.bundle " +
+			"--certificate-identity \"$CERTIFICATE_IDENTITY\" --certificate-oidc-issuer \"$CERTIFICATE_OIDC_ISSUER\" " +
+			"/tmp/image.sha256\n")
+	}
+
+	script.WriteString(`decompress=cat
+if [ "${COMPRESSED:-false}" = true ]; then
+  case "${IMG_URL%%\?*}" in
+    *.gz) decompress=gunzip ;;
+    *.xz) decompress=unxz ;;
+    *.bz2) decompress=bunzip2 ;;
+    *) echo "Unsupported compression of $IMG_URL" >&2; exit 1 ;;
+  esac
+fi
+if ! wget -qO- "$IMG_URL" | { tee /dev/fd/3 | sha256sum >/tmp/image.digest; } 3>&1 | $decompress |
+  dd of="$DEST_DISK" bs=4M conv=fsync 2>/dev/null ||
+  [ "$(cut -d' ' -f1 /tmp/image.digest)" != "$(cut -d' ' -f1 /tmp/image.sha256)" ]; then
+  echo "Image streamed to $DEST_DISK does not match its signed checksum, wiping $DEST_DISK" >&2
+  dd if=/dev/zero of="$DEST_DISK" bs=4M count=16 conv=fsync 2>/dev/null
+  exit 1
+fi
+`)
+
+	return script.String()
+}
+
+// applyImageVerification replaces every action of the Tinkerbell template data streaming an image, i.e. with the
+// image URL in its IMG_URL environment variable, with an action streaming the image once and verifying the bytes
+// written to disk against the signed checksum of the image, see imageVerificationScript. The workflow fails on
+// images whose checksum signature does not verify or which do not match their checksum. Templates without such an
+// action, or with one the image of which is not streamed as is or decompressed to DEST_DISK, e.g. qcow2 or WIM
+// images, are refused with ErrImageVerificationUnsupported.
+func applyImageVerification(data string, verification *infrastructurev1.ImageVerification) (string, error) {
+	if verification == nil {
+		return data, nil
+	}
+
+	doc, tasks, err := parseTemplate(data)
+	if err != nil {
+		return "", err
+	}
+
+	image := verification.Image
+	if image == "" {
+		image = defaultImageVerificationImage
+	}
+
+	script := imageVerificationScript(verification)
+	verified := 0
+
+	for _, task := range tasks.Content {
+		actions := mappingValue(task, "actions")
+		if actions == nil || actions.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for i, action := range actions.Content {
+			environment := mappingValue(action, "environment")
+
+			imageURL := mappingValue(environment, imageURLEnvironment)
+			if imageURL == nil || imageURL.Kind != yaml.ScalarNode || imageURL.Value == "" {
+				continue
+			}
+
+			name := "stream image"
+			if actionName := mappingValue(action, "name"); actionName != nil {
+				name = actionName.Value
+			}
+
+			destDisk := mappingValue(environment, destDiskEnvironment)
+			compressed := mappingValue(environment, compressedEnvironment)
+
+			if destDisk == nil || destDisk.Kind != yaml.ScalarNode || compressed == nil ||
+				compressed.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("%w: action %q", ErrImageVerificationUnsupported, name)
+			}
+
+			env := map[string]string{
+				imageURLEnvironment:   imageURL.Value,
+				destDiskEnvironment:   destDisk.Value,
+				compressedEnvironment: compressed.Value,
+			}
+			if verification.PublicKey != "" {
+				env["COSIGN_PUBLIC_KEY"] = verification.PublicKey
+			} else {
+				env["CERTIFICATE_IDENTITY"] = verification.CertificateIdentity
+				env["CERTIFICATE_OIDC_ISSUER"] = verification.CertificateOIDCIssuer
+			}
+
+			var volumes []string
+			if node := mappingValue(action, "volumes"); node != nil {
+				if err := node.Decode(&volumes); err != nil {
+					return "", fmt.Errorf("decoding volumes of action %q: %w", name, err)
+				}
+			}
+
+			node := &yaml.Node{}
+			if err := node.Encode(imageVerificationAction{
+				Name:        name,
+				Image:       image,
+				Timeout:     1800, //nolint:gomnd
+				Command:     []string{"sh", "-c", script},
+				Environment: env,
+				Volumes:     volumes,
+			}); err != nil {
+				return "", fmt.Errorf("encoding image verification action: %w", err)
+			}
+
+			actions.Content[i] = node
+			verified++
+		}
+	}
+
+	if verified == 0 {
+		return "", ErrImageVerificationUnsupported
+	}
+
+	return encodeTemplate(doc)
+}
+
+// imageVerification returns the image verification configuration of the cluster of the machine, if any.
+func (scope *machineReconcileScope) imageVerification() *infrastructurev1.ImageVerification {
+	if scope.tinkerbellCluster == nil {
+		return nil
+	}
+
+	return scope.tinkerbellCluster.Spec.ImageVerification
+}
@@ -0,0 +1,86 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_applyImageVerification(t *testing.T) {
+	t.Parallel()
+
+	verification := &infrastructurev1.ImageVerification{
+		PublicKey: "-----BEGIN PUBLIC KEY-----\nMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE\n-----END PUBLIC KEY-----\n",
+	}
+
+	t.Run("verifies_every_streamed_image", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		data, err := applyImageVerification(`
+tasks:
+  - name: os
+    actions:
+      - name: wipe
+        image: wipe
+      - name: image os
+        image: image2disk
+        environment:
+          IMG_URL: "http://10.1.1.1:8080/{{ .Hardware.Metadata.Instance.OperatingSystem.Slug }}.raw.gz"
+          DEST_DISK: /dev/sda
+          COMPRESSED: true
+        volumes:
+          - /dev:/dev
+`, verification)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, tasks, err := parseTemplate(data)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		names := []string{}
+		forEachAction(tasks, func(name string, _ *yaml.Node) { names = append(names, name) })
+		g.Expect(names).To(Equal([]string{"wipe", "image os"}), "Expected the image to be streamed once")
+		g.Expect(data).To(ContainSubstring(
+			"IMG_URL: http://10.1.1.1:8080/{{ .Hardware.Metadata.Instance.OperatingSystem.Slug }}.raw.gz"))
+		g.Expect(data).To(ContainSubstring("DEST_DISK: /dev/sda"))
+		g.Expect(data).To(ContainSubstring("- /dev:/dev"))
+		g.Expect(data).NotTo(ContainSubstring("image: image2disk"))
+		g.Expect(data).To(ContainSubstring(
+			"--key env://COSIGN_PUBLIC_KEY --signature /tmp/image.sha256.sig /tmp/image.sha256"))
+		g.Expect(data).To(ContainSubstring("sha256sum >/tmp/image.digest"))
+	})
+
+	t.Run("refuses_streamed_images_which_cannot_be_verified", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := applyImageVerification(`
+tasks:
+  - name: os
+    actions:
+      - name: stream image
+        image: quay.io/tinkerbell/actions/qemuimg2disk
+        environment:
+          IMG_URL: http://10.1.1.1:8080/ubuntu.qcow2
+          DEST_DISK: /dev/sda
+`, verification)
+		g.Expect(err).To(MatchError(ErrImageVerificationUnsupported))
+	})
+
+	t.Run("refuses_templates_without_streamed_image", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := applyImageVerification(`
+tasks:
+  - name: os
+    actions:
+      - name: install
+        image: installer
+`, verification)
+		g.Expect(err).To(MatchError(ErrImageVerificationUnsupported))
+	})
+}
@@ -0,0 +1,178 @@
+package machine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"text/template"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+)
+
+const (
+	// hookScriptMarker is the comment identifying iPXE scripts generated by CAPT on the interfaces of Hardware, so
+	// they are removed when the Hardware is released.
+	hookScriptMarker = "# Generated by CAPT to boot Hook with the kernel arguments of TinkerbellMachine"
+
+	// defaultTinkWorkerImage is the tink-worker image Hook runs, matching the Tink API CAPT is built against.
+	defaultTinkWorkerImage = "quay.io/tinkerbell/tink-worker:v0.12.2"
+)
+
+// ErrIPXEScriptConflict is the error returned when kernel arguments are set for a machine whose Hardware already
+// has an iPXE script on an interface it netboots from, which CAPT would otherwise overwrite.
+var ErrIPXEScriptConflict = errors.New("kernel arguments cannot be combined with an iPXE script set on the Hardware")
+
+// HookBootOptions configures the iPXE script booting the Tinkerbell Hook OS which CAPT serves for machines setting
+// kernel arguments. Unset fields default to the Tinkerbell stack installed at the TINKERBELL_IP.
+type HookBootOptions struct {
+	// URL is the base URL the Hook kernel and initramfs are downloaded from, unless the netboot configuration of
+	// the interface sets an OSIE base URL. Defaults to port 8080 of TINKERBELL_IP.
+	URL string
+
+	// TinkServerAddress is the host and port of the Tink server gRPC API. Defaults to port 42113 of
+	// TINKERBELL_IP.
+	TinkServerAddress string
+
+	// TinkServerTLS makes tink-worker connect to the Tink server with TLS.
+	TinkServerTLS bool
+
+	// SyslogHost is the host Hook sends its logs to. Defaults to TINKERBELL_IP.
+	SyslogHost string
+
+	// TinkWorkerImage is the tink-worker image Hook runs. Defaults to defaultTinkWorkerImage.
+	TinkWorkerImage string
+}
+
+// withDefaults returns the options with unset fields defaulted to the Tinkerbell stack at the given IP.
+func (o HookBootOptions) withDefaults(tinkerbellIP string) HookBootOptions {
+	if o.URL == "" {
+		o.URL = "http://" + net.JoinHostPort(tinkerbellIP, "8080")
+	}
+
+	if o.TinkServerAddress == "" {
+		o.TinkServerAddress = net.JoinHostPort(tinkerbellIP, "42113")
+	}
+
+	if o.SyslogHost == "" {
+		o.SyslogHost = tinkerbellIP
+	}
+
+	if o.TinkWorkerImage == "" {
+		o.TinkWorkerImage = defaultTinkWorkerImage
+	}
+
+	return o
+}
+
+// hookScriptTemplate mirrors the script Smee serves to boot Hook, with the kernel arguments of the machine appended.
+//
+//nolint:lll
+var hookScriptTemplate = template.Must(template.New("hook").Parse(`#!ipxe
+{{.Marker}} {{.Machine}}
+set arch ${buildarch}
+iseq ${arch} i386 && set arch x86_64 ||
+iseq ${arch} arm32 && set arch aarch64 ||
+iseq ${arch} arm64 && set arch aarch64 ||
+kernel {{.URL}}/vmlinuz-${arch} facility={{.Facility}} syslog_host={{.SyslogHost}} grpc_authority={{.TinkServerAddress}} tinkerbell_tls={{.TinkServerTLS}} tink_worker_image={{.TinkWorkerImage}} worker_id={{.WorkerID}} hw_addr={{.MAC}} modules=loop,squashfs,sd-mod,usb-storage initrd=initramfs-${arch}{{range .KernelArgs}} {{.}}{{end}}
+initrd {{.URL}}/initramfs-${arch}
+boot
+`))
+
+// kernelArgs returns the kernel arguments the Hook OS of the machine is booted with.
+func (scope *machineReconcileScope) kernelArgs() []string {
+	if scope.isoBoot() || scope.persistentNetboot() {
+		return nil
+	}
+
+	return scope.tinkerbellMachine.Spec.BootOptions.KernelArgs
+}
+
+// hookScript returns the iPXE script booting the Hook OS with the kernel arguments of the machine from the given
+// interface of the Hardware.
+func (scope *machineReconcileScope) hookScript(hw *tinkv1.Hardware, iface *tinkv1.Interface) (string, error) {
+	workerID, err := scope.workerDevice(hw)
+	if err != nil {
+		return "", err
+	}
+
+	options := scope.hookBootOptions()
+
+	if iface.Netboot != nil && iface.Netboot.OSIE != nil && iface.Netboot.OSIE.BaseURL != "" {
+		options.URL = iface.Netboot.OSIE.BaseURL
+	}
+
+	facility := ""
+	if hw.Spec.Metadata != nil && hw.Spec.Metadata.Facility != nil {
+		facility = hw.Spec.Metadata.Facility.FacilityCode
+	}
+
+	buf := &bytes.Buffer{}
+
+	err = hookScriptTemplate.Execute(buf, map[string]any{
+		"Marker":            hookScriptMarker,
+		"Machine":           scope.tinkerbellMachine.Namespace + "/" + scope.tinkerbellMachine.Name,
+		"URL":               strings.TrimSuffix(options.URL, "/"),
+		"Facility":          facility,
+		"SyslogHost":        options.SyslogHost,
+		"TinkServerAddress": options.TinkServerAddress,
+		"TinkServerTLS":     options.TinkServerTLS,
+		"TinkWorkerImage":   options.TinkWorkerImage,
+		"WorkerID":          workerID,
+		"MAC":               strings.ToLower(iface.DHCP.MAC),
+		"KernelArgs":        scope.kernelArgs(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("rendering Hook iPXE script: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// isHookScript returns whether the iPXE configuration is a script generated by CAPT, see hookScript.
+func isHookScript(ipxe *tinkv1.IPXE) bool {
+	return ipxe.URL == "" && strings.HasPrefix(ipxe.Contents, "#!ipxe\n"+hookScriptMarker)
+}
+
+// ensureHookScript sets the iPXE script booting the Hook OS with the kernel arguments of the machine on the given
+// interface. It returns true when the interface had to be changed. An iPXE script or URL set on the interface by
+// someone else is not overwritten, as it could not be restored when the Hardware is released, see
+// ErrIPXEScriptConflict.
+func (scope *machineReconcileScope) ensureHookScript(hw *tinkv1.Hardware, iface *tinkv1.Interface) (bool, error) {
+	if len(scope.kernelArgs()) == 0 {
+		return false, nil
+	}
+
+	if ipxe := iface.Netboot.IPXE; ipxe != nil && (ipxe.URL != "" || ipxe.Contents != "") && !isHookScript(ipxe) {
+		return false, fmt.Errorf("%w: Hardware %s interface %s", ErrIPXEScriptConflict, hw.Name, iface.DHCP.MAC)
+	}
+
+	script, err := scope.hookScript(hw, iface)
+	if err != nil {
+		return false, err
+	}
+
+	if iface.Netboot.IPXE != nil && iface.Netboot.IPXE.Contents == script && iface.Netboot.IPXE.URL == "" {
+		return false, nil
+	}
+
+	iface.Netboot.IPXE = &tinkv1.IPXE{Contents: script}
+
+	return true, nil
+}
+
+// clearHookScripts removes the iPXE scripts generated by CAPT from the interfaces of the Hardware when it is
+// released. The change is only made to the given object and must be persisted by the caller.
+func clearHookScripts(hw *tinkv1.Hardware) {
+	for i := range hw.Spec.Interfaces {
+		netboot := hw.Spec.Interfaces[i].Netboot
+		if netboot == nil || netboot.IPXE == nil {
+			continue
+		}
+
+		if isHookScript(netboot.IPXE) {
+			netboot.IPXE = nil
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+)
+
+const (
+	// HardwareLeaseAnnotation is set on claimed Hardware to the time provisioning last progressed, in RFC 3339
+	// format. Hardware whose lease is not renewed within the lease duration is released once the
+	// TinkerbellMachine it was claimed for is gone. It is only set when a lease duration is configured.
+	HardwareLeaseAnnotation = "v1alpha1.tinkerbell.org/provisioning-lease"
+
+	// hardwareLeaseProgressAnnotation holds the workflow progress the lease was last renewed for.
+	hardwareLeaseProgressAnnotation = "v1alpha1.tinkerbell.org/provisioning-lease-progress"
+)
+
+// HardwareLeaseExpiry returns when the provisioning lease of the Hardware expires, and false when the Hardware
+// holds no lease, e.g. because it is not claimed or was already provisioned.
+func HardwareLeaseExpiry(hw *tinkv1.Hardware, duration time.Duration) (time.Time, bool, error) {
+	annotations := hw.GetAnnotations()
+
+	renewed, found := annotations[HardwareLeaseAnnotation]
+	if !found || annotations[HardwareProvisionedAnnotation] == "true" {
+		return time.Time{}, false, nil
+	}
+
+	if _, claimed := hw.GetLabels()[HardwareOwnerNameLabel]; !claimed {
+		return time.Time{}, false, nil
+	}
+
+	renewedAt, err := time.Parse(time.RFC3339, renewed)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("parsing %s annotation: %w", HardwareLeaseAnnotation, err)
+	}
+
+	return renewedAt.Add(duration), true, nil
+}
+
+// takeHardwareLease starts the provisioning lease of newly claimed Hardware. The Hardware is updated by the
+// caller.
+func (scope *machineReconcileScope) takeHardwareLease(hw *tinkv1.Hardware) {
+	if scope.hardwareLeaseDuration <= 0 || hw.GetAnnotations()[HardwareProvisionedAnnotation] == "true" {
+		return
+	}
+
+	if _, found := hw.GetAnnotations()[HardwareLeaseAnnotation]; found {
+		return
+	}
+
+	if hw.Annotations == nil {
+		hw.Annotations = map[string]string{}
+	}
+
+	hw.Annotations[HardwareLeaseAnnotation] = time.Now().UTC().Format(time.RFC3339)
+}
+
+// renewHardwareLease renews the provisioning lease of the Hardware when the workflow progressed since the lease
+// was last renewed.
+func (scope *machineReconcileScope) renewHardwareLease(hw *tinkv1.Hardware, wf *tinkv1.Workflow) error {
+	if scope.hardwareLeaseDuration <= 0 {
+		return nil
+	}
+
+	progress := workflowProgress(wf)
+	if hw.GetAnnotations()[hardwareLeaseProgressAnnotation] == progress {
+		return nil
+	}
+
+	return scope.patchHardwareAnnotations(hw, map[string]string{
+		HardwareLeaseAnnotation:         time.Now().UTC().Format(time.RFC3339),
+		hardwareLeaseProgressAnnotation: progress,
+	})
+}
+
+// workflowProgress summarizes the progress of the workflow, changing whenever its state, its current action or
+// the state of any action changes.
+func workflowProgress(wf *tinkv1.Workflow) string {
+	progress := []string{string(wf.Status.State), wf.Status.CurrentAction}
+
+	for _, task := range wf.Status.Tasks {
+		for _, action := range task.Actions {
+			progress = append(progress, string(action.Status))
+		}
+	}
+
+	return strings.Join(progress, "/")
+}
+
+// clearHardwareLease removes the provisioning lease from released Hardware.
+func clearHardwareLease(hw *tinkv1.Hardware) {
+	delete(hw.ObjectMeta.Annotations, HardwareLeaseAnnotation)
+	delete(hw.ObjectMeta.Annotations, hardwareLeaseProgressAnnotation)
+}
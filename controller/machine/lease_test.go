@@ -0,0 +1,61 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_Machine_reconciliation_takes_and_renews_hardware_lease(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	r := &machine.TinkerbellMachineReconciler{Client: client, HardwareLeaseDuration: time.Hour}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}}
+	hwKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	hw := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, hwKey, hw)).To(Succeed())
+	g.Expect(hw.Annotations).To(HaveKey(machine.HardwareLeaseAnnotation), "Expected claimed Hardware to be leased")
+
+	// Pretend the lease was taken long ago, the progress of the workflow renews it.
+	hw.Annotations[machine.HardwareLeaseAnnotation] = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	g.Expect(client.Update(ctx, hw)).To(Succeed())
+
+	wf := &tinkv1.Workflow{}
+	g.Expect(client.Get(ctx, req.NamespacedName, wf)).To(Succeed())
+	wf.Status.State = tinkv1.WorkflowStateRunning
+	g.Expect(client.Update(ctx, wf)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, hwKey, hw)).To(Succeed())
+
+	expiresAt, leased, err := machine.HardwareLeaseExpiry(hw, time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(leased).To(BeTrue())
+	g.Expect(expiresAt).To(BeTemporally(">", time.Now()), "Expected the lease to be renewed")
+}
@@ -0,0 +1,63 @@
+package machine
+
+import (
+	"fmt"
+	"time"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/cron"
+)
+
+// maintenanceWindowEnd returns when the maintenance windows the given time is in end, false when it is in none.
+// Overlapping windows end with the last of them.
+func maintenanceWindowEnd(windows []infrastructurev1.MaintenanceWindow, now time.Time) (time.Time, bool, error) {
+	var end time.Time
+
+	for _, w := range windows {
+		schedule, err := cron.Parse(w.Schedule)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("parsing maintenance window: %w", err)
+		}
+
+		loc, err := time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("loading time zone of maintenance window: %w", err)
+		}
+
+		start, ok := schedule.Prev(now.In(loc), now.Add(-w.Duration.Duration))
+		if ok && start.Add(w.Duration.Duration).After(now) && start.Add(w.Duration.Duration).After(end) {
+			end = start.Add(w.Duration.Duration)
+		}
+	}
+
+	return end, !end.IsZero(), nil
+}
+
+// provisioningDeferred returns true when the machine is in a maintenance window of its cluster, during which it
+// does not start provisioning. The machine is then requeued for the end of the window and the
+// ProvisioningSlotAcquired condition reports until when provisioning is deferred.
+func (scope *machineReconcileScope) provisioningDeferred() (bool, error) {
+	end, deferred, err := maintenanceWindowEnd(scope.tinkerbellCluster.Spec.MaintenanceWindows, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	if !deferred {
+		if conditions.GetReason(scope.tinkerbellMachine, infrastructurev1.ProvisioningSlotAcquiredCondition) ==
+			infrastructurev1.ProvisioningDeferredReason {
+			conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.ProvisioningSlotAcquiredCondition)
+		}
+
+		return false, nil
+	}
+
+	conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.ProvisioningSlotAcquiredCondition,
+		infrastructurev1.ProvisioningDeferredReason, clusterv1.ConditionSeverityInfo,
+		"Provisioning is deferred during a maintenance window of the cluster until %s", end.UTC().Format(time.RFC3339))
+	scope.requeue(time.Until(end))
+
+	return true, nil
+}
@@ -0,0 +1,70 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_maintenanceWindowEnd(t *testing.T) {
+	t.Parallel()
+
+	// 2024-03-15 is a Friday.
+	now := time.Date(2024, time.March, 15, 23, 30, 0, 0, time.UTC)
+
+	window := func(schedule string, duration time.Duration, tz string) infrastructurev1.MaintenanceWindow {
+		return infrastructurev1.MaintenanceWindow{
+			Schedule: schedule,
+			Duration: metav1.Duration{Duration: duration},
+			TimeZone: tz,
+		}
+	}
+
+	tests := map[string]struct {
+		windows []infrastructurev1.MaintenanceWindow
+		want    time.Time
+		wantIn  bool
+	}{
+		"no windows": {},
+		"in window": {
+			windows: []infrastructurev1.MaintenanceWindow{window("0 22 * * FRI", 4*time.Hour, "")},
+			want:    time.Date(2024, time.March, 16, 2, 0, 0, 0, time.UTC),
+			wantIn:  true,
+		},
+		"window ended": {
+			windows: []infrastructurev1.MaintenanceWindow{window("0 22 * * FRI", time.Hour, "")},
+		},
+		"window of another day": {
+			windows: []infrastructurev1.MaintenanceWindow{window("0 22 * * THU", 4*time.Hour, "")},
+		},
+		"window in time zone": {
+			windows: []infrastructurev1.MaintenanceWindow{window("0 0 * * SAT", time.Hour, "Europe/Berlin")},
+			want:    time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC),
+			wantIn:  true,
+		},
+		"overlapping windows end with the last one": {
+			windows: []infrastructurev1.MaintenanceWindow{
+				window("0 22 * * FRI", 4*time.Hour, ""),
+				window("0 23 * * *", 8*time.Hour, ""),
+			},
+			want:   time.Date(2024, time.March, 16, 7, 0, 0, 0, time.UTC),
+			wantIn: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			end, in, err := maintenanceWindowEnd(test.windows, now)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(in).To(Equal(test.wantIn))
+			g.Expect(end.Equal(test.want)).To(BeTrue(), "got %s", end)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package machine
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+//nolint:gochecknoglobals
+var requeuedMachines = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "capt_tinkerbellmachine_requeued_items",
+		Help: "Number of TinkerbellMachines waiting to be reconciled again after a failed reconcile, by operation type.",
+	},
+	[]string{"operation"},
+)
+
+//nolint:gochecknoglobals
+var unsatisfiedHardwareDemand = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "capt_unsatisfied_hardware_demand",
+		Help: "Number of TinkerbellMachines waiting for Hardware matching their affinity, by namespace and hardware selector.",
+	},
+	[]string{"namespace", "selector"},
+)
+
+//nolint:gochecknoglobals
+var machinePhase = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "capt_tinkerbellmachine_phase",
+		Help: "Lifecycle phase of each TinkerbellMachine, 1 for the phase it is in.",
+	},
+	[]string{"namespace", "name", "phase"},
+)
+
+//nolint:gochecknoglobals
+var orphanedObjects = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "capt_orphaned_objects",
+		Help: "Number of Templates and Workflows whose TinkerbellMachine no longer exists found by the last sweep, by kind.",
+	},
+	[]string{"kind"},
+)
+
+//nolint:gochecknoglobals
+var deletedOrphanedObjects = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "capt_orphaned_objects_deleted_total",
+		Help: "Number of Templates and Workflows deleted because their TinkerbellMachine no longer exists, by kind.",
+	},
+	[]string{"kind"},
+)
+
+//nolint:gochecknoinits
+func init() {
+	metrics.Registry.MustRegister(requeuedMachines, unsatisfiedHardwareDemand, machinePhase, orphanedObjects,
+		deletedOrphanedObjects)
+}
@@ -0,0 +1,69 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+// ErrMultipleHardwareClaimed is the error returned when several Hardware carry the owner labels of a machine and
+// none of them is the Hardware named in its spec.
+var ErrMultipleHardwareClaimed = fmt.Errorf("multiple hardware claimed")
+
+// resolveMultipleClaims returns the Hardware to keep among several Hardware carrying the owner labels of the
+// machine, e.g. after an interrupted claim or owner labels copied by hand. The Hardware named in the spec of the
+// machine is kept and the others are released, so they are neither provisioned twice nor report their addresses
+// for the machine. Paused Hardware is released once it is unpaused. When none is named in the spec, nothing is released and ErrMultipleHardwareClaimed is returned
+// until the owner labels of the extra Hardware are removed.
+func (scope *machineReconcileScope) resolveMultipleClaims(hardware []tinkv1.Hardware) (*tinkv1.Hardware, error) {
+	names := make([]string, 0, len(hardware))
+	keep := -1
+
+	for i := range hardware {
+		names = append(names, hardware[i].Namespace+"/"+hardware[i].Name)
+
+		if hardware[i].Name == scope.tinkerbellMachine.Spec.HardwareName &&
+			hardware[i].Namespace == scope.hardwareNamespace() {
+			keep = i
+		}
+	}
+
+	if keep < 0 {
+		record.Warnf(scope.tinkerbellMachine, infrastructurev1.MultipleHardwareClaimedReason,
+			"Hardware %s are all claimed by the machine", strings.Join(names, ", "))
+
+		return nil, fmt.Errorf("%w: %s", ErrMultipleHardwareClaimed, strings.Join(names, ", "))
+	}
+
+	for i := range hardware {
+		if i == keep {
+			continue
+		}
+
+		// Paused Hardware must not be changed, it is released once it is unpaused.
+		if hardwareutil.Paused(&hardware[i]) {
+			record.Warnf(scope.tinkerbellMachine, infrastructurev1.MultipleHardwareClaimedReason,
+				"Not releasing paused Hardware %s also claimed by the machine, keeping %s", hardware[i].Name,
+				hardware[keep].Name)
+
+			continue
+		}
+
+		scope.log.Info("Releasing Hardware also claimed by the machine", "hardware", hardware[i].Name,
+			"keptHardware", hardware[keep].Name)
+
+		if err := scope.releaseHardware(&hardware[i]); err != nil {
+			return nil, fmt.Errorf("releasing Hardware %s: %w", hardware[i].Name, err)
+		}
+
+		record.Warnf(scope.tinkerbellMachine, infrastructurev1.MultipleHardwareClaimedReason,
+			"Released Hardware %s also claimed by the machine, keeping %s", hardware[i].Name, hardware[keep].Name)
+	}
+
+	return &hardware[keep], nil
+}
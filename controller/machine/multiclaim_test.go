@@ -0,0 +1,113 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_Machine_reconciliation_with_multiple_claimed_hardware(t *testing.T) {
+	t.Parallel()
+
+	ownerLabels := map[string]string{
+		machine.HardwareOwnerNameLabel:      tinkerbellMachineName,
+		machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+	}
+
+	objects := func(hardwareName string) []runtime.Object {
+		tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, uuid.New().String())
+		tm.Spec.HardwareName = hardwareName
+
+		return []runtime.Object{
+			tm,
+			validCluster(clusterName, clusterNamespace),
+			validTinkerbellCluster(clusterName, clusterNamespace),
+			validHardware("claimed", uuid.New().String(), hardwareIP, testOptions{Labels: ownerLabels}),
+			validHardware("duplicate", uuid.New().String(), "10.10.0.11", testOptions{Labels: ownerLabels}),
+			validMachine(machineName, clusterNamespace, clusterName),
+			validSecret(machineName, clusterNamespace),
+		}
+	}
+
+	t.Run("keeps_the_hardware_of_the_spec", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client := kubernetesClientWithObjects(t, objects("claimed"))
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		ctx := context.Background()
+
+		duplicate := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, types.NamespacedName{Name: "duplicate", Namespace: clusterNamespace}, duplicate)).
+			To(Succeed())
+		g.Expect(duplicate.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel), "Expected duplicate to be released")
+
+		claimed := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, types.NamespacedName{Name: "claimed", Namespace: clusterNamespace}, claimed)).
+			To(Succeed())
+		g.Expect(claimed.Labels).To(HaveKeyWithValue(machine.HardwareOwnerNameLabel, tinkerbellMachineName))
+
+		workflow := &tinkv1.Workflow{}
+		g.Expect(client.Get(ctx, types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+			workflow)).To(Succeed())
+		g.Expect(workflow.Spec.HardwareRef).To(Equal("claimed"))
+	})
+
+	t.Run("does_not_release_paused_hardware", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		objs := objects("claimed")
+		for _, obj := range objs {
+			if hw, ok := obj.(*tinkv1.Hardware); ok && hw.Name == "duplicate" {
+				hw.Annotations = map[string]string{machine.HardwarePausedAnnotation: "true"}
+			}
+		}
+
+		client := kubernetesClientWithObjects(t, objs)
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		duplicate := &tinkv1.Hardware{}
+		g.Expect(client.Get(context.Background(), types.NamespacedName{Name: "duplicate", Namespace: clusterNamespace},
+			duplicate)).To(Succeed())
+		g.Expect(duplicate.Labels).To(HaveKeyWithValue(machine.HardwareOwnerNameLabel, tinkerbellMachineName),
+			"Expected paused Hardware not to be released")
+	})
+
+	t.Run("fails_when_no_hardware_is_in_the_spec", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client := kubernetesClientWithObjects(t, objects(""))
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).To(MatchError(machine.ErrMultipleHardwareClaimed))
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(context.Background(),
+			types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, updatedMachine)).To(Succeed())
+		g.Expect(conditions.GetReason(updatedMachine, infrastructurev1.HardwareClaimedCondition)).
+			To(Equal(infrastructurev1.MultipleHardwareClaimedReason))
+
+		for _, name := range []string{"claimed", "duplicate"} {
+			hw := &tinkv1.Hardware{}
+			g.Expect(client.Get(context.Background(), types.NamespacedName{Name: name, Namespace: clusterNamespace},
+				hw)).To(Succeed())
+			g.Expect(hw.Labels).To(HaveKey(machine.HardwareOwnerNameLabel), "Expected no Hardware to be released")
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package machine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// workflowNameHashLength is the number of hex characters of the hash suffixed to generated names.
+const workflowNameHashLength = 10
+
+// workflowName returns the name of the Workflow installing the OS, in the namespace of the Hardware.
+func (scope *machineReconcileScope) workflowName() string {
+	if name := scope.tinkerbellMachine.Status.WorkflowName; name != "" {
+		return name
+	}
+
+	return scope.legacyWorkflowName()
+}
+
+// templateName returns the name of the Template of the Workflow installing the OS, in the namespace of the
+// Hardware.
+func (scope *machineReconcileScope) templateName() string {
+	if name := scope.tinkerbellMachine.Status.TemplateName; name != "" {
+		return name
+	}
+
+	return scope.legacyWorkflowName()
+}
+
+// legacyWorkflowName returns the name of the Template and Workflow of machines which have none recorded in their
+// status, as used before the names were recorded: the name of the machine, prefixed with its namespace for pooled
+// Hardware, as machines of several namespaces share the namespace of the pool.
+func (scope *machineReconcileScope) legacyWorkflowName() string {
+	if scope.pooled() {
+		return fmt.Sprintf("%s-%s", scope.tinkerbellMachine.Namespace, scope.tinkerbellMachine.Name)
+	}
+
+	return scope.tinkerbellMachine.Name
+}
+
+// generatedWorkflowName returns the name for the Template and Workflow of a machine. It is the name of the
+// machine, unless that is too long to be used as a label value or the Hardware is pooled. The name is then
+// shortened and suffixed with a hash of the namespace and name of the machine, which keeps it unique in the
+// namespace of the Hardware.
+func (scope *machineReconcileScope) generatedWorkflowName() string {
+	name := scope.tinkerbellMachine.Name
+	if !scope.pooled() && len(name) <= validation.DNS1123LabelMaxLength {
+		return name
+	}
+
+	if scope.pooled() {
+		name = fmt.Sprintf("%s-%s", scope.tinkerbellMachine.Namespace, name)
+	}
+
+	return hashSuffixedName(name, scope.tinkerbellMachine.Namespace+"/"+scope.tinkerbellMachine.Name)
+}
+
+// hashSuffixedName returns the given name suffixed with a hash of key, shortened so the result is at most 63
+// characters long, the maximum length of label values and DNS labels. Names generated for distinct keys are
+// distinct.
+func hashSuffixedName(name, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])[:workflowNameHashLength]
+
+	if maxLength := validation.DNS1123LabelMaxLength - workflowNameHashLength - 1; len(name) > maxLength {
+		name = strings.TrimRight(name[:maxLength], "-._")
+	}
+
+	return name + "-" + hash
+}
+
+// boundedLabelValue returns the given value when it is a valid label value, otherwise the value shortened and
+// suffixed with a hash of it.
+func boundedLabelValue(value string) string {
+	if len(value) <= validation.LabelValueMaxLength {
+		return value
+	}
+
+	return hashSuffixedName(value, value)
+}
+
+// recordWorkflowNames records the names of the Template and Workflow of the machine in its status, unless they
+// are recorded already. Names are generated for Hardware claimed by this reconciliation. Machines which claimed
+// Hardware before, e.g. before the names were recorded or when the status was lost, keep the names of their existing
+// Workflow.
+func (scope *machineReconcileScope) recordWorkflowNames(newlyClaimed bool) error {
+	status := &scope.tinkerbellMachine.Status
+	if status.WorkflowName != "" && status.TemplateName != "" {
+		return nil
+	}
+
+	name := scope.generatedWorkflowName()
+
+	if legacy := scope.legacyWorkflowName(); !newlyClaimed && legacy != name {
+		key := types.NamespacedName{Name: name, Namespace: scope.hardwareNamespace()}
+
+		err := scope.client.Get(scope.ctx, key, &tinkv1.Workflow{})
+
+		switch {
+		case apierrors.IsNotFound(err):
+			name = legacy
+		case err != nil:
+			return fmt.Errorf("getting Workflow %s: %w", key, err)
+		}
+	}
+
+	if status.WorkflowName == "" {
+		status.WorkflowName = name
+	}
+
+	if status.TemplateName == "" {
+		status.TemplateName = name
+	}
+
+	return nil
+}
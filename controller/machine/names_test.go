@@ -0,0 +1,45 @@
+package machine //nolint:testpackage
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	"github.com/onsi/gomega/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_stageName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		workflowName string
+		want         types.GomegaMatcher
+	}{
+		"short names are kept": {workflowName: "machine", want: Equal("machine-burn-in")},
+		"long names are shortened": {
+			workflowName: strings.Repeat("m", validation.DNS1123LabelMaxLength),
+			want:         HavePrefix(strings.Repeat("m", validation.DNS1123LabelMaxLength-workflowNameHashLength-1) + "-"),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			scope := &machineReconcileScope{tinkerbellMachine: &infrastructurev1.TinkerbellMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+				Status:     infrastructurev1.TinkerbellMachineStatus{WorkflowName: tc.workflowName},
+			}}
+
+			burnIn, wipe := scope.stageName("burn-in"), scope.stageName("wipe")
+			g.Expect(burnIn).To(tc.want)
+			g.Expect(validation.IsDNS1123Label(burnIn)).To(BeEmpty())
+			g.Expect(burnIn).NotTo(Equal(wipe), "Expected stages to get distinct names")
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+)
+
+// HardwareNetbootInterfacesAnnotation lists the MAC addresses, comma separated, of the Hardware interfaces whose
+// AllowPXE setting is managed by CAPT. It is populated with all interfaces having a MAC address and a netboot
+// configuration when the Hardware is first provisioned, and can be set beforehand to restrict the interfaces CAPT
+// touches. Interfaces which are not listed are left to external tooling.
+const HardwareNetbootInterfacesAnnotation = "v1alpha1.tinkerbell.org/netboot-interfaces"
+
+// managedNetbootInterfaces returns the MAC addresses of the interfaces whose AllowPXE setting is managed by CAPT.
+func managedNetbootInterfaces(hw *tinkv1.Hardware) map[string]bool {
+	managed := map[string]bool{}
+
+	for _, mac := range strings.Split(hw.GetAnnotations()[HardwareNetbootInterfacesAnnotation], ",") {
+		if mac = strings.ToLower(strings.TrimSpace(mac)); mac != "" {
+			managed[mac] = true
+		}
+	}
+
+	return managed
+}
+
+// netbootInterfaceCandidates returns the MAC addresses of the interfaces which can be netbooted.
+func netbootInterfaceCandidates(hw *tinkv1.Hardware) []string {
+	var macs []string
+
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP == nil || iface.DHCP.MAC == "" || iface.Netboot == nil {
+			continue
+		}
+
+		macs = append(macs, strings.ToLower(iface.DHCP.MAC))
+	}
+
+	return macs
+}
+
+// ensureNetbootInterfacesTracked records the interfaces CAPT manages on the Hardware, unless they were already set.
+// Only the primary member is managed for bonded interfaces, and only the pinned interface when the machine pins one.
+func (scope *machineReconcileScope) ensureNetbootInterfacesTracked(hw *tinkv1.Hardware) error {
+	if _, ok := hw.GetAnnotations()[HardwareNetbootInterfacesAnnotation]; ok {
+		return nil
+	}
+
+	candidates := netbootInterfaceCandidates(hw)
+
+	// Diskless Hardware may lack a netboot configuration, it is always netbooted from its first interface.
+	if len(candidates) == 0 && scope.persistentNetboot() {
+		for _, iface := range hw.Spec.Interfaces {
+			if iface.DHCP != nil && iface.DHCP.MAC != "" {
+				candidates = []string{strings.ToLower(iface.DHCP.MAC)}
+
+				break
+			}
+		}
+	}
+
+	// Only the primary member of a bond is netbooted, the other members would request their own leases.
+	primary, err := scope.bondPrimaryInterface(hw)
+	if err != nil {
+		return err
+	}
+
+	if primary != nil {
+		candidates = []string{strings.ToLower(primary.DHCP.MAC)}
+	}
+
+	// Only the interface pinned by the machine is netbooted, so the worker booted is the one its workflows address.
+	pinned, err := scope.pinnedInterface(hw)
+	if err != nil {
+		return err
+	}
+
+	if pinned != nil {
+		candidates = []string{strings.ToLower(pinned.DHCP.MAC)}
+	}
+
+	return scope.patchHardwareAnnotations(hw, map[string]string{
+		HardwareNetbootInterfacesAnnotation: strings.Join(candidates, ","),
+	})
+}
+
+// ensureNetbootState sets AllowPXE to the desired value on the interfaces managed by CAPT, along with the iPXE
+// script booting Hook with the kernel arguments of the machine when PXE is allowed. It returns the number of
+// interfaces which had to be changed.
+func (scope *machineReconcileScope) ensureNetbootState(hw *tinkv1.Hardware, allowPXE bool) (int, error) {
+	managed := managedNetbootInterfaces(hw)
+	if len(managed) == 0 {
+		return 0, nil
+	}
+
+	patchHelper, err := patch.NewHelper(hw, scope.client)
+	if err != nil {
+		return 0, fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	changed := 0
+
+	for i := range hw.Spec.Interfaces {
+		iface := &hw.Spec.Interfaces[i]
+		if iface.DHCP == nil || !managed[strings.ToLower(iface.DHCP.MAC)] {
+			continue
+		}
+
+		if iface.Netboot == nil {
+			iface.Netboot = &tinkv1.Netboot{}
+		}
+
+		scriptChanged := false
+
+		if allowPXE {
+			if scriptChanged, err = scope.ensureHookScript(hw, iface); err != nil {
+				return 0, err
+			}
+		}
+
+		if iface.Netboot.AllowPXE != nil && *iface.Netboot.AllowPXE == allowPXE {
+			if scriptChanged {
+				changed++
+			}
+
+			continue
+		}
+
+		iface.Netboot.AllowPXE = ptr.To(allowPXE)
+		changed++
+	}
+
+	if changed == 0 {
+		return 0, nil
+	}
+
+	if err := patchHelper.Patch(scope.ctx, hw); err != nil {
+		return 0, fmt.Errorf("patching Hardware object: %w", err)
+	}
+
+	return changed, nil
+}
+
+// reassertNetbootState sets AllowPXE to the desired value on the interfaces managed by CAPT, reporting any interface
+// which was changed by someone else since CAPT last set it.
+func (scope *machineReconcileScope) reassertNetbootState(hw *tinkv1.Hardware, allowPXE bool) error {
+	changed, err := scope.ensureNetbootState(hw, allowPXE)
+	if err != nil {
+		return err
+	}
+
+	if changed > 0 {
+		scope.log.Info("Netboot state of Hardware drifted, re-asserted it",
+			"hardware", hw.Name, "allowPXE", allowPXE, "interfaces", changed)
+
+		record.Warnf(scope.tinkerbellMachine, "NetbootStateDrift",
+			"Re-asserted allowPXE=%t on %d interface(s) of Hardware %s", allowPXE, changed, hw.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/feature"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+const (
+	// HardwareNetbootHandshakeAnnotation is set by CAPT on Hardware to when the workflow of its machine signaled it
+	// is about to boot into the OS, in RFC 3339 format. Netboot of the Hardware was disallowed before the signal was
+	// acknowledged, so the machine cannot netboot into the provisioning environment again and be re-imaged.
+	HardwareNetbootHandshakeAnnotation = "v1alpha1.tinkerbell.org/netboot-handshake"
+
+	// NetbootHandshakePattern is the pattern of the endpoint the netboot handshake action of workflows calls. The
+	// report token of the TinkerbellMachine in the URL keeps the handshake from being made for another machine.
+	NetbootHandshakePattern = "POST /netboot/{namespace}/{name}/{token}"
+
+	// netbootHandshakeActionName is the name of the action of workflows making the netboot handshake.
+	netbootHandshakeActionName = "disable netboot"
+
+	// defaultNetbootHandshakeImage is the image of the action making the netboot handshake of clusters which do not
+	// set one.
+	defaultNetbootHandshakeImage = "curlimages/curl:8.11.1"
+)
+
+// ErrBootActionNotFound is returned when the netboot handshake cannot be added to a template, as none of its actions
+// boots into the OS.
+var ErrBootActionNotFound = errors.New("template has no action booting into the OS")
+
+// bootActionRepositories are the repositories of the images of actions booting into the OS, e.g. waitdaemon running
+// kexec or reboot once the workflow finished.
+var bootActionRepositories = []string{waitdaemonRepository, "kexec", "reboot"}
+
+// netbootHandshakeAction is the action of workflows making the netboot handshake, in the order its fields are
+// rendered. It retries until CAPT acknowledged the handshake, so the final action only boots into the OS once netboot
+// is disallowed.
+type netbootHandshakeAction struct {
+	Name    string   `yaml:"name"`
+	Image   string   `yaml:"image"`
+	Timeout int      `yaml:"timeout"`
+	Command []string `yaml:"command"`
+}
+
+// netbootHandshakeEnabled returns whether the workflow of the machine makes the netboot handshake: the feature gate
+// is enabled, a bootstrap report URL is configured and the machine is netbooted into the provisioning environment.
+func (scope *machineReconcileScope) netbootHandshakeEnabled() bool {
+	return feature.Enabled(scope.featureGates, feature.NetbootHandshake) && scope.bootstrapReportURL != "" &&
+		!scope.isoBoot() && !scope.persistentNetboot()
+}
+
+// netbootHandshakeEndpoint returns the URL the workflow of the machine makes the netboot handshake with, under the
+// report base URL, empty when the handshake is not made.
+func (scope *machineReconcileScope) netbootHandshakeEndpoint() (string, error) {
+	if !scope.netbootHandshakeEnabled() {
+		return "", nil
+	}
+
+	token, err := scope.reportToken()
+	if err != nil {
+		return "", err
+	}
+
+	tm := scope.tinkerbellMachine
+
+	return fmt.Sprintf("%s/netboot/%s/%s/%s", scope.reportBaseURL(), tm.Namespace, tm.Name, token), nil
+}
+
+// netbootHandshakeMade returns whether the workflow of the machine made the netboot handshake.
+func netbootHandshakeMade(hw *tinkv1.Hardware) bool {
+	_, ok := hw.GetAnnotations()[HardwareNetbootHandshakeAnnotation]
+
+	return ok
+}
+
+// provisioningNetbootState returns whether netboot of the Hardware is allowed while its workflow runs: until the
+// netboot handshake is made, unless the machine boots from virtual media.
+func (scope *machineReconcileScope) provisioningNetbootState(hw *tinkv1.Hardware) bool {
+	return !scope.isoBoot() && !netbootHandshakeMade(hw)
+}
+
+// checkNetbootHandshake records a warning when the workflow of the machine succeeded without making the netboot
+// handshake, as the machine may have netbooted into the provisioning environment again.
+func (scope *machineReconcileScope) checkNetbootHandshake(hw *tinkv1.Hardware) {
+	if !scope.netbootHandshakeEnabled() || netbootHandshakeMade(hw) {
+		return
+	}
+
+	record.Warnf(scope.tinkerbellMachine, "NetbootHandshakeMissing",
+		"Workflow succeeded without disallowing netboot of Hardware %s first, the machine may have netbooted again",
+		hw.Name)
+}
+
+// applyNetbootHandshake adds the action making the netboot handshake with the given URL to the Tinkerbell template
+// data, right before the first action booting into the OS, whose image is one of bootActionRepositories. Templates
+// without such an action are refused, as the handshake would be made at the wrong time or not at all.
+func applyNetbootHandshake(data, url string, images *infrastructurev1.ActionImages) (string, error) {
+	if url == "" {
+		return data, nil
+	}
+
+	doc, tasks, err := parseTemplate(data)
+	if err != nil {
+		return "", err
+	}
+
+	image := defaultNetbootHandshakeImage
+	if images != nil && images.NetbootHandshakeImage != "" {
+		image = images.NetbootHandshakeImage
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(netbootHandshakeAction{
+		Name:    netbootHandshakeActionName,
+		Image:   image,
+		Timeout: 300, //nolint:gomnd
+		Command: []string{
+			"curl", "-fsS", "--retry", "20", "--retry-delay", "10", "--retry-all-errors", "-X", "POST", url,
+		},
+	}); err != nil {
+		return "", fmt.Errorf("encoding netboot handshake action: %w", err)
+	}
+
+	for _, task := range tasks.Content {
+		actions := mappingValue(task, "actions")
+		if actions == nil || actions.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for i, action := range actions.Content {
+			if !bootAction(action) {
+				continue
+			}
+
+			actions.Content = append(actions.Content[:i], append([]*yaml.Node{node}, actions.Content[i:]...)...)
+
+			return encodeTemplate(doc)
+		}
+	}
+
+	return "", ErrBootActionNotFound
+}
+
+// bootAction returns whether the action of a template boots into the OS.
+func bootAction(action *yaml.Node) bool {
+	image := mappingValue(action, "image")
+
+	return image != nil && image.Kind == yaml.ScalarNode &&
+		slices.Contains(bootActionRepositories, imageRepositoryName(image.Value))
+}
+
+// NetbootHandshakeHandler acknowledges the netboot handshake of workflows: it disallows netboot of the interfaces
+// of the Hardware of the TinkerbellMachine managed by CAPT and records the handshake in the
+// HardwareNetbootHandshakeAnnotation, before responding. Netboot then stays disallowed until the Hardware is
+// released or reprovisioned.
+type NetbootHandshakeHandler struct {
+	Client client.Client
+}
+
+// ServeHTTP acknowledges the netboot handshake of the workflow of a TinkerbellMachine.
+func (h *NetbootHandshakeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := types.NamespacedName{Namespace: r.PathValue("namespace"), Name: r.PathValue("name")}
+	log := ctrl.LoggerFrom(r.Context()).WithValues("tinkerbellMachine", key)
+
+	tm := &infrastructurev1.TinkerbellMachine{}
+	if err := h.Client.Get(r.Context(), key, tm); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		log.Error(err, "Getting TinkerbellMachine of netboot handshake")
+		http.Error(w, "getting machine", http.StatusInternalServerError)
+
+		return
+	}
+
+	if !verifyMachineReportToken(w, r, h.Client, tm) {
+		return
+	}
+
+	hardware := &tinkv1.HardwareList{}
+	if err := h.Client.List(r.Context(), hardware, client.MatchingLabels{
+		HardwareOwnerNameLabel:      tm.Name,
+		HardwareOwnerNamespaceLabel: tm.Namespace,
+	}); err != nil {
+		log.Error(err, "Listing Hardware of netboot handshake")
+		http.Error(w, "listing hardware", http.StatusInternalServerError)
+
+		return
+	}
+
+	if len(hardware.Items) != 1 {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	hw := &hardware.Items[0]
+
+	// Paused Hardware must not be changed, the workflow retries the handshake until it is unpaused.
+	if hardwareutil.Paused(hw) {
+		http.Error(w, "hardware is paused", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	if err := h.disallowNetboot(r, hw); err != nil {
+		log.Error(err, "Disallowing netboot of Hardware", "hardware", hw.Name)
+		http.Error(w, "patching hardware", http.StatusInternalServerError)
+
+		return
+	}
+
+	log.Info("Disallowed netboot of Hardware on netboot handshake", "hardware", hw.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// disallowNetboot disallows netboot of the interfaces of the Hardware managed by CAPT and records the handshake.
+func (h *NetbootHandshakeHandler) disallowNetboot(r *http.Request, hw *tinkv1.Hardware) error {
+	if netbootHandshakeMade(hw) {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(hw, h.Client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for hardware: %w", err)
+	}
+
+	managed := managedNetbootInterfaces(hw)
+
+	for i := range hw.Spec.Interfaces {
+		iface := &hw.Spec.Interfaces[i]
+		if iface.DHCP == nil || iface.Netboot == nil || !managed[strings.ToLower(iface.DHCP.MAC)] {
+			continue
+		}
+
+		iface.Netboot.AllowPXE = ptr.To(false)
+	}
+
+	if hw.Annotations == nil {
+		hw.Annotations = map[string]string{}
+	}
+
+	hw.Annotations[HardwareNetbootHandshakeAnnotation] = time.Now().UTC().Format(time.RFC3339)
+
+	if err := patchHelper.Patch(r.Context(), hw); err != nil {
+		return fmt.Errorf("patching hardware: %w", err)
+	}
+
+	return nil
+}
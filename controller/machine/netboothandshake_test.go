@@ -0,0 +1,214 @@
+package machine_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/feature"
+)
+
+func Test_WorkflowTemplate_makes_netboot_handshake_before_booting_the_OS(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	const handshakeURL = "http://10.1.1.1:8082/netboot/default/machine/token"
+
+	wt := &machine.WorkflowTemplate{
+		Name:                "machine",
+		ImageURL:            "http://10.1.1.1:8080/ubuntu.gz",
+		DestPartition:       "/dev/sda1",
+		NetbootHandshakeURL: handshakeURL,
+	}
+
+	data, err := wt.Render()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	handshake := strings.Index(data, "name: disable netboot")
+	g.Expect(handshake).To(BeNumerically(">", strings.Index(data, "add tink cloud-init ds-config")))
+	g.Expect(handshake).To(BeNumerically("<", strings.Index(data, "kexec image")))
+	g.Expect(data).To(ContainSubstring(handshakeURL))
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_keeps_netboot_disallowed_after_netboot_handshake(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.UID = types.UID(uuid.New().String())
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.Interfaces[0].DHCP.MAC = "00:00:00:00:00:01"
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+
+	gates := feature.NewGates()
+	g.Expect(gates.SetFromMap(map[string]bool{string(feature.NetbootHandshake): true})).To(Succeed())
+
+	r := &machine.TinkerbellMachineReconciler{
+		Client:             client,
+		FeatureGates:       gates,
+		BootstrapReportURL: "http://10.1.1.1:8082",
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}}
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(ctx, req.NamespacedName, template)).To(Succeed())
+	token := reportToken(t, client, tm)
+	g.Expect(*template.Spec.Data).To(ContainSubstring("http://10.1.1.1:8082/netboot/" + clusterNamespace + "/" +
+		tinkerbellMachineName + "/" + token))
+
+	hardwareKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+	allowPXE := func() bool {
+		updated := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, hardwareKey, updated)).To(Succeed())
+
+		return *updated.Spec.Interfaces[0].Netboot.AllowPXE
+	}
+
+	g.Expect(allowPXE()).To(BeTrue())
+
+	handshake := func(token string) int {
+		mux := http.NewServeMux()
+		mux.Handle(machine.NetbootHandshakePattern, &machine.NetbootHandshakeHandler{Client: client})
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost,
+			"/netboot/"+clusterNamespace+"/"+tinkerbellMachineName+"/"+token, nil))
+
+		return rec.Code
+	}
+
+	g.Expect(handshake(string(tm.UID))).To(Equal(http.StatusNotFound),
+		"Expected handshakes authenticated with the UID of the machine to be rejected")
+	g.Expect(allowPXE()).To(BeTrue())
+
+	g.Expect(handshake(token)).To(Equal(http.StatusNoContent))
+	g.Expect(allowPXE()).To(BeFalse(), "Expected netboot to be disallowed before acknowledging the handshake")
+
+	wf := &tinkv1.Workflow{}
+	g.Expect(client.Get(ctx, req.NamespacedName, wf)).To(Succeed())
+	wf.Status.State = tinkv1.WorkflowStateRunning
+	g.Expect(client.Update(ctx, wf)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowPXE()).To(BeFalse(), "Expected netboot to stay disallowed while the final action runs")
+
+	wf.Status.State = tinkv1.WorkflowStateSuccess
+	g.Expect(client.Update(ctx, wf)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowPXE()).To(BeFalse())
+}
+
+func Test_Machine_reconciliation_adds_netboot_handshake_before_boot_action_of_template_override(t *testing.T) {
+	t.Parallel()
+
+	const override = `version: "0.1"
+name: custom
+global_timeout: 600
+tasks:
+  - name: "custom"
+    worker: "{{.device_1}}"
+    actions:
+      - name: "stream image"
+        image: quay.io/tinkerbell/actions/oci2disk
+        timeout: 600
+`
+
+	tests := map[string]struct {
+		override string
+		wantErr  error
+	}{
+		"with boot action": {
+			override: override + `      - name: "boot"
+        image: quay.io/tinkerbell/actions/kexec
+        timeout: 90
+      - name: "cleanup"
+        image: alpine
+        timeout: 60
+`,
+		},
+		"without boot action": {override: override, wantErr: machine.ErrBootActionNotFound},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			hardwareUUID := uuid.New().String()
+			tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+			tm.Spec.TemplateOverride = tc.override
+
+			tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+			tinkerbellCluster.Spec.ActionImages = &infrastructurev1.ActionImages{NetbootHandshakeImage: "10.1.1.1:5000/curl:8"}
+
+			client := kubernetesClientWithObjects(t, []runtime.Object{
+				tm,
+				validCluster(clusterName, clusterNamespace),
+				tinkerbellCluster,
+				validHardware(hardwareName, hardwareUUID, hardwareIP),
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			})
+
+			gates := feature.NewGates()
+			g.Expect(gates.SetFromMap(map[string]bool{string(feature.NetbootHandshake): true})).To(Succeed())
+
+			r := &machine.TinkerbellMachineReconciler{
+				Client:             client,
+				FeatureGates:       gates,
+				BootstrapReportURL: "http://10.1.1.1:8082",
+			}
+			req := ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+			}
+
+			_, err := r.Reconcile(context.Background(), req)
+			if tc.wantErr != nil {
+				g.Expect(err).To(MatchError(tc.wantErr))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			template := &tinkv1.Template{}
+			g.Expect(client.Get(context.Background(), req.NamespacedName, template)).To(Succeed())
+
+			data := *template.Spec.Data
+			g.Expect(data).To(ContainSubstring(`name: "boot"`))
+			handshake := strings.Index(data, "name: disable netboot")
+			g.Expect(handshake).To(BeNumerically(">", strings.Index(data, `name: "stream image"`)))
+			g.Expect(handshake).To(BeNumerically("<", strings.Index(data, `name: "boot"`)))
+			g.Expect(data).To(ContainSubstring("image: 10.1.1.1:5000/curl:8"))
+		})
+	}
+}
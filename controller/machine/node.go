@@ -0,0 +1,218 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// NodeGate defines what is required from the Node of a provisioned machine before the TinkerbellMachine is
+// marked as Ready.
+type NodeGate string
+
+const (
+	// NodeGateNone marks TinkerbellMachines as Ready as soon as the provisioning workflow succeeded.
+	NodeGateNone NodeGate = "none"
+
+	// NodeGateJoined waits for the Node of the machine to be registered in the workload cluster.
+	NodeGateJoined NodeGate = "joined"
+
+	// NodeGateReady waits for the Node of the machine to be registered in the workload cluster and Ready. Nodes
+	// only become Ready once a CNI is installed, which must therefore not depend on machines being Ready.
+	NodeGateReady NodeGate = "ready"
+
+	// nodeGateRequeueAfter is how long to wait before checking the Node of the machine again.
+	nodeGateRequeueAfter = 30 * time.Second
+
+	// workloadClusterClientName is the name under which CAPT connects to workload clusters.
+	workloadClusterClientName = "capt-node-gate"
+)
+
+// ErrUnknownNodeGate is returned when an unsupported NodeGate is configured.
+var ErrUnknownNodeGate = fmt.Errorf("unknown node gate")
+
+// WorkloadClusterClientFunc returns a client for the workload cluster with the given name.
+type WorkloadClusterClientFunc func(ctx context.Context, cluster client.ObjectKey) (client.Client, error)
+
+// Validate returns an error when the NodeGate is not supported.
+func (g NodeGate) Validate() error {
+	switch g {
+	case "", NodeGateNone, NodeGateJoined, NodeGateReady:
+		return nil
+	default:
+		return fmt.Errorf("%w %q, must be one of %s, %s or %s", ErrUnknownNodeGate, g,
+			NodeGateNone, NodeGateJoined, NodeGateReady)
+	}
+}
+
+// setupWorkloadClusterClient returns a WorkloadClusterClientFunc returning clients of a ClusterCacheTracker, which
+// connects to every workload cluster once, using its <cluster>-kubeconfig Secret, and caches the Nodes read from it.
+// Pods are read uncached.
+// Connections are dropped again when the cluster is deleted.
+func (r *TinkerbellMachineReconciler) setupWorkloadClusterClient(
+	ctx context.Context,
+	mgr ctrl.Manager,
+	options controller.Options,
+) (WorkloadClusterClientFunc, error) {
+	log := ctrl.LoggerFrom(ctx).WithName(workloadClusterClientName)
+
+	tracker, err := remote.NewClusterCacheTracker(mgr, remote.ClusterCacheTrackerOptions{
+		Log:            &log,
+		ControllerName: workloadClusterClientName,
+		// Pods are only listed to drain a Node, which does not warrant caching all Pods of the cluster.
+		ClientUncachedObjects: []client.Object{&corev1.ConfigMap{}, &corev1.Secret{}, &corev1.Pod{}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating workload cluster cache tracker: %w", err)
+	}
+
+	if err := (&remote.ClusterCacheReconciler{
+		Client:           r.Client,
+		Tracker:          tracker,
+		WatchFilterValue: r.WatchFilterValue,
+	}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: options.MaxConcurrentReconciles}); err != nil {
+		return nil, fmt.Errorf("setting up workload cluster cache reconciler: %w", err)
+	}
+
+	return tracker.GetClient, nil
+}
+
+// findNode returns the Node with the provider ID of the TinkerbellMachine, nil if it has not joined yet.
+func findNode(ctx context.Context, c client.Client, providerID string) (*corev1.Node, error) {
+	nodes := &corev1.NodeList{}
+	if err := c.List(ctx, nodes); err != nil {
+		return nil, fmt.Errorf("listing Nodes: %w", err)
+	}
+
+	for i := range nodes.Items {
+		if nodes.Items[i].Spec.ProviderID == providerID {
+			return &nodes.Items[i], nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil
+}
+
+func nodeIsReady(node *corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+func normalizeVersion(v string) string {
+	return strings.TrimPrefix(strings.TrimSpace(v), "v")
+}
+
+// nodeGatePassed checks the Node of the machine in the workload cluster against the configured NodeGate and
+// reflects the result in the NodeHealthy and KubeletVersionMatches conditions.
+func (scope *machineReconcileScope) nodeGatePassed() (bool, error) {
+	if scope.nodeGate == "" || scope.nodeGate == NodeGateNone {
+		return true, nil
+	}
+
+	cluster := client.ObjectKey{Namespace: scope.machine.Namespace, Name: scope.machine.Spec.ClusterName}
+
+	workloadClient, err := scope.workloadClusterClient(scope.ctx, cluster)
+	if err != nil {
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.NodeHealthyCondition,
+			infrastructurev1.WorkloadClusterUnreachableReason, clusterv1.ConditionSeverityInfo, "%s", err.Error())
+
+		return false, nil
+	}
+
+	node, err := findNode(scope.ctx, workloadClient, scope.tinkerbellMachine.Spec.ProviderID)
+	if err != nil {
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.NodeHealthyCondition,
+			infrastructurev1.WorkloadClusterUnreachableReason, clusterv1.ConditionSeverityInfo, "%s", err.Error())
+
+		return false, nil
+	}
+
+	if node == nil {
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.NodeHealthyCondition,
+			infrastructurev1.NodeNotFoundReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for a Node with provider ID %s to join the cluster", scope.tinkerbellMachine.Spec.ProviderID)
+
+		return false, nil
+	}
+
+	scope.setKubeletVersionCondition(node)
+
+	if scope.nodeGate == NodeGateReady && !nodeIsReady(node) {
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.NodeHealthyCondition,
+			infrastructurev1.NodeNotReadyReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for Node %s to become Ready", node.Name)
+
+		return false, nil
+	}
+
+	conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.NodeHealthyCondition)
+
+	return true, nil
+}
+
+// setKubeletVersionCondition reports whether the kubelet of the Node runs the Kubernetes version of the Machine.
+// A mismatch typically means the provisioned image does not match the requested version.
+func (scope *machineReconcileScope) setKubeletVersionCondition(node *corev1.Node) {
+	if scope.machine.Spec.Version == nil {
+		return
+	}
+
+	want := normalizeVersion(*scope.machine.Spec.Version)
+	got := normalizeVersion(node.Status.NodeInfo.KubeletVersion)
+
+	if got == want {
+		conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.KubeletVersionMatchesCondition)
+
+		return
+	}
+
+	conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.KubeletVersionMatchesCondition,
+		infrastructurev1.KubeletVersionMismatchReason, clusterv1.ConditionSeverityWarning,
+		"Node %s runs kubelet %s, but the Machine requests %s", node.Name,
+		node.Status.NodeInfo.KubeletVersion, *scope.machine.Spec.Version)
+}
+
+// markReady marks the TinkerbellMachine as Ready once the configured NodeGate passes. Machines which are Ready
+// already are not checked again.
+func (scope *machineReconcileScope) markReady() error {
+	if !scope.tinkerbellMachine.Status.Ready {
+		end := scope.trace("CheckNodeGate", attribute.String("node_gate", string(scope.nodeGate)))
+		passed, err := scope.nodeGatePassed()
+		end(err)
+
+		if err != nil {
+			return err
+		}
+
+		if !passed {
+			scope.log.V(4).Info("Waiting for the Node to pass the node gate before marking TinkerbellMachine as Ready", //nolint:gomnd,lll
+				"gate", scope.nodeGate)
+			scope.requeue(nodeGateRequeueAfter)
+
+			return nil
+		}
+	}
+
+	scope.log.Info("Marking TinkerbellMachine as Ready")
+	scope.tinkerbellMachine.Status.Ready = true
+	scope.recordReady()
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package machine //nolint:testpackage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+const nodeTestProviderID = "tinkerbell://default/hw"
+
+func nodeGateTestScope(gate NodeGate, nodes ...runtime.Object) *machineReconcileScope {
+	workload := fake.NewClientBuilder().WithRuntimeObjects(nodes...).Build()
+
+	return &machineReconcileScope{
+		log: logr.Discard(),
+		ctx: context.Background(),
+		tinkerbellMachine: &infrastructurev1.TinkerbellMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+			Spec:       infrastructurev1.TinkerbellMachineSpec{ProviderID: nodeTestProviderID},
+		},
+		machine: &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+			Spec:       clusterv1.MachineSpec{ClusterName: "cluster", Version: ptr.To("v1.30.1")},
+		},
+		nodeGate: gate,
+		workloadClusterClient: func(context.Context, client.ObjectKey) (client.Client, error) {
+			return workload, nil
+		},
+	}
+}
+
+func testNode(ready corev1.ConditionStatus, kubeletVersion string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Spec:       corev1.NodeSpec{ProviderID: nodeTestProviderID},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+			NodeInfo:   corev1.NodeSystemInfo{KubeletVersion: kubeletVersion},
+		},
+	}
+}
+
+func Test_markReady(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		gate           NodeGate
+		nodes          []runtime.Object
+		wantReady      bool
+		wantReason     string
+		wantKubeletOK  *bool
+		wantRequeueing bool
+	}{
+		"no_gate": {
+			gate:      NodeGateNone,
+			wantReady: true,
+		},
+		"joined_without_node": {
+			gate:           NodeGateJoined,
+			wantReason:     infrastructurev1.NodeNotFoundReason,
+			wantRequeueing: true,
+		},
+		"joined_with_not_ready_node": {
+			gate:          NodeGateJoined,
+			nodes:         []runtime.Object{testNode(corev1.ConditionFalse, "v1.30.1")},
+			wantReady:     true,
+			wantKubeletOK: ptr.To(true),
+		},
+		"ready_with_not_ready_node": {
+			gate:           NodeGateReady,
+			nodes:          []runtime.Object{testNode(corev1.ConditionFalse, "v1.30.1")},
+			wantReason:     infrastructurev1.NodeNotReadyReason,
+			wantKubeletOK:  ptr.To(true),
+			wantRequeueing: true,
+		},
+		"ready_with_kubelet_version_mismatch": {
+			gate:          NodeGateReady,
+			nodes:         []runtime.Object{testNode(corev1.ConditionTrue, "v1.29.4")},
+			wantReady:     true,
+			wantKubeletOK: ptr.To(false),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			scope := nodeGateTestScope(test.gate, test.nodes...)
+
+			g.Expect(scope.markReady()).To(Succeed())
+			g.Expect(scope.tinkerbellMachine.Status.Ready).To(Equal(test.wantReady))
+			g.Expect(scope.requeueAfter > 0).To(Equal(test.wantRequeueing))
+
+			if test.wantReason != "" {
+				g.Expect(conditions.GetReason(scope.tinkerbellMachine, infrastructurev1.NodeHealthyCondition)).
+					To(Equal(test.wantReason))
+			}
+
+			if test.wantKubeletOK != nil {
+				g.Expect(conditions.IsTrue(scope.tinkerbellMachine, infrastructurev1.KubeletVersionMatchesCondition)).
+					To(Equal(*test.wantKubeletOK))
+			}
+		})
+	}
+}
+
+func Test_markReady_workload_cluster_unreachable(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scope := nodeGateTestScope(NodeGateJoined)
+	scope.workloadClusterClient = func(context.Context, client.ObjectKey) (client.Client, error) {
+		return nil, errors.New("connection refused") //nolint:goerr113
+	}
+
+	g.Expect(scope.markReady()).To(Succeed())
+	g.Expect(scope.tinkerbellMachine.Status.Ready).To(BeFalse())
+	g.Expect(conditions.GetReason(scope.tinkerbellMachine, infrastructurev1.NodeHealthyCondition)).
+		To(Equal(infrastructurev1.WorkloadClusterUnreachableReason))
+
+	// Machines which are Ready already are not checked again.
+	scope.tinkerbellMachine.Status.Ready = true
+	scope.requeueAfter = 0
+	g.Expect(scope.markReady()).To(Succeed())
+	g.Expect(scope.requeueAfter).To(BeZero())
+}
+
+func TestNodeGate_Validate(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	g.Expect(NodeGate("").Validate()).To(Succeed())
+	g.Expect(NodeGateReady.Validate()).To(Succeed())
+	g.Expect(NodeGate("healthy").Validate()).To(MatchError(ErrUnknownNodeGate))
+}
@@ -0,0 +1,84 @@
+package machine
+
+import (
+	"maps"
+	"slices"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// tinkerbellMachineChanged is a predicate skipping updates of TinkerbellMachines which only change their status,
+// e.g. the patches of the reconciler itself or the mirrored Workflow status, unless the machine has a generation
+// which was not reconciled successfully yet or the bootstrap report server changed its BootstrapSucceeded
+// condition. The Workflows, BMC Jobs and Hardware of the machine are watched separately.
+func tinkerbellMachineChanged() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldObj, newObj := e.ObjectOld, e.ObjectNew
+
+			if tm, ok := newObj.(*infrastructurev1.TinkerbellMachine); ok && tm.Status.ObservedGeneration != tm.Generation {
+				return true
+			}
+
+			if bootstrapReported(oldObj, newObj) {
+				return true
+			}
+
+			return oldObj.GetGeneration() != newObj.GetGeneration() ||
+				!oldObj.GetDeletionTimestamp().Equal(newObj.GetDeletionTimestamp()) ||
+				!maps.Equal(oldObj.GetLabels(), newObj.GetLabels()) ||
+				!maps.Equal(oldObj.GetAnnotations(), newObj.GetAnnotations()) ||
+				!slices.Equal(oldObj.GetFinalizers(), newObj.GetFinalizers()) ||
+				len(oldObj.GetOwnerReferences()) != len(newObj.GetOwnerReferences())
+		},
+	}
+}
+
+// bootstrapReported returns whether the BootstrapSucceeded condition, which only the bootstrap report server sets,
+// changed between the TinkerbellMachines, so the Ready summary including it is recomputed.
+func bootstrapReported(oldObj, newObj client.Object) bool {
+	oldMachine, ok := oldObj.(*infrastructurev1.TinkerbellMachine)
+	if !ok {
+		return false
+	}
+
+	newMachine, ok := newObj.(*infrastructurev1.TinkerbellMachine)
+	if !ok {
+		return false
+	}
+
+	oldCondition := conditions.Get(oldMachine, infrastructurev1.BootstrapSucceededCondition)
+	newCondition := conditions.Get(newMachine, infrastructurev1.BootstrapSucceededCondition)
+
+	if oldCondition == nil || newCondition == nil {
+		return oldCondition != newCondition
+	}
+
+	return oldCondition.Status != newCondition.Status || oldCondition.Reason != newCondition.Reason
+}
+
+// tinkerbellClusterChanged is a predicate passing updates of TinkerbellClusters which change their spec or
+// readiness, so the status patches of the TinkerbellCluster reconciler do not reconcile all the machines of the
+// cluster.
+func tinkerbellClusterChanged() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldCluster, ok := e.ObjectOld.(*infrastructurev1.TinkerbellCluster)
+			if !ok {
+				return true
+			}
+
+			newCluster, ok := e.ObjectNew.(*infrastructurev1.TinkerbellCluster)
+			if !ok {
+				return true
+			}
+
+			return oldCluster.Generation != newCluster.Generation || oldCluster.Status.Ready != newCluster.Status.Ready
+		},
+	}
+}
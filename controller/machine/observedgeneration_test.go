@@ -0,0 +1,61 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_tinkerbellMachineChanged(t *testing.T) {
+	t.Parallel()
+
+	observed := &infrastructurev1.TinkerbellMachine{}
+	observed.Generation = 2
+	observed.Status.ObservedGeneration = 2
+
+	for name, tc := range map[string]struct {
+		mutate func(*infrastructurev1.TinkerbellMachine)
+		want   bool
+	}{
+		"status only": {
+			mutate: func(tm *infrastructurev1.TinkerbellMachine) { tm.Status.Ready = true },
+		},
+		"spec": {
+			mutate: func(tm *infrastructurev1.TinkerbellMachine) { tm.Generation = 3 },
+			want:   true,
+		},
+		"annotations": {
+			mutate: func(tm *infrastructurev1.TinkerbellMachine) {
+				tm.Annotations = map[string]string{ForceDeleteAnnotation: ""}
+			},
+			want: true,
+		},
+		"bootstrap reported": {
+			mutate: func(tm *infrastructurev1.TinkerbellMachine) {
+				conditions.MarkFalse(tm, infrastructurev1.BootstrapSucceededCondition,
+					infrastructurev1.BootstrapFailedReason, clusterv1.ConditionSeverityError, "kubeadm join failed")
+			},
+			want: true,
+		},
+		"generation not observed": {
+			mutate: func(tm *infrastructurev1.TinkerbellMachine) { tm.Status.ObservedGeneration = 1 },
+			want:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			updated := observed.DeepCopy()
+			tc.mutate(updated)
+
+			g.Expect(tinkerbellMachineChanged().Update(event.UpdateEvent{ObjectOld: observed, ObjectNew: updated})).
+				To(Equal(tc.want))
+		})
+	}
+}
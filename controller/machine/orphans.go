@@ -0,0 +1,141 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// OrphanSweeper periodically deletes the Templates, Workflows and BMC Jobs created for TinkerbellMachines which no
+// longer exist, e.g. force-deleted machines or machines lost in a failed pivot, whose objects are not garbage
+// collected: the ones in the namespace of pooled Hardware are only owned through labels, and the finalizer of a
+// force-deleted machine did not run.
+type OrphanSweeper struct {
+	Client client.Client
+
+	// Interval is how often objects are swept. Objects younger than the interval are never deleted, so the
+	// objects of machines the cache does not know about yet are kept.
+	Interval time.Duration
+
+	// DryRun only logs and counts the orphaned objects instead of deleting them.
+	DryRun bool
+}
+
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=templates;workflows,verbs=list;watch;delete
+// +kubebuilder:rbac:groups=bmc.tinkerbell.org,resources=jobs,verbs=list;watch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachines,verbs=get;list;watch
+
+// NeedLeaderElection returns true, as a single replica of the controller sweeps orphaned objects.
+func (s *OrphanSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// Start sweeps orphaned objects every interval until the context is done.
+func (s *OrphanSweeper) Start(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx).WithName("orphansweeper")
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.Sweep(ctx); err != nil {
+			log.Error(err, "Sweeping orphaned objects")
+		}
+	}, s.Interval)
+
+	return nil
+}
+
+// Sweep deletes the Templates, Workflows and BMC Jobs older than the interval whose TinkerbellMachine no longer
+// exists.
+func (s *OrphanSweeper) Sweep(ctx context.Context) error {
+	templates := &tinkv1.TemplateList{}
+	if err := s.Client.List(ctx, templates); err != nil {
+		return fmt.Errorf("listing Templates: %w", err)
+	}
+
+	objs := make([]client.Object, 0, len(templates.Items))
+	for i := range templates.Items {
+		objs = append(objs, &templates.Items[i])
+	}
+
+	if err := s.sweep(ctx, "Template", objs); err != nil {
+		return err
+	}
+
+	workflows := &tinkv1.WorkflowList{}
+	if err := s.Client.List(ctx, workflows); err != nil {
+		return fmt.Errorf("listing Workflows: %w", err)
+	}
+
+	objs = make([]client.Object, 0, len(workflows.Items))
+	for i := range workflows.Items {
+		objs = append(objs, &workflows.Items[i])
+	}
+
+	if err := s.sweep(ctx, "Workflow", objs); err != nil {
+		return err
+	}
+
+	jobs := &rufiov1.JobList{}
+	if err := s.Client.List(ctx, jobs); err != nil {
+		return fmt.Errorf("listing BMCJobs: %w", err)
+	}
+
+	objs = make([]client.Object, 0, len(jobs.Items))
+	for i := range jobs.Items {
+		objs = append(objs, &jobs.Items[i])
+	}
+
+	return s.sweep(ctx, "Job", objs)
+}
+
+// sweep deletes the given objects of a kind which were created for a TinkerbellMachine which no longer exists.
+func (s *OrphanSweeper) sweep(ctx context.Context, kind string, objs []client.Object) error {
+	log := ctrl.LoggerFrom(ctx)
+	orphaned := 0
+
+	for _, obj := range objs {
+		_, pooled := obj.GetLabels()[OwnerUIDLabel]
+		if !pooled && tinkerbellMachineOwner(obj) == nil {
+			continue
+		}
+
+		if time.Since(obj.GetCreationTimestamp().Time) < s.Interval || !obj.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+
+		tm, err := ownerMachine(ctx, s.Client, obj)
+		if err != nil {
+			return err
+		}
+
+		if tm != nil {
+			continue
+		}
+
+		orphaned++
+
+		if s.DryRun {
+			log.Info("Found orphaned object, not deleting it in dry-run mode", "kind", kind,
+				"object", client.ObjectKeyFromObject(obj))
+
+			continue
+		}
+
+		if err := s.Client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting orphaned %s %s: %w", kind, client.ObjectKeyFromObject(obj), err)
+		}
+
+		log.Info("Deleted orphaned object", "kind", kind, "object", client.ObjectKeyFromObject(obj))
+		deletedOrphanedObjects.WithLabelValues(kind).Inc()
+	}
+
+	orphanedObjects.WithLabelValues(kind).Set(float64(orphaned))
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_OrphanSweeper(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	uid := uuid.New().String()
+	old := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+
+	ownedBy := func(name, uid string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{
+			APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
+			Kind:       "TinkerbellMachine",
+			Name:       name,
+			UID:        types.UID(uid),
+		}}
+	}
+
+	template := func(name string, created metav1.Time, owners []metav1.OwnerReference) *tinkv1.Template {
+		return &tinkv1.Template{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         clusterNamespace,
+			CreationTimestamp: created,
+			OwnerReferences:   owners,
+		}}
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, uid),
+		template("owned", old, ownedBy(tinkerbellMachineName, uid)),
+		template("orphaned", old, ownedBy("gone", uuid.New().String())),
+		template("recent", metav1.Now(), ownedBy("gone", uuid.New().String())),
+		template("unowned", old, nil),
+		&tinkv1.Workflow{ObjectMeta: metav1.ObjectMeta{
+			Name:              "pooled",
+			Namespace:         "pool",
+			CreationTimestamp: old,
+			Labels: map[string]string{
+				machine.OwnerUIDLabel:               uuid.New().String(),
+				machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+			},
+		}},
+		&rufiov1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:              "orphaned-poweroff",
+			Namespace:         clusterNamespace,
+			CreationTimestamp: old,
+			OwnerReferences:   ownedBy("gone", uuid.New().String()),
+		}},
+	})
+
+	templateExists := func(name string) bool {
+		err := client.Get(context.Background(), types.NamespacedName{Name: name, Namespace: clusterNamespace},
+			&tinkv1.Template{})
+		g.Expect(err == nil || apierrors.IsNotFound(err)).To(BeTrue())
+
+		return err == nil
+	}
+
+	workflowExists := func() bool {
+		err := client.Get(context.Background(), types.NamespacedName{Name: "pooled", Namespace: "pool"},
+			&tinkv1.Workflow{})
+		g.Expect(err == nil || apierrors.IsNotFound(err)).To(BeTrue())
+
+		return err == nil
+	}
+
+	jobExists := func() bool {
+		err := client.Get(context.Background(), types.NamespacedName{Name: "orphaned-poweroff", Namespace: clusterNamespace},
+			&rufiov1.Job{})
+		g.Expect(err == nil || apierrors.IsNotFound(err)).To(BeTrue())
+
+		return err == nil
+	}
+
+	dryRun := &machine.OrphanSweeper{Client: client, Interval: time.Hour, DryRun: true}
+	g.Expect(dryRun.Sweep(context.Background())).To(Succeed())
+	g.Expect(templateExists("orphaned")).To(BeTrue(), "Expected nothing to be deleted in dry-run mode")
+	g.Expect(workflowExists()).To(BeTrue(), "Expected nothing to be deleted in dry-run mode")
+	g.Expect(jobExists()).To(BeTrue(), "Expected nothing to be deleted in dry-run mode")
+
+	sweeper := &machine.OrphanSweeper{Client: client, Interval: time.Hour}
+	g.Expect(sweeper.Sweep(context.Background())).To(Succeed())
+	g.Expect(templateExists("orphaned")).To(BeFalse())
+	g.Expect(workflowExists()).To(BeFalse(), "Expected the orphaned Workflow of pooled Hardware to be deleted")
+	g.Expect(jobExists()).To(BeFalse(), "Expected the orphaned BMCJob to be deleted")
+	g.Expect(templateExists("owned")).To(BeTrue())
+	g.Expect(templateExists("recent")).To(BeTrue(), "Expected objects younger than the interval to be kept")
+	g.Expect(templateExists("unowned")).To(BeTrue(), "Expected objects not created for machines to be kept")
+}
@@ -0,0 +1,158 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+// HardwarePausedAnnotation is hardwareutil.PausedAnnotation.
+const HardwarePausedAnnotation = hardwareutil.PausedAnnotation
+
+// ErrHardwarePaused is the error returned for Hardware which is not selected as it is paused.
+var ErrHardwarePaused = errors.New("hardware is paused")
+
+// ErrHardwareBusy is the error returned for Hardware which is not selected as a one-off workflow of CAPT runs on it,
+// e.g. an inventory scan or an image pre-warm.
+var ErrHardwareBusy = errors.New("hardware is busy")
+
+// reconcileHardwarePaused returns whether the Hardware bound to the machine is paused, in which case the machine
+// must not be reconciled further, and reflects it in the HardwarePausedCondition.
+func (scope *machineReconcileScope) reconcileHardwarePaused() (bool, error) {
+	if scope.tinkerbellMachine.Spec.HardwareName == "" {
+		return scope.claimedHardwarePaused()
+	}
+
+	hw := &tinkv1.Hardware{}
+	if err := scope.getHardwareForMachine(hw); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return scope.hardwarePaused(hw), nil
+}
+
+// claimedHardwarePaused returns whether Hardware claimed by the machine before its name was recorded is paused.
+func (scope *machineReconcileScope) claimedHardwarePaused() (bool, error) {
+	hardware := &tinkv1.HardwareList{}
+	if err := scope.client.List(scope.ctx, hardware, client.MatchingLabels{
+		HardwareOwnerNameLabel:      scope.tinkerbellMachine.Name,
+		HardwareOwnerNamespaceLabel: scope.tinkerbellMachine.Namespace,
+	}); err != nil {
+		return false, fmt.Errorf("listing hardware with owner: %w", err)
+	}
+
+	for i := range hardware.Items {
+		if hardwareutil.Paused(&hardware.Items[i]) {
+			return scope.hardwarePaused(&hardware.Items[i]), nil
+		}
+	}
+
+	conditions.Delete(scope.tinkerbellMachine, infrastructurev1.HardwarePausedCondition)
+
+	return false, nil
+}
+
+// hardwarePaused returns whether the given Hardware of the machine is paused, and reflects it in the
+// HardwarePausedCondition.
+func (scope *machineReconcileScope) hardwarePaused(hw *tinkv1.Hardware) bool {
+	if !hardwareutil.Paused(hw) {
+		if conditions.Has(scope.tinkerbellMachine, infrastructurev1.HardwarePausedCondition) {
+			record.Eventf(scope.tinkerbellMachine, "HardwareUnpaused", "Hardware %s is no longer paused", hw.Name)
+		}
+
+		conditions.Delete(scope.tinkerbellMachine, infrastructurev1.HardwarePausedCondition)
+
+		return false
+	}
+
+	if !conditions.IsTrue(scope.tinkerbellMachine, infrastructurev1.HardwarePausedCondition) {
+		record.Eventf(scope.tinkerbellMachine, "HardwarePaused",
+			"Hardware %s is paused, the machine is not reconciled until %s is removed", hw.Name,
+			HardwarePausedAnnotation)
+	}
+
+	conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.HardwarePausedCondition)
+	scope.log.Info("Hardware is paused, skipping reconciliation", "hardware", hw.Name)
+
+	return true
+}
+
+// unpausedHardware returns the given Hardware which is not paused. When all of it is paused, the returned error
+// lists it.
+func unpausedHardware(hardware []tinkv1.Hardware) ([]tinkv1.Hardware, error) {
+	unpaused := make([]tinkv1.Hardware, 0, len(hardware))
+	paused := []error{}
+
+	for i := range hardware {
+		if hardwareutil.Paused(&hardware[i]) {
+			paused = append(paused, fmt.Errorf("%w: Hardware %s", ErrHardwarePaused, hardware[i].Name))
+
+			continue
+		}
+
+		unpaused = append(unpaused, hardware[i])
+	}
+
+	if len(unpaused) == 0 && len(paused) > 0 {
+		return nil, errors.Join(paused...)
+	}
+
+	return unpaused, nil
+}
+
+// hardwarePausedChanged is a predicate passing updates of Hardware adding or removing the HardwarePausedAnnotation.
+func hardwarePausedChanged() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			_, before := e.ObjectOld.GetAnnotations()[HardwarePausedAnnotation]
+			_, after := e.ObjectNew.GetAnnotations()[HardwarePausedAnnotation]
+
+			return before != after
+		},
+	}
+}
+
+// idleHardware returns the given Hardware which is not busy, see hardwareutil.Busy, nor being pre-warmed. When all of
+// it is busy, the returned error lists it.
+func (scope *machineReconcileScope) idleHardware(hardware []tinkv1.Hardware) ([]tinkv1.Hardware, error) {
+	idle := make([]tinkv1.Hardware, 0, len(hardware))
+	busy := []error{}
+
+	for i := range hardware {
+		prewarming, err := scope.prewarming(&hardware[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if prewarming || hardwareutil.Busy(&hardware[i]) {
+			busy = append(busy, fmt.Errorf("%w: Hardware %s", ErrHardwareBusy, hardware[i].Name))
+
+			continue
+		}
+
+		idle = append(idle, hardware[i])
+	}
+
+	if len(idle) == 0 && len(busy) > 0 {
+		return nil, errors.Join(busy...)
+	}
+
+	return idle, nil
+}
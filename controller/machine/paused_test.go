@@ -0,0 +1,170 @@
+package machine_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+func Test_Machine_reconciliation_leaves_paused_hardware_untouched(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.UID = types.UID(uuid.New().String())
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	hardwareKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+	machineKey := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	hw := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, hardwareKey, hw)).To(Succeed())
+	g.Expect(hw.Spec.UserData).NotTo(BeNil())
+
+	hw.Annotations[machine.HardwarePausedAnnotation] = "true"
+	hw.Spec.UserData = nil
+	g.Expect(client.Update(ctx, hw)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	paused := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, hardwareKey, paused)).To(Succeed())
+	g.Expect(paused.ResourceVersion).To(Equal(hw.ResourceVersion), "Expected paused Hardware not to be changed")
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, machineKey, updatedMachine)).To(Succeed())
+	g.Expect(conditions.IsTrue(updatedMachine, infrastructurev1.HardwarePausedCondition)).To(BeTrue())
+
+	mux := http.NewServeMux()
+	mux.Handle(machine.NetbootHandshakePattern, &machine.NetbootHandshakeHandler{Client: client})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost,
+		"/netboot/"+clusterNamespace+"/"+tinkerbellMachineName+"/"+reportToken(t, client, tm), nil))
+	g.Expect(rec.Code).To(Equal(http.StatusServiceUnavailable), "Expected the handshake to be retried later")
+
+	delete(paused.Annotations, machine.HardwarePausedAnnotation)
+	g.Expect(client.Update(ctx, paused)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, hardwareKey, hw)).To(Succeed())
+	g.Expect(hw.Spec.UserData).NotTo(BeNil(), "Expected the user data to be restored once unpaused")
+
+	g.Expect(client.Get(ctx, machineKey, updatedMachine)).To(Succeed())
+	g.Expect(conditions.Has(updatedMachine, infrastructurev1.HardwarePausedCondition)).To(BeFalse())
+}
+
+func Test_Machine_reconciliation_does_not_select_paused_hardware(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Annotations = map[string]string{machine.HardwarePausedAnnotation: "true"}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
+	g.Expect(err).To(MatchError(machine.ErrHardwarePaused))
+}
+
+func Test_Machine_reconciliation_does_not_select_hardware_being_scanned(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Annotations = map[string]string{hardwareutil.InventoryScanAnnotation: "true"}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
+	g.Expect(err).To(MatchError(machine.ErrHardwareBusy))
+}
+
+func Test_Machine_reconciliation_does_not_select_hardware_being_prewarmed(t *testing.T) {
+	t.Parallel()
+
+	for name, started := range map[string]bool{"started": true, "not_started": false} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			hardwareUUID := uuid.New().String()
+			hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+			hw.Annotations = map[string]string{machine.HardwarePrewarmImageAnnotation: "http://10.1.1.1/image.gz"}
+
+			objects := []runtime.Object{
+				validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				hw,
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
+
+			if started {
+				objects = append(objects, &tinkv1.Workflow{ObjectMeta: metav1.ObjectMeta{
+					Name:      hardwareName + machine.HardwarePrewarmWorkflowSuffix,
+					Namespace: clusterNamespace,
+				}})
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			if !started {
+				g.Expect(err).NotTo(HaveOccurred(), "Expected Hardware whose pre-warm was not started to be selected")
+
+				return
+			}
+
+			g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
+			g.Expect(err).To(MatchError(machine.ErrHardwareBusy))
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/patch"
+)
+
+// bmcJobOperationNetboot is the operation of the BMC Job power cycling Hardware into its persistent netboot OS.
+const bmcJobOperationNetboot = "netboot"
+
+// persistentNetboot returns true when the machine netboots an in-memory OS on every boot instead of installing one
+// to disk.
+func (scope *machineReconcileScope) persistentNetboot() bool {
+	return scope.tinkerbellMachine.Spec.BootOptions.PersistentNetboot != nil
+}
+
+// reconcilePersistentNetboot provisions a machine netbooting an in-memory OS: its netbooted interfaces are kept
+// allowed to PXE boot into the configured iPXE script, and Hardware with a BMC is power cycled into it once. The OS
+// fetches the user-data of the Hardware, kept up to date with the bootstrap data, on every boot.
+func (scope *machineReconcileScope) reconcilePersistentNetboot(hw *tinkv1.Hardware) error {
+	provisioned := hw.ObjectMeta.GetAnnotations()[HardwareProvisionedAnnotation] == "true"
+
+	if !provisioned {
+		deferred, err := scope.provisioningDeferred()
+		if err != nil || deferred {
+			return err
+		}
+	}
+
+	if err := scope.ensureNetbootInterfacesTracked(hw); err != nil {
+		return fmt.Errorf("failed to track netboot interfaces: %w", err)
+	}
+
+	changed, err := scope.ensurePersistentNetbootScript(hw)
+	if err != nil {
+		return fmt.Errorf("failed to set persistent netboot script: %w", err)
+	}
+
+	if provisioned && changed > 0 {
+		scope.log.Info("Persistent netboot configuration of Hardware drifted, re-asserted it",
+			"hardware", hw.Name, "interfaces", changed)
+	}
+
+	if !provisioned {
+		if scope.managesPower(hw) {
+			ready, err := scope.ensurePersistentNetbootBMCReady(hw)
+			if err != nil || !ready {
+				return err
+			}
+
+			job, err := scope.ensureBMCJob(bmcJobOperationNetboot, hw, []rufiov1.Action{
+				{PowerAction: rufiov1.PowerHardOff.Ptr()},
+				{
+					OneTimeBootDeviceAction: &rufiov1.OneTimeBootDeviceAction{
+						Devices: []rufiov1.BootDevice{rufiov1.PXE},
+					},
+				},
+				{PowerAction: rufiov1.PowerOn.Ptr()},
+			})
+			if err != nil {
+				return fmt.Errorf("ensuring netboot BMCJob: %w", err)
+			}
+
+			scope.setBMCJobCondition(bmcJobOperationNetboot, job)
+
+			if err := scope.recordBMCJobFailure(hw, job); err != nil {
+				return err
+			}
+
+			if !job.HasCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue) {
+				return nil
+			}
+		} else {
+			scope.requestPowerAction(hw, "power cycle into a network boot of the persistent netboot OS")
+		}
+
+		err := scope.patchHardwareAnnotations(hw, map[string]string{HardwareProvisionedAnnotation: "true"})
+		if err != nil {
+			return fmt.Errorf("failed to patch hardware: %w", err)
+		}
+	}
+
+	return scope.markReady()
+}
+
+// ensurePersistentNetbootBMCReady checks that the BMC of the hardware can power it before the BMC Job power cycling
+// it into its persistent netboot OS is created.
+func (scope *machineReconcileScope) ensurePersistentNetbootBMCReady(hw *tinkv1.Hardware) (bool, error) {
+	jobs, err := scope.listBMCJobs(bmcJobOperationNetboot)
+	if err != nil || len(jobs) > 0 {
+		return err == nil, err
+	}
+
+	return scope.ensureBMCReady(hw)
+}
+
+// ensurePersistentNetbootScript allows the interfaces managed by CAPT to PXE boot into the iPXE script of the
+// machine. It returns the number of interfaces which had to be changed.
+func (scope *machineReconcileScope) ensurePersistentNetbootScript(hw *tinkv1.Hardware) (int, error) {
+	script := scope.tinkerbellMachine.Spec.BootOptions.PersistentNetboot.IPXEScriptURL
+
+	patchHelper, err := patch.NewHelper(hw, scope.client)
+	if err != nil {
+		return 0, fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	managed := managedNetbootInterfaces(hw)
+	changed := 0
+
+	for i := range hw.Spec.Interfaces {
+		iface := &hw.Spec.Interfaces[i]
+		if iface.DHCP == nil || !managed[strings.ToLower(iface.DHCP.MAC)] {
+			continue
+		}
+
+		if iface.Netboot == nil {
+			iface.Netboot = &tinkv1.Netboot{}
+		}
+
+		if ptr.Deref(iface.Netboot.AllowPXE, false) && iface.Netboot.IPXE != nil && iface.Netboot.IPXE.URL == script {
+			continue
+		}
+
+		iface.Netboot.AllowPXE = ptr.To(true)
+		iface.Netboot.IPXE = &tinkv1.IPXE{URL: script}
+		changed++
+	}
+
+	if changed == 0 {
+		return 0, nil
+	}
+
+	if err := patchHelper.Patch(scope.ctx, hw); err != nil {
+		return 0, fmt.Errorf("patching Hardware object: %w", err)
+	}
+
+	return changed, nil
+}
+
+// clearPersistentNetbootScript stops the interfaces managed by CAPT from netbooting the iPXE script of the
+// machine when the Hardware is released. The change is only made to the given object and must be persisted by the
+// caller.
+func (scope *machineReconcileScope) clearPersistentNetbootScript(hw *tinkv1.Hardware) {
+	if !scope.persistentNetboot() {
+		return
+	}
+
+	script := scope.tinkerbellMachine.Spec.BootOptions.PersistentNetboot.IPXEScriptURL
+	managed := managedNetbootInterfaces(hw)
+
+	for i := range hw.Spec.Interfaces {
+		iface := &hw.Spec.Interfaces[i]
+		if iface.DHCP == nil || !managed[strings.ToLower(iface.DHCP.MAC)] || iface.Netboot == nil {
+			continue
+		}
+
+		if iface.Netboot.IPXE != nil && iface.Netboot.IPXE.URL == script {
+			iface.Netboot.IPXE = nil
+			iface.Netboot.AllowPXE = ptr.To(false)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package machine
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// phases returns the provisioning phase timestamps of the TinkerbellMachine, initializing them when unset.
+func (scope *machineReconcileScope) phases() *infrastructurev1.ProvisioningPhases {
+	if scope.tinkerbellMachine.Status.Phases == nil {
+		scope.tinkerbellMachine.Status.Phases = &infrastructurev1.ProvisioningPhases{}
+	}
+
+	return scope.tinkerbellMachine.Status.Phases
+}
+
+// recordPhase sets the timestamp of a phase unless it was recorded already.
+func recordPhase(phase **metav1.Time, at time.Time) {
+	if *phase != nil {
+		return
+	}
+
+	t := metav1.NewTime(at)
+	*phase = &t
+}
+
+// workflowStartedAt returns when the first action of the workflow started, or now if no action reports it.
+func workflowStartedAt(wf *tinkv1.Workflow) time.Time {
+	for _, task := range wf.Status.Tasks {
+		for _, action := range task.Actions {
+			if action.StartedAt != nil {
+				return action.StartedAt.Time
+			}
+		}
+	}
+
+	return time.Now()
+}
+
+// workflowCompletedAt returns when the last started action of the workflow finished, or now if no action reports
+// it.
+func workflowCompletedAt(wf *tinkv1.Workflow) time.Time {
+	var completedAt time.Time
+
+	for _, task := range wf.Status.Tasks {
+		for _, action := range task.Actions {
+			if action.StartedAt == nil {
+				continue
+			}
+
+			if end := action.StartedAt.Add(time.Duration(action.Seconds) * time.Second); end.After(completedAt) {
+				completedAt = end
+			}
+		}
+	}
+
+	if completedAt.IsZero() {
+		return time.Now()
+	}
+
+	return completedAt
+}
+
+// recordWorkflowPhases records the start and completion of the workflow as observed in its status.
+func (scope *machineReconcileScope) recordWorkflowPhases(wf *tinkv1.Workflow) {
+	switch wf.Status.State {
+	case "", tinkv1.WorkflowStatePreparing, tinkv1.WorkflowStatePending:
+		return
+	case tinkv1.WorkflowStateSuccess, tinkv1.WorkflowStateFailed, tinkv1.WorkflowStateTimeout:
+		recordPhase(&scope.phases().WorkflowStartedAt, workflowStartedAt(wf))
+		recordPhase(&scope.phases().WorkflowCompletedAt, workflowCompletedAt(wf))
+	default:
+		recordPhase(&scope.phases().WorkflowStartedAt, workflowStartedAt(wf))
+	}
+}
+
+// recordReady records when the TinkerbellMachine became Ready and how long provisioning took.
+func (scope *machineReconcileScope) recordReady() {
+	phases := scope.phases()
+	if phases.ReadyAt != nil {
+		return
+	}
+
+	recordPhase(&phases.ReadyAt, time.Now())
+
+	if created := scope.tinkerbellMachine.CreationTimestamp; !created.IsZero() {
+		phases.ProvisioningDuration = &metav1.Duration{Duration: phases.ReadyAt.Sub(created.Time).Round(time.Second)}
+	}
+}
+
+// setPhase sets the lifecycle phase of the TinkerbellMachine from its deletion state and conditions, once its Ready
+// condition was set, and exports it as a metric.
+func (scope *machineReconcileScope) setPhase() {
+	tm := scope.tinkerbellMachine
+
+	switch {
+	case scope.MachineScheduledForDeletion():
+		tm.Status.Phase = infrastructurev1.TinkerbellMachinePhaseDeleting
+	case tm.Status.ErrorReason != nil ||
+		ptr.Deref(conditions.GetSeverity(tm, clusterv1.ReadyCondition), "") == clusterv1.ConditionSeverityError:
+		tm.Status.Phase = infrastructurev1.TinkerbellMachinePhaseFailed
+	case tm.Status.Ready:
+		tm.Status.Phase = infrastructurev1.TinkerbellMachinePhaseProvisioned
+	case tm.Spec.HardwareName != "":
+		tm.Status.Phase = infrastructurev1.TinkerbellMachinePhaseProvisioning
+	default:
+		tm.Status.Phase = infrastructurev1.TinkerbellMachinePhasePending
+	}
+
+	observeMachinePhase(types.NamespacedName{Name: tm.Name, Namespace: tm.Namespace}, tm.Status.Phase)
+}
+
+// observeMachinePhase exports the phase of the machine, removing its series when the phase is empty, i.e. the
+// machine is gone.
+func observeMachinePhase(machine types.NamespacedName, phase infrastructurev1.TinkerbellMachinePhase) {
+	machinePhase.DeletePartialMatch(prometheus.Labels{"namespace": machine.Namespace, "name": machine.Name})
+
+	if phase != "" {
+		machinePhase.WithLabelValues(machine.Namespace, machine.Name, string(phase)).Set(1)
+	}
+}
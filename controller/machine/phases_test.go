@@ -0,0 +1,106 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_workflowPhaseTimes(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	wf := &tinkv1.Workflow{Status: tinkv1.WorkflowStatus{Tasks: []tinkv1.Task{{
+		Actions: []tinkv1.Action{
+			{StartedAt: &metav1.Time{Time: started}, Seconds: 30},
+			{StartedAt: &metav1.Time{Time: started.Add(30 * time.Second)}, Seconds: 90},
+			{Name: "not-started"},
+		},
+	}}}}
+
+	g.Expect(workflowStartedAt(wf)).To(Equal(started))
+	g.Expect(workflowCompletedAt(wf)).To(Equal(started.Add(2 * time.Minute)))
+
+	empty := &tinkv1.Workflow{}
+	g.Expect(workflowCompletedAt(empty)).To(BeTemporally("~", time.Now(), time.Second))
+}
+
+func Test_setPhase(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		mutate func(tm *infrastructurev1.TinkerbellMachine)
+		want   infrastructurev1.TinkerbellMachinePhase
+	}{
+		"pending": {
+			mutate: func(*infrastructurev1.TinkerbellMachine) {},
+			want:   infrastructurev1.TinkerbellMachinePhasePending,
+		},
+		"provisioning": {
+			mutate: func(tm *infrastructurev1.TinkerbellMachine) { tm.Spec.HardwareName = "hw" },
+			want:   infrastructurev1.TinkerbellMachinePhaseProvisioning,
+		},
+		"provisioned": {
+			mutate: func(tm *infrastructurev1.TinkerbellMachine) {
+				tm.Spec.HardwareName = "hw"
+				tm.Status.Ready = true
+			},
+			want: infrastructurev1.TinkerbellMachinePhaseProvisioned,
+		},
+		"failed workflow": {
+			mutate: func(tm *infrastructurev1.TinkerbellMachine) {
+				tm.Spec.HardwareName = "hw"
+				conditions.MarkFalse(tm, infrastructurev1.WorkflowSucceededCondition,
+					infrastructurev1.WorkflowFailedReason, clusterv1.ConditionSeverityError, "")
+			},
+			want: infrastructurev1.TinkerbellMachinePhaseFailed,
+		},
+		"terminal error": {
+			mutate: func(tm *infrastructurev1.TinkerbellMachine) {
+				tm.Status.ErrorReason = ptr.To(capierrors.UpdateMachineError)
+			},
+			want: infrastructurev1.TinkerbellMachinePhaseFailed,
+		},
+		"deleting": {
+			mutate: func(tm *infrastructurev1.TinkerbellMachine) {
+				tm.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+				tm.Status.Ready = true
+			},
+			want: infrastructurev1.TinkerbellMachinePhaseDeleting,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			tm := &infrastructurev1.TinkerbellMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "phase-" + name, Namespace: "phases"},
+			}
+			tc.mutate(tm)
+
+			scope := &machineReconcileScope{tinkerbellMachine: tm}
+			scope.setReadyCondition()
+			scope.setPhase()
+
+			g.Expect(tm.Status.Phase).To(Equal(tc.want))
+			g.Expect(testutil.ToFloat64(machinePhase.WithLabelValues(tm.Namespace, tm.Name, string(tc.want)))).
+				To(BeEquivalentTo(1))
+
+			observeMachinePhase(types.NamespacedName{Name: tm.Name, Namespace: tm.Namespace}, "")
+			g.Expect(machinePhase.DeleteLabelValues(tm.Namespace, tm.Name, string(tc.want))).To(BeFalse(),
+				"Expected the series of the machine to be removed")
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/providerid"
+)
+
+// OwnerUIDLabel is set to the UID of the owning TinkerbellMachine on the Templates, Workflows and BMC Jobs CAPT
+// creates in the namespace of Hardware selected from a HardwarePool, as owner references can not point to a
+// TinkerbellMachine of another namespace.
+const OwnerUIDLabel = "tinkerbellmachine.infrastructure.cluster.x-k8s.io/owner-uid"
+
+const (
+	// hardwarePoolUseVerb is the RBAC verb allowing the service accounts of a namespace to use a HardwarePool.
+	hardwarePoolUseVerb = "use"
+
+	// serviceAccountsGroup is the group of all service accounts, which the service accounts of a namespace belong
+	// to in addition to the group of their namespace.
+	serviceAccountsGroup = "system:serviceaccounts"
+)
+
+// ErrHardwarePoolUnavailable is the error returned when the HardwarePool of a TinkerbellMachine does not exist, may
+// not be used in the namespace of the TinkerbellMachine or selects Hardware of namespaces CAPT does not watch.
+var ErrHardwarePoolUnavailable = fmt.Errorf("hardware pool unavailable")
+
+// HardwarePoolAuthorizer returns true when TinkerbellMachines of the given namespace may select Hardware from the
+// HardwarePool with the given name.
+type HardwarePoolAuthorizer func(ctx context.Context, pool, namespace string) (bool, error)
+
+// NewSubjectAccessReviewPoolAuthorizer returns a HardwarePoolAuthorizer creating a SubjectAccessReview with the given
+// client, which checks whether the service accounts of the namespace are allowed to "use" the HardwarePool. Access is
+// granted with RBAC, like to any other resource.
+func NewSubjectAccessReviewPoolAuthorizer(c client.Client) HardwarePoolAuthorizer {
+	return func(ctx context.Context, pool, namespace string) (bool, error) {
+		review := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+			Groups: []string{serviceAccountsGroup, serviceAccountsGroup + ":" + namespace},
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:    infrastructurev1.GroupVersion.Group,
+				Resource: "hardwarepools",
+				Name:     pool,
+				Verb:     hardwarePoolUseVerb,
+			},
+		}}
+
+		if err := c.Create(ctx, review); err != nil {
+			return false, fmt.Errorf("creating SubjectAccessReview: %w", err)
+		}
+
+		return review.Status.Allowed, nil
+	}
+}
+
+// hardwareSources returns the namespaces, with the label selector restricting their Hardware, the machine selects
+// Hardware from: the ones of its HardwarePool, or its own namespace without a pool.
+func (scope *machineReconcileScope) hardwareSources() ([]infrastructurev1.HardwarePoolSource, error) {
+	name := scope.tinkerbellMachine.Spec.HardwarePool
+	if name == "" {
+		return []infrastructurev1.HardwarePoolSource{{Namespace: scope.tinkerbellMachine.Namespace}}, nil
+	}
+
+	pool := &infrastructurev1.HardwarePool{}
+	if err := scope.client.Get(scope.ctx, client.ObjectKey{Name: name}, pool); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s: %w", ErrHardwarePoolUnavailable, name, err)
+		}
+
+		return nil, fmt.Errorf("getting HardwarePool: %w", err)
+	}
+
+	authorize := scope.hardwarePoolAuthorizer
+	if authorize == nil {
+		authorize = NewSubjectAccessReviewPoolAuthorizer(scope.client)
+	}
+
+	allowed, err := authorize(scope.ctx, name, scope.tinkerbellMachine.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("authorizing use of HardwarePool %s: %w", name, err)
+	}
+
+	if !allowed {
+		return nil, fmt.Errorf("%w: namespace %s is not allowed to use %s",
+			ErrHardwarePoolUnavailable, scope.tinkerbellMachine.Namespace, name)
+	}
+
+	// The cache of a controller restricted to some namespaces holds no Hardware of other namespaces.
+	for _, source := range pool.Spec.Sources {
+		if len(scope.watchNamespaces) > 0 && !slices.Contains(scope.watchNamespaces, source.Namespace) {
+			return nil, fmt.Errorf("%w: %s selects Hardware of namespace %s, which is not watched",
+				ErrHardwarePoolUnavailable, name, source.Namespace)
+		}
+	}
+
+	return pool.Spec.Sources, nil
+}
+
+// sourceSelector restricts the given selector to the Hardware selected by the source.
+func sourceSelector(selector labels.Selector, source infrastructurev1.HardwarePoolSource) (labels.Selector, error) {
+	if source.Selector == nil {
+		return selector, nil
+	}
+
+	restriction, err := metav1.LabelSelectorAsSelector(source.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("converting selector of HardwarePool source %s: %w", source.Namespace, err)
+	}
+
+	requirements, selectable := restriction.Requirements()
+	if !selectable {
+		return labels.Nothing(), nil
+	}
+
+	return selector.Add(requirements...), nil
+}
+
+// hardwareNamespace returns the namespace of the Hardware bound to the machine, which is the namespace of its
+// Templates, Workflows and BMC Jobs. It is the namespace of the machine until Hardware is selected.
+func (scope *machineReconcileScope) hardwareNamespace() string {
+	if namespace, _, err := providerid.Parse(scope.tinkerbellMachine.Spec.ProviderID); err == nil {
+		return namespace
+	}
+
+	return scope.tinkerbellMachine.Namespace
+}
+
+// pooled returns true when the Hardware bound to the machine is in another namespace than the machine.
+func (scope *machineReconcileScope) pooled() bool {
+	return scope.hardwareNamespace() != scope.tinkerbellMachine.Namespace
+}
+
+// setOwner makes the TinkerbellMachine the owner of the given object created in the namespace of its Hardware. For
+// pooled Hardware the owner is recorded in labels instead, and the object must be removed explicitly.
+func (scope *machineReconcileScope) setOwner(obj metav1.Object, controller bool) {
+	if scope.pooled() {
+		objLabels := obj.GetLabels()
+		if objLabels == nil {
+			objLabels = map[string]string{}
+		}
+
+		objLabels[OwnerUIDLabel] = string(scope.tinkerbellMachine.UID)
+		objLabels[HardwareOwnerNamespaceLabel] = scope.tinkerbellMachine.Namespace
+		obj.SetLabels(objLabels)
+
+		return
+	}
+
+	ref := metav1.OwnerReference{
+		APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
+		Kind:       "TinkerbellMachine",
+		Name:       scope.tinkerbellMachine.Name,
+		UID:        scope.tinkerbellMachine.ObjectMeta.UID,
+	}
+
+	if controller {
+		ref.Controller = &controller
+	}
+
+	obj.SetOwnerReferences(append(obj.GetOwnerReferences(), ref))
+}
+
+// ownedBy returns true when the given object was created for the TinkerbellMachine by setOwner.
+func (scope *machineReconcileScope) ownedBy(obj metav1.Object) bool {
+	if uid, ok := obj.GetLabels()[OwnerUIDLabel]; ok && uid == string(scope.tinkerbellMachine.UID) {
+		return true
+	}
+
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == scope.tinkerbellMachine.UID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removePooledBMCJobs removes the BMC Jobs of a machine with pooled Hardware, which are not garbage collected with
+// the TinkerbellMachine as they have no owner reference to it.
+func (scope *machineReconcileScope) removePooledBMCJobs() error {
+	if !scope.pooled() {
+		return nil
+	}
+
+	jobs, err := scope.listBMCJobs("")
+	if err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		if err := scope.client.Delete(scope.ctx, &jobs[i]); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting BMCJob: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// PooledObjectToTinkerbellMachine is a handler.MapFunc enqueuing the TinkerbellMachine owning a Workflow or BMC Job
+// created in the namespace of pooled Hardware, which records its owner in labels instead of an owner reference.
+func (r *TinkerbellMachineReconciler) PooledObjectToTinkerbellMachine(ctx context.Context) handler.MapFunc {
+	log := ctrl.LoggerFrom(ctx)
+
+	return func(ctx context.Context, o client.Object) []ctrl.Request {
+		uid, ok := o.GetLabels()[OwnerUIDLabel]
+		if !ok {
+			return nil
+		}
+
+		machines := &infrastructurev1.TinkerbellMachineList{}
+		if err := r.Client.List(ctx, machines,
+			client.InNamespace(o.GetLabels()[HardwareOwnerNamespaceLabel])); err != nil {
+			log.Error(err, "failed to list TinkerbellMachines owning pooled object")
+
+			return nil
+		}
+
+		for i := range machines.Items {
+			if string(machines.Items[i].UID) == uid {
+				return []ctrl.Request{{NamespacedName: client.ObjectKeyFromObject(&machines.Items[i])}}
+			}
+		}
+
+		return nil
+	}
+}
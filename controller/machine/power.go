@@ -0,0 +1,34 @@
+package machine
+
+import (
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// powerActionRequiredReason is the reason of the events describing the power actions to perform for Hardware whose
+// power is managed externally.
+const powerActionRequiredReason = "PowerActionRequired"
+
+// managesPower returns true when the power of the hardware is managed through BMC Jobs, either by CAPT or by
+// Tinkerbell for the workflow.
+func (scope *machineReconcileScope) managesPower(hw *tinkv1.Hardware) bool {
+	switch scope.tinkerbellMachine.Spec.BootOptions.PowerManagement {
+	case "", infrastructurev1.PowerManagementProvider:
+		return hw.Spec.BMCRef != nil
+	default:
+		return false
+	}
+}
+
+// requestPowerAction records an event describing the power action an external system must perform on the hardware,
+// when its power is managed externally.
+func (scope *machineReconcileScope) requestPowerAction(hw *tinkv1.Hardware, action string) {
+	if scope.tinkerbellMachine.Spec.BootOptions.PowerManagement != infrastructurev1.PowerManagementExternal {
+		return
+	}
+
+	scope.log.Info("Power action required", "hardware", hw.Name, "action", action)
+	record.Eventf(scope.tinkerbellMachine, powerActionRequiredReason, "Hardware %s/%s: %s", hw.Namespace, hw.Name, action)
+}
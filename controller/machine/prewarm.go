@@ -0,0 +1,73 @@
+package machine
+
+import (
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// HardwarePrewarmImageAnnotation is set on unclaimed Hardware to the URL of an OS image to pre-warm it with: a
+	// one-off workflow fetches the image on the Hardware before it is claimed, so caches between the Hardware and
+	// the image server are warm and the image is known to be reachable once a machine is provisioned on it. The
+	// annotation is removed once the workflow finished.
+	HardwarePrewarmImageAnnotation = "v1alpha1.tinkerbell.org/prewarm-image"
+
+	// HardwarePrewarmedImageAnnotation is set on Hardware to the URL of the last image it was pre-warmed with.
+	// Hardware pre-warmed with the image of a machine is preferred over other Hardware scoring the same.
+	HardwarePrewarmedImageAnnotation = "v1alpha1.tinkerbell.org/prewarmed-image"
+
+	// HardwarePrewarmedAtAnnotation is set on pre-warmed Hardware to the time its pre-warm workflow succeeded, in
+	// RFC 3339 format.
+	HardwarePrewarmedAtAnnotation = "v1alpha1.tinkerbell.org/prewarmed-at"
+
+	// HardwarePrewarmWorkflowSuffix is appended to the name of Hardware to name the Template and Workflow
+	// pre-warming it.
+	HardwarePrewarmWorkflowSuffix = "-prewarm"
+)
+
+// prewarming returns whether the Hardware is being pre-warmed: a pre-warm was requested with
+// HardwarePrewarmImageAnnotation and its Workflow was created. Requested pre-warms which were not started, e.g. as
+// pre-warming is disabled, do not keep the Hardware from being selected.
+func (scope *machineReconcileScope) prewarming(hw *tinkv1.Hardware) (bool, error) {
+	if hw.Annotations[HardwarePrewarmImageAnnotation] == "" {
+		return false, nil
+	}
+
+	key := client.ObjectKey{Namespace: hw.Namespace, Name: hw.Name + HardwarePrewarmWorkflowSuffix}
+	if err := scope.client.Get(scope.ctx, key, &tinkv1.Workflow{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("getting pre-warm Workflow of Hardware %s: %w", hw.Name, err)
+	}
+
+	return true, nil
+}
+
+// scorePrewarmedHardware records in the scores which of the Hardware was pre-warmed with the image the machine is
+// provisioned with on it.
+func (scope *machineReconcileScope) scorePrewarmedHardware(
+	hardware []tinkv1.Hardware,
+	scores map[client.ObjectKey]*hardwareScore,
+) {
+	for i := range hardware {
+		hw := &hardware[i]
+
+		prewarmed := hw.Annotations[HardwarePrewarmedImageAnnotation]
+		if prewarmed == "" {
+			continue
+		}
+
+		// Failing to render the image URL fails the provisioning later on, it only does not prefer the Hardware.
+		imageURL, err := scope.imageURL(hw)
+		if err != nil {
+			continue
+		}
+
+		scores[client.ObjectKeyFromObject(hw)].Prewarmed = prewarmed == imageURL
+	}
+}
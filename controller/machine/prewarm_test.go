@@ -0,0 +1,54 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_Machine_reconciliation_prefers_hardware_prewarmed_with_its_image(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.ImageLookupFormat = "{{.BaseRegistry}}/ubuntu-{{.OSVersion}}.gz"
+
+	tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkerbellCluster.Spec.ImageLookupBaseRegistry = "http://images.example.com"
+	tinkerbellCluster.Spec.ImageLookupOSVersion = "2204"
+
+	// Without pre-warming, the Hardware scoring the same are ordered by name.
+	stale := validHardware("a", uuid.New().String(), "1.1.1.1")
+	stale.Annotations = map[string]string{
+		machine.HardwarePrewarmedImageAnnotation: "http://images.example.com/ubuntu-2004.gz",
+	}
+
+	prewarmed := validHardware("b", uuid.New().String(), "1.1.1.2")
+	prewarmed.Annotations = map[string]string{
+		machine.HardwarePrewarmedImageAnnotation: "http://images.example.com/ubuntu-2204.gz",
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		tinkerbellCluster,
+		stale,
+		prewarmed,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(context.Background(),
+		types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, tm)).To(Succeed())
+	g.Expect(tm.Spec.HardwareName).To(Equal("b"))
+}
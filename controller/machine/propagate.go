@@ -0,0 +1,40 @@
+package machine
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// propagateMetadata copies the labels and annotations configured for propagation from the TinkerbellMachine onto
+// a Template, Workflow or BMC Job created for it, so tooling filtering or charging back by them covers the
+// Tinkerbell objects too. Labels and annotations set by the controller itself are never overwritten.
+func (scope *machineReconcileScope) propagateMetadata(obj metav1.Object) {
+	labels := copyKeys(obj.GetLabels(), scope.tinkerbellMachine.GetLabels(), scope.propagatedLabels)
+	if len(labels) > 0 {
+		obj.SetLabels(labels)
+	}
+
+	annotations := copyKeys(obj.GetAnnotations(), scope.tinkerbellMachine.GetAnnotations(), scope.propagatedAnnotations)
+	if len(annotations) > 0 {
+		obj.SetAnnotations(annotations)
+	}
+}
+
+// copyKeys copies the given keys present in src into dst, unless dst already has them.
+func copyKeys(dst, src map[string]string, keys []string) map[string]string {
+	for _, k := range keys {
+		v, ok := src[k]
+		if !ok {
+			continue
+		}
+
+		if dst == nil {
+			dst = map[string]string{}
+		}
+
+		if _, exists := dst[k]; !exists {
+			dst[k] = v
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,45 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_propagateMetadata(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scope := &machineReconcileScope{
+		tinkerbellMachine: &infrastructurev1.TinkerbellMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels:      map[string]string{"team": "storage", "cost-center": "42", "other": "ignored"},
+				Annotations: map[string]string{"owner": "storage@example.com"},
+			},
+		},
+		propagatedLabels:      []string{"team", "cost-center", "missing"},
+		propagatedAnnotations: []string{"owner"},
+	}
+
+	wf := &tinkv1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "set-by-controller"}},
+	}
+
+	scope.propagateMetadata(wf)
+
+	g.Expect(wf.Labels).To(Equal(map[string]string{"team": "set-by-controller", "cost-center": "42"}))
+	g.Expect(wf.Annotations).To(Equal(map[string]string{"owner": "storage@example.com"}))
+
+	scope.propagatedLabels = nil
+	scope.propagatedAnnotations = nil
+
+	template := &tinkv1.Template{}
+	scope.propagateMetadata(template)
+
+	g.Expect(template.Labels).To(BeNil())
+	g.Expect(template.Annotations).To(BeNil())
+}
@@ -0,0 +1,97 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// proxyDropInServices are the systemd services of the provisioned OS configured to use the proxy of the cluster.
+var proxyDropInServices = []string{"containerd", "docker"}
+
+// proxyEnvironment returns the environment variables configuring the given proxy, in upper and lower case as tools
+// disagree on which one they read. Nil is returned when no proxy is configured.
+func proxyEnvironment(proxy *infrastructurev1.Proxy) map[string]string {
+	if proxy == nil {
+		return nil
+	}
+
+	env := map[string]string{}
+
+	for name, value := range map[string]string{
+		"HTTP_PROXY":  proxy.HTTPProxy,
+		"HTTPS_PROXY": proxy.HTTPSProxy,
+		"NO_PROXY":    strings.Join(proxy.NoProxy, ","),
+	} {
+		if value != "" {
+			env[name] = value
+			env[strings.ToLower(name)] = value
+		}
+	}
+
+	if len(env) == 0 {
+		return nil
+	}
+
+	return env
+}
+
+// applyProxyEnvironment sets the environment variables of the given proxy on every action of the Tinkerbell template
+// data, so actions downloading images reach them through the proxy.
+func applyProxyEnvironment(data string, proxy *infrastructurev1.Proxy) (string, error) {
+	env := proxyEnvironment(proxy)
+	if len(env) == 0 {
+		return data, nil
+	}
+
+	doc, tasks, err := parseTemplate(data)
+	if err != nil {
+		return "", err
+	}
+
+	forEachAction(tasks, func(_ string, action *yaml.Node) {
+		setMappingValues(action, "environment", env)
+	})
+
+	return encodeTemplate(doc)
+}
+
+// proxyFiles returns the systemd drop-ins configuring the services of proxyDropInServices to use the given proxy.
+func proxyFiles(proxy *infrastructurev1.Proxy) []WorkflowFile {
+	env := proxyEnvironment(proxy)
+	if len(env) == 0 {
+		return nil
+	}
+
+	dropIn := &strings.Builder{}
+	dropIn.WriteString("[Service]\n")
+
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"} {
+		if value, ok := env[name]; ok {
+			fmt.Fprintf(dropIn, "Environment=\"%s=%s\"\n", name, value)
+		}
+	}
+
+	files := make([]WorkflowFile, 0, len(proxyDropInServices))
+
+	for _, service := range proxyDropInServices {
+		files = append(files, WorkflowFile{
+			Path:    fmt.Sprintf("/etc/systemd/system/%s.service.d/http-proxy.conf", service),
+			Content: dropIn.String(),
+		})
+	}
+
+	return files
+}
+
+// proxy returns the proxy configuration of the cluster of the machine, if any.
+func (scope *machineReconcileScope) proxy() *infrastructurev1.Proxy {
+	if scope.tinkerbellCluster == nil {
+		return nil
+	}
+
+	return scope.tinkerbellCluster.Spec.Proxy
+}
@@ -0,0 +1,117 @@
+package machine
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+const (
+	// HardwareQuarantinedLabel is hardwareutil.QuarantinedLabel. Removing the label, together with the
+	// HardwareFailuresAnnotation, returns the Hardware to the pool.
+	HardwareQuarantinedLabel = hardwareutil.QuarantinedLabel
+
+	// HardwareFailuresAnnotation is set by CAPT on Hardware to the number of consecutive provisioning failures,
+	// either failed workflows or failed BMC Jobs. It is removed once the Hardware is provisioned successfully.
+	HardwareFailuresAnnotation = "v1alpha1.tinkerbell.org/provisioning-failures"
+
+	// HardwareLastFailureAnnotation identifies the last failure counted in HardwareFailuresAnnotation, so a
+	// failure observed by several reconciliations is only counted once.
+	HardwareLastFailureAnnotation = "v1alpha1.tinkerbell.org/last-provisioning-failure"
+
+	// HardwareLastFailureTimeAnnotation is set by CAPT on Hardware to when the failure identified by
+	// HardwareLastFailureAnnotation was observed, in RFC 3339 format. Hardware is not selected until the hardware
+	// failure cool-down of the cluster elapsed since then.
+	HardwareLastFailureTimeAnnotation = "v1alpha1.tinkerbell.org/last-provisioning-failure-time"
+)
+
+// failureKey identifies a failed workflow or BMC Job.
+func failureKey(kind, name string, uid types.UID) string {
+	return fmt.Sprintf("%s/%s/%s", kind, name, uid)
+}
+
+// recordHardwareFailure records when a provisioning failure of the Hardware was observed, counts it and
+// quarantines the Hardware once the quarantine threshold of consecutive failures is reached. Failures already
+// recorded are ignored.
+func (scope *machineReconcileScope) recordHardwareFailure(hw *tinkv1.Hardware, key, msg string) error {
+	if hw.GetAnnotations()[HardwareLastFailureAnnotation] == key {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(hw, scope.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	// A malformed count is treated as no previous failures.
+	failures, _ := strconv.Atoi(hw.GetAnnotations()[HardwareFailuresAnnotation])
+	failures++
+
+	if hw.Annotations == nil {
+		hw.Annotations = map[string]string{}
+	}
+
+	if scope.quarantineThreshold > 0 {
+		hw.Annotations[HardwareFailuresAnnotation] = strconv.Itoa(failures)
+	}
+
+	hw.Annotations[HardwareLastFailureAnnotation] = key
+	hw.Annotations[HardwareLastFailureTimeAnnotation] = time.Now().UTC().Format(time.RFC3339)
+
+	quarantine := scope.quarantineThreshold > 0 && failures >= scope.quarantineThreshold &&
+		hw.GetLabels()[HardwareQuarantinedLabel] == ""
+	if quarantine {
+		if hw.Labels == nil {
+			hw.Labels = map[string]string{}
+		}
+
+		hw.Labels[HardwareQuarantinedLabel] = "true"
+	}
+
+	if err := patchHelper.Patch(scope.ctx, hw); err != nil {
+		return fmt.Errorf("patching Hardware object: %w", err)
+	}
+
+	if quarantine {
+		scope.log.Info("Quarantined Hardware after consecutive provisioning failures",
+			"Hardware name", hw.Name, "failures", failures)
+
+		record.Warnf(hw, "HardwareQuarantined",
+			"Hardware quarantined after %d consecutive provisioning failures, the last one on TinkerbellMachine %s: %s",
+			failures, scope.tinkerbellMachine.Name, msg)
+	}
+
+	return nil
+}
+
+// resetHardwareFailures clears the consecutive provisioning failures of successfully provisioned Hardware.
+func (scope *machineReconcileScope) resetHardwareFailures(hw *tinkv1.Hardware) error {
+	_, counted := hw.GetAnnotations()[HardwareFailuresAnnotation]
+	_, last := hw.GetAnnotations()[HardwareLastFailureAnnotation]
+
+	if !counted && !last {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(hw, scope.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	delete(hw.Annotations, HardwareFailuresAnnotation)
+	delete(hw.Annotations, HardwareLastFailureAnnotation)
+	delete(hw.Annotations, HardwareLastFailureTimeAnnotation)
+
+	if err := patchHelper.Patch(scope.ctx, hw); err != nil {
+		return fmt.Errorf("patching Hardware object: %w", err)
+	}
+
+	return nil
+}
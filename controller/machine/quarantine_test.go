@@ -0,0 +1,66 @@
+package machine //nolint:testpackage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_recordHardwareFailure(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+
+	hw := &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hw).Build()
+
+	scope := &machineReconcileScope{
+		log:    logr.Discard(),
+		ctx:    context.Background(),
+		client: c,
+		tinkerbellMachine: &infrastructurev1.TinkerbellMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+		},
+		quarantineThreshold: 2,
+	}
+
+	current := func() *tinkv1.Hardware {
+		updated := &tinkv1.Hardware{}
+		g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(hw), updated)).To(Succeed())
+
+		return updated
+	}
+
+	g.Expect(scope.recordHardwareFailure(current(), "Workflow/machine/1", "failed")).To(Succeed())
+	g.Expect(scope.recordHardwareFailure(current(), "Workflow/machine/1", "failed")).To(Succeed())
+	g.Expect(current().Annotations).To(HaveKeyWithValue(HardwareFailuresAnnotation, "1"),
+		"Expected a failure to be counted once")
+	g.Expect(current().Labels).NotTo(HaveKey(HardwareQuarantinedLabel))
+
+	g.Expect(scope.recordHardwareFailure(current(), "Job/machine-eject-media-x/2", "failed")).To(Succeed())
+	g.Expect(current().Annotations).To(HaveKeyWithValue(HardwareFailuresAnnotation, "2"))
+	g.Expect(current().Labels).To(HaveKeyWithValue(HardwareQuarantinedLabel, "true"))
+
+	g.Expect(scope.resetHardwareFailures(current())).To(Succeed())
+	g.Expect(current().Annotations).NotTo(HaveKey(HardwareFailuresAnnotation))
+	g.Expect(current().Annotations).NotTo(HaveKey(HardwareLastFailureAnnotation))
+	g.Expect(current().Annotations).NotTo(HaveKey(HardwareLastFailureTimeAnnotation))
+	g.Expect(current().Labels).To(HaveKey(HardwareQuarantinedLabel), "Expected quarantine to be lifted by operators only")
+
+	scope.quarantineThreshold = 0
+	g.Expect(scope.recordHardwareFailure(current(), "Workflow/machine/3", "failed")).To(Succeed())
+	g.Expect(current().Annotations).NotTo(HaveKey(HardwareFailuresAnnotation), "Expected quarantining to be disabled")
+	g.Expect(current().Annotations).To(HaveKey(HardwareLastFailureTimeAnnotation),
+		"Expected the failure to be recorded for the cool-down")
+}
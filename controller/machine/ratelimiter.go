@@ -0,0 +1,127 @@
+package machine
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type operation string
+
+const (
+	operationCreate operation = "create"
+	operationDelete operation = "delete"
+
+	deleteRetryBaseDelay = 5 * time.Millisecond
+	deleteRetryMaxDelay  = 30 * time.Second
+)
+
+// operationRateLimiter is a workqueue rate limiter which keeps TinkerbellMachine deletions from being starved by
+// creations that keep failing, e.g. because no hardware is available.
+//
+// The default controller rate limiter combines a per-item exponential backoff with an overall token bucket. When the
+// hardware pool is exhausted, failing creations drain the shared bucket and deletions, which are the very operations
+// that free up hardware, end up waiting behind them. Deletions therefore get their own per-item backoff, capped at a
+// much lower delay, and do not take tokens from the bucket used by creations.
+type operationRateLimiter struct {
+	create workqueue.TypedRateLimiter[reconcile.Request]
+	delete workqueue.TypedRateLimiter[reconcile.Request]
+
+	mu       sync.Mutex
+	deleting map[reconcile.Request]struct{}
+	pending  map[reconcile.Request]operation
+}
+
+var _ workqueue.TypedRateLimiter[reconcile.Request] = &operationRateLimiter{}
+
+func newOperationRateLimiter() *operationRateLimiter {
+	return &operationRateLimiter{
+		create:   workqueue.DefaultTypedControllerRateLimiter[reconcile.Request](),
+		delete:   workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](deleteRetryBaseDelay, deleteRetryMaxDelay),
+		deleting: map[reconcile.Request]struct{}{},
+		pending:  map[reconcile.Request]operation{},
+	}
+}
+
+// observe records whether the TinkerbellMachine behind the request is being deleted.
+func (l *operationRateLimiter) observe(item reconcile.Request, deleting bool) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if deleting {
+		l.deleting[item] = struct{}{}
+
+		return
+	}
+
+	delete(l.deleting, item)
+}
+
+func (l *operationRateLimiter) operation(item reconcile.Request) operation {
+	if _, ok := l.deleting[item]; ok {
+		return operationDelete
+	}
+
+	return operationCreate
+}
+
+// When returns how long to wait before the item is processed again.
+func (l *operationRateLimiter) When(item reconcile.Request) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	op := l.operation(item)
+	l.pending[item] = op
+	l.updateMetrics()
+
+	if op == operationDelete {
+		return l.delete.When(item)
+	}
+
+	return l.create.When(item)
+}
+
+// Forget indicates that an item is finished being retried.
+func (l *operationRateLimiter) Forget(item reconcile.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.pending, item)
+	l.updateMetrics()
+
+	l.create.Forget(item)
+	l.delete.Forget(item)
+}
+
+// NumRequeues returns how many times the item was requeued.
+func (l *operationRateLimiter) NumRequeues(item reconcile.Request) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.operation(item) == operationDelete {
+		return l.delete.NumRequeues(item)
+	}
+
+	return l.create.NumRequeues(item)
+}
+
+func (l *operationRateLimiter) updateMetrics() {
+	counts := map[operation]int{
+		operationCreate: 0,
+		operationDelete: 0,
+	}
+
+	for _, op := range l.pending {
+		counts[op]++
+	}
+
+	for op, count := range counts {
+		requeuedMachines.WithLabelValues(string(op)).Set(float64(count))
+	}
+}
@@ -0,0 +1,38 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_operationRateLimiter_does_not_starve_deletions(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	limiter := newOperationRateLimiter()
+
+	creating := reconcile.Request{NamespacedName: types.NamespacedName{Name: "creating", Namespace: "default"}}
+	deleting := reconcile.Request{NamespacedName: types.NamespacedName{Name: "deleting", Namespace: "default"}}
+
+	limiter.observe(deleting, true)
+
+	// Simulate a creation failing over and over because no hardware is available.
+	for range 200 {
+		limiter.When(creating)
+	}
+
+	g.Expect(limiter.When(creating)).To(BeNumerically(">", time.Minute), "Expected failing creation to back off")
+	g.Expect(limiter.When(deleting)).To(BeNumerically("<", time.Second), "Expected deletion not to wait behind creations")
+	g.Expect(limiter.NumRequeues(deleting)).To(Equal(1))
+
+	limiter.Forget(deleting)
+	g.Expect(limiter.NumRequeues(deleting)).To(BeZero())
+
+	// Once the object is gone, it is treated as a regular operation again.
+	limiter.observe(deleting, false)
+	g.Expect(limiter.NumRequeues(deleting)).To(BeZero())
+}
@@ -0,0 +1,66 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+)
+
+const (
+	// HardwareProvisioningReadyAnnotation is set on Hardware to "true" when it passes the checks CAPT runs before
+	// claiming Hardware for a machine, "false" otherwise. Hardware failing the checks is never selected.
+	HardwareProvisioningReadyAnnotation = "v1alpha1.tinkerbell.org/provisioning-ready"
+
+	// HardwareNotReadyReasonAnnotation is set on Hardware failing the pre-claim checks to the reasons it cannot be
+	// provisioned. It is removed once the Hardware passes them.
+	HardwareNotReadyReasonAnnotation = "v1alpha1.tinkerbell.org/provisioning-not-ready-reason"
+)
+
+// ErrHardwareNotReady is the error returned when Hardware fails the pre-claim checks.
+var ErrHardwareNotReady = fmt.Errorf("hardware is not ready for provisioning")
+
+// HardwareReadiness runs the checks Hardware has to pass before it is claimed for a machine, so Hardware which
+// would fail provisioning is not selected. It returns nil when the Hardware is ready, the problems found otherwise.
+func HardwareReadiness(hw *tinkv1.Hardware) error {
+	return hardwareReadiness(hw, false)
+}
+
+// hardwareReadiness runs the pre-claim checks, skipping the disk configuration for diskless machines.
+func hardwareReadiness(hw *tinkv1.Hardware, diskless bool) error {
+	var errs []error
+
+	if !diskless && (len(hw.Spec.Disks) < 1 || hw.Spec.Disks[0].Device == "") {
+		errs = append(errs, ErrHardwareMissingDiskConfiguration)
+	}
+
+	if _, err := hardwareIP(hw); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// readyHardware returns the given Hardware which passes the pre-claim checks. When none does, the returned error
+// lists why each Hardware is not ready, so the machine reports what to fix instead of only no Hardware available.
+// The disk configuration is not checked for diskless machines.
+func readyHardware(hardware []tinkv1.Hardware, diskless bool) ([]tinkv1.Hardware, error) {
+	ready := make([]tinkv1.Hardware, 0, len(hardware))
+	notReady := []error{}
+
+	for i := range hardware {
+		if err := hardwareReadiness(&hardware[i], diskless); err != nil {
+			notReady = append(notReady, fmt.Errorf("%w: Hardware %s: %w", ErrHardwareNotReady, hardware[i].Name, err))
+
+			continue
+		}
+
+		ready = append(ready, hardware[i])
+	}
+
+	if len(ready) == 0 && len(notReady) > 0 {
+		return nil, errors.Join(notReady...)
+	}
+
+	return ready, nil
+}
@@ -0,0 +1,204 @@
+package machine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// releaseHookTimeout is how long a post-release hook waits for the endpoint to respond. It is kept short as the
+// hook is called while reconciling the deletion of the machine.
+const releaseHookTimeout = 10 * time.Second
+
+var (
+	// ErrReleaseHookFailed is the error returned when the post-release hook of a machine did not succeed.
+	ErrReleaseHookFailed = fmt.Errorf("post-release hook failed")
+
+	// ErrReleaseHookAddressForbidden is the error returned when the post-release hook resolves to an address of the
+	// node or the link, e.g. the cloud metadata endpoint, which machine owners must not reach through CAPT.
+	ErrReleaseHookAddressForbidden = errors.New("post-release hook address is forbidden")
+)
+
+// ReleasedHardware is the payload sent to the post-release hook of a machine.
+type ReleasedHardware struct {
+	// Name and Namespace are the name and namespace of the released Hardware.
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+
+	// Hostname is the hostname of the Hardware, if any.
+	Hostname string `json:"hostname,omitempty"`
+
+	// Interfaces are the interfaces of the Hardware with a MAC address.
+	Interfaces []ReleasedInterface `json:"interfaces,omitempty"`
+
+	// Machine is the TinkerbellMachine the Hardware was released from.
+	Machine ReleasedFrom `json:"machine"`
+}
+
+// ReleasedInterface is an interface of released Hardware.
+type ReleasedInterface struct {
+	MAC       string `json:"mac"`
+	IPAddress string `json:"ipAddress,omitempty"`
+	Hostname  string `json:"hostname,omitempty"`
+}
+
+// ReleasedFrom identifies the TinkerbellMachine Hardware was released from.
+type ReleasedFrom struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	Cluster    string `json:"cluster,omitempty"`
+	ProviderID string `json:"providerID,omitempty"`
+}
+
+// ReleaseNotifier sends the given released Hardware to the post-release hook at the given URL, and returns an
+// error unless the hook succeeded.
+type ReleaseNotifier func(ctx context.Context, hookURL string, released ReleasedHardware) error
+
+// NewHTTPReleaseNotifier returns a ReleaseNotifier sending a POST request with the released Hardware in JSON with
+// the given client.
+func NewHTTPReleaseNotifier(client *http.Client) ReleaseNotifier {
+	return func(ctx context.Context, hookURL string, released ReleasedHardware) error {
+		body, err := json.Marshal(released)
+		if err != nil {
+			return fmt.Errorf("encoding released hardware: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, releaseHookTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, hookURL, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("%w: creating request: %w", ErrReleaseHookFailed, err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrReleaseHookFailed, err)
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%w: %s returned %d %s", ErrReleaseHookFailed, hookURL, resp.StatusCode,
+				http.StatusText(resp.StatusCode))
+		}
+
+		return nil
+	}
+}
+
+// NewReleaseHookHTTPClient returns the client the post-release hooks are called with by default: it times out after
+// releaseHookTimeout, does not follow redirects and refuses to connect to loopback, link-local, multicast and
+// unspecified addresses, whatever the host of the hook resolves to.
+func NewReleaseHookHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: releaseHookTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrReleaseHookAddressForbidden, err)
+			}
+
+			if ip := addrPort.Addr().Unmap(); ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+				ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+				return fmt.Errorf("%w: %s", ErrReleaseHookAddressForbidden, ip)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   releaseHookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// releasedHardware returns the payload of the post-release hook for the given Hardware.
+func (scope *machineReconcileScope) releasedHardware(hw *tinkv1.Hardware) ReleasedHardware {
+	released := ReleasedHardware{
+		Name:      hw.Name,
+		Namespace: hw.Namespace,
+		Machine: ReleasedFrom{
+			Name:       scope.tinkerbellMachine.Name,
+			Namespace:  scope.tinkerbellMachine.Namespace,
+			Cluster:    scope.tinkerbellMachine.Labels[clusterv1.ClusterNameLabel],
+			ProviderID: scope.tinkerbellMachine.Spec.ProviderID,
+		},
+	}
+
+	if hw.Spec.Metadata != nil && hw.Spec.Metadata.Instance != nil {
+		released.Hostname = hw.Spec.Metadata.Instance.Hostname
+	}
+
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP == nil || iface.DHCP.MAC == "" {
+			continue
+		}
+
+		ri := ReleasedInterface{MAC: iface.DHCP.MAC, Hostname: iface.DHCP.Hostname}
+		if iface.DHCP.IP != nil {
+			ri.IPAddress = iface.DHCP.IP.Address
+		}
+
+		released.Interfaces = append(released.Interfaces, ri)
+	}
+
+	return released
+}
+
+// notifyPostReleaseHook calls the post-release hook of the machine, if any, before its Hardware is released, so the
+// Hardware cannot be claimed by another machine while the hook runs. An error is returned when the hook failed and
+// its failure policy is Fail, so the Hardware stays claimed, the TinkerbellMachine is kept and the hook retried.
+func (scope *machineReconcileScope) notifyPostReleaseHook(hw *tinkv1.Hardware) error {
+	hook := scope.tinkerbellMachine.Spec.PostReleaseHook
+	if hook == nil {
+		return nil
+	}
+
+	notify := scope.releaseNotifier
+	if notify == nil {
+		notify = NewHTTPReleaseNotifier(NewReleaseHookHTTPClient())
+	}
+
+	err := notify(scope.ctx, hook.URL, scope.releasedHardware(hw))
+	if err == nil {
+		scope.log.Info("Notified post-release hook", "hardware", hw.Name, "url", hook.URL)
+
+		return nil
+	}
+
+	if hook.FailurePolicy == infrastructurev1.PostReleaseHookFailurePolicyIgnore {
+		record.Warnf(scope.tinkerbellMachine, "PostReleaseHookFailed", "Ignored failed post-release hook: %v", err)
+
+		return nil
+	}
+
+	record.Warnf(scope.tinkerbellMachine, "PostReleaseHookFailed", "Post-release hook failed, retrying: %v", err)
+
+	return err
+}
@@ -0,0 +1,154 @@
+package machine_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_Machine_reconciliation_notifies_post_release_hook(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	var (
+		client   ctrlclient.Client
+		failing  atomic.Bool
+		released machine.ReleasedHardware
+		owned    bool
+	)
+
+	failing.Store(true)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hw := &tinkv1.Hardware{}
+		key := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+
+		if err := client.Get(r.Context(), key, hw); err == nil {
+			owned = hw.Labels[machine.HardwareOwnerNameLabel] == tinkerbellMachineName
+		}
+
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&released); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+	tm.Spec.PostReleaseHook = &infrastructurev1.PostReleaseHook{URL: server.URL}
+
+	hw := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+	hw.Spec.Interfaces[0].DHCP.MAC = "00:00:5e:00:53:01"
+
+	client = kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	r := &machine.TinkerbellMachineReconciler{
+		Client:          client,
+		ReleaseNotifier: machine.NewHTTPReleaseNotifier(server.Client()),
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}}
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, req.NamespacedName, tm)).To(Succeed())
+	g.Expect(client.Delete(ctx, tm)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).To(MatchError(machine.ErrReleaseHookFailed))
+	g.Expect(client.Get(ctx, req.NamespacedName, tm)).To(Succeed(),
+		"Expected the machine to be kept until the hook succeeds")
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}, hw)).To(Succeed())
+	g.Expect(hw.Labels).To(HaveKeyWithValue(machine.HardwareOwnerNameLabel, tinkerbellMachineName),
+		"Expected the Hardware to stay claimed until the hook succeeds")
+
+	failing.Store(false)
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = client.Get(ctx, req.NamespacedName, tm)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the machine to be deleted once the hook succeeded")
+
+	g.Expect(released.Name).To(Equal(hardwareName))
+	g.Expect(released.Namespace).To(Equal(clusterNamespace))
+	g.Expect(released.Interfaces).To(Equal([]machine.ReleasedInterface{
+		{MAC: "00:00:5e:00:53:01", IPAddress: hardwareIP},
+	}))
+	g.Expect(released.Machine.Name).To(Equal(tinkerbellMachineName))
+	g.Expect(owned).To(BeTrue(), "Expected the hook to be called before the Hardware is released")
+}
+
+func Test_ReleaseHookHTTPClient_refuses_loopback_addresses(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	notify := machine.NewHTTPReleaseNotifier(machine.NewReleaseHookHTTPClient())
+
+	err := notify(context.Background(), server.URL, machine.ReleasedHardware{Name: hardwareName})
+	g.Expect(err).To(MatchError(machine.ErrReleaseHookAddressForbidden))
+}
+
+func Test_Machine_reconciliation_ignores_failed_post_release_hook(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+	tm.Spec.PostReleaseHook = &infrastructurev1.PostReleaseHook{
+		URL:           "http://127.0.0.1:1",
+		FailurePolicy: infrastructurev1.PostReleaseHookFailurePolicyIgnore,
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, uuid.New().String(), hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	req := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, req, tm)).To(Succeed())
+	g.Expect(client.Delete(ctx, tm)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = client.Get(ctx, req, tm)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the machine to be deleted despite the failed hook")
+}
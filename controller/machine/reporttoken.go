@@ -0,0 +1,109 @@
+package machine
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// ReportTokenKey is the key of the token in the Secrets holding the tokens of report endpoints.
+	ReportTokenKey = "token"
+
+	// reportTokenSecretSuffix is appended to the name of a TinkerbellMachine to name the Secret holding the token
+	// its machine reports with.
+	reportTokenSecretSuffix = "-report-token"
+
+	// reportTokenBytes is the number of random bytes of a report token.
+	reportTokenBytes = 32
+)
+
+// ErrReportTokenSecretConflict is returned when the Secret holding the report token of an object exists but is
+// not controlled by it, e.g. while the Secret of a deleted object of the same name is being garbage collected.
+var ErrReportTokenSecretConflict = errors.New("report token Secret is not controlled by its object")
+
+// EnsureReportToken returns the token the given object, a TinkerbellMachine or Hardware, is reported for with,
+// from the Secret of the given name in its namespace. The Secret is created with a random token when it does not
+// exist, controlled by the object, so it is deleted with it and a recreated object gets a new token.
+func EnsureReportToken(ctx context.Context, c client.Client, owner client.Object, name string) (string, error) {
+	secret := &corev1.Secret{}
+
+	err := c.Get(ctx, client.ObjectKey{Namespace: owner.GetNamespace(), Name: name}, secret)
+	if err == nil {
+		if !metav1.IsControlledBy(secret, owner) || len(secret.Data[ReportTokenKey]) == 0 {
+			return "", fmt.Errorf("%w: %s", ErrReportTokenSecretConflict, name)
+		}
+
+		return string(secret.Data[ReportTokenKey]), nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("getting report token Secret %s: %w", name, err)
+	}
+
+	token := make([]byte, reportTokenBytes)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("generating report token: %w", err)
+	}
+
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: owner.GetNamespace()},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{ReportTokenKey: []byte(hex.EncodeToString(token))},
+	}
+
+	if err := controllerutil.SetControllerReference(owner, secret, c.Scheme()); err != nil {
+		return "", fmt.Errorf("setting owner of report token Secret: %w", err)
+	}
+
+	if err := c.Create(ctx, secret); err != nil {
+		return "", fmt.Errorf("creating report token Secret %s: %w", name, err)
+	}
+
+	return string(secret.Data[ReportTokenKey]), nil
+}
+
+// VerifyReportToken returns whether the given token is the one of the object, from the Secret of the given name in
+// its namespace. Tokens are compared in constant time. Reports for objects without a token are refused.
+func VerifyReportToken(ctx context.Context, c client.Client, owner client.Object, name, token string) (bool, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: owner.GetNamespace(), Name: name}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("getting report token Secret %s: %w", name, err)
+	}
+
+	want := secret.Data[ReportTokenKey]
+	if !metav1.IsControlledBy(secret, owner) || len(want) == 0 {
+		return false, nil
+	}
+
+	return subtle.ConstantTimeCompare(want, []byte(token)) == 1, nil
+}
+
+// ReportTokenSecretName returns the name of the Secret holding the token the TinkerbellMachine of the given name
+// reports with, shortened and suffixed with a hash of the name so it stays a valid name for long machine names.
+func ReportTokenSecretName(tinkerbellMachineName string) string {
+	return hashSuffixedName(tinkerbellMachineName+reportTokenSecretSuffix, tinkerbellMachineName)
+}
+
+// reportTokenSecretName returns the name of the Secret holding the token the machine reports with.
+func (scope *machineReconcileScope) reportTokenSecretName() string {
+	return ReportTokenSecretName(scope.tinkerbellMachine.Name)
+}
+
+// reportToken returns the token the machine reports its bootstrap result and makes the netboot handshake with.
+func (scope *machineReconcileScope) reportToken() (string, error) {
+	return EnsureReportToken(scope.ctx, scope.client, scope.tinkerbellMachine, scope.reportTokenSecretName())
+}
@@ -0,0 +1,87 @@
+package machine
+
+import (
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// ReprovisionAnnotation is set on a TinkerbellMachine to "true" to reinstall the OS of its Hardware in place,
+// keeping the Machine. It is removed once the reinstallation was started.
+const ReprovisionAnnotation = "tinkerbellmachine.infrastructure.cluster.x-k8s.io/reprovision"
+
+// reprovisionRequested returns true when the TinkerbellMachine is annotated to be reprovisioned.
+func (scope *machineReconcileScope) reprovisionRequested() bool {
+	return scope.tinkerbellMachine.Annotations[ReprovisionAnnotation] == "true"
+}
+
+// reprovisionDrained drains the Node of the machine and reprovisions its Hardware once the Node is drained, as the
+// Hardware is power cycled to netboot.
+func (scope *machineReconcileScope) reprovisionDrained(hw *tinkv1.Hardware) error {
+	drained, err := scope.drainNode()
+	if err != nil {
+		return fmt.Errorf("draining Node: %w", err)
+	}
+
+	if !drained {
+		scope.requeue(drainRequeueAfter)
+
+		return nil
+	}
+
+	return scope.reprovision(hw)
+}
+
+// reprovision starts the reinstallation of the provisioned Hardware of the machine: the Templates and Workflows of
+// the machine are removed and the Hardware is no longer marked as provisioned, so they are created again and the
+// Hardware netboots to run them, overwriting its disk. The machine is not Ready until the new Workflow succeeded.
+func (scope *machineReconcileScope) reprovision(hw *tinkv1.Hardware) error {
+	scope.log.Info("Reprovisioning Hardware", "hardware", hw.Name)
+
+	if err := scope.removeTemplate(); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("removing Template: %w", err)
+	}
+
+	if err := scope.removeWorkflow(); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("removing Workflow: %w", err)
+	}
+
+	patchHelper, err := patch.NewHelper(hw, scope.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	delete(hw.Annotations, HardwareProvisionedAnnotation)
+	delete(hw.Annotations, HardwareNetbootHandshakeAnnotation)
+
+	if err := patchHelper.Patch(scope.ctx, hw); err != nil {
+		return fmt.Errorf("patching Hardware object: %w", err)
+	}
+
+	delete(scope.tinkerbellMachine.Annotations, ReprovisionAnnotation)
+	scope.tinkerbellMachine.Status.Ready = false
+	scope.tinkerbellMachine.Status.ConsoleLog = nil
+	conditions.Delete(scope.tinkerbellMachine, infrastructurev1.WorkflowStagesSucceededCondition)
+	conditions.Delete(scope.tinkerbellMachine, infrastructurev1.WorkflowSucceededCondition)
+	conditions.Delete(scope.tinkerbellMachine, infrastructurev1.BootstrapSucceededCondition)
+
+	record.Eventf(scope.tinkerbellMachine, "Reprovisioning", "Reinstalling the OS of Hardware %s", hw.Name)
+
+	return scope.patch()
+}
+
+// ignoreReprovision removes the ReprovisionAnnotation of a machine which cannot be reprovisioned yet, so it is not
+// reprovisioned as soon as it is provisioned.
+func (scope *machineReconcileScope) ignoreReprovision(reason string) error {
+	record.Warnf(scope.tinkerbellMachine, "ReprovisionIgnored", "Ignored reprovisioning request: %s", reason)
+
+	delete(scope.tinkerbellMachine.Annotations, ReprovisionAnnotation)
+
+	return scope.patch()
+}
@@ -0,0 +1,135 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_Machine_reconciliation_reprovisions_annotated_machine(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Annotations = map[string]string{machine.ReprovisionAnnotation: "true"}
+	tm.Status.Ready = true
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP, testOptions{
+		Labels: map[string]string{
+			machine.HardwareOwnerNameLabel:      tinkerbellMachineName,
+			machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+		},
+	})
+	hw.Annotations = map[string]string{machine.HardwareProvisionedAnnotation: "true"}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+		validWorkflow(tinkerbellMachineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, key, tm)).To(Succeed())
+	g.Expect(tm.Annotations).NotTo(HaveKey(machine.ReprovisionAnnotation), "Expected the request to be consumed")
+	g.Expect(tm.Status.Ready).To(BeFalse())
+	g.Expect(tm.Status.Phase).To(Equal(infrastructurev1.TinkerbellMachinePhaseProvisioning))
+
+	err = client.Get(ctx, key, &tinkv1.Workflow{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the previous Workflow to be removed")
+
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}, hw)).To(Succeed())
+	g.Expect(hw.Annotations).NotTo(HaveKey(machine.HardwareProvisionedAnnotation))
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	wf := &tinkv1.Workflow{}
+	g.Expect(client.Get(ctx, key, wf)).To(Succeed(), "Expected a new Workflow reinstalling the same Hardware")
+	g.Expect(wf.Spec.HardwareRef).To(Equal(hardwareName))
+	g.Expect(client.Get(ctx, key, &tinkv1.Template{})).To(Succeed())
+}
+
+func Test_Machine_reconciliation_ignores_reprovisioning_of_unprovisioned_machine(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+	tm.Annotations = map[string]string{machine.ReprovisionAnnotation: "true"}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, uuid.New().String(), hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, key, tm)).To(Succeed())
+	g.Expect(tm.Annotations).NotTo(HaveKey(machine.ReprovisionAnnotation),
+		"Expected the request to be dropped instead of reprovisioning once provisioned")
+}
+
+func Test_Machine_reconciliation_ignores_reprovisioning_of_control_plane_machine(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Annotations = map[string]string{machine.ReprovisionAnnotation: "true"}
+	tm.Labels = map[string]string{clusterv1.MachineControlPlaneLabel: ""}
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP, testOptions{
+		Labels: map[string]string{
+			machine.HardwareOwnerNameLabel:      tinkerbellMachineName,
+			machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+		},
+	})
+	hw.Annotations = map[string]string{machine.HardwareProvisionedAnnotation: "true"}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+		validWorkflow(tinkerbellMachineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, key, tm)).To(Succeed())
+	g.Expect(tm.Annotations).NotTo(HaveKey(machine.ReprovisionAnnotation), "Expected the request to be dropped")
+	g.Expect(client.Get(ctx, key, &tinkv1.Workflow{})).To(Succeed(), "Expected the Workflow to be kept")
+
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}, hw)).To(Succeed())
+	g.Expect(hw.Annotations).To(HaveKey(machine.HardwareProvisionedAnnotation))
+}
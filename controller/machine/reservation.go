@@ -0,0 +1,72 @@
+package machine
+
+import (
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+const (
+	// HardwareReservedForLabel reserves Hardware for a kind of machines. Hardware labeled with
+	// HardwareReservedForControlPlane is kept for control plane machines, as configured by the
+	// ControlPlaneHardwareReservation of the TinkerbellCluster, so worker rollouts can not starve control plane
+	// replacements.
+	HardwareReservedForLabel = "v1alpha1.tinkerbell.org/reserved-for"
+
+	// HardwareReservedForControlPlane is the HardwareReservedForLabel value reserving Hardware for control plane
+	// machines.
+	HardwareReservedForControlPlane = "control-plane"
+)
+
+// isControlPlane returns true when the machine is a control plane machine of its cluster.
+func (scope *machineReconcileScope) isControlPlane() bool {
+	if _, ok := scope.tinkerbellMachine.Labels[clusterv1.MachineControlPlaneLabel]; ok {
+		return true
+	}
+
+	if scope.machine == nil {
+		return false
+	}
+
+	_, ok := scope.machine.Labels[clusterv1.MachineControlPlaneLabel]
+
+	return ok
+}
+
+// hardwareReservationMode returns how Hardware reserved for control plane machines is kept from worker machines.
+func (scope *machineReconcileScope) hardwareReservationMode() infrastructurev1.HardwareReservationMode {
+	if scope.tinkerbellCluster == nil || scope.tinkerbellCluster.Spec.ControlPlaneHardwareReservation == "" {
+		return infrastructurev1.HardwareReservationStrict
+	}
+
+	return scope.tinkerbellCluster.Spec.ControlPlaneHardwareReservation
+}
+
+// reservedHardware narrows the candidate Hardware down according to the reservation of Hardware for control plane
+// machines: control plane machines select reserved Hardware when there is any, worker machines select unreserved
+// Hardware, falling back to reserved Hardware only with the Soft reservation mode.
+func (scope *machineReconcileScope) reservedHardware(hardware []tinkv1.Hardware) []tinkv1.Hardware {
+	var reserved, unreserved []tinkv1.Hardware
+
+	for i := range hardware {
+		if hardware[i].Labels[HardwareReservedForLabel] == HardwareReservedForControlPlane {
+			reserved = append(reserved, hardware[i])
+		} else {
+			unreserved = append(unreserved, hardware[i])
+		}
+	}
+
+	switch {
+	case scope.isControlPlane():
+		if len(reserved) > 0 {
+			return reserved
+		}
+
+		return unreserved
+	case len(unreserved) > 0 || scope.hardwareReservationMode() == infrastructurev1.HardwareReservationStrict:
+		return unreserved
+	default:
+		return reserved
+	}
+}
@@ -0,0 +1,51 @@
+package machine
+
+import (
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/feature"
+)
+
+const (
+	// HardwareRoleLabel is set by users on Hardware to the role of the machines it is meant for,
+	// HardwareRoleControlPlane or HardwareRoleWorker, as the Tinkerbell playground does. With the HardwareRoles
+	// feature gate, it partitions Hardware by role without hardware affinities.
+	HardwareRoleLabel = "tinkerbell.org/role"
+
+	// HardwareRoleControlPlane is the HardwareRoleLabel value of Hardware meant for control plane machines.
+	HardwareRoleControlPlane = "control-plane"
+
+	// HardwareRoleWorker is the HardwareRoleLabel value of Hardware meant for worker machines.
+	HardwareRoleWorker = "worker"
+)
+
+// roleHardware narrows the candidate Hardware down to the Hardware labeled with the role of the machine when there
+// is any, or else to the Hardware without role, so Hardware labeled for the other role is never selected. Without
+// the HardwareRoles feature gate, the candidates are returned as they are.
+func (scope *machineReconcileScope) roleHardware(hardware []tinkv1.Hardware) []tinkv1.Hardware {
+	if !feature.Enabled(scope.featureGates, feature.HardwareRoles) {
+		return hardware
+	}
+
+	role := HardwareRoleWorker
+	if scope.isControlPlane() {
+		role = HardwareRoleControlPlane
+	}
+
+	var labeled, unlabeled []tinkv1.Hardware
+
+	for i := range hardware {
+		switch hardware[i].Labels[HardwareRoleLabel] {
+		case role:
+			labeled = append(labeled, hardware[i])
+		case "":
+			unlabeled = append(unlabeled, hardware[i])
+		}
+	}
+
+	if len(labeled) > 0 {
+		return labeled
+	}
+
+	return unlabeled
+}
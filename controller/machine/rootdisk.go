@@ -0,0 +1,148 @@
+package machine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+// HardwareDisksAnnotation is hardwareutil.DisksAnnotation.
+const HardwareDisksAnnotation = hardwareutil.DisksAnnotation
+
+var (
+	// ErrNoMatchingRootDisk is the error returned when no disk of Hardware matches the root disk selector.
+	ErrNoMatchingRootDisk = fmt.Errorf("no disk matches the root disk selector")
+
+	// ErrInvalidHardwareDisks is the error returned when the disks annotation of Hardware cannot be parsed.
+	ErrInvalidHardwareDisks = fmt.Errorf("invalid %s annotation", HardwareDisksAnnotation)
+)
+
+// HardwareDisk is a disk listed in the HardwareDisksAnnotation of Hardware.
+type HardwareDisk struct {
+	// Device is the kernel name of the disk, e.g. /dev/sda.
+	Device string `json:"device"`
+
+	// ByID is the /dev/disk/by-id path of the disk. It defaults to the udev path derived from the WWN.
+	ByID string `json:"byID,omitempty"`
+
+	Serial     string `json:"serial,omitempty"`
+	WWN        string `json:"wwn,omitempty"`
+	SizeBytes  int64  `json:"sizeBytes,omitempty"`
+	Rotational *bool  `json:"rotational,omitempty"`
+}
+
+// normalizeWWN returns the given WWN in lower case without the 0x prefix.
+func normalizeWWN(wwn string) string {
+	return strings.TrimPrefix(strings.ToLower(wwn), "0x")
+}
+
+// stablePath returns the /dev/disk/by-id path of the disk, empty when neither ByID nor WWN is known.
+func (d HardwareDisk) stablePath() string {
+	switch {
+	case d.ByID != "":
+		return d.ByID
+	case d.WWN != "":
+		return "/dev/disk/by-id/wwn-0x" + normalizeWWN(d.WWN)
+	default:
+		return ""
+	}
+}
+
+// matches returns true when the disk matches every field set in the selector.
+func (d HardwareDisk) matches(selector *infrastructurev1.RootDiskSelector) bool {
+	if selector.Serial != "" && d.Serial != selector.Serial {
+		return false
+	}
+
+	if selector.WWN != "" && normalizeWWN(d.WWN) != normalizeWWN(selector.WWN) {
+		return false
+	}
+
+	if selector.MinSize != nil && d.SizeBytes < selector.MinSize.Value() {
+		return false
+	}
+
+	if selector.MaxSize != nil && (d.SizeBytes == 0 || d.SizeBytes > selector.MaxSize.Value()) {
+		return false
+	}
+
+	if selector.Rotational != nil && (d.Rotational == nil || *d.Rotational != *selector.Rotational) {
+		return false
+	}
+
+	return true
+}
+
+// hardwareDisks returns the disks listed in the HardwareDisksAnnotation of the Hardware.
+func hardwareDisks(hw *tinkv1.Hardware) ([]HardwareDisk, error) {
+	value, ok := hw.Annotations[HardwareDisksAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	var disks []HardwareDisk
+	if err := json.Unmarshal([]byte(value), &disks); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHardwareDisks, err)
+	}
+
+	return disks, nil
+}
+
+// rootDisk returns the stable path of the first disk of the Hardware matching the selector which has one.
+func rootDisk(hw *tinkv1.Hardware, selector *infrastructurev1.RootDiskSelector) (string, error) {
+	disks, err := hardwareDisks(hw)
+	if err != nil {
+		return "", err
+	}
+
+	for _, disk := range disks {
+		if path := disk.stablePath(); path != "" && disk.matches(selector) {
+			return path, nil
+		}
+	}
+
+	return "", ErrNoMatchingRootDisk
+}
+
+// rootDiskSelector returns the root disk selector of the machine, if any.
+func (scope *machineReconcileScope) rootDiskSelector() *infrastructurev1.RootDiskSelector {
+	if scope.tinkerbellMachine.Spec.Storage == nil {
+		return nil
+	}
+
+	return scope.tinkerbellMachine.Spec.Storage.RootDiskSelector
+}
+
+// rootDiskHardware returns the given Hardware with a disk matching the root disk selector of the machine, all of it
+// when the machine has none. When none matches, the returned error lists why.
+func (scope *machineReconcileScope) rootDiskHardware(hardware []tinkv1.Hardware) ([]tinkv1.Hardware, error) {
+	selector := scope.rootDiskSelector()
+	if selector == nil {
+		return hardware, nil
+	}
+
+	matching := make([]tinkv1.Hardware, 0, len(hardware))
+	notMatching := []error{}
+
+	for i := range hardware {
+		if _, err := rootDisk(&hardware[i], selector); err != nil {
+			notMatching = append(notMatching, fmt.Errorf("Hardware %s: %w", hardware[i].Name, err))
+
+			continue
+		}
+
+		matching = append(matching, hardware[i])
+	}
+
+	if len(matching) == 0 && len(notMatching) > 0 {
+		return nil, errors.Join(notMatching...)
+	}
+
+	return matching, nil
+}
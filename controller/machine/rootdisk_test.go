@@ -0,0 +1,97 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_Machine_reconciliation_installs_to_selected_root_disk(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+	tm.Spec.Storage = &infrastructurev1.Storage{RootDiskSelector: &infrastructurev1.RootDiskSelector{
+		MinSize:    ptr.To(resource.MustParse("400Gi")),
+		Rotational: ptr.To(false),
+	}}
+
+	// The disks of the Hardware with only a spinning disk do not match.
+	hdd := validHardware("hdd", uuid.New().String(), "10.10.0.11")
+	hdd.Annotations = map[string]string{
+		machine.HardwareDisksAnnotation: `[{"device": "/dev/sda", "wwn": "0x5000C500A1B2C3D4",
+			"sizeBytes": 4000787030016, "rotational": true}]`,
+	}
+
+	ssd := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+	ssd.Annotations = map[string]string{
+		machine.HardwareDisksAnnotation: `[
+			{"device": "/dev/sda", "serial": "S4EVNX0N", "sizeBytes": 240057409536, "rotational": false},
+			{"device": "/dev/sdb", "wwn": "0x5002538E4098A1B2", "sizeBytes": 960197124096, "rotational": false}
+		]`,
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hdd,
+		ssd,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	g.Expect(client.Get(ctx, key, tm)).To(Succeed())
+	g.Expect(tm.Spec.HardwareName).To(Equal(hardwareName), "Expected the Hardware with a matching disk")
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(ctx, key, template)).To(Succeed())
+	g.Expect(*template.Spec.Data).To(ContainSubstring("DEST_DISK: /dev/disk/by-id/wwn-0x5002538e4098a1b2\n"))
+	g.Expect(*template.Spec.Data).To(ContainSubstring("/dev/disk/by-id/wwn-0x5002538e4098a1b2-part1"))
+	g.Expect(*template.Spec.Data).NotTo(ContainSubstring("/dev/sd"))
+}
+
+func Test_Machine_reconciliation_without_hardware_matching_root_disk_selector(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+	tm.Spec.Storage = &infrastructurev1.Storage{RootDiskSelector: &infrastructurev1.RootDiskSelector{
+		Serial: "S4EVNX0N",
+	}}
+
+	// The disk has the serial but no stable path, so it cannot be selected.
+	hw := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+	hw.Annotations = map[string]string{
+		machine.HardwareDisksAnnotation: `[{"device": "/dev/sda", "serial": "S4EVNX0N"}]`,
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
+	g.Expect(err).To(MatchError(machine.ErrNoMatchingRootDisk))
+}
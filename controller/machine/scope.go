@@ -20,19 +20,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/component-base/featuregate"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/go-logr/logr"
 	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
 )
@@ -53,8 +58,12 @@ var (
 	ErrMissingClient = fmt.Errorf("client is nil")
 
 	// ErrMissingBootstrapDataSecretValueKey is the error returned when the Secret referenced for bootstrap data
-	// is missing the value key.
-	ErrMissingBootstrapDataSecretValueKey = fmt.Errorf("retrieving bootstrap data: secret value key is missing")
+	// is missing the key holding the bootstrap data.
+	ErrMissingBootstrapDataSecretValueKey = fmt.Errorf("retrieving bootstrap data: secret data key is missing")
+
+	// ErrUnsupportedBootstrapFormat is the error returned when the bootstrap data Secret declares a format CAPT
+	// does not support.
+	ErrUnsupportedBootstrapFormat = fmt.Errorf("unsupported bootstrap data format")
 
 	// ErrBootstrapUserDataEmpty is the error returned when the referenced bootstrap data is empty.
 	ErrBootstrapUserDataEmpty = fmt.Errorf("received bootstrap user data is empty")
@@ -69,6 +78,96 @@ type machineReconcileScope struct {
 	machine              *clusterv1.Machine
 	tinkerbellCluster    *infrastructurev1.TinkerbellCluster
 	bootstrapCloudConfig string
+
+	// bootstrapFormat is the format of bootstrapCloudConfig.
+	bootstrapFormat BootstrapFormat
+
+	// bootstrapSecretUID is the UID of the Secret holding bootstrapCloudConfig.
+	bootstrapSecretUID types.UID
+
+	// bootstrapDataExpiresAt is the time the bootstrap data expires. Zero if unknown.
+	bootstrapDataExpiresAt time.Time
+
+	// requeueAfter is the delay after which the TinkerbellMachine should be reconciled again. Zero means
+	// no time based requeue.
+	requeueAfter time.Duration
+
+	// nodeGate is what is required from the Node of the machine before it is marked as Ready.
+	nodeGate NodeGate
+
+	// workloadClusterClient returns a client for the workload cluster, used to check and drain the Node of the
+	// machine.
+	workloadClusterClient WorkloadClusterClientFunc
+
+	// quarantineThreshold is the number of consecutive provisioning failures after which Hardware is
+	// quarantined. Zero disables quarantining.
+	quarantineThreshold int
+
+	// propagatedLabels and propagatedAnnotations are the keys of the labels and annotations copied from the
+	// TinkerbellMachine onto the Templates, Workflows and BMC Jobs created for it.
+	propagatedLabels      []string
+	propagatedAnnotations []string
+
+	// finalActionGracePeriod is how long the final action of a workflow may run without reporting before it is
+	// assumed to have succeeded. Zero waits for the workflow to succeed.
+	finalActionGracePeriod time.Duration
+
+	// imageChecker checks the OS image of the machine can be downloaded before provisioning starts. Nil
+	// disables the check.
+	imageChecker ImageChecker
+
+	// bmcJobRetries is how often a BMC operation whose Jobs failed through all BMCs of the Hardware is retried.
+	// Zero disables retries.
+	bmcJobRetries int
+
+	// bmcJobRetryBackoff is how long a failed BMC operation waits before its first retry, doubled for every
+	// further one.
+	bmcJobRetryBackoff time.Duration
+
+	// hardwareLeaseDuration is how long claimed Hardware may go without provisioning progress before its lease
+	// expires. Zero disables leases.
+	hardwareLeaseDuration time.Duration
+
+	// workflowTerminationTimeout is how long the teardown of the machine waits for its running workflows to
+	// finish. Zero does not wait.
+	workflowTerminationTimeout time.Duration
+
+	// hookBoot configures the iPXE script booting Hook for machines with kernel arguments.
+	hookBoot HookBootOptions
+
+	// bootstrapReportURL is the base URL of the BootstrapReportServer, empty when bootstrap results are not
+	// reported.
+	bootstrapReportURL string
+
+	// consoleCaptureImage is the image capturing the console of machines, empty when consoles are not captured.
+	consoleCaptureImage string
+
+	// consoleLogReader reads the captured console output of machines.
+	consoleLogReader ConsoleLogReader
+
+	// apiReader reads the Jobs capturing the console of the machine. Nil reads them with the client.
+	apiReader client.Reader
+
+	// releaseNotifier notifies the post-release hook of the machine. Nil uses NewHTTPReleaseNotifier with
+	// NewReleaseHookHTTPClient.
+	releaseNotifier ReleaseNotifier
+
+	// featureGates are the feature gates of the reconciler. Nil uses their default state.
+	featureGates featuregate.FeatureGate
+
+	// hardwarePoolAuthorizer authorizes the use of HardwarePools. Nil uses NewSubjectAccessReviewPoolAuthorizer.
+	hardwarePoolAuthorizer HardwarePoolAuthorizer
+
+	// watchNamespaces are the namespaces the cache is restricted to, empty when all namespaces are watched.
+	watchNamespaces []string
+}
+
+// requeue requests the TinkerbellMachine to be reconciled again after the given delay. When called multiple
+// times, the shortest delay wins.
+func (scope *machineReconcileScope) requeue(after time.Duration) {
+	if scope.requeueAfter == 0 || after < scope.requeueAfter {
+		scope.requeueAfter = after
+	}
 }
 
 func (scope *machineReconcileScope) addFinalizer() error {
@@ -92,14 +191,66 @@ func (scope *machineReconcileScope) ensureTemplateAndWorkflow(hw *tinkv1.Hardwar
 
 	switch {
 	case apierrors.IsNotFound(err):
+		deferred, err := scope.provisioningDeferred()
+		if err != nil {
+			return nil, err
+		}
+
+		if deferred {
+			return nil, &errRequeueRequested{}
+		}
+
+		bmcReady, err := scope.ensureBMCReady(hw)
+		if err != nil {
+			return nil, err
+		}
+
+		if !bmcReady {
+			return nil, &errRequeueRequested{}
+		}
+
+		stagesSucceeded, err := scope.reconcileWorkflowStages(hw)
+		if err != nil {
+			return nil, err
+		}
+
+		if !stagesSucceeded {
+			return nil, &errRequeueRequested{}
+		}
+
+		acquired, err := scope.acquireProvisioningSlot()
+		if err != nil {
+			return nil, err
+		}
+
+		if !acquired {
+			return nil, &errRequeueRequested{}
+		}
+
+		if scope.bootstrapDataExpired() {
+			scope.waitForBootstrapDataRefresh()
+
+			return nil, &errRequeueRequested{}
+		}
+
 		if err := scope.ensureTemplate(hw); err != nil {
 			return nil, fmt.Errorf("failed to ensure template: %w", err)
 		}
 
-		if err := scope.createWorkflow(hw); err != nil {
+		if err := scope.ensureNetbootInterfacesTracked(hw); err != nil {
+			return nil, fmt.Errorf("failed to track netboot interfaces: %w", err)
+		}
+
+		if _, err := scope.ensureNetbootState(hw, !scope.isoBoot()); err != nil {
+			return nil, fmt.Errorf("failed to set netboot state: %w", err)
+		}
+
+		if err := scope.createWorkflow(scope.workflowName(), scope.templateName(), hw); err != nil {
 			return nil, fmt.Errorf("failed to create workflow: %w", err)
 		}
 
+		recordPhase(&scope.phases().WorkflowCreatedAt, time.Now())
+
 		return nil, &errRequeueRequested{}
 	case err != nil:
 		return nil, fmt.Errorf("failed to get workflow: %w", err)
@@ -117,24 +268,77 @@ func (scope *machineReconcileScope) Reconcile() error {
 		}
 	}()
 
+	paused, err := scope.reconcileHardwarePaused()
+	if err != nil {
+		return fmt.Errorf("checking whether Hardware is paused: %w", err)
+	}
+
+	if paused {
+		return nil
+	}
+
 	hw, err := scope.ensureHardware()
 	if err != nil {
+		if errors.Is(err, &errRequeueRequested{}) {
+			return nil
+		}
+
 		return fmt.Errorf("failed to ensure hardware: %w", err)
 	}
 
-	return scope.reconcile(hw)
+	if err := scope.reconcile(hw); err != nil {
+		return err
+	}
+
+	// The spec was acted upon, which the deferred patch records.
+	scope.tinkerbellMachine.Status.ObservedGeneration = scope.tinkerbellMachine.Generation
+
+	return nil
 }
 
 func (scope *machineReconcileScope) reconcile(hw *tinkv1.Hardware) error {
+	provisioned := hw.ObjectMeta.GetAnnotations()[HardwareProvisionedAnnotation] == "true"
+
+	if err := scope.reconcileDeletePriority(hw); err != nil {
+		return fmt.Errorf("failed to reconcile delete priority: %w", err)
+	}
+
+	if scope.reprovisionRequested() {
+		switch {
+		case scope.persistentNetboot():
+			return scope.ignoreReprovision("machines with persistent netboot run no workflow")
+		case !provisioned:
+			return scope.ignoreReprovision("the machine is not provisioned yet")
+		case scope.isControlPlane():
+			return scope.ignoreReprovision("control plane machines must be replaced through their control plane provider")
+		default:
+			return scope.reprovisionDrained(hw)
+		}
+	}
+
+	if scope.persistentNetboot() {
+		return scope.reconcilePersistentNetboot(hw)
+	}
+
 	// If the workflow has completed the TinkerbellMachine is ready.
-	if v, found := hw.ObjectMeta.GetAnnotations()[HardwareProvisionedAnnotation]; found && v == "true" {
-		scope.log.Info("Marking TinkerbellMachine as Ready")
-		scope.tinkerbellMachine.Status.Ready = true
+	if provisioned {
+		if err := scope.markReady(); err != nil {
+			return err
+		}
 
-		return nil
+		if err := scope.reassertNetbootState(hw, false); err != nil {
+			return fmt.Errorf("failed to re-assert netboot state: %w", err)
+		}
+
+		return scope.ejectVirtualMedia(hw)
 	}
 
+	scope.requeueAtBootstrapDataExpiry()
+
+	end := scope.trace("EnsureWorkflow")
 	wf, err := scope.ensureTemplateAndWorkflow(hw)
+	end(err)
+
 	if err != nil {
 		if errors.Is(err, &errRequeueRequested{}) {
 			return nil
@@ -143,22 +347,72 @@ func (scope *machineReconcileScope) reconcile(hw *tinkv1.Hardware) error {
 		return fmt.Errorf("ensure template and workflow returned: %w", err)
 	}
 
+	scope.recordWorkflowPhases(wf)
+
+	if err := scope.renewHardwareLease(hw, wf); err != nil {
+		return fmt.Errorf("failed to renew hardware lease: %w", err)
+	}
+
+	trace.SpanFromContext(scope.ctx).SetAttributes(attribute.String("workflow.state", string(wf.Status.State)))
+
 	if wf.Status.State == tinkv1.WorkflowStateFailed || wf.Status.State == tinkv1.WorkflowStateTimeout {
-		return errWorkflowFailed
+		scope.markWorkflowFailed(wf)
+
+		if err := scope.saveConsoleLog(); err != nil {
+			return fmt.Errorf("failed to save console log: %w", err)
+		}
+
+		if err := scope.recordHardwareFailure(hw, failureKey("Workflow", wf.Name, wf.UID), workflowFailureMessage(wf)); err != nil {
+			return fmt.Errorf("failed to record hardware failure: %w", err)
+		}
+
+		return fmt.Errorf("%w: %s", errWorkflowFailed, workflowFailureMessage(wf))
 	}
 
-	if wf.Status.State != tinkv1.WorkflowStateSuccess {
+	if !scope.workflowSucceeded(wf) {
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.WorkflowSucceededCondition,
+			infrastructurev1.WorkflowRunningReason, clusterv1.ConditionSeverityInfo,
+			"Workflow is in state %s", wf.Status.State)
+
+		if err := scope.saveEndedConsoleCapture(); err != nil {
+			return fmt.Errorf("failed to save console log: %w", err)
+		}
+
+		if err := scope.ensureConsoleCapture(hw); err != nil {
+			return fmt.Errorf("failed to capture console: %w", err)
+		}
+
+		if err := scope.reassertNetbootState(hw, scope.provisioningNetbootState(hw)); err != nil {
+			return fmt.Errorf("failed to re-assert netboot state: %w", err)
+		}
+
 		return nil
 	}
 
-	scope.log.Info("Marking TinkerbellMachine as Ready")
-	scope.tinkerbellMachine.Status.Ready = true
+	conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.WorkflowSucceededCondition)
+	scope.checkNetbootHandshake(hw)
+
+	if err := scope.removeConsoleCapture(); err != nil {
+		return fmt.Errorf("failed to stop console capture: %w", err)
+	}
 
 	if err := scope.patchHardwareAnnotations(hw, map[string]string{HardwareProvisionedAnnotation: "true"}); err != nil {
 		return fmt.Errorf("failed to patch hardware: %w", err)
 	}
 
-	return nil
+	if err := scope.resetHardwareFailures(hw); err != nil {
+		return fmt.Errorf("failed to reset hardware failures: %w", err)
+	}
+
+	if err := scope.markReady(); err != nil {
+		return err
+	}
+
+	if _, err := scope.ensureNetbootState(hw, false); err != nil {
+		return fmt.Errorf("failed to disallow netboot: %w", err)
+	}
+
+	return scope.ejectVirtualMedia(hw)
 }
 
 func (scope *machineReconcileScope) setStatus(hw *tinkv1.Hardware) error {
@@ -167,17 +421,21 @@ func (scope *machineReconcileScope) setStatus(hw *tinkv1.Hardware) error {
 
 		namespacedName := types.NamespacedName{
 			Name:      scope.tinkerbellMachine.Spec.HardwareName,
-			Namespace: scope.tinkerbellMachine.Namespace,
+			Namespace: scope.hardwareNamespace(),
 		}
 
 		if err := scope.client.Get(scope.ctx, namespacedName, hw); err != nil {
 			return fmt.Errorf("getting Hardware: %w", err)
 		}
+
+		if err := ResolveStatusInterfaces(scope.ctx, scope.client, hw); err != nil {
+			return err
+		}
 	}
 
-	ip, err := hardwareIP(hw)
+	ip, err := scope.machineIP(hw)
 	if err != nil {
-		return fmt.Errorf("extracting Hardware IP address: %w", err)
+		return fmt.Errorf("extracting machine IP address: %w", err)
 	}
 
 	scope.tinkerbellMachine.Status.Addresses = []corev1.NodeAddress{
@@ -225,15 +483,45 @@ func (scope *machineReconcileScope) DeleteMachineWithDependencies() error {
 		return scope.removeFinalizer()
 	}
 
+	// Paused Hardware is left as it is, so the machine is only deleted once the Hardware is unpaused.
+	if scope.hardwarePaused(hw) {
+		return scope.patch()
+	}
+
+	// External systems may hold on to the hardware through pre-terminate hooks. Keep it bound and powered
+	// on until all of them are removed.
+	waiting, err := scope.waitForPreTerminateHooks()
+	if err != nil {
+		return fmt.Errorf("checking pre-terminate hooks: %w", err)
+	}
+
+	if waiting {
+		return nil
+	}
+
+	if scope.forceDelete() {
+		return scope.forceDeleteMachine(hw)
+	}
+
+	waiting, err = scope.waitForWorkflowTermination(hw)
+	if err != nil {
+		return fmt.Errorf("checking running workflows: %w", err)
+	}
+
+	if waiting {
+		return nil
+	}
+
 	if err := scope.removeDependencies(hw); err != nil {
 		return err
 	}
 
-	// The hardware BMCRef is nil.
+	// The hardware BMCRef is nil or its power is not managed by CAPT.
 	// Remove finalizers and let machine object delete.
-	if hw.Spec.BMCRef == nil {
-		scope.log.Info("Hardware BMC reference not present; skipping hardware power off",
+	if !scope.managesPower(hw) {
+		scope.log.Info("Hardware power not managed through its BMC; skipping hardware power off",
 			"BMCRef", hw.Spec.BMCRef, "Hardware", hw.Name)
+		scope.requestPowerAction(hw, "power off")
 
 		return scope.removeFinalizer()
 	}
@@ -242,7 +530,8 @@ func (scope *machineReconcileScope) DeleteMachineWithDependencies() error {
 }
 
 // removeDependencies removes the Template, Workflow linked to the machine.
-// Deletes the machine hardware labels for the machine.
+// Deletes the machine hardware labels for the machine, after calling its post-release hook, unless the Hardware
+// was already released or claimed by another machine.
 func (scope *machineReconcileScope) removeDependencies(hardware *tinkv1.Hardware) error {
 	if err := scope.removeTemplate(); err != nil {
 		return fmt.Errorf("removing Template: %w", err)
@@ -252,6 +541,14 @@ func (scope *machineReconcileScope) removeDependencies(hardware *tinkv1.Hardware
 		return fmt.Errorf("removing Workflow: %w", err)
 	}
 
+	if !scope.ownsHardware(hardware) {
+		return nil
+	}
+
+	if err := scope.notifyPostReleaseHook(hardware); err != nil {
+		return err
+	}
+
 	if err := scope.releaseHardware(hardware); err != nil {
 		return fmt.Errorf("releasing Hardware: %w", err)
 	}
@@ -260,6 +557,14 @@ func (scope *machineReconcileScope) removeDependencies(hardware *tinkv1.Hardware
 }
 
 func (scope *machineReconcileScope) removeFinalizer() error {
+	if err := scope.removePooledBMCJobs(); err != nil {
+		return fmt.Errorf("removing BMCJobs: %w", err)
+	}
+
+	if err := scope.removeConsoleCapture(); err != nil {
+		return fmt.Errorf("removing console capture: %w", err)
+	}
+
 	controllerutil.RemoveFinalizer(scope.tinkerbellMachine, infrastructurev1.MachineFinalizer)
 
 	scope.log.Info("Patching Machine object to remove finalizer")
@@ -269,9 +574,16 @@ func (scope *machineReconcileScope) removeFinalizer() error {
 
 // patch commits all done changes to TinkerbellMachine object. If patching fails, error
 // is returned.
-func (scope *machineReconcileScope) patch() error {
+func (scope *machineReconcileScope) patch() (err error) {
+	end := scope.trace("PatchTinkerbellMachine")
+	defer func() { end(err) }()
+
+	scope.setReadyCondition()
+	scope.setPhase()
+
 	// TODO: Improve control on when to patch the object.
-	if err := scope.patchHelper.Patch(scope.ctx, scope.tinkerbellMachine); err != nil {
+	if err := scope.patchHelper.Patch(scope.ctx, scope.tinkerbellMachine,
+		patch.WithOwnedConditions{Conditions: ownedConditions}); err != nil {
 		return fmt.Errorf("patching machine object: %w", err)
 	}
 
@@ -296,7 +608,7 @@ func (scope *machineReconcileScope) getReadyMachine() (*clusterv1.Machine, error
 	}
 
 	if reason != "" {
-		scope.log.Info("machine is not ready yet", "reason", reason)
+		scope.log.V(4).Info("machine is not ready yet", "reason", reason) //nolint:gomnd
 
 		return nil, nil
 	}
@@ -327,36 +639,8 @@ func isMachineReady(machine *clusterv1.Machine) (string, error) {
 	return "", nil
 }
 
-// getReadyBootstrapCloudConfig returns initialized bootstrap cloud config for a given machine.
-//
-// If bootstrap cloud config is not yet initialized, empty string is returned.
-func (scope *machineReconcileScope) getReadyBootstrapCloudConfig(machine *clusterv1.Machine) (string, error) {
-	secret := &corev1.Secret{}
-	key := types.NamespacedName{Namespace: machine.Namespace, Name: *machine.Spec.Bootstrap.DataSecretName}
-
-	if err := scope.client.Get(scope.ctx, key, secret); err != nil {
-		return "", fmt.Errorf("retrieving bootstrap data secret: %w", err)
-	}
-
-	bootstrapUserData, ok := secret.Data["value"]
-	if !ok {
-		return "", ErrMissingBootstrapDataSecretValueKey
-	}
-
-	if len(bootstrapUserData) == 0 {
-		return "", ErrBootstrapUserDataEmpty
-	}
-
-	return string(bootstrapUserData), nil
-}
-
 // getTinkerbellCluster returns associated TinkerbellCluster object for a given machine.
-func (scope *machineReconcileScope) getReadyTinkerbellCluster(machine *clusterv1.Machine) (*infrastructurev1.TinkerbellCluster, error) { //nolint:lll
-	cluster, err := util.GetClusterFromMetadata(scope.ctx, scope.client, machine.ObjectMeta)
-	if err != nil {
-		return nil, fmt.Errorf("getting cluster from metadata: %w", err)
-	}
-
+func (scope *machineReconcileScope) getReadyTinkerbellCluster(cluster *clusterv1.Cluster) (*infrastructurev1.TinkerbellCluster, error) { //nolint:lll
 	tinkerbellCluster := &infrastructurev1.TinkerbellCluster{}
 	tinkerbellClusterNamespacedName := client.ObjectKey{
 		Namespace: scope.tinkerbellMachine.Namespace,
@@ -368,7 +652,7 @@ func (scope *machineReconcileScope) getReadyTinkerbellCluster(machine *clusterv1
 	}
 
 	if !tinkerbellCluster.Status.Ready {
-		scope.log.Info("cluster not ready yet")
+		scope.log.V(4).Info("cluster not ready yet") //nolint:gomnd
 
 		return nil, nil
 	}
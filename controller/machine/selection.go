@@ -0,0 +1,99 @@
+package machine
+
+import (
+	"encoding/json"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// HardwareSelectionAnnotation is set on a TinkerbellMachine when Hardware is selected for it, to a JSON
+	// explanation of the selection: the number of candidates, and the required and preferred terms of the hardware
+	// affinity matched by the top candidates, with their scores. It helps debugging surprising placements caused
+	// by the weights of preferred terms.
+	HardwareSelectionAnnotation = "tinkerbellmachine.infrastructure.cluster.x-k8s.io/hardware-selection"
+
+	// selectionTopCandidates is the number of candidates recorded in the HardwareSelectionAnnotation.
+	selectionTopCandidates = 3
+)
+
+// hardwareScore is how a candidate Hardware scored against the preferred terms and the score expression of the
+// hardware affinity of a machine.
+type hardwareScore struct {
+	// Weight is the weight of the preferred terms the Hardware matched.
+	Weight int64 `json:"weight,omitempty"`
+	// PreferredTerms are the indices of the preferred terms the Hardware matched.
+	PreferredTerms []int `json:"preferredTerms,omitempty"`
+	// ExpressionScore is the score computed by the score expression.
+	ExpressionScore int64 `json:"expressionScore,omitempty"`
+	// Prewarmed is whether the Hardware was pre-warmed with the image of the machine. It breaks ties between
+	// Hardware scoring the same.
+	Prewarmed bool `json:"prewarmed,omitempty"`
+}
+
+func (s *hardwareScore) total() int64 {
+	return s.Weight + s.ExpressionScore
+}
+
+// selectionCandidate is a candidate Hardware as recorded in the HardwareSelectionAnnotation.
+type selectionCandidate struct {
+	Hardware string `json:"hardware"`
+	Score    int64  `json:"score"`
+	// RequiredTerms are the indices of the required terms the Hardware matched.
+	RequiredTerms []int `json:"requiredTerms,omitempty"`
+	hardwareScore
+}
+
+// selectionDecision is the content of the HardwareSelectionAnnotation.
+type selectionDecision struct {
+	Selected   string               `json:"selected"`
+	Candidates int                  `json:"candidates"`
+	Top        []selectionCandidate `json:"top"`
+}
+
+// recordSelection sets the HardwareSelectionAnnotation of the machine for the given candidates, sorted by
+// preference, the first of which was selected.
+func (scope *machineReconcileScope) recordSelection(
+	candidates []tinkv1.Hardware,
+	requiredTerms map[client.ObjectKey][]int,
+	scores map[client.ObjectKey]*hardwareScore,
+) {
+	decision := selectionDecision{Selected: client.ObjectKeyFromObject(&candidates[0]).String()}
+	seen := map[client.ObjectKey]bool{}
+
+	// Hardware matching several required terms is listed once per term.
+	for i := range candidates {
+		key := client.ObjectKeyFromObject(&candidates[i])
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		decision.Candidates++
+
+		if len(decision.Top) < selectionTopCandidates {
+			decision.Top = append(decision.Top, selectionCandidate{
+				Hardware:      key.String(),
+				Score:         scores[key].total(),
+				RequiredTerms: requiredTerms[key],
+				hardwareScore: *scores[key],
+			})
+		}
+	}
+
+	data, err := json.Marshal(decision)
+	if err != nil {
+		scope.log.Error(err, "Failed to record the hardware selection decision")
+
+		return
+	}
+
+	annotations := scope.tinkerbellMachine.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[HardwareSelectionAnnotation] = string(data)
+	scope.tinkerbellMachine.SetAnnotations(annotations)
+}
@@ -0,0 +1,128 @@
+package machine
+
+import (
+	"fmt"
+	"time"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// provisioningSlotRequeueAfter is how long a TinkerbellMachine waiting for a provisioning slot waits before
+// checking again. Workflows of other machines finishing do not trigger a reconciliation of waiting machines.
+const provisioningSlotRequeueAfter = 30 * time.Second
+
+// workflowFinished returns true when the workflow reached a terminal state.
+func workflowFinished(wf *tinkv1.Workflow) bool {
+	switch wf.Status.State {
+	case tinkv1.WorkflowStateSuccess, tinkv1.WorkflowStateFailed, tinkv1.WorkflowStateTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// acquireProvisioningSlot returns true when the TinkerbellMachine may create a workflow under the
+// MaxConcurrentProvisioning limit of its TinkerbellCluster. Otherwise, the machine is requeued and the
+// ProvisioningSlotAcquired condition reports how many machines are provisioning.
+//
+// Slots are counted from the unfinished workflows of the cluster as seen in the cache, so concurrent
+// reconciliations may briefly exceed the limit.
+func (scope *machineReconcileScope) acquireProvisioningSlot() (bool, error) {
+	limit := int(scope.tinkerbellCluster.Spec.MaxConcurrentProvisioning)
+	if limit <= 0 || scope.machine == nil {
+		return true, nil
+	}
+
+	// Workflows of machines selecting from a HardwarePool are in the namespaces of the pool, so they are counted in
+	// all namespaces.
+	opts := []client.ListOption{client.InNamespace(scope.tinkerbellMachine.Namespace)}
+	if scope.tinkerbellMachine.Spec.HardwarePool != "" {
+		opts = []client.ListOption{client.MatchingLabels{HardwareClusterNamespaceLabel: scope.machine.Namespace}}
+	}
+
+	opts = append(opts, client.MatchingLabels{clusterv1.ClusterNameLabel: scope.machine.Spec.ClusterName})
+
+	workflows := &tinkv1.WorkflowList{}
+	if err := scope.client.List(scope.ctx, workflows, opts...); err != nil {
+		return false, fmt.Errorf("listing workflows of the cluster: %w", err)
+	}
+
+	inFlight, err := scope.unlabeledWorkflowsInFlight()
+	if err != nil {
+		return false, err
+	}
+
+	for i := range workflows.Items {
+		if !workflowFinished(&workflows.Items[i]) {
+			inFlight++
+		}
+	}
+
+	if inFlight >= limit {
+		conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.ProvisioningSlotAcquiredCondition,
+			infrastructurev1.WaitingForProvisioningSlotReason, clusterv1.ConditionSeverityInfo,
+			"%d of at most %d machines of the cluster are provisioning", inFlight, limit)
+		scope.requeue(provisioningSlotRequeueAfter)
+
+		return false, nil
+	}
+
+	conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.ProvisioningSlotAcquiredCondition)
+
+	return true, nil
+}
+
+// unlabeledWorkflowsInFlight returns how many unfinished workflows of the cluster were created before workflows
+// were labeled with the name of their cluster. They are in the namespace of their TinkerbellMachine, which owns
+// them.
+func (scope *machineReconcileScope) unlabeledWorkflowsInFlight() (int, error) {
+	unlabeled, err := labels.NewRequirement(clusterv1.ClusterNameLabel, selection.DoesNotExist, nil)
+	if err != nil {
+		return 0, fmt.Errorf("building cluster requirement: %w", err)
+	}
+
+	workflows := &tinkv1.WorkflowList{}
+	if err := scope.client.List(scope.ctx, workflows, client.InNamespace(scope.tinkerbellMachine.Namespace),
+		client.MatchingLabelsSelector{Selector: labels.NewSelector().Add(*unlabeled)}); err != nil {
+		return 0, fmt.Errorf("listing unlabeled workflows: %w", err)
+	}
+
+	if len(workflows.Items) == 0 {
+		return 0, nil
+	}
+
+	machines := &infrastructurev1.TinkerbellMachineList{}
+	if err := scope.client.List(scope.ctx, machines, client.InNamespace(scope.tinkerbellMachine.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: scope.machine.Spec.ClusterName}); err != nil {
+		return 0, fmt.Errorf("listing TinkerbellMachines of the cluster: %w", err)
+	}
+
+	owners := make(map[types.UID]bool, len(machines.Items))
+	for i := range machines.Items {
+		owners[machines.Items[i].UID] = true
+	}
+
+	inFlight := 0
+
+	for i := range workflows.Items {
+		wf := &workflows.Items[i]
+
+		for _, ref := range wf.OwnerReferences {
+			if ref.Kind == "TinkerbellMachine" && owners[ref.UID] && !workflowFinished(wf) {
+				inFlight++
+
+				break
+			}
+		}
+	}
+
+	return inFlight, nil
+}
@@ -0,0 +1,156 @@
+package machine
+
+import (
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// stageName returns the name of the Template and Workflow of the given workflow stage: the name of the Workflow
+// installing the OS suffixed with the stage, shortened and suffixed with a hash when that is too long to be used as
+// a label value.
+func (scope *machineReconcileScope) stageName(stage string) string {
+	return boundedLabelValue(fmt.Sprintf("%s-%s", scope.workflowName(), stage))
+}
+
+// workflowNames returns the names of all Workflows of the TinkerbellMachine, the ones of the workflow
+// stages first.
+func (scope *machineReconcileScope) workflowNames() []string {
+	names := make([]string, 0, len(scope.tinkerbellMachine.Spec.WorkflowStages)+1)
+
+	for _, stage := range scope.tinkerbellMachine.Spec.WorkflowStages {
+		names = append(names, scope.stageName(stage.Name))
+	}
+
+	return append(names, scope.workflowName())
+}
+
+// templateNames returns the names of all Templates of the TinkerbellMachine, the ones of the workflow stages first.
+func (scope *machineReconcileScope) templateNames() []string {
+	names := scope.workflowNames()
+	names[len(names)-1] = scope.templateName()
+
+	return names
+}
+
+// reconcileWorkflowStages runs the workflow stages of the TinkerbellMachine one after the other, creating the
+// Workflow of a stage once the previous one succeeded. It returns true once all stages succeeded, so the workflow
+// installing the OS can be started.
+func (scope *machineReconcileScope) reconcileWorkflowStages(hw *tinkv1.Hardware) (bool, error) {
+	stages := scope.tinkerbellMachine.Spec.WorkflowStages
+	if len(stages) == 0 {
+		return true, nil
+	}
+
+	for i, stage := range stages {
+		name := scope.stageName(stage.Name)
+
+		wf := &tinkv1.Workflow{}
+
+		err := scope.client.Get(scope.ctx, types.NamespacedName{Name: name, Namespace: scope.hardwareNamespace()}, wf)
+
+		switch {
+		case apierrors.IsNotFound(err):
+			acquired, err := scope.acquireProvisioningSlot()
+			if err != nil || !acquired {
+				return false, err
+			}
+
+			if err := scope.startWorkflowStage(name, stage.Template, hw); err != nil {
+				return false, fmt.Errorf("starting workflow stage %s: %w", stage.Name, err)
+			}
+
+			conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.WorkflowStagesSucceededCondition,
+				infrastructurev1.WorkflowStageRunningReason, clusterv1.ConditionSeverityInfo,
+				"Stage %s (%d/%d) started", stage.Name, i+1, len(stages))
+
+			return false, nil
+		case err != nil:
+			return false, fmt.Errorf("getting workflow of stage %s: %w", stage.Name, err)
+		}
+
+		switch wf.Status.State {
+		case tinkv1.WorkflowStateSuccess:
+			continue
+		case tinkv1.WorkflowStateFailed, tinkv1.WorkflowStateTimeout:
+			return false, scope.markWorkflowStageFailed(stage.Name, wf, hw)
+		default:
+			conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.WorkflowStagesSucceededCondition,
+				infrastructurev1.WorkflowStageRunningReason, clusterv1.ConditionSeverityInfo,
+				"Stage %s (%d/%d) workflow is in state %s", stage.Name, i+1, len(stages), wf.Status.State)
+
+			if err := scope.reassertNetbootState(hw, !scope.isoBoot()); err != nil {
+				return false, fmt.Errorf("failed to re-assert netboot state: %w", err)
+			}
+
+			return false, nil
+		}
+	}
+
+	conditions.MarkTrue(scope.tinkerbellMachine, infrastructurev1.WorkflowStagesSucceededCondition)
+
+	return true, nil
+}
+
+// startWorkflowStage creates the Template and Workflow of a workflow stage, allowing the hardware to netboot into
+// it.
+func (scope *machineReconcileScope) startWorkflowStage(name, template string, hw *tinkv1.Hardware) error {
+	exists, err := scope.templateExists(name)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		template, err := applyActionImages(template, scope.actionImages())
+		if err != nil {
+			return fmt.Errorf("applying action images to stage %s: %w", name, err)
+		}
+
+		if err := scope.createTemplateObject(name, template); err != nil {
+			return err
+		}
+	}
+
+	if err := scope.ensureNetbootInterfacesTracked(hw); err != nil {
+		return fmt.Errorf("failed to track netboot interfaces: %w", err)
+	}
+
+	if _, err := scope.ensureNetbootState(hw, !scope.isoBoot()); err != nil {
+		return fmt.Errorf("failed to set netboot state: %w", err)
+	}
+
+	if err := scope.createWorkflow(name, name, hw); err != nil {
+		return err
+	}
+
+	scope.log.Info("Started workflow stage", "Workflow name", name)
+
+	return nil
+}
+
+// markWorkflowStageFailed surfaces a failed stage Workflow through the WorkflowStagesSucceeded condition and a
+// warning event, recorded when the failure is first observed, and counts it as a failure of the hardware.
+func (scope *machineReconcileScope) markWorkflowStageFailed(stage string, wf *tinkv1.Workflow, hw *tinkv1.Hardware) error { //nolint:lll
+	msg := fmt.Sprintf("stage %s: %s", stage, workflowFailureMessage(wf))
+
+	previous := conditions.Get(scope.tinkerbellMachine, infrastructurev1.WorkflowStagesSucceededCondition)
+	if previous == nil || previous.Reason != infrastructurev1.WorkflowStageFailedReason || previous.Message != msg {
+		record.Warn(scope.tinkerbellMachine, infrastructurev1.WorkflowStageFailedReason, msg)
+	}
+
+	conditions.MarkFalse(scope.tinkerbellMachine, infrastructurev1.WorkflowStagesSucceededCondition,
+		infrastructurev1.WorkflowStageFailedReason, clusterv1.ConditionSeverityError, "%s", msg)
+
+	if err := scope.recordHardwareFailure(hw, failureKey("Workflow", wf.Name, wf.UID), msg); err != nil {
+		return fmt.Errorf("failed to record hardware failure: %w", err)
+	}
+
+	return fmt.Errorf("%w: %s", errWorkflowFailed, msg)
+}
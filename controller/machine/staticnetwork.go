@@ -0,0 +1,63 @@
+package machine
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// ErrStaticNetworkInterfaceNotFound is the error returned when the Hardware has no interface with the MAC address
+// selected by the static network configuration.
+var ErrStaticNetworkInterfaceNotFound = fmt.Errorf("hardware has no interface for the static network configuration")
+
+// staticNetworkForHardware returns the static network configuration of the machine with the MAC address of the
+// configured interface of the given hardware, defaulting to its first interface. It returns nil when the machine
+// has no static network configuration.
+func staticNetworkForHardware(
+	staticNetwork *infrastructurev1.StaticNetwork,
+	hw *tinkv1.Hardware,
+) (*infrastructurev1.StaticNetwork, error) {
+	if staticNetwork == nil {
+		return nil, nil
+	}
+
+	resolved := staticNetwork.DeepCopy()
+
+	for i, iface := range hw.Spec.Interfaces {
+		if iface.DHCP == nil || iface.DHCP.MAC == "" {
+			continue
+		}
+
+		if (resolved.MACAddress == "" && i == 0) || strings.EqualFold(iface.DHCP.MAC, resolved.MACAddress) {
+			resolved.MACAddress = iface.DHCP.MAC
+
+			return resolved, nil
+		}
+	}
+
+	if resolved.MACAddress == "" {
+		return nil, fmt.Errorf("%w: first interface has no MAC address", ErrStaticNetworkInterfaceNotFound)
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrStaticNetworkInterfaceNotFound, resolved.MACAddress)
+}
+
+// machineIP returns the address the machine is reachable at once provisioned: the static address when configured,
+// the DHCP address of the primary member of the bond or of the first interface of the hardware otherwise.
+func (scope *machineReconcileScope) machineIP(hw *tinkv1.Hardware) (string, error) {
+	staticNetwork := scope.tinkerbellMachine.Spec.StaticNetwork
+	if staticNetwork == nil {
+		return scope.bondIP(hw)
+	}
+
+	ip, _, err := net.ParseCIDR(staticNetwork.Address)
+	if err != nil {
+		return "", fmt.Errorf("parsing static address: %w", err)
+	}
+
+	return ip.String(), nil
+}
@@ -0,0 +1,41 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_staticNetworkForHardware(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hw := &tinkv1.Hardware{
+		Spec: tinkv1.HardwareSpec{
+			Interfaces: []tinkv1.Interface{
+				{DHCP: &tinkv1.DHCP{MAC: "00:00:5e:00:53:01"}},
+				{DHCP: &tinkv1.DHCP{MAC: "00:00:5e:00:53:02"}},
+			},
+		},
+	}
+
+	resolved, err := staticNetworkForHardware(nil, hw)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolved).To(BeNil())
+
+	resolved, err = staticNetworkForHardware(&infrastructurev1.StaticNetwork{Address: "10.0.0.10/24"}, hw)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolved.MACAddress).To(Equal("00:00:5e:00:53:01"), "Expected the first interface by default")
+
+	resolved, err = staticNetworkForHardware(
+		&infrastructurev1.StaticNetwork{Address: "10.0.0.10/24", MACAddress: "00:00:5E:00:53:02"}, hw)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolved.MACAddress).To(Equal("00:00:5e:00:53:02"))
+
+	_, err = staticNetworkForHardware(
+		&infrastructurev1.StaticNetwork{Address: "10.0.0.10/24", MACAddress: "00:00:5e:00:53:03"}, hw)
+	g.Expect(err).To(MatchError(ErrStaticNetworkInterfaceNotFound))
+}
@@ -5,13 +5,22 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
 	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/imageurl"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/tinktemplate"
 )
 
 var (
@@ -20,9 +29,53 @@ var (
 
 	// ErrMissingImageURL is the error returned when the WorfklowTemplate ImageURL is not specified.
 	ErrMissingImageURL = fmt.Errorf("imageURL can't be empty")
+
+	// ErrActionNotFound is the error returned when an environment override references an action
+	// that is not part of the template.
+	ErrActionNotFound = fmt.Errorf("action not found in template")
+
+	// ErrMalformedTemplate is the error returned when the template does not have the expected structure.
+	ErrMalformedTemplate = fmt.Errorf("malformed template")
+
+	// ErrStaticNetworkMissingMACAddress is the error returned when the static network configuration does not
+	// select an interface.
+	ErrStaticNetworkMissingMACAddress = fmt.Errorf("static network MAC address can't be empty")
+
+	// ErrStaticNetworkUnsupportedBootstrapFormat is the error returned when a static network configuration is
+	// requested for a machine whose bootstrap data is not cloud-config, as it is written as netplan configuration.
+	ErrStaticNetworkUnsupportedBootstrapFormat = fmt.Errorf("static network requires cloud-config bootstrap data")
+
+	// ErrBondMissingInterfaces is the error returned when the bond configuration has no member interfaces.
+	ErrBondMissingInterfaces = fmt.Errorf("bond interfaces can't be empty")
+
+	// ErrBondUnsupportedBootstrapFormat is the error returned when a bond configuration is requested for a machine
+	// whose bootstrap data is not cloud-config, as it is written as netplan configuration.
+	ErrBondUnsupportedBootstrapFormat = fmt.Errorf("bond requires cloud-config bootstrap data")
+
+	// ErrWindowsUnsupportedNetworkConfig is the error returned when a static network or bond configuration is
+	// requested for a Windows image, as it is written as netplan configuration.
+	ErrWindowsUnsupportedNetworkConfig = fmt.Errorf("static network and bond are not supported for windows images")
+
+	// ErrWindowsUnsupportedBootstrapFormat is the error returned when a Windows image is requested for a machine
+	// whose bootstrap data is not cloud-config, the only user-data format of cloudbase-init CAPT supports.
+	ErrWindowsUnsupportedBootstrapFormat = fmt.Errorf("windows images require cloud-config bootstrap data")
+
+	// ErrWIMUnsupported is the error returned when a wim image is requested for a Linux image, or without the
+	// action applying it.
+	ErrWIMUnsupported = fmt.Errorf("wim images require a windows image and an action applying them")
+
+	// ErrInvalidWindowsUsername is the error returned when the user of a Windows image cannot be written to the
+	// cloudbase-init configuration or is not a valid Windows user name.
+	ErrInvalidWindowsUsername = fmt.Errorf("invalid windows username")
 )
 
 const (
+	// streamImageAction writes gzip compressed and raw images to the disk.
+	streamImageAction = "quay.io/tinkerbell/actions/oci2disk"
+
+	// qcow2StreamImageAction converts qcow2 images to a raw disk while writing them.
+	qcow2StreamImageAction = "quay.io/tinkerbell/actions/qemuimg2disk"
+
 	workflowTemplate = `
 version: "0.1"
 name: {{.Name}}
@@ -36,12 +89,17 @@ tasks:
       - /lib/firmware:/lib/firmware:ro
     actions:
       - name: "stream image"
-        image: quay.io/tinkerbell/actions/oci2disk
+        image: {{.StreamImageAction}}
         timeout: 600
         environment:
           IMG_URL: {{.ImageURL}}
           DEST_DISK: {{.DestDisk}}
-          COMPRESSED: true
+{{- if eq .ImageFormat "wim"}}
+          DEST_PARTITION: {{.DestPartition}}
+{{- else if ne .ImageFormat "qcow2"}}
+          COMPRESSED: {{ne .ImageFormat "raw"}}
+{{- end}}
+{{- if .ConfiguresCloudInit}}
       - name: "add tink cloud-init config"
         image: quay.io/tinkerbell/actions/writefile
         timeout: 90
@@ -67,6 +125,10 @@ tasks:
             manage_etc_hosts: localhost
             warnings:
               dsid_missing_source: off
+{{- if or .StaticNetwork .Bond}}
+            network:
+              config: disabled
+{{- end}}
       - name: "add tink cloud-init ds-config"
         image: quay.io/tinkerbell/actions/writefile
         timeout: 90
@@ -80,6 +142,133 @@ tasks:
           DIRMODE: 0700
           CONTENTS: |
             datasource: Ec2
+{{- end}}
+{{- with .Bond}}
+      - name: "add bond network config"
+        image: quay.io/tinkerbell/actions/writefile
+        timeout: 90
+        environment:
+          DEST_DISK: {{$.DestPartition}}
+          FS_TYPE: ext4
+          DEST_PATH: /etc/netplan/60-capt-bond.yaml
+          UID: 0
+          GID: 0
+          MODE: 0600
+          DIRMODE: 0755
+          CONTENTS: |
+            network:
+              version: 2
+              ethernets:
+{{- range $i, $mac := .Interfaces}}
+                capt{{$i}}:
+                  match:
+                    macaddress: "{{$mac}}"
+                  dhcp4: false
+                  dhcp6: false
+{{- end}}
+              bonds:
+                bond0:
+                  interfaces: [{{range $i, $_ := .Interfaces}}{{if $i}}, {{end}}capt{{$i}}{{end}}]
+                  macaddress: "{{index .Interfaces 0}}"
+                  parameters:
+                    mode: "{{.Mode}}"
+                    mii-monitor-interval: 100
+{{- if eq .Mode "active-backup"}}
+                    primary: capt0
+{{- else}}
+                    lacp-rate: fast
+                    transmit-hash-policy: layer3+4
+{{- end}}
+{{- with $.StaticNetwork}}
+                  dhcp4: false
+                  dhcp6: false
+                  addresses: ["{{.Address}}"]
+{{- if .Gateway}}
+                  routes:
+                    - to: default
+                      via: "{{.Gateway}}"
+{{- end}}
+{{- if .Nameservers}}
+                  nameservers:
+                    addresses:
+{{- range .Nameservers}}
+                      - "{{.}}"
+{{- end}}
+{{- end}}
+{{- else}}
+                  dhcp4: true
+{{- end}}
+{{- else}}
+{{- with .StaticNetwork}}
+      - name: "add static network config"
+        image: quay.io/tinkerbell/actions/writefile
+        timeout: 90
+        environment:
+          DEST_DISK: {{$.DestPartition}}
+          FS_TYPE: ext4
+          DEST_PATH: /etc/netplan/60-capt-static.yaml
+          UID: 0
+          GID: 0
+          MODE: 0600
+          DIRMODE: 0755
+          CONTENTS: |
+            network:
+              version: 2
+              ethernets:
+                capt0:
+                  match:
+                    macaddress: "{{.MACAddress}}"
+                  dhcp4: false
+                  dhcp6: false
+                  addresses: ["{{.Address}}"]
+{{- if .Gateway}}
+                  routes:
+                    - to: default
+                      via: "{{.Gateway}}"
+{{- end}}
+{{- if .Nameservers}}
+                  nameservers:
+                    addresses:
+{{- range .Nameservers}}
+                      - "{{.}}"
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if .Windows}}
+      - name: "add cloudbase-init config"
+        image: quay.io/tinkerbell/actions/writefile
+        timeout: 90
+        environment:
+          DEST_DISK: {{.DestPartition}}
+          FS_TYPE: ntfs3
+          DEST_PATH: "/Program Files/Cloudbase Solutions/Cloudbase-Init/conf/cloudbase-init.conf"
+          UID: 0
+          GID: 0
+          MODE: 0644
+          DIRMODE: 0755
+          CONTENTS: |
+            [DEFAULT]
+            username={{.WindowsUsername}}
+            groups=Administrators
+            first_logon_behaviour=no
+            metadata_services=cloudbaseinit.metadata.services.ec2service.EC2Service
+            plugins=cloudbaseinit.plugins.common.mtu.MTUPlugin,cloudbaseinit.plugins.common.sethostname.SetHostNamePlugin,cloudbaseinit.plugins.windows.extendvolumes.ExtendVolumesPlugin,cloudbaseinit.plugins.common.sshpublickeys.SetUserSSHPublicKeysPlugin,cloudbaseinit.plugins.common.userdata.UserDataPlugin
+            allow_reboot=true
+            [ec2]
+            metadata_base_url={{.MetadataBaseURL}}
+            add_metadata_private_ip_route=false
+      - name: "reboot"
+        image: ghcr.io/jacobweinstock/waitdaemon:0.2.1
+        timeout: 90
+        pid: host
+        command: ["reboot"]
+        environment:
+          IMAGE: alpine
+          WAIT_SECONDS: 10
+        volumes:
+          - /var/run/docker.sock:/var/run/docker.sock
+{{- else}}
       - name: "kexec image"
         image: ghcr.io/jacobweinstock/waitdaemon:0.2.1
         timeout: 90
@@ -91,6 +280,7 @@ tasks:
           WAIT_SECONDS: 5
         volumes:
           - /var/run/docker.sock:/var/run/docker.sock
+{{- end}}
 `
 )
 
@@ -102,6 +292,93 @@ type WorkflowTemplate struct {
 	DestDisk           string
 	DestPartition      string
 	DeviceTemplateName string
+
+	// ImageFormat is the format of the image at ImageURL. Defaults to gzip.
+	ImageFormat infrastructurev1.ImageFormat
+
+	// ActionEnvironment sets or overrides environment variables of the rendered actions, keyed by action name.
+	ActionEnvironment map[string]map[string]string
+
+	// StaticNetwork, when set, is written as netplan configuration of the interface with its MACAddress, which
+	// must be set unless Bond is set, and disables the network configuration of cloud-init.
+	StaticNetwork *infrastructurev1.StaticNetwork
+
+	// Bond, when set, is written as netplan configuration bonding the interfaces with its MAC addresses, the first
+	// one being the primary member, and disables the network configuration of cloud-init. StaticNetwork is then
+	// applied to the bond.
+	Bond *infrastructurev1.Bond
+
+	// BootstrapFormat is the format of the bootstrap data of the machine. The cloud-init configuration is only
+	// written for cloud-config bootstrap data. Defaults to cloud-config.
+	BootstrapFormat BootstrapFormat
+
+	// OSFamily is the family of the OS of the image. Windows images are configured with cloudbase-init instead of
+	// cloud-init, and rebooted into instead of kexec'd. Defaults to linux.
+	OSFamily infrastructurev1.OSFamily
+
+	// WindowsUsername is the user cloudbase-init creates on Windows images. Defaults to Admin.
+	WindowsUsername string
+
+	// WIMApplyAction is the image of the action applying wim images, required for them.
+	WIMApplyAction string
+
+	// Timeouts, when set, override the global timeout of the workflow and the timeouts of the rendered actions.
+	Timeouts *infrastructurev1.WorkflowTimeouts
+
+	// Files are written to DestPartition before booting into the OS, each by an action named after its path.
+	Files []WorkflowFile
+
+	// Proxy, when set, is set in the environment of all actions, and containerd and docker of Linux images are
+	// configured to use it. ActionEnvironment takes precedence.
+	Proxy *infrastructurev1.Proxy
+
+	// BootstrapReportURL, when set, is where images configured with cloud-init report the result of the bootstrap
+	// once cloud-init finished, see bootstrapReportFiles.
+	BootstrapReportURL string
+
+	// NetbootHandshakeURL, when set, is called by an action before the final action, so CAPT disallows netboot
+	// before the machine boots into the OS, see applyNetbootHandshake.
+	NetbootHandshakeURL string
+
+	// DataDisks are partitioned, formatted and mounted by cloud-init on first boot, see dataDiskFiles.
+	DataDisks []WorkflowDataDisk
+
+	// ActionImages, when set, configures the registry the action images are pulled from and how, see
+	// applyActionImages.
+	ActionImages *infrastructurev1.ActionImages
+
+	// ImageVerification, when set, verifies the signature of the image before streaming it, see
+	// applyImageVerification.
+	ImageVerification *infrastructurev1.ImageVerification
+}
+
+// Windows returns whether the image is a Windows image.
+func (wt *WorkflowTemplate) Windows() bool {
+	return wt.OSFamily == infrastructurev1.OSFamilyWindows
+}
+
+// ConfiguresCloudInit returns whether the image is configured to fetch its cloud-init user-data from the metadata
+// service. Images booting with Ignition or Talos configuration fetch it themselves, and Windows images use
+// cloudbase-init instead.
+func (wt *WorkflowTemplate) ConfiguresCloudInit() bool {
+	return !wt.Windows() && (wt.BootstrapFormat == "" || wt.BootstrapFormat == BootstrapFormatCloudConfig)
+}
+
+// MetadataBaseURL returns MetadataURL with a trailing slash, as cloudbase-init appends the metadata paths to it.
+func (wt *WorkflowTemplate) MetadataBaseURL() string {
+	return strings.TrimSuffix(wt.MetadataURL, "/") + "/"
+}
+
+// StreamImageAction returns the action image streaming the OS image to the disk.
+func (wt *WorkflowTemplate) StreamImageAction() string {
+	switch wt.ImageFormat {
+	case infrastructurev1.ImageFormatQCOW2:
+		return qcow2StreamImageAction
+	case infrastructurev1.ImageFormatWIM:
+		return wt.WIMApplyAction
+	default:
+		return streamImageAction
+	}
 }
 
 // Render renders workflow template for a given machine including user-data.
@@ -114,10 +391,54 @@ func (wt *WorkflowTemplate) Render() (string, error) {
 		return "", ErrMissingImageURL
 	}
 
+	if wt.StaticNetwork != nil && wt.Bond == nil && wt.StaticNetwork.MACAddress == "" {
+		return "", ErrStaticNetworkMissingMACAddress
+	}
+
+	if wt.Bond != nil && len(wt.Bond.Interfaces) == 0 {
+		return "", ErrBondMissingInterfaces
+	}
+
+	if wt.Windows() && (wt.StaticNetwork != nil || wt.Bond != nil) {
+		return "", ErrWindowsUnsupportedNetworkConfig
+	}
+
+	if wt.Windows() && wt.BootstrapFormat != "" && wt.BootstrapFormat != BootstrapFormatCloudConfig {
+		return "", fmt.Errorf("%w: %s", ErrWindowsUnsupportedBootstrapFormat, wt.BootstrapFormat)
+	}
+
+	if wt.ImageFormat == infrastructurev1.ImageFormatWIM && (!wt.Windows() || wt.WIMApplyAction == "") {
+		return "", ErrWIMUnsupported
+	}
+
+	if wt.Windows() && wt.WindowsUsername == "" {
+		wt.WindowsUsername = defaultWindowsUsername
+	}
+
+	if wt.Windows() && !validWindowsUsername(wt.WindowsUsername) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidWindowsUsername, wt.WindowsUsername)
+	}
+
+	if wt.Bond != nil && !wt.ConfiguresCloudInit() {
+		return "", fmt.Errorf("%w: %s", ErrBondUnsupportedBootstrapFormat, wt.BootstrapFormat)
+	}
+
+	if wt.StaticNetwork != nil && !wt.ConfiguresCloudInit() {
+		return "", fmt.Errorf("%w: %s", ErrStaticNetworkUnsupportedBootstrapFormat, wt.BootstrapFormat)
+	}
+
+	if len(wt.DataDisks) > 0 && !wt.ConfiguresCloudInit() {
+		return "", fmt.Errorf("%w: %s", ErrDataDisksUnsupportedBootstrapFormat, wt.BootstrapFormat)
+	}
+
 	if wt.DeviceTemplateName == "" {
 		wt.DeviceTemplateName = "{{.device_1}}"
 	}
 
+	if wt.ImageFormat == "" {
+		wt.ImageFormat = infrastructurev1.ImageFormatGzip
+	}
+
 	tpl, err := template.New("template").Parse(workflowTemplate)
 	if err != nil {
 		return "", fmt.Errorf("unable to parse template: %w", err)
@@ -130,13 +451,234 @@ func (wt *WorkflowTemplate) Render() (string, error) {
 		return "", fmt.Errorf("unable to execute template: %w", err)
 	}
 
-	return buf.String(), nil
+	fsType := "ext4"
+	if wt.Windows() {
+		fsType = "ntfs3"
+	}
+
+	files := wt.Files
+
+	// Images configured by Ignition or Talos own their systemd units, so the proxy drop-ins are only written to
+	// images configured by cloud-init.
+	if wt.ConfiguresCloudInit() {
+		files = append(proxyFiles(wt.Proxy), files...)
+		dataDiskFiles, err := dataDiskFiles(wt.DataDisks)
+		if err != nil {
+			return "", err
+		}
+
+		files = append(files, dataDiskFiles...)
+		files = append(files, bootstrapReportFiles(wt.BootstrapReportURL)...)
+	}
+
+	data, err := applyFiles(buf.String(), files, wt.DestPartition, fsType)
+	if err != nil {
+		return "", err
+	}
+
+	data, err = applyNetbootHandshake(data, wt.NetbootHandshakeURL, wt.ActionImages)
+	if err != nil {
+		return "", err
+	}
+
+	data, err = applyImageVerification(data, wt.ImageVerification)
+	if err != nil {
+		return "", err
+	}
+
+	data, err = applyActionImages(data, wt.ActionImages)
+	if err != nil {
+		return "", err
+	}
+
+	data, err = applyProxyEnvironment(data, wt.Proxy)
+	if err != nil {
+		return "", err
+	}
+
+	data, err = applyActionEnvironment(data, wt.ActionEnvironment)
+	if err != nil {
+		return "", err
+	}
+
+	return applyWorkflowTimeouts(data, wt.Timeouts)
+}
+
+// applyActionEnvironment sets the given environment variables on the named actions of the Tinkerbell template data.
+// Everything else in the template, including formatting of scalar values, is left untouched.
+func applyActionEnvironment(data string, env map[string]map[string]string) (string, error) {
+	if len(env) == 0 {
+		return data, nil
+	}
+
+	doc, tasks, err := parseTemplate(data)
+	if err != nil {
+		return "", err
+	}
+
+	found := map[string]bool{}
+
+	forEachAction(tasks, func(name string, action *yaml.Node) {
+		overrides, ok := env[name]
+		if !ok {
+			return
+		}
+
+		found[name] = true
+
+		setMappingValues(action, "environment", overrides)
+	})
+
+	for action := range env {
+		if !found[action] {
+			return "", fmt.Errorf("%w: %q", ErrActionNotFound, action)
+		}
+	}
+
+	return encodeTemplate(doc)
+}
+
+// applyWorkflowTimeouts sets the given global and action timeouts on the Tinkerbell template data. Timeouts of
+// actions the template does not have are ignored.
+func applyWorkflowTimeouts(data string, timeouts *infrastructurev1.WorkflowTimeouts) (string, error) {
+	if timeouts == nil || (timeouts.Global == 0 && len(timeouts.Actions) == 0) {
+		return data, nil
+	}
+
+	doc, tasks, err := parseTemplate(data)
+	if err != nil {
+		return "", err
+	}
+
+	if timeouts.Global > 0 {
+		setMappingScalar(doc.Content[0], "global_timeout", strconv.FormatInt(timeouts.Global, 10))
+	}
+
+	forEachAction(tasks, func(name string, action *yaml.Node) {
+		if timeout, ok := timeouts.Actions[name]; ok {
+			setMappingScalar(action, "timeout", strconv.FormatInt(timeout, 10))
+		}
+	})
+
+	return encodeTemplate(doc)
+}
+
+// parseTemplate parses Tinkerbell template data, returning the document and its tasks.
+func parseTemplate(data string) (*yaml.Node, *yaml.Node, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(data), doc); err != nil {
+		return nil, nil, fmt.Errorf("parsing template: %w", err)
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, nil, ErrMalformedTemplate
+	}
+
+	tasks := mappingValue(doc.Content[0], "tasks")
+	if tasks == nil || tasks.Kind != yaml.SequenceNode {
+		return nil, nil, fmt.Errorf("%w: tasks must be a list", ErrMalformedTemplate)
+	}
+
+	return doc, tasks, nil
+}
+
+// forEachAction calls f with the name and node of every named action of the given tasks.
+func forEachAction(tasks *yaml.Node, f func(name string, action *yaml.Node)) {
+	for _, task := range tasks.Content {
+		actions := mappingValue(task, "actions")
+		if actions == nil || actions.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for _, action := range actions.Content {
+			if name := mappingValue(action, "name"); name != nil {
+				f(name.Value, action)
+			}
+		}
+	}
+}
+
+// encodeTemplate serializes a parsed Tinkerbell template.
+func encodeTemplate(doc *yaml.Node) (string, error) {
+	out := &bytes.Buffer{}
+
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2) //nolint:gomnd
+
+	if err := enc.Encode(doc); err != nil {
+		return "", fmt.Errorf("serializing template: %w", err)
+	}
+
+	return out.String(), nil
+}
+
+// mappingValue returns the value node for the given key of a YAML mapping node, or nil if it does not exist.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// setMappingScalar sets the integer value of the given key of a YAML mapping node, adding the key if needed.
+func setMappingScalar(node *yaml.Node, key, value string) {
+	scalar := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value}
+
+	if existing := mappingValue(node, key); existing != nil {
+		*existing = *scalar
+
+		return
+	}
+
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, scalar)
+}
+
+// setMappingValues sets the given string values on the mapping stored under key, creating the mapping if needed.
+func setMappingValues(node *yaml.Node, key string, values map[string]string) {
+	target := mappingValue(node, key)
+	if target == nil || target.Kind != yaml.MappingNode {
+		target = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			target,
+		)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: values[name]}
+
+		if existing := mappingValue(target, name); existing != nil {
+			*existing = *value
+
+			continue
+		}
+
+		target.Content = append(target.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+			value,
+		)
+	}
 }
 
-func (scope *machineReconcileScope) templateExists() (bool, error) {
+func (scope *machineReconcileScope) templateExists(name string) (bool, error) {
 	namespacedName := types.NamespacedName{
-		Name:      scope.tinkerbellMachine.Name,
-		Namespace: scope.tinkerbellMachine.Namespace,
+		Name:      name,
+		Namespace: scope.hardwareNamespace(),
 	}
 
 	err := scope.client.Get(scope.ctx, namespacedName, &tinkv1.Template{})
@@ -151,60 +693,231 @@ func (scope *machineReconcileScope) templateExists() (bool, error) {
 	return false, nil
 }
 
+// metadataURL returns the URL of the Tinkerbell metadata service the machine fetches its metadata from: the one of
+// the machine, of its cluster, or port 50061 of TINKERBELL_IP.
+func (scope *machineReconcileScope) metadataURL() string {
+	if u := scope.tinkerbellMachine.Spec.MetadataURL; u != "" {
+		return u
+	}
+
+	if scope.tinkerbellCluster != nil && scope.tinkerbellCluster.Spec.MetadataURL != "" {
+		return scope.tinkerbellCluster.Spec.MetadataURL
+	}
+
+	return fmt.Sprintf("http://%s:50061", tinkerbellIP())
+}
+
+// tinkerbellIP returns the TINKERBELL_IP the controller is configured with, the IP of the Tinkerbell stack.
+func tinkerbellIP() string {
+	if ip := os.Getenv("TINKERBELL_IP"); ip != "" {
+		return ip
+	}
+
+	return "192.168.1.1"
+}
+
+// workflowTimeouts returns the workflow timeouts of the machine, falling back to the ones of its cluster for the
+// global timeout and the timeouts of actions the machine sets none for.
+func (scope *machineReconcileScope) workflowTimeouts() *infrastructurev1.WorkflowTimeouts {
+	machine := scope.tinkerbellMachine.Spec.WorkflowTimeouts
+
+	if scope.tinkerbellCluster == nil || scope.tinkerbellCluster.Spec.WorkflowTimeouts == nil {
+		return machine
+	}
+
+	timeouts := scope.tinkerbellCluster.Spec.WorkflowTimeouts.DeepCopy()
+	if machine == nil {
+		return timeouts
+	}
+
+	if machine.Global > 0 {
+		timeouts.Global = machine.Global
+	}
+
+	for action, timeout := range machine.Actions {
+		if timeouts.Actions == nil {
+			timeouts.Actions = map[string]int64{}
+		}
+
+		timeouts.Actions[action] = timeout
+	}
+
+	return timeouts
+}
+
 func (scope *machineReconcileScope) createTemplate(hw *tinkv1.Hardware) error {
 	if len(hw.Spec.Disks) < 1 {
 		return ErrHardwareMissingDiskConfiguration
 	}
 
 	templateData := scope.tinkerbellMachine.Spec.TemplateOverride
+	if name := scope.tinkerbellMachine.Spec.TemplateRefName; name != "" {
+		var err error
+
+		templateData, err = scope.libraryTemplate(name, hw)
+		if err != nil {
+			return err
+		}
+	}
+
 	if templateData == "" {
-		targetDisk := hw.Spec.Disks[0].Device
-		targetDevice := firstPartitionFromDevice(targetDisk)
+		targetDisk, err := scope.targetDisk(hw)
+		if err != nil {
+			return err
+		}
+
+		targetDevice := partitionFromDevice(targetDisk, scope.osPartition())
 
-		imageURL, err := scope.imageURL()
+		imageURL, err := scope.imageURL(hw)
 		if err != nil {
 			return fmt.Errorf("failed to generate imageURL: %w", err)
 		}
 
-		metadataIP := os.Getenv("TINKERBELL_IP")
-		if metadataIP == "" {
-			metadataIP = "192.168.1.1"
+		bond, err := bondForHardware(scope.tinkerbellMachine.Spec.Bond, hw)
+		if err != nil {
+			return err
+		}
+
+		// The static network configuration of a bond applies to the bond instead of one of its interfaces.
+		staticNetwork := scope.tinkerbellMachine.Spec.StaticNetwork
+		if bond == nil {
+			staticNetwork, err = staticNetworkForHardware(staticNetwork, hw)
+			if err != nil {
+				return err
+			}
+		}
+
+		files, err := scope.workflowFiles()
+		if err != nil {
+			return err
 		}
 
-		metadataURL := fmt.Sprintf("http://%s:50061", metadataIP)
+		dataDisks, err := scope.hardwareDataDisks(hw)
+		if err != nil {
+			return fmt.Errorf("resolving data disks of Hardware %s: %w", hw.Name, err)
+		}
+
+		windowsUsername, err := scope.windowsUsername(hw)
+		if err != nil {
+			return err
+		}
+
+		bootstrapReportURL, err := scope.bootstrapReportEndpoint()
+		if err != nil {
+			return fmt.Errorf("getting bootstrap report URL: %w", err)
+		}
+
+		netbootHandshakeURL, err := scope.netbootHandshakeEndpoint()
+		if err != nil {
+			return fmt.Errorf("getting netboot handshake URL: %w", err)
+		}
 
 		workflowTemplate := WorkflowTemplate{
-			Name:          scope.tinkerbellMachine.Name,
-			MetadataURL:   metadataURL,
-			ImageURL:      imageURL,
-			DestDisk:      targetDisk,
-			DestPartition: targetDevice,
+			Name:                scope.templateName(),
+			DeviceTemplateName:  fmt.Sprintf("{{.%s}}", scope.workerDeviceKey()),
+			MetadataURL:         scope.metadataURL(),
+			ImageURL:            imageURL,
+			DestDisk:            targetDisk,
+			DestPartition:       targetDevice,
+			ImageFormat:         scope.tinkerbellMachine.Spec.Image.Format,
+			ActionEnvironment:   scope.tinkerbellMachine.Spec.ActionEnvironment,
+			StaticNetwork:       staticNetwork,
+			Bond:                bond,
+			BootstrapFormat:     scope.bootstrapFormat,
+			OSFamily:            scope.tinkerbellMachine.Spec.Image.OSFamily,
+			WindowsUsername:     windowsUsername,
+			WIMApplyAction:      scope.tinkerbellMachine.Spec.Image.WIMApplyAction,
+			Timeouts:            scope.workflowTimeouts(),
+			Files:               files,
+			Proxy:               scope.proxy(),
+			BootstrapReportURL:  bootstrapReportURL,
+			NetbootHandshakeURL: netbootHandshakeURL,
+			DataDisks:           dataDisks,
+			ActionImages:        scope.actionImages(),
+			ImageVerification:   scope.imageVerification(),
 		}
 
 		templateData, err = workflowTemplate.Render()
 		if err != nil {
 			return fmt.Errorf("rendering template: %w", err)
 		}
+	} else {
+		// The webhook validates the override with placeholder Hardware, so validate it again with the claimed
+		// Hardware instead of leaving the workflow controller to fail rendering it.
+		if err := tinktemplate.Validate(templateData, hw); err != nil {
+			return fmt.Errorf("%w: template override: %w", ErrMalformedTemplate, err)
+		}
+
+		netbootHandshakeURL, err := scope.netbootHandshakeEndpoint()
+		if err != nil {
+			return fmt.Errorf("getting netboot handshake URL: %w", err)
+		}
+
+		templateData, err = applyNetbootHandshake(templateData, netbootHandshakeURL, scope.actionImages())
+		if err != nil {
+			return fmt.Errorf("applying netboot handshake to template override: %w", err)
+		}
+
+		templateData, err = applyImageVerification(templateData, scope.imageVerification())
+		if err != nil {
+			return fmt.Errorf("applying image verification to template override: %w", err)
+		}
+
+		templateData, err = applyActionImages(templateData, scope.actionImages())
+		if err != nil {
+			return fmt.Errorf("applying action images to template override: %w", err)
+		}
+
+		templateData, err = applyProxyEnvironment(templateData, scope.proxy())
+		if err != nil {
+			return fmt.Errorf("applying proxy environment to template override: %w", err)
+		}
+
+		templateData, err = applyActionEnvironment(templateData, scope.tinkerbellMachine.Spec.ActionEnvironment)
+		if err != nil {
+			return fmt.Errorf("applying action environment to template override: %w", err)
+		}
+
+		templateData, err = applyWorkflowTimeouts(templateData, scope.workflowTimeouts())
+		if err != nil {
+			return fmt.Errorf("applying workflow timeouts to template override: %w", err)
+		}
+	}
+
+	return scope.createTemplateObject(scope.templateName(), templateData)
+}
+
+// targetDisk returns the disk of the Hardware the OS is installed to: the disk matching the root disk selector of
+// the machine, or the first disk of the Hardware.
+func (scope *machineReconcileScope) targetDisk(hw *tinkv1.Hardware) (string, error) {
+	selector := scope.rootDiskSelector()
+	if selector == nil {
+		return hw.Spec.Disks[0].Device, nil
+	}
+
+	disk, err := rootDisk(hw, selector)
+	if err != nil {
+		return "", fmt.Errorf("resolving root disk of Hardware %s: %w", hw.Name, err)
 	}
 
+	return disk, nil
+}
+
+// createTemplateObject creates the Template with the given name and data, owned by the TinkerbellMachine.
+func (scope *machineReconcileScope) createTemplateObject(name, templateData string) error {
 	templateObject := &tinkv1.Template{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      scope.tinkerbellMachine.Name,
-			Namespace: scope.tinkerbellMachine.Namespace,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
-					Kind:       "TinkerbellMachine",
-					Name:       scope.tinkerbellMachine.Name,
-					UID:        scope.tinkerbellMachine.ObjectMeta.UID,
-				},
-			},
+			Name:      name,
+			Namespace: scope.hardwareNamespace(),
 		},
 		Spec: tinkv1.TemplateSpec{
 			Data: &templateData,
 		},
 	}
 
+	scope.setOwner(templateObject, false)
+	scope.propagateMetadata(templateObject)
+
 	if err := scope.client.Create(scope.ctx, templateObject); err != nil {
 		return fmt.Errorf("creating Tinkerbell template: %w", err)
 	}
@@ -212,21 +925,44 @@ func (scope *machineReconcileScope) createTemplate(hw *tinkv1.Hardware) error {
 	return nil
 }
 
-func firstPartitionFromDevice(device string) string {
+// windowsOSPartition is the partition of Windows images holding the OS, following the EFI system and Microsoft
+// reserved partitions.
+const windowsOSPartition = 3
+
+// osPartition returns the number of the partition of the image holding the OS, to which its configuration is
+// written.
+func (scope *machineReconcileScope) osPartition() int32 {
+	image := scope.tinkerbellMachine.Spec.Image
+
+	switch {
+	case image.OSPartition > 0:
+		return image.OSPartition
+	case image.Windows():
+		return windowsOSPartition
+	default:
+		return 1
+	}
+}
+
+// partitionFromDevice returns the device of the given partition of a disk device, which may be a udev symlink
+// such as a /dev/disk/by-id path.
+func partitionFromDevice(device string, partition int32) string {
 	nvmeDevice := regexp.MustCompile(`^/dev/nvme\d+n\d+$`)
 	emmcDevice := regexp.MustCompile(`^/dev/mmcblk\d+$`)
 
 	switch {
+	case strings.HasPrefix(device, "/dev/disk/"):
+		return fmt.Sprintf("%s-part%d", device, partition)
 	case nvmeDevice.MatchString(device), emmcDevice.MatchString(device):
-		return fmt.Sprintf("%sp1", device)
+		return fmt.Sprintf("%sp%d", device, partition)
 	default:
-		return fmt.Sprintf("%s1", device)
+		return fmt.Sprintf("%s%d", device, partition)
 	}
 }
 
 func (scope *machineReconcileScope) ensureTemplate(hardware *tinkv1.Hardware) error {
 	// TODO: should this reconccile the template instead of just ensuring it exists?
-	templateExists, err := scope.templateExists()
+	templateExists, err := scope.templateExists(scope.templateName())
 	if err != nil {
 		return fmt.Errorf("checking if Template exists: %w", err)
 	}
@@ -240,11 +976,22 @@ func (scope *machineReconcileScope) ensureTemplate(hardware *tinkv1.Hardware) er
 	return scope.createTemplate(hardware)
 }
 
-// removeTemplate makes sure template for TinkerbellMachine has been cleaned up.
+// removeTemplate makes sure templates for TinkerbellMachine, including the ones of workflow stages, have been
+// cleaned up.
 func (scope *machineReconcileScope) removeTemplate() error {
+	for _, name := range scope.templateNames() {
+		if err := scope.removeTemplateNamed(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (scope *machineReconcileScope) removeTemplateNamed(name string) error {
 	namespacedName := types.NamespacedName{
-		Name:      scope.tinkerbellMachine.Name,
-		Namespace: scope.tinkerbellMachine.Namespace,
+		Name:      name,
+		Namespace: scope.hardwareNamespace(),
 	}
 
 	template := &tinkv1.Template{}
@@ -269,12 +1016,17 @@ func (scope *machineReconcileScope) removeTemplate() error {
 	return nil
 }
 
-func (scope *machineReconcileScope) imageURL() (string, error) {
+func (scope *machineReconcileScope) imageURL(hw *tinkv1.Hardware) (string, error) {
 	imageLookupFormat := scope.tinkerbellMachine.Spec.ImageLookupFormat
 	if imageLookupFormat == "" {
 		imageLookupFormat = scope.tinkerbellCluster.Spec.ImageLookupFormat
 	}
 
+	if imageLookupFormat == infrastructurev1.DefaultImageLookupFormat {
+		imageLookupFormat = strings.TrimSuffix(imageLookupFormat, infrastructurev1.ImageFormatGzip.Suffix()) +
+			scope.tinkerbellMachine.Spec.Image.Format.Suffix()
+	}
+
 	imageLookupBaseRegistry := scope.tinkerbellMachine.Spec.ImageLookupBaseRegistry
 	if imageLookupBaseRegistry == "" {
 		imageLookupBaseRegistry = scope.tinkerbellCluster.Spec.ImageLookupBaseRegistry
@@ -290,40 +1042,52 @@ func (scope *machineReconcileScope) imageURL() (string, error) {
 		imageLookupOSVersion = scope.tinkerbellCluster.Spec.ImageLookupOSVersion
 	}
 
-	return imageURL(
-		imageLookupFormat,
+	catalog, err := scope.imageCatalog()
+	if err != nil {
+		return "", err
+	}
+
+	params := imageurl.NewParams(
 		imageLookupBaseRegistry,
 		imageLookupOSDistro,
 		imageLookupOSVersion,
 		*scope.machine.Spec.Version,
+		hardwareArch(hw),
 	)
+
+	return imageurl.Render(imageLookupFormat, params, catalog)
 }
 
-func imageURL(imageFormat, baseRegistry, osDistro, osVersion, kubernetesVersion string) (string, error) {
-	type image struct {
-		BaseRegistry      string
-		OSDistro          string
-		OSVersion         string
-		KubernetesVersion string
+// imageCatalog returns the images described by the image catalog of the cluster, nil when it has none.
+func (scope *machineReconcileScope) imageCatalog() (imageurl.Catalog, error) {
+	ref := scope.tinkerbellCluster.Spec.ImageCatalogRef
+	if ref == nil {
+		return nil, nil //nolint:nilnil
 	}
 
-	imageParams := image{
-		BaseRegistry:      baseRegistry,
-		OSDistro:          strings.ToLower(osDistro),
-		OSVersion:         strings.ReplaceAll(osVersion, ".", ""),
-		KubernetesVersion: kubernetesVersion,
-	}
+	cm := &corev1.ConfigMap{}
 
-	var buf bytes.Buffer
+	key := client.ObjectKey{Namespace: scope.tinkerbellCluster.Namespace, Name: ref.Name}
+	if err := scope.client.Get(scope.ctx, key, cm); err != nil {
+		return nil, fmt.Errorf("getting image catalog %s: %w", key, err)
+	}
 
-	template, err := template.New("image").Parse(imageFormat)
+	catalog, err := imageurl.ParseCatalog(cm.Data)
 	if err != nil {
-		return "", fmt.Errorf("failed to create template from string %q: %w", imageFormat, err)
+		return nil, fmt.Errorf("parsing image catalog %s: %w", key, err)
 	}
 
-	if err := template.Execute(&buf, imageParams); err != nil {
-		return "", fmt.Errorf("failed to populate template %q: %w", imageFormat, err)
+	return catalog, nil
+}
+
+// hardwareArch returns the architecture reported in the DHCP settings of the first interface of the Hardware
+// reporting one.
+func hardwareArch(hw *tinkv1.Hardware) string {
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP != nil && iface.DHCP.Arch != "" {
+			return iface.DHCP.Arch
+		}
 	}
 
-	return buf.String(), nil
+	return ""
 }
@@ -22,7 +22,9 @@ import (
 	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
 	"sigs.k8s.io/yaml"
 
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
 	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/tinktemplate"
 )
 
 func validWorkflowTemplate() *machine.WorkflowTemplate {
@@ -65,6 +67,527 @@ func Test_Cloud_config_template(t *testing.T) {
 			mutateF: func(_ *machine.WorkflowTemplate) {},
 		},
 
+		"fails_when_action_environment_references_unknown_action": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.ActionEnvironment = map[string]map[string]string{"does not exist": {"FOO": "bar"}}
+			},
+			expectError:   true,
+			expectedError: machine.ErrActionNotFound,
+		},
+
+		"applies_action_environment_overrides": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.ActionEnvironment = map[string]map[string]string{
+					"stream image": {"COMPRESSED": "false", "EXTRA": "1"},
+				}
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+				x := struct {
+					Tasks []struct {
+						Actions []struct {
+							Name        string            `json:"name"`
+							Environment map[string]string `json:"environment"`
+						} `json:"actions"`
+					} `json:"tasks"`
+				}{}
+
+				g.Expect(yaml.Unmarshal([]byte(renderResult), &x)).To(Succeed())
+				g.Expect(x.Tasks[0].Actions[0].Name).To(Equal("stream image"))
+				g.Expect(x.Tasks[0].Actions[0].Environment).To(HaveKeyWithValue("COMPRESSED", "false"))
+				g.Expect(x.Tasks[0].Actions[0].Environment).To(HaveKeyWithValue("EXTRA", "1"))
+				g.Expect(x.Tasks[0].Actions[0].Environment).To(HaveKeyWithValue("DEST_DISK", "/dev/sda"))
+				g.Expect(renderResult).To(ContainSubstring("MODE: 0600"), "Expected untouched values to keep their formatting")
+			},
+		},
+
+		"streams_raw_images_uncompressed": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.ImageFormat = infrastructurev1.ImageFormatRaw
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+
+				g.Expect(renderResult).To(ContainSubstring("image: quay.io/tinkerbell/actions/oci2disk"))
+				g.Expect(renderResult).To(ContainSubstring("COMPRESSED: false"))
+			},
+		},
+
+		"converts_qcow2_images": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.ImageFormat = infrastructurev1.ImageFormatQCOW2
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+
+				g.Expect(renderResult).To(ContainSubstring("image: quay.io/tinkerbell/actions/qemuimg2disk"))
+				g.Expect(renderResult).NotTo(ContainSubstring("COMPRESSED"))
+			},
+		},
+
+		"writes_static_network_config": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.StaticNetwork = &infrastructurev1.StaticNetwork{
+					Address:     "10.0.0.10/24",
+					Gateway:     "10.0.0.1",
+					Nameservers: []string{"10.0.0.2"},
+					MACAddress:  "00:00:5e:00:53:01",
+				}
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+				x := struct {
+					Tasks []struct {
+						Actions []struct {
+							Name        string            `json:"name"`
+							Environment map[string]string `json:"environment"`
+						} `json:"actions"`
+					} `json:"tasks"`
+				}{}
+
+				g.Expect(yaml.Unmarshal([]byte(renderResult), &x)).To(Succeed())
+
+				contents := map[string]string{}
+				for _, action := range x.Tasks[0].Actions {
+					contents[action.Name] = action.Environment["CONTENTS"]
+				}
+
+				g.Expect(contents).To(HaveKey("add static network config"))
+				g.Expect(contents["add tink cloud-init config"]).To(ContainSubstring("config: disabled"))
+
+				netplan := struct {
+					Network struct {
+						Ethernets map[string]struct {
+							Match       map[string]string   `json:"match"`
+							Addresses   []string            `json:"addresses"`
+							Routes      []map[string]string `json:"routes"`
+							Nameservers struct {
+								Addresses []string `json:"addresses"`
+							} `json:"nameservers"`
+						} `json:"ethernets"`
+					} `json:"network"`
+				}{}
+
+				g.Expect(yaml.Unmarshal([]byte(contents["add static network config"]), &netplan)).To(Succeed())
+
+				iface := netplan.Network.Ethernets["capt0"]
+				g.Expect(iface.Match).To(HaveKeyWithValue("macaddress", "00:00:5e:00:53:01"))
+				g.Expect(iface.Addresses).To(Equal([]string{"10.0.0.10/24"}))
+				g.Expect(iface.Routes).To(Equal([]map[string]string{{"to": "default", "via": "10.0.0.1"}}))
+				g.Expect(iface.Nameservers.Addresses).To(Equal([]string{"10.0.0.2"}))
+			},
+		},
+
+		"requires_MAC_address_for_static_network": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.StaticNetwork = &infrastructurev1.StaticNetwork{Address: "10.0.0.10/24"}
+			},
+			expectError:   true,
+			expectedError: machine.ErrStaticNetworkMissingMACAddress,
+		},
+
+		"writes_bond_network_config": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.Bond = &infrastructurev1.Bond{
+					Mode:       infrastructurev1.BondModeLACP,
+					Interfaces: []string{"00:00:5e:00:53:01", "00:00:5e:00:53:02"},
+				}
+				wt.StaticNetwork = &infrastructurev1.StaticNetwork{
+					Address: "10.0.0.10/24",
+					Gateway: "10.0.0.1",
+				}
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+				x := struct {
+					Tasks []struct {
+						Actions []struct {
+							Name        string            `json:"name"`
+							Environment map[string]string `json:"environment"`
+						} `json:"actions"`
+					} `json:"tasks"`
+				}{}
+
+				g.Expect(yaml.Unmarshal([]byte(renderResult), &x)).To(Succeed())
+
+				contents := map[string]string{}
+				for _, action := range x.Tasks[0].Actions {
+					contents[action.Name] = action.Environment["CONTENTS"]
+				}
+
+				g.Expect(contents).To(HaveKey("add bond network config"))
+				g.Expect(contents).NotTo(HaveKey("add static network config"))
+				g.Expect(contents["add tink cloud-init config"]).To(ContainSubstring("config: disabled"))
+
+				netplan := struct {
+					Network struct {
+						Ethernets map[string]struct {
+							Match map[string]string `json:"match"`
+							DHCP4 bool              `json:"dhcp4"`
+						} `json:"ethernets"`
+						Bonds map[string]struct {
+							Interfaces []string            `json:"interfaces"`
+							MACAddress string              `json:"macaddress"`
+							Parameters map[string]any      `json:"parameters"`
+							DHCP4      bool                `json:"dhcp4"`
+							Addresses  []string            `json:"addresses"`
+							Routes     []map[string]string `json:"routes"`
+						} `json:"bonds"`
+					} `json:"network"`
+				}{}
+
+				g.Expect(yaml.Unmarshal([]byte(contents["add bond network config"]), &netplan)).To(Succeed())
+
+				g.Expect(netplan.Network.Ethernets).To(HaveLen(2))
+				g.Expect(netplan.Network.Ethernets["capt1"].Match).To(HaveKeyWithValue("macaddress", "00:00:5e:00:53:02"))
+				g.Expect(netplan.Network.Ethernets["capt1"].DHCP4).To(BeFalse())
+
+				bond := netplan.Network.Bonds["bond0"]
+				g.Expect(bond.Interfaces).To(Equal([]string{"capt0", "capt1"}))
+				g.Expect(bond.MACAddress).To(Equal("00:00:5e:00:53:01"), "Expected the MAC address of the primary member")
+				g.Expect(bond.Parameters).To(HaveKeyWithValue("mode", "802.3ad"))
+				g.Expect(bond.Parameters).To(HaveKeyWithValue("lacp-rate", "fast"))
+				g.Expect(bond.DHCP4).To(BeFalse())
+				g.Expect(bond.Addresses).To(Equal([]string{"10.0.0.10/24"}))
+				g.Expect(bond.Routes).To(Equal([]map[string]string{{"to": "default", "via": "10.0.0.1"}}))
+			},
+		},
+
+		"uses_DHCP_on_active_backup_bond_without_static_network": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.Bond = &infrastructurev1.Bond{
+					Mode:       infrastructurev1.BondModeActiveBackup,
+					Interfaces: []string{"00:00:5e:00:53:01", "00:00:5e:00:53:02"},
+				}
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+
+				g.Expect(renderResult).To(ContainSubstring(`mode: "active-backup"`))
+				g.Expect(renderResult).To(ContainSubstring("primary: capt0"))
+				g.Expect(renderResult).To(ContainSubstring("dhcp4: true"))
+				g.Expect(renderResult).NotTo(ContainSubstring("lacp-rate"))
+			},
+		},
+
+		"requires_interfaces_for_bond": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.Bond = &infrastructurev1.Bond{Mode: infrastructurev1.BondModeLACP}
+			},
+			expectError:   true,
+			expectedError: machine.ErrBondMissingInterfaces,
+		},
+
+		"rejects_bond_for_ignition_bootstrap_data": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.BootstrapFormat = machine.BootstrapFormatIgnition
+				wt.Bond = &infrastructurev1.Bond{Interfaces: []string{"00:00:5e:00:53:01"}}
+			},
+			expectError:   true,
+			expectedError: machine.ErrBondUnsupportedBootstrapFormat,
+		},
+
+		"skips_cloud_init_config_for_ignition_bootstrap_data": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.BootstrapFormat = machine.BootstrapFormatIgnition
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+
+				g.Expect(renderResult).NotTo(ContainSubstring("cloud-init"))
+				g.Expect(renderResult).To(ContainSubstring("kexec image"))
+			},
+		},
+
+		"rejects_static_network_for_talos_bootstrap_data": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.BootstrapFormat = machine.BootstrapFormatTalos
+				wt.StaticNetwork = &infrastructurev1.StaticNetwork{Address: "10.0.0.10/24", MACAddress: "00:00:5e:00:53:01"}
+			},
+			expectError:   true,
+			expectedError: machine.ErrStaticNetworkUnsupportedBootstrapFormat,
+		},
+
+		"configures_cloudbase_init_for_windows_images": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.OSFamily = infrastructurev1.OSFamilyWindows
+				wt.DestPartition = "/dev/sda3"
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+
+				g.Expect(renderResult).NotTo(ContainSubstring("cloud-init"))
+				g.Expect(renderResult).NotTo(ContainSubstring("kexec"))
+				g.Expect(renderResult).To(ContainSubstring("DEST_DISK: /dev/sda3"))
+				g.Expect(renderResult).To(ContainSubstring("FS_TYPE: ntfs3"))
+				g.Expect(renderResult).To(ContainSubstring("metadata_base_url=http://10.10.10.10/"))
+				g.Expect(renderResult).To(ContainSubstring("username=Admin"))
+				g.Expect(renderResult).To(ContainSubstring(`command: ["reboot"]`))
+				g.Expect(yaml.Unmarshal([]byte(renderResult), &map[string]any{})).To(Succeed())
+			},
+		},
+
+		"creates_windows_user_of_bmc_credentials": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.OSFamily = infrastructurev1.OSFamilyWindows
+				wt.WindowsUsername = "operator"
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+
+				g.Expect(renderResult).To(ContainSubstring("username=operator\n"))
+				g.Expect(renderResult).NotTo(ContainSubstring("username=Admin"))
+			},
+		},
+
+		"rejects_windows_username_breaking_the_configuration": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.OSFamily = infrastructurev1.OSFamilyWindows
+				wt.WindowsUsername = "operator\n[ec2]"
+			},
+			expectError:   true,
+			expectedError: machine.ErrInvalidWindowsUsername,
+		},
+
+		"applies_wim_images_with_their_action": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.OSFamily = infrastructurev1.OSFamilyWindows
+				wt.ImageFormat = infrastructurev1.ImageFormatWIM
+				wt.WIMApplyAction = "registry.example.com/actions/wimapply:v1"
+				wt.DestPartition = "/dev/sda3"
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+
+				g.Expect(renderResult).To(ContainSubstring("image: registry.example.com/actions/wimapply:v1"))
+				g.Expect(renderResult).To(ContainSubstring("DEST_PARTITION: /dev/sda3"))
+				g.Expect(renderResult).NotTo(ContainSubstring("COMPRESSED"))
+				g.Expect(yaml.Unmarshal([]byte(renderResult), &map[string]any{})).To(Succeed())
+			},
+		},
+
+		"rejects_wim_images_without_their_action": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.OSFamily = infrastructurev1.OSFamilyWindows
+				wt.ImageFormat = infrastructurev1.ImageFormatWIM
+			},
+			expectError:   true,
+			expectedError: machine.ErrWIMUnsupported,
+		},
+
+		"rejects_static_network_for_windows_images": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.OSFamily = infrastructurev1.OSFamilyWindows
+				wt.StaticNetwork = &infrastructurev1.StaticNetwork{Address: "10.0.0.10/24", MACAddress: "00:00:5e:00:53:01"}
+			},
+			expectError:   true,
+			expectedError: machine.ErrWindowsUnsupportedNetworkConfig,
+		},
+
+		"rejects_ignition_bootstrap_data_for_windows_images": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.OSFamily = infrastructurev1.OSFamilyWindows
+				wt.BootstrapFormat = machine.BootstrapFormatIgnition
+			},
+			expectError:   true,
+			expectedError: machine.ErrWindowsUnsupportedBootstrapFormat,
+		},
+
+		"overrides_timeouts": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.Timeouts = &infrastructurev1.WorkflowTimeouts{
+					Global:  9000,
+					Actions: map[string]int64{"stream image": 3600, "not in template": 10},
+				}
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+
+				g.Expect(renderResult).To(ContainSubstring("global_timeout: 9000"))
+				g.Expect(renderResult).To(MatchRegexp(`name: "?stream image"?\n\s+image: \S+\n\s+timeout: 3600\n`))
+				g.Expect(renderResult).NotTo(ContainSubstring("not in template"))
+			},
+		},
+
+		"writes_files_before_booting_into_the_os": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.Files = []machine.WorkflowFile{
+					{Path: "/etc/udev/rules.d/70-nic.rules", Content: "SUBSYSTEM==\"net\"\n"},
+					{Path: "/etc/motd", Mode: "600", Content: "{{ not a template }}"},
+				}
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+				x := struct {
+					Tasks []struct {
+						Actions []struct {
+							Name        string            `json:"name"`
+							Environment map[string]string `json:"environment"`
+						} `json:"actions"`
+					} `json:"tasks"`
+				}{}
+
+				g.Expect(yaml.Unmarshal([]byte(renderResult), &x)).To(Succeed())
+				g.Expect(tinktemplate.Validate(renderResult, nil)).To(Succeed())
+
+				actions := x.Tasks[0].Actions
+				names := make([]string, 0, len(actions))
+
+				for _, action := range actions {
+					names = append(names, action.Name)
+				}
+
+				g.Expect(names[len(names)-3:]).To(Equal([]string{
+					"add file /etc/udev/rules.d/70-nic.rules", "add file /etc/motd", "kexec image",
+				}))
+
+				udev := actions[len(actions)-3].Environment
+				g.Expect(udev).To(HaveKeyWithValue("DEST_PATH", "/etc/udev/rules.d/70-nic.rules"))
+				g.Expect(udev).To(HaveKeyWithValue("DEST_DISK", "/dev/sda1"))
+				g.Expect(udev).To(HaveKeyWithValue("MODE", "0644"))
+				g.Expect(udev).To(HaveKeyWithValue("CONTENTS", "SUBSYSTEM==\"net\"\n"))
+
+				motd := actions[len(actions)-2].Environment
+				g.Expect(motd).To(HaveKeyWithValue("MODE", "0600"))
+				g.Expect(motd).To(HaveKeyWithValue("CONTENTS", `{{"{{"}} not a template }}`),
+					"Expected template actions to be escaped from the Tinkerbell template rendering")
+			},
+		},
+
+		"configures_proxy": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.Proxy = &infrastructurev1.Proxy{
+					HTTPProxy: "http://proxy.example.com:3128",
+					NoProxy:   []string{"10.0.0.0/8", ".cluster.local"},
+				}
+				wt.ActionEnvironment = map[string]map[string]string{"kexec image": {"HTTP_PROXY": ""}}
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+				x := struct {
+					Tasks []struct {
+						Actions []struct {
+							Name        string            `json:"name"`
+							Environment map[string]string `json:"environment"`
+						} `json:"actions"`
+					} `json:"tasks"`
+				}{}
+
+				g.Expect(yaml.Unmarshal([]byte(renderResult), &x)).To(Succeed())
+
+				env := map[string]map[string]string{}
+				for _, action := range x.Tasks[0].Actions {
+					env[action.Name] = action.Environment
+				}
+
+				g.Expect(env["stream image"]).To(HaveKeyWithValue("HTTP_PROXY", "http://proxy.example.com:3128"))
+				g.Expect(env["stream image"]).To(HaveKeyWithValue("http_proxy", "http://proxy.example.com:3128"))
+				g.Expect(env["stream image"]).To(HaveKeyWithValue("NO_PROXY", "10.0.0.0/8,.cluster.local"))
+				g.Expect(env["stream image"]).NotTo(HaveKey("HTTPS_PROXY"))
+				g.Expect(env["kexec image"]).To(HaveKeyWithValue("HTTP_PROXY", ""),
+					"Expected the action environment to take precedence")
+
+				for _, service := range []string{"containerd", "docker"} {
+					dropIn := env["add file /etc/systemd/system/"+service+".service.d/http-proxy.conf"]
+					g.Expect(dropIn).To(HaveKeyWithValue("CONTENTS", "[Service]\n"+
+						"Environment=\"HTTP_PROXY=http://proxy.example.com:3128\"\n"+
+						"Environment=\"NO_PROXY=10.0.0.0/8,.cluster.local\"\n"))
+				}
+			},
+		},
+
+		"configures_proxy_without_drop_ins_on_ignition_images": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.BootstrapFormat = machine.BootstrapFormatIgnition
+				wt.Proxy = &infrastructurev1.Proxy{HTTPProxy: "http://proxy.example.com:3128"}
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+				g.Expect(renderResult).To(ContainSubstring("HTTP_PROXY: http://proxy.example.com:3128"))
+				g.Expect(renderResult).NotTo(ContainSubstring("http-proxy.conf"))
+			},
+		},
+
+		"pulls_action_images_from_registry": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.ActionImages = &infrastructurev1.ActionImages{
+					Registry:   "10.1.1.1:5000/tinkerbell",
+					BundleURL:  "http://10.1.1.1:8080/actions.tar",
+					PullPolicy: infrastructurev1.ActionImagePullIfNotPresent,
+				}
+			},
+			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+				x := struct {
+					Tasks []struct {
+						Actions []struct {
+							Name        string            `json:"name"`
+							Image       string            `json:"image"`
+							Command     []string          `json:"command"`
+							Environment map[string]string `json:"environment"`
+						} `json:"actions"`
+					} `json:"tasks"`
+				}{}
+
+				g.Expect(yaml.Unmarshal([]byte(renderResult), &x)).To(Succeed())
+
+				actions := x.Tasks[0].Actions
+				g.Expect(actions[0].Name).To(Equal("load action images"))
+				g.Expect(actions[0].Image).To(Equal("10.1.1.1:5000/tinkerbell/library/docker:27-cli"))
+
+				for _, action := range actions[1:] {
+					g.Expect(action.Image).To(HavePrefix("10.1.1.1:5000/tinkerbell/"), action.Name)
+				}
+
+				kexec := actions[len(actions)-1]
+				g.Expect(kexec.Environment).To(HaveKeyWithValue("IMAGE",
+					"10.1.1.1:5000/tinkerbell/tinkerbell/actions/kexec"))
+
+				script := actions[0].Command[2]
+				g.Expect(script).To(ContainSubstring("wget -qO- 'http://10.1.1.1:8080/actions.tar' | docker load\n"))
+				g.Expect(script).To(ContainSubstring(
+					"docker image inspect '10.1.1.1:5000/tinkerbell/tinkerbell/actions/kexec' >/dev/null 2>&1 || " +
+						"docker pull '10.1.1.1:5000/tinkerbell/tinkerbell/actions/kexec'\n"))
+			},
+		},
+
+		"verifies_streamed_image_against_signed_checksum": {
+			mutateF: func(wt *machine.WorkflowTemplate) {
+				wt.ImageVerification = &infrastructurev1.ImageVerification{
+					CertificateIdentity:   "https://github.com/example/images/.github/workflows/build.yaml@refs/heads/main",
+					CertificateOIDCIssuer: "https://token.actions.githubusercontent.com",
+				}
+				wt.ActionImages = &infrastructurev1.ActionImages{Registry: "10.1.1.1:5000"}
+			},
+			validateF: func(t *testing.T, wt *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
+				g := NewWithT(t)
+				x := struct {
+					Tasks []struct {
+						Actions []struct {
+							Name        string            `json:"name"`
+							Image       string            `json:"image"`
+							Command     []string          `json:"command"`
+							Environment map[string]string `json:"environment"`
+						} `json:"actions"`
+					} `json:"tasks"`
+				}{}
+
+				g.Expect(yaml.Unmarshal([]byte(renderResult), &x)).To(Succeed())
+				g.Expect(tinktemplate.Validate(renderResult, nil)).To(Succeed())
+
+				actions := x.Tasks[0].Actions
+				g.Expect(actions[0].Name).To(Equal("stream image"))
+				g.Expect(actions[0].Image).To(Equal("10.1.1.1:5000/sigstore/cosign/cosign:v2.4.1-dev"),
+					"Expected the verification image to be pulled from the action images registry")
+				g.Expect(actions[0].Environment).To(HaveKeyWithValue("IMG_URL", wt.ImageURL))
+				g.Expect(actions[0].Environment).To(HaveKeyWithValue("CERTIFICATE_OIDC_ISSUER",
+					"https://token.actions.githubusercontent.com"))
+				g.Expect(actions[0].Environment).To(HaveKeyWithValue("DEST_DISK", wt.DestDisk))
+				g.Expect(actions[0].Command[2]).To(ContainSubstring(
+					"cosign verify-blob --bundle /tmp/image.sha256.bundle"))
+			},
+		},
+
 		"rendered_output_should_be_valid_YAML": {
 			validateF: func(t *testing.T, _ *machine.WorkflowTemplate, renderResult string) { //nolint:thelper
 				g := NewWithT(t)
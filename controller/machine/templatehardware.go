@@ -0,0 +1,135 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/component-base/featuregate"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// controlPlaneGroup is the API group of the control plane providers owning the templates of control plane machines.
+const controlPlaneGroup = "controlplane.cluster.x-k8s.io"
+
+// TemplateHardwareOptions configures the selection of the Hardware of a TinkerbellMachineTemplate like the
+// TinkerbellMachineReconciler fields of the same names configure the selection of the Hardware of a machine.
+type TemplateHardwareOptions struct {
+	// FeatureGates are the feature gates of the controller. Nil uses their default state.
+	FeatureGates featuregate.FeatureGate
+
+	// HardwarePoolAuthorizer authorizes the use of HardwarePools. Nil uses NewSubjectAccessReviewPoolAuthorizer.
+	HardwarePoolAuthorizer HardwarePoolAuthorizer
+
+	// WatchNamespaces are the namespaces the cache is restricted to, empty when all namespaces are watched.
+	WatchNamespaces []string
+}
+
+// HardwareMatchingTemplate returns the Hardware machines created from the template may be provisioned on,
+// regardless of whether it is claimed: the Hardware of the namespace of the template, or of the sources of its
+// HardwarePool, matching its hardware affinity, narrowed down by role and control plane reservation like the
+// Hardware selected for a machine. Machines of templates owned by a control plane provider are control plane
+// machines.
+func HardwareMatchingTemplate(
+	ctx context.Context,
+	c client.Client,
+	tmpl *infrastructurev1.TinkerbellMachineTemplate,
+	options TemplateHardwareOptions,
+) ([]tinkv1.Hardware, error) {
+	tinkerbellMachine := &infrastructurev1.TinkerbellMachine{}
+	tinkerbellMachine.Namespace = tmpl.Namespace
+	tinkerbellMachine.Spec = tmpl.Spec.Template.Spec
+
+	for _, ref := range tmpl.OwnerReferences {
+		if strings.HasPrefix(ref.APIVersion, controlPlaneGroup+"/") {
+			tinkerbellMachine.Labels = map[string]string{clusterv1.MachineControlPlaneLabel: ""}
+		}
+	}
+
+	tinkerbellCluster, err := templateTinkerbellCluster(ctx, c, tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := &machineReconcileScope{
+		ctx:                    ctx,
+		client:                 c,
+		tinkerbellMachine:      tinkerbellMachine,
+		tinkerbellCluster:      tinkerbellCluster,
+		featureGates:           options.FeatureGates,
+		hardwarePoolAuthorizer: options.HardwarePoolAuthorizer,
+		watchNamespaces:        options.WatchNamespaces,
+	}
+
+	sources, err := scope.hardwareSources()
+	if err != nil {
+		return nil, err
+	}
+
+	var hardware []tinkv1.Hardware
+
+	for _, source := range sources {
+		selector, err := sourceSelector(labels.Everything(), source)
+		if err != nil {
+			return nil, err
+		}
+
+		list := &tinkv1.HardwareList{}
+		if err := c.List(ctx, list, &client.ListOptions{LabelSelector: selector, Namespace: source.Namespace}); err != nil {
+			return nil, fmt.Errorf("listing Hardware: %w", err)
+		}
+
+		hardware = append(hardware, list.Items...)
+	}
+
+	matching, err := hardwareMatchingAffinity(hardware, tmpl.Spec.Template.Spec.HardwareAffinity)
+	if err != nil {
+		return nil, err
+	}
+
+	return scope.reservedHardware(scope.roleHardware(matching)), nil
+}
+
+// templateTinkerbellCluster returns the TinkerbellCluster of the cluster named by the cluster name label of the
+// template, nil when the template has none or the cluster does not exist.
+func templateTinkerbellCluster(
+	ctx context.Context,
+	c client.Client,
+	tmpl *infrastructurev1.TinkerbellMachineTemplate,
+) (*infrastructurev1.TinkerbellCluster, error) {
+	name := tmpl.Labels[clusterv1.ClusterNameLabel]
+	if name == "" {
+		return nil, nil
+	}
+
+	cluster := &clusterv1.Cluster{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: tmpl.Namespace, Name: name}, cluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("getting Cluster %s: %w", name, err)
+	}
+
+	ref := cluster.Spec.InfrastructureRef
+	if ref == nil {
+		return nil, nil
+	}
+
+	tinkerbellCluster := &infrastructurev1.TinkerbellCluster{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: tmpl.Namespace, Name: ref.Name}, tinkerbellCluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("getting TinkerbellCluster %s: %w", ref.Name, err)
+	}
+
+	return tinkerbellCluster, nil
+}
@@ -0,0 +1,112 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_HardwareMatchingTemplate(t *testing.T) {
+	t.Parallel()
+
+	const poolNamespace = "hardware-pool"
+
+	workerLabels := map[string]string{"type": "worker"}
+	reservedLabels := map[string]string{
+		"type":                           "worker",
+		machine.HardwareReservedForLabel: machine.HardwareReservedForControlPlane,
+	}
+
+	hardware := func(name, namespace string, labels map[string]string) *tinkv1.Hardware {
+		hw := validHardware(name, "", hardwareIP, testOptions{Labels: labels})
+		hw.Namespace = namespace
+
+		return hw
+	}
+
+	tests := map[string]struct {
+		ownerAPIVersion string
+		pool            string
+		want            []string
+	}{
+		"worker_templates_skip_reserved_hardware": {
+			want: []string{"free", "claimed"},
+		},
+		"control_plane_templates_prefer_reserved_hardware": {
+			ownerAPIVersion: "controlplane.cluster.x-k8s.io/v1beta1",
+			want:            []string{"reserved"},
+		},
+		"pool_templates_select_hardware_of_the_pool": {
+			pool: "shared",
+			want: []string{"pooled"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			claimed := hardware("claimed", clusterNamespace, workerLabels)
+			claimed.Labels[machine.HardwareOwnerNameLabel] = "other"
+
+			tmpl := &infrastructurev1.TinkerbellMachineTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: clusterNamespace},
+				Spec: infrastructurev1.TinkerbellMachineTemplateSpec{
+					Template: infrastructurev1.TinkerbellMachineTemplateResource{
+						Spec: infrastructurev1.TinkerbellMachineSpec{
+							HardwarePool: tc.pool,
+							HardwareAffinity: &infrastructurev1.HardwareAffinity{
+								Required: []infrastructurev1.HardwareAffinityTerm{{
+									LabelSelector: metav1.LabelSelector{MatchLabels: workerLabels},
+								}},
+							},
+						},
+					},
+				},
+			}
+
+			if tc.ownerAPIVersion != "" {
+				tmpl.OwnerReferences = []metav1.OwnerReference{{
+					APIVersion: tc.ownerAPIVersion,
+					Kind:       "KubeadmControlPlane",
+					Name:       "control-plane",
+				}}
+			}
+
+			client := kubernetesClientWithObjects(t, []runtime.Object{
+				&infrastructurev1.HardwarePool{
+					ObjectMeta: metav1.ObjectMeta{Name: "shared"},
+					Spec: infrastructurev1.HardwarePoolSpec{
+						Sources: []infrastructurev1.HardwarePoolSource{{Namespace: poolNamespace}},
+					},
+				},
+				hardware("free", clusterNamespace, workerLabels),
+				hardware("reserved", clusterNamespace, reservedLabels),
+				hardware("other", clusterNamespace, map[string]string{"type": "storage"}),
+				hardware("pooled", poolNamespace, workerLabels),
+				claimed,
+			})
+
+			matching, err := machine.HardwareMatchingTemplate(context.Background(), client, tmpl,
+				machine.TemplateHardwareOptions{
+					HardwarePoolAuthorizer: func(context.Context, string, string) (bool, error) { return true, nil },
+				})
+			g.Expect(err).NotTo(HaveOccurred())
+
+			names := []string{}
+			for i := range matching {
+				names = append(names, matching[i].Name)
+			}
+
+			g.Expect(names).To(ConsistOf(tc.want))
+		})
+	}
+}
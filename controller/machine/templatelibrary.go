@@ -0,0 +1,104 @@
+package machine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	// ErrNoTemplateLibrary is the error returned when a machine references a library template but its
+	// TinkerbellCluster has no template library.
+	ErrNoTemplateLibrary = errors.New("cluster has no template library")
+
+	// ErrTemplateNotInLibrary is the error returned when the template referenced by a machine is not in the template
+	// library of its TinkerbellCluster.
+	ErrTemplateNotInLibrary = errors.New("template not in library")
+)
+
+// LibraryTemplateContext is the context of the machine library templates are rendered with.
+type LibraryTemplateContext struct {
+	// MachineName and ClusterName are the names of the TinkerbellMachine and of its cluster.
+	MachineName string
+	ClusterName string
+
+	// HardwareName is the name of the Hardware the machine is provisioned on.
+	HardwareName string
+
+	// KubernetesVersion is the Kubernetes version of the Machine.
+	KubernetesVersion string
+
+	// ImageURL is the URL of the OS image, as rendered from the image lookup format.
+	ImageURL string
+
+	// DestDisk is the disk the OS is installed to and DestPartition its partition holding the OS.
+	DestDisk      string
+	DestPartition string
+
+	// MetadataURL is the URL of the metadata service of the machine.
+	MetadataURL string
+
+	// Worker is the reference to the worker in the hardware map of the workflow, e.g. {{.device_1}}, to use as the
+	// worker of tasks.
+	Worker string
+}
+
+// libraryTemplate returns the named template of the template library of the cluster, rendered with the context of
+// the machine. Templates use [[ and ]] as delimiters, so the placeholders rendered by Tinkerbell are kept as they
+// are.
+func (scope *machineReconcileScope) libraryTemplate(name string, hw *tinkv1.Hardware) (string, error) {
+	ref := scope.tinkerbellCluster.Spec.TemplateLibraryRef
+	if ref == nil {
+		return "", fmt.Errorf("%w: template %q is referenced by templateRefName", ErrNoTemplateLibrary, name)
+	}
+
+	cm := &corev1.ConfigMap{}
+
+	key := client.ObjectKey{Namespace: scope.tinkerbellCluster.Namespace, Name: ref.Name}
+	if err := scope.client.Get(scope.ctx, key, cm); err != nil {
+		return "", fmt.Errorf("getting template library %s: %w", key, err)
+	}
+
+	data, ok := cm.Data[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %q in %s", ErrTemplateNotInLibrary, name, key)
+	}
+
+	targetDisk, err := scope.targetDisk(hw)
+	if err != nil {
+		return "", err
+	}
+
+	imageURL, err := scope.imageURL(hw)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate imageURL: %w", err)
+	}
+
+	tmpl, err := template.New(name).Delims("[[", "]]").Option("missingkey=error").Parse(data)
+	if err != nil {
+		return "", fmt.Errorf("%w: library template %q: %w", ErrMalformedTemplate, name, err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, LibraryTemplateContext{
+		MachineName:       scope.tinkerbellMachine.Name,
+		ClusterName:       scope.machine.Spec.ClusterName,
+		HardwareName:      hw.Name,
+		KubernetesVersion: *scope.machine.Spec.Version,
+		ImageURL:          imageURL,
+		DestDisk:          targetDisk,
+		DestPartition:     partitionFromDevice(targetDisk, scope.osPartition()),
+		MetadataURL:       scope.metadataURL(),
+		Worker:            fmt.Sprintf("{{.%s}}", scope.workerDeviceKey()),
+	}); err != nil {
+		return "", fmt.Errorf("%w: library template %q: %w", ErrMalformedTemplate, name, err)
+	}
+
+	return buf.String(), nil
+}
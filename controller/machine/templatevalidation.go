@@ -0,0 +1,130 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/tinktemplate"
+)
+
+// TemplateValidationReconciler validates the user-supplied Templates of TinkerbellMachines, from template overrides,
+// library templates and workflow stages, whenever they change, so Templates edited into a state the Tinkerbell
+// workflow controller cannot render are reported on the machine before its Workflow fails. The Templates CAPT
+// generates are not validated.
+type TemplateValidationReconciler struct {
+	client.Client
+
+	mu sync.Mutex
+
+	// reported is the validation error last reported for each invalid Template, so a warning event is only
+	// recorded when a Template becomes invalid or fails for another reason.
+	reported map[types.NamespacedName]string
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=templates,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile validates the Template and records a warning event on the TinkerbellMachine owning it when it became
+// invalid.
+func (r *TemplateValidationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	t := &tinkv1.Template{}
+	if err := r.Client.Get(ctx, req.NamespacedName, t); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.forget(req.NamespacedName)
+
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("getting Template: %w", err)
+	}
+
+	tm, err := ownerMachine(ctx, r.Client, t)
+	if err != nil || tm == nil || !tm.DeletionTimestamp.IsZero() || generatedTemplate(tm, t) {
+		return ctrl.Result{}, err
+	}
+
+	data := ""
+	if t.Spec.Data != nil {
+		data = *t.Spec.Data
+	}
+
+	validationErr := tinktemplate.Validate(data, nil)
+	if validationErr == nil {
+		r.forget(req.NamespacedName)
+
+		return ctrl.Result{}, nil
+	}
+
+	if !r.report(req.NamespacedName, validationErr.Error()) {
+		return ctrl.Result{}, nil
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Template of TinkerbellMachine is invalid",
+		"tinkerbellMachine", client.ObjectKeyFromObject(tm), "error", validationErr.Error())
+	record.Warnf(tm, "InvalidTemplate", "Template %s is invalid: %v", t.Name, validationErr)
+
+	return ctrl.Result{}, nil
+}
+
+// generatedTemplate returns whether the Template is the one CAPT generates to install the OS of the machine, which
+// is the case unless the machine has a template override or a library template. Templates of workflow stages are
+// always supplied by the user.
+func generatedTemplate(tm *infrastructurev1.TinkerbellMachine, t *tinkv1.Template) bool {
+	if tm.Spec.TemplateOverride != "" || tm.Spec.TemplateRefName != "" {
+		return false
+	}
+
+	return t.Name == (&machineReconcileScope{tinkerbellMachine: tm}).templateName()
+}
+
+// report records the validation error of the Template and returns whether it differs from the one last reported.
+func (r *TemplateValidationReconciler) report(key types.NamespacedName, validationErr string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.reported == nil {
+		r.reported = map[types.NamespacedName]string{}
+	}
+
+	if r.reported[key] == validationErr {
+		return false
+	}
+
+	r.reported[key] = validationErr
+
+	return true
+}
+
+// forget removes the validation error reported for a Template which became valid or was deleted.
+func (r *TemplateValidationReconciler) forget(key types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.reported, key)
+}
+
+// SetupWithManager configures reconciler with a given manager.
+func (r *TemplateValidationReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("tinkerbelltemplatevalidation").
+		WithOptions(options).
+		For(&tinkv1.Template{}, builder.WithPredicates(ownedByMachine, predicate.GenerationChangedPredicate{})).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
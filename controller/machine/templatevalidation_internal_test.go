@@ -0,0 +1,57 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_generatedTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		spec     infrastructurev1.TinkerbellMachineSpec
+		template string
+		want     bool
+	}{
+		"template_of_the_os":  {template: "machine", want: true},
+		"template_of_a_stage": {template: "machine-firmware"},
+		"template_override":   {spec: infrastructurev1.TinkerbellMachineSpec{TemplateOverride: "t"}, template: "machine"},
+		"library_template":    {spec: infrastructurev1.TinkerbellMachineSpec{TemplateRefName: "t"}, template: "machine"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			tm := &infrastructurev1.TinkerbellMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+				Spec:       tc.spec,
+			}
+			tmpl := &tinkv1.Template{ObjectMeta: metav1.ObjectMeta{Name: tc.template, Namespace: "default"}}
+
+			g.Expect(generatedTemplate(tm, tmpl)).To(Equal(tc.want))
+		})
+	}
+}
+
+func Test_TemplateValidationReconciler_reports_transitions(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	r := &TemplateValidationReconciler{}
+	key := types.NamespacedName{Namespace: "default", Name: "machine"}
+
+	g.Expect(r.report(key, "no tasks")).To(BeTrue(), "Expected a Template becoming invalid to be reported")
+	g.Expect(r.report(key, "no tasks")).To(BeFalse(), "Expected the same error not to be reported again")
+	g.Expect(r.report(key, "no actions")).To(BeTrue(), "Expected another error to be reported")
+
+	r.forget(key)
+	g.Expect(r.report(key, "no actions")).To(BeTrue(), "Expected a Template invalid again to be reported")
+}
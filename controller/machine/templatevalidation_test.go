@@ -0,0 +1,57 @@
+package machine_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_Template_validation_of_machine_templates(t *testing.T) {
+	t.Parallel()
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+	tm.Spec.TemplateOverride = "version: \"0.1\""
+
+	for name, data := range map[string]string{
+		"valid":   "version: \"0.1\"\nname: t\ntasks:\n- name: t\n  worker: w\n  actions:\n  - name: a\n    image: i\n",
+		"invalid": "version: \"0.1\"\nname: t\ntasks: []\n",
+		"syntax":  "name: {{ .Unclosed",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			template := &tinkv1.Template{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      tinkerbellMachineName,
+					Namespace: clusterNamespace,
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
+						Kind:       "TinkerbellMachine",
+						Name:       tinkerbellMachineName,
+						UID:        tm.UID,
+						Controller: ptr.To(true),
+					}},
+				},
+				Spec: tinkv1.TemplateSpec{Data: ptr.To(data)},
+			}
+
+			client := kubernetesClientWithObjects(t, []runtime.Object{tm.DeepCopy(), template})
+			r := &machine.TemplateValidationReconciler{Client: client}
+
+			_, err := r.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: template.Name, Namespace: template.Namespace},
+			})
+			g.Expect(err).NotTo(HaveOccurred(), "Expected invalid Templates to be reported instead of retried")
+		})
+	}
+}
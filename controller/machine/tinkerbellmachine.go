@@ -22,9 +22,12 @@ import (
 	"fmt"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/component-base/featuregate"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/collections"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/predicates"
@@ -33,32 +36,139 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"go.opentelemetry.io/otel/attribute"
 
 	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
 	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
 
 	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/tracing"
 )
 
 // TinkerbellMachineReconciler implements Reconciler interface by managing Tinkerbell machines.
 type TinkerbellMachineReconciler struct {
 	client.Client
 	WatchFilterValue string
+
+	// BootstrapDataTTL is how long bootstrap data remains usable after its Secret was created. Machines are not
+	// provisioned with expired bootstrap data. Zero disables the check unless the bootstrap data Secret carries
+	// an explicit expiry annotation.
+	BootstrapDataTTL time.Duration
+
+	// BMCJobTTL is how long finished BMC Jobs are kept before they are removed. The newest Job of each
+	// operation is always kept. Zero disables the cleanup.
+	BMCJobTTL time.Duration
+
+	// BMCJobRetries is how often a BMC operation whose Jobs failed through all BMCs of the Hardware is retried
+	// before it is reported as failed. Zero disables retries.
+	BMCJobRetries int
+
+	// BMCJobRetryBackoff is how long a failed BMC operation waits before its first retry. The back-off doubles
+	// with every further retry, up to 10 minutes.
+	BMCJobRetryBackoff time.Duration
+
+	// NodeGate is what is required from the Node of a provisioned machine in the workload cluster before the
+	// TinkerbellMachine is marked as Ready. Defaults to NodeGateNone.
+	NodeGate NodeGate
+
+	// WorkloadClusterClient returns a client for a workload cluster, used to check the NodeGate and to drain the
+	// Nodes of reprovisioned machines. Defaults to the clients of a ClusterCacheTracker connecting through the
+	// <cluster>-kubeconfig Secret of the cluster, which only connects to clusters when they are first used.
+	WorkloadClusterClient WorkloadClusterClientFunc
+
+	// HardwareQuarantineThreshold is the number of consecutive provisioning failures, failed workflows or BMC
+	// Jobs, after which Hardware is labeled with HardwareQuarantinedLabel and no longer selected. Zero disables
+	// quarantining.
+	HardwareQuarantineThreshold int
+
+	// PropagatedLabels and PropagatedAnnotations are the keys of the labels and annotations copied from a
+	// TinkerbellMachine onto the Templates, Workflows and BMC Jobs created for it.
+	PropagatedLabels      []string
+	PropagatedAnnotations []string
+
+	// FinalActionGracePeriod is how long the final action of a workflow may run, once all other actions
+	// succeeded, before it is assumed to have succeeded. This is for actions like kexec which never report
+	// success. Zero waits for the workflow to succeed.
+	FinalActionGracePeriod time.Duration
+
+	// ImageChecker, when set, checks the OS image of a machine can be downloaded before any BMC Job or Workflow
+	// is created for it. See NewHTTPImageChecker.
+	ImageChecker ImageChecker
+
+	// HardwareLeaseDuration is how long claimed Hardware may go without provisioning progress before its
+	// provisioning lease, the HardwareLeaseAnnotation, expires. Zero disables leases.
+	HardwareLeaseDuration time.Duration
+
+	// WorkflowTerminationTimeout is how long the teardown of a deleted TinkerbellMachine waits for its running
+	// workflows to finish before its Hardware is released, so tink-worker is not left writing to the disk of
+	// Hardware claimed by another machine. Zero does not wait.
+	WorkflowTerminationTimeout time.Duration
+
+	// HookBoot configures the iPXE script booting the Hook OS which is served for machines setting kernel
+	// arguments.
+	HookBoot HookBootOptions
+
+	// BootstrapReportURL, when set, is the base URL under which the machines reach the BootstrapReportServer. The
+	// OS of machines bootstrapped with cloud-init is then configured to report their bootstrap result to it.
+	BootstrapReportURL string
+
+	// ConsoleCaptureImage, when set, is the image with ipmitool run by the Jobs capturing the serial console of
+	// machines with consoleCapture while they provision.
+	ConsoleCaptureImage string
+
+	// ConsoleLogReader reads the console output captured for machines whose provisioning failed. Console output
+	// is not saved without one.
+	ConsoleLogReader ConsoleLogReader
+
+	// APIReader reads the Jobs capturing the consoles of machines, so the controller does not need to cache all
+	// Jobs of the cluster. Defaults to the APIReader of the manager.
+	APIReader client.Reader
+
+	// ReleaseNotifier notifies the post-release hooks of machines. Defaults to NewHTTPReleaseNotifier with
+	// NewReleaseHookHTTPClient.
+	ReleaseNotifier ReleaseNotifier
+
+	// HardwarePoolAuthorizer authorizes TinkerbellMachines to select Hardware from HardwarePools. Defaults to
+	// NewSubjectAccessReviewPoolAuthorizer with the Client.
+	HardwarePoolAuthorizer HardwarePoolAuthorizer
+
+	// WatchNamespaces are the namespaces the cache of the manager is restricted to. HardwarePools selecting
+	// Hardware of other namespaces can not be used. Empty when all namespaces are watched.
+	WatchNamespaces []string
+
+	// FeatureGates enables features shipped disabled, see the feature package. Nil uses the default state of
+	// every gate.
+	FeatureGates featuregate.FeatureGate
+
+	// rateLimiter keeps deletions from being starved by failing creations. It is nil unless the
+	// controller was set up with the default rate limiter.
+	rateLimiter *operationRateLimiter
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachines/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=hardwarepools,verbs=get;list;watch
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
-// +kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=patch
+// +kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;patch
 // +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware;hardware/status,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=tinkerbell.org,resources=templates;templates/status,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=tinkerbell.org,resources=workflows;workflows/status,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=bmc.tinkerbell.org,resources=jobs,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=bmc.tinkerbell.org,resources=jobs,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=bmc.tinkerbell.org,resources=machines,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
 
 // Reconcile ensures that all Tinkerbell machines are aligned with a given spec.
 //
 //nolint:funlen,cyclop
-func (r *TinkerbellMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *TinkerbellMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
 	// If the TinkerbellMachineReconciler instant is invalid we can't continue. There's also no way
 	// for us to recover the TinkerbellMachineReconciler instance (i.e. there's a programmer error).
 	// To avoid continuously requeueing resources for the controller that will never resolve its
@@ -67,19 +177,51 @@ func (r *TinkerbellMachineReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		panic(err)
 	}
 
+	ctx, span := tracing.Start(ctx, "TinkerbellMachine.Reconcile",
+		attribute.String("namespace", req.Namespace),
+		attribute.String("name", req.Name),
+	)
+	defer func() { tracing.End(span, reterr) }()
+
 	log := ctrl.LoggerFrom(ctx)
-	log.Info("starting reconcile")
+	log.V(4).Info("starting reconcile") //nolint:gomnd
 
 	scope := &machineReconcileScope{
 		log:               log,
 		ctx:               ctx,
 		tinkerbellMachine: &infrastructurev1.TinkerbellMachine{},
 		client:            r.Client,
+		nodeGate:          r.NodeGate,
+
+		workloadClusterClient: r.WorkloadClusterClient,
+		quarantineThreshold:   r.HardwareQuarantineThreshold,
+		propagatedLabels:      r.PropagatedLabels,
+		propagatedAnnotations: r.PropagatedAnnotations,
+
+		finalActionGracePeriod: r.FinalActionGracePeriod,
+		imageChecker:           r.ImageChecker,
+		hardwareLeaseDuration:  r.HardwareLeaseDuration,
+		bmcJobRetries:          r.BMCJobRetries,
+		bmcJobRetryBackoff:     r.BMCJobRetryBackoff,
+
+		workflowTerminationTimeout: r.WorkflowTerminationTimeout,
+		hookBoot:                   r.HookBoot,
+		bootstrapReportURL:         r.BootstrapReportURL,
+		consoleCaptureImage:        r.ConsoleCaptureImage,
+		consoleLogReader:           r.ConsoleLogReader,
+		apiReader:                  r.APIReader,
+		releaseNotifier:            r.ReleaseNotifier,
+		featureGates:               r.FeatureGates,
+		hardwarePoolAuthorizer:     r.HardwarePoolAuthorizer,
+		watchNamespaces:            r.WatchNamespaces,
 	}
 
 	if err := r.Client.Get(ctx, req.NamespacedName, scope.tinkerbellMachine); err != nil {
 		if apierrors.IsNotFound(err) {
-			log.Info("TinkerbellMachine not found")
+			log.V(4).Info("TinkerbellMachine not found") //nolint:gomnd
+			r.rateLimiter.observe(req, false)
+			unsatisfiedDemand.satisfied(req.NamespacedName)
+			observeMachinePhase(req.NamespacedName, "")
 
 			return ctrl.Result{}, nil
 		}
@@ -94,8 +236,14 @@ func (r *TinkerbellMachineReconciler) Reconcile(ctx context.Context, req ctrl.Re
 
 	scope.patchHelper = patchHelper
 
+	r.rateLimiter.observe(req, scope.MachineScheduledForDeletion())
+
 	if scope.MachineScheduledForDeletion() {
-		return ctrl.Result{}, scope.DeleteMachineWithDependencies()
+		unsatisfiedDemand.satisfied(req.NamespacedName)
+
+		err := scope.DeleteMachineWithDependencies()
+
+		return ctrl.Result{RequeueAfter: scope.requeueAfter}, err
 	}
 
 	// We must be bound to a CAPI Machine object before we can continue.
@@ -108,9 +256,25 @@ func (r *TinkerbellMachineReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return ctrl.Result{}, nil
 	}
 
+	cluster, err := util.GetClusterFromMetadata(ctx, r.Client, machine.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("getting cluster from metadata: %w", err)
+	}
+
+	if annotations.IsPaused(cluster, scope.tinkerbellMachine) {
+		log.V(4).Info("Cluster or TinkerbellMachine is paused, skipping reconciliation") //nolint:gomnd
+
+		return ctrl.Result{}, nil
+	}
+
 	// We need a bootstrap cloud config secret to bootstrap the node so we can't proceed without it.
 	// Typically, this is something akin to cloud-init user-data.
-	bootstrapCloudConfig, err := scope.getReadyBootstrapCloudConfig(machine)
+	bootstrapSecret, err := scope.getBootstrapDataSecret(machine)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("receiving bootstrap cloud config: %w", err)
+	}
+
+	bootstrapCloudConfig, err := getReadyBootstrapCloudConfig(bootstrapSecret, scope.tinkerbellMachine.Spec.BootstrapDataKey)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("receiving bootstrap cloud config: %w", err)
 	}
@@ -121,22 +285,38 @@ func (r *TinkerbellMachineReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return ctrl.Result{RequeueAfter: requeueAfter}, nil
 	}
 
-	tinkerbellCluster, err := scope.getReadyTinkerbellCluster(machine)
+	tinkerbellCluster, err := scope.getReadyTinkerbellCluster(cluster)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("getting TinkerbellCluster: %w", err)
 	}
 
 	if tinkerbellCluster == nil {
-		log.Info("TinkerbellCluster is not ready yet")
+		log.V(4).Info("TinkerbellCluster is not ready yet") //nolint:gomnd
 
 		return ctrl.Result{}, nil
 	}
 
+	bootstrapDataExpiresAt, err := bootstrapDataExpiry(bootstrapSecret, r.BootstrapDataTTL)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("determining bootstrap data expiry: %w", err)
+	}
+
 	scope.machine = machine
 	scope.bootstrapCloudConfig = bootstrapCloudConfig
+	scope.bootstrapFormat = detectBootstrapFormat(bootstrapSecret, bootstrapCloudConfig)
+	scope.bootstrapSecretUID = bootstrapSecret.UID
+	scope.bootstrapDataExpiresAt = bootstrapDataExpiresAt
 	scope.tinkerbellCluster = tinkerbellCluster
 
-	return ctrl.Result{}, scope.Reconcile()
+	if err := scope.cleanupFinishedBMCJobs(r.BMCJobTTL); err != nil {
+		return ctrl.Result{}, fmt.Errorf("cleaning up finished BMCJobs: %w", err)
+	}
+
+	if err := scope.Reconcile(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: scope.requeueAfter}, nil
 }
 
 // SetupWithManager configures reconciler with a given manager.
@@ -147,6 +327,23 @@ func (r *TinkerbellMachineReconciler) SetupWithManager(
 ) error {
 	log := ctrl.LoggerFrom(ctx)
 
+	if err := r.NodeGate.Validate(); err != nil {
+		return err
+	}
+
+	if r.APIReader == nil {
+		r.APIReader = mgr.GetAPIReader()
+	}
+
+	if r.WorkloadClusterClient == nil {
+		workloadClusterClient, err := r.setupWorkloadClusterClient(ctx, mgr, options)
+		if err != nil {
+			return err
+		}
+
+		r.WorkloadClusterClient = workloadClusterClient
+	}
+
 	clusterToObjectFunc, err := util.ClusterToTypedObjectsMapper(
 		r.Client,
 		&infrastructurev1.TinkerbellMachineList{},
@@ -156,10 +353,15 @@ func (r *TinkerbellMachineReconciler) SetupWithManager(
 		return fmt.Errorf("failed to create mapper for Cluster to TinkrebellMachines: %w", err)
 	}
 
+	if options.RateLimiter == nil {
+		r.rateLimiter = newOperationRateLimiter()
+		options.RateLimiter = r.rateLimiter
+	}
+
 	builder := ctrl.NewControllerManagedBy(mgr).
 		WithOptions(options).
 		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(log, r.WatchFilterValue)).
-		For(&infrastructurev1.TinkerbellMachine{}).
+		For(&infrastructurev1.TinkerbellMachine{}, builder.WithPredicates(tinkerbellMachineChanged())).
 		Watches(
 			&clusterv1.Machine{},
 			handler.EnqueueRequestsFromMapFunc(
@@ -169,12 +371,26 @@ func (r *TinkerbellMachineReconciler) SetupWithManager(
 		Watches(
 			&infrastructurev1.TinkerbellCluster{},
 			handler.EnqueueRequestsFromMapFunc(r.TinkerbellClusterToTinkerbellMachines(ctx)),
+			builder.WithPredicates(tinkerbellClusterChanged()),
 		).
 		Watches(
 			&clusterv1.Cluster{},
 			handler.EnqueueRequestsFromMapFunc(clusterToObjectFunc),
 			builder.WithPredicates(predicates.ClusterUnpausedAndInfrastructureReady(log)),
 		).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.BootstrapSecretToTinkerbellMachines(ctx)),
+		).
+		Watches(
+			&tinkv1.Hardware{},
+			handler.EnqueueRequestsFromMapFunc(r.HardwareToWaitingTinkerbellMachines(ctx)),
+		).
+		Watches(
+			&tinkv1.Hardware{},
+			handler.EnqueueRequestsFromMapFunc(r.HardwareToOwnerTinkerbellMachine(ctx)),
+			builder.WithPredicates(predicate.Or(hardwareDecommissionChanged(), hardwarePausedChanged())),
+		).
 		Watches(
 			&tinkv1.Workflow{},
 			handler.EnqueueRequestForOwner(
@@ -192,6 +408,14 @@ func (r *TinkerbellMachineReconciler) SetupWithManager(
 				&infrastructurev1.TinkerbellMachine{},
 				handler.OnlyControllerOwner(),
 			),
+		).
+		Watches(
+			&tinkv1.Workflow{},
+			handler.EnqueueRequestsFromMapFunc(r.PooledObjectToTinkerbellMachine(ctx)),
+		).
+		Watches(
+			&rufiov1.Job{},
+			handler.EnqueueRequestsFromMapFunc(r.PooledObjectToTinkerbellMachine(ctx)),
 		)
 
 	if err := builder.Complete(r); err != nil {
@@ -272,5 +496,5 @@ func (r *TinkerbellMachineReconciler) validate() error {
 		return ErrMissingClient
 	}
 
-	return nil
+	return r.NodeGate.Validate()
 }
@@ -19,25 +19,36 @@ package machine_test
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
 	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/ptr"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/yaml"
 
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
 	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
 
 	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
 	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/feature"
 )
 
 const (
@@ -155,7 +166,8 @@ func validMachine(name, namespace, clusterName string) *clusterv1.Machine {
 			},
 		},
 		Spec: clusterv1.MachineSpec{
-			Version: ptr.To[string]("1.19.4"),
+			ClusterName: clusterName,
+			Version:     ptr.To[string]("1.19.4"),
 			Bootstrap: clusterv1.Bootstrap{
 				DataSecretName: ptr.To[string](name),
 			},
@@ -163,6 +175,26 @@ func validMachine(name, namespace, clusterName string) *clusterv1.Machine {
 	}
 }
 
+// validBMC returns a contactable rufio Machine with the given name and the Secret holding its credentials.
+func validBMC(name, namespace string) []runtime.Object {
+	return []runtime.Object{
+		&rufiov1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: rufiov1.MachineSpec{Connection: rufiov1.Connection{
+				Host:          "10.0.0.1",
+				AuthSecretRef: corev1.SecretReference{Name: name + "-auth", Namespace: namespace},
+			}},
+			Status: rufiov1.MachineStatus{Conditions: []rufiov1.MachineCondition{
+				{Type: rufiov1.Contactable, Status: rufiov1.ConditionTrue},
+			}},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-auth", Namespace: namespace},
+			Data:       map[string][]byte{"username": []byte("admin"), "password": []byte("secret")},
+		},
+	}
+}
+
 //nolint:unparam
 func validSecret(name, namespace string) *corev1.Secret {
 	return &corev1.Secret{
@@ -285,6 +317,9 @@ func kubernetesClientWithObjects(t *testing.T, objects []runtime.Object) client.
 	g.Expect(infrastructurev1.AddToScheme(scheme)).To(Succeed(), "Adding Tinkerbell CAPI objects to scheme should succeed")
 	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed(), "Adding CAPI objects to scheme should succeed")
 	g.Expect(corev1.AddToScheme(scheme)).To(Succeed(), "Adding Core V1 objects to scheme should succeed")
+	g.Expect(rufiov1.AddToScheme(scheme)).To(Succeed(), "Adding Rufio objects to scheme should succeed")
+	g.Expect(batchv1.AddToScheme(scheme)).To(Succeed(), "Adding Batch objects to scheme should succeed")
+	g.Expect(authorizationv1.AddToScheme(scheme)).To(Succeed(), "Adding Authorization objects to scheme should succeed")
 
 	objs := []client.Object{
 		&infrastructurev1.TinkerbellMachine{},
@@ -322,6 +357,17 @@ func Test_Machine_reconciliation_with_available_hardware(t *testing.T) {
 		Namespace: clusterNamespace,
 	}
 
+	t.Run("reports_running_workflow_in_ready_condition", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		tm := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, globalResourceName, tm)).To(Succeed())
+
+		g.Expect(conditions.IsFalse(tm, clusterv1.ReadyCondition)).To(BeTrue(), "Expected Ready condition to be false")
+		g.Expect(conditions.GetReason(tm, clusterv1.ReadyCondition)).To(Equal(infrastructurev1.WorkflowRunningReason))
+	})
+
 	t.Run("creates_template", func(t *testing.T) {
 		t.Parallel()
 		g := NewWithT(t)
@@ -514,6 +560,27 @@ func Test_Machine_reconciliation_workflow_complete(t *testing.T) {
 		g.Expect(updatedMachine.Status.Ready).To(BeTrue(), "Machine is not ready")
 	})
 
+	t.Run("sets_ready_condition", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(conditions.IsTrue(updatedMachine, clusterv1.ReadyCondition)).To(BeTrue(),
+			"Expected Ready condition to be true")
+	})
+
+	t.Run("records_provisioning_phases", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		phases := updatedMachine.Status.Phases
+		g.Expect(phases).NotTo(BeNil(), "Expected provisioning phases to be recorded")
+		g.Expect(phases.HardwareSelectedAt).NotTo(BeNil())
+		g.Expect(phases.WorkflowStartedAt).NotTo(BeNil())
+		g.Expect(phases.WorkflowCompletedAt).NotTo(BeNil())
+		g.Expect(phases.ReadyAt).NotTo(BeNil())
+		g.Expect(phases.WorkflowCreatedAt).To(BeNil(), "Workflow was not created by this reconciliation")
+	})
+
 	// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#normal-resource.
 	t.Run("sets_tinkerbell_finalizer", func(t *testing.T) {
 		t.Parallel()
@@ -533,145 +600,2471 @@ func Test_Machine_reconciliation_workflow_complete(t *testing.T) {
 			"Expected first IP address to be %q", hardwareIP)
 	})
 
-	// So it becomes unavailable for other clusters.
-	t.Run("sets_ownership_label_on_selected_hardware", func(t *testing.T) {
-		t.Parallel()
-		g := NewWithT(t)
-
-		hardwareNamespacedName := types.NamespacedName{
-			Name:      hardwareName,
-			Namespace: clusterNamespace,
-		}
+	// So it becomes unavailable for other clusters.
+	t.Run("sets_ownership_label_on_selected_hardware", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		hardwareNamespacedName := types.NamespacedName{
+			Name:      hardwareName,
+			Namespace: clusterNamespace,
+		}
+
+		updatedHardware := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+
+		g.Expect(updatedHardware.ObjectMeta.Labels).To(
+			HaveKeyWithValue(machine.HardwareOwnerNameLabel, tinkerbellMachineName),
+			"Expected owner name label to be set on Hardware")
+
+		g.Expect(updatedHardware.ObjectMeta.Labels).To(
+			HaveKeyWithValue(machine.HardwareOwnerNamespaceLabel, clusterNamespace),
+			"Expected owner namespace label to be set on Hardware")
+	})
+
+	// Ensure idempotency of reconcile operation. E.g. we shouldn't try to create the template with the same name
+	// on every iteration.
+	t.Run("succeeds_when_executed_twice", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred(), "Unexpected reconciliation error")
+	})
+
+	// Status should be updated on every run.
+	//
+	// Don't execute this test in parallel, as we reset status here.
+	t.Run("refreshes_status_when_machine_is_already_provisioned", func(t *testing.T) { //nolint:paralleltest
+		updatedMachine.Status.Addresses = nil
+		g := NewWithT(t)
+
+		g.Expect(client.Update(context.Background(), updatedMachine)).To(Succeed())
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		updatedMachine = &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, namespacedName, updatedMachine)).To(Succeed())
+		g.Expect(updatedMachine.Status.Addresses).NotTo(BeEmpty(), "Machine status should be updated on every reconciliation")
+	})
+}
+
+func Test_Machine_reconciliation_workflow_failed(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+
+	failedWorkflow := validWorkflow(tinkerbellMachineName, clusterNamespace)
+	failedWorkflow.Status.State = tinkv1.WorkflowStateFailed
+	failedWorkflow.Status.Tasks[0].Actions = []tinkv1.Action{
+		{Name: "disk-wipe", Status: tinkv1.WorkflowStateSuccess},
+		{Name: "stream-image", Status: tinkv1.WorkflowStateFailed, Message: "pulling image\nno space left on device"},
+	}
+
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+		validTemplate(tinkerbellMachineName, clusterNamespace),
+		failedWorkflow,
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(ContainSubstring("stream-image")), "Expected reconciliation to fail with the failing action")
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	namespacedName := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+	g.Expect(client.Get(context.Background(), namespacedName, updatedMachine)).To(Succeed())
+
+	g.Expect(updatedMachine.Status.Ready).To(BeFalse())
+
+	condition := conditions.Get(updatedMachine, infrastructurev1.WorkflowSucceededCondition)
+	g.Expect(condition).NotTo(BeNil(), "Expected WorkflowSucceeded condition to be set")
+	g.Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(condition.Reason).To(Equal(infrastructurev1.WorkflowFailedReason))
+	g.Expect(condition.Message).To(ContainSubstring(`action "stream-image"`))
+	g.Expect(condition.Message).To(ContainSubstring("no space left on device"))
+
+	ready := conditions.Get(updatedMachine, clusterv1.ReadyCondition)
+	g.Expect(ready).NotTo(BeNil(), "Expected Ready condition to be set")
+	g.Expect(ready.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(ready.Reason).To(Equal(infrastructurev1.WorkflowFailedReason), "Expected Ready to summarize the failure")
+	g.Expect(ready.Severity).To(Equal(clusterv1.ConditionSeverityError))
+}
+
+func Test_Machine_reconciliation_with_pinned_worker_MAC(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.Interfaces[0].DHCP.MAC = "00:00:00:00:00:01"
+	hw.Spec.Interfaces = append(hw.Spec.Interfaces, tinkv1.Interface{
+		DHCP:    &tinkv1.DHCP{MAC: "00:00:00:00:00:02"},
+		Netboot: &tinkv1.Netboot{AllowPXE: ptr.To(false)},
+	})
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.WorkerDevice = infrastructurev1.WorkerDevice{
+		Source: infrastructurev1.WorkerDeviceSourceMAC,
+		MAC:    "00:00:00:00:00:02",
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	wf := &tinkv1.Workflow{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, wf)).
+		To(Succeed())
+	g.Expect(wf.Spec.HardwareMap).To(HaveKeyWithValue("device_1", "00:00:00:00:00:02"))
+
+	updated := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}, updated)).
+		To(Succeed())
+	g.Expect(updated.Annotations).To(HaveKeyWithValue(machine.HardwareNetbootInterfacesAnnotation,
+		"00:00:00:00:00:02"), "Expected only the pinned interface to be netbooted")
+}
+
+func Test_Machine_reconciliation_with_pinned_worker_MAC_selects_hardware_with_the_interface(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	other := validHardware("a-"+hardwareName, uuid.New().String(), "10.0.0.2")
+	other.Spec.Interfaces[0].DHCP.MAC = "00:00:00:00:00:03"
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.Interfaces[0].DHCP.MAC = "00:00:00:00:00:02"
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.WorkerDevice = infrastructurev1.WorkerDevice{
+		Source: infrastructurev1.WorkerDeviceSourceMAC,
+		MAC:    "00:00:00:00:00:02",
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		other,
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updated := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(context.Background(),
+		types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, updated)).To(Succeed())
+	g.Expect(updated.Spec.HardwareName).To(Equal(hardwareName),
+		"Expected the Hardware with the pinned interface to be selected")
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_manages_netboot_of_tracked_interfaces_only(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Annotations = map[string]string{machine.HardwareNetbootInterfacesAnnotation: "00:00:00:00:00:01"}
+	hw.Spec.Interfaces[0].DHCP.MAC = "00:00:00:00:00:01"
+	hw.Spec.Interfaces[0].Netboot.AllowPXE = ptr.To(false)
+	hw.Spec.Interfaces = append(hw.Spec.Interfaces, tinkv1.Interface{
+		DHCP:    &tinkv1.DHCP{MAC: "00:00:00:00:00:02"},
+		Netboot: &tinkv1.Netboot{AllowPXE: ptr.To(false)},
+	})
+
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+	ctx := context.Background()
+	key := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+
+	allowPXE := func() []bool {
+		updated := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, key, updated)).To(Succeed())
+
+		var out []bool
+		for _, iface := range updated.Spec.Interfaces {
+			out = append(out, *iface.Netboot.AllowPXE)
+		}
+
+		return out
+	}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowPXE()).To(Equal([]bool{true, false}), "Expected netboot to be allowed on tracked interfaces only")
+
+	wf := &tinkv1.Workflow{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, wf)).To(Succeed())
+	g.Expect(wf.Spec.BootOptions.ToggleAllowNetboot).To(BeFalse(), "Tinkerbell should not toggle netboot on all interfaces")
+
+	wf.Status.State = tinkv1.WorkflowStateSuccess
+	g.Expect(client.Update(ctx, wf)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowPXE()).To(Equal([]bool{false, false}), "Expected netboot to be disallowed after provisioning")
+
+	// Simulate external changes to both interfaces.
+	updated := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, key, updated)).To(Succeed())
+	updated.Spec.Interfaces[0].Netboot.AllowPXE = ptr.To(true)
+	updated.Spec.Interfaces[1].Netboot.AllowPXE = ptr.To(true)
+	g.Expect(client.Update(ctx, updated)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowPXE()).To(Equal([]bool{false, true}), "Expected drift to be corrected on tracked interfaces only")
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_boots_from_virtual_media(t *testing.T) {
+	t.Parallel()
+
+	isoMachine := func(hardwareUUID string) *infrastructurev1.TinkerbellMachine {
+		tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+		tm.Spec.BootOptions = infrastructurev1.BootOptions{
+			BootMode: infrastructurev1.BootModeISO,
+			ISOURL:   "http://10.1.1.1:7171/iso/hook.iso",
+		}
+
+		return tm
+	}
+
+	t.Run("requires_hardware_with_bmc", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		hardwareUUID := uuid.New().String()
+		client := kubernetesClientWithObjects(t, []runtime.Object{
+			isoMachine(hardwareUUID),
+			validCluster(clusterName, clusterNamespace),
+			validTinkerbellCluster(clusterName, clusterNamespace),
+			validHardware(hardwareName, hardwareUUID, hardwareIP),
+			validMachine(machineName, clusterNamespace, clusterName),
+			validSecret(machineName, clusterNamespace),
+		})
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).To(MatchError(ContainSubstring("bmcRef")))
+	})
+
+	t.Run("never_allows_pxe_and_ejects_media_once_provisioned", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		hardwareUUID := uuid.New().String()
+		hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+		hw.Annotations = map[string]string{machine.HardwareNetbootInterfacesAnnotation: "00:00:00:00:00:01"}
+		hw.Spec.BMCRef = &corev1.TypedLocalObjectReference{Name: "bmc"}
+		hw.Spec.Interfaces[0].DHCP.MAC = "00:00:00:00:00:01"
+		hw.Spec.Metadata.Instance.ID = "00:00:00:00:00:01"
+
+		client := kubernetesClientWithObjects(t, append([]runtime.Object{
+			isoMachine(hardwareUUID),
+			validCluster(clusterName, clusterNamespace),
+			validTinkerbellCluster(clusterName, clusterNamespace),
+			hw,
+			validMachine(machineName, clusterNamespace, clusterName),
+			validSecret(machineName, clusterNamespace),
+		}, validBMC("bmc", clusterNamespace)...))
+		ctx := context.Background()
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		updatedHardware := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}, updatedHardware)).
+			To(Succeed())
+		g.Expect(*updatedHardware.Spec.Interfaces[0].Netboot.AllowPXE).To(BeFalse(), "Expected PXE to be disallowed")
+
+		wf := &tinkv1.Workflow{}
+		g.Expect(client.Get(ctx, types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, wf)).
+			To(Succeed())
+		g.Expect(wf.Spec.BootOptions.BootMode).To(Equal(tinkv1.BootModeISO))
+		g.Expect(wf.Spec.BootOptions.ISOURL).To(Equal("http://10.1.1.1:7171/iso/00-00-00-00-00-01/hook.iso"))
+		g.Expect(wf.Spec.BootOptions.ToggleAllowNetboot).To(BeFalse())
+
+		wf.Status.State = tinkv1.WorkflowStateSuccess
+		g.Expect(client.Update(ctx, wf)).To(Succeed())
+
+		_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		jobs := &rufiov1.JobList{}
+		g.Expect(client.List(ctx, jobs)).To(Succeed())
+		g.Expect(jobs.Items).To(HaveLen(1), "Expected a BMC Job ejecting the ISO")
+		g.Expect(jobs.Items[0].Labels).To(HaveKeyWithValue(machine.BMCJobOperationLabel, "eject-media"))
+		g.Expect(jobs.Items[0].Spec.Tasks[0].VirtualMediaAction).NotTo(BeNil())
+		g.Expect(jobs.Items[0].Spec.Tasks[0].VirtualMediaAction.MediaURL).To(BeEmpty())
+	})
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_creates_windows_user_of_bmc_credentials(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.Image.OSFamily = infrastructurev1.OSFamilyWindows
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.BMCRef = &corev1.TypedLocalObjectReference{Name: "bmc"}
+
+	client := kubernetesClientWithObjects(t, append([]runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}, validBMC("bmc", clusterNamespace)...))
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	template := &tinkv1.Template{}
+	templateKey := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+	g.Expect(client.Get(context.Background(), templateKey, template)).To(Succeed())
+	g.Expect(*template.Spec.Data).To(ContainSubstring("username=admin\n"))
+}
+
+func Test_Machine_reconciliation_with_persistent_netboot(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	const script = "http://10.1.1.1/ephemeral.ipxe"
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.BootOptions.PersistentNetboot = &infrastructurev1.PersistentNetboot{IPXEScriptURL: script}
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.Disks = nil
+	hw.Spec.BMCRef = &corev1.TypedLocalObjectReference{Name: "bmc"}
+	hw.Spec.Interfaces[0].DHCP.MAC = "00:00:00:00:00:01"
+	hw.Spec.Interfaces[0].Netboot = nil
+
+	client := kubernetesClientWithObjects(t, append([]runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}, validBMC("bmc", clusterNamespace)...))
+	ctx := context.Background()
+	hardwareKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+	machineKey := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Expected Hardware without disks to be selected")
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, hardwareKey, updatedHardware)).To(Succeed())
+	g.Expect(*updatedHardware.Spec.Interfaces[0].Netboot.AllowPXE).To(BeTrue())
+	g.Expect(updatedHardware.Spec.Interfaces[0].Netboot.IPXE.URL).To(Equal(script))
+	g.Expect(updatedHardware.Spec.UserData).NotTo(BeNil())
+
+	g.Expect(client.Get(ctx, machineKey, &tinkv1.Template{})).NotTo(Succeed(), "Expected no Template")
+	g.Expect(client.Get(ctx, machineKey, &tinkv1.Workflow{})).NotTo(Succeed(), "Expected no Workflow")
+
+	jobs := &rufiov1.JobList{}
+	g.Expect(client.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(1), "Expected a BMC Job netbooting the Hardware")
+	g.Expect(jobs.Items[0].Labels).To(HaveKeyWithValue(machine.BMCJobOperationLabel, "netboot"))
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, machineKey, updatedMachine)).To(Succeed())
+	g.Expect(updatedMachine.Status.Ready).To(BeFalse(), "Expected machine to wait for the BMC Job")
+
+	job := &jobs.Items[0]
+	job.SetCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue)
+	g.Expect(client.Update(ctx, job)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, machineKey, updatedMachine)).To(Succeed())
+	g.Expect(updatedMachine.Status.Ready).To(BeTrue())
+
+	g.Expect(client.Get(ctx, hardwareKey, updatedHardware)).To(Succeed())
+	g.Expect(updatedHardware.Annotations).To(HaveKeyWithValue(machine.HardwareProvisionedAnnotation, "true"))
+	g.Expect(*updatedHardware.Spec.Interfaces[0].Netboot.AllowPXE).To(BeTrue(), "Expected PXE to stay allowed")
+
+	g.Expect(client.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(1), "Expected the Hardware to be netbooted only once")
+}
+
+func Test_Machine_reconciliation_with_kernel_args(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.BootOptions.KernelArgs = []string{"console=ttyS0,115200", "intel_iommu=on"}
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.Interfaces[0].DHCP.MAC = "00:00:00:00:00:01"
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	hardwareKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, hardwareKey, updatedHardware)).To(Succeed())
+	g.Expect(*updatedHardware.Spec.Interfaces[0].Netboot.AllowPXE).To(BeTrue())
+	g.Expect(updatedHardware.Spec.Interfaces[0].Netboot.IPXE).NotTo(BeNil(), "Expected a Hook iPXE script")
+
+	script := updatedHardware.Spec.Interfaces[0].Netboot.IPXE.Contents
+	g.Expect(script).To(HavePrefix("#!ipxe\n"))
+	g.Expect(script).To(ContainSubstring(" console=ttyS0,115200 intel_iommu=on\n"))
+	g.Expect(script).To(ContainSubstring(" worker_id=" + hardwareIP + " hw_addr=00:00:00:00:00:01 "))
+	g.Expect(script).To(ContainSubstring(" tink_worker_image=quay.io/tinkerbell/tink-worker:v0.12.2 "))
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	machineKey := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+	g.Expect(client.Get(ctx, machineKey, updatedMachine)).To(Succeed())
+	g.Expect(client.Delete(ctx, updatedMachine)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, hardwareKey, updatedHardware)).To(Succeed())
+	g.Expect(updatedHardware.Spec.Interfaces[0].Netboot.IPXE).To(BeNil(),
+		"Expected the Hook iPXE script to be removed on release")
+}
+
+func Test_Machine_reconciliation_with_kernel_args_uses_endpoints_of_machine_and_cluster(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.BootOptions.KernelArgs = []string{"console=ttyS0,115200"}
+	tm.Spec.Endpoints = &infrastructurev1.ProvisioningEndpoints{SyslogHost: "198.51.100.20"}
+
+	tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkerbellCluster.Spec.Endpoints = &infrastructurev1.ProvisioningEndpoints{
+		HookURL:           "http://203.0.113.10:8080/",
+		TinkServerAddress: "203.0.113.10:42113",
+		SyslogHost:        "203.0.113.10",
+	}
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.Interfaces[0].DHCP.MAC = "00:00:00:00:00:01"
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		tinkerbellCluster,
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(context.Background(),
+		types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}, updatedHardware)).To(Succeed())
+
+	script := updatedHardware.Spec.Interfaces[0].Netboot.IPXE.Contents
+	g.Expect(script).To(ContainSubstring("kernel http://203.0.113.10:8080/vmlinuz-${arch} "))
+	g.Expect(script).To(ContainSubstring(" grpc_authority=203.0.113.10:42113 "))
+	g.Expect(script).To(ContainSubstring(" syslog_host=198.51.100.20 "), "Expected the machine to override its cluster")
+}
+
+func Test_Machine_reconciliation_with_kernel_args_refuses_ipxe_script_of_hardware(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.BootOptions.KernelArgs = []string{"console=ttyS0,115200"}
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.Interfaces[0].DHCP.MAC = "00:00:00:00:00:01"
+	hw.Spec.Interfaces[0].Netboot.IPXE = &tinkv1.IPXE{URL: "http://10.1.1.1/custom.ipxe"}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(machine.ErrIPXEScriptConflict))
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(context.Background(),
+		types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}, updatedHardware)).To(Succeed())
+	g.Expect(updatedHardware.Spec.Interfaces[0].Netboot.IPXE).To(Equal(hw.Spec.Interfaces[0].Netboot.IPXE),
+		"Expected the iPXE script of the Hardware to be kept")
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_with_hardware_pool(t *testing.T) {
+	t.Parallel()
+
+	const poolNamespace = "hardware-pool"
+
+	hardwareUUID := uuid.New().String()
+
+	pooledHardware := func() *tinkv1.Hardware {
+		hw := validHardware(hardwareName, hardwareUUID, hardwareIP, testOptions{Labels: map[string]string{"rack": "a"}})
+		hw.Namespace = poolNamespace
+
+		return hw
+	}
+
+	pool := func() *infrastructurev1.HardwarePool {
+		return &infrastructurev1.HardwarePool{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared"},
+			Spec: infrastructurev1.HardwarePoolSpec{
+				Sources: []infrastructurev1.HardwarePoolSource{{
+					Namespace: poolNamespace,
+					Selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"rack": "a"}},
+				}},
+			},
+		}
+	}
+
+	// reconcile reconciles the machine, with the service accounts of the given namespace allowed to use the pool.
+	reconcile := func(c client.Client, allowedNamespace string, watchNamespaces ...string) error {
+		r := &machine.TinkerbellMachineReconciler{
+			Client: c,
+			HardwarePoolAuthorizer: func(_ context.Context, pool, namespace string) (bool, error) {
+				return pool == "shared" && namespace == allowedNamespace, nil
+			},
+			WatchNamespaces: watchNamespaces,
+		}
+
+		_, err := r.Reconcile(context.Background(), ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+		})
+
+		return err
+	}
+
+	objects := func(hwPool *infrastructurev1.HardwarePool) []runtime.Object {
+		tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+		tm.Spec.HardwarePool = hwPool.Name
+
+		return []runtime.Object{
+			tm,
+			hwPool,
+			validCluster(clusterName, clusterNamespace),
+			validTinkerbellCluster(clusterName, clusterNamespace),
+			pooledHardware(),
+			validMachine(machineName, clusterNamespace, clusterName),
+			validSecret(machineName, clusterNamespace),
+		}
+	}
+
+	t.Run("selects_hardware_of_the_pool", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+		ctx := context.Background()
+
+		client := kubernetesClientWithObjects(t, objects(pool()))
+
+		g.Expect(reconcile(client, clusterNamespace)).To(Succeed())
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		machineKey := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+		g.Expect(client.Get(ctx, machineKey, updatedMachine)).To(Succeed())
+		g.Expect(updatedMachine.Spec.ProviderID).To(Equal(fmt.Sprintf("tinkerbell://%s/%s", poolNamespace, hardwareName)))
+
+		updatedHardware := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: poolNamespace}, updatedHardware)).To(Succeed())
+		g.Expect(updatedHardware.Labels).To(HaveKeyWithValue(machine.HardwareOwnerNamespaceLabel, clusterNamespace))
+
+		// Objects of machines of several namespaces share the namespace of the pool, so their names are hashed.
+		g.Expect(updatedMachine.Status.WorkflowName).To(HavePrefix(clusterNamespace + "-" + tinkerbellMachineName + "-"))
+		g.Expect(updatedMachine.Status.TemplateName).To(Equal(updatedMachine.Status.WorkflowName))
+
+		key := types.NamespacedName{Name: updatedMachine.Status.WorkflowName, Namespace: poolNamespace}
+
+		template := &tinkv1.Template{}
+		g.Expect(client.Get(ctx, key, template)).To(Succeed())
+		g.Expect(template.OwnerReferences).To(BeEmpty(), "Expected no owner reference across namespaces")
+		g.Expect(template.Labels).To(HaveKeyWithValue(machine.OwnerUIDLabel, string(updatedMachine.UID)))
+
+		workflow := &tinkv1.Workflow{}
+		g.Expect(client.Get(ctx, key, workflow)).To(Succeed())
+		g.Expect(workflow.Spec.HardwareRef).To(Equal(hardwareName))
+		g.Expect(workflow.Spec.TemplateRef).To(Equal(key.Name))
+		g.Expect(workflow.OwnerReferences).To(BeEmpty(), "Expected no owner reference across namespaces")
+
+		// Selecting again must keep the Hardware once the Template exists.
+		g.Expect(reconcile(client, clusterNamespace)).To(Succeed())
+
+		r := &machine.TinkerbellMachineReconciler{Client: client}
+		g.Expect(r.PooledObjectToTinkerbellMachine(ctx)(ctx, workflow)).
+			To(ConsistOf(ctrl.Request{NamespacedName: machineKey}), "Expected the Workflow to be mapped to its machine")
+	})
+
+	t.Run("fails_when_namespace_is_not_allowed_to_use_the_pool", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client := kubernetesClientWithObjects(t, objects(pool()))
+
+		g.Expect(reconcile(client, "other")).To(MatchError(machine.ErrHardwarePoolUnavailable))
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(context.Background(),
+			types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, updatedMachine)).To(Succeed())
+		g.Expect(updatedMachine.Spec.HardwareName).To(BeEmpty())
+		g.Expect(conditions.GetReason(updatedMachine, infrastructurev1.HardwareClaimedCondition)).
+			To(Equal(infrastructurev1.HardwarePoolUnavailableReason))
+	})
+
+	t.Run("fails_when_pool_selects_hardware_of_unwatched_namespaces", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client := kubernetesClientWithObjects(t, objects(pool()))
+
+		err := reconcile(client, clusterNamespace, clusterNamespace)
+		g.Expect(err).To(MatchError(machine.ErrHardwarePoolUnavailable))
+		g.Expect(err).To(MatchError(ContainSubstring("not watched")))
+	})
+}
+
+func Test_NewSubjectAccessReviewPoolAuthorizer(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	var reviewed *authorizationv1.SubjectAccessReview
+
+	fakeClient := kubernetesClientWithObjects(t, nil).(client.WithWatch) //nolint:forcetypeassert
+
+	c := interceptor.NewClient(fakeClient, interceptor.Funcs{
+		Create: func(_ context.Context, _ client.WithWatch, obj client.Object, _ ...client.CreateOption) error {
+			reviewed, _ = obj.(*authorizationv1.SubjectAccessReview)
+			reviewed.Status.Allowed = slices.Contains(reviewed.Spec.Groups, "system:serviceaccounts:team-a")
+
+			return nil
+		},
+	})
+
+	authorize := machine.NewSubjectAccessReviewPoolAuthorizer(c)
+
+	allowed, err := authorize(context.Background(), "shared", "team-a")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowed).To(BeTrue())
+	g.Expect(reviewed.Spec.ResourceAttributes).To(Equal(&authorizationv1.ResourceAttributes{
+		Group: infrastructurev1.GroupVersion.Group, Resource: "hardwarepools", Name: "shared", Verb: "use",
+	}))
+
+	allowed, err = authorize(context.Background(), "shared", "team-b")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowed).To(BeFalse())
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_runs_workflow_stages_in_order(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.WorkflowStages = []infrastructurev1.WorkflowStage{
+		{Name: "firmware", Template: "name: firmware"},
+		{Name: "burn-in", Template: "name: burn-in"},
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+
+	workflow := func(name string) (*tinkv1.Workflow, error) {
+		wf := &tinkv1.Workflow{}
+
+		return wf, client.Get(ctx, types.NamespacedName{Name: name, Namespace: clusterNamespace}, wf)
+	}
+
+	stagesCondition := func() *clusterv1.Condition {
+		updated := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, updated)).
+			To(Succeed())
+
+		return conditions.Get(updated, infrastructurev1.WorkflowStagesSucceededCondition)
+	}
+
+	completeStage := func(name string, state tinkv1.WorkflowState) {
+		wf, err := workflow(name)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		wf.Status.State = state
+		g.Expect(client.Update(ctx, wf)).To(Succeed())
+	}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	firmware, err := workflow(tinkerbellMachineName + "-firmware")
+	g.Expect(err).NotTo(HaveOccurred(), "Expected the workflow of the first stage to be created")
+	g.Expect(firmware.Spec.TemplateRef).To(Equal(tinkerbellMachineName + "-firmware"))
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: firmware.Spec.TemplateRef, Namespace: clusterNamespace}, template)).
+		To(Succeed())
+	g.Expect(*template.Spec.Data).To(Equal("name: firmware"))
+
+	_, err = workflow(tinkerbellMachineName + "-burn-in")
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the second stage to wait for the first one")
+	g.Expect(stagesCondition().Reason).To(Equal(infrastructurev1.WorkflowStageRunningReason))
+
+	completeStage(tinkerbellMachineName+"-firmware", tinkv1.WorkflowStateSuccess)
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = workflow(tinkerbellMachineName + "-burn-in")
+	g.Expect(err).NotTo(HaveOccurred(), "Expected the second stage to start once the first one succeeded")
+
+	_, err = workflow(tinkerbellMachineName)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the OS installation to wait for all stages")
+
+	completeStage(tinkerbellMachineName+"-burn-in", tinkv1.WorkflowStateFailed)
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(ContainSubstring("stage burn-in")))
+	g.Expect(stagesCondition().Reason).To(Equal(infrastructurev1.WorkflowStageFailedReason))
+
+	completeStage(tinkerbellMachineName+"-burn-in", tinkv1.WorkflowStateSuccess)
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = workflow(tinkerbellMachineName)
+	g.Expect(err).NotTo(HaveOccurred(), "Expected the OS installation to start once all stages succeeded")
+	g.Expect(stagesCondition().Status).To(Equal(corev1.ConditionTrue))
+}
+
+func Test_Machine_reconciliation_bounds_workflow_stage_names_of_long_machine_names(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	name := strings.Repeat("m", 70)
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(name, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.WorkflowStages = []infrastructurev1.WorkflowStage{{Name: "firmware", Template: "name: firmware"}}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, name, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	workflows := &tinkv1.WorkflowList{}
+	g.Expect(client.List(context.Background(), workflows)).To(Succeed())
+	g.Expect(workflows.Items).To(HaveLen(1), "Expected the workflow of the stage to be created")
+	g.Expect(len(workflows.Items[0].Name)).To(BeNumerically("<=", 63))
+	g.Expect(workflows.Items[0].Spec.TemplateRef).To(Equal(workflows.Items[0].Name))
+}
+
+func Test_Machine_reconciliation_waits_for_provisioning_slot(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkerbellCluster.Spec.MaxConcurrentProvisioning = 1
+
+	otherWorkflow := validWorkflow("other-machine", clusterNamespace)
+	otherWorkflow.Labels = map[string]string{clusterv1.ClusterNameLabel: clusterName}
+	otherWorkflow.Status.State = tinkv1.WorkflowStateRunning
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		tinkerbellCluster,
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+		otherWorkflow,
+	})
+	ctx := context.Background()
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	result, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected the machine to be requeued while waiting")
+
+	g.Expect(apierrors.IsNotFound(client.Get(ctx, key, &tinkv1.Workflow{}))).To(BeTrue(),
+		"Expected no workflow to be created while the cluster has no free provisioning slot")
+
+	updated := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, key, updated)).To(Succeed())
+
+	condition := conditions.Get(updated, infrastructurev1.ProvisioningSlotAcquiredCondition)
+	g.Expect(condition).NotTo(BeNil())
+	g.Expect(condition.Reason).To(Equal(infrastructurev1.WaitingForProvisioningSlotReason))
+
+	otherWorkflow.Status.State = tinkv1.WorkflowStateSuccess
+	g.Expect(client.Update(ctx, otherWorkflow)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	created := &tinkv1.Workflow{}
+	g.Expect(client.Get(ctx, key, created)).To(Succeed(), "Expected the workflow to be created once a slot is free")
+	g.Expect(created.Labels).To(HaveKeyWithValue(clusterv1.ClusterNameLabel, clusterName))
+}
+
+func Test_Machine_reconciliation_counts_unlabeled_workflows_against_provisioning_slots(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkerbellCluster.Spec.MaxConcurrentProvisioning = 1
+
+	otherMachine := validTinkerbellMachine("other-machine", clusterNamespace, "other-machine", uuid.New().String(),
+		testOptions{Labels: map[string]string{clusterv1.ClusterNameLabel: clusterName}})
+
+	// Workflows created before the upgrade to a release labeling them are only owned by their TinkerbellMachine.
+	otherWorkflow := validWorkflow("other-machine", clusterNamespace)
+	otherWorkflow.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: infrastructurev1.GroupVersion.String(),
+		Kind:       "TinkerbellMachine",
+		Name:       otherMachine.Name,
+		UID:        otherMachine.UID,
+	}}
+	otherWorkflow.Status.State = tinkv1.WorkflowStateRunning
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		otherMachine,
+		validCluster(clusterName, clusterNamespace),
+		tinkerbellCluster,
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+		otherWorkflow,
+	})
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(apierrors.IsNotFound(client.Get(context.Background(), key, &tinkv1.Workflow{}))).To(BeTrue(),
+		"Expected the unlabeled workflow of the cluster to take the only provisioning slot")
+}
+
+func Test_Machine_reconciliation_defers_provisioning_during_maintenance_window(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkerbellCluster.Spec.MaintenanceWindows = []infrastructurev1.MaintenanceWindow{
+		{Schedule: "* * * * *", Duration: metav1.Duration{Duration: time.Hour}},
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		tinkerbellCluster,
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	result, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute),
+		"Expected the machine to be requeued at the end of the window")
+
+	g.Expect(apierrors.IsNotFound(client.Get(ctx, key, &tinkv1.Workflow{}))).To(BeTrue(),
+		"Expected no workflow to be created during the maintenance window")
+
+	updated := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, key, updated)).To(Succeed())
+	g.Expect(conditions.GetReason(updated, infrastructurev1.ProvisioningSlotAcquiredCondition)).
+		To(Equal(infrastructurev1.ProvisioningDeferredReason))
+
+	clusterKey := types.NamespacedName{Name: clusterName, Namespace: clusterNamespace}
+	g.Expect(client.Get(ctx, clusterKey, tinkerbellCluster)).To(Succeed())
+	tinkerbellCluster.Spec.MaintenanceWindows = nil
+	g.Expect(client.Update(ctx, tinkerbellCluster)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, key, &tinkv1.Workflow{})).To(Succeed(), "Expected the workflow once the window ended")
+	g.Expect(client.Get(ctx, key, updated)).To(Succeed())
+	g.Expect(conditions.IsTrue(updated, infrastructurev1.ProvisioningSlotAcquiredCondition)).To(BeTrue())
+}
+
+func Test_Machine_reconciliation_with_drifted_bootstrap_data(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.BootstrapDataDriftPolicy = infrastructurev1.BootstrapDataDriftPolicyRemediate
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP, testOptions{
+		Labels: map[string]string{
+			machine.HardwareOwnerNameLabel:      tinkerbellMachineName,
+			machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+		},
+	})
+	hw.Annotations = map[string]string{machine.HardwareProvisionedAnnotation: "true"}
+	hw.Spec.UserData = ptr.To("outdated")
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+
+	gates := feature.NewGates()
+	g.Expect(gates.SetFromMap(map[string]bool{string(feature.ReprovisionOnUserDataChange): true})).To(Succeed())
+
+	r := &machine.TinkerbellMachineReconciler{Client: client, FeatureGates: gates}
+	_, err := r.Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}, updatedHardware)).
+		To(Succeed())
+	g.Expect(*updatedHardware.Spec.UserData).NotTo(Equal("outdated"), "Expected user-data to be updated")
+
+	updatedMachine := &clusterv1.Machine{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: machineName, Namespace: clusterNamespace}, updatedMachine)).
+		To(Succeed())
+	g.Expect(updatedMachine.Annotations).To(HaveKey(clusterv1.RemediateMachineAnnotation),
+		"Expected the Machine to be marked for remediation")
+}
+
+func Test_Machine_reconciliation_with_recreated_unchanged_bootstrap_secret(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.BootstrapDataDriftPolicy = infrastructurev1.BootstrapDataDriftPolicyRemediate
+
+	// The Secret was recreated with the same data by clusterctl move.
+	secret := validSecret(machineName, clusterNamespace)
+	secret.UID = "moved-uid"
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		secret,
+	})
+	ctx := context.Background()
+	hardwareKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+
+	gates := feature.NewGates()
+	g.Expect(gates.SetFromMap(map[string]bool{string(feature.ReprovisionOnUserDataChange): true})).To(Succeed())
+
+	r := &machine.TinkerbellMachineReconciler{Client: client, FeatureGates: gates}
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}}
+
+	_, err := r.Reconcile(ctx, request)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	hw := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, hardwareKey, hw)).To(Succeed())
+	g.Expect(hw.Spec.UserData).NotTo(BeNil())
+
+	userData := *hw.Spec.UserData
+	hw.Annotations[machine.HardwareProvisionedAnnotation] = "true"
+	hw.Annotations[machine.HardwareBootstrapSecretUIDAnnotation] = "previous-uid"
+	g.Expect(client.Update(ctx, hw)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, request)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, hardwareKey, hw)).To(Succeed())
+	g.Expect(*hw.Spec.UserData).To(Equal(userData))
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(machine.HardwareBootstrapSecretUIDAnnotation, "moved-uid"),
+		"Expected the UID of the recreated Secret to be recorded")
+
+	updatedMachine := &clusterv1.Machine{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: machineName, Namespace: clusterNamespace}, updatedMachine)).
+		To(Succeed())
+	g.Expect(updatedMachine.Annotations).NotTo(HaveKey(clusterv1.RemediateMachineAnnotation),
+		"Expected a Secret recreated with the same data not to remediate the machine")
+}
+
+func Test_Machine_reconciliation_with_drifted_bootstrap_data_of_control_plane_machine(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.BootstrapDataDriftPolicy = infrastructurev1.BootstrapDataDriftPolicyRemediate
+	tm.Labels = map[string]string{clusterv1.MachineControlPlaneLabel: ""}
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP, testOptions{
+		Labels: map[string]string{
+			machine.HardwareOwnerNameLabel:      tinkerbellMachineName,
+			machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+		},
+	})
+	hw.Annotations = map[string]string{machine.HardwareProvisionedAnnotation: "true"}
+	hw.Spec.UserData = ptr.To("outdated")
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+
+	gates := feature.NewGates()
+	g.Expect(gates.SetFromMap(map[string]bool{string(feature.ReprovisionOnUserDataChange): true})).To(Succeed())
+
+	r := &machine.TinkerbellMachineReconciler{Client: client, FeatureGates: gates}
+	_, err := r.Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}, updatedHardware)).
+		To(Succeed())
+	g.Expect(*updatedHardware.Spec.UserData).NotTo(Equal("outdated"), "Expected user-data to be updated")
+
+	updatedMachine := &clusterv1.Machine{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: machineName, Namespace: clusterNamespace}, updatedMachine)).
+		To(Succeed())
+	g.Expect(updatedMachine.Annotations).NotTo(HaveKey(clusterv1.RemediateMachineAnnotation),
+		"Expected control plane Machines not to be marked for remediation")
+}
+
+func Test_Machine_reconciliation_with_drifted_bootstrap_data_and_reprovisioning_disabled(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.BootstrapDataDriftPolicy = infrastructurev1.BootstrapDataDriftPolicyRemediate
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP, testOptions{
+		Labels: map[string]string{
+			machine.HardwareOwnerNameLabel:      tinkerbellMachineName,
+			machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+		},
+	})
+	hw.Annotations = map[string]string{machine.HardwareProvisionedAnnotation: "true"}
+	hw.Spec.UserData = ptr.To("outdated")
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+
+	gates := feature.NewGates()
+	g.Expect(gates.SetFromMap(map[string]bool{string(feature.ReprovisionOnUserDataChange): false})).To(Succeed())
+
+	r := &machine.TinkerbellMachineReconciler{Client: client, FeatureGates: gates}
+	_, err := r.Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}, updatedHardware)).
+		To(Succeed())
+	g.Expect(*updatedHardware.Spec.UserData).NotTo(Equal("outdated"), "Expected user-data to be updated")
+
+	updatedMachine := &clusterv1.Machine{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: machineName, Namespace: clusterNamespace}, updatedMachine)).
+		To(Succeed())
+	g.Expect(updatedMachine.Annotations).NotTo(HaveKey(clusterv1.RemediateMachineAnnotation),
+		"Expected the Machine not to be marked for remediation with the feature gate disabled")
+}
+
+func Test_Machine_reconciliation_with_hardware_flagged_for_decommission(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP, testOptions{
+		Labels: map[string]string{
+			machine.HardwareOwnerNameLabel:      tinkerbellMachineName,
+			machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+			machine.HardwareDecommissionLabel:   "true",
+		},
+	})
+	hw.Annotations = map[string]string{machine.HardwareProvisionedAnnotation: "true"}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	machineKey := types.NamespacedName{Name: machineName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedMachine := &clusterv1.Machine{}
+	g.Expect(client.Get(ctx, machineKey, updatedMachine)).To(Succeed())
+	g.Expect(updatedMachine.Annotations).To(HaveKey(clusterv1.DeleteMachineAnnotation),
+		"Expected the Machine to be deleted first on scale down")
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}, updatedHardware)).
+		To(Succeed())
+	delete(updatedHardware.Labels, machine.HardwareDecommissionLabel)
+	g.Expect(client.Update(ctx, updatedHardware)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, machineKey, updatedMachine)).To(Succeed())
+	g.Expect(updatedMachine.Annotations).NotTo(HaveKey(clusterv1.DeleteMachineAnnotation),
+		"Expected the annotation to be removed with the Hardware no longer flagged")
+}
+
+func Test_Machine_reconciliation_keeps_delete_machine_annotation_set_by_users(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP, testOptions{
+		Labels: map[string]string{
+			machine.HardwareOwnerNameLabel:      tinkerbellMachineName,
+			machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+		},
+	})
+	hw.Annotations = map[string]string{machine.HardwareProvisionedAnnotation: "true"}
+
+	m := validMachine(machineName, clusterNamespace, clusterName)
+	m.Annotations = map[string]string{clusterv1.DeleteMachineAnnotation: ""}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		m,
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedMachine := &clusterv1.Machine{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{Name: machineName, Namespace: clusterNamespace},
+		updatedMachine)).To(Succeed())
+	g.Expect(updatedMachine.Annotations).To(HaveKey(clusterv1.DeleteMachineAnnotation))
+}
+
+func Test_Machine_reconciliation_with_missing_hardware(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		policy           infrastructurev1.HardwareMissingPolicy
+		wantHardwareName string
+		wantStatus       corev1.ConditionStatus
+		wantFailed       bool
+	}{
+		"fails_the_machine_by_default": {
+			wantHardwareName: "deleted-hardware",
+			wantStatus:       corev1.ConditionFalse,
+			wantFailed:       true,
+		},
+		"selects_new_hardware_with_reselect_policy": {
+			policy:           infrastructurev1.HardwareMissingPolicyReselect,
+			wantHardwareName: hardwareName,
+			wantStatus:       corev1.ConditionTrue,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			hardwareUUID := uuid.New().String()
+			tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+			tm.Spec.HardwareName = "deleted-hardware"
+			tm.Spec.ProviderID = "tinkerbell://" + clusterNamespace + "/deleted-hardware"
+			tm.Spec.HardwareMissingPolicy = tc.policy
+
+			client := kubernetesClientWithObjects(t, []runtime.Object{
+				tm,
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				validHardware(hardwareName, hardwareUUID, hardwareIP),
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			})
+
+			for range 2 {
+				_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			updated := &infrastructurev1.TinkerbellMachine{}
+			g.Expect(client.Get(context.Background(),
+				types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, updated)).To(Succeed())
+
+			g.Expect(updated.Spec.HardwareName).To(Equal(tc.wantHardwareName))
+			g.Expect(updated.Status.ErrorMessage != nil).To(Equal(tc.wantFailed))
+
+			condition := conditions.Get(updated, infrastructurev1.HardwareAvailableCondition)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(tc.wantStatus))
+		})
+	}
+}
+
+func Test_Machine_reconciliation_without_hardware_finalizer(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkerbellCluster.Spec.HardwareFinalizerPolicy = infrastructurev1.HardwareFinalizerPolicyNone
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		tinkerbellCluster,
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	ctx := context.Background()
+	hardwareKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+	machineKey := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	hw := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, hardwareKey, hw)).To(Succeed())
+	g.Expect(hw.Labels).To(HaveKeyWithValue(machine.HardwareOwnerNameLabel, tinkerbellMachineName))
+	g.Expect(hw.Finalizers).To(BeEmpty(), "Expected no finalizer on claimed Hardware")
+
+	// Hardware of a ready machine pruned by GitOps does not fail the machine.
+	tm := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, machineKey, tm)).To(Succeed())
+	tm.Status.Ready = true
+	g.Expect(client.Status().Update(ctx, tm)).To(Succeed())
+	g.Expect(client.Delete(ctx, hw)).To(Succeed())
+
+	result, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected a requeue while waiting for the Hardware")
+
+	g.Expect(client.Get(ctx, machineKey, tm)).To(Succeed())
+	g.Expect(tm.Status.ErrorMessage).To(BeNil(), "Expected the ready machine not to be failed")
+	g.Expect(tm.Spec.HardwareName).To(Equal(hardwareName))
+	g.Expect(conditions.IsFalse(tm, infrastructurev1.HardwareAvailableCondition)).To(BeTrue())
+
+	// Hardware applied again is re-adopted as provisioned, so the running node is not provisioned again.
+	g.Expect(client.Create(ctx, validHardware(hardwareName, hardwareUUID, hardwareIP))).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, hardwareKey, hw)).To(Succeed())
+	g.Expect(hw.Labels).To(HaveKeyWithValue(machine.HardwareOwnerNameLabel, tinkerbellMachineName))
+	g.Expect(hw.Annotations).To(HaveKeyWithValue(machine.HardwareProvisionedAnnotation, "true"))
+	g.Expect(hw.Finalizers).To(BeEmpty())
+
+	g.Expect(client.Get(ctx, machineKey, tm)).To(Succeed())
+	g.Expect(conditions.IsTrue(tm, infrastructurev1.HardwareAvailableCondition)).To(BeTrue())
+}
+
+func Test_Machine_reconciliation_with_expired_bootstrap_data(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	expiredSecret := validSecret(machineName, clusterNamespace)
+	expiredSecret.Annotations = map[string]string{
+		machine.BootstrapDataExpiresAtAnnotation: time.Now().Add(-time.Hour).Format(time.RFC3339),
+	}
+
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		expiredSecret,
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	result, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Unexpected reconciliation error")
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected requeue while waiting for refreshed bootstrap data")
+
+	globalResourceName := types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}
+
+	err = client.Get(context.Background(), globalResourceName, &tinkv1.Workflow{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected no workflow to be created with expired bootstrap data")
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation(t *testing.T) {
+	t.Parallel()
+
+	t.Run("is_not_requeued_when", func(t *testing.T) {
+		t.Parallel()
+
+		// Requeue will be handled when resource is created.
+		t.Run("is_requeued_when_machine_object_is_missing", //nolint:paralleltest
+			machineReconciliationIsRequeuedWhenTinkerbellMachineObjectIsMissing)
+
+		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
+		// Requeue will be handled when ownerRef is set
+		t.Run("machine_has_no_owner_set", machineReconciliationIsRequeuedWhenTinkerbellMachineHasNoOwnerSet) //nolint:paralleltest
+
+		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
+		// Requeue will be handled when bootstrap secret is set through the Watch on Machines
+		t.Run("bootstrap_secret_is_not_ready", machineReconciliationIsRequeuedWhenBootstrapSecretIsNotReady) //nolint:paralleltest
+
+		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
+		// Requeue will be handled when bootstrap secret is set through the Watch on Clusters
+		t.Run("cluster_infrastructure_is_not_ready", machineReconciliationIsRequeuedWhenClusterInfrastructureIsNotReady) //nolint:paralleltest
+	})
+
+	t.Run("fails_when", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("reconciler_is_nil", machineReconciliationPanicsWhenReconcilerIsNil)                     //nolint:paralleltest
+		t.Run("reconciler_has_no_client_set", machineReconciliationPanicsWhenReconcilerHasNoClientSet) //nolint:paralleltest
+
+		// CAPI spec says this is optional, but @detiber says it's effectively required, so treat it as so.
+		t.Run("machine_has_no_version_set", machineReconciliationFailsWhenMachineHasNoVersionSet) //nolint:paralleltest
+
+		t.Run("associated_cluster_object_does_not_exist", //nolint:paralleltest
+			machineReconciliationFailsWhenAssociatedClusterObjectDoesNotExist)
+
+		t.Run("associated_tinkerbell_cluster_object_does_not_exist", //nolint:paralleltest
+			machineReconciliationFailsWhenAssociatedTinkerbellClusterObjectDoesNotExist)
+
+		// If for example CAPI changes key used to store bootstrap date, we shouldn't try to create machines
+		// with empty bootstrap config, we should fail early instead.
+		t.Run("bootstrap_config_is_empty", machineReconciliationFailsWhenBootstrapConfigIsEmpty)               //nolint:paralleltest
+		t.Run("bootstrap_config_has_no_value_key", machineReconciliationFailsWhenBootstrapConfigHasNoValueKey) //nolint:paralleltest
+
+		t.Run("there_is_no_hardware_available", machineReconciliationFailsWhenThereIsNoHardwareAvailable) //nolint:paralleltest
+
+		t.Run("hardware_is_only_available_in_other_namespaces", //nolint:paralleltest
+			machineReconciliationFailsWhenHardwareIsOnlyAvailableInOtherNamespaces)
+
+		t.Run("hardware_is_quarantined", machineReconciliationFailsWhenHardwareIsQuarantined) //nolint:paralleltest
+
+		t.Run("selected_hardware_has_no_ip_address_set", machineReconciliationFailsWhenSelectedHardwareHasNoIPAddressSet) //nolint:paralleltest
+
+		t.Run("template_override_is_malformed", machineReconciliationFailsWhenTemplateOverrideIsMalformed) //nolint:paralleltest
+	})
+
+	t.Run("skips_hardware_not_ready_for_provisioning", machineReconciliationSkipsHardwareNotReadyForProvisioning) //nolint:paralleltest
+
+	// Single hardware should only ever be used for a single machine.
+	t.Run("selects_unique_and_available_hardware_for_each_machine", //nolint:paralleltest
+		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachine)
+
+	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_required_hardware_affinity", //nolint:paralleltest
+		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByRequiredHardwareAffinity)
+
+	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_preferred_hardware_affinity", //nolint:paralleltest
+		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByPreferredHardwareAffinity)
+
+	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_required_and_preferred_hardware_affinity", //nolint:paralleltest
+		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByRequiredAndPreferredHardwareAffinity)
+
+	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_hardware_expressions", //nolint:paralleltest
+		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByHardwareExpressions)
+
+	t.Run("selects_hardware_skipping_hardware_expressions_fail_to_be_evaluated_against", //nolint:paralleltest
+		machineReconciliationSelectsHardwareSkippingHardwareExpressionsFailToBeEvaluatedAgainst)
+
+	// Patching Hardware and TinkerbellMachine are not atomic operations, so we should handle situation, when
+	// misspelling process is aborted in the middle.
+	//
+	// Without that, new Hardware will be selected each time.
+	t.Run("uses_already_selected_hardware_if_patching_tinkerbell_machine_failed", //nolint:paralleltest
+		machineReconciliationUsesAlreadySelectedHardwareIfPatchingTinkerbellMachineFailed)
+
+	t.Run("when_machine_is_scheduled_for_removal_it", func(t *testing.T) {
+		t.Parallel()
+
+		// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#behavior
+		t.Run("removes_tinkerbell_finalizer", notImplemented) //nolint:paralleltest
+
+		// Removing machine should release used hardware.
+		t.Run("marks_hardware_as_available_for_other_machines", notImplemented) //nolint:paralleltest
+	})
+}
+
+func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_it(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, ""),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, uuid.New().String(), hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ctx := context.Background()
+
+	tinkerbellMachineNamespacedName := types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, tinkerbellMachineNamespacedName, updatedMachine)).To(Succeed())
+
+	g.Expect(client.Delete(ctx, updatedMachine)).To(Succeed())
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	hardwareNamespacedName := types.NamespacedName{
+		Name:      hardwareName,
+		Namespace: clusterNamespace,
+	}
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+
+	t.Run("removes_tinkerbell_machine_finalizer_from_hardware", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(updatedHardware.ObjectMeta.GetFinalizers()).To(BeEmpty())
+	})
+
+	t.Run("makes_hardware_available_for_other_machines", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(updatedHardware.ObjectMeta.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel),
+			"Found hardware owner name label")
+		g.Expect(updatedHardware.ObjectMeta.Labels).NotTo(HaveKey(machine.HardwareOwnerNamespaceLabel),
+			"Found hardware owner namespace label")
+	})
+}
+
+func Test_Machine_reconciliation_uses_metadata_url_of_machine_or_cluster(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		machineURL, clusterURL, expected string
+	}{
+		"machine overrides cluster": {
+			machineURL: "http://10.2.0.1:50061",
+			clusterURL: "http://10.1.0.1:50061",
+			expected:   "http://10.2.0.1:50061",
+		},
+		"cluster": {clusterURL: "http://10.1.0.1:50061", expected: "http://10.1.0.1:50061"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			hardwareUUID := uuid.New().String()
+			tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+			tm.Spec.MetadataURL = tc.machineURL
+
+			tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+			tinkerbellCluster.Spec.MetadataURL = tc.clusterURL
+
+			client := kubernetesClientWithObjects(t, []runtime.Object{
+				tm,
+				validCluster(clusterName, clusterNamespace),
+				tinkerbellCluster,
+				validHardware(hardwareName, hardwareUUID, hardwareIP),
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			})
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			template := &tinkv1.Template{}
+			g.Expect(client.Get(context.Background(),
+				types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, template)).To(Succeed())
+			g.Expect(*template.Spec.Data).To(ContainSubstring("metadata_urls: [\"%s\"]", tc.expected))
+		})
+	}
+}
+
+func Test_Machine_reconciliation_renders_image_url_with_catalog_and_architecture(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.ImageLookupFormat = `{{.BaseRegistry}}/{{.Arch}}/{{sha256 (print "ubuntu-" .ShortK8sVersion)}}.gz`
+
+	tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkerbellCluster.Spec.ImageLookupBaseRegistry = "http://images.example.com"
+	tinkerbellCluster.Spec.ImageCatalogRef = &corev1.LocalObjectReference{Name: "images"}
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.Interfaces[0].DHCP.Arch = "aarch64"
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		tinkerbellCluster,
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "images", Namespace: clusterNamespace},
+			Data:       map[string]string{"ubuntu-1.19": "sha256: 0123abcd\nsize: 1024\n"},
+		},
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(context.Background(),
+		types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, template)).To(Succeed())
+	g.Expect(*template.Spec.Data).To(ContainSubstring(
+		"IMG_URL: http://images.example.com/aarch64/0123abcd.gz"))
+}
+
+func Test_Machine_reconciliation_renders_library_template(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.TemplateRefName = "ubuntu"
+	tm.Spec.WorkerDevice.Key = "worker"
+
+	tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkerbellCluster.Spec.ImageLookupBaseRegistry = "http://images.example.com"
+	tinkerbellCluster.Spec.ImageLookupOSDistro = "ubuntu"
+	tinkerbellCluster.Spec.TemplateLibraryRef = &corev1.LocalObjectReference{Name: "templates"}
+
+	library := `version: "0.1"
+name: [[.MachineName]]
+global_timeout: 6000
+tasks:
+  - name: "[[.MachineName]]"
+    worker: "[[.Worker]]"
+    actions:
+      - name: "stream image"
+        image: quay.io/tinkerbell/actions/oci2disk
+        timeout: 600
+        environment:
+          IMG_URL: [[.ImageURL]]
+          DEST_DISK: [[.DestDisk]]
+          CLUSTER: [[.ClusterName]]
+`
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		tinkerbellCluster,
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "templates", Namespace: clusterNamespace},
+			Data:       map[string]string{"ubuntu": library},
+		},
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(context.Background(),
+		types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, template)).To(Succeed())
+	g.Expect(*template.Spec.Data).To(ContainSubstring("name: " + tinkerbellMachineName))
+	g.Expect(*template.Spec.Data).To(ContainSubstring(`worker: "{{.worker}}"`))
+	g.Expect(*template.Spec.Data).To(ContainSubstring("IMG_URL: http://images.example.com/ubuntu-"))
+	g.Expect(*template.Spec.Data).To(ContainSubstring("DEST_DISK: /dev/sda"))
+	g.Expect(*template.Spec.Data).To(ContainSubstring("CLUSTER: " + clusterName))
+}
+
+func Test_Machine_reconciliation_fails_when_library_template_is_missing(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.TemplateRefName = "ubuntu"
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(machine.ErrNoTemplateLibrary))
+}
+
+func Test_Machine_reconciliation_applies_workflow_timeouts_of_machine_and_cluster(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.WorkflowTimeouts = &infrastructurev1.WorkflowTimeouts{
+		Actions: map[string]int64{"stream image": 3600},
+	}
+
+	tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkerbellCluster.Spec.WorkflowTimeouts = &infrastructurev1.WorkflowTimeouts{
+		Global:  9000,
+		Actions: map[string]int64{"stream image": 1200, "kexec image": 180},
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		tinkerbellCluster,
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(context.Background(),
+		types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, template)).To(Succeed())
+
+	parsed := struct {
+		GlobalTimeout int64 `json:"global_timeout"`
+		Tasks         []struct {
+			Actions []struct {
+				Name    string `json:"name"`
+				Timeout int64  `json:"timeout"`
+			} `json:"actions"`
+		} `json:"tasks"`
+	}{}
+	g.Expect(yaml.Unmarshal([]byte(*template.Spec.Data), &parsed)).To(Succeed())
+
+	timeouts := map[string]int64{}
+	for _, action := range parsed.Tasks[0].Actions {
+		timeouts[action.Name] = action.Timeout
+	}
+
+	g.Expect(parsed.GlobalTimeout).To(BeEquivalentTo(9000), "Expected the global timeout of the cluster")
+	g.Expect(timeouts).To(HaveKeyWithValue("stream image", BeEquivalentTo(3600)),
+		"Expected the timeout of the machine to take precedence")
+	g.Expect(timeouts).To(HaveKeyWithValue("kexec image", BeEquivalentTo(180)))
+	g.Expect(timeouts).To(HaveKeyWithValue("add tink cloud-init ds-config", BeEquivalentTo(90)))
+}
+
+func Test_Machine_reconciliation_writes_files_with_content_from_secrets_and_config_maps(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.Files = []infrastructurev1.File{
+		{Path: "/etc/ssl/certs/registry.pem", ContentFrom: &infrastructurev1.FileSource{
+			Secret: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "registry"},
+				Key:                  "ca.crt",
+			},
+		}},
+		{Path: "/etc/environment", ContentFrom: &infrastructurev1.FileSource{
+			ConfigMap: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "proxy"},
+				Key:                  "environment",
+			},
+		}},
+	}
+
+	objects := []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: clusterNamespace},
+			Data:       map[string][]byte{"ca.crt": []byte("-----BEGIN CERTIFICATE-----")},
+		},
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(ContainSubstring("reading content of file /etc/environment")),
+		"Expected the Template not to be created while the content of a file is missing")
+
+	g.Expect(client.Create(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "proxy", Namespace: clusterNamespace},
+		Data:       map[string]string{"environment": "HTTPS_PROXY=http://proxy:3128"},
+	})).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(context.Background(),
+		types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, template)).To(Succeed())
+
+	parsed := struct {
+		Tasks []struct {
+			Actions []struct {
+				Name        string            `json:"name"`
+				Environment map[string]string `json:"environment"`
+			} `json:"actions"`
+		} `json:"tasks"`
+	}{}
+	g.Expect(yaml.Unmarshal([]byte(*template.Spec.Data), &parsed)).To(Succeed())
+
+	contents := map[string]string{}
+	for _, action := range parsed.Tasks[0].Actions {
+		contents[action.Name] = action.Environment["CONTENTS"]
+	}
+
+	g.Expect(contents).To(HaveKeyWithValue("add file /etc/ssl/certs/registry.pem", "-----BEGIN CERTIFICATE-----"))
+	g.Expect(contents).To(HaveKeyWithValue("add file /etc/environment", "HTTPS_PROXY=http://proxy:3128"))
+}
+
+func Test_Machine_reconciliation_names_workflow_of_long_machine_names(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	name := strings.Repeat("long-machine-deployment-name-", 3) + "abcde"
+	hardwareUUID := uuid.New().String()
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(name, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, name, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tm := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{Name: name, Namespace: clusterNamespace}, tm)).
+		To(Succeed())
+	g.Expect(len(tm.Status.WorkflowName)).To(BeNumerically("<=", 63))
+	g.Expect(tm.Status.WorkflowName).To(HavePrefix("long-machine-deployment-name-"))
+	g.Expect(tm.Status.TemplateName).To(Equal(tm.Status.WorkflowName))
+
+	key := types.NamespacedName{Name: tm.Status.WorkflowName, Namespace: clusterNamespace}
+	g.Expect(client.Get(context.Background(), key, &tinkv1.Template{})).To(Succeed())
+
+	// The recorded names are used from then on.
+	_, err = reconcileMachineWithClient(client, name, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	workflow := &tinkv1.Workflow{}
+	g.Expect(client.Get(context.Background(), key, workflow)).To(Succeed())
+	g.Expect(workflow.Spec.TemplateRef).To(Equal(tm.Status.TemplateName))
+}
+
+func Test_Machine_reconciliation_records_hardware_selection(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID,
+		testOptions{HardwareAffinity: &infrastructurev1.HardwareAffinity{
+			Preferred: []infrastructurev1.WeightedHardwareAffinityTerm{
+				{
+					Weight: 10,
+					HardwareAffinityTerm: infrastructurev1.HardwareAffinityTerm{
+						LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"rack": "foo"}},
+					},
+				},
+				{
+					Weight: 20,
+					HardwareAffinityTerm: infrastructurev1.HardwareAffinityTerm{
+						LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"rack": "bar"}},
+					},
+				},
+			},
+		}})
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware("foo", uuid.New().String(), "1.1.1.1", testOptions{Labels: map[string]string{"rack": "foo"}}),
+		validHardware("bar", uuid.New().String(), "1.1.1.2", testOptions{Labels: map[string]string{"rack": "bar"}}),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(context.Background(),
+		types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, tm)).To(Succeed())
+	g.Expect(tm.Spec.HardwareName).To(Equal("bar"))
+	g.Expect(tm.Annotations[machine.HardwareSelectionAnnotation]).To(MatchJSON(`{
+		"selected": "myClusterNamespace/bar",
+		"candidates": 2,
+		"top": [
+			{"hardware": "myClusterNamespace/bar", "score": 20, "requiredTerms": [0], "weight": 20, "preferredTerms": [1]},
+			{"hardware": "myClusterNamespace/foo", "score": 10, "requiredTerms": [0], "weight": 10, "preferredTerms": [0]}
+		]
+	}`))
+}
+
+func Test_Machine_reconciliation_combines_weights_of_overlapping_preferred_terms(t *testing.T) {
+	t.Parallel()
+
+	preferred := func(weight int32, labels map[string]string) infrastructurev1.WeightedHardwareAffinityTerm {
+		return infrastructurev1.WeightedHardwareAffinityTerm{
+			Weight: weight,
+			HardwareAffinityTerm: infrastructurev1.HardwareAffinityTerm{
+				LabelSelector: metav1.LabelSelector{MatchLabels: labels},
+			},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		scoring  infrastructurev1.PreferredScoring
+		expected string
+	}{
+		"last_match_by_default": {expected: "ssd"},
+		"last_match":            {scoring: infrastructurev1.PreferredScoringLastMatch, expected: "ssd"},
+		"sum":                   {scoring: infrastructurev1.PreferredScoringSum, expected: "gpu"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			// Hardware gpu matches the first and last terms, weighing 60 when summed but 30 with its last match,
+			// Hardware ssd only matches the second term, weighing 50.
+			tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, uuid.New().String(),
+				testOptions{HardwareAffinity: &infrastructurev1.HardwareAffinity{
+					Preferred: []infrastructurev1.WeightedHardwareAffinityTerm{
+						preferred(30, map[string]string{"rack": "r1"}),
+						preferred(50, map[string]string{"disk": "ssd"}),
+						preferred(30, map[string]string{"accelerator": "gpu"}),
+					},
+					PreferredScoring: tc.scoring,
+				}})
+
+			client := kubernetesClientWithObjects(t, []runtime.Object{
+				tm,
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				validHardware("gpu", uuid.New().String(), "1.1.1.1",
+					testOptions{Labels: map[string]string{"rack": "r1", "accelerator": "gpu"}}),
+				validHardware("ssd", uuid.New().String(), "1.1.1.2",
+					testOptions{Labels: map[string]string{"rack": "r2", "disk": "ssd"}}),
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			})
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			g.Expect(client.Get(context.Background(),
+				types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, tm)).To(Succeed())
+			g.Expect(tm.Spec.HardwareName).To(Equal(tc.expected))
+		})
+	}
+}
+
+func Test_Machine_reconciliation_with_control_plane_hardware_reservation(t *testing.T) {
+	t.Parallel()
+
+	soft := infrastructurev1.HardwareReservationSoft
+	both := []string{"reserved", "other"}
+
+	for name, tc := range map[string]struct {
+		controlPlane bool
+		mode         infrastructurev1.HardwareReservationMode
+		hardware     []string
+		expected     string
+	}{
+		"control_plane_prefers_reserved":     {controlPlane: true, hardware: both, expected: "reserved"},
+		"control_plane_falls_back_to_others": {controlPlane: true, hardware: []string{"other"}, expected: "other"},
+		"worker_skips_reserved":              {hardware: both, expected: "other"},
+		"worker_never_selects_reserved":      {hardware: []string{"reserved"}},
+		"soft_worker_skips_reserved":         {mode: soft, hardware: both, expected: "other"},
+		"soft_worker_falls_back_to_reserved": {mode: soft, hardware: []string{"reserved"}, expected: "reserved"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, uuid.New().String())
+			if tc.controlPlane {
+				tm.Labels = map[string]string{clusterv1.MachineControlPlaneLabel: ""}
+			}
+
+			tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+			tinkerbellCluster.Spec.ControlPlaneHardwareReservation = tc.mode
+
+			objects := []runtime.Object{
+				tm,
+				validCluster(clusterName, clusterNamespace),
+				tinkerbellCluster,
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
+
+			for i, name := range tc.hardware {
+				options := testOptions{}
+				if name == "reserved" {
+					options.Labels = map[string]string{
+						machine.HardwareReservedForLabel: machine.HardwareReservedForControlPlane,
+					}
+				}
+
+				objects = append(objects, validHardware(name, uuid.New().String(), fmt.Sprintf("1.1.1.%d", i+1), options))
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			if tc.expected == "" {
+				g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(client.Get(context.Background(),
+				types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, tm)).To(Succeed())
+			g.Expect(tm.Spec.HardwareName).To(Equal(tc.expected))
+		})
+	}
+}
+
+func Test_Machine_reconciliation_records_observed_generation(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, uuid.New().String())
+	tm.Generation = 3
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, uuid.New().String(), hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(context.Background(),
+		types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, tm)).To(Succeed())
+	g.Expect(tm.Status.ObservedGeneration).To(BeEquivalentTo(3))
+}
+
+func Test_Machine_reconciliation_with_paused_cluster(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	cluster := validCluster(clusterName, clusterNamespace)
+	cluster.Spec.Paused = true
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, uuid.New().String()),
+		cluster,
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, uuid.New().String(), hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	result, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(BeZero())
+
+	tm := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(context.Background(),
+		types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, tm)).To(Succeed())
+	g.Expect(tm.Finalizers).To(BeEmpty(), "Expected paused machine not to be reconciled")
+	g.Expect(tm.Spec.ProviderID).To(BeEmpty(), "Expected no Hardware to be selected for paused machine")
+}
+
+func Test_Machine_reconciliation_with_hardware_roles(t *testing.T) {
+	t.Parallel()
+
+	all := []string{"control-plane", "worker", ""}
+
+	for name, tc := range map[string]struct {
+		controlPlane bool
+		disabled     bool
+		hardware     []string
+		expected     string
+	}{
+		"control_plane_selects_control_plane_role": {controlPlane: true, hardware: all, expected: "control-plane"},
+		"worker_selects_worker_role":               {hardware: all, expected: "worker"},
+		"control_plane_falls_back_to_no_role": {
+			controlPlane: true, hardware: []string{"worker", ""}, expected: "no-role",
+		},
+		"worker_never_selects_control_plane_role": {hardware: []string{"control-plane"}},
+		"disabled_ignores_roles": {
+			disabled: true, hardware: []string{"control-plane"}, expected: "control-plane",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, uuid.New().String())
+			if tc.controlPlane {
+				tm.Labels = map[string]string{clusterv1.MachineControlPlaneLabel: ""}
+			}
+
+			objects := []runtime.Object{
+				tm,
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
+
+			for i, role := range tc.hardware {
+				name, options := "no-role", testOptions{}
+				if role != "" {
+					name, options.Labels = role, map[string]string{machine.HardwareRoleLabel: role}
+				}
+
+				objects = append(objects, validHardware(name, uuid.New().String(), fmt.Sprintf("1.1.1.%d", i+1), options))
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			gates := feature.NewGates()
+			g.Expect(gates.SetFromMap(map[string]bool{string(feature.HardwareRoles): !tc.disabled})).To(Succeed())
+
+			r := &machine.TinkerbellMachineReconciler{Client: client, FeatureGates: gates}
+
+			_, err := r.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+			})
+			if tc.expected == "" {
+				g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(client.Get(context.Background(),
+				types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}, tm)).To(Succeed())
+			g.Expect(tm.Spec.HardwareName).To(Equal(tc.expected))
+		})
+	}
+}
+
+func Test_Machine_reconciliation_checks_bmc_readiness(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		bmc      func([]runtime.Object) []runtime.Object
+		expected error
+		message  string
+	}{
+		"ready": {
+			bmc: func(objects []runtime.Object) []runtime.Object { return objects },
+		},
+		"machine_not_found": {
+			bmc:      func([]runtime.Object) []runtime.Object { return nil },
+			expected: machine.ErrBMCMachineNotFound,
+		},
+		"secret_without_password": {
+			bmc: func(objects []runtime.Object) []runtime.Object {
+				delete(objects[1].(*corev1.Secret).Data, "password")
+
+				return objects
+			},
+			expected: machine.ErrBMCCredentialsInvalid,
+			message:  "has no password",
+		},
+		"not_contactable": {
+			bmc: func(objects []runtime.Object) []runtime.Object {
+				objects[0].(*rufiov1.Machine).Status.Conditions[0] = rufiov1.MachineCondition{
+					Type:    rufiov1.Contactable,
+					Status:  rufiov1.ConditionFalse,
+					Message: "dial tcp 10.0.0.1:623: connection refused",
+				}
+
+				return objects
+			},
+			expected: machine.ErrBMCNotContactable,
+			message:  "connection refused",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			hardwareUUID := uuid.New().String()
+			tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+			tm.Spec.BootOptions.BootMode = infrastructurev1.BootModeNetboot
+
+			hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+			hw.Spec.BMCRef = &corev1.TypedLocalObjectReference{Name: "bmc"}
+
+			client := kubernetesClientWithObjects(t, append([]runtime.Object{
+				tm,
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				hw,
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}, tc.bmc(validBMC("bmc", clusterNamespace))...))
+			ctx := context.Background()
+			key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			g.Expect(client.Get(ctx, key, tm)).To(Succeed())
+			condition := conditions.Get(tm, infrastructurev1.BMCReadyCondition)
+			g.Expect(condition).NotTo(BeNil())
+
+			if tc.expected == nil {
+				g.Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+				g.Expect(client.Get(ctx, key, &tinkv1.Workflow{})).To(Succeed())
+
+				return
+			}
+
+			g.Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+			g.Expect(condition.Reason).To(Equal(infrastructurev1.BMCNotReadyReason))
+			g.Expect(condition.Message).To(ContainSubstring(tc.expected.Error()))
+			g.Expect(condition.Message).To(ContainSubstring(tc.message))
+			g.Expect(client.Get(ctx, key, &tinkv1.Workflow{})).NotTo(Succeed(),
+				"Expected no Workflow powering on the Hardware")
+		})
+	}
+}
+
+func Test_Machine_reconciliation_with_external_power_management(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.BootOptions.BootMode = infrastructurev1.BootModeNetboot
+	tm.Spec.BootOptions.PowerManagement = infrastructurev1.PowerManagementExternal
+
+	hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	hw.Spec.BMCRef = &corev1.TypedLocalObjectReference{Name: "bmc"}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		tm,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hw,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+	ctx := context.Background()
+	machineKey := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	workflow := &tinkv1.Workflow{}
+	g.Expect(client.Get(ctx, machineKey, workflow)).To(Succeed())
+	g.Expect(workflow.Spec.BootOptions.BootMode).To(BeEmpty(), "Expected Tinkerbell to leave the power alone")
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, machineKey, updatedMachine)).To(Succeed())
+	g.Expect(client.Delete(ctx, updatedMachine)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = client.Get(ctx, machineKey, updatedMachine)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the machine to be removed without powering off")
+
+	jobs := &rufiov1.JobList{}
+	g.Expect(client.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(BeEmpty(), "Expected no BMC Jobs")
+}
+
+func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_with_running_workflow(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	hardwareUUID := uuid.New().String()
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	})
+
+	r := &machine.TinkerbellMachineReconciler{Client: client, WorkflowTerminationTimeout: time.Hour}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}}
 
-		updatedHardware := &tinkv1.Hardware{}
-		g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
 
-		g.Expect(updatedHardware.ObjectMeta.Labels).To(
-			HaveKeyWithValue(machine.HardwareOwnerNameLabel, tinkerbellMachineName),
-			"Expected owner name label to be set on Hardware")
+	wf := &tinkv1.Workflow{}
+	g.Expect(client.Get(ctx, req.NamespacedName, wf)).To(Succeed())
+	wf.Status.State = tinkv1.WorkflowStateRunning
+	g.Expect(client.Update(ctx, wf)).To(Succeed())
 
-		g.Expect(updatedHardware.ObjectMeta.Labels).To(
-			HaveKeyWithValue(machine.HardwareOwnerNamespaceLabel, clusterNamespace),
-			"Expected owner namespace label to be set on Hardware")
-	})
+	tm := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, req.NamespacedName, tm)).To(Succeed())
+	g.Expect(client.Delete(ctx, tm)).To(Succeed())
 
-	// Ensure idempotency of reconcile operation. E.g. we shouldn't try to create the template with the same name
-	// on every iteration.
-	t.Run("succeeds_when_executed_twice", func(t *testing.T) {
-		t.Parallel()
-		g := NewWithT(t)
+	result, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected the teardown to check the workflow again")
 
-		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
-		g.Expect(err).NotTo(HaveOccurred(), "Unexpected reconciliation error")
-	})
+	hw := &tinkv1.Hardware{}
+	hwKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+	g.Expect(client.Get(ctx, hwKey, hw)).To(Succeed())
+	g.Expect(hw.Labels).To(HaveKey(machine.HardwareOwnerNameLabel), "Hardware should stay bound while its workflow runs")
+	g.Expect(client.Get(ctx, req.NamespacedName, &tinkv1.Workflow{})).To(Succeed(), "Workflow should not be removed")
 
-	// Status should be updated on every run.
-	//
-	// Don't execute this test in parallel, as we reset status here.
-	t.Run("refreshes_status_when_machine_is_already_provisioned", func(t *testing.T) { //nolint:paralleltest
-		updatedMachine.Status.Addresses = nil
-		g := NewWithT(t)
+	g.Expect(client.Get(ctx, req.NamespacedName, wf)).To(Succeed())
+	wf.Status.State = tinkv1.WorkflowStateFailed
+	g.Expect(client.Update(ctx, wf)).To(Succeed())
 
-		g.Expect(client.Update(context.Background(), updatedMachine)).To(Succeed())
-		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
-		g.Expect(err).NotTo(HaveOccurred())
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
 
-		updatedMachine = &infrastructurev1.TinkerbellMachine{}
-		g.Expect(client.Get(ctx, namespacedName, updatedMachine)).To(Succeed())
-		g.Expect(updatedMachine.Status.Addresses).NotTo(BeEmpty(), "Machine status should be updated on every reconciliation")
-	})
+	g.Expect(client.Get(ctx, hwKey, hw)).To(Succeed())
+	g.Expect(hw.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel),
+		"Hardware should be released once its workflow finished")
 }
 
-//nolint:funlen
-func Test_Machine_reconciliation(t *testing.T) {
-	t.Parallel()
+// completeBMCJobs marks all BMC Jobs as completed.
+func completeBMCJobs(ctx context.Context, g *WithT, c client.Client) {
+	jobs := &rufiov1.JobList{}
+	g.Expect(c.List(ctx, jobs)).To(Succeed())
 
-	t.Run("is_not_requeued_when", func(t *testing.T) {
-		t.Parallel()
+	for i := range jobs.Items {
+		jobs.Items[i].SetCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue)
+		g.Expect(c.Update(ctx, &jobs.Items[i])).To(Succeed())
+	}
+}
 
-		// Requeue will be handled when resource is created.
-		t.Run("is_requeued_when_machine_object_is_missing", //nolint:paralleltest
-			machineReconciliationIsRequeuedWhenTinkerbellMachineObjectIsMissing)
+func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_with_timed_out_workflow(t *testing.T) {
+	t.Parallel()
 
-		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
-		// Requeue will be handled when ownerRef is set
-		t.Run("machine_has_no_owner_set", machineReconciliationIsRequeuedWhenTinkerbellMachineHasNoOwnerSet) //nolint:paralleltest
+	tests := map[string]struct {
+		bmc bool
+	}{
+		"quarantines_hardware_without_bmc": {},
+		"powers_off_hardware_before_releasing_it": {
+			bmc: true,
+		},
+	}
 
-		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
-		// Requeue will be handled when bootstrap secret is set through the Watch on Machines
-		t.Run("bootstrap_secret_is_not_ready", machineReconciliationIsRequeuedWhenBootstrapSecretIsNotReady) //nolint:paralleltest
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			hardwareUUID := uuid.New().String()
+			hw := validHardware(hardwareName, hardwareUUID, hardwareIP)
+
+			objects := []runtime.Object{
+				validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				hw,
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
 
-		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
-		// Requeue will be handled when bootstrap secret is set through the Watch on Clusters
-		t.Run("cluster_infrastructure_is_not_ready", machineReconciliationIsRequeuedWhenClusterInfrastructureIsNotReady) //nolint:paralleltest
-	})
+			if tc.bmc {
+				hw.Spec.BMCRef = &corev1.TypedLocalObjectReference{Name: "bmc"}
+				objects = append(objects, validBMC("bmc", clusterNamespace)...)
+			}
 
-	t.Run("fails_when", func(t *testing.T) {
-		t.Parallel()
+			client := kubernetesClientWithObjects(t, objects)
 
-		t.Run("reconciler_is_nil", machineReconciliationPanicsWhenReconcilerIsNil)                     //nolint:paralleltest
-		t.Run("reconciler_has_no_client_set", machineReconciliationPanicsWhenReconcilerHasNoClientSet) //nolint:paralleltest
+			r := &machine.TinkerbellMachineReconciler{Client: client, WorkflowTerminationTimeout: time.Nanosecond}
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}}
 
-		// CAPI spec says this is optional, but @detiber says it's effectively required, so treat it as so.
-		t.Run("machine_has_no_version_set", machineReconciliationFailsWhenMachineHasNoVersionSet) //nolint:paralleltest
+			_, err := r.Reconcile(ctx, req)
+			g.Expect(err).NotTo(HaveOccurred())
 
-		t.Run("associated_cluster_object_does_not_exist", //nolint:paralleltest
-			machineReconciliationFailsWhenAssociatedClusterObjectDoesNotExist)
+			if tc.bmc {
+				completeBMCJobs(ctx, g, client)
 
-		t.Run("associated_tinkerbell_cluster_object_does_not_exist", //nolint:paralleltest
-			machineReconciliationFailsWhenAssociatedTinkerbellClusterObjectDoesNotExist)
+				_, err = r.Reconcile(ctx, req)
+				g.Expect(err).NotTo(HaveOccurred())
+			}
 
-		// If for example CAPI changes key used to store bootstrap date, we shouldn't try to create machines
-		// with empty bootstrap config, we should fail early instead.
-		t.Run("bootstrap_config_is_empty", machineReconciliationFailsWhenBootstrapConfigIsEmpty)               //nolint:paralleltest
-		t.Run("bootstrap_config_has_no_value_key", machineReconciliationFailsWhenBootstrapConfigHasNoValueKey) //nolint:paralleltest
+			wf := &tinkv1.Workflow{}
+			g.Expect(client.Get(ctx, req.NamespacedName, wf)).To(Succeed())
+			wf.Status.State = tinkv1.WorkflowStateRunning
+			g.Expect(client.Update(ctx, wf)).To(Succeed())
 
-		t.Run("there_is_no_hardware_available", machineReconciliationFailsWhenThereIsNoHardwareAvailable) //nolint:paralleltest
+			tm := &infrastructurev1.TinkerbellMachine{}
+			g.Expect(client.Get(ctx, req.NamespacedName, tm)).To(Succeed())
+			g.Expect(client.Delete(ctx, tm)).To(Succeed())
 
-		t.Run("selected_hardware_has_no_ip_address_set", machineReconciliationFailsWhenSelectedHardwareHasNoIPAddressSet) //nolint:paralleltest
-	})
+			_, err = r.Reconcile(ctx, req)
+			g.Expect(err).NotTo(HaveOccurred())
 
-	// Single hardware should only ever be used for a single machine.
-	t.Run("selects_unique_and_available_hardware_for_each_machine", //nolint:paralleltest
-		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachine)
+			hwKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+			g.Expect(client.Get(ctx, hwKey, hw)).To(Succeed())
 
-	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_required_hardware_affinity", //nolint:paralleltest
-		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByRequiredHardwareAffinity)
+			if !tc.bmc {
+				g.Expect(hw.Labels).To(HaveKeyWithValue(machine.HardwareQuarantinedLabel, "true"))
+				g.Expect(hw.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel))
 
-	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_preferred_hardware_affinity", //nolint:paralleltest
-		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByPreferredHardwareAffinity)
+				return
+			}
 
-	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_required_and_preferred_hardware_affinity", //nolint:paralleltest
-		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByRequiredAndPreferredHardwareAffinity)
+			g.Expect(hw.Labels).To(HaveKey(machine.HardwareOwnerNameLabel),
+				"Hardware should stay bound until it is powered off")
+			g.Expect(hw.Labels).NotTo(HaveKey(machine.HardwareQuarantinedLabel))
 
-	// Patching Hardware and TinkerbellMachine are not atomic operations, so we should handle situation, when
-	// misspelling process is aborted in the middle.
-	//
-	// Without that, new Hardware will be selected each time.
-	t.Run("uses_already_selected_hardware_if_patching_tinkerbell_machine_failed", //nolint:paralleltest
-		machineReconciliationUsesAlreadySelectedHardwareIfPatchingTinkerbellMachineFailed)
+			jobs := &rufiov1.JobList{}
+			g.Expect(client.List(ctx, jobs)).To(Succeed())
+			g.Expect(jobs.Items).To(ContainElement(HaveField("ObjectMeta.Labels",
+				HaveKeyWithValue(machine.BMCJobOperationLabel, "poweroff"))))
 
-	t.Run("when_machine_is_scheduled_for_removal_it", func(t *testing.T) {
-		t.Parallel()
+			completeBMCJobs(ctx, g, client)
 
-		// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#behavior
-		t.Run("removes_tinkerbell_finalizer", notImplemented) //nolint:paralleltest
+			_, err = r.Reconcile(ctx, req)
+			g.Expect(err).NotTo(HaveOccurred())
 
-		// Removing machine should release used hardware.
-		t.Run("marks_hardware_as_available_for_other_machines", notImplemented) //nolint:paralleltest
-	})
+			g.Expect(client.Get(ctx, hwKey, hw)).To(Succeed())
+			g.Expect(hw.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel))
+			g.Expect(hw.Labels).NotTo(HaveKey(machine.HardwareQuarantinedLabel))
+		})
+	}
 }
 
-func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_it(t *testing.T) {
+func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_with_pre_terminate_hook(t *testing.T) {
 	t.Parallel()
 	g := NewWithT(t)
 
+	capiMachine := validMachine(machineName, clusterNamespace, clusterName)
+	hookAnnotation := clusterv1.PreTerminateDeleteHookAnnotationPrefix + "/cmdb"
+
 	objects := []runtime.Object{
 		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, ""),
 		validCluster(clusterName, clusterNamespace),
 		validTinkerbellCluster(clusterName, clusterNamespace),
 		validHardware(hardwareName, uuid.New().String(), hardwareIP),
-		validMachine(machineName, clusterNamespace, clusterName),
+		capiMachine,
 		validSecret(machineName, clusterNamespace),
 	}
 
@@ -682,42 +3075,34 @@ func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_it(t *tes
 
 	ctx := context.Background()
 
-	tinkerbellMachineNamespacedName := types.NamespacedName{
-		Name:      tinkerbellMachineName,
-		Namespace: clusterNamespace,
-	}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: machineName, Namespace: clusterNamespace}, capiMachine)).To(Succeed())
+	capiMachine.Annotations = map[string]string{hookAnnotation: "cmdb-controller"}
+	g.Expect(client.Update(ctx, capiMachine)).To(Succeed())
 
 	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	tinkerbellMachineNamespacedName := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
 	g.Expect(client.Get(ctx, tinkerbellMachineNamespacedName, updatedMachine)).To(Succeed())
-
 	g.Expect(client.Delete(ctx, updatedMachine)).To(Succeed())
+
 	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
 	g.Expect(err).NotTo(HaveOccurred())
 
-	hardwareNamespacedName := types.NamespacedName{
-		Name:      hardwareName,
-		Namespace: clusterNamespace,
-	}
-
+	hardwareNamespacedName := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
 	updatedHardware := &tinkv1.Hardware{}
 	g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+	g.Expect(updatedHardware.ObjectMeta.Labels).To(HaveKey(machine.HardwareOwnerNameLabel),
+		"Hardware should stay bound while a pre-terminate hook is set")
 
-	t.Run("removes_tinkerbell_machine_finalizer_from_hardware", func(t *testing.T) {
-		t.Parallel()
-		g := NewWithT(t)
-
-		g.Expect(updatedHardware.ObjectMeta.GetFinalizers()).To(BeEmpty())
-	})
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: machineName, Namespace: clusterNamespace}, capiMachine)).To(Succeed())
+	delete(capiMachine.Annotations, hookAnnotation)
+	g.Expect(client.Update(ctx, capiMachine)).To(Succeed())
 
-	t.Run("makes_hardware_available_for_other_machines", func(t *testing.T) {
-		t.Parallel()
-		g := NewWithT(t)
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
 
-		g.Expect(updatedHardware.ObjectMeta.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel),
-			"Found hardware owner name label")
-		g.Expect(updatedHardware.ObjectMeta.Labels).NotTo(HaveKey(machine.HardwareOwnerNamespaceLabel),
-			"Found hardware owner namespace label")
-	})
+	g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+	g.Expect(updatedHardware.ObjectMeta.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel),
+		"Hardware should be released once pre-terminate hooks are removed")
 }
 
 func machineReconciliationPanicsWhenReconcilerIsNil(t *testing.T) {
@@ -985,6 +3370,65 @@ func machineReconciliationFailsWhenThereIsNoHardwareAvailable(t *testing.T) {
 		validSecret(machineName, clusterNamespace),
 	}
 
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	updated := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, key, updated)).To(Succeed())
+	g.Expect(conditions.GetReason(updated, infrastructurev1.HardwareClaimedCondition)).
+		To(Equal(infrastructurev1.InsufficientHardwareReason))
+
+	// Adding Hardware notifies the waiting machine, claimed Hardware does not.
+	toMachines := (&machine.TinkerbellMachineReconciler{Client: client}).HardwareToWaitingTinkerbellMachines(ctx)
+
+	added := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	g.Expect(toMachines(ctx, added)).To(ConsistOf(ctrl.Request{NamespacedName: key}))
+
+	added.Labels = map[string]string{machine.HardwareOwnerNameLabel: "other"}
+	g.Expect(toMachines(ctx, added)).To(BeEmpty())
+}
+
+func machineReconciliationFailsWhenHardwareIsOnlyAvailableInOtherNamespaces(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	otherNamespaceHardware := validHardware(hardwareName, hardwareUUID, hardwareIP)
+	otherNamespaceHardware.Namespace = "other"
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		otherNamespaceHardware,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	_, err := reconcileMachineWithClient(kubernetesClientWithObjects(t, objects), tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
+}
+
+func machineReconciliationFailsWhenHardwareIsQuarantined(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP, testOptions{
+			Labels: map[string]string{machine.HardwareQuarantinedLabel: "true"},
+		}),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
 	_, err := reconcileMachineWithClient(kubernetesClientWithObjects(t, objects), tinkerbellMachineName, clusterNamespace)
 	g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
 }
@@ -1005,7 +3449,67 @@ func machineReconciliationFailsWhenSelectedHardwareHasNoIPAddressSet(t *testing.
 	}
 
 	_, err := reconcileMachineWithClient(kubernetesClientWithObjects(t, objects), tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
 	g.Expect(err).To(MatchError(machine.ErrHardwareFirstInterfaceDHCPMissingIP))
+	g.Expect(err).To(MatchError(ContainSubstring("Hardware "+hardwareName)), "Expected the unready Hardware to be named")
+}
+
+func machineReconciliationFailsWhenTemplateOverrideIsMalformed(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tinkerbellMachine.Spec.TemplateOverride = "version: \"0.1\"\nname: custom\ntasks: []\n"
+
+	objects := []runtime.Object{
+		tinkerbellMachine,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(machine.ErrMalformedTemplate))
+
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+	g.Expect(apierrors.IsNotFound(client.Get(context.Background(), key, &tinkv1.Template{}))).To(BeTrue(),
+		"Expected no template to be created for a malformed template override")
+}
+
+func machineReconciliationSkipsHardwareNotReadyForProvisioning(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	diskless := validHardware("diskless", uuid.New().String(), "2.2.2.2")
+	diskless.Spec.Disks = nil
+
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		diskless,
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}, updatedMachine)).To(Succeed())
+	g.Expect(updatedMachine.Spec.HardwareName).To(Equal(hardwareName))
 }
 
 func machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachine(t *testing.T) {
@@ -1406,6 +3910,40 @@ func machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteri
 		})
 }
 
+func machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByHardwareExpressions(t *testing.T) {
+	machineReconciliationHardwareAffinityHelper(t, testOptions{
+		HardwareAffinity: &infrastructurev1.HardwareAffinity{
+			RequiredExpression: `hardware.metadata.labels["rack"] == "foo"`,
+		},
+	}, testOptions{
+		HardwareAffinity: &infrastructurev1.HardwareAffinity{
+			ScoreExpression: `hardware.metadata.labels["rack"] == "bar" ? 100 : 0`,
+		},
+	}, testOptions{
+		HardwareAffinity: &infrastructurev1.HardwareAffinity{
+			RequiredExpression: `hardware.metadata.labels["rack"] in ["foo", "baz"]`,
+		},
+	})
+}
+
+// The annotations of all Hardware but the one selected by each machine miss the looked up key, which fails the
+// evaluation of the expressions against it.
+func machineReconciliationSelectsHardwareSkippingHardwareExpressionsFailToBeEvaluatedAgainst(t *testing.T) {
+	machineReconciliationHardwareAffinityHelper(t, testOptions{
+		HardwareAffinity: &infrastructurev1.HardwareAffinity{
+			RequiredExpression: `hardware.metadata.labels["rack"] == "foo" || hardware.metadata.annotations["x"] == "y"`,
+		},
+	}, testOptions{
+		HardwareAffinity: &infrastructurev1.HardwareAffinity{
+			ScoreExpression: `hardware.metadata.labels["rack"] == "bar" ? 100 : size(hardware.metadata.annotations["x"])`,
+		},
+	}, testOptions{
+		HardwareAffinity: &infrastructurev1.HardwareAffinity{
+			RequiredExpression: `hardware.metadata.labels["rack"] == "baz"`,
+		},
+	})
+}
+
 //nolint:funlen
 func machineReconciliationHardwareAffinityHelper(t *testing.T, fooOptions testOptions, barOptions testOptions, bazOptions testOptions) {
 	t.Helper()
@@ -0,0 +1,22 @@
+package machine
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/tracing"
+)
+
+// trace starts a span as a child of the current span of the scope, making it the parent of the spans started until
+// the returned function is called with the outcome of the traced operation.
+func (scope *machineReconcileScope) trace(name string, attrs ...attribute.KeyValue) func(error) {
+	parent := scope.ctx
+
+	ctx, span := tracing.Start(parent, name, attrs...)
+	scope.ctx = ctx
+
+	return func(err error) {
+		tracing.End(span, err)
+
+		scope.ctx = parent
+	}
+}
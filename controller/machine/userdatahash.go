@@ -0,0 +1,59 @@
+package machine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+)
+
+// HardwareUserDataHashAnnotation is set by CAPT on claimed Hardware to the hash of the bootstrap data, its format
+// and the provider ID its user-data was rendered from, along with the rendered user-data. While it matches, the
+// user-data is not rendered again, sparing the parsing and serializing of Ignition configs on every resync.
+const HardwareUserDataHashAnnotation = "v1alpha1.tinkerbell.org/user-data-hash"
+
+// HardwareBootstrapSecretUIDAnnotation is set by CAPT on claimed Hardware to the UID of the bootstrap data Secret its
+// user-data was rendered from. A different UID means the Secret was recreated, e.g. when the management cluster was
+// restored from a backup, so the user-data is rendered again even when the bootstrap data looks unchanged.
+const HardwareBootstrapSecretUIDAnnotation = "v1alpha1.tinkerbell.org/bootstrap-secret-uid"
+
+// userDataHash returns the hash of the inputs the user-data of Hardware is rendered from and of the user-data.
+func userDataHash(format BootstrapFormat, bootstrapData, providerID, userData string) string {
+	h := sha256.New()
+
+	for _, s := range []string{string(format), providerID, bootstrapData, userData} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// userDataUpToDate returns true when the user-data of the Hardware was rendered from the bootstrap data of the
+// machine with the given provider ID and was not changed since.
+func (scope *machineReconcileScope) userDataUpToDate(hw *tinkv1.Hardware, providerID string) bool {
+	hash, ok := hw.GetAnnotations()[HardwareUserDataHashAnnotation]
+	if !ok || hw.Spec.UserData == nil {
+		return false
+	}
+
+	return hash == userDataHash(scope.bootstrapFormat, scope.bootstrapCloudConfig, providerID, *hw.Spec.UserData)
+}
+
+// bootstrapSecretRecreated returns true when the user-data of the Hardware was rendered from another bootstrap data
+// Secret than the current one of the same name. Hardware claimed before the UID was recorded is not considered.
+func (scope *machineReconcileScope) bootstrapSecretRecreated(hw *tinkv1.Hardware) bool {
+	uid, ok := hw.GetAnnotations()[HardwareBootstrapSecretUIDAnnotation]
+
+	return ok && scope.bootstrapSecretUID != "" && uid != string(scope.bootstrapSecretUID)
+}
+
+// userDataAnnotations returns the annotations recording the inputs the user-data of Hardware was rendered from.
+func (scope *machineReconcileScope) userDataAnnotations(hash string) map[string]string {
+	annotations := map[string]string{HardwareUserDataHashAnnotation: hash}
+	if scope.bootstrapSecretUID != "" {
+		annotations[HardwareBootstrapSecretUIDAnnotation] = string(scope.bootstrapSecretUID)
+	}
+
+	return annotations
+}
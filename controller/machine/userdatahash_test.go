@@ -0,0 +1,169 @@
+package machine //nolint:testpackage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+const userDataProviderID = "tinkerbell://default/hw"
+
+// ignitionConfig returns an Ignition config with the given number of files referencing the provider ID.
+func ignitionConfig(files int) string {
+	entries := make([]string, 0, files)
+	for i := range files {
+		entries = append(entries, fmt.Sprintf(
+			`{"path":"/etc/file-%d","contents":{"source":"data:,providerID%%3A%%20PROVIDER_ID"}}`, i))
+	}
+
+	return `{"ignition":{"version":"3.3.0"},"storage":{"files":[` + strings.Join(entries, ",") + `]}}`
+}
+
+func Test_ensureHardwareUserData_renders_changed_inputs_only(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+
+	hw := &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hw).Build()
+
+	scope := &machineReconcileScope{
+		log:    logr.Discard(),
+		ctx:    context.Background(),
+		client: c,
+		tinkerbellMachine: &infrastructurev1.TinkerbellMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+		},
+		bootstrapFormat:      BootstrapFormatIgnition,
+		bootstrapCloudConfig: ignitionConfig(1),
+	}
+
+	current := func() *tinkv1.Hardware {
+		updated := &tinkv1.Hardware{}
+		g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(hw), updated)).To(Succeed())
+
+		return updated
+	}
+
+	g.Expect(scope.ensureHardwareUserData(current(), userDataProviderID)).To(Succeed())
+	g.Expect(*current().Spec.UserData).To(ContainSubstring("providerID:%20tinkerbell:%2F%2Fdefault%2Fhw"))
+	g.Expect(current().Annotations).To(HaveKey(HardwareUserDataHashAnnotation))
+	g.Expect(scope.userDataUpToDate(current(), userDataProviderID)).To(BeTrue())
+
+	// Changed bootstrap data is rendered again.
+	scope.bootstrapCloudConfig = ignitionConfig(2)
+	g.Expect(scope.userDataUpToDate(current(), userDataProviderID)).To(BeFalse())
+	g.Expect(scope.ensureHardwareUserData(current(), userDataProviderID)).To(Succeed())
+	g.Expect(*current().Spec.UserData).To(ContainSubstring("/etc/file-1"))
+
+	// User-data changed by someone else is rendered again.
+	edited := current()
+	edited.Spec.UserData = ptr.To("edited")
+	g.Expect(c.Update(context.Background(), edited)).To(Succeed())
+	g.Expect(scope.userDataUpToDate(current(), userDataProviderID)).To(BeFalse())
+	g.Expect(scope.ensureHardwareUserData(current(), userDataProviderID)).To(Succeed())
+	g.Expect(*current().Spec.UserData).To(ContainSubstring("/etc/file-1"))
+}
+
+func Test_ensureHardwareUserData_refreshes_user_data_of_recreated_secret(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+
+	hw := &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hw).Build()
+
+	scope := &machineReconcileScope{
+		log:    logr.Discard(),
+		ctx:    context.Background(),
+		client: c,
+		tinkerbellMachine: &infrastructurev1.TinkerbellMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+		},
+		bootstrapFormat:      BootstrapFormatIgnition,
+		bootstrapCloudConfig: ignitionConfig(1),
+	}
+
+	current := func() *tinkv1.Hardware {
+		updated := &tinkv1.Hardware{}
+		g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(hw), updated)).To(Succeed())
+
+		return updated
+	}
+
+	// Hardware claimed before the UID was recorded gets it recorded without being considered recreated.
+	g.Expect(scope.ensureHardwareUserData(current(), userDataProviderID)).To(Succeed())
+	g.Expect(current().Annotations).NotTo(HaveKey(HardwareBootstrapSecretUIDAnnotation))
+
+	scope.bootstrapSecretUID = "uid-1"
+	g.Expect(scope.bootstrapSecretRecreated(current())).To(BeFalse())
+	g.Expect(scope.ensureHardwareUserData(current(), userDataProviderID)).To(Succeed())
+	g.Expect(current().Annotations).To(HaveKeyWithValue(HardwareBootstrapSecretUIDAnnotation, "uid-1"))
+
+	// A recreated Secret refreshes the user-data even when the bootstrap data is unchanged.
+	scope.bootstrapSecretUID = "uid-2"
+	g.Expect(scope.userDataUpToDate(current(), userDataProviderID)).To(BeTrue())
+	g.Expect(scope.bootstrapSecretRecreated(current())).To(BeTrue())
+	g.Expect(scope.ensureHardwareUserData(current(), userDataProviderID)).To(Succeed())
+	g.Expect(current().Annotations).To(HaveKeyWithValue(HardwareBootstrapSecretUIDAnnotation, "uid-2"))
+	g.Expect(scope.bootstrapSecretRecreated(current())).To(BeFalse())
+
+	// The user-data rendered from a recreated Secret with changed bootstrap data is updated.
+	scope.bootstrapSecretUID = "uid-3"
+	scope.bootstrapCloudConfig = ignitionConfig(2)
+	g.Expect(scope.ensureHardwareUserData(current(), userDataProviderID)).To(Succeed())
+	g.Expect(*current().Spec.UserData).To(ContainSubstring("/etc/file-1"))
+	g.Expect(current().Annotations).To(HaveKeyWithValue(HardwareBootstrapSecretUIDAnnotation, "uid-3"))
+}
+
+// Benchmark_userData compares rendering the user-data of Hardware from a large Ignition config, as done on every
+// resync before, with checking the hash of the inputs and of the user-data rendered from them.
+func Benchmark_userData(b *testing.B) {
+	scope := &machineReconcileScope{bootstrapFormat: BootstrapFormatIgnition, bootstrapCloudConfig: ignitionConfig(200)}
+
+	userData, err := injectProviderID(scope.bootstrapFormat, scope.bootstrapCloudConfig, userDataProviderID)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			HardwareUserDataHashAnnotation: userDataHash(scope.bootstrapFormat, scope.bootstrapCloudConfig,
+				userDataProviderID, userData),
+		}},
+		Spec: tinkv1.HardwareSpec{UserData: &userData},
+	}
+
+	b.Run("render", func(b *testing.B) {
+		for range b.N {
+			rendered, err := injectProviderID(scope.bootstrapFormat, scope.bootstrapCloudConfig, userDataProviderID)
+			if err != nil || rendered != *hw.Spec.UserData {
+				b.Fatal("unexpected user-data", err)
+			}
+		}
+	})
+
+	b.Run("hash", func(b *testing.B) {
+		for range b.N {
+			if !scope.userDataUpToDate(hw, userDataProviderID) {
+				b.Fatal("expected user-data to be up to date")
+			}
+		}
+	})
+}
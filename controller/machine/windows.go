@@ -0,0 +1,86 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+const (
+	// defaultWindowsUsername is the user cloudbase-init creates on Windows images of Hardware whose BMC credentials
+	// have no username.
+	defaultWindowsUsername = "Admin"
+
+	// maxWindowsUsernameLength is the maximum length of a Windows user name.
+	maxWindowsUsernameLength = 20
+
+	// windowsUsernameInvalidCharacters are the characters Windows does not allow in user names.
+	windowsUsernameInvalidCharacters = `"/\[]:;|=,+*?<>@`
+)
+
+// validWindowsUsername returns whether the name is a valid Windows user name, which also keeps it from breaking
+// out of its line of the cloudbase-init configuration.
+func validWindowsUsername(name string) bool {
+	if name == "" || len(name) > maxWindowsUsernameLength || strings.ContainsAny(name, windowsUsernameInvalidCharacters) {
+		return false
+	}
+
+	for _, r := range name {
+		if r < ' ' || r == 0x7f {
+			return false
+		}
+	}
+
+	return strings.Trim(name, ". ") != ""
+}
+
+// windowsUsername returns the user cloudbase-init creates on Windows images: the username of the BMC credentials of
+// the first rufio Machine of the Hardware having one, so the same operators administer the BMC and the OS, or
+// defaultWindowsUsername. It returns "" for Linux images.
+func (scope *machineReconcileScope) windowsUsername(hw *tinkv1.Hardware) (string, error) {
+	if !scope.tinkerbellMachine.Spec.Image.Windows() {
+		return "", nil
+	}
+
+	for _, name := range hardwareutil.BMCRefs(hw) {
+		bmc := &rufiov1.Machine{}
+		key := client.ObjectKey{Namespace: scope.hardwareNamespace(), Name: name}
+
+		if err := scope.client.Get(scope.ctx, key, bmc); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return "", fmt.Errorf("getting rufio Machine %s: %w", key, err)
+		}
+
+		ref := bmc.Spec.Connection.AuthSecretRef
+		if ref.Name == "" {
+			continue
+		}
+
+		secret := &corev1.Secret{}
+		key = client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}
+
+		if err := scope.client.Get(scope.ctx, key, secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return "", fmt.Errorf("getting BMC Secret %s: %w", key, err)
+		}
+
+		if username := string(secret.Data["username"]); username != "" {
+			return username, nil
+		}
+	}
+
+	return defaultWindowsUsername, nil
+}
@@ -6,25 +6,151 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
 
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
 	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
 )
 
 // errWorkflowFailed is the error returned when the workflow fails.
 var errWorkflowFailed = errors.New("workflow failed")
 
-// errISOBootURLRequired is the error returned when the isoURL is required for iso boot mode.
-var errISOBootURLRequired = errors.New("iso boot mode requires an isoURL")
+// workflowFailureOutputLines is the number of trailing lines of a failed action's output which are
+// surfaced on the TinkerbellMachine.
+const workflowFailureOutputLines = 10
+
+// workflowTerminationRequeueAfter is how often the teardown of a machine checks whether its running workflows
+// finished.
+const workflowTerminationRequeueAfter = 15 * time.Second
+
+var (
+	// errISOBootURLRequired is the error returned when the isoURL is required for iso boot mode.
+	errISOBootURLRequired = errors.New("iso boot mode requires an isoURL")
+
+	// errISOBootBMCRefRequired is the error returned when iso boot mode is used with Hardware without a BMC.
+	errISOBootBMCRefRequired = errors.New("iso boot mode requires Hardware with a bmcRef")
+
+	// ErrWorkerDeviceUnavailable is the error returned when the Hardware lacks the field identifying the worker
+	// of its workflows.
+	ErrWorkerDeviceUnavailable = errors.New("hardware has no worker device identifier")
+
+	// ErrPinnedInterfaceNotFound is the error returned when the Hardware has no interface with the MAC address
+	// pinned by the machine.
+	ErrPinnedInterfaceNotFound = errors.New("hardware has no interface with the pinned MAC address")
+)
+
+// defaultWorkerDeviceKey is the key of the Hardware in the hardware map of workflows, unless configured otherwise.
+const defaultWorkerDeviceKey = "device_1"
+
+// workerDeviceKey returns the key of the Hardware in the hardware map of the workflows of the machine.
+func (scope *machineReconcileScope) workerDeviceKey() string {
+	if key := scope.tinkerbellMachine.Spec.WorkerDevice.Key; key != "" {
+		return key
+	}
+
+	return defaultWorkerDeviceKey
+}
+
+// pinnedInterface returns the interface of the hardware with the MAC address pinned by the machine, nil when the
+// machine pins none.
+func (scope *machineReconcileScope) pinnedInterface(hw *tinkv1.Hardware) (*tinkv1.Interface, error) {
+	mac := scope.tinkerbellMachine.Spec.WorkerDevice.MAC
+	if mac == "" {
+		return nil, nil //nolint:nilnil // The machine pins no interface.
+	}
+
+	for i, iface := range hw.Spec.Interfaces {
+		if iface.DHCP != nil && strings.EqualFold(iface.DHCP.MAC, mac) {
+			return &hw.Spec.Interfaces[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s on Hardware %s", ErrPinnedInterfaceNotFound, mac, hw.Name)
+}
+
+// pinnedInterfaceHardware returns the given Hardware which has the interface pinned by the machine. When none of it
+// has, the returned error lists it.
+func (scope *machineReconcileScope) pinnedInterfaceHardware(hardware []tinkv1.Hardware) ([]tinkv1.Hardware, error) {
+	if scope.tinkerbellMachine.Spec.WorkerDevice.MAC == "" {
+		return hardware, nil
+	}
+
+	matching := make([]tinkv1.Hardware, 0, len(hardware))
+	notMatching := []error{}
+
+	for i := range hardware {
+		if _, err := scope.pinnedInterface(&hardware[i]); err != nil {
+			notMatching = append(notMatching, err)
+
+			continue
+		}
+
+		matching = append(matching, hardware[i])
+	}
+
+	if len(matching) == 0 && len(notMatching) > 0 {
+		return nil, errors.Join(notMatching...)
+	}
+
+	return matching, nil
+}
+
+// workerDevice returns the identifier of the Hardware as the worker of the workflows of the machine.
+func (scope *machineReconcileScope) workerDevice(hw *tinkv1.Hardware) (string, error) {
+	var device string
+
+	source := scope.tinkerbellMachine.Spec.WorkerDevice.Source
+
+	switch source {
+	case v1beta1.WorkerDeviceSourceMAC:
+		pinned, err := scope.pinnedInterface(hw)
+		if err != nil {
+			return "", err
+		}
+
+		primary, err := scope.bondPrimaryInterface(hw)
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case pinned != nil:
+			device = pinned.DHCP.MAC
+		case primary != nil:
+			device = primary.DHCP.MAC
+		case len(hw.Spec.Interfaces) > 0 && hw.Spec.Interfaces[0].DHCP != nil:
+			device = hw.Spec.Interfaces[0].DHCP.MAC
+		}
+	case v1beta1.WorkerDeviceSourceHardwareName:
+		device = hw.Name
+	default:
+		source = v1beta1.WorkerDeviceSourceInstanceID
+
+		if hw.Spec.Metadata != nil && hw.Spec.Metadata.Instance != nil {
+			device = hw.Spec.Metadata.Instance.ID
+		}
+	}
+
+	if device == "" {
+		return "", fmt.Errorf("%w: %s of Hardware %s is not set", ErrWorkerDeviceUnavailable, source, hw.Name)
+	}
+
+	return device, nil
+}
 
 func (scope *machineReconcileScope) getWorkflow() (*tinkv1.Workflow, error) {
 	namespacedName := types.NamespacedName{
-		Name:      scope.tinkerbellMachine.Name,
-		Namespace: scope.tinkerbellMachine.Namespace,
+		Name:      scope.workflowName(),
+		Namespace: scope.hardwareNamespace(),
 	}
 
 	t := &tinkv1.Workflow{}
@@ -41,39 +167,59 @@ func (scope *machineReconcileScope) getWorkflow() (*tinkv1.Workflow, error) {
 	return t, nil
 }
 
-func (scope *machineReconcileScope) createWorkflow(hw *tinkv1.Hardware) error {
-	c := true
+// isoBoot returns true when the machine boots into the provisioning environment from virtual media instead of PXE.
+func (scope *machineReconcileScope) isoBoot() bool {
+	return scope.tinkerbellMachine.Spec.BootOptions.BootMode == v1beta1.BootModeISO
+}
+
+// createWorkflow creates the Workflow with the given name, running the named Template on the hardware.
+func (scope *machineReconcileScope) createWorkflow(name, templateName string, hw *tinkv1.Hardware) error {
+	device, err := scope.workerDevice(hw)
+	if err != nil {
+		return err
+	}
+
 	workflow := &tinkv1.Workflow{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      scope.tinkerbellMachine.Name,
-			Namespace: scope.tinkerbellMachine.Namespace,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
-					Kind:       "TinkerbellMachine",
-					Name:       scope.tinkerbellMachine.Name,
-					UID:        scope.tinkerbellMachine.ObjectMeta.UID,
-					Controller: &c,
-				},
-			},
+			Name:      name,
+			Namespace: scope.hardwareNamespace(),
 		},
 		Spec: tinkv1.WorkflowSpec{
-			TemplateRef: scope.tinkerbellMachine.Name,
+			TemplateRef: templateName,
 			HardwareRef: hw.Name,
-			HardwareMap: map[string]string{"device_1": hw.Spec.Metadata.Instance.ID},
+			HardwareMap: map[string]string{scope.workerDeviceKey(): device},
 			BootOptions: tinkv1.BootOptions{
-				ToggleAllowNetboot: true,
+				// Tinkerbell toggles netboot on all interfaces, so only let it do so when CAPT does
+				// not manage the netboot state of specific interfaces itself. Machines booting from an
+				// ISO must never be allowed to PXE boot.
+				ToggleAllowNetboot: !scope.isoBoot() && len(managedNetbootInterfaces(hw)) == 0,
 			},
 		},
 	}
 
+	if scope.isoBoot() && hw.Spec.BMCRef == nil {
+		return errISOBootBMCRefRequired
+	}
+
+	// The cluster labels let provisioning slots be counted per cluster.
+	if scope.machine != nil && scope.machine.Spec.ClusterName != "" {
+		workflow.Labels = map[string]string{
+			clusterv1.ClusterNameLabel:    scope.machine.Spec.ClusterName,
+			HardwareClusterNamespaceLabel: scope.machine.Namespace,
+		}
+	}
+
+	scope.setOwner(workflow, true)
+	scope.propagateMetadata(workflow)
+
 	// We check the BMCRef so that the implementation behaves similar to how it was when
-	// CAPT was creating the BMCJob.
-	if hw.Spec.BMCRef != nil {
+	// CAPT was creating the BMCJob. Tinkerbell creates no BMCJob without a boot mode, which
+	// leaves the power of externally managed Hardware alone.
+	if scope.managesPower(hw) {
 		switch scope.tinkerbellMachine.Spec.BootOptions.BootMode {
-		case v1beta1.BootMode("netboot"):
-			workflow.Spec.BootOptions.BootMode = tinkv1.BootMode("netboot")
-		case v1beta1.BootMode("iso"):
+		case v1beta1.BootModeNetboot:
+			workflow.Spec.BootOptions.BootMode = tinkv1.BootModeNetboot
+		case v1beta1.BootModeISO:
 			if scope.tinkerbellMachine.Spec.BootOptions.ISOURL == "" {
 				return errISOBootURLRequired
 			}
@@ -87,7 +233,7 @@ func (scope *machineReconcileScope) createWorkflow(hw *tinkv1.Hardware) error {
 			u.Path = path.Join(urlPath, strings.Replace(hw.Spec.Metadata.Instance.ID, ":", "-", 5), file)
 
 			workflow.Spec.BootOptions.ISOURL = u.String()
-			workflow.Spec.BootOptions.BootMode = tinkv1.BootMode("iso")
+			workflow.Spec.BootOptions.BootMode = tinkv1.BootModeISO
 		}
 	}
 
@@ -95,14 +241,139 @@ func (scope *machineReconcileScope) createWorkflow(hw *tinkv1.Hardware) error {
 		return fmt.Errorf("creating workflow: %w", err)
 	}
 
+	scope.requestPowerAction(hw, fmt.Sprintf("power cycle into a network boot to run workflow %s", name))
+
 	return nil
 }
 
-// removeWorkflow makes sure workflow for TinkerbellMachine has been cleaned up.
+// removeWorkflow makes sure workflows for TinkerbellMachine, including the ones of workflow stages, have been
+// cleaned up.
 func (scope *machineReconcileScope) removeWorkflow() error {
+	for _, name := range scope.workflowNames() {
+		if err := scope.removeWorkflowNamed(name); err != nil {
+			return err
+		}
+	}
+
+	scope.tinkerbellMachine.Status.Workflow = nil
+
+	return nil
+}
+
+// waitForWorkflowTermination reports whether the teardown of the machine must wait for its running workflows to
+// finish, so tink-worker is not left writing to the disk of Hardware which is then released and claimed for another
+// machine. Tinkerbell cannot abort workflows, so the teardown waits for up to the workflow termination timeout after
+// the deletion of the TinkerbellMachine, and then stops the workflows, see stopTimedOutWorkflows. Without a timeout
+// the workflows are stopped right away.
+func (scope *machineReconcileScope) waitForWorkflowTermination(hw *tinkv1.Hardware) (bool, error) {
+	var running []string
+
+	for _, name := range scope.workflowNames() {
+		wf := &tinkv1.Workflow{}
+
+		err := scope.client.Get(scope.ctx, types.NamespacedName{Name: name, Namespace: scope.hardwareNamespace()}, wf)
+
+		switch {
+		case apierrors.IsNotFound(err):
+			continue
+		case err != nil:
+			return false, fmt.Errorf("getting workflow %s: %w", name, err)
+		}
+
+		if wf.Status.State == tinkv1.WorkflowStateRunning {
+			running = append(running, name)
+		}
+	}
+
+	if len(running) == 0 {
+		return false, nil
+	}
+
+	remaining := time.Until(scope.tinkerbellMachine.DeletionTimestamp.Add(scope.workflowTerminationTimeout))
+	if remaining <= 0 {
+		return scope.stopTimedOutWorkflows(hw, running)
+	}
+
+	scope.log.Info("Waiting for running workflows to finish before releasing hardware", "workflows", running)
+	record.Eventf(scope.tinkerbellMachine, "WaitingForWorkflowTermination",
+		"Waiting for running workflows to finish before releasing hardware: %s", strings.Join(running, ", "))
+	scope.requeue(min(remaining, workflowTerminationRequeueAfter))
+
+	return true, nil
+}
+
+// stopTimedOutWorkflows keeps Hardware whose workflows are still running after the workflow termination timeout
+// from being claimed while tink-worker may still write to its disk. Hardware whose power is managed by CAPT is
+// powered off before the teardown continues; other Hardware, and Hardware which could not be powered off, is
+// quarantined, so it is released without returning to the pool. It reports whether the teardown must wait for the
+// Hardware to be powered off.
+func (scope *machineReconcileScope) stopTimedOutWorkflows(hw *tinkv1.Hardware, running []string) (bool, error) {
+	workflows := strings.Join(running, ", ")
+
+	if scope.managesPower(hw) {
+		bmcJob, err := scope.ensureBMCJob(bmcJobOperationPowerOff, hw, []rufiov1.Action{
+			{
+				PowerAction: rufiov1.PowerHardOff.Ptr(),
+			},
+		})
+		if err != nil {
+			return false, fmt.Errorf("ensuring power off BMCJob: %w", err)
+		}
+
+		scope.setBMCJobCondition(bmcJobOperationPowerOff, bmcJob)
+
+		if bmcJob.HasCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue) {
+			scope.log.Info("Powered off Hardware with workflows still running after the workflow termination timeout",
+				"workflows", running, "timeout", scope.workflowTerminationTimeout)
+
+			return false, nil
+		}
+
+		if !bmcJob.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue) || scope.bmcJobRetryPending(bmcJob) {
+			scope.log.Info("Powering off Hardware with workflows still running after the workflow termination timeout",
+				"workflows", running, "timeout", scope.workflowTerminationTimeout)
+			record.Warnf(scope.tinkerbellMachine, "WorkflowTerminationTimeout",
+				"Workflows %s still running after %s, powering off Hardware %s", workflows,
+				scope.workflowTerminationTimeout, hw.Name)
+
+			return true, scope.patch()
+		}
+	}
+
+	if hw.GetLabels()[HardwareQuarantinedLabel] != "" {
+		return false, nil
+	}
+
+	patchHelper, err := patch.NewHelper(hw, scope.client)
+	if err != nil {
+		return false, fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	if hw.Labels == nil {
+		hw.Labels = map[string]string{}
+	}
+
+	hw.Labels[HardwareQuarantinedLabel] = "true"
+
+	if err := patchHelper.Patch(scope.ctx, hw); err != nil {
+		return false, fmt.Errorf("patching Hardware object: %w", err)
+	}
+
+	scope.log.Info("Quarantined Hardware with workflows still running after the workflow termination timeout",
+		"workflows", running, "timeout", scope.workflowTerminationTimeout, "Hardware name", hw.Name)
+
+	msg := "Workflows %s still running after %s, Hardware %s quarantined as its disk may still be written to"
+	record.Warnf(scope.tinkerbellMachine, "WorkflowTerminationTimeout", msg, workflows,
+		scope.workflowTerminationTimeout, hw.Name)
+	record.Warnf(hw, "HardwareQuarantined", msg, workflows, scope.workflowTerminationTimeout, hw.Name)
+
+	return false, nil
+}
+
+func (scope *machineReconcileScope) removeWorkflowNamed(name string) error {
 	namespacedName := types.NamespacedName{
-		Name:      scope.tinkerbellMachine.Name,
-		Namespace: scope.tinkerbellMachine.Namespace,
+		Name:      name,
+		Namespace: scope.hardwareNamespace(),
 	}
 
 	workflow := &tinkv1.Workflow{}
@@ -126,3 +397,124 @@ func (scope *machineReconcileScope) removeWorkflow() error {
 
 	return nil
 }
+
+// failedAction returns the first action of the workflow which failed or timed out along with the name of its task.
+// A nil action is returned when no action failed, e.g. when the workflow as a whole timed out.
+func failedAction(wf *tinkv1.Workflow) (string, *tinkv1.Action) {
+	for _, task := range wf.Status.Tasks {
+		for i := range task.Actions {
+			action := &task.Actions[i]
+			if action.Status == tinkv1.WorkflowStateFailed || action.Status == tinkv1.WorkflowStateTimeout {
+				return task.Name, action
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// tailLines returns the last n non-empty lines of s.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// workflowFailureMessage describes why the given workflow failed, including the failing action and the
+// tail of its output, so users do not have to dig through the Workflow and tink-worker logs.
+func workflowFailureMessage(wf *tinkv1.Workflow) string {
+	task, action := failedAction(wf)
+	if action == nil {
+		return fmt.Sprintf("workflow %s/%s finished with state %s", wf.Namespace, wf.Name, wf.Status.State)
+	}
+
+	msg := fmt.Sprintf("action %q of task %q finished with state %s", action.Name, task, action.Status)
+
+	if output := tailLines(action.Message, workflowFailureOutputLines); output != "" {
+		msg = fmt.Sprintf("%s: %s", msg, output)
+	}
+
+	return msg
+}
+
+// markWorkflowFailed surfaces a failed workflow on the TinkerbellMachine through the WorkflowSucceeded
+// condition and a warning event. The event is only recorded when the failure is first observed.
+func (scope *machineReconcileScope) markWorkflowFailed(wf *tinkv1.Workflow) {
+	reason := v1beta1.WorkflowFailedReason
+	if wf.Status.State == tinkv1.WorkflowStateTimeout {
+		reason = v1beta1.WorkflowTimeoutReason
+	}
+
+	msg := workflowFailureMessage(wf)
+
+	previous := conditions.Get(scope.tinkerbellMachine, v1beta1.WorkflowSucceededCondition)
+	if previous == nil || previous.Reason != reason || previous.Message != msg {
+		record.Warn(scope.tinkerbellMachine, reason, msg)
+	}
+
+	conditions.MarkFalse(scope.tinkerbellMachine, v1beta1.WorkflowSucceededCondition, reason,
+		clusterv1.ConditionSeverityError, "%s", msg)
+}
+
+// finalActionGraceRemaining returns how long the final action of the running workflow still has to report before
+// it is assumed to have succeeded, once all other actions succeeded. Actions like kexec replace the operating
+// system running tink-worker and never report success, leaving the workflow running. It returns false when the
+// workflow is not waiting for its final action only.
+func finalActionGraceRemaining(wf *tinkv1.Workflow, grace time.Duration, now time.Time) (time.Duration, bool) {
+	if wf.Status.State != tinkv1.WorkflowStateRunning {
+		return 0, false
+	}
+
+	var actions []tinkv1.Action
+	for _, task := range wf.Status.Tasks {
+		actions = append(actions, task.Actions...)
+	}
+
+	if len(actions) == 0 {
+		return 0, false
+	}
+
+	for _, action := range actions[:len(actions)-1] {
+		if action.Status != tinkv1.WorkflowStateSuccess {
+			return 0, false
+		}
+	}
+
+	final := actions[len(actions)-1]
+	if final.Status != tinkv1.WorkflowStateRunning || final.StartedAt == nil {
+		return 0, false
+	}
+
+	return max(0, grace-now.Sub(final.StartedAt.Time)), true
+}
+
+// workflowSucceeded returns whether the workflow succeeded, either by reporting so or, when the final action grace
+// period is enabled, by its final action running for longer than the grace period.
+func (scope *machineReconcileScope) workflowSucceeded(wf *tinkv1.Workflow) bool {
+	if wf.Status.State == tinkv1.WorkflowStateSuccess {
+		return true
+	}
+
+	if scope.finalActionGracePeriod <= 0 {
+		return false
+	}
+
+	remaining, waiting := finalActionGraceRemaining(wf, scope.finalActionGracePeriod, time.Now())
+	if !waiting {
+		return false
+	}
+
+	if remaining > 0 {
+		scope.requeue(remaining)
+
+		return false
+	}
+
+	scope.log.Info("Assuming final workflow action succeeded after grace period", "workflow", wf.Name,
+		"action", wf.Status.CurrentAction)
+
+	return true
+}
@@ -0,0 +1,182 @@
+package machine //nolint:testpackage
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_finalActionGraceRemaining(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	grace := 5 * time.Minute
+
+	workflow := func(state tinkv1.WorkflowState, actions ...tinkv1.Action) *tinkv1.Workflow {
+		return &tinkv1.Workflow{Status: tinkv1.WorkflowStatus{
+			State: state,
+			Tasks: []tinkv1.Task{{Actions: actions}},
+		}}
+	}
+	succeeded := tinkv1.Action{Name: "stream image", Status: tinkv1.WorkflowStateSuccess}
+	kexec := func(status tinkv1.WorkflowState, startedAgo time.Duration) tinkv1.Action {
+		return tinkv1.Action{Name: "kexec image", Status: status, StartedAt: &metav1.Time{Time: now.Add(-startedAgo)}}
+	}
+
+	tests := map[string]struct {
+		wf        *tinkv1.Workflow
+		remaining time.Duration
+		waiting   bool
+	}{
+		"final action just started": {
+			wf:        workflow(tinkv1.WorkflowStateRunning, succeeded, kexec(tinkv1.WorkflowStateRunning, time.Minute)),
+			remaining: 4 * time.Minute,
+			waiting:   true,
+		},
+		"final action running past the grace period": {
+			wf:      workflow(tinkv1.WorkflowStateRunning, succeeded, kexec(tinkv1.WorkflowStateRunning, time.Hour)),
+			waiting: true,
+		},
+		"earlier action still running": {
+			wf: workflow(tinkv1.WorkflowStateRunning, kexec(tinkv1.WorkflowStateRunning, time.Hour),
+				tinkv1.Action{Name: "kexec image", Status: tinkv1.WorkflowStatePending}),
+		},
+		"earlier action failed": {
+			wf: workflow(tinkv1.WorkflowStateRunning,
+				tinkv1.Action{Name: "stream image", Status: tinkv1.WorkflowStateFailed},
+				kexec(tinkv1.WorkflowStateRunning, time.Hour)),
+		},
+		"final action failed": {
+			wf: workflow(tinkv1.WorkflowStateFailed, succeeded, kexec(tinkv1.WorkflowStateFailed, time.Hour)),
+		},
+		"final action not started": {
+			wf: workflow(tinkv1.WorkflowStateRunning, succeeded,
+				tinkv1.Action{Name: "kexec image", Status: tinkv1.WorkflowStateRunning}),
+		},
+		"workflow without actions": {
+			wf: workflow(tinkv1.WorkflowStateRunning),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			remaining, waiting := finalActionGraceRemaining(tc.wf, grace, now)
+			g.Expect(waiting).To(Equal(tc.waiting))
+			g.Expect(remaining).To(Equal(tc.remaining))
+		})
+	}
+}
+
+func Test_workflowSucceeded(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	wf := &tinkv1.Workflow{Status: tinkv1.WorkflowStatus{
+		State: tinkv1.WorkflowStateRunning,
+		Tasks: []tinkv1.Task{{Actions: []tinkv1.Action{{
+			Name:      "kexec image",
+			Status:    tinkv1.WorkflowStateRunning,
+			StartedAt: &metav1.Time{Time: time.Now().Add(-time.Minute)},
+		}}}},
+	}}
+
+	scope := &machineReconcileScope{}
+	g.Expect(scope.workflowSucceeded(wf)).To(BeFalse(), "Expected the workflow to be waited for without grace period")
+
+	scope.finalActionGracePeriod = time.Hour
+	g.Expect(scope.workflowSucceeded(wf)).To(BeFalse())
+	g.Expect(scope.requeueAfter).To(BeNumerically("~", 59*time.Minute, time.Minute))
+
+	scope.finalActionGracePeriod = 30 * time.Second
+	g.Expect(scope.workflowSucceeded(wf)).To(BeTrue())
+
+	wf.Status.State = tinkv1.WorkflowStateSuccess
+	scope.finalActionGracePeriod = 0
+	g.Expect(scope.workflowSucceeded(wf)).To(BeTrue())
+}
+
+func Test_workerDevice(t *testing.T) {
+	t.Parallel()
+
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: "hw-1"},
+		Spec: tinkv1.HardwareSpec{
+			Interfaces: []tinkv1.Interface{
+				{DHCP: &tinkv1.DHCP{MAC: "00:00:5e:00:53:01"}},
+				{DHCP: &tinkv1.DHCP{MAC: "00:00:5e:00:53:02"}},
+			},
+			Metadata: &tinkv1.HardwareMetadata{Instance: &tinkv1.MetadataInstance{ID: "instance-1"}},
+		},
+	}
+
+	tests := map[string]struct {
+		device  v1beta1.WorkerDevice
+		hw      *tinkv1.Hardware
+		wantKey string
+		want    string
+		wantErr error
+	}{
+		"default":    {hw: hw, wantKey: "device_1", want: "instance-1"},
+		"custom key": {device: v1beta1.WorkerDevice{Key: "worker"}, hw: hw, wantKey: "worker", want: "instance-1"},
+		"MAC": {
+			device:  v1beta1.WorkerDevice{Source: v1beta1.WorkerDeviceSourceMAC},
+			hw:      hw,
+			wantKey: "device_1",
+			want:    "00:00:5e:00:53:01",
+		},
+		"pinned MAC": {
+			device:  v1beta1.WorkerDevice{Source: v1beta1.WorkerDeviceSourceMAC, MAC: "00:00:5E:00:53:02"},
+			hw:      hw,
+			wantKey: "device_1",
+			want:    "00:00:5e:00:53:02",
+		},
+		"pinned MAC not on hardware": {
+			device:  v1beta1.WorkerDevice{Source: v1beta1.WorkerDeviceSourceMAC, MAC: "00:00:5e:00:53:03"},
+			hw:      hw,
+			wantKey: "device_1",
+			wantErr: ErrPinnedInterfaceNotFound,
+		},
+		"hardware name": {
+			device:  v1beta1.WorkerDevice{Source: v1beta1.WorkerDeviceSourceHardwareName},
+			hw:      hw,
+			wantKey: "device_1",
+			want:    "hw-1",
+		},
+		"missing instance ID": {
+			hw:      &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "hw-2"}},
+			wantKey: "device_1",
+			wantErr: ErrWorkerDeviceUnavailable,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			scope := &machineReconcileScope{tinkerbellMachine: &v1beta1.TinkerbellMachine{
+				Spec: v1beta1.TinkerbellMachineSpec{WorkerDevice: tc.device},
+			}}
+
+			g.Expect(scope.workerDeviceKey()).To(Equal(tc.wantKey))
+
+			device, err := scope.workerDevice(tc.hw)
+			if tc.wantErr != nil {
+				g.Expect(err).To(MatchError(tc.wantErr))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(device).To(Equal(tc.want))
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// WorkflowStatusReconciler mirrors the status of the Workflows of TinkerbellMachines into the status of the
+// machines whenever a Workflow changes, so the status of a machine shows the progress of its provisioning without
+// waiting for the machine itself to be reconciled.
+type WorkflowStatusReconciler struct {
+	client.Client
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachines/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=workflows,verbs=get;list;watch
+
+// Reconcile mirrors the status of the Workflow into the status of the TinkerbellMachine owning it.
+func (r *WorkflowStatusReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	wf := &tinkv1.Workflow{}
+	if err := r.Client.Get(ctx, req.NamespacedName, wf); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("getting Workflow: %w", err)
+	}
+
+	tm, err := ownerMachine(ctx, r.Client, wf)
+	if err != nil || tm == nil || !tm.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, err
+	}
+
+	if !mirrorsWorkflow(tm, wf.Name) {
+		return ctrl.Result{}, nil
+	}
+
+	status := workflowStatus(wf)
+	if equality.Semantic.DeepEqual(tm.Status.Workflow, status) {
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(tm, r.Client)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("initializing patch helper for TinkerbellMachine: %w", err)
+	}
+
+	tm.Status.Workflow = status
+
+	if err := patchHelper.Patch(ctx, tm); err != nil {
+		return ctrl.Result{}, fmt.Errorf("patching TinkerbellMachine: %w", err)
+	}
+
+	ctrl.LoggerFrom(ctx).V(4).Info("Mirrored workflow status", //nolint:gomnd
+		"tinkerbellMachine", client.ObjectKeyFromObject(tm), "state", status.State, "action", status.CurrentAction)
+
+	return ctrl.Result{}, nil
+}
+
+// ownerMachine returns the TinkerbellMachine which created the object, a Template or Workflow, or nil if there is
+// none. Objects in the namespace of the machine are owned through an owner reference, the ones of pooled Hardware
+// through the owner labels set by setOwner.
+func ownerMachine(ctx context.Context, c client.Client, obj client.Object) (*infrastructurev1.TinkerbellMachine, error) { //nolint:lll
+	if ref := tinkerbellMachineOwner(obj); ref != nil {
+		tm := &infrastructurev1.TinkerbellMachine{}
+
+		err := c.Get(ctx, client.ObjectKey{Namespace: obj.GetNamespace(), Name: ref.Name}, tm)
+
+		switch {
+		case apierrors.IsNotFound(err):
+			return nil, nil
+		case err != nil:
+			return nil, fmt.Errorf("getting TinkerbellMachine: %w", err)
+		case tm.UID != ref.UID:
+			return nil, nil
+		}
+
+		return tm, nil
+	}
+
+	uid, ok := obj.GetLabels()[OwnerUIDLabel]
+	if !ok {
+		return nil, nil
+	}
+
+	machines := &infrastructurev1.TinkerbellMachineList{}
+	if err := c.List(ctx, machines, client.InNamespace(obj.GetLabels()[HardwareOwnerNamespaceLabel])); err != nil {
+		return nil, fmt.Errorf("listing TinkerbellMachines: %w", err)
+	}
+
+	for i := range machines.Items {
+		if string(machines.Items[i].UID) == uid {
+			return &machines.Items[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// tinkerbellMachineOwner returns the owner reference of the TinkerbellMachine owning the object, if any. Templates
+// are owned without being controlled by their TinkerbellMachine.
+func tinkerbellMachineOwner(obj metav1.Object) *metav1.OwnerReference {
+	refs := obj.GetOwnerReferences()
+
+	for i := range refs {
+		if refs[i].Kind == "TinkerbellMachine" {
+			return &refs[i]
+		}
+	}
+
+	return nil
+}
+
+// mirrorsWorkflow returns true when the status of the named Workflow should be mirrored into the status of the
+// machine. The Workflows of a machine run one after the other, so a Workflow is only mirrored when it is not
+// followed by the one already mirrored, which keeps a late event of a finished stage from hiding its successor.
+func mirrorsWorkflow(tm *infrastructurev1.TinkerbellMachine, name string) bool {
+	names := (&machineReconcileScope{tinkerbellMachine: tm}).workflowNames()
+
+	i := slices.Index(names, name)
+	if i < 0 {
+		return false
+	}
+
+	if tm.Status.Workflow == nil {
+		return true
+	}
+
+	return i >= slices.Index(names, tm.Status.Workflow.Name)
+}
+
+// workflowStatus returns the status of the Workflow to mirror into the status of its TinkerbellMachine.
+func workflowStatus(wf *tinkv1.Workflow) *infrastructurev1.WorkflowStatus {
+	status := &infrastructurev1.WorkflowStatus{
+		Name:          wf.Name,
+		State:         string(wf.Status.State),
+		CurrentAction: wf.Status.CurrentAction,
+	}
+
+	if _, action := failedAction(wf); action != nil {
+		status.FailedAction = action.Name
+		status.Output = tailLines(action.Message, workflowFailureOutputLines)
+	}
+
+	return status
+}
+
+// ownedByMachine passes the objects created for TinkerbellMachines.
+//
+//nolint:gochecknoglobals
+var ownedByMachine = predicate.NewPredicateFuncs(func(o client.Object) bool {
+	_, pooled := o.GetLabels()[OwnerUIDLabel]
+
+	return pooled || tinkerbellMachineOwner(o) != nil
+})
+
+// SetupWithManager configures reconciler with a given manager.
+func (r *WorkflowStatusReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("tinkerbellworkflowstatus").
+		WithOptions(options).
+		For(&tinkv1.Workflow{}, builder.WithPredicates(ownedByMachine)).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
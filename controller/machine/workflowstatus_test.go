@@ -0,0 +1,119 @@
+package machine_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func Test_Workflow_status_is_mirrored_into_machine_status(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+
+	wf := validWorkflow(tinkerbellMachineName, clusterNamespace)
+	wf.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
+		Kind:       "TinkerbellMachine",
+		Name:       tinkerbellMachineName,
+		UID:        tm.UID,
+		Controller: ptr.To(true),
+	}}
+	wf.Status.State = tinkv1.WorkflowStateRunning
+	wf.Status.CurrentAction = "stream image"
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{tm, wf})
+	ctx := context.Background()
+	r := &machine.WorkflowStatusReconciler{Client: client}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: wf.Name, Namespace: wf.Namespace}}
+	tmKey := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, tmKey, tm)).To(Succeed())
+	g.Expect(tm.Status.Workflow).To(Equal(&infrastructurev1.WorkflowStatus{
+		Name:          wf.Name,
+		State:         string(tinkv1.WorkflowStateRunning),
+		CurrentAction: "stream image",
+	}))
+
+	output := make([]string, 0, 20)
+	for i := range 20 {
+		output = append(output, fmt.Sprintf("line %d", i))
+	}
+
+	g.Expect(client.Get(ctx, req.NamespacedName, wf)).To(Succeed())
+	wf.Status.State = tinkv1.WorkflowStateFailed
+	wf.Status.Tasks[0].Actions[0] = tinkv1.Action{
+		Name:    "stream image",
+		Status:  tinkv1.WorkflowStateFailed,
+		Message: strings.Join(output, "\n"),
+	}
+	g.Expect(client.Update(ctx, wf)).To(Succeed())
+
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, tmKey, tm)).To(Succeed())
+	g.Expect(tm.Status.Workflow.State).To(Equal(string(tinkv1.WorkflowStateFailed)))
+	g.Expect(tm.Status.Workflow.FailedAction).To(Equal("stream image"))
+	g.Expect(tm.Status.Workflow.Output).To(HavePrefix("line 10\n"), "Expected only the tail of the output")
+	g.Expect(tm.Status.Workflow.Output).To(HaveSuffix("line 19"))
+}
+
+func Test_Workflow_status_of_pooled_hardware_is_mirrored_into_machine_status(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tm := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tm.Spec.ProviderID = "tinkerbell://pool/" + hardwareName
+	tm.Status.WorkflowName = "pooled-workflow"
+
+	wf := validWorkflow("pooled-workflow", "pool")
+	wf.Labels = map[string]string{
+		machine.OwnerUIDLabel:               string(tm.UID),
+		machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+	}
+
+	other := validWorkflow(tinkerbellMachineName, "pool")
+	other.Labels = map[string]string{
+		machine.OwnerUIDLabel:               uuid.New().String(),
+		machine.HardwareOwnerNamespaceLabel: clusterNamespace,
+	}
+
+	client := kubernetesClientWithObjects(t, []runtime.Object{tm, wf, other})
+	ctx := context.Background()
+	r := &machine.WorkflowStatusReconciler{Client: client}
+	tmKey := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: other.Name, Namespace: "pool"}})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, tmKey, tm)).To(Succeed())
+	g.Expect(tm.Status.Workflow).To(BeNil(), "Expected the Workflow of another machine not to be mirrored")
+
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: wf.Name, Namespace: "pool"}})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(ctx, tmKey, tm)).To(Succeed())
+	g.Expect(tm.Status.Workflow).NotTo(BeNil())
+	g.Expect(tm.Status.Workflow.Name).To(Equal("pooled-workflow"))
+	g.Expect(tm.Status.Workflow.State).To(Equal(string(tinkv1.WorkflowStateSuccess)))
+}
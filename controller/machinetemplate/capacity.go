@@ -0,0 +1,115 @@
+// Package machinetemplate contains controllers maintaining the status of TinkerbellMachineTemplates.
+package machinetemplate
+
+import (
+	"errors"
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Capacity annotations of the cluster-autoscaler, set on a TinkerbellMachineTemplate to override the capacity
+// derived from Hardware.
+const (
+	// CPUCapacityAnnotation is the number of CPUs of the Nodes of the template.
+	CPUCapacityAnnotation = "capacity.cluster-autoscaler.kubernetes.io/cpu"
+
+	// MemoryCapacityAnnotation is the memory of the Nodes of the template.
+	MemoryCapacityAnnotation = "capacity.cluster-autoscaler.kubernetes.io/memory"
+
+	// EphemeralDiskCapacityAnnotation is the ephemeral storage of the Nodes of the template.
+	EphemeralDiskCapacityAnnotation = "capacity.cluster-autoscaler.kubernetes.io/ephemeral-disk"
+
+	// GPUCountCapacityAnnotation is the number of GPUs of the Nodes of the template.
+	GPUCountCapacityAnnotation = "capacity.cluster-autoscaler.kubernetes.io/gpu-count"
+
+	// GPUTypeCapacityAnnotation is the resource name of the GPUs of the Nodes of the template. Defaults to
+	// DefaultGPUResourceName.
+	GPUTypeCapacityAnnotation = "capacity.cluster-autoscaler.kubernetes.io/gpu-type"
+
+	// MaxPodsCapacityAnnotation is the number of pods the Nodes of the template can run.
+	MaxPodsCapacityAnnotation = "capacity.cluster-autoscaler.kubernetes.io/maxPods"
+
+	// DefaultGPUResourceName is the resource name of the GPUs counted by GPUCountCapacityAnnotation when
+	// GPUTypeCapacityAnnotation is not set.
+	DefaultGPUResourceName = "nvidia.com/gpu"
+)
+
+// ErrInvalidCapacityAnnotation is returned for capacity annotations whose value is not a quantity.
+var ErrInvalidCapacityAnnotation = errors.New("invalid capacity annotation")
+
+// HardwareCapacity returns the capacity all the given Hardware provides: the resources listed by every Hardware,
+// each with the smallest quantity any of them has, so a Node scaled from zero never has less than predicted.
+func HardwareCapacity(hardware []tinkv1.Hardware) corev1.ResourceList {
+	if len(hardware) == 0 {
+		return nil
+	}
+
+	capacity := corev1.ResourceList{}
+
+	for name, quantity := range hardware[0].Spec.Resources {
+		capacity[corev1.ResourceName(name)] = quantity.DeepCopy()
+	}
+
+	for i := range hardware[1:] {
+		resources := hardware[i+1].Spec.Resources
+
+		for name, smallest := range capacity {
+			quantity, ok := resources[string(name)]
+
+			switch {
+			case !ok:
+				delete(capacity, name)
+			case quantity.Cmp(smallest) < 0:
+				capacity[name] = quantity.DeepCopy()
+			}
+		}
+	}
+
+	if len(capacity) == 0 {
+		return nil
+	}
+
+	return capacity
+}
+
+// AnnotatedCapacity returns the capacity set by the cluster-autoscaler capacity annotations. Annotations whose value
+// is not a quantity are skipped and reported in the returned error along with the valid ones.
+func AnnotatedCapacity(annotations map[string]string) (corev1.ResourceList, error) {
+	gpu := corev1.ResourceName(DefaultGPUResourceName)
+	if gpuType := annotations[GPUTypeCapacityAnnotation]; gpuType != "" {
+		gpu = corev1.ResourceName(gpuType)
+	}
+
+	resources := map[string]corev1.ResourceName{
+		CPUCapacityAnnotation:           corev1.ResourceCPU,
+		MemoryCapacityAnnotation:        corev1.ResourceMemory,
+		EphemeralDiskCapacityAnnotation: corev1.ResourceEphemeralStorage,
+		GPUCountCapacityAnnotation:      gpu,
+		MaxPodsCapacityAnnotation:       corev1.ResourcePods,
+	}
+
+	capacity := corev1.ResourceList{}
+
+	var errs []error
+
+	for annotation, name := range resources {
+		value, ok := annotations[annotation]
+		if !ok {
+			continue
+		}
+
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %w", ErrInvalidCapacityAnnotation, annotation, err))
+
+			continue
+		}
+
+		capacity[name] = quantity
+	}
+
+	return capacity, errors.Join(errs...)
+}
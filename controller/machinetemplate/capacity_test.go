@@ -0,0 +1,114 @@
+package machinetemplate_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machinetemplate"
+)
+
+func hardwareWithResources(name string, labels map[string]string, resources map[string]string) *tinkv1.Hardware {
+	hw := &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Spec:       tinkv1.HardwareSpec{Resources: map[string]resource.Quantity{}},
+	}
+
+	for k, v := range resources {
+		hw.Spec.Resources[k] = resource.MustParse(v)
+	}
+
+	return hw
+}
+
+func Test_HardwareCapacity(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	capacity := machinetemplate.HardwareCapacity([]tinkv1.Hardware{
+		*hardwareWithResources("a", nil, map[string]string{"cpu": "32", "memory": "128Gi", "nvidia.com/gpu": "2"}),
+		*hardwareWithResources("b", nil, map[string]string{"cpu": "16", "memory": "256Gi"}),
+	})
+
+	g.Expect(capacity).To(HaveLen(2), "Expected only resources of all Hardware")
+	g.Expect(capacity.Cpu().String()).To(Equal("16"))
+	g.Expect(capacity.Memory().String()).To(Equal("128Gi"))
+
+	g.Expect(machinetemplate.HardwareCapacity(nil)).To(BeNil())
+}
+
+func Test_AnnotatedCapacity(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	capacity, err := machinetemplate.AnnotatedCapacity(map[string]string{
+		machinetemplate.CPUCapacityAnnotation:      "8",
+		machinetemplate.GPUCountCapacityAnnotation: "4",
+		machinetemplate.GPUTypeCapacityAnnotation:  "amd.com/gpu",
+		machinetemplate.MemoryCapacityAnnotation:   "lots",
+	})
+	g.Expect(err).To(MatchError(machinetemplate.ErrInvalidCapacityAnnotation))
+	g.Expect(capacity).To(HaveLen(2))
+	g.Expect(capacity.Cpu().String()).To(Equal("8"))
+	g.Expect(capacity.Name("amd.com/gpu", resource.DecimalSI).String()).To(Equal("4"))
+}
+
+func Test_CapacityReconciler(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrastructurev1.AddToScheme(scheme)).To(Succeed())
+
+	tmpl := &infrastructurev1.TinkerbellMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "workers",
+			Namespace:   "default",
+			Annotations: map[string]string{machinetemplate.MaxPodsCapacityAnnotation: "250"},
+		},
+		Spec: infrastructurev1.TinkerbellMachineTemplateSpec{
+			Template: infrastructurev1.TinkerbellMachineTemplateResource{
+				Spec: infrastructurev1.TinkerbellMachineSpec{
+					HardwareAffinity: &infrastructurev1.HardwareAffinity{
+						Required: []infrastructurev1.HardwareAffinityTerm{{
+							LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"type": "worker"}},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithStatusSubresource(&infrastructurev1.TinkerbellMachineTemplate{}).
+		WithObjects(
+			tmpl,
+			hardwareWithResources("worker", map[string]string{"type": "worker"},
+				map[string]string{"cpu": "64", "memory": "512Gi"}),
+			hardwareWithResources("cp", map[string]string{"type": "cp"}, map[string]string{"cpu": "8", "memory": "32Gi"}),
+		).Build()
+
+	r := &machinetemplate.CapacityReconciler{Client: c}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tmpl)})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(tmpl), tmpl)).To(Succeed())
+	g.Expect(tmpl.Status.Capacity).To(HaveLen(3))
+	g.Expect(tmpl.Status.Capacity.Cpu().String()).To(Equal("64"), "Expected only Hardware matching the affinity")
+	g.Expect(tmpl.Status.Capacity.Memory().String()).To(Equal("512Gi"))
+	g.Expect(tmpl.Status.Capacity.Pods().String()).To(Equal("250"))
+	g.Expect(tmpl.Status.Capacity).NotTo(HaveKey(corev1.ResourceEphemeralStorage))
+}
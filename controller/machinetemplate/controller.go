@@ -0,0 +1,124 @@
+package machinetemplate
+
+import (
+	"context"
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+// CapacityReconciler publishes the capacity of the Nodes of the machines created from a TinkerbellMachineTemplate
+// in its status, so the cluster-autoscaler can scale MachineDeployments using the template from zero. The capacity
+// is derived from the resources of the Hardware machines created from the template may be provisioned on, see
+// machine.HardwareMatchingTemplate, overridden by the cluster-autoscaler capacity annotations of the template.
+type CapacityReconciler struct {
+	client.Client
+
+	// HardwareOptions configures the selection of the Hardware of the templates.
+	HardwareOptions machine.TemplateHardwareOptions
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachinetemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachinetemplates/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware,verbs=get;list;watch
+
+// Reconcile sets the capacity of the TinkerbellMachineTemplate.
+func (r *CapacityReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	tmpl := &infrastructurev1.TinkerbellMachineTemplate{}
+	if err := r.Client.Get(ctx, req.NamespacedName, tmpl); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("getting TinkerbellMachineTemplate: %w", err)
+	}
+
+	if !tmpl.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	matching, err := machine.HardwareMatchingTemplate(ctx, r.Client, tmpl, r.HardwareOptions)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("matching Hardware: %w", err)
+	}
+
+	capacity := HardwareCapacity(matching)
+
+	annotated, err := AnnotatedCapacity(tmpl.GetAnnotations())
+	if err != nil {
+		record.Warnf(tmpl, "InvalidCapacityAnnotation", "Ignored capacity annotations: %v", err)
+	}
+
+	if capacity == nil && len(annotated) > 0 {
+		capacity = corev1.ResourceList{}
+	}
+
+	for name, quantity := range annotated {
+		capacity[name] = quantity
+	}
+
+	if equality.Semantic.DeepEqual(tmpl.Status.Capacity, capacity) {
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(tmpl, r.Client)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("initializing patch helper for TinkerbellMachineTemplate: %w", err)
+	}
+
+	tmpl.Status.Capacity = capacity
+
+	if err := patchHelper.Patch(ctx, tmpl); err != nil {
+		return ctrl.Result{}, fmt.Errorf("patching TinkerbellMachineTemplate: %w", err)
+	}
+
+	ctrl.LoggerFrom(ctx).V(4).Info("Updated capacity", "capacity", capacity) //nolint:gomnd
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager configures reconciler with a given manager.
+func (r *CapacityReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	// Hardware may match the affinity of any template of its namespace, and of any template selecting from a
+	// HardwarePool.
+	hardwareToTemplates := func(ctx context.Context, o client.Object) []ctrl.Request {
+		templates := &infrastructurev1.TinkerbellMachineTemplateList{}
+		if err := r.Client.List(ctx, templates); err != nil {
+			return nil
+		}
+
+		requests := make([]ctrl.Request, 0, len(templates.Items))
+
+		for i := range templates.Items {
+			tmpl := &templates.Items[i]
+			if tmpl.Namespace == o.GetNamespace() || tmpl.Spec.Template.Spec.HardwarePool != "" {
+				requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tmpl)})
+			}
+		}
+
+		return requests
+	}
+
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("tinkerbellmachinetemplatecapacity").
+		WithOptions(options).
+		For(&infrastructurev1.TinkerbellMachineTemplate{}).
+		Watches(&tinkv1.Hardware{}, handler.EnqueueRequestsFromMapFunc(hardwareToTemplates)).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package machinetemplate
+
+import (
+	"context"
+	"fmt"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+// PrewarmImageAnnotation is set on a TinkerbellMachineTemplate to the URL of the image of its machines, to pre-warm
+// the unclaimed Hardware matching its hardware affinity with it. Annotating the template created for a rolling
+// update before switching the MachineDeployment to it gets the Hardware ready for the new machines.
+const PrewarmImageAnnotation = machine.HardwarePrewarmImageAnnotation
+
+// PrewarmReconciler requests the pre-warm of the unclaimed Hardware machines created from TinkerbellMachineTemplates
+// annotated with PrewarmImageAnnotation may be provisioned on, see machine.HardwareMatchingTemplate, by annotating
+// the Hardware with machine.HardwarePrewarmImageAnnotation.
+type PrewarmReconciler struct {
+	client.Client
+
+	// HardwareOptions configures the selection of the Hardware of the templates.
+	HardwareOptions machine.TemplateHardwareOptions
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachinetemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile requests the pre-warm of the Hardware of the TinkerbellMachineTemplate.
+func (r *PrewarmReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	tmpl := &infrastructurev1.TinkerbellMachineTemplate{}
+	if err := r.Client.Get(ctx, req.NamespacedName, tmpl); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("getting TinkerbellMachineTemplate: %w", err)
+	}
+
+	imageURL := tmpl.Annotations[PrewarmImageAnnotation]
+	if imageURL == "" || !tmpl.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	matching, err := machine.HardwareMatchingTemplate(ctx, r.Client, tmpl, r.HardwareOptions)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("matching Hardware: %w", err)
+	}
+
+	requested := 0
+
+	for i := range matching {
+		hw := &matching[i]
+		if !prewarmable(hw, imageURL) {
+			continue
+		}
+
+		patchHelper, err := patch.NewHelper(hw, r.Client)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("initializing patch helper for Hardware %s: %w", hw.Name, err)
+		}
+
+		if hw.Annotations == nil {
+			hw.Annotations = map[string]string{}
+		}
+
+		hw.Annotations[machine.HardwarePrewarmImageAnnotation] = imageURL
+
+		if err := patchHelper.Patch(ctx, hw); err != nil {
+			return ctrl.Result{}, fmt.Errorf("patching Hardware %s: %w", hw.Name, err)
+		}
+
+		requested++
+	}
+
+	if requested > 0 {
+		ctrl.LoggerFrom(ctx).Info("Requested image pre-warm", "image", imageURL, "hardware", requested)
+		record.Eventf(tmpl, "ImagePrewarmRequested", "Requested pre-warm of %s on %d Hardware", imageURL, requested)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// prewarmable returns whether the Hardware can be selected for a machine, is not busy, e.g. with an inventory scan,
+// and is neither pre-warmed nor being pre-warmed with the image.
+func prewarmable(hw *tinkv1.Hardware, imageURL string) bool {
+	if _, claimed := hardwareutil.Owner(hw); claimed || hardwareutil.Paused(hw) || hardwareutil.Busy(hw) {
+		return false
+	}
+
+	_, quarantined := hw.Labels[hardwareutil.QuarantinedLabel]
+	_, decommissioned := hw.Labels[hardwareutil.DecommissionLabel]
+
+	if quarantined || decommissioned {
+		return false
+	}
+
+	return hw.Annotations[machine.HardwarePrewarmImageAnnotation] != imageURL &&
+		hw.Annotations[machine.HardwarePrewarmedImageAnnotation] != imageURL
+}
+
+// SetupWithManager configures reconciler with a given manager.
+func (r *PrewarmReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named("tinkerbellmachinetemplateprewarm").
+		WithOptions(options).
+		For(&infrastructurev1.TinkerbellMachineTemplate{}).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package machinetemplate_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machinetemplate"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+func Test_PrewarmReconciler(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	const imageURL = "http://10.1.1.1:8080/ubuntu-2204-kube-v1.30.0.gz"
+
+	scheme := runtime.NewScheme()
+	g.Expect(tinkv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrastructurev1.AddToScheme(scheme)).To(Succeed())
+
+	tmpl := &infrastructurev1.TinkerbellMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "workers-v1.30",
+			Namespace:   "default",
+			Annotations: map[string]string{machinetemplate.PrewarmImageAnnotation: imageURL},
+		},
+		Spec: infrastructurev1.TinkerbellMachineTemplateSpec{
+			Template: infrastructurev1.TinkerbellMachineTemplateResource{
+				Spec: infrastructurev1.TinkerbellMachineSpec{
+					HardwareAffinity: &infrastructurev1.HardwareAffinity{
+						Required: []infrastructurev1.HardwareAffinityTerm{{
+							LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"type": "worker"}},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	worker := map[string]string{"type": "worker"}
+	claimed := hardwareWithResources("claimed", map[string]string{
+		"type":                         "worker",
+		machine.HardwareOwnerNameLabel: "machine",
+	}, nil)
+	paused := hardwareWithResources("paused", worker, nil)
+	paused.Annotations = map[string]string{machine.HardwarePausedAnnotation: ""}
+	scanning := hardwareWithResources("scanning", worker, nil)
+	scanning.Annotations = map[string]string{hardwareutil.InventoryScanAnnotation: "true"}
+	prewarmed := hardwareWithResources("prewarmed", worker, nil)
+	prewarmed.Annotations = map[string]string{machine.HardwarePrewarmedImageAnnotation: imageURL}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(
+			tmpl,
+			hardwareWithResources("free", worker, nil),
+			hardwareWithResources("cp", map[string]string{"type": "cp"}, nil),
+			claimed,
+			paused,
+			scanning,
+			prewarmed,
+		).Build()
+
+	r := &machinetemplate.PrewarmReconciler{Client: c}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tmpl)})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	requested := func(name string) bool {
+		hw := &tinkv1.Hardware{}
+		g.Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, hw)).To(Succeed())
+
+		return hw.Annotations[machine.HardwarePrewarmImageAnnotation] == imageURL
+	}
+
+	g.Expect(requested("free")).To(BeTrue())
+	g.Expect(requested("cp")).To(BeFalse(), "Expected only Hardware matching the affinity to be pre-warmed")
+	g.Expect(requested("claimed")).To(BeFalse())
+	g.Expect(requested("paused")).To(BeFalse())
+	g.Expect(requested("scanning")).To(BeFalse(), "Expected Hardware being scanned to be skipped")
+	g.Expect(requested("prewarmed")).To(BeFalse(), "Expected Hardware pre-warmed with the image to be skipped")
+}
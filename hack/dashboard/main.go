@@ -0,0 +1,246 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command dashboard emits a Grafana dashboard for the metrics published about CAPT resources by
+// kube-state-metrics (see config/metrics) and by the CAPT controller manager itself.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const selector = `namespace=~"$namespace",cluster_name=~"$cluster"`
+
+type panel struct {
+	ID         int             `json:"id"`
+	Title      string          `json:"title"`
+	Type       string          `json:"type"`
+	Datasource datasource      `json:"datasource"`
+	GridPos    gridPos         `json:"gridPos"`
+	Targets    []target        `json:"targets"`
+	Options    json.RawMessage `json:"options,omitempty"`
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type target struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Format       string `json:"format,omitempty"`
+	Instant      bool   `json:"instant,omitempty"`
+	RefID        string `json:"refId"`
+}
+
+type variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label"`
+	Type       string      `json:"type"`
+	Query      interface{} `json:"query"`
+	Datasource *datasource `json:"datasource,omitempty"`
+	IncludeAll bool        `json:"includeAll,omitempty"`
+	Multi      bool        `json:"multi,omitempty"`
+	AllValue   string      `json:"allValue,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"`
+}
+
+type dashboard struct {
+	Title         string `json:"title"`
+	UID           string `json:"uid"`
+	SchemaVersion int    `json:"schemaVersion"`
+	Editable      bool   `json:"editable"`
+	Refresh       string `json:"refresh"`
+	Time          struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"time"`
+	Templating struct {
+		List []variable `json:"list"`
+	} `json:"templating"`
+	Panels []panel `json:"panels"`
+}
+
+// panelSpec describes a single panel of the dashboard.
+type panelSpec struct {
+	title  string
+	kind   string
+	width  int
+	height int
+	table  bool
+	exprs  []query
+}
+
+// query is a single PromQL expression of a panel along with its legend.
+type query struct {
+	legend string
+	expr   string
+}
+
+//nolint:gochecknoglobals
+var panels = []panelSpec{
+	{
+		title: "TinkerbellMachines",
+		kind:  "stat",
+		width: 6, height: 4,
+		exprs: []query{{"total", fmt.Sprintf("count(capt_tinkerbellmachine_info{%s})", selector)}},
+	},
+	{
+		title: "Ready TinkerbellMachines",
+		kind:  "stat",
+		width: 6, height: 4,
+		exprs: []query{{"ready", fmt.Sprintf("sum(capt_tinkerbellmachine_status_ready{%s})", selector)}},
+	},
+	{
+		title: "TinkerbellClusters",
+		kind:  "stat",
+		width: 6, height: 4,
+		exprs: []query{{"total", fmt.Sprintf("count(capt_tinkerbellcluster_info{%s})", selector)}},
+	},
+	{
+		title: "Ready TinkerbellClusters",
+		kind:  "stat",
+		width: 6, height: 4,
+		exprs: []query{{"ready", fmt.Sprintf("sum(capt_tinkerbellcluster_status_ready{%s})", selector)}},
+	},
+	{
+		title: "TinkerbellMachines by phase",
+		kind:  "timeseries",
+		width: 24, height: 8,
+		exprs: []query{{"{{phase}}", fmt.Sprintf("sum by (phase) (capt_tinkerbellmachine_status_phase{%s})", selector)}},
+	},
+	{
+		title: "Not ready TinkerbellMachines",
+		kind:  "table",
+		width: 12, height: 8,
+		table: true,
+		exprs: []query{{"", fmt.Sprintf("capt_tinkerbellmachine_status_ready{%s} == 0", selector)}},
+	},
+	{
+		title: "Hardware bindings",
+		kind:  "table",
+		width: 12, height: 8,
+		table: true,
+		exprs: []query{{"", fmt.Sprintf(`capt_tinkerbellmachine_info{%s,hardware_name!=""}`, selector)}},
+	},
+	{
+		title: "Requeued TinkerbellMachines by operation",
+		kind:  "timeseries",
+		width: 24, height: 8,
+		exprs: []query{{"{{operation}}", "sum by (operation) (capt_tinkerbellmachine_requeued_items)"}},
+	},
+}
+
+func build() dashboard {
+	ds := datasource{Type: "prometheus", UID: "${datasource}"}
+
+	d := dashboard{
+		Title:         "Cluster API Provider Tinkerbell",
+		UID:           "capt-overview",
+		SchemaVersion: 39, //nolint:gomnd
+		Editable:      true,
+		Refresh:       "30s",
+	}
+	d.Time.From = "now-6h"
+	d.Time.To = "now"
+
+	d.Templating.List = []variable{
+		{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		{
+			Name: "namespace", Label: "Namespace", Type: "query", Datasource: &ds,
+			Query:      "label_values(capt_tinkerbellmachine_info, namespace)",
+			IncludeAll: true, Multi: true, AllValue: ".*", Refresh: 2, //nolint:gomnd
+		},
+		{
+			Name: "cluster", Label: "Cluster", Type: "query", Datasource: &ds,
+			Query:      `label_values(capt_tinkerbellmachine_info{namespace=~"$namespace"}, cluster_name)`,
+			IncludeAll: true, Multi: true, AllValue: ".*", Refresh: 2, //nolint:gomnd
+		},
+	}
+
+	x, y, rowHeight := 0, 0, 0
+
+	for i, p := range panels {
+		if x+p.width > 24 { //nolint:gomnd
+			x, y, rowHeight = 0, y+rowHeight, 0
+		}
+
+		out := panel{
+			ID:         i + 1,
+			Title:      p.title,
+			Type:       p.kind,
+			Datasource: ds,
+			GridPos:    gridPos{H: p.height, W: p.width, X: x, Y: y},
+		}
+
+		refID := 'A'
+
+		for _, q := range p.exprs {
+			t := target{Expr: q.expr, LegendFormat: q.legend, RefID: string(refID)}
+			if p.table {
+				t.Format = "table"
+				t.Instant = true
+			}
+
+			out.Targets = append(out.Targets, t)
+			refID++
+		}
+
+		d.Panels = append(d.Panels, out)
+
+		x += p.width
+		if p.height > rowHeight {
+			rowHeight = p.height
+		}
+	}
+
+	return d
+}
+
+func main() {
+	output := flag.String("output", "", "File to write the dashboard to. Defaults to stdout.")
+	flag.Parse()
+
+	data, err := json.MarshalIndent(build(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "marshaling dashboard: %v\n", err)
+		os.Exit(1)
+	}
+
+	data = append(data, '\n')
+
+	if *output == "" {
+		_, _ = os.Stdout.Write(data)
+
+		return
+	}
+
+	if err := os.WriteFile(*output, data, 0o644); err != nil { //nolint:gosec
+		fmt.Fprintf(os.Stderr, "writing dashboard: %v\n", err)
+		os.Exit(1)
+	}
+}
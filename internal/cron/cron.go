@@ -0,0 +1,180 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cron parses standard five field cron expressions, e.g. "0 22 * * FRI", and finds the times they match.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule is returned for expressions which are not valid cron expressions.
+var ErrInvalidSchedule = errors.New("invalid cron expression")
+
+// field is the range of values of a field of a cron expression, and the names of its values if any.
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+//nolint:gochecknoglobals,gomnd
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{
+		"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC",
+	}},
+	{name: "day of week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}},
+}
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	// values holds, for each field, whether each value of the field matches.
+	values [5][]bool
+
+	// domRestricted and dowRestricted are true when the day of month or the day of week field is not a wildcard.
+	// As in cron, a day matches either of them when both are restricted.
+	domRestricted, dowRestricted bool
+}
+
+// Parse parses a cron expression of five fields: minute, hour, day of month, month and day of week. Fields are
+// wildcards, values, ranges or lists of them, optionally with a step, e.g. "*/15", "1-5" or "MON,WED,FRI". Sunday
+// is 0 or 7.
+func Parse(expr string) (*Schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("%w %q: expected %d fields, got %d", ErrInvalidSchedule, expr, len(fields), len(parts))
+	}
+
+	s := &Schedule{}
+
+	for i, part := range parts {
+		values, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %s: %w", ErrInvalidSchedule, expr, fields[i].name, err)
+		}
+
+		s.values[i] = values
+	}
+
+	// Sunday is both 0 and 7.
+	s.values[4][0] = s.values[4][0] || s.values[4][7]
+	s.domRestricted = !strings.HasPrefix(parts[2], "*")
+	s.dowRestricted = !strings.HasPrefix(parts[4], "*")
+
+	return s, nil
+}
+
+// parseField returns which values of the field the comma separated list matches.
+func parseField(list string, f field) ([]bool, error) {
+	values := make([]bool, f.max+1)
+
+	for _, item := range strings.Split(list, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+
+		step := 1
+
+		if hasStep {
+			var err error
+
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+
+		low, high := f.min, f.max
+
+		if rng != "*" {
+			lowStr, highStr, isRange := strings.Cut(rng, "-")
+
+			var err error
+
+			if low, err = parseValue(lowStr, f); err != nil {
+				return nil, err
+			}
+
+			high = low
+
+			if isRange {
+				if high, err = parseValue(highStr, f); err != nil {
+					return nil, err
+				}
+			} else if hasStep {
+				high = f.max
+			}
+
+			if high < low {
+				return nil, fmt.Errorf("invalid range %q", rng)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
+
+// parseValue parses a value of the field, a number or a name.
+func parseValue(s string, f field) (int, error) {
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q, expected %d-%d", s, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+// Matches returns true when the schedule matches the minute of t, in the location of t.
+func (s *Schedule) Matches(t time.Time) bool {
+	if !s.values[0][t.Minute()] || !s.values[1][t.Hour()] || !s.values[3][int(t.Month())] {
+		return false
+	}
+
+	dom, dow := s.values[2][t.Day()], s.values[4][int(t.Weekday())]
+
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+
+	return dom && dow
+}
+
+// Prev returns the latest minute matching the schedule which is not after t and not before since, false when
+// there is none.
+func (s *Schedule) Prev(t, since time.Time) (time.Time, bool) {
+	for m := t.Truncate(time.Minute); !m.Before(since); m = m.Add(-time.Minute) {
+		if s.Matches(m) {
+			return m, true
+		}
+	}
+
+	return time.Time{}, false
+}
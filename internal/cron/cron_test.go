@@ -0,0 +1,100 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/cron"
+)
+
+func TestMatches(t *testing.T) {
+	t.Parallel()
+
+	// 2024-03-15 is a Friday.
+	friday := time.Date(2024, time.March, 15, 22, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		expr string
+		time time.Time
+		want bool
+	}{
+		"every minute":                 {expr: "* * * * *", time: friday, want: true},
+		"day of week name":             {expr: "0 22 * * FRI", time: friday, want: true},
+		"other day of week":            {expr: "0 22 * * MON-THU", time: friday},
+		"other minute":                 {expr: "30 22 * * *", time: friday},
+		"step":                         {expr: "*/15 20-23 * * *", time: friday.Add(45 * time.Minute), want: true},
+		"step outside of range":        {expr: "*/15 20-23 * * *", time: friday.Add(10 * time.Minute)},
+		"list of months":               {expr: "0 22 * jan,mar *", time: friday, want: true},
+		"sunday as seven":              {expr: "0 22 * * 7", time: friday.Add(48 * time.Hour), want: true},
+		"day of month or day of week":  {expr: "0 22 1 * FRI", time: friday, want: true},
+		"day of month and any weekday": {expr: "0 22 1 * *", time: friday},
+		"step starting at a value":     {expr: "5/20 * * * *", time: friday.Add(25 * time.Minute), want: true},
+		"step not starting at a value": {expr: "5/20 * * * *", time: friday.Add(20 * time.Minute)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			s, err := cron.Parse(tc.expr)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(s.Matches(tc.time)).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestParse_invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * * FRIDAY",
+	} {
+		_, err := cron.Parse(expr)
+		NewWithT(t).Expect(err).To(MatchError(cron.ErrInvalidSchedule), expr)
+	}
+}
+
+func TestPrev(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	s, err := cron.Parse("0 22 * * FRI")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	now := time.Date(2024, time.March, 16, 1, 30, 45, 0, time.UTC)
+
+	start, ok := s.Prev(now, now.Add(-4*time.Hour))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(start).To(Equal(time.Date(2024, time.March, 15, 22, 0, 0, 0, time.UTC)))
+
+	_, ok = s.Prev(now, now.Add(-time.Hour))
+	g.Expect(ok).To(BeFalse())
+}
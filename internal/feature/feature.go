@@ -0,0 +1,79 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package feature defines the feature gates of CAPT, so new capabilities can ship disabled and be enabled per
+// deployment with the --feature-gates flag.
+package feature
+
+import (
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/component-base/featuregate"
+)
+
+const (
+	// DiscoveryController creates Hardware from the CSV inventory ConfigMaps named by
+	// --hardware-inventory-configmap.
+	DiscoveryController featuregate.Feature = "DiscoveryController"
+
+	// HardwareRoles has control plane machines select Hardware labeled with the control-plane role and worker
+	// machines Hardware labeled with the worker role when there is any, see machine.HardwareRoleLabel.
+	HardwareRoles featuregate.Feature = "HardwareRoles"
+
+	// ImagePrewarm pre-warms unclaimed Hardware annotated with an image, or matching the hardware affinity of a
+	// TinkerbellMachineTemplate annotated with one, with a one-off workflow running the image set by
+	// --image-prewarm-image.
+	ImagePrewarm featuregate.Feature = "ImagePrewarm"
+
+	// InventoryScan collects the inventory of unclaimed Hardware annotated for an inventory scan with a one-off
+	// workflow running the image set by --inventory-scan-image.
+	InventoryScan featuregate.Feature = "InventoryScan"
+
+	// NetbootHandshake adds an action before the final action of workflows, which has CAPT disallow netboot of the
+	// Hardware before the machine reboots into its OS. Requires --bootstrap-report-url.
+	NetbootHandshake featuregate.Feature = "NetbootHandshake"
+
+	// ReprovisionOnUserDataChange marks Machines with the Remediate bootstrap data drift policy for remediation
+	// when their bootstrap data changes. When disabled, the policy behaves like Update.
+	ReprovisionOnUserDataChange featuregate.Feature = "ReprovisionOnUserDataChange"
+)
+
+// defaultGates are the feature gates of CAPT with their default state. New gates start as Alpha, disabled by
+// default.
+var defaultGates = map[featuregate.Feature]featuregate.FeatureSpec{ //nolint:gochecknoglobals
+	DiscoveryController:         {Default: false, PreRelease: featuregate.Alpha},
+	HardwareRoles:               {Default: false, PreRelease: featuregate.Alpha},
+	ImagePrewarm:                {Default: false, PreRelease: featuregate.Alpha},
+	InventoryScan:               {Default: false, PreRelease: featuregate.Alpha},
+	NetbootHandshake:            {Default: false, PreRelease: featuregate.Alpha},
+	ReprovisionOnUserDataChange: {Default: false, PreRelease: featuregate.Alpha},
+}
+
+// NewGates returns the feature gates of CAPT in their default state, to be set with AddFlag.
+func NewGates() featuregate.MutableFeatureGate {
+	gates := featuregate.NewFeatureGate()
+	runtime.Must(gates.Add(defaultGates))
+
+	return gates
+}
+
+// Enabled returns whether the feature is enabled by the given gates, its default state when gates is nil.
+func Enabled(gates featuregate.FeatureGate, f featuregate.Feature) bool {
+	if gates == nil {
+		return defaultGates[f].Default
+	}
+
+	return gates.Enabled(f)
+}
@@ -0,0 +1,28 @@
+package feature_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	"github.com/spf13/pflag"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/feature"
+)
+
+func Test_gates_are_set_from_flag(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	gates := feature.NewGates()
+	g.Expect(feature.Enabled(gates, feature.DiscoveryController)).To(BeFalse())
+	g.Expect(feature.Enabled(nil, feature.DiscoveryController)).To(BeFalse(), "Expected the default without gates")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	gates.AddFlag(fs)
+
+	g.Expect(fs.Parse([]string{"--feature-gates=DiscoveryController=true"})).To(Succeed())
+	g.Expect(feature.Enabled(gates, feature.DiscoveryController)).To(BeTrue())
+	g.Expect(feature.Enabled(gates, feature.ReprovisionOnUserDataChange)).To(BeFalse())
+
+	g.Expect(fs.Parse([]string{"--feature-gates=NotAFeature=true"})).NotTo(Succeed())
+}
@@ -0,0 +1,159 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package hardwareexpr compiles and evaluates CEL expressions used to filter and rank candidate Hardware.
+package hardwareexpr
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// Variable is the name of the variable holding the candidate Hardware in expressions.
+	Variable = "hardware"
+
+	// CostLimit is the maximum cost of evaluating an expression against a single Hardware, in CEL cost units.
+	// Evaluations exceeding it fail, and expressions whose estimated cost exceeds it are rejected by CheckCost.
+	CostLimit = 1_000_000
+
+	// maxSize is the number of items of lists and maps, and the length of strings, of Hardware assumed when
+	// estimating the cost of an expression.
+	maxSize = 256
+)
+
+var (
+	// ErrUnexpectedResultType is returned when an expression evaluates to a different type than expected.
+	ErrUnexpectedResultType = fmt.Errorf("unexpected expression result type")
+
+	// ErrCostLimitExceeded is returned when the estimated cost of an expression exceeds CostLimit.
+	ErrCostLimitExceeded = fmt.Errorf("expression cost limit exceeded")
+)
+
+// Program is a compiled expression.
+type Program struct {
+	expr    string
+	env     *cel.Env
+	ast     *cel.Ast
+	program cel.Program
+}
+
+// CompileFilter compiles an expression which must evaluate to a bool.
+func CompileFilter(expr string) (*Program, error) {
+	return compile(expr, cel.BoolType)
+}
+
+// CompileScore compiles an expression which must evaluate to an int.
+func CompileScore(expr string) (*Program, error) {
+	return compile(expr, cel.IntType)
+}
+
+func compile(expr string, want *cel.Type) (*Program, error) {
+	env, err := cel.NewEnv(cel.Variable(Variable, cel.DynType))
+	if err != nil {
+		return nil, fmt.Errorf("creating CEL environment: %w", err)
+	}
+
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("compiling expression: %w", issues.Err())
+	}
+
+	if ast.OutputType() != cel.DynType && !ast.OutputType().IsExactType(want) {
+		return nil, fmt.Errorf("%w: expression must evaluate to %s, got %s",
+			ErrUnexpectedResultType, want, ast.OutputType())
+	}
+
+	program, err := env.Program(ast, cel.CostLimit(CostLimit))
+	if err != nil {
+		return nil, fmt.Errorf("building program: %w", err)
+	}
+
+	return &Program{expr: expr, env: env, ast: ast, program: program}, nil
+}
+
+// CheckCost returns ErrCostLimitExceeded when the worst case cost of evaluating the expression against Hardware
+// with up to 256 items in its lists and maps exceeds CostLimit.
+func (p *Program) CheckCost() error {
+	estimate, err := p.env.EstimateCost(p.ast, sizeEstimator{})
+	if err != nil {
+		return fmt.Errorf("estimating cost: %w", err)
+	}
+
+	if estimate.Max > CostLimit {
+		return fmt.Errorf("%w: estimated cost %d exceeds %d", ErrCostLimitExceeded, estimate.Max, CostLimit)
+	}
+
+	return nil
+}
+
+// sizeEstimator bounds the size of the values of Hardware, which are all dynamically typed, for cost estimation.
+type sizeEstimator struct{}
+
+func (sizeEstimator) EstimateSize(checker.AstNode) *checker.SizeEstimate {
+	return &checker.SizeEstimate{Min: 0, Max: maxSize}
+}
+
+func (sizeEstimator) EstimateCallCost(string, string, *checker.AstNode, []checker.AstNode) *checker.CallEstimate {
+	return nil
+}
+
+func (p *Program) eval(obj runtime.Object) (interface{}, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("converting object: %w", err)
+	}
+
+	out, _, err := p.program.Eval(map[string]interface{}{Variable: u})
+	if err != nil {
+		return nil, fmt.Errorf("evaluating %q: %w", p.expr, err)
+	}
+
+	return out.Value(), nil
+}
+
+// Matches evaluates a filter expression against the given object.
+func (p *Program) Matches(obj runtime.Object) (bool, error) {
+	v, err := p.eval(obj)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: %q evaluated to %T, want bool", ErrUnexpectedResultType, p.expr, v)
+	}
+
+	return b, nil
+}
+
+// Score evaluates a score expression against the given object.
+func (p *Program) Score(obj runtime.Object) (int64, error) {
+	v, err := p.eval(obj)
+	if err != nil {
+		return 0, err
+	}
+
+	i, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q evaluated to %T, want int", ErrUnexpectedResultType, p.expr, v)
+	}
+
+	return i, nil
+}
@@ -0,0 +1,116 @@
+package hardwareexpr_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/hardwareexpr"
+)
+
+func hardware() *tinkv1.Hardware {
+	return &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "hw",
+			Labels: map[string]string{"rack": "r1"},
+		},
+		Spec: tinkv1.HardwareSpec{
+			Disks: []tinkv1.Disk{{Device: "/dev/sda"}, {Device: "/dev/sdb"}},
+		},
+	}
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		expr       string
+		want       bool
+		compileErr bool
+		evalErr    bool
+	}{
+		"matching label":     {expr: `hardware.metadata.labels["rack"] == "r1"`, want: true},
+		"non matching label": {expr: `hardware.metadata.labels["rack"] == "r2"`},
+		"disk count":         {expr: "size(hardware.spec.disks) > 1", want: true},
+		"syntax error":       {expr: "hardware.metadata.labels[", compileErr: true},
+		"not a bool":         {expr: "1 + 1", compileErr: true},
+		"missing key":        {expr: `hardware.metadata.annotations["foo"] == "bar"`, evalErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			p, err := hardwareexpr.CompileFilter(tc.expr)
+			if tc.compileErr {
+				g.Expect(err).To(HaveOccurred())
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			got, err := p.Matches(hardware())
+			if tc.evalErr {
+				g.Expect(err).To(HaveOccurred())
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(got).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	p, err := hardwareexpr.CompileScore("size(hardware.spec.disks) * 10")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	got, err := p.Score(hardware())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(got).To(BeEquivalentTo(20))
+
+	_, err = hardwareexpr.CompileScore(`"high"`)
+	g.Expect(err).To(MatchError(hardwareexpr.ErrUnexpectedResultType))
+}
+
+func TestCheckCost(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		expr    string
+		wantErr bool
+	}{
+		"label":  {expr: `hardware.metadata.labels["rack"] == "r1"`},
+		"disks":  {expr: `hardware.spec.disks.exists(d, d.device == "/dev/sda")`},
+		"nested": {expr: `hardware.spec.disks.all(a, hardware.spec.disks.all(b, a.device != "" && b.device != ""))`},
+		"deeply nested": {
+			expr: `hardware.spec.disks.all(a, hardware.spec.disks.all(b, hardware.spec.disks.all(c, ` +
+				`a.device + b.device + c.device != "")))`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			p, err := hardwareexpr.CompileFilter(tc.expr)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			if tc.wantErr {
+				g.Expect(p.CheckCost()).To(MatchError(hardwareexpr.ErrCostLimitExceeded))
+			} else {
+				g.Expect(p.CheckCost()).To(Succeed())
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package imageurl renders the URLs of the OS images of machines from image lookup formats, Go templates using the
+// fields of Params and functions looking up the checksums and sizes of images in a Catalog.
+package imageurl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+var (
+	// ErrInvalidFormat is returned for image lookup formats which are not valid templates, or use fields or
+	// functions which do not exist.
+	ErrInvalidFormat = errors.New("invalid image lookup format")
+
+	// ErrNotInCatalog is returned when an image lookup format looks up an image which is not in the catalog.
+	ErrNotInCatalog = errors.New("image not in catalog")
+)
+
+// Params are the fields image lookup formats can use.
+type Params struct {
+	// BaseRegistry is the base URL of the images.
+	BaseRegistry string
+
+	// OSDistro is the lower-cased OS distribution, e.g. ubuntu.
+	OSDistro string
+
+	// OSVersion is the OS version without dots, e.g. 2204.
+	OSVersion string
+
+	// KubernetesVersion is the Kubernetes version of the machine, e.g. v1.30.2.
+	KubernetesVersion string
+
+	// ShortK8sVersion is the Kubernetes minor version of the machine, e.g. v1.30.
+	ShortK8sVersion string
+
+	// Arch is the architecture of the Hardware as reported in its DHCP settings, e.g. x86_64 or aarch64. It is
+	// empty when the Hardware reports none.
+	Arch string
+}
+
+// NewParams returns the Params of an image, normalizing the OS distribution and version as image names do.
+func NewParams(baseRegistry, osDistro, osVersion, kubernetesVersion, arch string) Params {
+	return Params{
+		BaseRegistry:      baseRegistry,
+		OSDistro:          strings.ToLower(osDistro),
+		OSVersion:         strings.ReplaceAll(osVersion, ".", ""),
+		KubernetesVersion: kubernetesVersion,
+		ShortK8sVersion:   shortVersion(kubernetesVersion),
+		Arch:              arch,
+	}
+}
+
+// shortVersion returns the major and minor parts of a version, e.g. v1.30 for v1.30.2.
+func shortVersion(version string) string {
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 { //nolint:gomnd
+		return version
+	}
+
+	return parts[0] + "." + parts[1]
+}
+
+// CatalogEntry describes an image of a Catalog.
+type CatalogEntry struct {
+	// SHA256 is the hex encoded SHA-256 checksum of the image.
+	SHA256 string `yaml:"sha256"`
+
+	// Size is the size of the image in bytes.
+	Size int64 `yaml:"size"`
+}
+
+// Catalog describes images by name, so image lookup formats can use their checksums and sizes.
+type Catalog map[string]CatalogEntry
+
+// ParseCatalog parses the data of a catalog ConfigMap, whose keys are the names of images and whose values are
+// YAML documents with their sha256 and size.
+func ParseCatalog(data map[string]string) (Catalog, error) {
+	catalog := make(Catalog, len(data))
+
+	for name, value := range data {
+		entry := CatalogEntry{}
+		if err := yaml.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, fmt.Errorf("parsing catalog entry %q: %w", name, err)
+		}
+
+		catalog[name] = entry
+	}
+
+	return catalog, nil
+}
+
+// funcs returns the functions of image lookup formats looking up images in the catalog.
+func funcs(catalog Catalog) template.FuncMap {
+	lookup := func(name string) (CatalogEntry, error) {
+		entry, ok := catalog[name]
+		if !ok {
+			return CatalogEntry{}, fmt.Errorf("%w: %q", ErrNotInCatalog, name)
+		}
+
+		return entry, nil
+	}
+
+	return template.FuncMap{
+		"sha256": func(name string) (string, error) {
+			entry, err := lookup(name)
+
+			return entry.SHA256, err
+		},
+		"size": func(name string) (string, error) {
+			entry, err := lookup(name)
+
+			return strconv.FormatInt(entry.Size, 10), err
+		},
+	}
+}
+
+// Render renders the image lookup format with the given params, looking up images in the catalog.
+func Render(format string, params Params, catalog Catalog) (string, error) {
+	tmpl, err := template.New("image").Funcs(funcs(catalog)).Parse(format)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, params); err != nil {
+		if errors.Is(err, ErrNotInCatalog) {
+			return "", fmt.Errorf("populating template %q: %w", format, err)
+		}
+
+		return "", fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+
+	return buf.String(), nil
+}
+
+// Validate returns an error when the image lookup format is not a valid template or uses fields or functions
+// which do not exist, so mistakes are reported when the format is set instead of when a machine is provisioned.
+// Images looked up in the catalog are assumed to exist.
+func Validate(format string) error {
+	tmpl, err := template.New("image").Funcs(funcs(nil)).Parse(format)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+
+	catalog := template.FuncMap{
+		"sha256": func(string) string { return "" },
+		"size":   func(string) string { return "0" },
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Funcs(catalog).Execute(&buf, NewParams("", "", "", "v1.0.0", "")); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+
+	return nil
+}
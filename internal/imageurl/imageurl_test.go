@@ -0,0 +1,110 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imageurl_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/imageurl"
+)
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	catalog, err := imageurl.ParseCatalog(map[string]string{
+		"ubuntu-2204-v1.30.2": "sha256: 0123abcd\nsize: 2147483648\n",
+	})
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+
+	params := imageurl.NewParams("http://images.example.com", "Ubuntu", "22.04", "v1.30.2", "x86_64")
+
+	tests := map[string]struct {
+		format  string
+		want    string
+		wantErr error
+	}{
+		"default format": {
+			format: "{{.BaseRegistry}}/{{.OSDistro}}-{{.OSVersion}}:{{.KubernetesVersion}}.gz",
+			want:   "http://images.example.com/ubuntu-2204:v1.30.2.gz",
+		},
+		"short kubernetes version and architecture": {
+			format: "{{.BaseRegistry}}/{{.ShortK8sVersion}}/{{.Arch}}/{{.OSDistro}}.raw",
+			want:   "http://images.example.com/v1.30/x86_64/ubuntu.raw",
+		},
+		"catalog lookups": {
+			format: `{{.BaseRegistry}}/sha256/{{sha256 "ubuntu-2204-v1.30.2"}}?size={{size "ubuntu-2204-v1.30.2"}}`,
+			want:   "http://images.example.com/sha256/0123abcd?size=2147483648",
+		},
+		"image missing from catalog": {
+			format:  `{{sha256 "flatcar"}}`,
+			wantErr: imageurl.ErrNotInCatalog,
+		},
+		"unknown field": {
+			format:  "{{.BaseRegistry}}/{{.Distro}}.gz",
+			wantErr: imageurl.ErrInvalidFormat,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			got, err := imageurl.Render(tc.format, params, catalog)
+			if tc.wantErr != nil {
+				g.Expect(err).To(MatchError(tc.wantErr))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(got).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		format  string
+		wantErr bool
+	}{
+		"default format":    {format: "{{.BaseRegistry}}/{{.OSDistro}}-{{.OSVersion}}:{{.KubernetesVersion}}.gz"},
+		"catalog functions": {format: `{{sha256 (printf "%s-%s" .OSDistro .KubernetesVersion)}}-{{size "x"}}`},
+		"empty format":      {format: ""},
+		"unknown field":     {format: "{{.K8sVersion}}", wantErr: true},
+		"unknown function":  {format: `{{sha512 "x"}}`, wantErr: true},
+		"invalid syntax":    {format: "{{.BaseRegistry", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			err := imageurl.Validate(tc.format)
+			if tc.wantErr {
+				g.Expect(err).To(MatchError(imageurl.ErrInvalidFormat))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging builds the logger of the controller manager, so production deployments can run quiet and debug
+// runs can be verbose, per controller if needed.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zerologr"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// FormatJSON writes one JSON object per log line.
+	FormatJSON = "json"
+
+	// FormatConsole writes human readable log lines.
+	FormatConsole = "console"
+)
+
+// DebugVerbosity is the logr V-level the reconcilers log their per-reconcile details at.
+const DebugVerbosity = 4
+
+// levels maps the named log levels to the highest logr V-level logged. Errors are always logged.
+//
+//nolint:gochecknoglobals
+var levels = map[string]int{
+	"error": -1,
+	"info":  0,
+	"debug": DebugVerbosity,
+}
+
+// controllerKey is the logger value controller-runtime names the controller of a reconciliation with.
+const controllerKey = "controller"
+
+var (
+	// ErrUnknownLevel is the error returned for a log level which is neither named nor a V-level.
+	ErrUnknownLevel = fmt.Errorf("log level must be error, info, debug or a verbosity level")
+
+	// ErrUnknownFormat is the error returned for an unsupported log format.
+	ErrUnknownFormat = fmt.Errorf("log format must be %s or %s", FormatJSON, FormatConsole)
+)
+
+// Options configure the logger.
+type Options struct {
+	// Level is error, info, debug or the highest logr V-level to log. Defaults to info.
+	Level string
+
+	// Format is json or console. Defaults to json.
+	Format string
+
+	// ControllerVerbosity overrides the highest logr V-level logged by the named controllers.
+	ControllerVerbosity map[string]int
+
+	// SamplingBurst is the number of messages of each level below warning logged per SamplingPeriod, further
+	// messages are dropped. Zero disables sampling.
+	SamplingBurst int
+
+	// SamplingPeriod is the period SamplingBurst applies to.
+	SamplingPeriod time.Duration
+}
+
+// ParseLevel returns the highest logr V-level logged for the given log level.
+func ParseLevel(level string) (int, error) {
+	if level == "" {
+		return 0, nil
+	}
+
+	if verbosity, found := levels[level]; found {
+		return verbosity, nil
+	}
+
+	verbosity, err := strconv.Atoi(level)
+	if err != nil || verbosity < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownLevel, level)
+	}
+
+	return verbosity, nil
+}
+
+// New returns a logger writing to w as configured.
+func New(w io.Writer, opts Options) (logr.Logger, error) {
+	verbosity, err := ParseLevel(opts.Level)
+	if err != nil {
+		return logr.Logger{}, err
+	}
+
+	switch opts.Format {
+	case "", FormatJSON:
+	case FormatConsole:
+		w = zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339}
+	default:
+		return logr.Logger{}, fmt.Errorf("%w: %q", ErrUnknownFormat, opts.Format)
+	}
+
+	// Verbosity is filtered by the sink, so zerolog has to let all levels through.
+	maxVerbosity := verbosity
+	for _, v := range opts.ControllerVerbosity {
+		maxVerbosity = max(maxVerbosity, v)
+	}
+
+	zerologr.SetMaxV(maxVerbosity)
+
+	zl := zerolog.New(w).Level(zerolog.TraceLevel).With().Caller().Timestamp().Logger()
+
+	if opts.SamplingBurst > 0 {
+		zl = zl.Sample(samplerFor(opts.SamplingBurst, opts.SamplingPeriod))
+	}
+
+	return logr.New(&verbositySink{
+		sink:                zerologr.New(&zl).GetSink(),
+		verbosity:           verbosity,
+		controllerVerbosity: opts.ControllerVerbosity,
+	}), nil
+}
+
+// samplerFor samples messages below warning, so warnings and errors are never dropped.
+func samplerFor(burst int, period time.Duration) zerolog.Sampler {
+	sampler := func() zerolog.Sampler {
+		return &zerolog.BurstSampler{Burst: uint32(burst), Period: period} //nolint:gosec
+	}
+
+	return zerolog.LevelSampler{
+		TraceSampler: sampler(),
+		DebugSampler: sampler(),
+		InfoSampler:  sampler(),
+	}
+}
+
+// verbositySink filters messages by their V-level, using the verbosity of the controller the logger was created for
+// when it is overridden.
+type verbositySink struct {
+	sink                logr.LogSink
+	verbosity           int
+	controllerVerbosity map[string]int
+}
+
+var _ logr.CallDepthLogSink = &verbositySink{}
+
+// Init implements logr.LogSink.
+func (s *verbositySink) Init(info logr.RuntimeInfo) {
+	// Account for the frame of the wrapper when reporting the caller.
+	info.CallDepth++
+	s.sink.Init(info)
+}
+
+// Enabled implements logr.LogSink.
+func (s *verbositySink) Enabled(level int) bool {
+	return level <= s.verbosity && s.sink.Enabled(level)
+}
+
+// Info implements logr.LogSink.
+func (s *verbositySink) Info(level int, msg string, keysAndValues ...any) {
+	s.sink.Info(level, msg, keysAndValues...)
+}
+
+// Error implements logr.LogSink.
+func (s *verbositySink) Error(err error, msg string, keysAndValues ...any) {
+	s.sink.Error(err, msg, keysAndValues...)
+}
+
+// WithValues implements logr.LogSink.
+func (s *verbositySink) WithValues(keysAndValues ...any) logr.LogSink {
+	verbosity := s.verbosity
+
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if key, ok := keysAndValues[i].(string); !ok || key != controllerKey {
+			continue
+		}
+
+		if name, ok := keysAndValues[i+1].(string); ok {
+			if v, found := s.controllerVerbosity[name]; found {
+				verbosity = v
+			}
+		}
+	}
+
+	return &verbositySink{
+		sink:                s.sink.WithValues(keysAndValues...),
+		verbosity:           verbosity,
+		controllerVerbosity: s.controllerVerbosity,
+	}
+}
+
+// WithName implements logr.LogSink.
+func (s *verbositySink) WithName(name string) logr.LogSink {
+	return &verbositySink{
+		sink:                s.sink.WithName(name),
+		verbosity:           s.verbosity,
+		controllerVerbosity: s.controllerVerbosity,
+	}
+}
+
+// WithCallDepth implements logr.CallDepthLogSink.
+func (s *verbositySink) WithCallDepth(depth int) logr.LogSink {
+	sink := s.sink
+	if withCallDepth, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = withCallDepth.WithCallDepth(depth)
+	}
+
+	return &verbositySink{
+		sink:                sink,
+		verbosity:           s.verbosity,
+		controllerVerbosity: s.controllerVerbosity,
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/logging"
+)
+
+func Test_ParseLevel(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	for level, expected := range map[string]int{"": 0, "error": -1, "info": 0, "debug": logging.DebugVerbosity, "7": 7} {
+		verbosity, err := logging.ParseLevel(level)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(verbosity).To(Equal(expected), level)
+	}
+
+	for _, level := range []string{"warning", "-1"} {
+		_, err := logging.ParseLevel(level)
+		g.Expect(err).To(MatchError(logging.ErrUnknownLevel))
+	}
+}
+
+//nolint:paralleltest // Sets the global zerolog level.
+func Test_New_filters_by_verbosity(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := logging.New(&bytes.Buffer{}, logging.Options{Format: "text"})
+	g.Expect(err).To(MatchError(logging.ErrUnknownFormat))
+
+	buf := &bytes.Buffer{}
+
+	logger, err := logging.New(buf, logging.Options{
+		Level:               "error",
+		ControllerVerbosity: map[string]int{"tinkerbellmachine": logging.DebugVerbosity},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	logger.Info("dropped info")
+	logger.Error(errors.New("failure"), "kept error")
+
+	machineLogger := logger.WithValues("controller", "tinkerbellmachine")
+	machineLogger.V(logging.DebugVerbosity).Info("kept controller debug")
+	machineLogger.V(logging.DebugVerbosity + 1).Info("dropped controller trace")
+
+	logger.WithValues("controller", "tinkerbellcluster").Info("dropped other controller info")
+
+	g.Expect(buf.String()).NotTo(ContainSubstring("dropped"))
+	g.Expect(buf.String()).To(ContainSubstring(`"message":"kept error"`))
+	g.Expect(buf.String()).To(ContainSubstring(`"message":"kept controller debug"`))
+	g.Expect(buf.String()).To(ContainSubstring("/internal/logging/logging_test.go:"),
+		"Expected the caller to be reported through the wrapping sink")
+}
+
+//nolint:paralleltest // Sets the global zerolog level.
+func Test_New_samples_messages_below_warning(t *testing.T) {
+	g := NewWithT(t)
+	buf := &bytes.Buffer{}
+
+	logger, err := logging.New(buf, logging.Options{SamplingBurst: 2, SamplingPeriod: time.Hour})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for range 5 {
+		logger.Info("sampled")
+		logger.Error(errors.New("failure"), "never sampled")
+	}
+
+	g.Expect(strings.Count(buf.String(), `"message":"sampled"`)).To(Equal(2))
+	g.Expect(strings.Count(buf.String(), `"message":"never sampled"`)).To(Equal(5))
+}
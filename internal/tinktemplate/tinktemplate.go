@@ -0,0 +1,247 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tinktemplate validates Tinkerbell templates against the schema the Tinkerbell workflow controller
+// enforces when rendering them, so malformed templates are rejected before a Template is created.
+package tinktemplate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+// maxNameLength is the exclusive upper bound of the length of template, task and action names.
+const maxNameLength = 200
+
+var (
+	// ErrSyntax is returned for templates which are not valid Go templates.
+	ErrSyntax = errors.New("invalid template syntax")
+
+	// ErrMalformed is returned for templates which do not render to a valid Tinkerbell workflow.
+	ErrMalformed = errors.New("malformed template")
+)
+
+// workflow is the schema of a rendered template, as parsed by the Tinkerbell workflow controller.
+type workflow struct {
+	Version       string `yaml:"version"`
+	Name          string `yaml:"name"`
+	GlobalTimeout int    `yaml:"global_timeout"`
+	Tasks         []task `yaml:"tasks"`
+}
+
+type task struct {
+	Name       string   `yaml:"name"`
+	WorkerAddr string   `yaml:"worker"`
+	Actions    []action `yaml:"actions"`
+}
+
+type action struct {
+	Name    string `yaml:"name"`
+	Image   string `yaml:"image"`
+	Timeout int64  `yaml:"timeout"`
+}
+
+// hardwareData is the data about the Hardware templates are rendered with, as provided by the Tinkerbell workflow
+// controller.
+type hardwareData struct {
+	Disks      []string
+	Interfaces []tinkv1.Interface
+	UserData   string
+	Metadata   tinkv1.HardwareMetadata
+	VendorData string
+}
+
+// Validate checks the template is a valid Go template and renders to a Tinkerbell workflow with a name, at least
+// one task and uniquely named actions with images. The template is rendered with the data of the given Hardware,
+// or of placeholder Hardware when nil. Functions are not evaluated, so templates whose structure depends on the
+// result of functions, or which can not be rendered with the placeholder Hardware, are only checked for syntax.
+func Validate(data string, hw *tinkv1.Hardware) error {
+	// Functions are provided by the workflow controller, so calls to unknown functions are not syntax errors.
+	tree := parse.New("template")
+	tree.Mode = parse.SkipFuncCheck
+	trees := map[string]*parse.Tree{}
+
+	if _, err := tree.Parse(data, "", "", trees); err != nil {
+		return fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+
+	funcs := template.FuncMap{}
+	for _, tree := range trees {
+		collectFuncs(tree.Root, funcs)
+	}
+
+	tpl, err := template.New("template").Option("missingkey=zero").Funcs(funcs).Parse(data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, renderData(hw)); err != nil {
+		return nil //nolint:nilerr // Rendering depends on data only known to the workflow controller.
+	}
+
+	return validateWorkflow(buf.Bytes())
+}
+
+func validateWorkflow(rendered []byte) error {
+	wf := &workflow{}
+	if err := yaml.Unmarshal(rendered, wf); err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	if !validName(wf.Name) {
+		return fmt.Errorf("%w: name must be set and shorter than %d characters", ErrMalformed, maxNameLength)
+	}
+
+	if len(wf.Tasks) == 0 {
+		return fmt.Errorf("%w: at least one task must be defined", ErrMalformed)
+	}
+
+	tasks := map[string]bool{}
+
+	for i, t := range wf.Tasks {
+		if !validName(t.Name) {
+			return fmt.Errorf("%w: tasks[%d]: name must be set and shorter than %d characters", ErrMalformed, i,
+				maxNameLength)
+		}
+
+		if tasks[t.Name] {
+			return fmt.Errorf("%w: tasks[%d]: task name %q is not unique", ErrMalformed, i, t.Name)
+		}
+
+		tasks[t.Name] = true
+		actions := map[string]bool{}
+
+		for j, a := range t.Actions {
+			path := fmt.Sprintf("tasks[%d].actions[%d]", i, j)
+
+			switch {
+			case !validName(a.Name):
+				return fmt.Errorf("%w: %s: name must be set and shorter than %d characters", ErrMalformed, path,
+					maxNameLength)
+			case actions[a.Name]:
+				return fmt.Errorf("%w: %s: action name %q is not unique in task %q", ErrMalformed, path, a.Name, t.Name)
+			case a.Image == "" || strings.ContainsAny(a.Image, " \t\n"):
+				return fmt.Errorf("%w: %s: image %q is not a valid image reference", ErrMalformed, path, a.Image)
+			}
+
+			actions[a.Name] = true
+		}
+	}
+
+	return nil
+}
+
+func validName(name string) bool {
+	return name != "" && len(name) < maxNameLength
+}
+
+// renderData returns the data the workflow controller renders templates for the Hardware with.
+func renderData(hw *tinkv1.Hardware) map[string]any {
+	if hw == nil {
+		hw = &tinkv1.Hardware{Spec: tinkv1.HardwareSpec{
+			Disks:      []tinkv1.Disk{{Device: "/dev/sda"}},
+			Interfaces: []tinkv1.Interface{{DHCP: &tinkv1.DHCP{MAC: "00:00:00:00:00:01"}}},
+		}}
+	}
+
+	data := hardwareData{Interfaces: hw.Spec.Interfaces}
+
+	for _, disk := range hw.Spec.Disks {
+		data.Disks = append(data.Disks, disk.Device)
+	}
+
+	if hw.Spec.UserData != nil {
+		data.UserData = *hw.Spec.UserData
+	}
+
+	if hw.Spec.Metadata != nil {
+		data.Metadata = *hw.Spec.Metadata
+	}
+
+	if hw.Spec.VendorData != nil {
+		data.VendorData = *hw.Spec.VendorData
+	}
+
+	device := "00:00:00:00:00:01"
+	if len(hw.Spec.Interfaces) > 0 && hw.Spec.Interfaces[0].DHCP != nil && hw.Spec.Interfaces[0].DHCP.MAC != "" {
+		device = hw.Spec.Interfaces[0].DHCP.MAC
+	}
+
+	return map[string]any{"device_1": device, "Hardware": data}
+}
+
+// collectFuncs adds a placeholder for each function called in the node to funcs.
+func collectFuncs(node parse.Node, funcs template.FuncMap) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, c := range n.Nodes {
+			collectFuncs(c, funcs)
+		}
+	case *parse.ActionNode:
+		collectFuncs(n.Pipe, funcs)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+
+		for _, cmd := range n.Cmds {
+			collectFuncs(cmd, funcs)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectFuncs(arg, funcs)
+		}
+	case *parse.IdentifierNode:
+		if _, builtin := builtins[n.Ident]; !builtin {
+			funcs[n.Ident] = func(...any) string { return "" }
+		}
+	case *parse.IfNode:
+		collectBranch(&n.BranchNode, funcs)
+	case *parse.RangeNode:
+		collectBranch(&n.BranchNode, funcs)
+	case *parse.WithNode:
+		collectBranch(&n.BranchNode, funcs)
+	case *parse.TemplateNode:
+		collectFuncs(n.Pipe, funcs)
+	}
+}
+
+func collectBranch(n *parse.BranchNode, funcs template.FuncMap) {
+	collectFuncs(n.Pipe, funcs)
+	collectFuncs(n.List, funcs)
+	collectFuncs(n.ElseList, funcs)
+}
+
+// builtins are the functions predefined by text/template.
+//
+//nolint:gochecknoglobals
+var builtins = map[string]struct{}{
+	"and": {}, "call": {}, "html": {}, "index": {}, "slice": {}, "js": {}, "len": {}, "not": {}, "or": {},
+	"print": {}, "printf": {}, "println": {}, "urlquery": {}, "eq": {}, "ge": {}, "gt": {}, "le": {}, "lt": {},
+	"ne": {},
+}
@@ -0,0 +1,101 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tinktemplate_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/tinktemplate"
+)
+
+const validTemplate = `version: "0.1"
+name: ubuntu
+global_timeout: 6000
+tasks:
+  - name: "ubuntu"
+    worker: "{{.device_1}}"
+    actions:
+      - name: "stream image"
+        image: quay.io/tinkerbell-actions/image2disk:v1.0.0
+        timeout: 600
+        environment:
+          DEST_DISK: {{ index .Hardware.Disks 0 }}
+      - name: "write hostname"
+        image: quay.io/tinkerbell-actions/writefile:v1.0.0
+        timeout: 90
+        environment:
+          DEST_DISK: {{ formatPartition ( index .Hardware.Disks 0 ) 1 }}
+`
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data    string
+		wantErr error
+	}{
+		"valid template": {data: validTemplate},
+		"template depending on data of the workflow controller": {
+			data: "{{ if .Hardware.Metadata.Instance.ID }}name: foo{{ end }}",
+		},
+		"unterminated action": {
+			data:    "version: \"0.1\"\nname: {{ .device_1 \n",
+			wantErr: tinktemplate.ErrSyntax,
+		},
+		"invalid YAML": {
+			data:    "version: \"0.1\"\nname: foo\ntasks: [\n",
+			wantErr: tinktemplate.ErrMalformed,
+		},
+		"no name": {
+			data:    "version: \"0.1\"\ntasks:\n  - name: foo\n",
+			wantErr: tinktemplate.ErrMalformed,
+		},
+		"no tasks": {
+			data:    "version: \"0.1\"\nname: foo\n",
+			wantErr: tinktemplate.ErrMalformed,
+		},
+		"duplicate task names": {
+			data:    "name: foo\ntasks:\n  - name: a\n  - name: a\n",
+			wantErr: tinktemplate.ErrMalformed,
+		},
+		"duplicate action names": {
+			data: "name: foo\ntasks:\n  - name: a\n    actions:\n      - name: x\n        image: busybox\n" +
+				"      - name: x\n        image: busybox\n",
+			wantErr: tinktemplate.ErrMalformed,
+		},
+		"action without image": {
+			data:    "name: foo\ntasks:\n  - name: a\n    actions:\n      - name: x\n",
+			wantErr: tinktemplate.ErrMalformed,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			err := tinktemplate.Validate(tc.data, nil)
+			if tc.wantErr == nil {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(tc.wantErr))
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/tracing"
+)
+
+func Test_Setup_without_endpoint_is_noop(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	shutdown, err := tracing.Setup(context.Background(), tracing.Options{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(shutdown(context.Background())).To(Succeed())
+}
+
+//nolint:paralleltest // Sets the global tracer provider.
+func Test_End_records_errors(t *testing.T) {
+	g := NewWithT(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	_, child := tracing.Start(ctx, "child")
+	tracing.End(child, errors.New("boom")) //nolint:goerr113
+	tracing.End(parent, nil)
+
+	spans := recorder.Ended()
+	g.Expect(spans).To(HaveLen(2))
+	g.Expect(spans[0].Name()).To(Equal("child"))
+	g.Expect(spans[0].Parent().SpanID()).To(Equal(spans[1].SpanContext().SpanID()))
+	g.Expect(spans[0].Status().Code).To(Equal(codes.Error))
+	g.Expect(spans[0].Status().Description).To(Equal("boom"))
+	g.Expect(spans[1].Status().Code).To(Equal(codes.Unset))
+}
@@ -17,34 +17,46 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
-	"github.com/go-logr/zerologr"
-	"github.com/rs/zerolog"
 	"github.com/spf13/pflag"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	cgrecord "k8s.io/client-go/tools/record"
 	"k8s.io/component-base/version"
 	"k8s.io/klog/v2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/flags"
 	"sigs.k8s.io/cluster-api/util/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
 	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
 
-	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
-
 	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/binding"
 	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/cluster"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/discovery"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/hardware"
 	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machinetemplate"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/feature"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/logging"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/tracing"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -70,13 +82,16 @@ func init() {
 //nolint:gochecknoglobals
 var (
 	enableLeaderElection          bool
-	metricsAddr                   string
+	managerOptions                = flags.ManagerOptions{}
 	leaderElectionNamespace       string
-	watchNamespace                string
+	watchNamespaces               []string
 	profilerAddress               string
 	healthAddr                    string
 	watchFilterValue              string
 	webhookCertDir                string
+	webhookCertName               string
+	webhookKeyName                string
+	webhookCertPollInterval       time.Duration
 	tinkerbellClusterConcurrency  int
 	tinkerbellMachineConcurrency  int
 	tinkerbellHardwareConcurrency int
@@ -87,15 +102,49 @@ var (
 	leaderElectionLeaseDuration   time.Duration
 	leaderElectionRenewDeadline   time.Duration
 	leaderElectionRetryPeriod     time.Duration
+	bootstrapDataTTL              time.Duration
+	bmcJobTTL                     time.Duration
+	bmcJobRetries                 int
+	bmcJobRetryBackoff            time.Duration
+	nodeGate                      string
+	hardwareQuarantineThreshold   int
+	propagatedLabels              []string
+	propagatedAnnotations         []string
+	hardwareBindingsConfigMap     string
+	hardwareInventoryConfigMap    string
+	finalActionGracePeriod        time.Duration
+	imagePreflightCheck           bool
+	imagePreflightCABundle        string
+	imagePreflightInsecure        bool
+	hardwareLeaseDuration         time.Duration
+	workflowTerminationTimeout    time.Duration
+	orphanSweepInterval           time.Duration
+	orphanSweepDryRun             bool
+	hookURL                       string
+	tinkServerAddress             string
+	tinkServerTLS                 bool
+	syslogHost                    string
+	tinkWorkerImage               string
+	bootstrapReportAddress        string
+	bootstrapReportURL            string
+	bootstrapReportCertFile       string
+	bootstrapReportKeyFile        string
+	consoleCaptureImage           string
+	inventoryScanImage            string
+	imagePrewarmImage             string
+	otlpEndpoint                  string
+	otlpInsecure                  bool
+	otlpSamplingRatio             float64
+	logLevel                      string
+	logFormat                     string
+	logControllerVerbosity        map[string]int
+	logSamplingBurst              int
+	logSamplingPeriod             time.Duration
+	featureGates                  = feature.NewGates()
 )
 
 func initFlags(fs *pflag.FlagSet) { //nolint:funlen
-	fs.StringVar(
-		&metricsAddr,
-		"metrics-bind-addr",
-		"localhost:8080",
-		"The address the metric endpoint binds to.",
-	)
+	flags.AddManagerOptions(fs, &managerOptions)
 
 	fs.BoolVar(
 		&enableLeaderElection,
@@ -125,11 +174,11 @@ func initFlags(fs *pflag.FlagSet) { //nolint:funlen
 		"Duration the LeaderElector clients should wait between tries of actions (duration string)",
 	)
 
-	fs.StringVar(
-		&watchNamespace,
+	fs.StringSliceVar(
+		&watchNamespaces,
 		"namespace",
-		"",
-		"Namespace that the controller watches to reconcile cluster-api objects. If unspecified, the controller watches for cluster-api objects across all namespaces.", //nolint:lll
+		nil,
+		"Comma separated namespaces that the controller watches to reconcile cluster-api objects and select Hardware from. If unspecified, the controller watches for cluster-api objects across all namespaces. Required when installed with the namespace-scoped RBAC manifests.", //nolint:lll
 	)
 
 	fs.StringVar(
@@ -168,19 +217,19 @@ func initFlags(fs *pflag.FlagSet) { //nolint:funlen
 	fs.IntVar(&tinkerbellHardwareConcurrency,
 		"tinkerbell-hardware-concurrency",
 		10, //nolint:gomnd
-		"Number of Tinkerbell Hardware resources to process simultaneously",
+		"Number of Tinkerbell Hardware resources processed simultaneously by each of the Hardware readiness, lease, binding and inventory controllers", //nolint:lll
 	)
 
 	fs.IntVar(&tinkerbellTemplateConcurrency,
 		"tinkerbell-template-concurrency",
 		10, //nolint:gomnd
-		"Number of Tinkerbell Template resources to process simultaneously",
+		"Number of Tinkerbell Templates of TinkerbellMachines validated simultaneously",
 	)
 
 	fs.IntVar(&tinkerbellWorkflowConcurrency,
 		"tinkerbell-workflow-concurrency",
 		10, //nolint:gomnd
-		"Number of Tinkerbell Workflow resources to process simultaneously",
+		"Number of Tinkerbell Workflows mirrored into the status of their TinkerbellMachines simultaneously",
 	)
 
 	fs.DurationVar(&syncPeriod,
@@ -189,6 +238,234 @@ func initFlags(fs *pflag.FlagSet) { //nolint:funlen
 		"The minimum interval at which watched resources are reconciled (e.g. 15m)",
 	)
 
+	fs.DurationVar(&bootstrapDataTTL,
+		"bootstrap-data-ttl",
+		0,
+		"How long bootstrap data remains usable after its Secret was created (e.g. the kubeadm bootstrap token TTL). Machines wait for refreshed bootstrap data instead of provisioning with expired data. Zero disables the check.", //nolint:lll
+	)
+
+	fs.DurationVar(&bmcJobTTL,
+		"bmc-job-ttl",
+		24*time.Hour, //nolint:gomnd
+		"How long finished BMC Jobs are kept before they are removed. The newest Job of each operation is always kept. Zero disables the cleanup.", //nolint:lll
+	)
+
+	fs.IntVar(&bmcJobRetries,
+		"bmc-job-retries",
+		3, //nolint:gomnd
+		"How often a BMC operation, e.g. powering off Hardware, whose Jobs failed through all BMCs of the Hardware is retried before it is reported as failed. Zero disables retries.", //nolint:lll
+	)
+
+	fs.DurationVar(&bmcJobRetryBackoff,
+		"bmc-job-retry-backoff",
+		30*time.Second, //nolint:gomnd
+		"How long a failed BMC operation waits before its first retry. The back-off doubles with every further retry, up to 10 minutes.", //nolint:lll
+	)
+
+	fs.StringVar(&nodeGate,
+		"node-gate",
+		string(machine.NodeGateNone),
+		"What is required from the Node of a provisioned machine before the TinkerbellMachine is marked as Ready: none, joined (the Node registered with the workload cluster) or ready (the Node is also Ready, which requires the CNI to be installed independently of machine readiness).", //nolint:lll
+	)
+
+	fs.IntVar(&hardwareQuarantineThreshold,
+		"hardware-quarantine-threshold",
+		3, //nolint:gomnd
+		"Number of consecutive provisioning failures (failed workflows or BMC Jobs) after which Hardware is labeled as quarantined and no longer selected for machines. Zero disables quarantining.", //nolint:lll
+	)
+
+	fs.StringSliceVar(&propagatedLabels,
+		"propagate-labels",
+		nil,
+		"Comma separated keys of TinkerbellMachine labels copied onto the Templates, Workflows and BMC Jobs created for it, e.g. team,cost-center.", //nolint:lll
+	)
+
+	fs.StringSliceVar(&propagatedAnnotations,
+		"propagate-annotations",
+		nil,
+		"Comma separated keys of TinkerbellMachine annotations copied onto the Templates, Workflows and BMC Jobs created for it.", //nolint:lll
+	)
+
+	fs.StringVar(&hardwareBindingsConfigMap,
+		"hardware-bindings-configmap",
+		"",
+		"Name of the ConfigMap the Hardware bindings of each namespace are backed up to, for capt-ctl restore-bindings to re-apply them after a disaster recovery. Backups are disabled if unspecified.", //nolint:lll
+	)
+
+	fs.StringVar(&hardwareInventoryConfigMap,
+		"hardware-inventory-configmap",
+		"",
+		"Name of the ConfigMaps holding the CSV inventory Hardware is created from in their namespace, with the DiscoveryController feature gate. Discovery is disabled if unspecified.", //nolint:lll
+	)
+
+	fs.DurationVar(&finalActionGracePeriod,
+		"final-action-grace-period",
+		0,
+		"How long the final action of a workflow may run, once all other actions succeeded, before the machine is considered provisioned. For final actions like kexec which never report success. Zero waits for the workflow to succeed.", //nolint:lll
+	)
+
+	fs.DurationVar(&hardwareLeaseDuration,
+		"hardware-lease-duration",
+		0,
+		"How long claimed Hardware may go without provisioning progress before its lease expires. Hardware with an expired lease is released once the TinkerbellMachine it was claimed for is gone. Zero disables leases.", //nolint:lll
+	)
+
+	fs.DurationVar(&workflowTerminationTimeout,
+		"workflow-termination-timeout",
+		10*time.Minute, //nolint:gomnd
+		"How long the deletion of a TinkerbellMachine waits for its running workflows to finish before its Hardware is powered off, or quarantined when it cannot be, and the workflows are removed. Zero does not wait.", //nolint:lll
+	)
+
+	fs.DurationVar(&orphanSweepInterval,
+		"orphan-sweep-interval",
+		time.Hour,
+		"How often Templates, Workflows and BMC Jobs whose TinkerbellMachine no longer exists are deleted. Zero disables the sweeper.", //nolint:lll
+	)
+
+	fs.BoolVar(&orphanSweepDryRun,
+		"orphan-sweep-dry-run",
+		false,
+		"Only log and count the Templates and Workflows whose TinkerbellMachine no longer exists instead of deleting them.",
+	)
+
+	fs.StringVar(&hookURL,
+		"hook-url",
+		"",
+		"Base URL of the Hook kernel and initramfs, for machines netbooting Hook with kernel arguments. Defaults to port 8080 of TINKERBELL_IP.", //nolint:lll
+	)
+
+	fs.StringVar(&tinkServerAddress,
+		"tink-server-address",
+		"",
+		"Host and port of the Tink server gRPC API, for machines netbooting Hook with kernel arguments. Defaults to port 42113 of TINKERBELL_IP.", //nolint:lll
+	)
+
+	fs.BoolVar(&tinkServerTLS,
+		"tink-server-tls",
+		false,
+		"Connect tink-worker of machines netbooting Hook with kernel arguments to the Tink server with TLS.",
+	)
+
+	fs.StringVar(&syslogHost,
+		"syslog-host",
+		"",
+		"Host Hook of machines netbooting it with kernel arguments sends its logs to. Defaults to TINKERBELL_IP.",
+	)
+
+	fs.StringVar(&tinkWorkerImage,
+		"tink-worker-image",
+		"",
+		"tink-worker image run by Hook of machines netbooting it with kernel arguments. Defaults to quay.io/tinkerbell/tink-worker:v0.12.2.", //nolint:lll
+	)
+
+	fs.StringVar(&bootstrapReportAddress,
+		"bootstrap-report-bind-address",
+		"",
+		"The address the endpoint receiving the bootstrap results of machines binds to. Empty disables bootstrap reports.",
+	)
+
+	fs.StringVar(&bootstrapReportURL,
+		"bootstrap-report-url",
+		"",
+		"Base URL under which machines reach the bootstrap report endpoint, e.g. https://10.1.1.1:8082. Required with --bootstrap-report-bind-address.", //nolint:lll
+	)
+
+	fs.StringVar(&bootstrapReportCertFile,
+		"bootstrap-report-tls-cert-file",
+		"",
+		"Certificate the bootstrap report endpoint is served with over TLS. Requires --bootstrap-report-tls-key-file.",
+	)
+
+	fs.StringVar(&bootstrapReportKeyFile,
+		"bootstrap-report-tls-key-file",
+		"",
+		"Key of the certificate the bootstrap report endpoint is served with over TLS.",
+	)
+
+	fs.StringVar(&consoleCaptureImage,
+		"console-capture-image",
+		"",
+		"Image with ipmitool capturing the serial console of machines with consoleCapture while they provision. Empty disables console capture.", //nolint:lll
+	)
+
+	fs.StringVar(&inventoryScanImage,
+		"inventory-scan-image",
+		"",
+		"Image of the action collecting the inventory of Hardware annotated for an inventory scan. Requires --bootstrap-report-url and the InventoryScan feature gate.", //nolint:lll
+	)
+
+	fs.StringVar(&imagePrewarmImage,
+		"image-prewarm-image",
+		"",
+		"Image of the action fetching the image Hardware is annotated to be pre-warmed with. Requires the ImagePrewarm feature gate.", //nolint:lll
+	)
+
+	fs.BoolVar(&imagePreflightCheck,
+		"image-preflight-check",
+		false,
+		"Check with an HTTP HEAD request that the OS image of a machine can be downloaded before powering it on to provision it.", //nolint:lll
+	)
+
+	fs.StringVar(&imagePreflightCABundle,
+		"image-preflight-ca-bundle",
+		"",
+		"Path to a PEM encoded CA bundle trusted, in addition to the system CAs, by the image pre-flight check.",
+	)
+
+	fs.BoolVar(&imagePreflightInsecure,
+		"image-preflight-insecure-skip-verify",
+		false,
+		"Skip the verification of the TLS certificates of image servers by the image pre-flight check.",
+	)
+
+	fs.StringVar(&otlpEndpoint,
+		"otlp-endpoint",
+		"",
+		"The host:port of the OTLP gRPC collector reconcile traces are exported to. Tracing is disabled if unspecified.",
+	)
+
+	fs.BoolVar(&otlpInsecure,
+		"otlp-insecure",
+		false,
+		"Connect to the OTLP collector without TLS.",
+	)
+
+	fs.Float64Var(&otlpSamplingRatio,
+		"otlp-sampling-ratio",
+		1,
+		"Fraction of reconciliations traced, between 0 and 1. Reconciliations triggered within a sampled trace are always traced.", //nolint:lll
+	)
+
+	fs.StringVar(&logLevel,
+		"log-level",
+		"info",
+		fmt.Sprintf("Log level: error, info, debug (per-reconcile details, verbosity %d) or the highest verbosity to log.", logging.DebugVerbosity), //nolint:lll
+	)
+
+	fs.StringVar(&logFormat,
+		"log-format",
+		logging.FormatJSON,
+		"Log format: json or console.",
+	)
+
+	fs.StringToIntVar(&logControllerVerbosity,
+		"log-controller-verbosity",
+		nil,
+		"Comma separated highest verbosity to log per controller, overriding the log level, e.g. tinkerbellmachine=4,hardwarereadiness=0.", //nolint:lll
+	)
+
+	fs.IntVar(&logSamplingBurst,
+		"log-sampling-burst",
+		0,
+		"Number of messages of each level below warning logged per sampling period, further messages are dropped. Zero disables sampling.", //nolint:lll
+	)
+
+	fs.DurationVar(&logSamplingPeriod,
+		"log-sampling-period",
+		time.Second,
+		"The period the log sampling burst applies to.",
+	)
+
 	fs.IntVar(&webhookPort,
 		"webhook-port",
 		9443, //nolint:gomnd
@@ -201,11 +478,31 @@ func initFlags(fs *pflag.FlagSet) { //nolint:funlen
 		"Webhook Server Certificate Directory, is the directory that contains the server key and certificate",
 	)
 
+	fs.StringVar(&webhookCertName,
+		"webhook-cert-name",
+		"tls.crt",
+		"Name of the Webhook Server certificate file in the certificate directory, e.g. of the Secret cert-manager issues the certificate to", //nolint:lll
+	)
+
+	fs.StringVar(&webhookKeyName,
+		"webhook-key-name",
+		"tls.key",
+		"Name of the Webhook Server key file in the certificate directory",
+	)
+
+	fs.DurationVar(&webhookCertPollInterval,
+		"webhook-cert-poll-interval",
+		10*time.Second, //nolint:gomnd
+		"Interval at which the Webhook Server certificate is checked for changes, in addition to watching it. Rotated certificates are served without a restart.", //nolint:lll
+	)
+
 	fs.StringVar(&healthAddr,
 		"health-addr",
 		":9440",
 		"The address the health endpoint binds to.",
 	)
+
+	featureGates.AddFlag(fs)
 }
 
 func addHealthChecks(mgr ctrl.Manager) error {
@@ -221,6 +518,44 @@ func addHealthChecks(mgr ctrl.Manager) error {
 }
 
 func setupReconcilers(ctx context.Context, mgr ctrl.Manager) error {
+	var imageChecker machine.ImageChecker
+
+	if (bootstrapReportAddress == "") != (bootstrapReportURL == "") {
+		return fmt.Errorf("--bootstrap-report-bind-address and --bootstrap-report-url must be set together")
+	}
+
+	scanInventory := inventoryScanImage != "" && featureGates.Enabled(feature.InventoryScan)
+	if scanInventory && bootstrapReportURL == "" {
+		return fmt.Errorf("--inventory-scan-image requires --bootstrap-report-url")
+	}
+
+	prewarmImages := imagePrewarmImage != "" && featureGates.Enabled(feature.ImagePrewarm)
+
+	netbootHandshake := featureGates.Enabled(feature.NetbootHandshake)
+	if netbootHandshake && bootstrapReportURL == "" {
+		return fmt.Errorf("the %s feature gate requires --bootstrap-report-url", feature.NetbootHandshake)
+	}
+
+	if imagePreflightCheck {
+		httpClient, err := machine.ImageCheckHTTPClient(imagePreflightCABundle, imagePreflightInsecure)
+		if err != nil {
+			return fmt.Errorf("unable to setup image pre-flight check:%w", err)
+		}
+
+		imageChecker = machine.NewHTTPImageChecker(httpClient)
+	}
+
+	var consoleLogReader machine.ConsoleLogReader
+
+	if consoleCaptureImage != "" {
+		clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			return fmt.Errorf("unable to setup console capture:%w", err)
+		}
+
+		consoleLogReader = machine.NewConsoleLogReader(clientset)
+	}
+
 	if err := (&cluster.TinkerbellClusterReconciler{
 		Client:           mgr.GetClient(),
 		WatchFilterValue: watchFilterValue,
@@ -229,15 +564,213 @@ func setupReconcilers(ctx context.Context, mgr ctrl.Manager) error {
 	}
 
 	if err := (&machine.TinkerbellMachineReconciler{
-		Client:           mgr.GetClient(),
-		WatchFilterValue: watchFilterValue,
+		Client:             mgr.GetClient(),
+		WatchFilterValue:   watchFilterValue,
+		BootstrapDataTTL:   bootstrapDataTTL,
+		BMCJobTTL:          bmcJobTTL,
+		BMCJobRetries:      bmcJobRetries,
+		BMCJobRetryBackoff: bmcJobRetryBackoff,
+		NodeGate:           machine.NodeGate(nodeGate),
+		WatchNamespaces:    watchNamespaces,
+
+		HardwareQuarantineThreshold: hardwareQuarantineThreshold,
+		PropagatedLabels:            propagatedLabels,
+		PropagatedAnnotations:       propagatedAnnotations,
+		FinalActionGracePeriod:      finalActionGracePeriod,
+		ImageChecker:                imageChecker,
+		HardwareLeaseDuration:       hardwareLeaseDuration,
+		WorkflowTerminationTimeout:  workflowTerminationTimeout,
+		FeatureGates:                featureGates,
+		BootstrapReportURL:          bootstrapReportURL,
+		ConsoleCaptureImage:         consoleCaptureImage,
+		ConsoleLogReader:            consoleLogReader,
+		HookBoot: machine.HookBootOptions{
+			URL:               hookURL,
+			TinkServerAddress: tinkServerAddress,
+			TinkServerTLS:     tinkServerTLS,
+			SyslogHost:        syslogHost,
+			TinkWorkerImage:   tinkWorkerImage,
+		},
 	}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: tinkerbellMachineConcurrency}); err != nil {
 		return fmt.Errorf("unable to setup TinkerbellMachine controller:%w", err)
 	}
 
+	if orphanSweepInterval > 0 {
+		if err := mgr.Add(&machine.OrphanSweeper{
+			Client:   mgr.GetClient(),
+			Interval: orphanSweepInterval,
+			DryRun:   orphanSweepDryRun,
+		}); err != nil {
+			return fmt.Errorf("unable to setup orphan sweeper:%w", err)
+		}
+	}
+
+	if bootstrapReportAddress != "" {
+		handlers := map[string]http.Handler{}
+		if scanInventory {
+			handlers[hardware.InventoryReportPattern] = &hardware.InventoryReportHandler{Client: mgr.GetClient()}
+		}
+
+		if netbootHandshake {
+			handlers[machine.NetbootHandshakePattern] = &machine.NetbootHandshakeHandler{Client: mgr.GetClient()}
+		}
+
+		tlsConfig, err := bootstrapReportTLSConfig(mgr)
+		if err != nil {
+			return err
+		}
+
+		if err := mgr.Add(&machine.BootstrapReportServer{
+			Client:    mgr.GetClient(),
+			Address:   bootstrapReportAddress,
+			Handlers:  handlers,
+			TLSConfig: tlsConfig,
+		}); err != nil {
+			return fmt.Errorf("unable to setup bootstrap report server:%w", err)
+		}
+	}
+
+	if err := (&machine.WorkflowStatusReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: tinkerbellWorkflowConcurrency}); err != nil {
+		return fmt.Errorf("unable to setup Workflow status controller:%w", err)
+	}
+
+	if err := (&machine.TemplateValidationReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: tinkerbellTemplateConcurrency}); err != nil {
+		return fmt.Errorf("unable to setup Template validation controller:%w", err)
+	}
+
+	templateHardwareOptions := machine.TemplateHardwareOptions{
+		FeatureGates:    featureGates,
+		WatchNamespaces: watchNamespaces,
+	}
+
+	if err := (&machinetemplate.CapacityReconciler{
+		Client:          mgr.GetClient(),
+		HardwareOptions: templateHardwareOptions,
+	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: tinkerbellMachineConcurrency}); err != nil {
+		return fmt.Errorf("unable to setup TinkerbellMachineTemplate capacity controller:%w", err)
+	}
+
+	if err := (&hardware.ReadinessReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: tinkerbellHardwareConcurrency}); err != nil {
+		return fmt.Errorf("unable to setup Hardware readiness controller:%w", err)
+	}
+
+	if hardwareLeaseDuration > 0 {
+		if err := (&hardware.LeaseReconciler{
+			Client:   mgr.GetClient(),
+			Duration: hardwareLeaseDuration,
+		}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: tinkerbellHardwareConcurrency}); err != nil {
+			return fmt.Errorf("unable to setup Hardware lease controller:%w", err)
+		}
+	}
+
+	if scanInventory {
+		if err := (&hardware.InventoryScanReconciler{
+			Client:    mgr.GetClient(),
+			Image:     inventoryScanImage,
+			ReportURL: bootstrapReportURL,
+		}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: tinkerbellHardwareConcurrency}); err != nil {
+			return fmt.Errorf("unable to setup Hardware inventory scan controller:%w", err)
+		}
+	}
+
+	if prewarmImages {
+		if err := (&hardware.ImagePrewarmReconciler{
+			Client: mgr.GetClient(),
+			Image:  imagePrewarmImage,
+		}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: tinkerbellHardwareConcurrency}); err != nil {
+			return fmt.Errorf("unable to setup Hardware image pre-warm controller:%w", err)
+		}
+
+		if err := (&machinetemplate.PrewarmReconciler{
+			Client:          mgr.GetClient(),
+			HardwareOptions: templateHardwareOptions,
+		}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: tinkerbellMachineConcurrency}); err != nil {
+			return fmt.Errorf("unable to setup TinkerbellMachineTemplate image pre-warm controller:%w", err)
+		}
+	}
+
+	if hardwareBindingsConfigMap != "" {
+		if err := (&binding.HardwareBindingReconciler{
+			Client:        mgr.GetClient(),
+			ConfigMapName: hardwareBindingsConfigMap,
+		}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: tinkerbellHardwareConcurrency}); err != nil {
+			return fmt.Errorf("unable to setup Hardware binding controller:%w", err)
+		}
+	}
+
+	if hardwareInventoryConfigMap != "" && featureGates.Enabled(feature.DiscoveryController) {
+		if err := (&discovery.InventoryReconciler{
+			Client:        mgr.GetClient(),
+			ConfigMapName: hardwareInventoryConfigMap,
+		}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: tinkerbellHardwareConcurrency}); err != nil {
+			return fmt.Errorf("unable to setup Hardware inventory controller:%w", err)
+		}
+	}
+
 	return nil
 }
 
+// newWebhookServer returns the webhook server, serving the certificate in the webhook certificate directory with
+// the given TLS options. The returned watcher reloads the certificate when it changes, e.g. when cert-manager renews
+// it, and must be added to the manager.
+func newWebhookServer(tlsOptions []func(*tls.Config)) (webhook.Server, *certwatcher.CertWatcher, error) {
+	watcher, err := certwatcher.New(
+		filepath.Join(webhookCertDir, webhookCertName),
+		filepath.Join(webhookCertDir, webhookKeyName),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading webhook server certificate: %w", err)
+	}
+
+	watcher = watcher.WithWatchInterval(webhookCertPollInterval)
+	watcher.RegisterCallback(func(cert tls.Certificate) {
+		if cert.Leaf != nil {
+			setupLog.Info("Loaded webhook server certificate", "notAfter", cert.Leaf.NotAfter)
+		}
+	})
+
+	server := webhook.NewServer(webhook.Options{
+		Port: webhookPort,
+		TLSOpts: append(tlsOptions,
+			func(cfg *tls.Config) { cfg.GetCertificate = watcher.GetCertificate },
+		),
+	})
+
+	return server, watcher, nil
+}
+
+// bootstrapReportTLSConfig returns the TLS configuration of the bootstrap report server, serving the configured
+// certificate, or nil when none is configured. The certificate is reloaded when it changes.
+func bootstrapReportTLSConfig(mgr ctrl.Manager) (*tls.Config, error) {
+	if bootstrapReportCertFile == "" && bootstrapReportKeyFile == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	if bootstrapReportCertFile == "" || bootstrapReportKeyFile == "" {
+		return nil, fmt.Errorf("--bootstrap-report-tls-cert-file and --bootstrap-report-tls-key-file must be set together")
+	}
+
+	watcher, err := certwatcher.New(bootstrapReportCertFile, bootstrapReportKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading bootstrap report server certificate: %w", err)
+	}
+
+	if err := mgr.Add(watcher); err != nil {
+		return nil, fmt.Errorf("unable to setup bootstrap report server certificate watcher:%w", err)
+	}
+
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: watcher.GetCertificate,
+	}, nil
+}
+
 func setupWebhooks(mgr ctrl.Manager) error {
 	if err := (&infrastructurev1.TinkerbellCluster{}).SetupWebhookWithManager(mgr); err != nil {
 		return fmt.Errorf("unable to setup TinkerbellCluster webhook:%w", err)
@@ -251,6 +784,34 @@ func setupWebhooks(mgr ctrl.Manager) error {
 		return fmt.Errorf("unable to setup TinkerbellMachineTemplate webhook:%w", err)
 	}
 
+	if err := (&hardware.DeletionGuard{Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
+		return fmt.Errorf("unable to setup Hardware webhook:%w", err)
+	}
+
+	return nil
+}
+
+// errInvalidWatchNamespace is returned for namespaces to watch which are empty or listed more than once. An empty
+// namespace is the all-namespaces key of the cache, which would silently watch the whole cluster.
+var errInvalidWatchNamespace = errors.New("invalid namespace to watch")
+
+// validateWatchNamespaces returns an error when a namespace to watch is empty, blank or listed more than once, e.g.
+// from a stray comma in --namespace.
+func validateWatchNamespaces(namespaces []string) error {
+	seen := map[string]bool{}
+
+	for _, namespace := range namespaces {
+		if strings.TrimSpace(namespace) == "" {
+			return fmt.Errorf("%w: empty namespace in %q", errInvalidWatchNamespace, strings.Join(namespaces, ","))
+		}
+
+		if seen[namespace] {
+			return fmt.Errorf("%w: %q is listed more than once", errInvalidWatchNamespace, namespace)
+		}
+
+		seen[namespace] = true
+	}
+
 	return nil
 }
 
@@ -259,8 +820,28 @@ func main() { //nolint:funlen
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 	pflag.Parse()
 
-	if watchNamespace != "" {
-		setupLog.Info("Watching cluster-api objects only in namespace for reconciliation", "namespace", watchNamespace)
+	logger, err := logging.New(os.Stdout, logging.Options{
+		Level:               logLevel,
+		Format:              logFormat,
+		ControllerVerbosity: logControllerVerbosity,
+		SamplingBurst:       logSamplingBurst,
+		SamplingPeriod:      logSamplingPeriod,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid logging flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctrl.SetLogger(logger)
+	klog.SetLogger(logger)
+
+	if err := validateWatchNamespaces(watchNamespaces); err != nil {
+		setupLog.Error(err, "invalid --namespace flag")
+		os.Exit(1)
+	}
+
+	if len(watchNamespaces) > 0 {
+		setupLog.Info("Watching cluster-api objects only in namespaces for reconciliation", "namespaces", watchNamespaces)
 	}
 
 	if profilerAddress != "" {
@@ -272,21 +853,20 @@ func main() { //nolint:funlen
 		}()
 	}
 
-	zl := zerolog.New(os.Stdout).Level(zerolog.InfoLevel).With().Caller().Timestamp().Logger()
-	logger := zerologr.New(&zl)
-	ctrl.SetLogger(logger)
-	klog.SetLogger(logger)
-
 	// Machine and cluster operations can create enough events to trigger the event recorder spam filter
 	// Setting the burst size higher ensures all events will be recorded and submitted to the API
 	broadcaster := cgrecord.NewBroadcasterWithCorrelatorOptions(cgrecord.CorrelatorOptions{
 		BurstSize: 100, //nolint:gomnd
 	})
+	tlsOptions, metricsOptions, err := flags.GetManagerOptions(managerOptions)
+	if err != nil {
+		setupLog.Error(err, "unable to parse manager options")
+		os.Exit(1)
+	}
+
 	opts := ctrl.Options{
-		Scheme: scheme,
-		Metrics: metricsserver.Options{
-			BindAddress: metricsAddr,
-		},
+		Scheme:                  scheme,
+		Metrics:                 *metricsOptions,
 		LeaderElection:          enableLeaderElection,
 		LeaderElectionID:        "controller-leader-election-capt",
 		LeaderElectionNamespace: leaderElectionNamespace,
@@ -295,11 +875,26 @@ func main() { //nolint:funlen
 		RetryPeriod:             &leaderElectionRetryPeriod,
 		HealthProbeBindAddress:  healthAddr,
 		EventBroadcaster:        broadcaster,
+		// Hardware created by older Tinkerbell versions is read as unstructured objects while selecting Hardware,
+		// see machine.ResolveStatusInterfaces.
+		Client: client.Options{Cache: &client.CacheOptions{Unstructured: true}},
+	}
+
+	webhookServer, webhookCertWatcher, err := newWebhookServer(tlsOptions)
+	if err != nil {
+		setupLog.Error(err, "unable to setup webhook server")
+		os.Exit(1)
 	}
 
-	if watchNamespace != "" {
+	opts.WebhookServer = webhookServer
+
+	if len(watchNamespaces) > 0 {
 		opts.Cache = cache.Options{
-			DefaultNamespaces: map[string]cache.Config{watchNamespace: {}},
+			DefaultNamespaces: map[string]cache.Config{},
+		}
+
+		for _, namespace := range watchNamespaces {
+			opts.Cache.DefaultNamespaces[namespace] = cache.Config{}
 		}
 	}
 
@@ -309,12 +904,28 @@ func main() { //nolint:funlen
 		os.Exit(1)
 	}
 
+	if err := mgr.Add(webhookCertWatcher); err != nil {
+		setupLog.Error(err, "unable to add webhook certificate watcher")
+		os.Exit(1)
+	}
+
 	// Initialize event recorder.
 	record.InitFromRecorder(mgr.GetEventRecorderFor("tinkerbell-controller"))
 
 	// Setup the context that's going to be used in controllers and for the manager.
 	ctx := ctrl.SetupSignalHandler()
 
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Endpoint:      otlpEndpoint,
+		Insecure:      otlpInsecure,
+		SamplingRatio: otlpSamplingRatio,
+		Version:       version.Get().String(),
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to setup tracing")
+		os.Exit(1)
+	}
+
 	if err := setupReconcilers(ctx, mgr); err != nil {
 		setupLog.Error(err, "failed to add Tinkerbell Reconcilers")
 		os.Exit(1)
@@ -337,4 +948,12 @@ func main() { //nolint:funlen
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	// The signal handler context is done at this point, flush the remaining spans within a grace period.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //nolint:gomnd
+	defer cancel()
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		setupLog.Error(err, "failed to flush traces")
+	}
 }
@@ -0,0 +1,75 @@
+package main //nolint:testpackage
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/cluster-api/util/flags"
+)
+
+//nolint:paralleltest // initFlags binds the flags to package variables.
+func Test_initFlags_metrics_options(t *testing.T) {
+	for name, tc := range map[string]struct {
+		args    []string
+		address string
+		secure  bool
+	}{
+		"serves_https_with_authentication_by_default": {
+			address: ":8443",
+			secure:  true,
+		},
+		"serves_plain_http_on_the_previous_default_address": {
+			args:    []string{"--diagnostics-address=localhost:8080", "--insecure-diagnostics"},
+			address: "localhost:8080",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			fs := pflag.NewFlagSet("capt", pflag.ContinueOnError)
+			initFlags(fs)
+			g.Expect(fs.Parse(tc.args)).To(Succeed())
+
+			_, metricsOptions, err := flags.GetManagerOptions(managerOptions)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(metricsOptions.BindAddress).To(Equal(tc.address))
+			g.Expect(metricsOptions.SecureServing).To(Equal(tc.secure))
+
+			if tc.secure {
+				g.Expect(metricsOptions.FilterProvider).NotTo(BeNil(),
+					"Expected metrics requests to be authenticated and authorized")
+			}
+		})
+	}
+}
+
+func Test_validateWatchNamespaces(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		namespaces []string
+		valid      bool
+	}{
+		"all namespaces":    {valid: true},
+		"namespaces":        {namespaces: []string{"a", "b"}, valid: true},
+		"empty entry":       {namespaces: []string{"a", "", "b"}},
+		"trailing comma":    {namespaces: []string{"a", ""}},
+		"whitespace entry":  {namespaces: []string{"a", " "}},
+		"duplicate entries": {namespaces: []string{"a", "b", "a"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			err := validateWatchNamespaces(tc.namespaces)
+			if tc.valid {
+				g.Expect(err).NotTo(HaveOccurred())
+
+				return
+			}
+
+			g.Expect(err).To(MatchError(errInvalidWatchNamespace))
+		})
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package hardwareutil defines the labels and annotations CAPT sets on and reads from Tinkerbell Hardware, and
+// helpers for Hardware, so other controllers can integrate with CAPT without importing its controllers.
+package hardwareutil
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// OwnerNameLabel is set by CAPT on Hardware claimed by a TinkerbellMachine to the name of the machine.
+	OwnerNameLabel = "v1alpha1.tinkerbell.org/ownerName"
+
+	// OwnerNamespaceLabel is set by CAPT on Hardware claimed by a TinkerbellMachine to the namespace of the machine.
+	OwnerNamespaceLabel = "v1alpha1.tinkerbell.org/ownerNamespace"
+
+	// ClusterNameLabel is set by CAPT on claimed Hardware to the name of the cluster of the owning machine.
+	ClusterNameLabel = "v1alpha1.tinkerbell.org/clusterName"
+
+	// ClusterNamespaceLabel is set by CAPT on claimed Hardware to the namespace of the cluster of the owning machine.
+	ClusterNamespaceLabel = "v1alpha1.tinkerbell.org/clusterNamespace"
+
+	// ProvisionedAnnotation is set by CAPT to "true" on Hardware once the workflow of its machine succeeded.
+	ProvisionedAnnotation = "v1alpha1.tinkerbell.org/provisioned"
+
+	// QuarantinedLabel is set by CAPT on Hardware which failed provisioning too many consecutive times. Quarantined
+	// Hardware is never selected for a TinkerbellMachine.
+	QuarantinedLabel = "v1alpha1.tinkerbell.org/quarantined"
+
+	// DecommissionLabel is set by users on Hardware to retire it. Such Hardware is never selected for a
+	// TinkerbellMachine, and the Machine provisioned on it is deleted first when scaling down.
+	DecommissionLabel = "v1alpha1.tinkerbell.org/decommission"
+
+	// PausedAnnotation is set by users on Hardware to freeze it, e.g. during an investigation: CAPT does not change
+	// paused Hardware, neither its netboot settings nor its user-data, and does not reconcile the machine it is bound
+	// to until the annotation is removed. Its value is ignored.
+	PausedAnnotation = "v1alpha1.tinkerbell.org/paused"
+
+	// BMCRefsAnnotation is set by users on Hardware with several BMC paths, e.g. Redfish and IPMI, to the
+	// comma-separated names of the rufio Machines CAPT tries in order for its BMC Jobs, moving on to the next one when
+	// a Job fails. The bmcRef of the Hardware is tried first when it is not listed.
+	BMCRefsAnnotation = "v1alpha1.tinkerbell.org/bmc-refs"
+
+	// LastSuccessfulBMCAnnotation is set by CAPT on Hardware to the name of the rufio Machine through which a BMC Job
+	// last completed. It is tried first for later BMC Jobs.
+	LastSuccessfulBMCAnnotation = "v1alpha1.tinkerbell.org/last-successful-bmc"
+
+	// InventoryScanAnnotation is set by users on Hardware to "true" to collect its inventory with a one-off workflow
+	// of CAPT. It is removed once the scan finished.
+	InventoryScanAnnotation = "v1alpha1.tinkerbell.org/inventory-scan"
+
+	// DisksAnnotation is set on Hardware to the JSON list of its disks, as Hardware does not describe the serial
+	// number, WWN, size or kind of its disks. It is matched against the root disk selector of machines.
+	DisksAnnotation = "v1alpha1.tinkerbell.org/disks"
+)
+
+var (
+	// ErrHardwareIsNil is returned when the given Hardware is nil.
+	ErrHardwareIsNil = errors.New("given Hardware object is nil")
+
+	// ErrMissingInterfaces is returned when the Hardware has no network interfaces.
+	ErrMissingInterfaces = errors.New("hardware has no interfaces defined")
+
+	// ErrFirstInterfaceNotDHCP is returned when the first network interface of the Hardware has no DHCP
+	// configuration.
+	ErrFirstInterfaceNotDHCP = errors.New("hardware's first interface has no DHCP address defined")
+
+	// ErrFirstInterfaceDHCPMissingIP is returned when the first network interface of the Hardware has no DHCP IP
+	// address.
+	ErrFirstInterfaceDHCPMissingIP = errors.New("hardware's first interface has no DHCP IP address defined")
+)
+
+// IP returns the IP address of the first network interface of the Hardware, which is the address of its Node.
+func IP(hw *tinkv1.Hardware) (string, error) {
+	if hw == nil {
+		return "", ErrHardwareIsNil
+	}
+
+	if len(hw.Spec.Interfaces) == 0 {
+		return "", ErrMissingInterfaces
+	}
+
+	dhcp := hw.Spec.Interfaces[0].DHCP
+	if dhcp == nil {
+		return "", ErrFirstInterfaceNotDHCP
+	}
+
+	if dhcp.IP == nil || dhcp.IP.Address == "" {
+		return "", ErrFirstInterfaceDHCPMissingIP
+	}
+
+	return dhcp.IP.Address, nil
+}
+
+// Owner returns the TinkerbellMachine which claimed the Hardware, false when it is not claimed.
+func Owner(hw *tinkv1.Hardware) (types.NamespacedName, bool) {
+	name, ok := hw.GetLabels()[OwnerNameLabel]
+	if !ok {
+		return types.NamespacedName{}, false
+	}
+
+	namespace := hw.GetLabels()[OwnerNamespaceLabel]
+	if namespace == "" {
+		namespace = hw.Namespace
+	}
+
+	return types.NamespacedName{Namespace: namespace, Name: name}, true
+}
+
+// Provisioned returns whether the workflow of the machine which claimed the Hardware succeeded.
+func Provisioned(hw *tinkv1.Hardware) bool {
+	return hw.GetAnnotations()[ProvisionedAnnotation] == "true"
+}
+
+// Paused returns whether the Hardware is paused with the PausedAnnotation.
+func Paused(hw *tinkv1.Hardware) bool {
+	_, ok := hw.GetAnnotations()[PausedAnnotation]
+
+	return ok
+}
+
+// Busy returns whether a one-off workflow of CAPT runs or is about to run on the Hardware, e.g. an inventory scan.
+// Busy Hardware is not selected for a TinkerbellMachine, as the workflow would race the one provisioning it.
+func Busy(hw *tinkv1.Hardware) bool {
+	return hw.GetAnnotations()[InventoryScanAnnotation] == "true"
+}
+
+// BMCRefs returns the names of the rufio Machines through which the BMC of the Hardware is reached, in the order
+// BMC Jobs try them: the bmcRef of the Hardware and those of the BMCRefsAnnotation, the one in the
+// LastSuccessfulBMCAnnotation first. It returns nil for Hardware without a bmcRef.
+func BMCRefs(hw *tinkv1.Hardware) []string {
+	if hw.Spec.BMCRef == nil {
+		return nil
+	}
+
+	refs := []string{}
+
+	for _, name := range strings.Split(hw.GetAnnotations()[BMCRefsAnnotation], ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(refs, name) {
+			refs = append(refs, name)
+		}
+	}
+
+	if !slices.Contains(refs, hw.Spec.BMCRef.Name) {
+		refs = append([]string{hw.Spec.BMCRef.Name}, refs...)
+	}
+
+	if last := slices.Index(refs, hw.GetAnnotations()[LastSuccessfulBMCAnnotation]); last > 0 {
+		refs = append(append([]string{refs[last]}, refs[:last]...), refs[last+1:]...)
+	}
+
+	return refs
+}
@@ -0,0 +1,177 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hardwareutil_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/hardwareutil"
+)
+
+func TestIP(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		hw      *tinkv1.Hardware
+		want    string
+		wantErr error
+	}{
+		"nil Hardware":  {wantErr: hardwareutil.ErrHardwareIsNil},
+		"no interfaces": {hw: &tinkv1.Hardware{}, wantErr: hardwareutil.ErrMissingInterfaces},
+		"no DHCP":       {hw: hardwareWithDHCP(nil), wantErr: hardwareutil.ErrFirstInterfaceNotDHCP},
+		"no IP":         {hw: hardwareWithDHCP(&tinkv1.DHCP{}), wantErr: hardwareutil.ErrFirstInterfaceDHCPMissingIP},
+		"IP of interface": {
+			hw:   hardwareWithDHCP(&tinkv1.DHCP{IP: &tinkv1.IP{Address: "10.0.0.10"}}),
+			want: "10.0.0.10",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			ip, err := hardwareutil.IP(tc.hw)
+			if tc.wantErr != nil {
+				g.Expect(err).To(MatchError(tc.wantErr))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(ip).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestOwner(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hw := &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "tink-system"}}
+
+	_, owned := hardwareutil.Owner(hw)
+	g.Expect(owned).To(BeFalse(), "Expected Hardware without owner labels not to be claimed")
+
+	hw.Labels = map[string]string{hardwareutil.OwnerNameLabel: "machine"}
+
+	owner, owned := hardwareutil.Owner(hw)
+	g.Expect(owned).To(BeTrue())
+	g.Expect(owner).To(Equal(types.NamespacedName{Namespace: "tink-system", Name: "machine"}),
+		"Expected the namespace of the Hardware when the owner namespace label is missing")
+
+	hw.Labels[hardwareutil.OwnerNamespaceLabel] = "default"
+
+	owner, _ = hardwareutil.Owner(hw)
+	g.Expect(owner).To(Equal(types.NamespacedName{Namespace: "default", Name: "machine"}))
+}
+
+func TestProvisioned(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hw := &tinkv1.Hardware{}
+	g.Expect(hardwareutil.Provisioned(hw)).To(BeFalse())
+
+	hw.Annotations = map[string]string{hardwareutil.ProvisionedAnnotation: "true"}
+	g.Expect(hardwareutil.Provisioned(hw)).To(BeTrue())
+}
+
+func TestPaused(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hw := &tinkv1.Hardware{}
+	g.Expect(hardwareutil.Paused(hw)).To(BeFalse())
+
+	hw.Annotations = map[string]string{hardwareutil.PausedAnnotation: ""}
+	g.Expect(hardwareutil.Paused(hw)).To(BeTrue())
+}
+
+func TestBusy(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hw := &tinkv1.Hardware{}
+	g.Expect(hardwareutil.Busy(hw)).To(BeFalse())
+
+	hw.Annotations = map[string]string{hardwareutil.InventoryScanAnnotation: "true"}
+	g.Expect(hardwareutil.Busy(hw)).To(BeTrue())
+}
+
+func TestBMCRefs(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		bmcRef      string
+		annotations map[string]string
+		want        []string
+	}{
+		"no bmcRef": {annotations: map[string]string{hardwareutil.BMCRefsAnnotation: "redfish"}},
+		"bmcRef only": {
+			bmcRef: "redfish",
+			want:   []string{"redfish"},
+		},
+		"listed BMCs in order": {
+			bmcRef:      "ipmi",
+			annotations: map[string]string{hardwareutil.BMCRefsAnnotation: "redfish, ipmi,,redfish"},
+			want:        []string{"redfish", "ipmi"},
+		},
+		"bmcRef first when not listed": {
+			bmcRef:      "redfish",
+			annotations: map[string]string{hardwareutil.BMCRefsAnnotation: "ipmi"},
+			want:        []string{"redfish", "ipmi"},
+		},
+		"last successful BMC first": {
+			bmcRef: "redfish",
+			annotations: map[string]string{
+				hardwareutil.BMCRefsAnnotation:           "redfish,ipmi,rpc",
+				hardwareutil.LastSuccessfulBMCAnnotation: "rpc",
+			},
+			want: []string{"rpc", "redfish", "ipmi"},
+		},
+		"unknown last successful BMC": {
+			bmcRef:      "redfish",
+			annotations: map[string]string{hardwareutil.LastSuccessfulBMCAnnotation: "removed"},
+			want:        []string{"redfish"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			hw := &tinkv1.Hardware{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			if tc.bmcRef != "" {
+				hw.Spec.BMCRef = &corev1.TypedLocalObjectReference{Name: tc.bmcRef}
+			}
+
+			g.Expect(hardwareutil.BMCRefs(hw)).To(Equal(tc.want))
+		})
+	}
+}
+
+func hardwareWithDHCP(dhcp *tinkv1.DHCP) *tinkv1.Hardware {
+	return &tinkv1.Hardware{Spec: tinkv1.HardwareSpec{Interfaces: []tinkv1.Interface{{DHCP: dhcp}}}}
+}
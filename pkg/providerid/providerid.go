@@ -0,0 +1,51 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package providerid builds and parses the provider IDs CAPT sets on TinkerbellMachines and Nodes, e.g.
+// tinkerbell://default/node-1 for the Hardware node-1 in the default namespace.
+package providerid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prefix is the prefix of the provider IDs of Hardware.
+const Prefix = "tinkerbell://"
+
+// ErrInvalid is returned for provider IDs which do not name Hardware.
+var ErrInvalid = errors.New("invalid provider ID")
+
+// New returns the provider ID of the Hardware with the given namespace and name.
+func New(namespace, name string) string {
+	return fmt.Sprintf("%s%s/%s", Prefix, namespace, name)
+}
+
+// Parse returns the namespace and name of the Hardware identified by the provider ID.
+func Parse(id string) (namespace, name string, err error) {
+	rest, ok := strings.CutPrefix(id, Prefix)
+	if !ok {
+		return "", "", fmt.Errorf("%w %q: expected prefix %s", ErrInvalid, id, Prefix)
+	}
+
+	namespace, name, ok = strings.Cut(rest, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("%w %q: expected %s<namespace>/<name>", ErrInvalid, id, Prefix)
+	}
+
+	return namespace, name, nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providerid_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/pkg/providerid"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		id        string
+		namespace string
+		name      string
+		wantErr   bool
+	}{
+		"provider ID of Hardware": {id: providerid.New("default", "node-1"), namespace: "default", name: "node-1"},
+		"other provider":          {id: "aws:///us-east-1a/i-0123", wantErr: true},
+		"missing namespace":       {id: "tinkerbell://node-1", wantErr: true},
+		"empty name":              {id: "tinkerbell://default/", wantErr: true},
+		"nested name":             {id: "tinkerbell://default/node/1", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			namespace, hwName, err := providerid.Parse(tc.id)
+			if tc.wantErr {
+				g.Expect(err).To(MatchError(providerid.ErrInvalid))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(namespace).To(Equal(tc.namespace))
+			g.Expect(hwName).To(Equal(tc.name))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package contract_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/cluster"
+)
+
+func reconcileTinkerbellCluster(tc *infrastructurev1.TinkerbellCluster) (ctrl.Result, error) {
+	r := &cluster.TinkerbellClusterReconciler{Client: k8sClient}
+
+	return r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tc)})
+}
+
+// From https://cluster-api.sigs.k8s.io/developer/providers/contracts/infra-cluster.
+func Test_TinkerbellCluster_contract(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("waits_for_owner_cluster_without_error", func(t *testing.T) {
+		g := NewWithT(t)
+		ns := createNamespace(t)
+
+		tc := validTinkerbellCluster(ns, nil)
+		create(t, tc)
+
+		result, err := reconcileTinkerbellCluster(tc)
+		g.Expect(err).NotTo(HaveOccurred(), "A missing owner Cluster must not be reported as error")
+		g.Expect(result).To(BeZero(), "The owner reference set by Cluster API triggers the next reconciliation")
+
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tc), tc)).To(Succeed())
+		g.Expect(tc.Status.Ready).To(BeFalse())
+	})
+
+	t.Run("reports_ready_with_control_plane_endpoint", func(t *testing.T) {
+		g := NewWithT(t)
+		ns := createNamespace(t)
+
+		c := validCluster(ns)
+		create(t, c)
+
+		tc := validTinkerbellCluster(ns, c)
+		create(t, tc)
+
+		_, err := reconcileTinkerbellCluster(tc)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tc), tc)).To(Succeed())
+		g.Expect(tc.Status.Ready).To(BeTrue(), "Expected status.ready to be set")
+		g.Expect(tc.Spec.ControlPlaneEndpoint.IsValid()).To(BeTrue(), "Expected spec.controlPlaneEndpoint to be set")
+		g.Expect(tc.Spec.ControlPlaneEndpoint.Host).To(Equal(hardwareIP))
+	})
+
+	t.Run("is_not_reconciled_while_cluster_is_paused", func(t *testing.T) {
+		g := NewWithT(t)
+		ns := createNamespace(t)
+
+		c := validCluster(ns)
+		c.Spec.Paused = true
+		create(t, c)
+
+		tc := validTinkerbellCluster(ns, c)
+		create(t, tc)
+
+		result, err := reconcileTinkerbellCluster(tc)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(BeZero())
+
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tc), tc)).To(Succeed())
+		g.Expect(tc.Status.Ready).To(BeFalse(), "Expected paused TinkerbellCluster not to be reconciled")
+	})
+
+	t.Run("removes_finalizer_on_deletion", func(t *testing.T) {
+		g := NewWithT(t)
+		ns := createNamespace(t)
+
+		c := validCluster(ns)
+		create(t, c)
+
+		tc := validTinkerbellCluster(ns, c)
+		tc.Finalizers = []string{infrastructurev1.ClusterFinalizer}
+		create(t, tc)
+
+		g.Expect(k8sClient.Delete(ctx, tc)).To(Succeed())
+
+		_, err := reconcileTinkerbellCluster(tc)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(tc), tc)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected TinkerbellCluster to be deleted, got %v", err)
+	})
+}
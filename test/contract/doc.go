@@ -0,0 +1,7 @@
+// Package contract holds the contract tests of CAPT. They run the TinkerbellCluster and TinkerbellMachine
+// reconcilers against an API server started by envtest and assert the behavior Cluster API expects from
+// infrastructure providers: the required spec and status fields, finalizers, paused clusters, the order of
+// deletion, and which conditions are requeued rather than reported as errors.
+//
+// The tests are skipped unless KUBEBUILDER_ASSETS points at the control plane binaries, see make test-contract.
+package contract
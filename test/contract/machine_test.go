@@ -0,0 +1,206 @@
+package contract_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controller/machine"
+)
+
+func reconcileTinkerbellMachine(tm *infrastructurev1.TinkerbellMachine) (ctrl.Result, error) {
+	r := &machine.TinkerbellMachineReconciler{Client: k8sClient}
+
+	return r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tm)})
+}
+
+// createReadyCluster creates a Cluster with infrastructure which is ready, in the given namespace.
+func createReadyCluster(t *testing.T, namespace string, options ...func(*clusterv1.Cluster)) {
+	t.Helper()
+	g := NewWithT(t)
+
+	c := validCluster(namespace)
+	for _, o := range options {
+		o(c)
+	}
+
+	create(t, c)
+
+	tc := validTinkerbellCluster(namespace, c)
+	create(t, tc)
+
+	tc.Status.Ready = true
+	g.Expect(k8sClient.Status().Update(context.Background(), tc)).To(Succeed())
+}
+
+// createMachine creates a Machine, its bootstrap data Secret and a TinkerbellMachine owned by it, in the given
+// namespace.
+func createMachine(
+	t *testing.T,
+	namespace string,
+	options ...func(*clusterv1.Machine),
+) *infrastructurev1.TinkerbellMachine {
+	t.Helper()
+
+	m := validMachine(namespace)
+	for _, o := range options {
+		o(m)
+	}
+
+	create(t, validSecret(namespace), m)
+
+	tm := validTinkerbellMachine(namespace, m)
+	create(t, tm)
+
+	return tm
+}
+
+// From https://cluster-api.sigs.k8s.io/developer/providers/contracts/infra-machine.
+func Test_TinkerbellMachine_contract(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("waits_for_owner_machine_without_error", func(t *testing.T) {
+		g := NewWithT(t)
+		ns := createNamespace(t)
+
+		createReadyCluster(t, ns)
+		create(t, validHardware(ns))
+
+		tm := validTinkerbellMachine(ns, nil)
+		create(t, tm)
+
+		result, err := reconcileTinkerbellMachine(tm)
+		g.Expect(err).NotTo(HaveOccurred(), "A missing owner Machine must not be reported as error")
+		g.Expect(result).To(BeZero(), "The owner reference set by Cluster API triggers the next reconciliation")
+
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tm), tm)).To(Succeed())
+		g.Expect(tm.Finalizers).To(BeEmpty())
+		g.Expect(tm.Spec.ProviderID).To(BeEmpty())
+	})
+
+	t.Run("waits_for_bootstrap_data_without_error", func(t *testing.T) {
+		g := NewWithT(t)
+		ns := createNamespace(t)
+
+		createReadyCluster(t, ns)
+		create(t, validHardware(ns))
+
+		tm := createMachine(t, ns, func(m *clusterv1.Machine) { m.Spec.Bootstrap.DataSecretName = nil })
+
+		result, err := reconcileTinkerbellMachine(tm)
+		g.Expect(err).NotTo(HaveOccurred(), "Missing bootstrap data must not be reported as error")
+		g.Expect(result).To(BeZero(), "The Machine being updated triggers the next reconciliation")
+
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tm), tm)).To(Succeed())
+		g.Expect(tm.Spec.ProviderID).To(BeEmpty())
+	})
+
+	t.Run("is_not_reconciled_while_cluster_is_paused", func(t *testing.T) {
+		g := NewWithT(t)
+		ns := createNamespace(t)
+
+		createReadyCluster(t, ns, func(c *clusterv1.Cluster) { c.Spec.Paused = true })
+		create(t, validHardware(ns))
+
+		tm := createMachine(t, ns)
+
+		result, err := reconcileTinkerbellMachine(tm)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(BeZero())
+
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tm), tm)).To(Succeed())
+		g.Expect(tm.Finalizers).To(BeEmpty(), "Expected paused TinkerbellMachine not to be reconciled")
+		g.Expect(tm.Spec.ProviderID).To(BeEmpty(), "Expected no Hardware to be selected for paused machine")
+	})
+
+	t.Run("reports_missing_hardware_as_error", func(t *testing.T) {
+		g := NewWithT(t)
+		ns := createNamespace(t)
+
+		createReadyCluster(t, ns)
+
+		tm := createMachine(t, ns)
+
+		// Reporting the error retries with the back-off of the controller rather than at a fixed interval.
+		_, err := reconcileTinkerbellMachine(tm)
+		g.Expect(err).To(MatchError(machine.ErrNoHardwareAvailable))
+
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tm), tm)).To(Succeed())
+		g.Expect(tm.Finalizers).To(ContainElement(infrastructurev1.MachineFinalizer),
+			"Expected finalizer to be added before any Hardware is claimed")
+		g.Expect(tm.Status.Ready).To(BeFalse())
+	})
+
+	t.Run("sets_provider_id_and_finalizer_once_hardware_is_selected", func(t *testing.T) {
+		g := NewWithT(t)
+		ns := createNamespace(t)
+
+		createReadyCluster(t, ns)
+		create(t, validHardware(ns))
+
+		tm := createMachine(t, ns)
+
+		_, err := reconcileTinkerbellMachine(tm)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tm), tm)).To(Succeed())
+		g.Expect(tm.Finalizers).To(ContainElement(infrastructurev1.MachineFinalizer))
+		g.Expect(tm.Spec.ProviderID).To(Equal(fmt.Sprintf("tinkerbell://%s/%s", ns, hardwareName)))
+		g.Expect(tm.Status.Ready).To(BeFalse(), "Expected machine not to be ready before it is provisioned")
+
+		hw := &tinkv1.Hardware{}
+		g.Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: ns, Name: hardwareName}, hw)).To(Succeed())
+		g.Expect(hw.Labels).To(HaveKeyWithValue(machine.HardwareOwnerNameLabel, machineName))
+		g.Expect(hw.Labels).To(HaveKeyWithValue(machine.HardwareOwnerNamespaceLabel, ns))
+
+		wf := &tinkv1.Workflow{}
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tm), wf)).To(Succeed())
+		g.Expect(wf.OwnerReferences).To(ContainElement(HaveField("UID", tm.UID)),
+			"Expected Workflow to be owned by the machine, so it is collected with it")
+	})
+
+	t.Run("releases_hardware_before_removing_finalizer_on_deletion", func(t *testing.T) {
+		g := NewWithT(t)
+		ns := createNamespace(t)
+
+		createReadyCluster(t, ns)
+		create(t, validHardware(ns))
+
+		tm := createMachine(t, ns)
+
+		_, err := reconcileTinkerbellMachine(tm)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tm), tm)).To(Succeed())
+		g.Expect(k8sClient.Delete(ctx, tm)).To(Succeed())
+
+		// The finalizer keeps the machine until its dependencies are removed.
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tm), tm)).To(Succeed())
+		g.Expect(tm.DeletionTimestamp.IsZero()).To(BeFalse())
+
+		_, err = reconcileTinkerbellMachine(tm)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(tm), tm)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected TinkerbellMachine to be deleted, got %v", err)
+
+		hw := &tinkv1.Hardware{}
+		g.Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: ns, Name: hardwareName}, hw)).To(Succeed())
+		g.Expect(hw.Labels).NotTo(HaveKey(machine.HardwareOwnerNameLabel), "Expected Hardware to be released")
+		g.Expect(hw.Finalizers).NotTo(ContainElement(infrastructurev1.MachineFinalizer))
+
+		err = k8sClient.Get(ctx, client.ObjectKey{Namespace: ns, Name: machineName}, &tinkv1.Template{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected Template to be removed, got %v", err)
+
+		err = k8sClient.Get(ctx, client.ObjectKey{Namespace: ns, Name: machineName}, &tinkv1.Workflow{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected Workflow to be removed, got %v", err)
+	})
+}
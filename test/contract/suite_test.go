@@ -0,0 +1,238 @@
+package contract_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega" //nolint:revive // one day we will remove gomega
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	tinkv1 "github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+const (
+	clusterName  = "test-cluster"
+	machineName  = "test-machine"
+	hardwareName = "test-hardware"
+	hardwareIP   = "1.1.1.1"
+)
+
+// k8sClient talks to the API server started by TestMain.
+var k8sClient client.Client
+
+func TestMain(m *testing.M) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		fmt.Println("KUBEBUILDER_ASSETS is not set, skipping the contract tests, run them with make test-contract")
+		os.Exit(0)
+	}
+
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	crdPaths, err := crdDirectories()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	scheme := runtime.NewScheme()
+
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		clusterv1.AddToScheme,
+		infrastructurev1.AddToScheme,
+		tinkv1.AddToScheme,
+		rufiov1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			fmt.Fprintln(os.Stderr, "building scheme:", err)
+
+			return 1
+		}
+	}
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     crdPaths,
+		ErrorIfCRDPathMissing: true,
+		Scheme:                scheme,
+	}
+
+	cfg, err := testEnv.Start()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "starting test environment:", err)
+
+		return 1
+	}
+
+	defer func() {
+		if err := testEnv.Stop(); err != nil {
+			fmt.Fprintln(os.Stderr, "stopping test environment:", err)
+		}
+	}()
+
+	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "creating client:", err)
+
+		return 1
+	}
+
+	return m.Run()
+}
+
+// crdDirectories returns the directories holding the CRDs of CAPT and of Cluster API, Tinkerbell and Rufio, at the
+// versions required by go.mod.
+func crdDirectories() ([]string, error) {
+	dirs := []string{filepath.Join("..", "..", "config", "crd", "bases")}
+
+	for _, module := range []string{
+		"sigs.k8s.io/cluster-api",
+		"github.com/tinkerbell/tink",
+		"github.com/tinkerbell/rufio",
+	} {
+		out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", module).Output()
+		if err != nil {
+			return nil, fmt.Errorf("locating module %s: %w", module, err)
+		}
+
+		dirs = append(dirs, filepath.Join(strings.TrimSpace(string(out)), "config", "crd", "bases"))
+	}
+
+	return dirs, nil
+}
+
+// createNamespace creates a namespace of its own for the objects of a test.
+func createNamespace(t *testing.T) string {
+	t.Helper()
+	g := NewWithT(t)
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "contract-"}}
+	g.Expect(k8sClient.Create(context.Background(), ns)).To(Succeed())
+
+	return ns.Name
+}
+
+// create creates the given objects, in order.
+func create(t *testing.T, objects ...client.Object) {
+	t.Helper()
+	g := NewWithT(t)
+
+	for _, o := range objects {
+		g.Expect(k8sClient.Create(context.Background(), o)).To(Succeed(), "Creating %T %s", o, o.GetName())
+	}
+}
+
+// ownerReference returns a reference to the given Cluster API object, which must have been created, as owner.
+func ownerReference(owner client.Object, kind string) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: clusterv1.GroupVersion.String(),
+		Kind:       kind,
+		Name:       owner.GetName(),
+		UID:        owner.GetUID(),
+	}
+}
+
+func validCluster(namespace string) *clusterv1.Cluster {
+	return &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: namespace},
+		Spec: clusterv1.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: infrastructurev1.GroupVersion.String(),
+				Kind:       "TinkerbellCluster",
+				Name:       clusterName,
+				Namespace:  namespace,
+			},
+		},
+	}
+}
+
+// validTinkerbellCluster returns a TinkerbellCluster owned by the given Cluster, unless it is nil.
+func validTinkerbellCluster(namespace string, owner *clusterv1.Cluster) *infrastructurev1.TinkerbellCluster {
+	tc := &infrastructurev1.TinkerbellCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: namespace},
+		Spec: infrastructurev1.TinkerbellClusterSpec{
+			ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: hardwareIP, Port: 6443},
+		},
+	}
+
+	if owner != nil {
+		tc.OwnerReferences = []metav1.OwnerReference{ownerReference(owner, "Cluster")}
+	}
+
+	return tc
+}
+
+func validMachine(namespace string) *clusterv1.Machine {
+	return &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      machineName,
+			Namespace: namespace,
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: clusterName},
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: clusterName,
+			Version:     ptr.To("v1.31.0"),
+			Bootstrap:   clusterv1.Bootstrap{DataSecretName: ptr.To(machineName)},
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: infrastructurev1.GroupVersion.String(),
+				Kind:       "TinkerbellMachine",
+				Name:       machineName,
+				Namespace:  namespace,
+			},
+		},
+	}
+}
+
+// validTinkerbellMachine returns a TinkerbellMachine owned by the given Machine, unless it is nil.
+func validTinkerbellMachine(namespace string, owner *clusterv1.Machine) *infrastructurev1.TinkerbellMachine {
+	tm := &infrastructurev1.TinkerbellMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      machineName,
+			Namespace: namespace,
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: clusterName},
+		},
+	}
+
+	if owner != nil {
+		tm.OwnerReferences = []metav1.OwnerReference{ownerReference(owner, "Machine")}
+	}
+
+	return tm
+}
+
+func validSecret(namespace string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: machineName, Namespace: namespace},
+		Data:       map[string][]byte{"value": []byte("#cloud-config\n")},
+	}
+}
+
+func validHardware(namespace string) *tinkv1.Hardware {
+	return &tinkv1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: hardwareName, Namespace: namespace},
+		Spec: tinkv1.HardwareSpec{
+			Disks: []tinkv1.Disk{{Device: "/dev/sda"}},
+			Interfaces: []tinkv1.Interface{{
+				DHCP:    &tinkv1.DHCP{MAC: "00:00:00:00:00:01", IP: &tinkv1.IP{Address: hardwareIP}},
+				Netboot: &tinkv1.Netboot{AllowPXE: ptr.To(true)},
+			}},
+			Metadata: &tinkv1.HardwareMetadata{Instance: &tinkv1.MetadataInstance{ID: hardwareIP}},
+		},
+	}
+}